@@ -12,12 +12,23 @@ var Cfg *Config
 
 // Config 应用配置结构
 type Config struct {
-	App        AppConfig        `mapstructure:"app"`
-	Database   DatabaseConfig   `mapstructure:"database"`
-	Redis      RedisConfig      `mapstructure:"redis"`
-	Log        LogConfig        `mapstructure:"log"`
-	Monitoring MonitoringConfig `mapstructure:"monitoring"`
-	RocketMQ   RocketMQConfig   `mapstructure:"rocketmq"`
+	App              AppConfig              `mapstructure:"app"`
+	Database         DatabaseConfig         `mapstructure:"database"`
+	Redis            RedisConfig            `mapstructure:"redis"`
+	Log              LogConfig              `mapstructure:"log"`
+	Monitoring       MonitoringConfig       `mapstructure:"monitoring"`
+	Admin            AdminConfig            `mapstructure:"admin"`
+	RocketMQ         RocketMQConfig         `mapstructure:"rocketmq"`
+	Notify           NotifyConfig           `mapstructure:"notify"`
+	Outbound         OutboundConfig         `mapstructure:"outbound"`
+	Bus              BusConfig              `mapstructure:"bus"`
+	Outbox           OutboxConfig           `mapstructure:"outbox"`
+	OrderTimeout     OrderTimeoutConfig     `mapstructure:"order_timeout"`
+	Lifecycle        LifecycleConfig        `mapstructure:"lifecycle"`
+	Ledger           LedgerConfig           `mapstructure:"ledger"`
+	BalanceReconcile BalanceReconcileConfig `mapstructure:"balance_reconcile"`
+	Risk             RiskConfig             `mapstructure:"risk"`
+	Replay           ReplayConfig           `mapstructure:"replay"`
 }
 
 // AppConfig 应用配置
@@ -73,6 +84,13 @@ type MonitoringConfig struct {
 	SwaggerEnabled     bool     `mapstructure:"swagger_enabled"`      // 是否启用 Swagger（生产环境可关闭）
 }
 
+// AdminConfig 管理接口配置
+// 管理接口（查单、补单等运维操作）必须配置 Token 或 IP 白名单，两者都未配置时拒绝访问
+type AdminConfig struct {
+	Token       string   `mapstructure:"token"`        // 管理接口 Token（Authorization: Bearer <token>）
+	IPWhitelist []string `mapstructure:"ip_whitelist"` // 管理接口 IP 白名单（支持 CIDR）
+}
+
 // RocketMQConfig RocketMQ 配置
 type RocketMQConfig struct {
 	Enabled       bool     `mapstructure:"enabled"`        // 是否启用 RocketMQ
@@ -86,6 +104,95 @@ type RocketMQConfig struct {
 	LogLevel      string   `mapstructure:"log_level"`      // SDK 日志级别（DEBUG, INFO, WARN, ERROR），默认 WARN
 }
 
+// BusConfig 消息总线配置
+// backend：auto（启用 RocketMQ 时使用 RocketMQ，否则使用 Redis）、rocketmq、redis、none（同步处理）
+type BusConfig struct {
+	Backend           string         `mapstructure:"backend"`            // 消息总线后端
+	Group             string         `mapstructure:"group"`              // Redis Streams 消费组名
+	StreamMaxLen      int64          `mapstructure:"stream_max_len"`     // 每个主题 Stream 的最大长度（近似裁剪）
+	VisibilityTimeout time.Duration  `mapstructure:"visibility_timeout"` // 消息未确认超过该时间后由其他实例认领
+	MaxAttempts       int            `mapstructure:"max_attempts"`       // 处理失败的默认最大投递次数（超过后写入死信）
+	TopicMaxAttempts  map[string]int `mapstructure:"topic_max_attempts"` // 按主题覆盖最大投递次数
+	DeadLetterTopic   string         `mapstructure:"dead_letter_topic"`  // 死信主题
+	DedupeTTL         time.Duration  `mapstructure:"dedupe_ttl"`         // 幂等消费记录保留时间
+}
+
+// OutboxConfig 事务发件箱中继配置
+type OutboxConfig struct {
+	PollInterval time.Duration `mapstructure:"poll_interval"` // 轮询待发送事件的间隔
+	BatchSize    int           `mapstructure:"batch_size"`    // 每批发送的最大事件数
+	Retention    time.Duration `mapstructure:"retention"`     // 已发送事件的保留时间
+}
+
+// OrderTimeoutConfig 订单超时配置（Redis 有序集合定时队列 + 数据库对账扫描）
+type OrderTimeoutConfig struct {
+	QueueEnabled  bool          `mapstructure:"queue_enabled"`  // 是否启用 Redis 定时队列（消息总线不可用时使用）
+	DrainInterval time.Duration `mapstructure:"drain_interval"` // 主节点拉取到期订单的间隔
+	BatchSize     int           `mapstructure:"batch_size"`     // 每次拉取的最大订单数
+	Concurrency   int           `mapstructure:"concurrency"`    // 并发处理超时订单的数量
+	RetryDelay    time.Duration `mapstructure:"retry_delay"`    // 处理失败（或节点崩溃）后重新处理的间隔
+	ScanInterval  time.Duration `mapstructure:"scan_interval"`  // 数据库对账扫描间隔
+	ScanLookback  time.Duration `mapstructure:"scan_lookback"`  // 数据库对账扫描的订单创建时间范围
+}
+
+// LifecycleConfig 后台服务管理配置（主节点选举与优雅停止）
+type LifecycleConfig struct {
+	DrainTimeout  time.Duration `mapstructure:"drain_timeout"`  // 收到停止信号后等待后台服务退出的最长时间
+	LeaseTTL      time.Duration `mapstructure:"lease_ttl"`      // 主节点租约时长
+	RenewInterval time.Duration `mapstructure:"renew_interval"` // 主节点续约（及其他实例竞选）间隔
+}
+
+// LedgerConfig 资金账本配置
+type LedgerConfig struct {
+	SnapshotInterval time.Duration `mapstructure:"snapshot_interval"` // 账户余额快照间隔（每个账户每天保留一条，按间隔刷新当天快照）
+}
+
+// BalanceReconcileConfig 余额核对配置（Redis 与数据库余额、预占手续费、资金流水）
+type BalanceReconcileConfig struct {
+	Interval        time.Duration `mapstructure:"interval"`         // 主节点核对间隔
+	ConfirmDelay    time.Duration `mapstructure:"confirm_delay"`    // 两次检测的间隔（两次都存在的偏差才报告，排除处理中的订单）
+	AutoRepair      bool          `mapstructure:"auto_repair"`      // 是否自动修复阈值内的 Redis 偏差
+	RepairThreshold int64         `mapstructure:"repair_threshold"` // 自动修复的最大偏差（分），超出时告警
+}
+
+// RiskConfig 买家风控配置（滑动窗口内的下单次数、未支付订单数，限制为 0 表示不检查）
+type RiskConfig struct {
+	Enabled                 bool          `mapstructure:"enabled"`                    // 是否启用（黑名单在未启用时同样生效）
+	Window                  time.Duration `mapstructure:"window"`                     // 滑动窗口长度
+	MaxOrdersPerIP          int           `mapstructure:"max_orders_per_ip"`          // 窗口内同一 IP 的最大订单数
+	MaxOrdersPerFingerprint int           `mapstructure:"max_orders_per_fingerprint"` // 窗口内同一设备指纹的最大订单数
+	MaxUnpaidPerBuyer       int           `mapstructure:"max_unpaid_per_buyer"`       // 窗口内同一买家（收银台用户ID、支付宝 buyer_id）的最大未支付订单数
+	ChallengeRatio          float64       `mapstructure:"challenge_ratio"`            // 达到限制的该比例时要求用户确认（challenge），超过限制时拒绝（block）
+}
+
+// ReplayConfig 商户接口防重放配置（请求携带 timestamp、nonce 并参与签名）
+type ReplayConfig struct {
+	Window     time.Duration `mapstructure:"window"`      // 允许的时间戳偏差（请求时间与服务器时间相差超过该值视为过期），nonce 保留 2 倍时长
+	RequireAll bool          `mapstructure:"require_all"` // 是否对所有商户强制检查（关闭时只检查开启了 replay_protect 的商户）
+}
+
+// NotifyConfig 商户通知投递配置
+type NotifyConfig struct {
+	Workers          int           `mapstructure:"workers"`           // 投递 worker 数量
+	QueueSize        int           `mapstructure:"queue_size"`        // 本地待投递队列长度
+	HostConcurrency  int           `mapstructure:"host_concurrency"`  // 单个商户主机的最大并发投递数
+	BreakerThreshold int           `mapstructure:"breaker_threshold"` // 单个主机连续失败多少次后熔断
+	BreakerCooldown  time.Duration `mapstructure:"breaker_cooldown"`  // 熔断时长（到期后放行一个探测请求）
+	MaxRetries       int           `mapstructure:"max_retries"`       // 最大投递次数（达到后标记为最大重试状态）
+}
+
+// OutboundConfig 出站 HTTP 请求安全配置（商户通知、上游通道调用）
+// 默认拒绝访问内网、环回、链路本地等地址，AllowHosts 中的主机名（支持 *.example.com）、IP 或 CIDR 除外
+// 租户级白名单在系统配置 outbound.allow_list 中维护
+type OutboundConfig struct {
+	AllowedSchemes   []string      `mapstructure:"allowed_schemes"`    // 允许的协议
+	AllowedPorts     []int         `mapstructure:"allowed_ports"`      // 允许的端口
+	AllowHosts       []string      `mapstructure:"allow_hosts"`        // 全局放行的主机名、IP 或 CIDR
+	MaxRedirects     int           `mapstructure:"max_redirects"`      // 最大重定向次数
+	MaxResponseBytes int64         `mapstructure:"max_response_bytes"` // 响应体大小上限（字节）
+	Timeout          time.Duration `mapstructure:"timeout"`            // 默认请求超时
+}
+
 // Load 加载配置文件
 // 如果 configPath 为空，则根据环境变量 APP_ENV 自动选择配置文件
 // APP_ENV 可选值: dev(默认), test, prod
@@ -148,6 +255,50 @@ func setDefaults() {
 	viper.SetDefault("rocketmq.port", 8081)
 	viper.SetDefault("rocketmq.producer_group", "pay-producer")
 	viper.SetDefault("rocketmq.consumer_group", "pay-consumer")
+	viper.SetDefault("bus.backend", "auto")
+	viper.SetDefault("bus.group", "pay-core")
+	viper.SetDefault("bus.stream_max_len", 100000)
+	viper.SetDefault("bus.visibility_timeout", "5m")
+	viper.SetDefault("bus.max_attempts", 16)
+	viper.SetDefault("bus.dead_letter_topic", "dead-letter")
+	viper.SetDefault("bus.dedupe_ttl", "24h")
+	viper.SetDefault("outbox.poll_interval", "1s")
+	viper.SetDefault("outbox.batch_size", 100)
+	viper.SetDefault("outbox.retention", "72h")
+	viper.SetDefault("order_timeout.queue_enabled", true)
+	viper.SetDefault("order_timeout.drain_interval", "1s")
+	viper.SetDefault("order_timeout.batch_size", 100)
+	viper.SetDefault("order_timeout.concurrency", 8)
+	viper.SetDefault("order_timeout.retry_delay", "1m")
+	viper.SetDefault("order_timeout.scan_interval", "5m")
+	viper.SetDefault("order_timeout.scan_lookback", "24h")
+	viper.SetDefault("lifecycle.drain_timeout", "30s")
+	viper.SetDefault("lifecycle.lease_ttl", "15s")
+	viper.SetDefault("lifecycle.renew_interval", "5s")
+	viper.SetDefault("ledger.snapshot_interval", "1h")
+	viper.SetDefault("balance_reconcile.interval", "10m")
+	viper.SetDefault("balance_reconcile.confirm_delay", "5s")
+	viper.SetDefault("balance_reconcile.auto_repair", false)
+	viper.SetDefault("balance_reconcile.repair_threshold", 10000)
+	viper.SetDefault("risk.enabled", false)
+	viper.SetDefault("risk.window", "1h")
+	viper.SetDefault("risk.max_orders_per_ip", 20)
+	viper.SetDefault("risk.max_orders_per_fingerprint", 10)
+	viper.SetDefault("risk.max_unpaid_per_buyer", 3)
+	viper.SetDefault("risk.challenge_ratio", 0.8)
+	viper.SetDefault("replay.window", "5m")
+	viper.SetDefault("replay.require_all", false)
+	viper.SetDefault("notify.workers", 32)
+	viper.SetDefault("notify.queue_size", 256)
+	viper.SetDefault("notify.host_concurrency", 4)
+	viper.SetDefault("notify.breaker_threshold", 5)
+	viper.SetDefault("notify.breaker_cooldown", "60s")
+	viper.SetDefault("notify.max_retries", 5)
+	viper.SetDefault("outbound.allowed_schemes", []string{"http", "https"})
+	viper.SetDefault("outbound.allowed_ports", []int{80, 443, 8080, 8443})
+	viper.SetDefault("outbound.max_redirects", 3)
+	viper.SetDefault("outbound.max_response_bytes", 1048576)
+	viper.SetDefault("outbound.timeout", "10s")
 }
 
 // GetDSN 获取数据库连接字符串
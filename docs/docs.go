@@ -190,7 +190,7 @@ const docTemplate = `{
         },
         "/health": {
             "get": {
-                "description": "检查服务健康状态，包括数据库和 Redis 连接状态",
+                "description": "检查服务健康状态，包括数据库和 Redis 连接状态、主节点选举状态",
                 "produces": [
                     "application/json"
                 ],
@@ -0,0 +1,207 @@
+package alipay
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/transform"
+)
+
+// 对账单类型
+const (
+	BillTypeTrade      = "trade"        // 商户基于支付宝交易收单的业务账单
+	BillTypeSignCustom = "signcustomer" // 基于商户支付宝余额收入及支出等资金变动的账务账单
+)
+
+// 业务明细中的业务类型
+const (
+	BillBizTypeTrade  = "交易"
+	BillBizTypeRefund = "退款"
+)
+
+// maxBillSize 对账单压缩包大小上限（防止异常响应占满内存）
+const maxBillSize = 200 << 20
+
+// BillDownloadURLQuery 查询对账单下载地址
+// 参考 Python: alipay.api_alipay_data_dataservice_bill_downloadurl_query
+// billDate 按天为 yyyy-MM-dd（当天账单次日 9 点后可下载），按月为 yyyy-MM；下载地址 30 秒内有效
+func (c *Client) BillDownloadURLQuery(billType, billDate string) (string, error) {
+	if billType == "" {
+		billType = BillTypeTrade
+	}
+	if billDate == "" {
+		return "", fmt.Errorf("bill_date 不能为空")
+	}
+
+	responseNode, err := c.execute("alipay.data.dataservice.bill.downloadurl.query", map[string]interface{}{
+		"bill_type": billType,
+		"bill_date": billDate,
+	}, "支付宝对账单下载地址查询")
+	if err != nil {
+		return "", err
+	}
+
+	downloadURL := getString(responseNode, "bill_download_url")
+	if downloadURL == "" {
+		return "", fmt.Errorf("响应格式错误: 缺少 bill_download_url")
+	}
+	return downloadURL, nil
+}
+
+// DownloadBill 下载对账单压缩包（不记录 query_log，内容过大）
+func (c *Client) DownloadBill(downloadURL string) ([]byte, error) {
+	resp, err := c.HTTPClient.Get(downloadURL)
+	if err != nil {
+		return nil, fmt.Errorf("下载对账单失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("下载对账单失败: HTTP %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBillSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取对账单失败: %w", err)
+	}
+	if len(data) > maxBillSize {
+		return nil, fmt.Errorf("对账单超过 %d 字节", maxBillSize)
+	}
+	return data, nil
+}
+
+// TradeBillLine 业务账单明细（金额单位为分）
+type TradeBillLine struct {
+	TradeNo       string // 支付宝交易号
+	OutTradeNo    string // 商户订单号
+	BizType       string // 业务类型：交易、退款
+	Subject       string // 商品名称
+	CreateTime    string // 创建时间
+	FinishTime    string // 完成时间
+	TotalAmount   int    // 订单金额
+	ReceiptAmount int    // 商家实收
+	OutRequestNo  string // 退款批次号/请求号
+	ServiceFee    int    // 服务费
+}
+
+// tradeBillColumns 业务明细列名与字段的对应（按列名匹配，兼容不同版本账单的列顺序）
+var tradeBillColumns = map[string]func(line *TradeBillLine, value string){
+	"支付宝交易号":    func(l *TradeBillLine, v string) { l.TradeNo = v },
+	"商户订单号":     func(l *TradeBillLine, v string) { l.OutTradeNo = v },
+	"业务类型":      func(l *TradeBillLine, v string) { l.BizType = v },
+	"商品名称":      func(l *TradeBillLine, v string) { l.Subject = v },
+	"创建时间":      func(l *TradeBillLine, v string) { l.CreateTime = v },
+	"完成时间":      func(l *TradeBillLine, v string) { l.FinishTime = v },
+	"订单金额（元）":   func(l *TradeBillLine, v string) { l.TotalAmount = parseAmount(v) },
+	"商家实收（元）":   func(l *TradeBillLine, v string) { l.ReceiptAmount = parseAmount(v) },
+	"退款批次号/请求号": func(l *TradeBillLine, v string) { l.OutRequestNo = v },
+	"服务费（元）":    func(l *TradeBillLine, v string) { l.ServiceFee = parseAmount(v) },
+}
+
+// ParseTradeBill 解析业务账单
+// data 可以是支付宝下载的 zip 压缩包（解析其中的业务明细文件，跳过汇总文件），也可以是解压后的 csv 文件；
+// 文件为 GBK 编码，以 # 开头的行为说明和合计，退款明细的金额为负数
+func ParseTradeBill(data []byte) ([]TradeBillLine, error) {
+	if !bytes.HasPrefix(data, []byte("PK")) {
+		return parseTradeBillCSV(bytes.NewReader(data))
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("解压对账单失败: %w", err)
+	}
+
+	var lines []TradeBillLine
+	found := false
+	for _, file := range zr.File {
+		name := billFileName(file)
+		if !strings.HasSuffix(strings.ToLower(name), ".csv") || strings.Contains(name, "汇总") {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("打开对账单文件 %s 失败: %w", name, err)
+		}
+		fileLines, err := parseTradeBillCSV(io.LimitReader(rc, maxBillSize))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("解析对账单文件 %s 失败: %w", name, err)
+		}
+		lines = append(lines, fileLines...)
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("对账单压缩包中没有业务明细文件")
+	}
+	return lines, nil
+}
+
+// billFileName 压缩包内的文件名（支付宝压缩包未设置 UTF-8 标记，文件名为 GBK 编码）
+func billFileName(file *zip.File) string {
+	if file.NonUTF8 {
+		if name, _, err := transform.String(simplifiedchinese.GBK.NewDecoder(), file.Name); err == nil {
+			return name
+		}
+	}
+	return file.Name
+}
+
+// parseTradeBillCSV 解析 GBK 编码的业务明细 csv
+func parseTradeBillCSV(r io.Reader) ([]TradeBillLine, error) {
+	reader := csv.NewReader(transform.NewReader(r, simplifiedchinese.GBK.NewDecoder()))
+	reader.FieldsPerRecord = -1 // 说明行和明细行的列数不同
+	reader.LazyQuotes = true
+
+	var setters []func(line *TradeBillLine, value string)
+	var lines []TradeBillLine
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取对账单失败: %w", err)
+		}
+		if len(record) == 0 || strings.HasPrefix(strings.TrimSpace(record[0]), "#") {
+			continue
+		}
+
+		// 第一个非说明行为列名
+		if setters == nil {
+			setters = make([]func(line *TradeBillLine, value string), len(record))
+			matched := 0
+			for i, name := range record {
+				if setter, ok := tradeBillColumns[strings.TrimSpace(name)]; ok {
+					setters[i] = setter
+					matched++
+				}
+			}
+			if setters[0] == nil || matched < 2 {
+				return nil, fmt.Errorf("不是业务账单: 列名 %v", record)
+			}
+			continue
+		}
+
+		var line TradeBillLine
+		for i, value := range record {
+			if i < len(setters) && setters[i] != nil {
+				// 账单中的单号带有制表符，防止 Excel 显示为科学计数法
+				setters[i](&line, strings.TrimSpace(value))
+			}
+		}
+		if line.TradeNo == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	if setters == nil {
+		return nil, fmt.Errorf("不是业务账单: 缺少列名")
+	}
+	return lines, nil
+}
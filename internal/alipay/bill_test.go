@@ -0,0 +1,72 @@
+package alipay
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// testTradeBill 业务明细示例（与支付宝下载的格式一致，单号后带制表符）
+const testTradeBill = `#支付宝业务明细查询
+#账号：[20880000000000000156]
+#起始日期：[2024年01月01日 00:00:00]   终止日期：[2024年01月02日 00:00:00]
+#-----------------------------------------业务明细列表----------------------------------------
+支付宝交易号,商户订单号,业务类型,商品名称,创建时间,完成时间,门店编号,门店名称,操作员,终端号,对方账户,订单金额（元）,商家实收（元）,支付宝红包（元）,集分宝（元）,支付宝优惠（元）,商家优惠（元）,券核销金额（元）,券名称,商家红包消费金额（元）,卡消费金额（元）,退款批次号/请求号,服务费（元）,分润（元）,备注
+2024010122001400000001	,P20240101000001	,交易,商品,2024-01-01 10:00:00,2024-01-01 10:00:05,,,,,test@example.com,100.29,100.29,0.00,0.00,0.00,0.00,0.00,,0.00,0.00,,-0.60,0.00,
+2024010122001400000001	,P20240101000001	,退款,商品,2024-01-01 10:00:00,2024-01-01 12:00:00,,,,,test@example.com,-0.29,-0.29,0.00,0.00,0.00,0.00,0.00,,0.00,0.00,R1	,0.00,0.00,
+#-----------------------------------------业务明细列表结束------------------------------------
+#交易合计：1笔，商家实收共100.29元，商家优惠共0.00元
+#退款合计：1笔，商家实收退款共-0.29元，商家优惠退款共0.00元
+#导出时间：[2024年01月02日 09:00:00]
+`
+
+func gbk(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatalf("encode GBK: %v", err)
+	}
+	return data
+}
+
+func TestParseTradeBillZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"20880000000000000156_20240101_业务明细.csv":     testTradeBill,
+		"20880000000000000156_20240101_业务明细(汇总).csv": "#汇总\n",
+	} {
+		// 支付宝压缩包的文件名为 GBK 编码
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: string(gbk(t, name)), NonUTF8: true})
+		if err != nil {
+			t.Fatalf("create zip entry: %v", err)
+		}
+		w.Write(gbk(t, content))
+	}
+	zw.Close()
+
+	lines, err := ParseTradeBill(buf.Bytes())
+	if err != nil {
+		t.Fatalf("ParseTradeBill: %v", err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("lines = %d, want 2", len(lines))
+	}
+
+	trade, refund := lines[0], lines[1]
+	if trade.TradeNo != "2024010122001400000001" || trade.OutTradeNo != "P20240101000001" ||
+		trade.BizType != BillBizTypeTrade || trade.TotalAmount != 10029 || trade.ServiceFee != -60 {
+		t.Fatalf("trade = %+v", trade)
+	}
+	if refund.BizType != BillBizTypeRefund || refund.TotalAmount != -29 || refund.OutRequestNo != "R1" {
+		t.Fatalf("refund = %+v", refund)
+	}
+}
+
+func TestParseTradeBillNotTradeBill(t *testing.T) {
+	if _, err := ParseTradeBill(gbk(t, "#账务明细\n账务流水号,业务流水号\n1,2\n")); err == nil {
+		t.Fatal("ParseTradeBill accepted a non-trade bill")
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/golang-pay-core/internal/database"
 	"github.com/golang-pay-core/internal/logger"
 	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/safehttp"
 	"go.uber.org/zap"
 )
 
@@ -164,10 +165,8 @@ func NewClient(product *models.AlipayProduct, notifyURL string, isOrder bool) (*
 		proxies["https"] = proxyURL
 	}
 
-	// 创建 HTTP 客户端
-	httpClient := &http.Client{
-		Timeout: 15 * time.Second,
-	}
+	// 创建 HTTP 客户端（带 SSRF 防护）
+	httpClient := safehttp.NewClient(15 * time.Second)
 
 	client := &Client{
 		AppID:           appID,
@@ -91,6 +91,7 @@ func ParseNotifyParams(params map[string]string) (*NotifyData, error) {
 	notifyData.OutTradeNo = outTradeNo
 	notifyData.TradeNo = params["trade_no"]
 	notifyData.TradeStatus = params["trade_status"]
+	notifyData.BuyerID = params["buyer_id"]
 
 	// 解析金额
 	if totalAmount, ok := params["total_amount"]; ok {
@@ -110,6 +111,7 @@ type NotifyData struct {
 	TradeNo     string // 支付宝交易号
 	TradeStatus string // 交易状态
 	TotalAmount int    // 金额（分）
+	BuyerID     string // 买家支付宝用户ID
 }
 
 // GetAlipayProductByID 根据产品ID获取支付宝产品信息
@@ -0,0 +1,321 @@
+package alipay
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TradeRefundResult 统一收单交易退款结果
+type TradeRefundResult struct {
+	TradeNo      string // 支付宝交易号
+	OutTradeNo   string // 商户订单号
+	OutRequestNo string // 退款请求号
+	RefundFee    int    // 该笔交易累计已退款金额（分）
+	FundChange   bool   // 本次退款是否发生了资金变化
+	GmtRefundPay string // 退款支付时间
+}
+
+// RefundQueryResult 统一收单交易退款查询结果
+type RefundQueryResult struct {
+	TradeNo      string // 支付宝交易号
+	OutTradeNo   string // 商户订单号
+	OutRequestNo string // 退款请求号
+	RefundAmount int    // 本次退款金额（分）
+	RefundStatus string // 退款状态：REFUND_SUCCESS 表示退款成功，为空表示退款未成功
+}
+
+// TradeRefund 统一收单交易退款
+// 参考 Python: alipay.api_alipay_trade_refund
+// outTradeNo 与 tradeNo 至少传一个，refundAmount 单位为分，outRequestNo 用于标识同一笔交易的多次（部分）退款
+func (c *Client) TradeRefund(outTradeNo, tradeNo string, refundAmount int, outRequestNo, reason string) (*TradeRefundResult, error) {
+	if outTradeNo == "" && tradeNo == "" {
+		return nil, fmt.Errorf("out_trade_no 和 trade_no 不能同时为空")
+	}
+
+	bizContent := map[string]interface{}{
+		"refund_amount": formatAmount(refundAmount),
+	}
+	if outTradeNo != "" {
+		bizContent["out_trade_no"] = outTradeNo
+	}
+	if tradeNo != "" {
+		bizContent["trade_no"] = tradeNo
+	}
+	if outRequestNo != "" {
+		bizContent["out_request_no"] = outRequestNo
+	}
+	if reason != "" {
+		bizContent["refund_reason"] = reason
+	}
+
+	responseNode, err := c.execute("alipay.trade.refund", bizContent, "支付宝退款API请求")
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TradeRefundResult{
+		TradeNo:      getString(responseNode, "trade_no"),
+		OutTradeNo:   getString(responseNode, "out_trade_no"),
+		OutRequestNo: outRequestNo,
+		RefundFee:    parseAmount(getString(responseNode, "refund_fee")),
+		FundChange:   getString(responseNode, "fund_change") == "Y",
+		GmtRefundPay: getString(responseNode, "gmt_refund_pay"),
+	}
+
+	return result, nil
+}
+
+// TradeFastpayRefundQuery 统一收单交易退款查询
+// 参考 Python: alipay.api_alipay_trade_fastpay_refund_query
+// outRequestNo 为退款请求号，未传入时支付宝默认使用 out_trade_no
+func (c *Client) TradeFastpayRefundQuery(outTradeNo, tradeNo, outRequestNo string) (*RefundQueryResult, error) {
+	if outTradeNo == "" && tradeNo == "" {
+		return nil, fmt.Errorf("out_trade_no 和 trade_no 不能同时为空")
+	}
+
+	bizContent := map[string]interface{}{}
+	if outTradeNo != "" {
+		bizContent["out_trade_no"] = outTradeNo
+	}
+	if tradeNo != "" {
+		bizContent["trade_no"] = tradeNo
+	}
+	if outRequestNo != "" {
+		bizContent["out_request_no"] = outRequestNo
+	} else {
+		bizContent["out_request_no"] = outTradeNo
+	}
+	// 需要返回退款状态
+	bizContent["query_options"] = []string{"gmt_refund_pay"}
+
+	responseNode, err := c.execute("alipay.trade.fastpay.refund.query", bizContent, "支付宝退款查询API请求")
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RefundQueryResult{
+		TradeNo:      getString(responseNode, "trade_no"),
+		OutTradeNo:   getString(responseNode, "out_trade_no"),
+		OutRequestNo: getString(responseNode, "out_request_no"),
+		RefundAmount: parseAmount(getString(responseNode, "refund_amount")),
+		RefundStatus: getString(responseNode, "refund_status"),
+	}
+
+	return result, nil
+}
+
+// TradeQueryResult 统一收单线下交易查询结果
+type TradeQueryResult struct {
+	TradeNo        string // 支付宝交易号
+	OutTradeNo     string // 商户订单号
+	TradeStatus    string // 交易状态：WAIT_BUYER_PAY、TRADE_CLOSED、TRADE_SUCCESS、TRADE_FINISHED
+	TotalAmount    int    // 交易金额（分）
+	BuyerPayAmount int    // 买家实付金额（分）
+	BuyerLogonID   string // 买家支付宝账号
+	SendPayDate    string // 交易支付时间
+}
+
+// TradeQuery 统一收单线下交易查询
+// 参考 Python: alipay.api_alipay_trade_query
+// outTradeNo 与 tradeNo 至少传一个；交易不存在时返回的错误包含 ACQ.TRADE_NOT_EXIST
+func (c *Client) TradeQuery(outTradeNo, tradeNo string) (*TradeQueryResult, error) {
+	if outTradeNo == "" && tradeNo == "" {
+		return nil, fmt.Errorf("out_trade_no 和 trade_no 不能同时为空")
+	}
+
+	bizContent := map[string]interface{}{}
+	if outTradeNo != "" {
+		bizContent["out_trade_no"] = outTradeNo
+	}
+	if tradeNo != "" {
+		bizContent["trade_no"] = tradeNo
+	}
+
+	responseNode, err := c.execute("alipay.trade.query", bizContent, "支付宝交易查询API请求")
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TradeQueryResult{
+		TradeNo:        getString(responseNode, "trade_no"),
+		OutTradeNo:     getString(responseNode, "out_trade_no"),
+		TradeStatus:    getString(responseNode, "trade_status"),
+		TotalAmount:    parseAmount(getString(responseNode, "total_amount")),
+		BuyerPayAmount: parseAmount(getString(responseNode, "buyer_pay_amount")),
+		BuyerLogonID:   getString(responseNode, "buyer_logon_id"),
+		SendPayDate:    getString(responseNode, "send_pay_date"),
+	}
+
+	return result, nil
+}
+
+// IsTradeNotExist 判断错误是否为交易不存在（用户未扫码/未登录时支付宝尚未创建交易）
+func IsTradeNotExist(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "ACQ.TRADE_NOT_EXIST")
+}
+
+// TradeCloseResult 统一收单交易关闭结果
+type TradeCloseResult struct {
+	TradeNo    string // 支付宝交易号
+	OutTradeNo string // 商户订单号
+}
+
+// TradeClose 统一收单交易关闭
+// 参考 Python: alipay.api_alipay_trade_close
+// 用于交易创建后用户一定时间内未支付时关闭交易，关闭后买家无法再付款
+// 交易不存在时返回的错误包含 ACQ.TRADE_NOT_EXIST，交易已支付或已关闭时包含 ACQ.TRADE_STATUS_ERROR
+func (c *Client) TradeClose(outTradeNo, tradeNo string) (*TradeCloseResult, error) {
+	if outTradeNo == "" && tradeNo == "" {
+		return nil, fmt.Errorf("out_trade_no 和 trade_no 不能同时为空")
+	}
+
+	bizContent := map[string]interface{}{}
+	if outTradeNo != "" {
+		bizContent["out_trade_no"] = outTradeNo
+	}
+	if tradeNo != "" {
+		bizContent["trade_no"] = tradeNo
+	}
+
+	responseNode, err := c.execute("alipay.trade.close", bizContent, "支付宝关闭交易API请求（订单超时）")
+	if err != nil {
+		return nil, err
+	}
+
+	return &TradeCloseResult{
+		TradeNo:    getString(responseNode, "trade_no"),
+		OutTradeNo: getString(responseNode, "out_trade_no"),
+	}, nil
+}
+
+// IsTradeStatusError 判断错误是否为交易状态不合法（交易已支付或已关闭，无法关闭）
+func IsTradeStatusError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "ACQ.TRADE_STATUS_ERROR")
+}
+
+// execute 调用支付宝开放平台接口（非页面跳转类接口的公共逻辑）
+// 构建公共参数、签名、以表单方式 POST 到网关，并解析 {method}_response 节点
+// 响应码不是 10000 时返回 "msg,sub_code,sub_msg" 格式的错误（与 TradePrecreate 保持一致）
+func (c *Client) execute(method string, bizContent map[string]interface{}, remarks string) (map[string]interface{}, error) {
+	// 将 biz_content 转换为 JSON 字符串
+	bizContentJSON, err := json.Marshal(bizContent)
+	if err != nil {
+		return nil, fmt.Errorf("序列化 biz_content 失败: %w", err)
+	}
+
+	// 构建请求参数
+	params := map[string]interface{}{
+		"app_id":      c.AppID,
+		"method":      method,
+		"charset":     "utf-8",
+		"sign_type":   c.SignType,
+		"timestamp":   time.Now().Format("2006-01-02 15:04:05"),
+		"version":     "1.0",
+		"biz_content": string(bizContentJSON),
+	}
+
+	// 如果有 app_auth_token，添加到参数中
+	if c.AppAuthToken != "" {
+		params["app_auth_token"] = c.AppAuthToken
+	}
+
+	// 证书模式下，添加证书 SN
+	if c.IsDC {
+		params["app_cert_sn"] = c.AppCertSN
+		params["alipay_root_cert_sn"] = c.AlipayRootCertSN
+	}
+
+	// 生成签名
+	sign, err := signParams(params, c.AppPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("生成签名失败: %w", err)
+	}
+	params["sign"] = sign
+
+	// 发送 POST 请求（表单格式）
+	resp, err := c.sendFormRequest(c.Gateway, c.buildQueryString(params), remarks)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+
+	// 解析响应：支付宝返回 JSON，格式为 {"alipay_trade_refund_response": {...}, "sign": "..."}
+	nodeName := strings.ReplaceAll(method, ".", "_") + "_response"
+	var respMap map[string]interface{}
+	if err := json.Unmarshal([]byte(resp), &respMap); err != nil {
+		// 兼容 form 格式的响应
+		respMap, err = parseAlipayFormResponse(resp)
+		if err != nil {
+			return nil, fmt.Errorf("解析响应失败: %w", err)
+		}
+	}
+
+	responseNode, ok := respMap[nodeName].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("响应格式错误: 缺少 %s", nodeName)
+	}
+
+	code := getString(responseNode, "code")
+	if code == "" {
+		return nil, fmt.Errorf("响应格式错误: 缺少 code 字段")
+	}
+
+	if code != "10000" {
+		return nil, fmt.Errorf("%s,%s,%s",
+			getString(responseNode, "msg"),
+			getString(responseNode, "sub_code"),
+			getString(responseNode, "sub_msg"))
+	}
+
+	return responseNode, nil
+}
+
+// sendFormRequest 以 application/x-www-form-urlencoded 方式发送 POST 请求，并记录 query_log
+func (c *Client) sendFormRequest(requestURL, body, remarks string) (string, error) {
+	resp, err := c.HTTPClient.Post(requestURL, "application/x-www-form-urlencoded;charset=utf-8", strings.NewReader(body))
+	if err != nil {
+		go c.createQueryLog(requestURL, "POST", body, "", err.Error(), remarks)
+		return "", fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 记录 query_log
+	go c.createQueryLog(requestURL, "POST", body, fmt.Sprintf("%d", resp.StatusCode), string(bodyBytes), remarks)
+
+	return string(bodyBytes), nil
+}
+
+// formatAmount 将金额（分）格式化为支付宝要求的元（保留两位小数）
+func formatAmount(cents int) string {
+	return fmt.Sprintf("%.2f", float64(cents)/100)
+}
+
+// parseAmount 将支付宝返回的金额（元）解析为分
+func parseAmount(yuan string) int {
+	if yuan == "" {
+		return 0
+	}
+	amount, err := strconv.ParseFloat(yuan, 64)
+	if err != nil {
+		return 0
+	}
+	// 四舍五入，避免浮点误差（如 0.29*100 = 28.999...）；对账单中的退款金额为负数
+	return int(math.Round(amount * 100))
+}
+
+// getString 从响应节点中获取字符串字段
+func getString(node map[string]interface{}, key string) string {
+	if v, ok := node[key].(string); ok {
+		return v
+	}
+	return ""
+}
@@ -0,0 +1,332 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/response"
+	"github.com/golang-pay-core/internal/service"
+	"go.uber.org/zap"
+)
+
+// AdminController 管理接口控制器（运维操作，需通过 AdminAuth 认证）
+type AdminController struct {
+	orderQueryService   *service.OrderQueryService
+	notificationService *service.NotificationManageService
+	deadLetterService   *service.DeadLetterService
+	riskService         *service.RiskService
+}
+
+// NewAdminController 创建管理接口控制器
+func NewAdminController() *AdminController {
+	return &AdminController{
+		orderQueryService:   service.NewOrderQueryService(),
+		notificationService: service.NewNotificationManageService(),
+		deadLetterService:   service.NewDeadLetterService(),
+		riskService:         service.NewRiskService(),
+	}
+}
+
+// QueryUpstream 主动向上游查单（上游已支付而本地未成功时自动补单）
+// @Summary 上游查单
+// @Description 向上游查询订单真实交易状态；上游已支付而本地未成功时执行补单（成功钩子、通知商户）
+// @Tags 管理
+// @Produce json
+// @Param order_no path string true "订单号" example:"PAY20240101120000001"
+// @Success 200 {object} response.Response{data=service.UpstreamQueryResult} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 401 {object} response.Response "未授权访问"
+// @Router /api/v1/admin/orders/{order_no}/query-upstream [post]
+func (c *AdminController) QueryUpstream(ctx *gin.Context) {
+	orderNo := ctx.Param("order_no")
+	if orderNo == "" {
+		response.Fail(ctx, http.StatusBadRequest, "订单号不能为空")
+		return
+	}
+
+	result, err := c.orderQueryService.QueryUpstream(ctx.Request.Context(), orderNo)
+	if err != nil {
+		if errors.Is(err, service.ErrQueryNotSupported) {
+			response.Fail(ctx, http.StatusBadRequest, err.Error())
+			return
+		}
+		logger.Logger.Warn("上游查单失败",
+			zap.String("order_no", orderNo),
+			zap.Error(err))
+		response.Fail(ctx, http.StatusInternalServerError, "上游查单失败: "+err.Error())
+		return
+	}
+
+	response.Success(ctx, result)
+}
+
+// Renotify 重新通知商户（不限商户，包括已达到最大重试次数的订单）
+// @Summary 重新通知商户
+// @Description 重置订单通知状态并立即重新投递；ver 为通知版本号（可选），传入时版本号不一致返回 7330
+// @Tags 管理
+// @Produce json
+// @Param order_no path string true "订单号" example:"PAY20240101120000001"
+// @Param ver query int false "期望的通知版本号"
+// @Success 200 {object} response.Response{data=service.NotificationInfo} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 401 {object} response.Response "未授权访问"
+// @Router /api/v1/admin/orders/{order_no}/renotify [post]
+func (c *AdminController) Renotify(ctx *gin.Context) {
+	orderNo := ctx.Param("order_no")
+	if orderNo == "" {
+		response.Fail(ctx, http.StatusBadRequest, "订单号不能为空")
+		return
+	}
+
+	var ver int64
+	if raw := ctx.Query("ver"); raw != "" {
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			response.Fail(ctx, http.StatusBadRequest, "版本号格式错误")
+			return
+		}
+		ver = v
+	}
+
+	info, orderErr := c.notificationService.Renotify(ctx.Request.Context(), orderNo, 0, ver)
+	if orderErr != nil {
+		response.FailWithCode(ctx, orderErr.Code, orderErr.Message)
+		return
+	}
+
+	response.Success(ctx, info)
+}
+
+// NotificationHistory 查询订单通知记录（不限商户）
+// @Summary 订单通知记录
+// @Description 查询订单的通知状态、版本号和最近 100 次投递记录
+// @Tags 管理
+// @Produce json
+// @Param order_no path string true "订单号" example:"PAY20240101120000001"
+// @Success 200 {object} response.Response{data=service.NotificationHistoryResponse} "成功"
+// @Failure 401 {object} response.Response "未授权访问"
+// @Router /api/v1/admin/orders/{order_no}/notifications [get]
+func (c *AdminController) NotificationHistory(ctx *gin.Context) {
+	orderNo := ctx.Param("order_no")
+	if orderNo == "" {
+		response.Fail(ctx, http.StatusBadRequest, "订单号不能为空")
+		return
+	}
+
+	history, orderErr := c.notificationService.ListHistory(ctx.Request.Context(), orderNo, 0)
+	if orderErr != nil {
+		response.FailWithCode(ctx, orderErr.Code, orderErr.Message)
+		return
+	}
+
+	response.Success(ctx, history)
+}
+
+// ListDeadLetters 查询消息死信列表
+// @Summary 消息死信列表
+// @Description 分页查询处理失败次数达到上限的消息（按创建时间倒序）；status：0 待处理，1 已重放
+// @Tags 管理
+// @Produce json
+// @Param topic query string false "主题" example:"alipay-notify"
+// @Param status query int false "状态"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页条数（最大 100）" default(20)
+// @Success 200 {object} response.Response{data=service.DeadLetterListResponse} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 401 {object} response.Response "未授权访问"
+// @Router /api/v1/admin/dead-letters [get]
+func (c *AdminController) ListDeadLetters(ctx *gin.Context) {
+	query := service.DeadLetterQuery{Topic: ctx.Query("topic")}
+	if raw := ctx.Query("status"); raw != "" {
+		status, err := strconv.Atoi(raw)
+		if err != nil {
+			response.Fail(ctx, http.StatusBadRequest, "状态格式错误")
+			return
+		}
+		query.Status = &status
+	}
+	query.Page, _ = strconv.Atoi(ctx.Query("page"))
+	query.PageSize, _ = strconv.Atoi(ctx.Query("page_size"))
+
+	result, err := c.deadLetterService.List(ctx.Request.Context(), query)
+	if err != nil {
+		logger.Logger.Error("查询消息死信失败", zap.Error(err))
+		response.Fail(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(ctx, result)
+}
+
+// GetDeadLetter 查询单条消息死信
+// @Summary 消息死信详情
+// @Description 查询死信的消息体、最后一次处理错误和投递次数
+// @Tags 管理
+// @Produce json
+// @Param id path int true "死信ID"
+// @Success 200 {object} response.Response{data=models.MQDeadLetter} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 401 {object} response.Response "未授权访问"
+// @Router /api/v1/admin/dead-letters/{id} [get]
+func (c *AdminController) GetDeadLetter(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		response.Fail(ctx, http.StatusBadRequest, "死信ID格式错误")
+		return
+	}
+
+	record, err := c.deadLetterService.Get(ctx.Request.Context(), id)
+	if err != nil {
+		c.failDeadLetter(ctx, id, err)
+		return
+	}
+
+	response.Success(ctx, record)
+}
+
+// ReplayDeadLetter 重放消息死信
+// @Summary 重放消息死信
+// @Description 将死信重新发送到原主题（重新计算投递次数），并标记为已重放
+// @Tags 管理
+// @Produce json
+// @Param id path int true "死信ID"
+// @Success 200 {object} response.Response{data=models.MQDeadLetter} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 401 {object} response.Response "未授权访问"
+// @Router /api/v1/admin/dead-letters/{id}/replay [post]
+func (c *AdminController) ReplayDeadLetter(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		response.Fail(ctx, http.StatusBadRequest, "死信ID格式错误")
+		return
+	}
+
+	record, err := c.deadLetterService.Replay(ctx.Request.Context(), id)
+	if err != nil {
+		c.failDeadLetter(ctx, id, err)
+		return
+	}
+
+	response.Success(ctx, record)
+}
+
+// failDeadLetter 死信接口的错误响应
+func (c *AdminController) failDeadLetter(ctx *gin.Context, id int64, err error) {
+	if errors.Is(err, service.ErrDeadLetterNotFound) {
+		response.Fail(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	logger.Logger.Warn("操作消息死信失败",
+		zap.Int64("dead_letter_id", id),
+		zap.Error(err))
+	response.Fail(ctx, http.StatusInternalServerError, err.Error())
+}
+
+// ListRiskBlacklist 查询风控黑名单
+// @Summary 风控黑名单列表
+// @Description 分页查询买家风控黑名单（按ID倒序，包含已过期的记录）；list_type：ip、fingerprint、user、buyer
+// @Tags 管理
+// @Produce json
+// @Param list_type query string false "名单类型" example:"ip"
+// @Param value query string false "名单值（精确匹配）"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页条数（最大 100）" default(20)
+// @Success 200 {object} response.Response{data=service.RiskBlacklistListResponse} "成功"
+// @Failure 401 {object} response.Response "未授权访问"
+// @Router /api/v1/admin/risk/blacklist [get]
+func (c *AdminController) ListRiskBlacklist(ctx *gin.Context) {
+	query := service.RiskBlacklistQuery{
+		ListType: ctx.Query("list_type"),
+		Value:    ctx.Query("value"),
+	}
+	query.Page, _ = strconv.Atoi(ctx.Query("page"))
+	query.PageSize, _ = strconv.Atoi(ctx.Query("page_size"))
+
+	result, err := c.riskService.ListBlacklist(ctx.Request.Context(), query)
+	if err != nil {
+		logger.Logger.Error("查询风控黑名单失败", zap.Error(err))
+		response.Fail(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(ctx, result)
+}
+
+// AddRiskBlacklist 添加风控黑名单
+// @Summary 添加风控黑名单
+// @Description 添加买家风控黑名单（已存在时更新备注和有效期），立即生效；命中时下单返回 7332，收银台不展示支付链接
+// @Tags 管理
+// @Accept json
+// @Produce json
+// @Param request body service.RiskBlacklistRequest true "黑名单"
+// @Success 200 {object} response.Response{data=models.RiskBlacklist} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 401 {object} response.Response "未授权访问"
+// @Router /api/v1/admin/risk/blacklist [post]
+func (c *AdminController) AddRiskBlacklist(ctx *gin.Context) {
+	var req service.RiskBlacklistRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Fail(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+	if req.ExpireSeconds < 0 {
+		response.Fail(ctx, http.StatusBadRequest, "有效期不能为负数")
+		return
+	}
+
+	var expireAt *time.Time
+	if req.ExpireSeconds > 0 {
+		t := time.Now().Add(time.Duration(req.ExpireSeconds) * time.Second)
+		expireAt = &t
+	}
+	entry, err := c.riskService.AddBlacklist(ctx.Request.Context(), req.ListType, req.Value, req.Remarks, expireAt)
+	if err != nil {
+		c.failRiskBlacklist(ctx, err)
+		return
+	}
+
+	response.Success(ctx, entry)
+}
+
+// RemoveRiskBlacklist 删除风控黑名单
+// @Summary 删除风控黑名单
+// @Description 删除买家风控黑名单，立即生效
+// @Tags 管理
+// @Produce json
+// @Param id path int true "黑名单ID"
+// @Success 200 {object} response.Response{data=models.RiskBlacklist} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Failure 401 {object} response.Response "未授权访问"
+// @Router /api/v1/admin/risk/blacklist/{id} [delete]
+func (c *AdminController) RemoveRiskBlacklist(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		response.Fail(ctx, http.StatusBadRequest, "黑名单ID格式错误")
+		return
+	}
+
+	entry, err := c.riskService.RemoveBlacklist(ctx.Request.Context(), id)
+	if err != nil {
+		c.failRiskBlacklist(ctx, err)
+		return
+	}
+
+	response.Success(ctx, entry)
+}
+
+// failRiskBlacklist 黑名单接口的错误响应
+func (c *AdminController) failRiskBlacklist(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrRiskBlacklistInvalid):
+		response.Fail(ctx, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrRiskBlacklistNotFound):
+		response.Fail(ctx, http.StatusNotFound, err.Error())
+	default:
+		logger.Logger.Warn("操作风控黑名单失败", zap.Error(err))
+		response.Fail(ctx, http.StatusInternalServerError, err.Error())
+	}
+}
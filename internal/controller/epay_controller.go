@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/service"
+	"go.uber.org/zap"
+)
+
+// EPayController 易支付（EPay）协议兼容控制器
+// 让使用易支付 SDK 的商户无需改代码即可接入：submit.php（页面跳转支付）、mapi.php（API 下单）、api.php（查单、商户信息、退款）
+type EPayController struct {
+	epayService     *service.EPayService
+	orderService    *service.OrderService
+	orderController *OrderController // 复用订单日志记录
+}
+
+// NewEPayController 创建易支付协议兼容控制器
+func NewEPayController() *EPayController {
+	return &EPayController{
+		epayService:     service.NewEPayService(),
+		orderService:    service.NewOrderService(),
+		orderController: NewOrderController(),
+	}
+}
+
+// epayFail 易支付协议失败响应
+func epayFail(ctx *gin.Context, msg string) {
+	ctx.JSON(http.StatusOK, gin.H{"code": -1, "msg": msg})
+}
+
+// epayParams 读取易支付请求参数（合并 Query 和 Form）
+func epayParams(ctx *gin.Context) map[string]string {
+	params := make(map[string]string)
+	if err := ctx.Request.ParseForm(); err != nil {
+		return params
+	}
+	for k, v := range ctx.Request.Form {
+		if len(v) > 0 {
+			params[k] = v[0]
+		}
+	}
+	return params
+}
+
+// Submit 易支付页面跳转支付
+// @Summary 易支付页面跳转支付
+// @Description 易支付协议 submit.php：验证签名并下单，成功后跳转到收银台/支付地址
+// @Tags 易支付
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param pid formData int true "商户ID"
+// @Param type formData string true "支付方式（alipay/wxpay 或支付通道ID）"
+// @Param out_trade_no formData string true "商户订单号"
+// @Param notify_url formData string true "异步通知地址"
+// @Param return_url formData string false "跳转地址"
+// @Param name formData string true "商品名称"
+// @Param money formData string true "金额（元）"
+// @Param param formData string false "业务扩展参数"
+// @Param sign formData string true "签名"
+// @Param sign_type formData string false "签名类型" example:"MD5"
+// @Success 302 {string} string "跳转到支付地址"
+// @Failure 400 {string} string "下单失败"
+// @Router /submit.php [post]
+func (c *EPayController) Submit(ctx *gin.Context) {
+	req, resp, orderErr := c.epayService.CreateOrder(ctx.Request.Context(), epayParams(ctx), ctx.Request.Method)
+	if orderErr != nil {
+		ctx.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"title":   "下单失败",
+			"message": orderErr.Message,
+		})
+		return
+	}
+
+	go c.orderController.recordOrderLogSuccess(ctx.Request.Context(), req, resp)
+	ctx.Redirect(http.StatusFound, resp.PayURL)
+}
+
+// MAPI 易支付 API 下单
+// @Summary 易支付API下单
+// @Description 易支付协议 mapi.php：验证签名并下单，返回 JSON（code=1 成功，payurl 为支付地址）
+// @Tags 易支付
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param pid formData int true "商户ID"
+// @Param type formData string true "支付方式（alipay/wxpay 或支付通道ID）"
+// @Param out_trade_no formData string true "商户订单号"
+// @Param notify_url formData string true "异步通知地址"
+// @Param return_url formData string false "跳转地址"
+// @Param name formData string true "商品名称"
+// @Param money formData string true "金额（元）"
+// @Param clientip formData string false "用户IP"
+// @Param sign formData string true "签名"
+// @Success 200 {object} service.CreateOrderResponse "成功"
+// @Router /mapi.php [post]
+func (c *EPayController) MAPI(ctx *gin.Context) {
+	req, resp, orderErr := c.epayService.CreateOrder(ctx.Request.Context(), epayParams(ctx), ctx.Request.Method)
+	if orderErr != nil {
+		epayFail(ctx, orderErr.Message)
+		return
+	}
+
+	go c.orderController.recordOrderLogSuccess(ctx.Request.Context(), req, resp)
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// API 易支付查询与退款接口
+// @Summary 易支付API
+// @Description 易支付协议 api.php：act=order 查询订单，act=query 查询商户信息，act=refund 订单退款（商户ID + 商户密钥鉴权）
+// @Tags 易支付
+// @Produce json
+// @Param act query string true "操作类型" Enums(order, query, refund)
+// @Param pid query int true "商户ID"
+// @Param key query string true "商户密钥"
+// @Param trade_no query string false "系统订单号"
+// @Param out_trade_no query string false "商户订单号"
+// @Param money query string false "退款金额（元，act=refund 时使用，为空退还全部）"
+// @Success 200 {object} map[string]interface{} "code=1 成功"
+// @Router /api.php [get]
+// @Router /api.php [post]
+func (c *EPayController) API(ctx *gin.Context) {
+	params := epayParams(ctx)
+	pid, key := params["pid"], params["key"]
+
+	switch params["act"] {
+	case "order":
+		result, orderErr := c.epayService.QueryOrder(ctx.Request.Context(), pid, key, params["trade_no"], params["out_trade_no"])
+		if orderErr != nil {
+			epayFail(ctx, orderErr.Message)
+			return
+		}
+		ctx.JSON(http.StatusOK, result)
+	case "query":
+		result, orderErr := c.epayService.QueryMerchant(ctx.Request.Context(), pid, key)
+		if orderErr != nil {
+			epayFail(ctx, orderErr.Message)
+			return
+		}
+		ctx.JSON(http.StatusOK, result)
+	case "refund":
+		result, orderErr := c.epayService.Refund(ctx.Request.Context(), pid, key, params["trade_no"], params["out_trade_no"], params["money"])
+		if orderErr != nil {
+			epayFail(ctx, orderErr.Message)
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"code":         1,
+			"msg":          "退款成功",
+			"trade_no":     result.PayOrderID,
+			"out_trade_no": result.MchOrderNo,
+			"refund_no":    result.RefundOrderID,
+			"money":        service.FormatEPayMoney(result.RefundAmount),
+		})
+	default:
+		epayFail(ctx, "No Act")
+	}
+}
+
+// Return 易支付同步跳转
+// 支付完成后跳转到商户 return_url，并携带与异步通知相同的签名参数
+// @Summary 易支付同步跳转
+// @Description 订单支付成功后跳转到商户 return_url（携带 trade_status=TRADE_SUCCESS 和 MD5 签名）
+// @Tags 易支付
+// @Produce html
+// @Param order_no path string true "订单号" example:"PAY20240101120000001"
+// @Success 302 {string} string "跳转到商户地址"
+// @Failure 400 {string} string "订单未支付"
+// @Router /api/pay/order/return/{order_no}/ [get]
+func (c *EPayController) Return(ctx *gin.Context) {
+	orderNo := ctx.Param("order_no")
+
+	order, err := c.orderService.GetOrderByOrderNo(orderNo)
+	if err != nil || order.OrderDetail == nil {
+		ctx.HTML(http.StatusNotFound, "error.html", gin.H{
+			"title":   "订单不存在",
+			"message": "未找到订单：" + orderNo,
+		})
+		return
+	}
+
+	if order.OrderStatus != models.OrderStatusPaid && order.OrderStatus != models.OrderStatusPaidNoNotify {
+		ctx.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"title":   "订单未支付",
+			"message": "订单尚未支付成功，请稍后刷新",
+		})
+		return
+	}
+
+	// 非易支付协议订单直接跳转到商户地址
+	if order.Compatible != 1 {
+		if order.OrderDetail.JumpURL == "" {
+			ctx.HTML(http.StatusOK, "error.html", gin.H{
+				"title":   "支付成功",
+				"message": "订单已支付成功",
+			})
+			return
+		}
+		ctx.Redirect(http.StatusFound, order.OrderDetail.JumpURL)
+		return
+	}
+
+	returnURL, err := c.epayService.BuildReturnURL(ctx.Request.Context(), order, order.OrderDetail)
+	if err != nil {
+		logger.Logger.Warn("构建易支付跳转地址失败",
+			zap.String("order_no", orderNo),
+			zap.Error(err))
+		ctx.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"title":   "支付成功",
+			"message": "订单已支付成功",
+		})
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, returnURL)
+}
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/service"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupEPayRouter 准备易支付接口测试环境：商户 1（密钥 user_key，租户 9）及订单 PAY001（商户订单号 M001，已支付）
+func setupEPayRouter(t *testing.T) *gin.Engine {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Order{}, &models.OrderDetail{}, &models.Tenant{}, &models.PayChannel{}, &models.MerchantSignKey{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	oldDB, oldRDB, oldLogger := database.DB, database.RDB, logger.Logger
+	database.DB, database.RDB, logger.Logger = db, rdb, zap.NewNop()
+	t.Cleanup(func() {
+		database.DB, database.RDB, logger.Logger = oldDB, oldRDB, oldLogger
+		_ = rdb.Close()
+	})
+
+	userID, merchantID := int64(100), int64(1)
+	merchantJSON, _ := json.Marshal(models.Merchant{ID: merchantID, ParentID: 9, SystemUserID: &userID})
+	userJSON, _ := json.Marshal(service.SystemUser{ID: userID, Key: "user_key", Status: true})
+	mr.Set("merchant:1", string(merchantJSON))
+	mr.Set(fmt.Sprintf("user:%d", userID), string(userJSON))
+	tenantUserID := int64(200)
+	db.Create(&models.Tenant{ID: 9, SystemUserID: &tenantUserID})
+	tenantJSON, _ := json.Marshal(models.Tenant{ID: 9})
+	tenantUserJSON, _ := json.Marshal(service.SystemUser{ID: tenantUserID, Status: true})
+	mr.Set("tenant:9", string(tenantJSON))
+	mr.Set(fmt.Sprintf("user:%d", tenantUserID), string(tenantUserJSON))
+
+	now := time.Now()
+	db.Create(&models.Order{ID: "O1", OrderNo: "PAY001", OutOrderNo: "M001", Money: 1000,
+		OrderStatus: models.OrderStatusPaid, MerchantID: &merchantID, Compatible: 1,
+		ReqExtra: `{"epay_type":"alipay","epay_name":"商品"}`, CreateDatetime: &now, PayDatetime: &now})
+	db.Create(&models.OrderDetail{OrderID: "O1", TicketNo: "T001"})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	c := NewEPayController()
+	r.GET("/api.php", c.API)
+	r.POST("/api.php", c.API)
+	r.POST("/mapi.php", c.MAPI)
+	return r
+}
+
+func epayRequest(t *testing.T, r *gin.Engine, method, path string, params url.Values) map[string]interface{} {
+	t.Helper()
+	var req *http.Request
+	if method == http.MethodGet {
+		req = httptest.NewRequest(method, path+"?"+params.Encode(), nil)
+	} else {
+		req = httptest.NewRequest(method, path, strings.NewReader(params.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body
+}
+
+func TestEPayAPIActRouting(t *testing.T) {
+	r := setupEPayRouter(t)
+
+	cases := []struct {
+		name   string
+		method string
+		params url.Values
+		code   float64
+		check  func(t *testing.T, body map[string]interface{})
+	}{
+		{"missing act", http.MethodGet, url.Values{"pid": {"1"}, "key": {"user_key"}}, -1,
+			func(t *testing.T, body map[string]interface{}) { assert.Equal(t, "No Act", body["msg"]) }},
+		{"unknown act", http.MethodGet, url.Values{"act": {"orders"}, "pid": {"1"}, "key": {"user_key"}}, -1,
+			func(t *testing.T, body map[string]interface{}) { assert.Equal(t, "No Act", body["msg"]) }},
+		{"order wrong key", http.MethodGet, url.Values{"act": {"order"}, "pid": {"1"}, "key": {"bad"}, "trade_no": {"PAY001"}}, -1,
+			func(t *testing.T, body map[string]interface{}) { assert.Equal(t, "商户密钥错误", body["msg"]) }},
+		{"order by trade_no", http.MethodGet, url.Values{"act": {"order"}, "pid": {"1"}, "key": {"user_key"}, "trade_no": {"PAY001"}}, 1,
+			func(t *testing.T, body map[string]interface{}) {
+				assert.Equal(t, "M001", body["out_trade_no"])
+				assert.Equal(t, "10.00", body["money"])
+				assert.Equal(t, "alipay", body["type"])
+				assert.Equal(t, float64(1), body["status"])
+				assert.Equal(t, "T001", body["api_trade_no"])
+			}},
+		{"order by out_trade_no via form", http.MethodPost, url.Values{"act": {"order"}, "pid": {"1"}, "key": {"user_key"}, "out_trade_no": {"M001"}}, 1,
+			func(t *testing.T, body map[string]interface{}) { assert.Equal(t, "PAY001", body["trade_no"]) }},
+		{"order not found", http.MethodGet, url.Values{"act": {"order"}, "pid": {"1"}, "key": {"user_key"}, "trade_no": {"PAY404"}}, -1, nil},
+		{"query", http.MethodGet, url.Values{"act": {"query"}, "pid": {"1"}, "key": {"user_key"}}, 1,
+			func(t *testing.T, body map[string]interface{}) {
+				assert.Equal(t, float64(1), body["active"])
+				assert.Equal(t, float64(1), body["orders"])
+			}},
+		{"query wrong key", http.MethodGet, url.Values{"act": {"query"}, "pid": {"1"}, "key": {"bad"}}, -1, nil},
+		{"refund invalid money", http.MethodPost, url.Values{"act": {"refund"}, "pid": {"1"}, "key": {"user_key"}, "trade_no": {"PAY001"}, "money": {"1e2"}}, -1,
+			func(t *testing.T, body map[string]interface{}) {
+				assert.Equal(t, service.ErrRefundAmountInvalid.Message, body["msg"])
+			}},
+		{"refund wrong key", http.MethodPost, url.Values{"act": {"refund"}, "pid": {"1"}, "key": {"bad"}, "trade_no": {"PAY001"}}, -1,
+			func(t *testing.T, body map[string]interface{}) { assert.Equal(t, "商户密钥错误", body["msg"]) }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			body := epayRequest(t, r, c.method, "/api.php", c.params)
+			assert.Equal(t, c.code, body["code"])
+			if c.check != nil {
+				c.check(t, body)
+			}
+		})
+	}
+}
+
+func TestEPayMAPISignInvalid(t *testing.T) {
+	r := setupEPayRouter(t)
+
+	body := epayRequest(t, r, http.MethodPost, "/mapi.php", url.Values{
+		"pid": {"1"}, "type": {"3"}, "out_trade_no": {"M002"}, "notify_url": {"https://merchant.example.com/notify"},
+		"name": {"商品"}, "money": {"1.00"}, "sign": {"bad"}, "sign_type": {"MD5"},
+	})
+	assert.Equal(t, float64(-1), body["code"])
+	assert.Equal(t, service.ErrSignInvalid.Message, body["msg"])
+}
@@ -23,23 +23,30 @@ type NotifyController struct {
 	orderService  *service.OrderService
 	notifyService *service.OrderNotifyService
 	cacheService  *service.CacheService
-	mqClient      *mq.RocketMQClient // RocketMQ 客户端（可选）
+	queryService  *service.OrderQueryService
+	riskService   *service.RiskService
+	bus           mq.Bus // 消息总线（可选）
 }
 
 // NewNotifyController 创建回调控制器
 func NewNotifyController() *NotifyController {
-	// 使用全局 RocketMQ 客户端（单例模式，避免重复创建）
-	mqClient := mq.GetGlobalMQClient()
-	if !mqClient.IsEnabled() {
-		mqClient = nil
+	// 使用全局消息总线（单例模式，避免重复创建）
+	bus := mq.GetBus()
+	if !bus.IsEnabled() {
+		bus = nil
 	}
 
-	return &NotifyController{
+	c := &NotifyController{
 		orderService:  service.NewOrderService(),
 		notifyService: service.NewOrderNotifyService(),
 		cacheService:  service.NewCacheService(),
-		mqClient:      mqClient,
+		queryService:  service.NewOrderQueryService(),
+		riskService:   service.NewRiskService(),
+		bus:           bus,
 	}
+	// 消费支付宝回调消息时使用与同步处理一致的完整逻辑（成功钩子、通知商户）
+	mq.SetAlipayNotifyProcessor(c)
+	return c
 }
 
 // AlipayNotify 支付宝回调接口
@@ -151,8 +158,8 @@ func (c *NotifyController) AlipayNotify(ctx *gin.Context) {
 		return
 	}
 
-	// 如果启用了 RocketMQ，使用消息队列处理；否则使用 goroutine
-	if c.mqClient != nil && c.mqClient.IsEnabled() {
+	// 如果启用了消息总线，使用消息队列处理；否则使用 goroutine
+	if c.bus != nil {
 		// 构建支付宝回调消息
 		notifyMsg := &mq.AlipayNotifyMessage{
 			PluginType: pluginType,
@@ -163,8 +170,8 @@ func (c *NotifyController) AlipayNotify(ctx *gin.Context) {
 				TradeNo:       notifyData.TradeNo,
 				TradeStatus:   notifyData.TradeStatus,
 				TotalAmount:   notifyData.TotalAmount,
-				ReceiptAmount: 0,  // 如果回调中有，可以从 params 解析
-				BuyerID:       "", // 如果回调中有，可以从 params 解析
+				ReceiptAmount: 0, // 如果回调中有，可以从 params 解析
+				BuyerID:       notifyData.BuyerID,
 				BuyerLogonID:  "", // 如果回调中有，可以从 params 解析
 				SellerID:      "", // 如果回调中有，可以从 params 解析
 				GmtPayment:    "", // 如果回调中有，可以从 params 解析
@@ -173,9 +180,6 @@ func (c *NotifyController) AlipayNotify(ctx *gin.Context) {
 		}
 
 		// 解析更多字段（如果存在）
-		if buyerID, ok := params["buyer_id"]; ok {
-			notifyMsg.NotifyData.BuyerID = buyerID
-		}
 		if buyerLogonID, ok := params["buyer_logon_id"]; ok {
 			notifyMsg.NotifyData.BuyerLogonID = buyerLogonID
 		}
@@ -192,8 +196,8 @@ func (c *NotifyController) AlipayNotify(ctx *gin.Context) {
 			}
 		}
 
-		// 发送消息到 RocketMQ
-		if err := c.mqClient.SendMessage(ctx.Request.Context(), "alipay-notify", "notify", notifyMsg); err != nil {
+		// 发送消息到消息总线
+		if err := c.bus.Publish(ctx.Request.Context(), mq.TopicAlipayNotify, "notify", notifyMsg); err != nil {
 			logger.Logger.Error("发送支付宝回调消息失败，降级为同步处理",
 				zap.String("product_id", productID),
 				zap.String("out_trade_no", notifyData.OutTradeNo),
@@ -202,7 +206,7 @@ func (c *NotifyController) AlipayNotify(ctx *gin.Context) {
 			go c.handleAlipayNotify(context.Background(), notifyData, productID)
 		}
 	} else {
-		// 未启用 RocketMQ，使用 goroutine（原有逻辑）
+		// 未启用消息总线，使用 goroutine（原有逻辑）
 		go c.handleAlipayNotify(context.Background(), notifyData, productID)
 	}
 
@@ -210,6 +214,20 @@ func (c *NotifyController) AlipayNotify(ctx *gin.Context) {
 	ctx.String(http.StatusOK, "success")
 }
 
+// ProcessAlipayNotify 处理消息总线中的支付宝回调消息（实现 mq.AlipayNotifyProcessor，签名已由消费者验证）
+func (c *NotifyController) ProcessAlipayNotify(ctx context.Context, msg *mq.AlipayNotifyMessage) error {
+	if msg.NotifyData == nil {
+		return fmt.Errorf("支付宝回调消息缺少回调数据")
+	}
+	return c.handleAlipayNotify(ctx, &alipay.NotifyData{
+		OutTradeNo:  msg.NotifyData.OutTradeNo,
+		TradeNo:     msg.NotifyData.TradeNo,
+		TradeStatus: msg.NotifyData.TradeStatus,
+		TotalAmount: msg.NotifyData.TotalAmount,
+		BuyerID:     msg.NotifyData.BuyerID,
+	}, msg.ProductID)
+}
+
 // handleMockNotify 处理alipay_mock插件回调（用于压测）
 func (c *NotifyController) handleMockNotify(ctx *gin.Context, pluginType, productID string) {
 	// 解析回调参数（与支付宝回调格式相同）
@@ -267,19 +285,20 @@ func (c *NotifyController) handleMockNotify(ctx *gin.Context, pluginType, produc
 }
 
 // handleAlipayNotify 处理支付宝回调
-func (c *NotifyController) handleAlipayNotify(ctx context.Context, notifyData *alipay.NotifyData, productID string) {
+// 返回错误表示数据库等临时故障，消息总线据此重新投递；订单不存在、产品不匹配等无需重试的情况返回 nil
+func (c *NotifyController) handleAlipayNotify(ctx context.Context, notifyData *alipay.NotifyData, productID string) error {
 	// 查询订单（通过 out_trade_no，即商户订单号）
 	var order models.Order
 	if err := database.DB.Where("out_order_no = ?", notifyData.OutTradeNo).First(&order).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			logger.Logger.Warn("订单不存在",
 				zap.String("out_order_no", notifyData.OutTradeNo))
-			return
+			return nil
 		}
 		logger.Logger.Error("查询订单失败",
 			zap.String("out_order_no", notifyData.OutTradeNo),
 			zap.Error(err))
-		return
+		return fmt.Errorf("查询订单失败: %w", err)
 	}
 
 	// 查询订单详情
@@ -288,7 +307,7 @@ func (c *NotifyController) handleAlipayNotify(ctx context.Context, notifyData *a
 		logger.Logger.Error("查询订单详情失败",
 			zap.String("order_id", order.ID),
 			zap.Error(err))
-		return
+		return fmt.Errorf("查询订单详情失败: %w", err)
 	}
 
 	// 验证产品ID是否匹配
@@ -297,7 +316,7 @@ func (c *NotifyController) handleAlipayNotify(ctx context.Context, notifyData *a
 			zap.String("order_id", order.ID),
 			zap.String("order_product_id", orderDetail.ProductID),
 			zap.String("notify_product_id", productID))
-		return
+		return nil
 	}
 
 	// 验证金额是否匹配（如果回调中有金额）
@@ -324,7 +343,7 @@ func (c *NotifyController) handleAlipayNotify(ctx context.Context, notifyData *a
 					Where("order_id = ?", order.ID).
 					Update("ticket_no", notifyData.TradeNo)
 			}
-			return
+			return nil
 		}
 	}
 
@@ -344,7 +363,7 @@ func (c *NotifyController) handleAlipayNotify(ctx context.Context, notifyData *a
 		logger.Logger.Info("未处理的交易状态",
 			zap.String("order_id", order.ID),
 			zap.String("trade_status", notifyData.TradeStatus))
-		return
+		return nil
 	}
 
 	// 记录订单之前的状态（用于成功钩子）
@@ -357,7 +376,12 @@ func (c *NotifyController) handleAlipayNotify(ctx context.Context, notifyData *a
 			zap.String("order_id", order.ID),
 			zap.Int("status", newStatus),
 			zap.Error(err))
-		return
+		return fmt.Errorf("更新订单状态失败: %w", err)
+	}
+
+	// 记录买家 buyer_id（命中风控黑名单时在订单详情上标记，供人工复核）
+	if newStatus == models.OrderStatusPaidNoNotify {
+		c.riskService.CheckPaidBuyer(ctx, &order, notifyData.BuyerID)
 	}
 
 	// 如果订单状态更新为"支付成功，通知未返回"，触发成功钩子
@@ -463,7 +487,7 @@ func (c *NotifyController) handleAlipayNotify(ctx context.Context, notifyData *a
 		var updatedDetail models.OrderDetail
 		if err := database.DB.Where("id = ?", order.ID).First(&updatedOrder).Error; err == nil {
 			if err := database.DB.Where("order_id = ?", order.ID).First(&updatedDetail).Error; err == nil {
-				go c.notifyService.NotifyMerchant(context.Background(), &updatedOrder, &updatedDetail)
+				c.notifyService.NotifyMerchant(context.Background(), &updatedOrder, &updatedDetail)
 			}
 		}
 	}
@@ -474,4 +498,5 @@ func (c *NotifyController) handleAlipayNotify(ctx context.Context, notifyData *a
 		zap.String("trade_no", notifyData.TradeNo),
 		zap.String("trade_status", notifyData.TradeStatus),
 		zap.Int("new_status", newStatus))
+	return nil
 }
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/gateway"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	gatewayplugin "github.com/golang-pay-core/internal/plugin/gateway"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// GatewayNotify 上游网关回调接口
+// @Summary 上游网关回调
+// @Description 处理上游网关插件的异步通知（按插件配置验签并解析订单号、交易状态和金额），成功后应答插件配置的 reply 内容
+// @Tags 支付回调
+// @Accept x-www-form-urlencoded
+// @Produce plain
+// @Param product_id path string true "产品ID（即插件ID）" example:"1"
+// @Success 200 {string} string "success"
+// @Failure 400 {string} string "fail"
+// @Router /api/pay/order/notify/upstream_gateway/{product_id}/ [post]
+// @Router /api/pay/order/notify/upstream_gateway/{product_id}/ [get]
+func (c *NotifyController) GatewayNotify(ctx *gin.Context) {
+	productID := ctx.Param("product_id")
+	pluginID, err := strconv.ParseInt(productID, 10, 64)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, "fail")
+		return
+	}
+
+	if err := ctx.Request.ParseForm(); err != nil {
+		ctx.String(http.StatusBadRequest, "fail")
+		return
+	}
+	params := make(map[string]string)
+	for k, v := range ctx.Request.Form {
+		if len(v) > 0 {
+			params[k] = v[0]
+		}
+	}
+
+	result, cfg, err := gatewayplugin.VerifyNotify(ctx.Request.Context(), pluginID, params)
+	if err != nil {
+		logger.Logger.Warn("上游网关回调验证失败",
+			zap.Int64("plugin_id", pluginID),
+			zap.Any("params", params),
+			zap.Error(err))
+		ctx.String(http.StatusBadRequest, "fail")
+		return
+	}
+
+	if err := c.handleGatewayNotify(ctx.Request.Context(), productID, result); err != nil {
+		logger.Logger.Error("上游网关回调处理失败",
+			zap.Int64("plugin_id", pluginID),
+			zap.String("order_no", result.OrderNo),
+			zap.Error(err))
+		ctx.String(http.StatusInternalServerError, "fail")
+		return
+	}
+
+	ctx.String(http.StatusOK, cfg.Notify.Reply)
+}
+
+// handleGatewayNotify 处理上游网关回调
+// 下单时上游商户订单号使用系统订单号 order_no；返回错误时由上游重试
+func (c *NotifyController) handleGatewayNotify(ctx context.Context, productID string, result *gateway.NotifyResult) error {
+	var order models.Order
+	if err := database.DB.Where("order_no = ?", result.OrderNo).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 订单不存在时无需重试
+			logger.Logger.Warn("订单不存在",
+				zap.String("order_no", result.OrderNo))
+			return nil
+		}
+		return err
+	}
+
+	var orderDetail models.OrderDetail
+	if err := database.DB.Where("order_id = ?", order.ID).First(&orderDetail).Error; err != nil {
+		return err
+	}
+
+	// 验证产品和插件类型是否匹配（防止使用其他插件的密钥伪造通知）
+	if orderDetail.ProductID != productID || orderDetail.PluginType != gatewayplugin.PluginType {
+		logger.Logger.Warn("上游网关回调产品不匹配",
+			zap.String("order_id", order.ID),
+			zap.String("order_product_id", orderDetail.ProductID),
+			zap.String("notify_product_id", productID),
+			zap.String("order_plugin_type", orderDetail.PluginType))
+		return nil
+	}
+
+	if !result.Paid {
+		logger.Logger.Info("未处理的交易状态",
+			zap.String("order_id", order.ID),
+			zap.String("trade_status", result.Status))
+		return nil
+	}
+
+	if result.Amount >= 0 && result.Amount != order.Money {
+		logger.Logger.Warn("金额不匹配",
+			zap.String("order_id", order.ID),
+			zap.Int("order_money", order.Money),
+			zap.Int("notify_amount", result.Amount))
+	}
+
+	// 已支付订单跳过重复回调
+	if order.OrderStatus == models.OrderStatusPaid || order.OrderStatus == models.OrderStatusPaidNoNotify {
+		logger.Logger.Info("订单已处理，跳过重复回调",
+			zap.String("order_id", order.ID),
+			zap.Int("current_status", order.OrderStatus))
+		return nil
+	}
+
+	if err := c.queryService.MarkOrderPaid(ctx, &order, result.TradeNo); err != nil {
+		return err
+	}
+
+	logger.Logger.Info("上游网关回调处理成功",
+		zap.String("order_id", order.ID),
+		zap.String("order_no", order.OrderNo),
+		zap.String("trade_no", result.TradeNo),
+		zap.String("trade_status", result.Status))
+	return nil
+}
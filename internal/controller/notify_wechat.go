@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/wechat"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// wechatNotifyFail 微信支付回调失败应答（微信支付收到非 2XX 应答后会按策略重试）
+func wechatNotifyFail(ctx *gin.Context, status int, message string) {
+	ctx.JSON(status, gin.H{"code": "FAIL", "message": message})
+}
+
+// WechatNotify 微信支付回调接口
+// @Summary 微信支付回调
+// @Description 处理微信支付 APIv3 支付成功通知（验签并解密 resource）
+// @Tags 支付回调
+// @Accept application/json
+// @Produce application/json
+// @Param plugin_type path string true "插件类型" example:"wechat_h5"
+// @Param product_id path string true "产品ID" example:"1"
+// @Success 204 "处理成功"
+// @Failure 400 {object} map[string]string "验签失败或参数错误"
+// @Failure 500 {object} map[string]string "处理失败，等待微信重试"
+// @Router /api/pay/order/notify/wechat_h5/{product_id}/ [post]
+func (c *NotifyController) WechatNotify(pluginType string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		productID := ctx.Param("product_id")
+		if productID == "" {
+			wechatNotifyFail(ctx, http.StatusBadRequest, "参数错误")
+			return
+		}
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil || len(body) == 0 {
+			wechatNotifyFail(ctx, http.StatusBadRequest, "请求体为空")
+			return
+		}
+
+		// 获取产品信息
+		product, err := wechat.GetWechatProductByID(productID)
+		if err != nil {
+			logger.Logger.Warn("获取微信支付产品失败",
+				zap.String("product_id", productID),
+				zap.Error(err))
+			wechatNotifyFail(ctx, http.StatusBadRequest, "产品不存在")
+			return
+		}
+
+		// 创建微信支付客户端（用于验签和解密）
+		client, err := wechat.NewClient(product, "")
+		if err != nil {
+			logger.Logger.Warn("创建微信支付客户端失败",
+				zap.String("product_id", productID),
+				zap.Error(err))
+			wechatNotifyFail(ctx, http.StatusInternalServerError, "系统错误")
+			return
+		}
+
+		notification, transaction, err := client.ParseTransactionNotify(ctx.Request.Header, body)
+		if err != nil {
+			logger.Logger.Warn("微信支付回调验签或解密失败",
+				zap.String("plugin_type", pluginType),
+				zap.String("product_id", productID),
+				zap.Error(err))
+			wechatNotifyFail(ctx, http.StatusBadRequest, "签名验证失败")
+			return
+		}
+
+		if err := c.handleWechatNotify(ctx.Request.Context(), pluginType, productID, transaction); err != nil {
+			logger.Logger.Error("微信支付回调处理失败",
+				zap.String("notify_id", notification.ID),
+				zap.String("out_trade_no", transaction.OutTradeNo),
+				zap.Error(err))
+			wechatNotifyFail(ctx, http.StatusInternalServerError, "处理失败")
+			return
+		}
+
+		// 处理成功：应答 204 无内容
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+// handleWechatNotify 处理微信支付回调
+// 下单时 out_trade_no 使用系统订单号 order_no；返回错误时由微信支付重试
+func (c *NotifyController) handleWechatNotify(ctx context.Context, pluginType, productID string, transaction *wechat.Transaction) error {
+	var order models.Order
+	if err := database.DB.Where("order_no = ?", transaction.OutTradeNo).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 订单不存在时无需重试
+			logger.Logger.Warn("订单不存在",
+				zap.String("order_no", transaction.OutTradeNo))
+			return nil
+		}
+		return err
+	}
+
+	var orderDetail models.OrderDetail
+	if err := database.DB.Where("order_id = ?", order.ID).First(&orderDetail).Error; err != nil {
+		return err
+	}
+
+	// 验证产品和插件类型是否匹配（微信支付产品ID与支付宝产品ID来自不同表）
+	if orderDetail.ProductID != productID || orderDetail.PluginType != pluginType {
+		logger.Logger.Warn("微信支付回调产品不匹配",
+			zap.String("order_id", order.ID),
+			zap.String("order_product_id", orderDetail.ProductID),
+			zap.String("notify_product_id", productID),
+			zap.String("order_plugin_type", orderDetail.PluginType),
+			zap.String("notify_plugin_type", pluginType))
+		return nil
+	}
+
+	if !transaction.IsPaid() {
+		logger.Logger.Info("未处理的交易状态",
+			zap.String("order_id", order.ID),
+			zap.String("trade_state", transaction.TradeState))
+		return nil
+	}
+
+	if transaction.Amount.Total != order.Money {
+		logger.Logger.Warn("金额不匹配",
+			zap.String("order_id", order.ID),
+			zap.Int("order_money", order.Money),
+			zap.Int("notify_amount", transaction.Amount.Total))
+	}
+
+	// 已支付订单跳过重复回调
+	if order.OrderStatus == models.OrderStatusPaid || order.OrderStatus == models.OrderStatusPaidNoNotify {
+		logger.Logger.Info("订单已处理，跳过重复回调",
+			zap.String("order_id", order.ID),
+			zap.Int("current_status", order.OrderStatus))
+		return nil
+	}
+
+	if err := c.queryService.MarkOrderPaid(ctx, &order, transaction.TransactionID); err != nil {
+		return err
+	}
+
+	logger.Logger.Info("微信支付回调处理成功",
+		zap.String("order_id", order.ID),
+		zap.String("order_no", order.OrderNo),
+		zap.String("transaction_id", transaction.TransactionID),
+		zap.String("trade_state", transaction.TradeState))
+	return nil
+}
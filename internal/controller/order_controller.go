@@ -19,17 +19,21 @@ import (
 )
 
 type OrderController struct {
-	orderService      *service.OrderService
-	merchantService   *service.MerchantService
-	payChannelService *service.PayChannelService
+	orderService        *service.OrderService
+	refundService       *service.OrderRefundService
+	notificationService *service.NotificationManageService
+	merchantService     *service.MerchantService
+	payChannelService   *service.PayChannelService
 }
 
 // NewOrderController 创建订单控制器
 func NewOrderController() *OrderController {
 	return &OrderController{
-		orderService:      service.NewOrderService(),
-		merchantService:   service.NewMerchantService(),
-		payChannelService: service.NewPayChannelService(),
+		orderService:        service.NewOrderService(),
+		refundService:       service.NewOrderRefundService(),
+		notificationService: service.NewNotificationManageService(),
+		merchantService:     service.NewMerchantService(),
+		payChannelService:   service.NewPayChannelService(),
 	}
 }
 
@@ -49,6 +53,9 @@ func NewOrderController() *OrderController {
 // @Param extra query string false "额外参数" example:"{}"
 // @Param compatible query int false "兼容模式 0/1" example:"0"
 // @Param test query bool false "测试模式" example:"false"
+// @Param keyId query string false "签名密钥编号（商户配置了多个签名密钥时指定验签密钥）"
+// @Param timestamp query int false "请求时间戳（Unix 秒，防重放）" example:"1704081600"
+// @Param nonce query string false "随机串（防重放，最长 64 个字符）"
 // @Param sign query string false "签名" example:"ABC123..."
 // @Param request body CreateOrderRequest false "订单信息（POST 方式）"
 // @Success 200 {object} response.Response{data=object} "成功"
@@ -99,6 +106,18 @@ func (c *OrderController) CreateOrder(ctx *gin.Context) {
 			rawSignData["extra"] = req.Extra
 			rawSignData["compatible"] = req.Compatible
 			rawSignData["test"] = req.Test
+			if req.ClientIP != "" {
+				rawSignData["clientIp"] = req.ClientIP
+			}
+			if req.KeyID != "" {
+				rawSignData["keyId"] = req.KeyID
+			}
+			if req.Timestamp != 0 {
+				rawSignData["timestamp"] = req.Timestamp
+			}
+			if req.Nonce != "" {
+				rawSignData["nonce"] = req.Nonce
+			}
 			rawSignData["sign"] = req.Sign
 		} else {
 			// Form 格式（application/x-www-form-urlencoded 或 multipart/form-data）
@@ -238,6 +257,23 @@ func (c *OrderController) parseQueryParams(ctx *gin.Context, req *service.Create
 		rawSignData["test"] = req.Test
 	}
 
+	if clientIP := ctx.Query("clientIp"); clientIP != "" {
+		req.ClientIP = clientIP
+		rawSignData["clientIp"] = clientIP
+	}
+	if keyID := ctx.Query("keyId"); keyID != "" {
+		req.KeyID = keyID
+		rawSignData["keyId"] = keyID
+	}
+	if timestamp := ctx.Query("timestamp"); timestamp != "" {
+		req.Timestamp, _ = strconv.ParseInt(timestamp, 10, 64)
+		rawSignData["timestamp"] = timestamp
+	}
+	if nonce := ctx.Query("nonce"); nonce != "" {
+		req.Nonce = nonce
+		rawSignData["nonce"] = nonce
+	}
+
 	if sign := ctx.Query("sign"); sign != "" {
 		req.Sign = sign
 		rawSignData["sign"] = sign
@@ -304,6 +340,23 @@ func (c *OrderController) parseFormParams(ctx *gin.Context, req *service.CreateO
 		rawSignData["test"] = req.Test
 	}
 
+	if clientIP := ctx.PostForm("clientIp"); clientIP != "" {
+		req.ClientIP = clientIP
+		rawSignData["clientIp"] = clientIP
+	}
+	if keyID := ctx.PostForm("keyId"); keyID != "" {
+		req.KeyID = keyID
+		rawSignData["keyId"] = keyID
+	}
+	if timestamp := ctx.PostForm("timestamp"); timestamp != "" {
+		req.Timestamp, _ = strconv.ParseInt(timestamp, 10, 64)
+		rawSignData["timestamp"] = timestamp
+	}
+	if nonce := ctx.PostForm("nonce"); nonce != "" {
+		req.Nonce = nonce
+		rawSignData["nonce"] = nonce
+	}
+
 	if sign := ctx.PostForm("sign"); sign != "" {
 		req.Sign = sign
 		rawSignData["sign"] = sign
@@ -386,6 +439,95 @@ func (c *OrderController) QueryOrder(ctx *gin.Context) {
 	response.Success(ctx, order)
 }
 
+// RefundOrder 订单退款（支持全额和部分退款）
+// @Summary 订单退款
+// @Description 对支付成功的订单发起退款，签名规则与下单一致，payOrderId（路径中的订单号）参与签名。
+// @Description refundAmount 为空或 0 时退还剩余全部金额；累计退款达到订单金额时订单状态变更为已退款
+// @Tags 订单
+// @Accept json
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param order_no path string true "订单号" example:"PAY20240101120000001"
+// @Param request body service.RefundOrderRequest true "退款信息"
+// @Success 200 {object} response.Response{data=service.RefundOrderResponse} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Router /api/v1/orders/{order_no}/refund [post]
+func (c *OrderController) RefundOrder(ctx *gin.Context) {
+	orderNo := ctx.Param("order_no")
+	if orderNo == "" {
+		response.Fail(ctx, http.StatusBadRequest, "订单号不能为空")
+		return
+	}
+
+	var req service.RefundOrderRequest
+	contentType := ctx.GetHeader("Content-Type")
+	if contentType == "application/json" || contentType == "application/json; charset=utf-8" {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			response.Fail(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+			return
+		}
+	} else {
+		// Form 格式（application/x-www-form-urlencoded 或 multipart/form-data）
+		if mchId := ctx.PostForm("mchId"); mchId != "" {
+			if id, err := strconv.Atoi(mchId); err == nil {
+				req.MerchantID = id
+			}
+		}
+		if refundAmount := ctx.PostForm("refundAmount"); refundAmount != "" {
+			if amount, err := strconv.Atoi(refundAmount); err == nil {
+				req.RefundAmount = amount
+			}
+		}
+		req.OutRefundNo = ctx.PostForm("mchRefundNo")
+		req.Reason = ctx.PostForm("reason")
+		req.KeyID = ctx.PostForm("keyId")
+		req.Nonce = ctx.PostForm("nonce")
+		if timestamp := ctx.PostForm("timestamp"); timestamp != "" {
+			req.Timestamp, _ = strconv.ParseInt(timestamp, 10, 64)
+		}
+		req.Sign = ctx.PostForm("sign")
+		if req.MerchantID == 0 || req.Sign == "" {
+			response.Fail(ctx, http.StatusBadRequest, "参数错误: mchId 和 sign 不能为空")
+			return
+		}
+	}
+	req.OrderNo = orderNo
+
+	// 构建原始签名数据（空值不参与签名）
+	rawSignData := map[string]interface{}{
+		"payOrderId": req.OrderNo,
+		"mchId":      req.MerchantID,
+		"sign":       req.Sign,
+	}
+	if req.RefundAmount != 0 {
+		rawSignData["refundAmount"] = req.RefundAmount
+	}
+	if req.OutRefundNo != "" {
+		rawSignData["mchRefundNo"] = req.OutRefundNo
+	}
+	if req.Reason != "" {
+		rawSignData["reason"] = req.Reason
+	}
+	if req.KeyID != "" {
+		rawSignData["keyId"] = req.KeyID
+	}
+	if req.Timestamp != 0 {
+		rawSignData["timestamp"] = req.Timestamp
+	}
+	if req.Nonce != "" {
+		rawSignData["nonce"] = req.Nonce
+	}
+	req.RawSignData = rawSignData
+
+	refundResp, orderErr := c.refundService.RefundOrder(ctx.Request.Context(), &req)
+	if orderErr != nil {
+		response.FailWithCode(ctx, orderErr.Code, orderErr.Message)
+		return
+	}
+
+	response.Success(ctx, refundResp)
+}
+
 // CreateOrderRequest 创建订单请求（用于文档）
 type CreateOrderRequest struct {
 	OutOrderNo   string `json:"out_order_no" binding:"required" example:"ORD20240101001"`
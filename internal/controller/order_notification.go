@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-pay-core/internal/response"
+	"github.com/golang-pay-core/internal/service"
+)
+
+// Renotify 商户重新发起支付结果通知
+// @Summary 重新通知
+// @Description 立即重新向订单的 notifyUrl 发送支付结果通知（包括已达到最大重试次数的订单），签名规则与下单一致，payOrderId（路径中的订单号）参与签名。
+// @Description ver 为通知记录接口返回的版本号（可选），传入时版本号不一致返回 7330，避免重复操作
+// @Tags 订单
+// @Accept json
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param order_no path string true "订单号" example:"PAY20240101120000001"
+// @Param request body service.NotificationManageRequest true "商户信息"
+// @Success 200 {object} response.Response{data=service.NotificationInfo} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Router /api/v1/orders/{order_no}/renotify [post]
+func (c *OrderController) Renotify(ctx *gin.Context) {
+	req, ok := c.bindNotificationRequest(ctx)
+	if !ok {
+		return
+	}
+
+	if orderErr := c.notificationService.VerifyMerchant(ctx.Request.Context(), req); orderErr != nil {
+		response.FailWithCode(ctx, orderErr.Code, orderErr.Message)
+		return
+	}
+
+	info, orderErr := c.notificationService.Renotify(ctx.Request.Context(), req.OrderNo, int64(req.MerchantID), req.Ver)
+	if orderErr != nil {
+		response.FailWithCode(ctx, orderErr.Code, orderErr.Message)
+		return
+	}
+
+	response.Success(ctx, info)
+}
+
+// NotificationHistory 商户查询订单通知记录
+// @Summary 通知记录
+// @Description 查询订单的通知状态、版本号和最近 100 次投递记录（请求内容、响应状态码和响应内容），签名规则与下单一致，payOrderId 参与签名
+// @Tags 订单
+// @Produce json
+// @Param order_no path string true "订单号" example:"PAY20240101120000001"
+// @Param mchId query int true "商户ID" example:"1"
+// @Param keyId query string false "签名密钥编号"
+// @Param timestamp query int false "请求时间戳（Unix 秒，防重放）"
+// @Param nonce query string false "随机串（防重放）"
+// @Param sign query string true "签名"
+// @Success 200 {object} response.Response{data=service.NotificationHistoryResponse} "成功"
+// @Failure 400 {object} response.Response "参数错误"
+// @Router /api/v1/orders/{order_no}/notifications [get]
+func (c *OrderController) NotificationHistory(ctx *gin.Context) {
+	req, ok := c.bindNotificationRequest(ctx)
+	if !ok {
+		return
+	}
+
+	if orderErr := c.notificationService.VerifyMerchant(ctx.Request.Context(), req); orderErr != nil {
+		response.FailWithCode(ctx, orderErr.Code, orderErr.Message)
+		return
+	}
+
+	history, orderErr := c.notificationService.ListHistory(ctx.Request.Context(), req.OrderNo, int64(req.MerchantID))
+	if orderErr != nil {
+		response.FailWithCode(ctx, orderErr.Code, orderErr.Message)
+		return
+	}
+
+	response.Success(ctx, history)
+}
+
+// bindNotificationRequest 解析通知管理请求（POST JSON、POST Form 或 GET Query），并构建原始签名数据
+func (c *OrderController) bindNotificationRequest(ctx *gin.Context) (*service.NotificationManageRequest, bool) {
+	orderNo := ctx.Param("order_no")
+	if orderNo == "" {
+		response.Fail(ctx, http.StatusBadRequest, "订单号不能为空")
+		return nil, false
+	}
+
+	var req service.NotificationManageRequest
+	contentType := ctx.GetHeader("Content-Type")
+	if ctx.Request.Method == http.MethodPost && (contentType == "application/json" || contentType == "application/json; charset=utf-8") {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			response.Fail(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+			return nil, false
+		}
+	} else {
+		// Form 或 Query 格式（ctx.Request.Form 合并了两者）
+		if err := ctx.Request.ParseForm(); err != nil {
+			response.Fail(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+			return nil, false
+		}
+		if mchId := ctx.Request.Form.Get("mchId"); mchId != "" {
+			if id, err := strconv.Atoi(mchId); err == nil {
+				req.MerchantID = id
+			}
+		}
+		if ver := ctx.Request.Form.Get("ver"); ver != "" {
+			if v, err := strconv.ParseInt(ver, 10, 64); err == nil {
+				req.Ver = v
+			}
+		}
+		req.KeyID = ctx.Request.Form.Get("keyId")
+		req.Nonce = ctx.Request.Form.Get("nonce")
+		if timestamp := ctx.Request.Form.Get("timestamp"); timestamp != "" {
+			req.Timestamp, _ = strconv.ParseInt(timestamp, 10, 64)
+		}
+		req.Sign = ctx.Request.Form.Get("sign")
+		if req.MerchantID == 0 || req.Sign == "" {
+			response.Fail(ctx, http.StatusBadRequest, "参数错误: mchId 和 sign 不能为空")
+			return nil, false
+		}
+	}
+	req.OrderNo = orderNo
+
+	// 构建原始签名数据（空值不参与签名）
+	rawSignData := map[string]interface{}{
+		"payOrderId": req.OrderNo,
+		"mchId":      req.MerchantID,
+		"sign":       req.Sign,
+	}
+	if req.Ver != 0 {
+		rawSignData["ver"] = req.Ver
+	}
+	if req.KeyID != "" {
+		rawSignData["keyId"] = req.KeyID
+	}
+	if req.Timestamp != 0 {
+		rawSignData["timestamp"] = req.Timestamp
+	}
+	if req.Nonce != "" {
+		rawSignData["nonce"] = req.Nonce
+	}
+	req.RawSignData = rawSignData
+
+	return &req, true
+}
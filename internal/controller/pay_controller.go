@@ -114,6 +114,17 @@ func (c *PayController) Auth(ctx *gin.Context) {
 		return
 	}
 
+	// 检查支付通道访问策略（封禁IP、地区、设备类型），拒绝时不返回支付链接
+	if denial := c.cashierService.CheckCashierAccess(ctx, &order, ctx.ClientIP(), ctx.Request.UserAgent()); denial != nil {
+		response.Fail(ctx, http.StatusForbidden, "当前网络环境或设备不支持该支付方式")
+		return
+	}
+	order.OrderDetail = &orderDetail
+	if risk := c.cashierService.EvaluateCashierRisk(ctx, &order, "auth", ctx.ClientIP(), "", ""); risk.Decision == models.RiskDecisionBlock {
+		response.Fail(ctx, http.StatusForbidden, "当前订单存在风险，暂时无法支付")
+		return
+	}
+
 	// 从订单详情的 Extra 字段中获取支付URL
 	var payURLFromDB string
 	if orderDetail.Extra != "" && orderDetail.Extra != "{}" {
@@ -267,6 +278,25 @@ func (c *PayController) Cashier(ctx *gin.Context) {
 		}
 	}
 
+	// 检查支付通道访问策略（封禁IP、地区、设备类型），拒绝时不展示支付链接
+	if denial := c.cashierService.CheckCashierAccess(ctx, order, ctx.ClientIP(), ctx.Request.UserAgent()); denial != nil {
+		ctx.HTML(http.StatusForbidden, "error.html", gin.H{
+			"title":   "访问受限",
+			"message": "当前网络环境或设备不支持该支付方式，请更换网络或设备后重试",
+		})
+		return
+	}
+
+	// 买家风控（黑名单、IP/设备频率、买家未支付订单数），拒绝时不展示支付链接，要求确认时用户点击按钮后才跳转支付
+	risk := c.cashierService.EvaluateCashierRisk(ctx, order, "cashier", ctx.ClientIP(), ctx.Query("fingerprint"), ctx.Query("user_id"))
+	if risk.Decision == models.RiskDecisionBlock {
+		ctx.HTML(http.StatusForbidden, "error.html", gin.H{
+			"title":   "访问受限",
+			"message": "当前订单存在风险，暂时无法支付，请稍后再试",
+		})
+		return
+	}
+
 	// 如果订单状态是生成中，更新为支付中（用户已进入收银台）
 	if order.OrderStatus == models.OrderStatusGenerating {
 		if err := c.orderService.UpdateOrderStatus(ctx, order.ID, models.OrderStatusPaying, ""); err != nil {
@@ -338,6 +368,7 @@ func (c *PayController) Cashier(ctx *gin.Context) {
 		"amount":     amount,
 		"need_auth":  needAuth,
 		"expireTime": expireTimeStr,
+		"challenge":  risk.Decision == models.RiskDecisionChallenge,
 	}
 
 	if needAuth {
@@ -431,6 +462,14 @@ func (c *PayController) Device(ctx *gin.Context) {
 	// 获取 User-Agent
 	userAgent := ctx.Request.UserAgent()
 
+	// 按设备指纹检查买家风控，拒绝时返回错误（收银台页面提交指纹失败后不会跳转支付）
+	if order, err := c.orderService.GetOrderByOrderNo(orderNo); err == nil {
+		if risk := c.cashierService.EvaluateCashierRisk(ctx, order, "device", clientIP, fingerprint, userID); risk.Decision == models.RiskDecisionBlock {
+			response.Fail(ctx, http.StatusForbidden, "当前订单存在风险，暂时无法支付")
+			return
+		}
+	}
+
 	// 异步记录设备信息（不阻塞响应）
 	go func() {
 		if err := c.cashierService.RecordCashierVisit(
@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	wechatplugin "github.com/golang-pay-core/internal/plugin/wechat"
+	"go.uber.org/zap"
+)
+
+// WechatJSAPI 微信 JSAPI 支付页
+// 首次进入跳转微信网页授权，授权回调携带 code 后下单并通过 WeixinJSBridge 调起支付
+// @Summary 微信JSAPI支付页
+// @Description 微信内打开的支付页面：网页授权获取 openid 后 JSAPI 下单并调起支付
+// @Tags 支付
+// @Produce html
+// @Param order_no path string true "订单号" example:"PAY20240101120000001"
+// @Param code query string false "网页授权code（微信回调携带）"
+// @Success 200 {string} string "HTML页面"
+// @Success 302 {string} string "跳转微信网页授权"
+// @Failure 400 {string} string "参数错误"
+// @Failure 404 {string} string "订单不存在"
+// @Router /api/pay/order/wechat_jsapi/{order_no}/ [get]
+func (c *PayController) WechatJSAPI(ctx *gin.Context) {
+	orderNo := ctx.Param("order_no")
+
+	order, err := c.orderService.GetOrderByOrderNo(orderNo)
+	if err != nil || order.OrderDetail == nil {
+		ctx.HTML(http.StatusNotFound, "error.html", gin.H{
+			"title":   "订单不存在",
+			"message": "未找到订单：" + orderNo,
+		})
+		return
+	}
+	orderDetail := order.OrderDetail
+
+	if orderDetail.PluginType != "wechat_jsapi" {
+		ctx.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"title":   "参数错误",
+			"message": "订单不支持微信JSAPI支付",
+		})
+		return
+	}
+
+	if order.OrderStatus != models.OrderStatusGenerating && order.OrderStatus != models.OrderStatusPaying {
+		ctx.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"title":   "订单状态错误",
+			"message": "订单已处理，无法支付",
+		})
+		return
+	}
+
+	var pluginID int64
+	if orderDetail.PluginID != nil {
+		pluginID = *orderDetail.PluginID
+	}
+	jsapiPlugin := wechatplugin.NewJSAPIPlugin(pluginID)
+
+	domainURL := requestDomainURL(ctx)
+	code := ctx.Query("code")
+	if code == "" {
+		// 未授权：跳转微信网页授权（静默授权），回调到当前页面
+		redirectURI := domainURL + ctx.Request.URL.Path
+		authorizeURL, err := jsapiPlugin.AuthorizeURL(orderDetail, orderNo, redirectURI)
+		if err != nil {
+			logger.Logger.Warn("生成微信网页授权地址失败",
+				zap.String("order_no", orderNo),
+				zap.Error(err))
+			ctx.HTML(http.StatusBadRequest, "error.html", gin.H{
+				"title":   "支付失败",
+				"message": "无法发起微信授权，请联系客服",
+			})
+			return
+		}
+		ctx.Redirect(http.StatusFound, authorizeURL)
+		return
+	}
+
+	payParams, err := jsapiPlugin.PayParams(ctx.Request.Context(), order, orderDetail, code, domainURL)
+	if err != nil {
+		logger.Logger.Warn("微信JSAPI下单失败",
+			zap.String("order_no", orderNo),
+			zap.Error(err))
+		ctx.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"title":   "支付失败",
+			"message": "微信下单失败，请重新扫码或联系客服",
+		})
+		return
+	}
+
+	// 如果订单状态是生成中，更新为支付中（用户已进入支付页）
+	if order.OrderStatus == models.OrderStatusGenerating {
+		if err := c.orderService.UpdateOrderStatus(ctx, order.ID, models.OrderStatusPaying, ""); err != nil {
+			logger.Logger.Warn("更新订单状态为支付中失败",
+				zap.String("order_id", order.ID),
+				zap.String("order_no", orderNo),
+				zap.Error(err))
+		}
+	}
+
+	// 易支付协议订单经同步跳转接口返回商户（携带签名参数）
+	jumpURL := orderDetail.JumpURL
+	if order.Compatible == 1 && jumpURL != "" {
+		jumpURL = domainURL + "/api/pay/order/return/" + orderNo + "/"
+	}
+
+	paramsJSON, _ := json.Marshal(payParams)
+	ctx.HTML(http.StatusOK, "wechat_jsapi.html", gin.H{
+		"order_no":   orderNo,
+		"amount":     float64(order.Money) / 100.0,
+		"pay_params": template.JS(paramsJSON),
+		"jump_url":   jumpURL,
+	})
+}
+
+// requestDomainURL 获取当前请求的域名地址（兼容反向代理）
+func requestDomainURL(ctx *gin.Context) string {
+	scheme := "http"
+	if ctx.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := ctx.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + ctx.Request.Host
+}
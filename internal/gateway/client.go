@@ -0,0 +1,287 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/safehttp"
+	"go.uber.org/zap"
+)
+
+// 查单归一化交易状态
+const (
+	TradeStatePaid     = "PAID"
+	TradeStateClosed   = "CLOSED"
+	TradeStateWaiting  = "WAITING"
+	TradeStateNotExist = "NOT_EXIST"
+)
+
+// Client 上游网关客户端
+type Client struct {
+	Config     *Config
+	HTTPClient *http.Client
+	OrderNo    string // 系统订单号（用于查询日志）
+	OutOrderNo string // 商户订单号（用于查询日志）
+}
+
+// NewClient 创建上游网关客户端（配置需已通过 Normalize 校验）
+func NewClient(cfg *Config, orderNo, outOrderNo string) *Client {
+	return &Client{
+		Config:     cfg,
+		HTTPClient: safehttp.NewClient(10 * time.Second),
+		OrderNo:    orderNo,
+		OutOrderNo: outOrderNo,
+	}
+}
+
+// NotifyResult 异步通知解析结果
+type NotifyResult struct {
+	OrderNo string // 系统订单号
+	TradeNo string // 上游交易号
+	Status  string // 上游原始交易状态
+	Paid    bool   // 是否支付成功
+	Amount  int    // 通知金额（分），未提供时为 -1
+}
+
+// QueryResult 查单结果
+type QueryResult struct {
+	TradeState  string // 归一化交易状态（TradeState*）
+	TradeStatus string // 上游原始交易状态
+	TradeNo     string // 上游交易号
+	Amount      int    // 上游交易金额（分），未提供时为 -1
+}
+
+// CreateOrder 向上游下单，返回支付地址
+// vars 为字段映射模板变量（order_no、out_order_no、money、money_yuan、notify_url、jump_url、client_ip、timestamp）
+func (c *Client) CreateOrder(vars map[string]string) (string, error) {
+	params := c.signedParams(c.Config.Fields, vars)
+
+	result, err := c.do(c.Config.Method, c.Config.URL, params, "上游网关下单")
+	if err != nil {
+		return "", err
+	}
+
+	if c.Config.SuccessPath != "" {
+		value, _ := LookupString(result, c.Config.SuccessPath)
+		if value != c.Config.SuccessValue {
+			message, _ := LookupString(result, c.Config.MessagePath)
+			if message == "" {
+				message = fmt.Sprintf("%s=%s", c.Config.SuccessPath, value)
+			}
+			return "", fmt.Errorf("上游下单失败: %s", message)
+		}
+	}
+
+	payURL, ok := LookupString(result, c.Config.PayURLPath)
+	if !ok || payURL == "" {
+		message, _ := LookupString(result, c.Config.MessagePath)
+		return "", fmt.Errorf("上游响应中没有支付地址(%s): %s", c.Config.PayURLPath, message)
+	}
+	return payURL, nil
+}
+
+// QueryOrder 向上游查单
+func (c *Client) QueryOrder(vars map[string]string) (*QueryResult, error) {
+	query := &c.Config.Query
+	if query.URL == "" || query.StatusPath == "" {
+		return nil, fmt.Errorf("上游网关未配置查单接口")
+	}
+
+	params := c.signedParams(query.Fields, vars)
+	result, err := c.do(query.Method, query.URL, params, "上游网关查单")
+	if err != nil {
+		return nil, err
+	}
+
+	if query.NotExistPath != "" {
+		if value, ok := LookupString(result, query.NotExistPath); ok && value == query.NotExistValue {
+			return &QueryResult{TradeState: TradeStateNotExist, Amount: -1}, nil
+		}
+	}
+
+	status, ok := LookupString(result, query.StatusPath)
+	if !ok {
+		return nil, fmt.Errorf("上游查单响应中没有交易状态(%s)", query.StatusPath)
+	}
+
+	queryResult := &QueryResult{TradeStatus: status, Amount: -1}
+	switch {
+	case containsValue(query.PaidValues, status):
+		queryResult.TradeState = TradeStatePaid
+	case containsValue(query.ClosedValues, status):
+		queryResult.TradeState = TradeStateClosed
+	default:
+		queryResult.TradeState = TradeStateWaiting
+	}
+
+	if query.TradeNoPath != "" {
+		queryResult.TradeNo, _ = LookupString(result, query.TradeNoPath)
+	}
+	if query.AmountPath != "" {
+		if amount, ok := LookupString(result, query.AmountPath); ok {
+			if money, err := parseAmount(amount, query.AmountUnit); err == nil {
+				queryResult.Amount = money
+			}
+		}
+	}
+	return queryResult, nil
+}
+
+// VerifyNotify 验证并解析上游异步通知参数
+func (c *Client) VerifyNotify(params map[string]string) (*NotifyResult, error) {
+	if !Verify(params, &c.Config.Sign) {
+		return nil, fmt.Errorf("签名验证失败")
+	}
+
+	notify := &c.Config.Notify
+	result := &NotifyResult{
+		OrderNo: params[notify.OrderNoField],
+		TradeNo: params[notify.TradeNoField],
+		Status:  params[notify.StatusField],
+		Amount:  -1,
+	}
+	if result.OrderNo == "" {
+		return nil, fmt.Errorf("通知中没有订单号(%s)", notify.OrderNoField)
+	}
+	result.Paid = containsValue(notify.SuccessValues, result.Status)
+
+	if amount := params[notify.AmountField]; amount != "" {
+		money, err := parseAmount(amount, notify.AmountUnit)
+		if err != nil {
+			return nil, err
+		}
+		result.Amount = money
+	}
+	return result, nil
+}
+
+// signedParams 渲染字段映射并追加签名
+func (c *Client) signedParams(fields map[string]string, vars map[string]string) map[string]string {
+	params := render(fields, vars)
+	if sign := Sign(params, &c.Config.Sign); sign != "" {
+		params[c.Config.Sign.Field] = sign
+	}
+	return params
+}
+
+// do 发送请求并解析 JSON 响应
+func (c *Client) do(method, endpoint string, params map[string]string, remarks string) (interface{}, error) {
+	values := url.Values{}
+	for k, v := range params {
+		values.Set(k, v)
+	}
+
+	var (
+		req         *http.Request
+		requestBody string
+		err         error
+	)
+	switch method {
+	case MethodGet:
+		separator := "?"
+		if strings.Contains(endpoint, "?") {
+			separator = "&"
+		}
+		endpoint = endpoint + separator + values.Encode()
+		req, err = http.NewRequest(http.MethodGet, endpoint, nil)
+	case MethodPostJSON:
+		body, _ := json.Marshal(params)
+		requestBody = string(body)
+		req, err = http.NewRequest(http.MethodPost, endpoint, strings.NewReader(requestBody))
+		if req != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	default:
+		requestBody = values.Encode()
+		req, err = http.NewRequest(http.MethodPost, endpoint, strings.NewReader(requestBody))
+		if req != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("创建上游请求失败: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		go c.createQueryLog(endpoint, req.Method, requestBody, "", err.Error(), remarks)
+		return nil, fmt.Errorf("上游请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取上游响应失败: %w", err)
+	}
+	go c.createQueryLog(endpoint, req.Method, requestBody, strconv.Itoa(resp.StatusCode), string(body), remarks)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("上游响应状态码异常: %d", resp.StatusCode)
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("上游响应不是有效的 JSON: %w", err)
+	}
+	return result, nil
+}
+
+// createQueryLog 记录上游请求日志到 dvadmin_query_log
+func (c *Client) createQueryLog(url, requestMethod, requestBody, responseCode, jsonResult, remarks string) {
+	if database.DB == nil {
+		return
+	}
+
+	now := time.Now()
+	queryLog := &models.QueryLog{
+		OutOrderNo:     c.OutOrderNo,
+		OrderNo:        c.OrderNo,
+		URL:            url,
+		RequestBody:    requestBody,
+		RequestMethod:  requestMethod,
+		ResponseCode:   responseCode,
+		JSONResult:     jsonResult,
+		Remarks:        remarks,
+		CreateDatetime: &now,
+	}
+
+	if err := database.DB.Create(queryLog).Error; err != nil && logger.Logger != nil {
+		logger.Logger.Warn("创建查询日志失败",
+			zap.String("out_order_no", c.OutOrderNo),
+			zap.String("order_no", c.OrderNo),
+			zap.String("url", url),
+			zap.Error(err))
+	}
+}
+
+// parseAmount 按单位解析金额为分
+func parseAmount(amount, unit string) (int, error) {
+	if unit == AmountUnitFen {
+		money, err := strconv.Atoi(amount)
+		if err != nil {
+			return 0, fmt.Errorf("金额格式错误: %s", amount)
+		}
+		return money, nil
+	}
+
+	value, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return 0, fmt.Errorf("金额格式错误: %s", amount)
+	}
+	return int(math.Round(value * 100)), nil
+}
+
+// FormatYuan 将金额（分）格式化为元（两位小数）
+func FormatYuan(money int) string {
+	return fmt.Sprintf("%.2f", float64(money)/100)
+}
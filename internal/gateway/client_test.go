@@ -0,0 +1,169 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestConfig 创建对接本地桩的网关配置（易支付 mapi.php 风格）
+func newTestConfig(t *testing.T, url string) *Config {
+	t.Helper()
+	cfg := &Config{
+		URL: url,
+		Fields: map[string]string{
+			"pid":          "1001",
+			"out_trade_no": "{order_no}",
+			"money":        "{money_yuan}",
+			"notify_url":   "{notify_url}",
+		},
+		Sign:         SignConfig{Algorithm: SignEPayMD5, Key: "secret", Exclude: []string{"sign_type"}},
+		PayURLPath:   "$.data['pay_url']",
+		SuccessPath:  "$.code",
+		SuccessValue: "1",
+		MessagePath:  "$.msg",
+		Query: QueryConfig{
+			URL:          url,
+			Fields:       map[string]string{"out_trade_no": "{order_no}"},
+			StatusPath:   "$.status",
+			PaidValues:   []string{"1"},
+			TradeNoPath:  "$.trade_no",
+			AmountPath:   "$.money",
+			NotExistPath: "$.code",
+		},
+	}
+	cfg.Query.NotExistValue = "-1"
+	if err := cfg.Normalize(); err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+	return cfg
+}
+
+func TestClientCreateOrder(t *testing.T) {
+	var cfg *Config
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("ParseForm: %v", err)
+		}
+		params := map[string]string{}
+		for k := range r.PostForm {
+			params[k] = r.PostForm.Get(k)
+		}
+		if !Verify(params, &cfg.Sign) {
+			t.Errorf("upstream received invalid sign: %v", params)
+		}
+		if params["money"] != "12.34" || params["out_trade_no"] != "PAY001" {
+			t.Errorf("unexpected params: %v", params)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": 1,
+			"data": map[string]interface{}{"pay_url": "https://upstream.example.com/pay/1"},
+		})
+	}))
+	defer server.Close()
+
+	cfg = newTestConfig(t, server.URL)
+	client := NewClient(cfg, "", "")
+	client.HTTPClient = server.Client()
+	payURL, err := client.CreateOrder(map[string]string{
+		"order_no":   "PAY001",
+		"money_yuan": FormatYuan(1234),
+		"notify_url": "https://pay.example.com/notify/",
+	})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	if payURL != "https://upstream.example.com/pay/1" {
+		t.Fatalf("payURL = %q", payURL)
+	}
+}
+
+func TestClientCreateOrderFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": -1, "msg": "通道维护"})
+	}))
+	defer server.Close()
+
+	client := NewClient(newTestConfig(t, server.URL), "", "")
+	client.HTTPClient = server.Client()
+	_, err := client.CreateOrder(map[string]string{"order_no": "PAY001"})
+	if err == nil || err.Error() != "上游下单失败: 通道维护" {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestClientQueryOrder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("out_trade_no") == "MISSING" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": -1})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 1, "status": 1, "trade_no": "T100", "money": "12.34"})
+	}))
+	defer server.Close()
+
+	client := NewClient(newTestConfig(t, server.URL), "", "")
+	client.HTTPClient = server.Client()
+	result, err := client.QueryOrder(map[string]string{"order_no": "PAY001"})
+	if err != nil {
+		t.Fatalf("QueryOrder: %v", err)
+	}
+	if result.TradeState != TradeStatePaid || result.TradeNo != "T100" || result.Amount != 1234 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	result, err = client.QueryOrder(map[string]string{"order_no": "MISSING"})
+	if err != nil {
+		t.Fatalf("QueryOrder: %v", err)
+	}
+	if result.TradeState != TradeStateNotExist {
+		t.Fatalf("TradeState = %q", result.TradeState)
+	}
+}
+
+func TestClientVerifyNotify(t *testing.T) {
+	cfg := newTestConfig(t, "https://upstream.example.com/mapi.php")
+	params := map[string]string{
+		"out_trade_no": "PAY001",
+		"trade_no":     "T100",
+		"trade_status": "TRADE_SUCCESS",
+		"money":        "12.34",
+		"sign_type":    "MD5",
+	}
+	params["sign"] = Sign(params, &cfg.Sign)
+
+	client := NewClient(cfg, "", "")
+	result, err := client.VerifyNotify(params)
+	if err != nil {
+		t.Fatalf("VerifyNotify: %v", err)
+	}
+	if !result.Paid || result.OrderNo != "PAY001" || result.TradeNo != "T100" || result.Amount != 1234 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	params["money"] = "0.01"
+	if _, err := client.VerifyNotify(params); err == nil {
+		t.Fatal("tampered notify should fail verification")
+	}
+}
+
+func TestLookupString(t *testing.T) {
+	var data interface{}
+	json.Unmarshal([]byte(`{"data":{"list":[{"url":"a"},{"url":"b"}],"amount":100}}`), &data)
+
+	cases := map[string]string{
+		"$.data.list[1].url":       "b",
+		"$['data']['list'][0].url": "a",
+		"$.data.amount":            "100",
+	}
+	for path, want := range cases {
+		if got, ok := LookupString(data, path); !ok || got != want {
+			t.Errorf("LookupString(%q) = %q, %v; want %q", path, got, ok, want)
+		}
+	}
+	if _, ok := LookupString(data, "$.data.missing"); ok {
+		t.Error("missing path should not be found")
+	}
+}
@@ -0,0 +1,150 @@
+package gateway
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 请求方式
+const (
+	MethodPostForm = "POST_FORM" // POST application/x-www-form-urlencoded（默认）
+	MethodPostJSON = "POST_JSON" // POST application/json
+	MethodGet      = "GET"       // GET 查询参数
+)
+
+// 金额单位
+const (
+	AmountUnitYuan = "yuan" // 元（两位小数）
+	AmountUnitFen  = "fen"  // 分
+)
+
+// Config 上游网关配置（由插件配置 PayPluginConfig 组装）
+// 对接新的上游聚合平台只需配置地址、字段映射、签名算法和响应 JSONPath，无需编写新插件
+type Config struct {
+	URL          string            `json:"url"`           // 下单地址
+	Method       string            `json:"method"`        // 请求方式（POST_FORM、POST_JSON、GET）
+	Fields       map[string]string `json:"fields"`        // 下单字段映射：上游字段名 -> 值模板（支持 {order_no} 等占位符）
+	Sign         SignConfig        `json:"sign"`          // 签名配置
+	PayURLPath   string            `json:"pay_url_path"`  // 下单响应中支付地址的 JSONPath，如 $.payurl
+	SuccessPath  string            `json:"success_path"`  // 下单成功标识的 JSONPath（可选），如 $.code
+	SuccessValue string            `json:"success_value"` // 下单成功标识的值（可选），如 1
+	MessagePath  string            `json:"message_path"`  // 下单失败信息的 JSONPath（可选），如 $.msg
+	Notify       NotifyConfig      `json:"notify"`        // 异步通知配置
+	Query        QueryConfig       `json:"query"`         // 查单配置
+}
+
+// SignConfig 签名配置
+type SignConfig struct {
+	Algorithm string   `json:"algorithm"` // 签名算法：md5（默认）、epay_md5、hmac_sha256、none
+	Key       string   `json:"key"`       // 签名密钥
+	Field     string   `json:"field"`     // 签名字段名，默认 sign
+	Uppercase bool     `json:"uppercase"` // 签名是否输出大写（验签不区分大小写）
+	Exclude   []string `json:"exclude"`   // 不参与签名的字段（如 sign_type），签名字段本身总是排除
+}
+
+// NotifyConfig 异步通知配置（通知参数为表单或查询参数）
+type NotifyConfig struct {
+	OrderNoField  string   `json:"order_no_field"` // 系统订单号字段，默认 out_trade_no
+	TradeNoField  string   `json:"trade_no_field"` // 上游交易号字段，默认 trade_no
+	StatusField   string   `json:"status_field"`   // 交易状态字段，默认 trade_status
+	SuccessValues []string `json:"success_values"` // 支付成功状态值，默认 TRADE_SUCCESS
+	AmountField   string   `json:"amount_field"`   // 金额字段，默认 money
+	AmountUnit    string   `json:"amount_unit"`    // 金额单位：yuan（默认）、fen
+	Reply         string   `json:"reply"`          // 处理成功应答内容，默认 success
+}
+
+// QueryConfig 查单配置（未配置 URL 时不支持主动查单）
+type QueryConfig struct {
+	URL           string            `json:"url"`            // 查单地址
+	Method        string            `json:"method"`         // 请求方式，默认与下单一致
+	Fields        map[string]string `json:"fields"`         // 查单字段映射
+	StatusPath    string            `json:"status_path"`    // 交易状态的 JSONPath
+	PaidValues    []string          `json:"paid_values"`    // 已支付状态值
+	ClosedValues  []string          `json:"closed_values"`  // 已关闭状态值
+	TradeNoPath   string            `json:"trade_no_path"`  // 上游交易号的 JSONPath（可选）
+	AmountPath    string            `json:"amount_path"`    // 金额的 JSONPath（可选）
+	AmountUnit    string            `json:"amount_unit"`    // 金额单位：yuan（默认）、fen
+	NotExistPath  string            `json:"not_exist_path"` // 订单不存在标识的 JSONPath（可选）
+	NotExistValue string            `json:"not_exist_value"`
+}
+
+// Normalize 校验配置并填充默认值
+func (c *Config) Normalize() error {
+	if c.URL == "" {
+		return fmt.Errorf("上游网关未配置下单地址")
+	}
+	if c.PayURLPath == "" {
+		return fmt.Errorf("上游网关未配置支付地址 JSONPath")
+	}
+
+	c.Method = strings.ToUpper(c.Method)
+	if c.Method == "" {
+		c.Method = MethodPostForm
+	}
+	if c.Sign.Algorithm == "" {
+		c.Sign.Algorithm = SignMD5
+	}
+	if c.Sign.Field == "" {
+		c.Sign.Field = "sign"
+	}
+	if c.Sign.Algorithm != SignNone && c.Sign.Key == "" {
+		return fmt.Errorf("上游网关未配置签名密钥")
+	}
+
+	if c.Notify.OrderNoField == "" {
+		c.Notify.OrderNoField = "out_trade_no"
+	}
+	if c.Notify.TradeNoField == "" {
+		c.Notify.TradeNoField = "trade_no"
+	}
+	if c.Notify.StatusField == "" {
+		c.Notify.StatusField = "trade_status"
+	}
+	if len(c.Notify.SuccessValues) == 0 {
+		c.Notify.SuccessValues = []string{"TRADE_SUCCESS"}
+	}
+	if c.Notify.AmountField == "" {
+		c.Notify.AmountField = "money"
+	}
+	if c.Notify.AmountUnit == "" {
+		c.Notify.AmountUnit = AmountUnitYuan
+	}
+	if c.Notify.Reply == "" {
+		c.Notify.Reply = "success"
+	}
+
+	c.Query.Method = strings.ToUpper(c.Query.Method)
+	if c.Query.Method == "" {
+		c.Query.Method = c.Method
+	}
+	if c.Query.AmountUnit == "" {
+		c.Query.AmountUnit = AmountUnitYuan
+	}
+
+	return nil
+}
+
+// render 使用订单变量渲染字段映射（{name} 占位符替换为变量值）
+func render(fields map[string]string, vars map[string]string) map[string]string {
+	pairs := make([]string, 0, len(vars)*2)
+	for k, v := range vars {
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	result := make(map[string]string, len(fields))
+	for k, v := range fields {
+		result[k] = replacer.Replace(v)
+	}
+	return result
+}
+
+// containsValue 判断值是否在列表中
+func containsValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Lookup 按 JSONPath 从 JSON 数据中取值
+// 支持常用子集：$、.key、['key']、[index]，如 $.data.pay_url、$.list[0]['url']
+func Lookup(data interface{}, path string) (interface{}, bool) {
+	tokens, err := parsePath(path)
+	if err != nil {
+		return nil, false
+	}
+
+	current := data
+	for _, token := range tokens {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// LookupString 按 JSONPath 取值并转换为字符串（数字不带多余小数位，对象和数组返回 JSON）
+func LookupString(data interface{}, path string) (string, bool) {
+	value, ok := Lookup(data, path)
+	if !ok || value == nil {
+		return "", false
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(raw), true
+	}
+}
+
+// parsePath 将 JSONPath 解析为字段名/下标序列
+func parsePath(path string) ([]string, error) {
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSONPath 必须以 $ 开头: %s", path)
+	}
+
+	var tokens []string
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("JSONPath 字段名为空: %s", path)
+			}
+			tokens = append(tokens, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath 缺少 ]: %s", path)
+			}
+			token := strings.Trim(rest[1:end], `'"`)
+			tokens = append(tokens, token)
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("JSONPath 格式错误: %s", path)
+		}
+	}
+	return tokens, nil
+}
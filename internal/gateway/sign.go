@@ -0,0 +1,75 @@
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+)
+
+// 签名算法
+const (
+	SignMD5        = "md5"         // k=v&...&key=密钥 的 MD5
+	SignEPayMD5    = "epay_md5"    // 易支付：k=v&...密钥 的 MD5
+	SignHMACSHA256 = "hmac_sha256" // k=v&... 的 HMAC-SHA256（密钥为签名密钥）
+	SignNone       = "none"        // 不签名
+)
+
+// signContent 构建待签名字符串：过滤签名字段、排除字段和空值，按字段名排序后以 k=v 用 & 连接
+func signContent(params map[string]string, cfg *SignConfig) string {
+	keys := make([]string, 0, len(params))
+	for k, v := range params {
+		if k == cfg.Field || v == "" || containsValue(cfg.Exclude, k) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+params[k])
+	}
+	return strings.Join(pairs, "&")
+}
+
+// Sign 按配置的算法生成签名（算法为 none 时返回空字符串）
+func Sign(params map[string]string, cfg *SignConfig) string {
+	content := signContent(params, cfg)
+
+	var sign string
+	switch cfg.Algorithm {
+	case SignNone:
+		return ""
+	case SignEPayMD5:
+		sum := md5.Sum([]byte(content + cfg.Key))
+		sign = hex.EncodeToString(sum[:])
+	case SignHMACSHA256:
+		mac := hmac.New(sha256.New, []byte(cfg.Key))
+		mac.Write([]byte(content))
+		sign = hex.EncodeToString(mac.Sum(nil))
+	default:
+		sum := md5.Sum([]byte(content + "&key=" + cfg.Key))
+		sign = hex.EncodeToString(sum[:])
+	}
+
+	if cfg.Uppercase {
+		return strings.ToUpper(sign)
+	}
+	return sign
+}
+
+// Verify 验证签名（不区分大小写，使用常量时间比较）
+func Verify(params map[string]string, cfg *SignConfig) bool {
+	if cfg.Algorithm == SignNone {
+		return true
+	}
+	sign := strings.ToLower(params[cfg.Field])
+	if sign == "" {
+		return false
+	}
+	expected := strings.ToLower(Sign(params, cfg))
+	return hmac.Equal([]byte(sign), []byte(expected))
+}
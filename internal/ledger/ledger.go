@@ -0,0 +1,287 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-pay-core/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrUnbalanced 分录借贷不平（明细金额之和不为 0）
+	ErrUnbalanced = errors.New("账本分录借贷不平")
+	// ErrDuplicate 相同幂等键的分录已经记账
+	ErrDuplicate = errors.New("账本分录已存在")
+)
+
+// Account 账户标识
+type Account struct {
+	Type    string // 账户类型（models.LedgerAccountXxx）
+	OwnerID int64  // 租户/商户/核销ID，平台账户为 0
+}
+
+// Tenant 租户账户
+func Tenant(id int64) Account { return Account{Type: models.LedgerAccountTenant, OwnerID: id} }
+
+// Merchant 商户账户
+func Merchant(id int64) Account { return Account{Type: models.LedgerAccountMerchant, OwnerID: id} }
+
+// Writeoff 核销账户
+func Writeoff(id int64) Account { return Account{Type: models.LedgerAccountWriteoff, OwnerID: id} }
+
+// Platform 平台账户
+func Platform() Account { return Account{Type: models.LedgerAccountPlatform} }
+
+// Line 分录明细
+type Line struct {
+	Account  Account
+	Amount   int64   // 正数增加，负数减少
+	FlowType int     // 兼容流水类型（租户/核销流水的 flow_type，为 0 时不生成兼容流水）
+	Tax      float64 // 核销流水费率
+}
+
+// Entry 账本分录
+type Entry struct {
+	Key          string // 幂等键（同一业务事件只记账一次）
+	Type         string // 分录类型（models.LedgerEntryXxx）
+	OrderID      string
+	PayChannelID *int64
+	Remarks      string
+	Lines        []Line
+}
+
+// Post 在事务中记账：写入分录和明细，更新账户余额和对应的业务余额，并生成 Django 后台使用的兼容流水
+// tx 必须是业务数据所在的事务；明细金额之和不为 0 时返回 ErrUnbalanced，幂等键已记账时返回 ErrDuplicate
+func Post(tx *gorm.DB, entry Entry) ([]models.LedgerPosting, error) {
+	lines := make([]Line, 0, len(entry.Lines))
+	var sum int64
+	for _, line := range entry.Lines {
+		if line.Amount == 0 {
+			continue
+		}
+		sum += line.Amount
+		lines = append(lines, line)
+	}
+	if sum != 0 {
+		return nil, fmt.Errorf("%w: %s 合计 %d", ErrUnbalanced, entry.Key, sum)
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	var count int64
+	if err := tx.Model(&models.LedgerEntry{}).Where("event_key = ?", entry.Key).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查询账本分录失败: %w", err)
+	}
+	if count > 0 {
+		return nil, ErrDuplicate
+	}
+
+	now := time.Now()
+	row := &models.LedgerEntry{
+		EventKey:       entry.Key,
+		EntryType:      entry.Type,
+		PayChannelID:   entry.PayChannelID,
+		Remarks:        entry.Remarks,
+		CreateDatetime: &now,
+	}
+	if entry.OrderID != "" {
+		row.OrderID = &entry.OrderID
+	}
+	if err := tx.Create(row).Error; err != nil {
+		return nil, fmt.Errorf("写入账本分录失败: %w", err)
+	}
+
+	postings := make([]models.LedgerPosting, 0, len(lines))
+	for _, line := range lines {
+		posting, err := postLine(tx, row, line, now)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeCashflow(tx, row, line, posting); err != nil {
+			return nil, err
+		}
+		postings = append(postings, *posting)
+	}
+	return postings, nil
+}
+
+// postLine 记一条明细：更新账户余额和业务余额
+// 平台账户是所有订单的对方账户，逐笔更新会成为热点行，其余额在快照时按明细汇总
+func postLine(tx *gorm.DB, entry *models.LedgerEntry, line Line, now time.Time) (*models.LedgerPosting, error) {
+	account, err := ensureAccount(tx, line.Account, now)
+	if err != nil {
+		return nil, err
+	}
+	if line.Account.Type != models.LedgerAccountPlatform {
+		if err := tx.Model(&models.LedgerAccount{}).
+			Where("id = ?", account.ID).
+			Updates(map[string]interface{}{
+				"balance":         gorm.Expr("balance + ?", line.Amount),
+				"update_datetime": &now,
+			}).Error; err != nil {
+			return nil, fmt.Errorf("更新账本账户余额失败: %w", err)
+		}
+	}
+
+	var oldMoney, newMoney int64
+	switch line.Account.Type {
+	case models.LedgerAccountTenant:
+		newMoney, err = applyTenant(tx, line.Account.OwnerID, line.Amount)
+		oldMoney = newMoney - line.Amount
+	case models.LedgerAccountWriteoff:
+		var limited bool
+		limited, newMoney, err = applyWriteoff(tx, line.Account.OwnerID, line.Amount)
+		if limited {
+			oldMoney = newMoney - line.Amount
+		}
+	case models.LedgerAccountMerchant:
+		newMoney, err = applyMerchantPre(tx, line.Account.OwnerID, line.Amount)
+		oldMoney = newMoney - line.Amount
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	posting := &models.LedgerPosting{
+		EntryID:        entry.ID,
+		AccountID:      account.ID,
+		Amount:         line.Amount,
+		OldMoney:       oldMoney,
+		NewMoney:       newMoney,
+		FlowType:       line.FlowType,
+		Tax:            line.Tax,
+		CreateDatetime: &now,
+	}
+	if err := tx.Create(posting).Error; err != nil {
+		return nil, fmt.Errorf("写入账本明细失败: %w", err)
+	}
+	return posting, nil
+}
+
+// ensureAccount 查询账户，不存在时创建（并发创建时忽略唯一键冲突后重新查询）
+func ensureAccount(tx *gorm.DB, ref Account, now time.Time) (*models.LedgerAccount, error) {
+	var account models.LedgerAccount
+	err := tx.Where("account_type = ? AND owner_id = ?", ref.Type, ref.OwnerID).First(&account).Error
+	if err == nil {
+		return &account, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("查询账本账户失败: %w", err)
+	}
+
+	account = models.LedgerAccount{
+		AccountType:    ref.Type,
+		OwnerID:        ref.OwnerID,
+		CreateDatetime: &now,
+		UpdateDatetime: &now,
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&account).Error; err != nil {
+		return nil, fmt.Errorf("创建账本账户失败: %w", err)
+	}
+	account = models.LedgerAccount{}
+	if err := tx.Where("account_type = ? AND owner_id = ?", ref.Type, ref.OwnerID).First(&account).Error; err != nil {
+		return nil, fmt.Errorf("查询账本账户失败: %w", err)
+	}
+	return &account, nil
+}
+
+// applyTenant 变更租户余额，返回变更后的余额
+// 先原子更新再读取，更新语句持有行锁，读取到的即为本次变更后的余额
+func applyTenant(tx *gorm.DB, tenantID int64, amount int64) (int64, error) {
+	if err := tx.Model(&models.Tenant{}).
+		Where("id = ?", tenantID).
+		Update("balance", gorm.Expr("balance + ?", amount)).Error; err != nil {
+		return 0, fmt.Errorf("更新租户余额失败: %w", err)
+	}
+	var tenant models.Tenant
+	if err := tx.Select("balance").Where("id = ?", tenantID).First(&tenant).Error; err != nil {
+		return 0, fmt.Errorf("查询租户余额失败: %w", err)
+	}
+	return tenant.Balance, nil
+}
+
+// applyWriteoff 变更核销余额，返回是否限制余额及变更后的余额（余额为 NULL 表示不限制，不做变更）
+func applyWriteoff(tx *gorm.DB, writeoffID int64, amount int64) (bool, int64, error) {
+	if err := tx.Model(&models.Writeoff{}).
+		Where("id = ? AND balance IS NOT NULL", writeoffID).
+		Update("balance", gorm.Expr("balance + ?", amount)).Error; err != nil {
+		return false, 0, fmt.Errorf("更新核销余额失败: %w", err)
+	}
+	var writeoff models.Writeoff
+	if err := tx.Select("balance").Where("id = ?", writeoffID).First(&writeoff).Error; err != nil {
+		return false, 0, fmt.Errorf("查询核销余额失败: %w", err)
+	}
+	if writeoff.Balance == nil {
+		return false, 0, nil
+	}
+	return true, *writeoff.Balance, nil
+}
+
+// applyMerchantPre 变更商户预付款（没有预付款记录时创建），返回变更后的预付款
+func applyMerchantPre(tx *gorm.DB, merchantID int64, amount int64) (int64, error) {
+	result := tx.Model(&models.MerchantPre{}).
+		Where("merchant_id = ?", merchantID).
+		Update("pre_pay", gorm.Expr("pre_pay + ?", amount))
+	if result.Error != nil {
+		return 0, fmt.Errorf("更新商户预付款失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		pre := &models.MerchantPre{MerchantID: merchantID, PrePay: amount, Ver: 1}
+		if err := tx.Select("merchant_id", "pre_pay", "ver").Create(pre).Error; err != nil {
+			return 0, fmt.Errorf("创建商户预付款失败: %w", err)
+		}
+		return amount, nil
+	}
+
+	var pre models.MerchantPre
+	if err := tx.Select("pre_pay").Where("merchant_id = ?", merchantID).First(&pre).Error; err != nil {
+		return 0, fmt.Errorf("查询商户预付款失败: %w", err)
+	}
+	return pre.PrePay, nil
+}
+
+// writeCashflow 生成兼容流水（dvadmin_tenant_cashflow / dvadmin_writeoff_cashflow），供 Django 后台查询
+func writeCashflow(tx *gorm.DB, entry *models.LedgerEntry, line Line, posting *models.LedgerPosting) error {
+	if line.FlowType == 0 {
+		return nil
+	}
+
+	switch line.Account.Type {
+	case models.LedgerAccountTenant:
+		cashflow := &models.TenantCashflow{
+			Remarks:        entry.Remarks,
+			OldMoney:       posting.OldMoney,
+			NewMoney:       posting.NewMoney,
+			ChangeMoney:    posting.Amount,
+			FlowType:       line.FlowType,
+			OrderID:        entry.OrderID,
+			PayChannelID:   entry.PayChannelID,
+			TenantID:       line.Account.OwnerID,
+			CreateDatetime: posting.CreateDatetime,
+		}
+		if err := tx.Create(cashflow).Error; err != nil {
+			return fmt.Errorf("记录租户资金流水失败: %w", err)
+		}
+	case models.LedgerAccountWriteoff:
+		cashflow := &models.WriteoffCashflow{
+			Remarks:        entry.Remarks,
+			OldMoney:       posting.OldMoney,
+			NewMoney:       posting.NewMoney,
+			ChangeMoney:    posting.Amount,
+			FlowType:       line.FlowType,
+			Tax:            line.Tax,
+			OrderID:        entry.OrderID,
+			PayChannelID:   entry.PayChannelID,
+			WriteoffID:     line.Account.OwnerID,
+			CreateDatetime: posting.CreateDatetime,
+		}
+		if err := tx.Create(cashflow).Error; err != nil {
+			return fmt.Errorf("记录核销资金流水失败: %w", err)
+		}
+	}
+	return nil
+}
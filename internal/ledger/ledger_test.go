@@ -0,0 +1,206 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupTestDB 设置测试数据库并替换全局连接
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&models.Tenant{}, &models.Writeoff{}, &models.MerchantPre{}, &models.OrderDetail{},
+		&models.TenantCashflow{}, &models.WriteoffCashflow{},
+		&models.LedgerAccount{}, &models.LedgerEntry{}, &models.LedgerPosting{}, &models.LedgerSnapshot{},
+	); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+	if err := db.Exec("CREATE TABLE dvadmin_writeoff_pay_channel (writeoff_id integer, pay_channel_id integer, tax real)").Error; err != nil {
+		t.Fatalf("Failed to create writeoff pay channel table: %v", err)
+	}
+	oldDB, oldLogger := database.DB, logger.Logger
+	database.DB, logger.Logger = db, zap.NewNop()
+	t.Cleanup(func() {
+		database.DB, logger.Logger = oldDB, oldLogger
+	})
+	return db
+}
+
+func int64Ptr(v int64) *int64 { return &v }
+
+func TestPostUnbalanced(t *testing.T) {
+	db := setupTestDB(t)
+
+	_, err := Post(db, Entry{Key: "k", Type: models.LedgerEntryOrderPaid, Lines: []Line{
+		{Account: Tenant(1), Amount: -10},
+		{Account: Platform(), Amount: 5},
+	}})
+	if !errors.Is(err, ErrUnbalanced) {
+		t.Fatalf("Post = %v, want ErrUnbalanced", err)
+	}
+}
+
+func TestOrderPaidAndRefund(t *testing.T) {
+	db := setupTestDB(t)
+
+	// 租户 1、商户 3，最终核销 10（费率 2%，余额 10000）的上级核销 20（费率 3%，不限制余额）
+	db.Create(&models.Tenant{ID: 1, Balance: 1000})
+	db.Create(&models.Writeoff{ID: 20, Ver: 1})
+	db.Create(&models.Writeoff{ID: 10, Ver: 1, Balance: int64Ptr(10000), ParentWriteoffID: int64Ptr(20)})
+	db.Exec("INSERT INTO dvadmin_writeoff_pay_channel (writeoff_id, pay_channel_id, tax) VALUES (10, 5, 2), (20, 5, 3)")
+	db.Create(&models.OrderDetail{OrderID: "o1", NotifyMoney: 1000, MerchantTax: 50})
+
+	order := &models.Order{
+		ID:           "o1",
+		Money:        1000,
+		Tax:          30,
+		OrderStatus:  models.OrderStatusPaying,
+		MerchantID:   int64Ptr(3),
+		PayChannelID: int64Ptr(5),
+		WriteoffID:   int64Ptr(10),
+	}
+	ev := OrderEvent{Order: order, TenantID: int64Ptr(1), Writeoff: true}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		_, err := PostOrderPaid(tx, ev)
+		return err
+	}); err != nil {
+		t.Fatalf("PostOrderPaid: %v", err)
+	}
+	if _, err := PostOrderPaid(db, ev); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("second PostOrderPaid = %v, want ErrDuplicate", err)
+	}
+
+	assertBalances(t, db, 970, 10000-980, -950)
+
+	var tenantFlows []models.TenantCashflow
+	db.Find(&tenantFlows)
+	if len(tenantFlows) != 1 || tenantFlows[0].OldMoney != 1000 || tenantFlows[0].NewMoney != 970 ||
+		tenantFlows[0].ChangeMoney != -30 || tenantFlows[0].FlowType != models.TenantCashflowTypeConsume {
+		t.Fatalf("tenant cashflow = %+v", tenantFlows)
+	}
+	var writeoffFlows []models.WriteoffCashflow
+	db.Order("id").Find(&writeoffFlows)
+	if len(writeoffFlows) != 2 ||
+		writeoffFlows[0].WriteoffID != 10 || writeoffFlows[0].ChangeMoney != -980 || writeoffFlows[0].NewMoney != 10000-980 ||
+		writeoffFlows[1].WriteoffID != 20 || writeoffFlows[1].ChangeMoney != 10 || writeoffFlows[1].FlowType != models.WriteoffCashflowTypeSubProfit {
+		t.Fatalf("writeoff cashflow = %+v", writeoffFlows)
+	}
+
+	// 退款冲回租户手续费、最终核销跑量和商户预付款，上级核销收益不冲回
+	order.OrderStatus = models.OrderStatusPaid
+	if _, err := PostOrderRefund(db, ev); err != nil {
+		t.Fatalf("PostOrderRefund: %v", err)
+	}
+	assertBalances(t, db, 1000, 10000, 0)
+
+	var refundFlows int64
+	db.Model(&models.WriteoffCashflow{}).Where("flow_type = ?", models.WriteoffCashflowTypeRefund).Count(&refundFlows)
+	if refundFlows != 1 {
+		t.Fatalf("writeoff refund cashflow = %d, want 1", refundFlows)
+	}
+
+	// 每条分录借贷平衡
+	var unbalanced int64
+	db.Raw("SELECT COUNT(*) FROM (SELECT entry_id FROM dvadmin_ledger_posting GROUP BY entry_id HAVING SUM(amount) <> 0) t").Scan(&unbalanced)
+	if unbalanced != 0 {
+		t.Fatalf("unbalanced entries = %d", unbalanced)
+	}
+
+	// 快照：平台账户余额为下级收益 10 的对方金额
+	db.Model(&models.LedgerPosting{}).Where("1 = 1").Update("create_datetime", time.Now().Add(-2*snapshotSettleDelay))
+	if _, err := TakeSnapshot(context.Background(), time.Now()); err != nil {
+		t.Fatalf("TakeSnapshot: %v", err)
+	}
+	var platform models.LedgerAccount
+	db.Where("account_type = ?", models.LedgerAccountPlatform).First(&platform)
+	var snapshot models.LedgerSnapshot
+	if err := db.Where("account_id = ?", platform.ID).First(&snapshot).Error; err != nil || snapshot.Balance != -10 {
+		t.Fatalf("platform snapshot = %+v, %v", snapshot, err)
+	}
+}
+
+func TestOrderPartialRefund(t *testing.T) {
+	db := setupTestDB(t)
+
+	// 租户 1、商户 3，最终核销 10（费率 2%），订单金额 1000、租户手续费 33
+	db.Create(&models.Tenant{ID: 1, Balance: 1000})
+	db.Create(&models.Writeoff{ID: 10, Ver: 1, Balance: int64Ptr(10000)})
+	db.Exec("INSERT INTO dvadmin_writeoff_pay_channel (writeoff_id, pay_channel_id, tax) VALUES (10, 5, 2)")
+	db.Create(&models.OrderDetail{OrderID: "o1", NotifyMoney: 1000, MerchantTax: 50})
+
+	order := &models.Order{
+		ID:           "o1",
+		Money:        1000,
+		Tax:          33,
+		OrderStatus:  models.OrderStatusPaying,
+		MerchantID:   int64Ptr(3),
+		PayChannelID: int64Ptr(5),
+		WriteoffID:   int64Ptr(10),
+	}
+	ev := OrderEvent{Order: order, TenantID: int64Ptr(1), Writeoff: true}
+	if _, err := PostOrderPaid(db, ev); err != nil {
+		t.Fatalf("PostOrderPaid: %v", err)
+	}
+	assertBalances(t, db, 967, 10000-980, -950)
+
+	// 部分退款 1/3：按比例冲回（向零取整），同一退款单只记账一次
+	order.OrderStatus = models.OrderStatusPaid
+	if _, err := PostOrderPartialRefund(db, ev, "R1", 333); err != nil {
+		t.Fatalf("PostOrderPartialRefund: %v", err)
+	}
+	if _, err := PostOrderPartialRefund(db, ev, "R1", 333); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("second PostOrderPartialRefund = %v, want ErrDuplicate", err)
+	}
+	assertBalances(t, db, 967+10, 10000-980+326, -950+316)
+
+	// 全额退款金额不能按部分退款记账
+	if _, err := PostOrderPartialRefund(db, ev, "R2", 1000); err == nil {
+		t.Fatal("PostOrderPartialRefund with full amount should fail")
+	}
+
+	// 剩余部分退款：冲回支付分录与部分退款之差，累计冲回后余额恢复
+	if _, err := PostOrderRefund(db, ev); err != nil {
+		t.Fatalf("PostOrderRefund: %v", err)
+	}
+	assertBalances(t, db, 1000, 10000, 0)
+
+	var refundFlows []models.WriteoffCashflow
+	db.Where("flow_type = ?", models.WriteoffCashflowTypeRefund).Order("id").Find(&refundFlows)
+	if len(refundFlows) != 2 || refundFlows[0].ChangeMoney != 326 || refundFlows[1].ChangeMoney != 654 {
+		t.Fatalf("writeoff refund cashflow = %+v", refundFlows)
+	}
+
+	var unbalanced int64
+	db.Raw("SELECT COUNT(*) FROM (SELECT entry_id FROM dvadmin_ledger_posting GROUP BY entry_id HAVING SUM(amount) <> 0) t").Scan(&unbalanced)
+	if unbalanced != 0 {
+		t.Fatalf("unbalanced entries = %d", unbalanced)
+	}
+}
+
+// assertBalances 检查租户余额、最终核销余额和商户预付款
+func assertBalances(t *testing.T, db *gorm.DB, tenant, writeoff, merchantPre int64) {
+	t.Helper()
+	var tn models.Tenant
+	db.First(&tn, 1)
+	var wo models.Writeoff
+	db.First(&wo, 10)
+	var pre models.MerchantPre
+	db.Where("merchant_id = ?", 3).First(&pre)
+	if tn.Balance != tenant || wo.Balance == nil || *wo.Balance != writeoff || pre.PrePay != merchantPre {
+		t.Fatalf("balances = tenant %d, writeoff %v, merchant pre %d; want %d, %d, %d",
+			tn.Balance, wo.Balance, pre.PrePay, tenant, writeoff, merchantPre)
+	}
+}
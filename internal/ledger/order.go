@@ -0,0 +1,376 @@
+package ledger
+
+import (
+	"fmt"
+
+	"github.com/golang-pay-core/internal/models"
+	"gorm.io/gorm"
+)
+
+// OrderEvent 订单资金事件
+type OrderEvent struct {
+	Order    *models.Order // 变更前的订单（需包含 id、merchant_id、money、tax、order_status、pay_channel_id、writeoff_id）
+	TenantID *int64        // 订单所属租户，为空时不处理租户手续费
+	Writeoff bool          // 是否处理核销余额
+}
+
+// OrderPaidKey 订单支付成功分录的幂等键
+func OrderPaidKey(orderID string) string {
+	return models.LedgerEntryOrderPaid + ":" + orderID
+}
+
+// OrderRefundKey 订单退款分录的幂等键
+func OrderRefundKey(orderID string) string {
+	return models.LedgerEntryOrderRefund + ":" + orderID
+}
+
+// OrderPartialRefundKey 订单部分退款分录的幂等键（每个退款单记账一次）
+func OrderPartialRefundKey(orderID, refundNo string) string {
+	return models.LedgerEntryOrderPartialRefund + ":" + orderID + ":" + refundNo
+}
+
+// PostOrderPaid 订单支付成功记账
+//   - 租户：扣除手续费 tax（流水类型 1=消费）
+//   - 核销：最终核销扣除 订单金额 - 最终核销手续费（流水类型 1=跑量），
+//     各级上级核销获得与下级的费率差收益（流水类型 7=下级收益）
+//   - 商户：预付款减少实际收入 notify_money - merchant_tax
+//   - 平台：对方账户，金额为以上各项之和取反
+func PostOrderPaid(tx *gorm.DB, ev OrderEvent) ([]models.LedgerPosting, error) {
+	order := ev.Order
+	var lines []Line
+
+	// 根据文档：只有当 tenant 存在且 tax != 0 时才扣费和记录流水
+	if ev.TenantID != nil && order.Tax != 0 {
+		lines = append(lines, Line{
+			Account:  Tenant(*ev.TenantID),
+			Amount:   -int64(order.Tax),
+			FlowType: models.TenantCashflowTypeConsume,
+		})
+	}
+
+	if ev.Writeoff && order.WriteoffID != nil {
+		writeoffLines, err := writeoffPaidLines(tx, order)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, writeoffLines...)
+	}
+
+	if order.MerchantID != nil {
+		realMoney, err := orderRealMoney(tx, order.ID)
+		if err != nil {
+			return nil, err
+		}
+		if realMoney > 0 {
+			lines = append(lines, Line{Account: Merchant(*order.MerchantID), Amount: -realMoney})
+		}
+	}
+
+	return Post(tx, Entry{
+		Key:          OrderPaidKey(order.ID),
+		Type:         models.LedgerEntryOrderPaid,
+		OrderID:      order.ID,
+		PayChannelID: order.PayChannelID,
+		Lines:        withPlatform(lines),
+	})
+}
+
+// PostOrderRefund 订单退款记账：冲回支付成功分录中的租户手续费、最终核销跑量和商户预付款
+// 已部分退款的订单只冲回部分退款分录之外的剩余部分，累计冲回金额与支付分录一致（没有舍入误差）
+func PostOrderRefund(tx *gorm.DB, ev OrderEvent) ([]models.LedgerPosting, error) {
+	order := ev.Order
+
+	lines, err := refundLines(tx, ev)
+	if err != nil {
+		return nil, err
+	}
+	reversed, err := partialRefundedAmounts(tx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range lines {
+		lines[i].Amount -= reversed[refundLineKey{lines[i].Account, lines[i].FlowType}]
+	}
+
+	return Post(tx, Entry{
+		Key:          OrderRefundKey(order.ID),
+		Type:         models.LedgerEntryOrderRefund,
+		OrderID:      order.ID,
+		PayChannelID: order.PayChannelID,
+		Remarks:      "退款",
+		Lines:        withPlatform(lines),
+	})
+}
+
+// PostOrderPartialRefund 订单部分退款记账：按 退款金额 / 订单金额 的比例冲回支付成功分录（金额向零取整）
+// 累计退款达到订单金额时不调用本方法，由 PostOrderRefund 冲回剩余部分
+func PostOrderPartialRefund(tx *gorm.DB, ev OrderEvent, refundNo string, refundMoney int) ([]models.LedgerPosting, error) {
+	order := ev.Order
+	if order.Money <= 0 || refundMoney <= 0 || refundMoney >= order.Money {
+		return nil, fmt.Errorf("部分退款金额无效: %d / %d", refundMoney, order.Money)
+	}
+
+	lines, err := refundLines(tx, ev)
+	if err != nil {
+		return nil, err
+	}
+	for i := range lines {
+		lines[i].Amount = lines[i].Amount * int64(refundMoney) / int64(order.Money)
+	}
+
+	return Post(tx, Entry{
+		Key:          OrderPartialRefundKey(order.ID, refundNo),
+		Type:         models.LedgerEntryOrderPartialRefund,
+		OrderID:      order.ID,
+		PayChannelID: order.PayChannelID,
+		Remarks:      "部分退款",
+		Lines:        withPlatform(lines),
+	})
+}
+
+// refundLines 全额冲回支付成功分录的明细（不含平台对方账户）
+// 上级核销的下级收益不冲回；只有状态 6 的订单冲回商户预付款
+// 账本上线前支付的订单没有支付分录，按兼容流水冲回
+func refundLines(tx *gorm.DB, ev OrderEvent) ([]Line, error) {
+	order := ev.Order
+
+	paid, found, err := paidLines(tx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		paid, err = legacyPaidLines(tx, ev)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var lines []Line
+	for _, line := range paid {
+		switch line.Account.Type {
+		case models.LedgerAccountTenant:
+			if ev.TenantID == nil || *ev.TenantID != line.Account.OwnerID {
+				continue
+			}
+			lines = append(lines, Line{
+				Account:  line.Account,
+				Amount:   -line.Amount,
+				FlowType: models.TenantCashflowTypeRefund,
+			})
+		case models.LedgerAccountWriteoff:
+			if !ev.Writeoff || line.FlowType != models.WriteoffCashflowTypeRunVolume {
+				continue
+			}
+			lines = append(lines, Line{
+				Account:  line.Account,
+				Amount:   -line.Amount,
+				FlowType: models.WriteoffCashflowTypeRefund,
+				Tax:      line.Tax,
+			})
+		case models.LedgerAccountMerchant:
+			if order.OrderStatus != models.OrderStatusPaid {
+				continue
+			}
+			lines = append(lines, Line{Account: line.Account, Amount: -line.Amount})
+		}
+	}
+	return lines, nil
+}
+
+// refundLineKey 退款明细的账户和流水类型
+type refundLineKey struct {
+	Account  Account
+	FlowType int
+}
+
+// partialRefundedAmounts 查询订单部分退款分录已冲回的金额（按账户和流水类型汇总，不含平台账户）
+func partialRefundedAmounts(tx *gorm.DB, orderID string) (map[refundLineKey]int64, error) {
+	var rows []struct {
+		AccountType string
+		OwnerID     int64
+		FlowType    int
+		Amount      int64
+	}
+	if err := tx.Table("dvadmin_ledger_posting AS p").
+		Select("a.account_type, a.owner_id, p.flow_type, SUM(p.amount) AS amount").
+		Joins("JOIN dvadmin_ledger_entry AS e ON e.id = p.entry_id").
+		Joins("JOIN dvadmin_ledger_account AS a ON a.id = p.account_id").
+		Where("e.order_id = ? AND e.entry_type = ? AND a.account_type <> ?",
+			orderID, models.LedgerEntryOrderPartialRefund, models.LedgerAccountPlatform).
+		Group("a.account_type, a.owner_id, p.flow_type").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询订单部分退款分录失败: %w", err)
+	}
+
+	amounts := make(map[refundLineKey]int64, len(rows))
+	for _, row := range rows {
+		amounts[refundLineKey{Account{Type: row.AccountType, OwnerID: row.OwnerID}, row.FlowType}] = row.Amount
+	}
+	return amounts, nil
+}
+
+// withPlatform 追加平台对方账户明细，使分录借贷平衡
+func withPlatform(lines []Line) []Line {
+	var sum int64
+	for _, line := range lines {
+		sum += line.Amount
+	}
+	if sum == 0 {
+		return lines
+	}
+	return append(lines, Line{Account: Platform(), Amount: -sum})
+}
+
+// writeoffPaidLines 多级核销明细：从最终核销向上遍历所有上级核销，按各级在支付通道上的费率计算
+func writeoffPaidLines(tx *gorm.DB, order *models.Order) ([]Line, error) {
+	var writeoffIDs []int64
+	currentWriteoffID := *order.WriteoffID
+	for {
+		var writeoff models.Writeoff
+		if err := tx.Select("id, parent_writeoff_id").Where("id = ?", currentWriteoffID).First(&writeoff).Error; err != nil {
+			break // 如果查询失败，停止遍历
+		}
+		writeoffIDs = append(writeoffIDs, writeoff.ID)
+		if writeoff.ParentWriteoffID == nil {
+			break
+		}
+		currentWriteoffID = *writeoff.ParentWriteoffID
+	}
+	if len(writeoffIDs) == 0 {
+		return nil, nil
+	}
+
+	// 收集每级核销的费率（查询失败时费率为 0）
+	taxes := make([]float64, len(writeoffIDs))
+	if order.PayChannelID != nil {
+		for i, id := range writeoffIDs {
+			var writeoffChannel struct {
+				Tax float64 `gorm:"column:tax"`
+			}
+			if err := tx.Table("dvadmin_writeoff_pay_channel").
+				Select("tax").
+				Where("writeoff_id = ? AND pay_channel_id = ?", id, *order.PayChannelID).
+				Scan(&writeoffChannel).Error; err == nil {
+				taxes[i] = writeoffChannel.Tax
+			}
+		}
+	}
+
+	// 最终核销：实际扣除金额 = 订单金额 - int(最终核销费率 * 订单金额 / 100)
+	finalTaxAmount := int64(taxes[0] * float64(order.Money) / 100.0)
+	lines := []Line{{
+		Account:  Writeoff(writeoffIDs[0]),
+		Amount:   -(int64(order.Money) - finalTaxAmount),
+		FlowType: models.WriteoffCashflowTypeRunVolume,
+		Tax:      taxes[0],
+	}}
+
+	// 上级核销：收益 = int((上级费率 - 下级费率) * 订单金额 / 100)
+	for i := 1; i < len(writeoffIDs); i++ {
+		taxDiff := taxes[i] - taxes[i-1]
+		if taxDiff <= 0 {
+			continue
+		}
+		lines = append(lines, Line{
+			Account:  Writeoff(writeoffIDs[i]),
+			Amount:   int64(taxDiff * float64(order.Money) / 100.0),
+			FlowType: models.WriteoffCashflowTypeSubProfit,
+			Tax:      taxDiff,
+		})
+	}
+	return lines, nil
+}
+
+// orderRealMoney 商户实际收入 = 通知金额 - 商户手续费
+func orderRealMoney(tx *gorm.DB, orderID string) (int64, error) {
+	var detail models.OrderDetail
+	if err := tx.Select("notify_money, merchant_tax").
+		Where("order_id = ?", orderID).
+		First(&detail).Error; err != nil {
+		return 0, fmt.Errorf("查询订单详情失败: %w", err)
+	}
+	return int64(detail.NotifyMoney) - int64(detail.MerchantTax), nil
+}
+
+// paidLines 查询订单支付成功分录的明细
+func paidLines(tx *gorm.DB, orderID string) ([]Line, bool, error) {
+	var entry models.LedgerEntry
+	err := tx.Select("id").Where("event_key = ?", OrderPaidKey(orderID)).First(&entry).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("查询订单支付分录失败: %w", err)
+	}
+
+	var rows []struct {
+		AccountType string
+		OwnerID     int64
+		Amount      int64
+		FlowType    int
+		Tax         float64
+	}
+	if err := tx.Table("dvadmin_ledger_posting AS p").
+		Select("a.account_type, a.owner_id, p.amount, p.flow_type, p.tax").
+		Joins("JOIN dvadmin_ledger_account AS a ON a.id = p.account_id").
+		Where("p.entry_id = ?", entry.ID).
+		Order("p.id").
+		Scan(&rows).Error; err != nil {
+		return nil, false, fmt.Errorf("查询订单支付分录明细失败: %w", err)
+	}
+
+	lines := make([]Line, 0, len(rows))
+	for _, row := range rows {
+		lines = append(lines, Line{
+			Account:  Account{Type: row.AccountType, OwnerID: row.OwnerID},
+			Amount:   row.Amount,
+			FlowType: row.FlowType,
+			Tax:      row.Tax,
+		})
+	}
+	return lines, true, nil
+}
+
+// legacyPaidLines 按兼容流水还原账本上线前支付的订单明细（租户消费流水、最终核销跑量流水、商户实际收入）
+func legacyPaidLines(tx *gorm.DB, ev OrderEvent) ([]Line, error) {
+	order := ev.Order
+	var lines []Line
+
+	if ev.TenantID != nil {
+		var consumeMoney int64
+		if err := tx.Model(&models.TenantCashflow{}).
+			Where("order_id = ? AND tenant_id = ? AND flow_type = ?", order.ID, *ev.TenantID, models.TenantCashflowTypeConsume).
+			Select("COALESCE(SUM(change_money), 0)").
+			Scan(&consumeMoney).Error; err != nil {
+			return nil, fmt.Errorf("查询租户消费流水失败: %w", err)
+		}
+		lines = append(lines, Line{Account: Tenant(*ev.TenantID), Amount: consumeMoney})
+	}
+
+	if ev.Writeoff && order.WriteoffID != nil {
+		var cashflow models.WriteoffCashflow
+		err := tx.Where("order_id = ? AND writeoff_id = ? AND flow_type = ?", order.ID, *order.WriteoffID, models.WriteoffCashflowTypeRunVolume).
+			First(&cashflow).Error
+		if err == nil {
+			lines = append(lines, Line{
+				Account:  Writeoff(*order.WriteoffID),
+				Amount:   cashflow.ChangeMoney,
+				FlowType: models.WriteoffCashflowTypeRunVolume,
+				Tax:      cashflow.Tax,
+			})
+		} else if err != gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("查询核销跑量流水失败: %w", err)
+		}
+	}
+
+	if order.MerchantID != nil && order.OrderStatus == models.OrderStatusPaid {
+		realMoney, err := orderRealMoney(tx, order.ID)
+		if err != nil {
+			return nil, err
+		}
+		if realMoney > 0 {
+			lines = append(lines, Line{Account: Merchant(*order.MerchantID), Amount: -realMoney})
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,143 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang-pay-core/config"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// snapshotSettleDelay 快照只包含该时间之前写入的明细
+// 自增ID按分配顺序而不是提交顺序可见，留出时间让较早分配ID的事务提交，避免快照跳过明细
+const snapshotSettleDelay = time.Minute
+
+// Snapshotter 账户余额快照服务
+// 每个账户每天保留一条快照，按间隔刷新当天快照；快照余额 = 上次快照余额 + 之后的明细金额，
+// 可用于核对账户余额（平台账户余额只在快照中汇总）
+// 作为单实例服务只在主节点上运行（lifecycle.Supervisor）
+type Snapshotter struct {
+	interval time.Duration
+}
+
+// NewSnapshotter 创建账户余额快照服务（未配置时每小时刷新）
+func NewSnapshotter() *Snapshotter {
+	interval := time.Hour
+	if config.Cfg != nil && config.Cfg.Ledger.SnapshotInterval > 0 {
+		interval = config.Cfg.Ledger.SnapshotInterval
+	}
+	return &Snapshotter{interval: interval}
+}
+
+// Start 启动快照服务，阻塞直到 ctx 取消
+func (s *Snapshotter) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	logger.Logger.Info("账户余额快照服务已启动",
+		zap.Duration("interval", s.interval))
+
+	for {
+		if count, err := TakeSnapshot(ctx, time.Now()); err != nil {
+			logger.Logger.Error("刷新账户余额快照失败", zap.Error(err))
+		} else {
+			logger.Logger.Info("账户余额快照已刷新", zap.Int("accounts", count))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			logger.Logger.Info("账户余额快照服务已停止")
+			return
+		}
+	}
+}
+
+// TakeSnapshot 刷新所有账户在 now 当天的余额快照，返回刷新的账户数
+func TakeSnapshot(ctx context.Context, now time.Time) (int, error) {
+	db := database.DB.WithContext(ctx)
+
+	var watermark int64
+	if err := db.Model(&models.LedgerPosting{}).
+		Where("create_datetime < ?", now.Add(-snapshotSettleDelay)).
+		Select("COALESCE(MAX(id), 0)").
+		Scan(&watermark).Error; err != nil {
+		return 0, fmt.Errorf("查询账本明细失败: %w", err)
+	}
+
+	var accounts []models.LedgerAccount
+	if err := db.Select("id").Order("id").Find(&accounts).Error; err != nil {
+		return 0, fmt.Errorf("查询账本账户失败: %w", err)
+	}
+
+	date := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	count := 0
+	for _, account := range accounts {
+		updated, err := snapshotAccount(db, account.ID, date, watermark, now)
+		if err != nil {
+			return count, err
+		}
+		if updated {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// snapshotAccount 刷新单个账户的当天快照，明细没有变化时跳过
+func snapshotAccount(db *gorm.DB, accountID int64, date time.Time, watermark int64, now time.Time) (bool, error) {
+	var last models.LedgerSnapshot
+	err := db.Where("account_id = ?", accountID).Order("date DESC").First(&last).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return false, fmt.Errorf("查询账户快照失败: %w", err)
+	}
+	found := err == nil
+	today := found && last.Date.Format("2006-01-02") == date.Format("2006-01-02")
+	if today && last.LastPostingID >= watermark {
+		return false, nil
+	}
+
+	var delta int64
+	if err := db.Model(&models.LedgerPosting{}).
+		Where("account_id = ? AND id > ? AND id <= ?", accountID, last.LastPostingID, watermark).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&delta).Error; err != nil {
+		return false, fmt.Errorf("汇总账本明细失败: %w", err)
+	}
+
+	lastPostingID := last.LastPostingID
+	if watermark > lastPostingID {
+		lastPostingID = watermark
+	}
+
+	if today {
+		if err := db.Model(&models.LedgerSnapshot{}).
+			Where("id = ?", last.ID).
+			Updates(map[string]interface{}{
+				"balance":         last.Balance + delta,
+				"last_posting_id": lastPostingID,
+				"update_datetime": &now,
+			}).Error; err != nil {
+			return false, fmt.Errorf("更新账户快照失败: %w", err)
+		}
+		return true, nil
+	}
+
+	snapshot := &models.LedgerSnapshot{
+		AccountID:      accountID,
+		Date:           date,
+		Balance:        last.Balance + delta,
+		LastPostingID:  lastPostingID,
+		CreateDatetime: &now,
+		UpdateDatetime: &now,
+	}
+	if err := db.Create(snapshot).Error; err != nil {
+		return false, fmt.Errorf("写入账户快照失败: %w", err)
+	}
+	return true, nil
+}
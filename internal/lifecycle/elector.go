@@ -0,0 +1,183 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-pay-core/config"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/utils"
+	"go.uber.org/zap"
+)
+
+// leaderKey 主节点租约（值为实例ID）
+const leaderKey = "lifecycle:leader"
+
+// renewLeaseScript 续约（只有租约持有者可以续约）
+var renewLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseLeaseScript 释放租约（只有租约持有者可以释放）
+var releaseLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// LeaderStatus 主节点选举状态
+type LeaderStatus struct {
+	InstanceID string     `json:"instance_id"`          // 本实例ID
+	IsLeader   bool       `json:"is_leader"`            // 本实例是否为主节点
+	Leader     string     `json:"leader"`               // 当前主节点实例ID
+	Since      *time.Time `json:"since,omitempty"`      // 本实例当选时间
+	Standalone bool       `json:"standalone,omitempty"` // Redis 未初始化，按单实例运行
+	Error      string     `json:"error,omitempty"`      // 查询主节点失败的原因
+}
+
+// Elector 基于 Redis 租约的主节点选举
+// 租约持有者为主节点，需在租约过期前续约；续约失败（包括 Redis 不可用）立即放弃主节点身份，
+// 其他实例在租约过期后接管。Redis 未初始化时按单实例运行，本实例始终为主节点
+type Elector struct {
+	key        string
+	instanceID string
+	leaseTTL   time.Duration
+	leader     atomic.Bool
+	mu         sync.Mutex
+	since      time.Time
+}
+
+var (
+	globalElector     *Elector
+	globalElectorInit sync.Once
+)
+
+// GetElector 获取全局主节点选举器（单例模式）
+func GetElector() *Elector {
+	globalElectorInit.Do(func() {
+		leaseTTL := 15 * time.Second
+		if config.Cfg != nil && config.Cfg.Lifecycle.LeaseTTL > 0 {
+			leaseTTL = config.Cfg.Lifecycle.LeaseTTL
+		}
+		globalElector = NewElector(leaderKey, leaseTTL)
+	})
+	return globalElector
+}
+
+// NewElector 创建主节点选举器
+func NewElector(key string, leaseTTL time.Duration) *Elector {
+	hostname, _ := os.Hostname()
+	return &Elector{
+		key:        key,
+		instanceID: fmt.Sprintf("%s:%d:%s", hostname, os.Getpid(), utils.GenerateID()),
+		leaseTTL:   leaseTTL,
+	}
+}
+
+// InstanceID 本实例ID
+func (e *Elector) InstanceID() string {
+	return e.instanceID
+}
+
+// IsLeader 本实例是否为主节点
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// TryAcquire 获取或续约主节点租约，返回本实例是否为主节点
+func (e *Elector) TryAcquire(ctx context.Context) bool {
+	if database.RDB == nil {
+		e.setLeader(true)
+		return true
+	}
+
+	if e.leader.Load() {
+		renewed, err := renewLeaseScript.Run(ctx, database.RDB, []string{e.key}, e.instanceID, e.leaseTTL.Milliseconds()).Int()
+		if err == nil && renewed == 1 {
+			return true
+		}
+		e.setLeader(false)
+		logger.Logger.Warn("主节点续约失败，放弃主节点身份",
+			zap.String("instance_id", e.instanceID),
+			zap.Error(err))
+		return false
+	}
+
+	acquired, err := database.RDB.SetNX(ctx, e.key, e.instanceID, e.leaseTTL).Result()
+	if err != nil {
+		logger.Logger.Warn("获取主节点租约失败", zap.Error(err))
+		return false
+	}
+	if acquired {
+		e.setLeader(true)
+	}
+	return acquired
+}
+
+// Release 释放主节点租约（停止时调用，其他实例无需等待租约过期即可接管）
+func (e *Elector) Release() {
+	if !e.leader.Load() {
+		return
+	}
+	e.setLeader(false)
+	if database.RDB == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := releaseLeaseScript.Run(ctx, database.RDB, []string{e.key}, e.instanceID).Err(); err != nil {
+		logger.Logger.Warn("释放主节点租约失败", zap.Error(err))
+	}
+}
+
+// Status 查询主节点选举状态
+func (e *Elector) Status(ctx context.Context) *LeaderStatus {
+	status := &LeaderStatus{
+		InstanceID: e.instanceID,
+		IsLeader:   e.leader.Load(),
+		Standalone: database.RDB == nil,
+	}
+
+	e.mu.Lock()
+	if status.IsLeader && !e.since.IsZero() {
+		since := e.since
+		status.Since = &since
+	}
+	e.mu.Unlock()
+
+	if status.Standalone {
+		status.Leader = e.instanceID
+		return status
+	}
+	leader, err := database.RDB.Get(ctx, e.key).Result()
+	if err != nil && err != redis.Nil {
+		status.Error = err.Error()
+	}
+	status.Leader = leader
+	return status
+}
+
+// setLeader 更新主节点身份并记录当选时间
+func (e *Elector) setLeader(leader bool) {
+	if e.leader.Swap(leader) == leader {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if leader {
+		e.since = time.Now()
+	} else {
+		e.since = time.Time{}
+	}
+}
@@ -0,0 +1,180 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/golang-pay-core/config"
+	"github.com/golang-pay-core/internal/logger"
+	"go.uber.org/zap"
+)
+
+// RunFunc 服务运行函数，阻塞运行直到 ctx 取消
+type RunFunc func(ctx context.Context)
+
+// task 受管理的服务
+type task struct {
+	name      string
+	run       RunFunc
+	singleton bool
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// Supervisor 后台服务管理
+//   - 普通服务在所有实例上运行，按注册顺序启动
+//   - 单实例服务（定时扫描、补偿任务等）只在主节点上运行，当选时启动，失去主节点身份时停止
+//   - 收到 SIGINT/SIGTERM 后先停止单实例服务并释放租约，再按注册的相反顺序停止普通服务，
+//     整个过程不超过 drainTimeout
+type Supervisor struct {
+	elector       *Elector
+	drainTimeout  time.Duration
+	renewInterval time.Duration
+	services      []*task
+	singletons    []*task
+	leading       bool
+}
+
+// NewSupervisor 创建后台服务管理（未配置的项使用默认值）
+func NewSupervisor() *Supervisor {
+	cfg := config.LifecycleConfig{}
+	if config.Cfg != nil {
+		cfg = config.Cfg.Lifecycle
+	}
+	if cfg.DrainTimeout <= 0 {
+		cfg.DrainTimeout = 30 * time.Second
+	}
+	if cfg.LeaseTTL <= 0 {
+		cfg.LeaseTTL = 15 * time.Second
+	}
+	if cfg.RenewInterval <= 0 || cfg.RenewInterval >= cfg.LeaseTTL {
+		cfg.RenewInterval = cfg.LeaseTTL / 3
+	}
+
+	return &Supervisor{
+		elector:       GetElector(),
+		drainTimeout:  cfg.DrainTimeout,
+		renewInterval: cfg.RenewInterval,
+	}
+}
+
+// Add 注册在所有实例上运行的服务
+func (s *Supervisor) Add(name string, run RunFunc) {
+	s.services = append(s.services, &task{name: name, run: run})
+}
+
+// AddSingleton 注册只在主节点上运行的服务
+func (s *Supervisor) AddSingleton(name string, run RunFunc) {
+	s.singletons = append(s.singletons, &task{name: name, run: run, singleton: true})
+}
+
+// Run 启动所有服务并参与主节点选举，阻塞直到收到停止信号（或 ctx 取消）且所有服务停止
+// 有服务未在 drainTimeout 内停止时返回错误
+func (s *Supervisor) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	for _, t := range s.services {
+		s.start(t)
+	}
+
+	ticker := time.NewTicker(s.renewInterval)
+	defer ticker.Stop()
+
+	// 没有单实例服务时不参与选举（如只提供 HTTP 接口的进程），避免占用租约导致其他实例无法运行单实例服务
+	for ctx.Err() == nil {
+		if len(s.singletons) > 0 {
+			s.elect(ctx)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+		}
+	}
+
+	logger.Logger.Info("收到停止信号，开始停止后台服务",
+		zap.Duration("drain_timeout", s.drainTimeout))
+
+	deadline := time.Now().Add(s.drainTimeout)
+	stopped := s.stopTasks(s.singletons, deadline)
+	s.leading = false
+	s.elector.Release()
+	stopped = s.stopTasks(s.services, deadline) && stopped
+	if !stopped {
+		return fmt.Errorf("部分后台服务未在 %s 内停止", s.drainTimeout)
+	}
+	logger.Logger.Info("后台服务已全部停止")
+	return nil
+}
+
+// elect 获取或续约主节点租约，并按主节点身份启动或停止单实例服务
+func (s *Supervisor) elect(ctx context.Context) {
+	leader := s.elector.TryAcquire(ctx)
+	switch {
+	case leader && !s.leading:
+		s.leading = true
+		logger.Logger.Info("当选主节点，启动单实例服务",
+			zap.String("instance_id", s.elector.InstanceID()),
+			zap.Int("singletons", len(s.singletons)))
+		for _, t := range s.singletons {
+			s.start(t)
+		}
+	case !leader && s.leading:
+		s.leading = false
+		logger.Logger.Warn("失去主节点身份，停止单实例服务",
+			zap.String("instance_id", s.elector.InstanceID()))
+		s.stopTasks(s.singletons, time.Now().Add(s.drainTimeout))
+	}
+}
+
+// start 启动服务（服务异常退出时记录日志，不影响其他服务）
+func (s *Supervisor) start(t *task) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+	t.done = make(chan struct{})
+
+	go func() {
+		defer close(t.done)
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Logger.Error("后台服务异常退出",
+					zap.String("service", t.name),
+					zap.Any("panic", r))
+			}
+		}()
+		t.run(ctx)
+	}()
+
+	logger.Logger.Info("后台服务已启动",
+		zap.String("service", t.name),
+		zap.Bool("singleton", t.singleton))
+}
+
+// stopTasks 按启动的相反顺序停止服务，等待每个服务退出直到 deadline
+// 返回是否全部停止
+func (s *Supervisor) stopTasks(tasks []*task, deadline time.Time) bool {
+	stopped := true
+	for i := len(tasks) - 1; i >= 0; i-- {
+		t := tasks[i]
+		if t.cancel == nil {
+			continue
+		}
+		t.cancel()
+
+		timer := time.NewTimer(time.Until(deadline))
+		select {
+		case <-t.done:
+			logger.Logger.Info("后台服务已停止", zap.String("service", t.name))
+		case <-timer.C:
+			stopped = false
+			logger.Logger.Error("后台服务未在停止时限内退出", zap.String("service", t.name))
+		}
+		timer.Stop()
+		t.cancel = nil
+	}
+	return stopped
+}
@@ -0,0 +1,103 @@
+package lifecycle
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-pay-core/internal/logger"
+	"go.uber.org/zap"
+)
+
+// recorder 记录服务启动和停止顺序
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) service(name string) RunFunc {
+	return func(ctx context.Context) {
+		r.add("start " + name)
+		<-ctx.Done()
+		r.add("stop " + name)
+	}
+}
+
+func newTestSupervisor(t *testing.T, drainTimeout time.Duration) *Supervisor {
+	oldLogger := logger.Logger
+	logger.Logger = zap.NewNop()
+	t.Cleanup(func() { logger.Logger = oldLogger })
+
+	return &Supervisor{
+		elector:       NewElector(leaderKey, time.Second),
+		drainTimeout:  drainTimeout,
+		renewInterval: 10 * time.Millisecond,
+	}
+}
+
+func TestSupervisorStartStopOrder(t *testing.T) {
+	s := newTestSupervisor(t, time.Second)
+	rec := &recorder{}
+	s.Add("bus", rec.service("bus"))
+	s.AddSingleton("scan", rec.service("scan"))
+	s.Add("http", rec.service("http"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	// Redis 未初始化时按单实例运行，本实例为主节点
+	deadline := time.Now().Add(time.Second)
+	for !s.elector.IsLeader() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if s.elector.IsLeader() {
+		t.Fatal("leadership not released on shutdown")
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	stops := []string{}
+	for _, event := range rec.events {
+		if strings.HasPrefix(event, "stop ") {
+			stops = append(stops, event)
+		}
+	}
+	want := []string{"stop scan", "stop http", "stop bus"}
+	if !reflect.DeepEqual(stops, want) {
+		t.Fatalf("stop order = %v, want %v (events %v)", stops, want, rec.events)
+	}
+}
+
+func TestSupervisorDrainTimeout(t *testing.T) {
+	s := newTestSupervisor(t, 50*time.Millisecond)
+	block := make(chan struct{})
+	defer close(block)
+	s.Add("stuck", func(ctx context.Context) { <-block })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	if err := s.Run(ctx); err == nil {
+		t.Fatal("Run returned nil for a service that did not stop")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Run waited %v, want about the drain timeout", elapsed)
+	}
+}
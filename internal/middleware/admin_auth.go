@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-pay-core/config"
+	"github.com/golang-pay-core/internal/response"
+)
+
+// AdminAuth 管理接口认证中间件
+// 支持两种认证方式（满足其一即可）：
+// 1. Token 认证（Authorization: Bearer <token>）
+// 2. IP 白名单（支持 CIDR）
+// 与 MetricsAuth 不同，Token 和白名单都未配置时拒绝访问
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminCfg := config.Cfg.Admin
+
+		// 方式一：从 Authorization 头获取 Token
+		if adminCfg.Token != "" {
+			authHeader := c.GetHeader("Authorization")
+			parts := strings.SplitN(authHeader, " ", 2)
+			if len(parts) == 2 && parts[0] == "Bearer" && subtle.ConstantTimeCompare([]byte(parts[1]), []byte(adminCfg.Token)) == 1 {
+				c.Next()
+				return
+			}
+		}
+
+		// 方式二：检查 IP 白名单
+		clientIP := c.ClientIP()
+		for _, allowedIP := range adminCfg.IPWhitelist {
+			if clientIP == allowedIP {
+				c.Next()
+				return
+			}
+			if strings.Contains(allowedIP, "/") && isIPInCIDR(clientIP, allowedIP) {
+				c.Next()
+				return
+			}
+		}
+
+		// 认证失败
+		response.Fail(c, http.StatusUnauthorized, "未授权访问")
+		c.Abort()
+	}
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// AlipayBillDiff 支付宝账单对账差异（每次对账中每笔不一致的交易一条）
+type AlipayBillDiff struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	RunID          string     `gorm:"type:varchar(32);index;not null;comment:对账批次" json:"run_id"`
+	ProductID      int64      `gorm:"not null;index:idx_alipay_bill_diff_product,priority:1;comment:支付宝产品" json:"product_id"`
+	BillDate       string     `gorm:"type:varchar(10);not null;index:idx_alipay_bill_diff_product,priority:2;comment:账单日期" json:"bill_date"`
+	DiffType       string     `gorm:"type:varchar(16);not null;comment:差异类型" json:"diff_type"`
+	OrderID        string     `gorm:"type:varchar(30);comment:系统订单" json:"order_id,omitempty"`
+	OrderNo        string     `gorm:"type:varchar(64);comment:商户订单号(out_trade_no)" json:"order_no,omitempty"`
+	TradeNo        string     `gorm:"type:varchar(64);comment:支付宝交易号" json:"trade_no,omitempty"`
+	LocalMoney     int        `gorm:"not null;default:0;comment:本地订单金额(分)" json:"local_money"`
+	BillMoney      int        `gorm:"not null;default:0;comment:账单金额(分)" json:"bill_money"`
+	Remarks        string     `gorm:"type:varchar(255);comment:备注" json:"remarks,omitempty"`
+	CreateDatetime *time.Time `gorm:"comment:创建时间" json:"create_datetime,omitempty"`
+}
+
+// TableName 指定表名
+func (AlipayBillDiff) TableName() string {
+	return "dvadmin_alipay_bill_diff"
+}
+
+// 支付宝账单差异类型
+const (
+	AlipayBillDiffMissing        = "missing"         // 本地已支付，账单中没有对应交易
+	AlipayBillDiffExtra          = "extra"           // 账单中有交易，本地没有对应的已支付订单
+	AlipayBillDiffAmountMismatch = "amount_mismatch" // 账单订单金额与本地订单金额不一致
+)
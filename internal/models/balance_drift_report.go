@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// BalanceDriftReport 余额核对偏差报告（每次核对中每个确认存在的偏差一条）
+// Drift 为两次检测中较小的偏差（Actual - Expected 方向），自动修复按该值调整
+type BalanceDriftReport struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	RunID          string     `gorm:"type:varchar(32);index;not null;comment:核对批次" json:"run_id"`
+	CheckType      string     `gorm:"type:varchar(32);not null;index:idx_balance_drift_owner,priority:1;comment:核对项" json:"check_type"`
+	OwnerID        int64      `gorm:"not null;index:idx_balance_drift_owner,priority:2;comment:租户/核销ID" json:"owner_id"`
+	Expected       int64      `gorm:"not null;comment:期望值" json:"expected"`
+	Actual         int64      `gorm:"not null;comment:实际值" json:"actual"`
+	Drift          int64      `gorm:"not null;comment:偏差" json:"drift"`
+	Action         string     `gorm:"type:varchar(16);not null;comment:处理结果" json:"action"`
+	Remarks        string     `gorm:"type:varchar(255);comment:备注" json:"remarks,omitempty"`
+	CreateDatetime *time.Time `gorm:"comment:创建时间" json:"create_datetime,omitempty"`
+}
+
+// TableName 指定表名
+func (BalanceDriftReport) TableName() string {
+	return "dvadmin_balance_drift_report"
+}
+
+// 余额核对项
+const (
+	BalanceCheckTenantPreTax     = "tenant_pre_tax"    // Redis 预占手续费 vs 未完成订单手续费之和
+	BalanceCheckTenantBalance    = "tenant_balance"    // Redis 租户余额 vs 数据库租户余额
+	BalanceCheckWriteoffBalance  = "writeoff_balance"  // Redis 核销余额 vs 数据库核销余额
+	BalanceCheckTenantCashflow   = "tenant_cashflow"   // 数据库租户余额 vs 最近一条租户流水的变更后余额
+	BalanceCheckWriteoffCashflow = "writeoff_cashflow" // 数据库核销余额 vs 最近一条核销流水的变更后余额
+)
+
+// 余额偏差处理结果
+const (
+	BalanceDriftActionReport   = "report"   // 只报告（未开启自动修复）
+	BalanceDriftActionRepaired = "repaired" // 已自动修复
+	BalanceDriftActionSkipped  = "skipped"  // 修复时值已变化，下次核对重新检测
+	BalanceDriftActionAlert    = "alert"    // 超出修复阈值或不能自动修复，需要人工处理
+)
@@ -56,6 +56,7 @@ const (
 	TenantCashflowTypeConsume       = 1 // 消费（订单手续费）
 	TenantCashflowTypeRecharge      = 2 // 充值
 	TenantCashflowTypeOtherRecharge = 3 // 其他充值
+	TenantCashflowTypeRefund        = 4 // 订单退款（退还手续费）
 
 	// 核销流水类型 (WriteoffCashFlow)
 	WriteoffCashflowTypeRunVolume = 1 // 跑量（订单扣减）
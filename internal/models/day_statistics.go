@@ -138,3 +138,17 @@ type WriteOffChannelDayStatistics struct {
 func (WriteOffChannelDayStatistics) TableName() string {
 	return "dvadmin_day_statistics_channel_writeoff"
 }
+
+// OrderStatisticsRecord 订单统计记录（日统计事件的幂等键，与统计更新在同一事务中写入）
+// 唯一约束: (order_id, order_status)
+type OrderStatisticsRecord struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	OrderID        string     `gorm:"type:varchar(30);not null;uniqueIndex:uk_order_statistics_record,priority:1;comment:订单ID" json:"order_id"`
+	OrderStatus    int        `gorm:"not null;uniqueIndex:uk_order_statistics_record,priority:2;comment:订单状态" json:"order_status"`
+	CreateDatetime *time.Time `gorm:"comment:创建时间" json:"create_datetime,omitempty"`
+}
+
+// TableName 指定表名
+func (OrderStatisticsRecord) TableName() string {
+	return "dvadmin_order_statistics_record"
+}
@@ -0,0 +1,87 @@
+package models
+
+import "time"
+
+// LedgerAccount 账本账户（租户、商户、核销、平台）
+// Balance 为该账户所有分录明细的累计金额（平台账户不逐笔更新，以快照为准）；
+// 租户余额、核销余额、商户预付款等业务余额另外包含后台充值等账本外变动
+type LedgerAccount struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	AccountType    string     `gorm:"type:varchar(16);not null;uniqueIndex:idx_ledger_account_owner,priority:1;comment:账户类型" json:"account_type"`
+	OwnerID        int64      `gorm:"not null;uniqueIndex:idx_ledger_account_owner,priority:2;comment:账户所属ID" json:"owner_id"`
+	Balance        int64      `gorm:"not null;default:0;comment:账本余额" json:"balance"`
+	CreateDatetime *time.Time `gorm:"comment:创建时间" json:"create_datetime,omitempty"`
+	UpdateDatetime *time.Time `gorm:"comment:修改时间" json:"update_datetime,omitempty"`
+}
+
+// TableName 指定表名
+func (LedgerAccount) TableName() string {
+	return "dvadmin_ledger_account"
+}
+
+// LedgerEntry 账本分录（写入后不可修改，所有明细金额之和为 0）
+type LedgerEntry struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	EventKey       string     `gorm:"type:varchar(128);not null;uniqueIndex;comment:幂等键" json:"event_key"`
+	EntryType      string     `gorm:"type:varchar(32);not null;comment:分录类型" json:"entry_type"`
+	OrderID        *string    `gorm:"index;type:varchar(30);comment:系统订单" json:"order_id,omitempty"`
+	PayChannelID   *int64     `gorm:"comment:支付通道" json:"pay_channel_id,omitempty"`
+	Remarks        string     `gorm:"type:varchar(255);comment:备注" json:"remarks,omitempty"`
+	CreateDatetime *time.Time `gorm:"comment:创建时间" json:"create_datetime,omitempty"`
+}
+
+// TableName 指定表名
+func (LedgerEntry) TableName() string {
+	return "dvadmin_ledger_entry"
+}
+
+// LedgerPosting 账本分录明细（一个账户的一笔变动）
+// OldMoney/NewMoney 为业务余额变更前后的值，平台账户和余额无限制的核销账户均为 0
+type LedgerPosting struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	EntryID        int64      `gorm:"index;not null;comment:关联分录" json:"entry_id"`
+	AccountID      int64      `gorm:"index;not null;comment:关联账户" json:"account_id"`
+	Amount         int64      `gorm:"not null;comment:变动金额" json:"amount"`
+	OldMoney       int64      `gorm:"not null;comment:变更前余额" json:"old_money"`
+	NewMoney       int64      `gorm:"not null;comment:变更后余额" json:"new_money"`
+	FlowType       int        `gorm:"not null;default:0;comment:兼容流水类型" json:"flow_type"`
+	Tax            float64    `gorm:"type:decimal(5,2);not null;default:0.00;comment:费率" json:"tax"`
+	CreateDatetime *time.Time `gorm:"comment:创建时间" json:"create_datetime,omitempty"`
+}
+
+// TableName 指定表名
+func (LedgerPosting) TableName() string {
+	return "dvadmin_ledger_posting"
+}
+
+// LedgerSnapshot 账户余额日快照
+// 唯一约束: (account_id, date)
+type LedgerSnapshot struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	AccountID      int64      `gorm:"not null;uniqueIndex:idx_ledger_snapshot_account_date,priority:1;comment:关联账户" json:"account_id"`
+	Date           time.Time  `gorm:"type:date;not null;uniqueIndex:idx_ledger_snapshot_account_date,priority:2;comment:日期" json:"date"`
+	Balance        int64      `gorm:"not null;comment:账本余额" json:"balance"`
+	LastPostingID  int64      `gorm:"not null;default:0;comment:快照包含的最后一条明细" json:"last_posting_id"`
+	CreateDatetime *time.Time `gorm:"comment:创建时间" json:"create_datetime,omitempty"`
+	UpdateDatetime *time.Time `gorm:"comment:修改时间" json:"update_datetime,omitempty"`
+}
+
+// TableName 指定表名
+func (LedgerSnapshot) TableName() string {
+	return "dvadmin_ledger_snapshot"
+}
+
+// 账本账户类型
+const (
+	LedgerAccountTenant   = "tenant"   // 租户（余额：dvadmin_tenant.balance）
+	LedgerAccountMerchant = "merchant" // 商户（预付款：dvadmin_merchant_pre.pre_pay）
+	LedgerAccountWriteoff = "writeoff" // 核销（余额：dvadmin_writeoff.balance，NULL 表示不限制）
+	LedgerAccountPlatform = "platform" // 平台（所有分录的对方账户）
+)
+
+// 账本分录类型
+const (
+	LedgerEntryOrderPaid          = "order_paid"           // 订单支付成功
+	LedgerEntryOrderRefund        = "order_refund"         // 订单退款（全额退款，冲回部分退款之外的剩余部分）
+	LedgerEntryOrderPartialRefund = "order_partial_refund" // 订单部分退款（按退款金额比例冲回）
+)
@@ -15,6 +15,13 @@ type Merchant struct {
 	SystemUserID   *int64     `gorm:"uniqueIndex;comment:绑定的系统用户" json:"system_user_id,omitempty"`
 	ParentID       int64      `gorm:"index;not null;comment:上级租户" json:"parent_id"`
 
+	// 商户通知配置
+	NotifyFormat      string `gorm:"type:varchar(16);not null;default:'';comment:通知格式" json:"notify_format"`         // json、form、epay，为空时按订单兼容模式选择
+	NotifySuccessBody string `gorm:"type:varchar(64);not null;default:'';comment:通知成功应答" json:"notify_success_body"` // 商户应答内容（多个用逗号分隔），为空或 * 时只校验 HTTP 状态码
+
+	// 防重放：开启后商户接口请求必须携带 timestamp、nonce（参与签名）
+	ReplayProtect bool `gorm:"not null;default:0;comment:防重放" json:"replay_protect"`
+
 	// 关联关系
 	Parent      *Tenant              `gorm:"foreignKey:ParentID" json:"parent,omitempty"`
 	Orders      []Order              `gorm:"foreignKey:MerchantID" json:"orders,omitempty"`
@@ -26,6 +33,13 @@ func (Merchant) TableName() string {
 	return "dvadmin_merchant"
 }
 
+// 商户通知格式
+const (
+	NotifyFormatJSON = "json" // POST application/json（标准模式默认）
+	NotifyFormatForm = "form" // POST application/x-www-form-urlencoded
+	NotifyFormatEPay = "epay" // 易支付：GET 查询参数（兼容模式默认）
+)
+
 // MerchantPayChannel 商户支付通道关联
 type MerchantPayChannel struct {
 	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
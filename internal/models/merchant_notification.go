@@ -12,6 +12,9 @@ type MerchantNotification struct {
 	CreateDatetime *time.Time `gorm:"comment:创建时间" json:"create_datetime,omitempty"`
 	UpdateDatetime *time.Time `gorm:"comment:修改时间" json:"update_datetime,omitempty"`
 	Remarks        string     `gorm:"type:varchar(255);comment:备注" json:"remarks,omitempty"`
+
+	// 手动重新通知时间（Go 扩展字段）：重试次数只统计该时间之后的通知记录
+	RenotifyDatetime *time.Time `gorm:"comment:手动重新通知时间" json:"renotify_datetime,omitempty"`
 }
 
 // TableName 指定表名
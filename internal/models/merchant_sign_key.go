@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// MerchantSignKey 商户签名密钥
+// 商户可以同时持有多个启用的密钥（按有效期轮换），请求携带 keyId 时只使用对应的密钥验签，否则依次尝试所有有效的密钥；
+// 配置了签名密钥的商户不再接受系统用户密钥（MD5）签名。停用密钥请将 status 置为 0 或设置失效时间，不要直接删除
+type MerchantSignKey struct {
+	ID                 int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	MerchantID         int64      `gorm:"not null;uniqueIndex:uk_merchant_sign_key,priority:1;comment:商户ID" json:"merchant_id"`
+	KeyID              string     `gorm:"type:varchar(32);not null;uniqueIndex:uk_merchant_sign_key,priority:2;comment:密钥编号" json:"key_id"`
+	SignType           string     `gorm:"type:varchar(16);not null;comment:签名算法" json:"sign_type"`                    // md5、hmac_sha256、rsa2
+	Secret             string     `gorm:"type:varchar(255);not null;default:'';comment:共享密钥" json:"secret,omitempty"` // md5、hmac_sha256 的签名密钥
+	MerchantPublicKey  string     `gorm:"type:text;comment:商户公钥" json:"merchant_public_key,omitempty"`                // rsa2 验证商户请求签名
+	PlatformPrivateKey string     `gorm:"type:text;comment:平台私钥" json:"platform_private_key,omitempty"`               // rsa2 签名响应和通知（商户使用对应的平台公钥验签）
+	Status             bool       `gorm:"not null;default:1;comment:状态" json:"status"`                                // 是否启用
+	ValidFrom          *time.Time `gorm:"comment:生效时间" json:"valid_from,omitempty"`                                   // 为空表示立即生效
+	ValidUntil         *time.Time `gorm:"comment:失效时间" json:"valid_until,omitempty"`                                  // 为空表示永久有效
+	Remarks            string     `gorm:"type:varchar(255);comment:备注" json:"remarks,omitempty"`
+	CreateDatetime     *time.Time `gorm:"comment:创建时间" json:"create_datetime,omitempty"`
+	UpdateDatetime     *time.Time `gorm:"index;comment:修改时间" json:"update_datetime,omitempty"`
+}
+
+// TableName 指定表名
+func (MerchantSignKey) TableName() string {
+	return "dvadmin_merchant_sign_key"
+}
+
+// 商户签名算法
+const (
+	SignTypeMD5        = "md5"         // 标准模式 k=v&...&key=密钥、兼容模式 k=v&...密钥 的 MD5（大写）
+	SignTypeHMACSHA256 = "hmac_sha256" // k=v&... 的 HMAC-SHA256（大写十六进制）
+	SignTypeRSA2       = "rsa2"        // k=v&... 的 SHA256withRSA（Base64）
+)
+
+// Active 密钥在 now 时是否有效
+func (k *MerchantSignKey) Active(now time.Time) bool {
+	if !k.Status {
+		return false
+	}
+	if k.ValidFrom != nil && now.Before(*k.ValidFrom) {
+		return false
+	}
+	if k.ValidUntil != nil && !now.Before(*k.ValidUntil) {
+		return false
+	}
+	return true
+}
+
+// CanSign 密钥是否可以用于签名响应和通知（非对称算法需要平台私钥）
+func (k *MerchantSignKey) CanSign() bool {
+	switch k.SignType {
+	case SignTypeRSA2:
+		return k.PlatformPrivateKey != ""
+	default:
+		return k.Secret != ""
+	}
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// MQConsumeRecord 消息消费幂等记录（Redis 不可用时使用 MySQL 去重）
+type MQConsumeRecord struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	DedupeKey      string     `gorm:"type:varchar(191);uniqueIndex;not null;comment:幂等键" json:"dedupe_key"`
+	Topic          string     `gorm:"type:varchar(64);not null;comment:主题" json:"topic"`
+	MessageID      string     `gorm:"type:varchar(64);comment:消息ID" json:"message_id"`
+	Token          string     `gorm:"type:varchar(64);not null;comment:处理令牌" json:"-"`
+	Status         int        `gorm:"not null;comment:状态" json:"status"`
+	ExpireDatetime time.Time  `gorm:"index;not null;comment:过期时间" json:"expire_datetime"`
+	CreateDatetime *time.Time `gorm:"comment:创建时间" json:"create_datetime,omitempty"`
+	UpdateDatetime *time.Time `gorm:"comment:修改时间" json:"update_datetime,omitempty"`
+}
+
+// TableName 指定表名
+func (MQConsumeRecord) TableName() string {
+	return "dvadmin_mq_consume_record"
+}
+
+// ConsumeRecordStatus 消费记录状态常量
+const (
+	ConsumeRecordStatusProcessing = 0 // 处理中
+	ConsumeRecordStatusDone       = 1 // 已处理
+)
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// MQDeadLetter 消息死信模型（处理失败次数达到上限的消息，可在管理接口中查看和重放）
+type MQDeadLetter struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Backend        string     `gorm:"type:varchar(16);not null;comment:消息总线后端" json:"backend"`
+	Topic          string     `gorm:"type:varchar(64);index;not null;comment:主题" json:"topic"`
+	Tag            string     `gorm:"type:varchar(64);comment:标签" json:"tag,omitempty"`
+	MessageID      string     `gorm:"type:varchar(64);index;comment:消息ID" json:"message_id,omitempty"`
+	Body           string     `gorm:"type:longtext;not null;comment:消息体" json:"body"`
+	Error          string     `gorm:"type:longtext;comment:最后一次处理错误" json:"error,omitempty"`
+	Attempts       int        `gorm:"not null;comment:投递次数" json:"attempts"`
+	Status         int        `gorm:"index;not null;comment:状态" json:"status"`
+	ReplayCount    int        `gorm:"not null;default:0;comment:重放次数" json:"replay_count"`
+	ReplayDatetime *time.Time `gorm:"comment:最近重放时间" json:"replay_datetime,omitempty"`
+	CreateDatetime *time.Time `gorm:"index;comment:创建时间" json:"create_datetime,omitempty"`
+	UpdateDatetime *time.Time `gorm:"comment:修改时间" json:"update_datetime,omitempty"`
+}
+
+// TableName 指定表名
+func (MQDeadLetter) TableName() string {
+	return "dvadmin_mq_dead_letter"
+}
+
+// DeadLetterStatus 死信状态常量
+const (
+	DeadLetterStatusPending  = 0 // 待处理
+	DeadLetterStatusReplayed = 1 // 已重放
+)
@@ -52,6 +52,8 @@ type OrderDetail struct {
 	Extra          string     `gorm:"type:json;comment:额外数据" json:"extra,omitempty"`
 	Remarks        string     `gorm:"type:longtext;comment:备注" json:"remarks,omitempty"`
 	BuyerID        string     `gorm:"type:varchar(255);comment:买家ID" json:"buyer_id,omitempty"`
+	RiskDecision   string     `gorm:"type:varchar(16);comment:风控决策" json:"risk_decision,omitempty"` // allow、challenge、block
+	RiskReason     string     `gorm:"type:varchar(255);comment:风控原因" json:"risk_reason,omitempty"`
 	CreateDatetime *time.Time `gorm:"comment:创建时间" json:"create_datetime,omitempty"`
 	UpdateDatetime *time.Time `gorm:"comment:修改时间" json:"update_datetime,omitempty"`
 	CreatorID      *int64     `gorm:"index;comment:创建人" json:"creator_id,omitempty"`
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// OrderRefund 订单退款记录模型
+// 一笔订单可以有多条退款记录（部分退款），累计退款金额达到订单金额时订单状态变更为已退款
+type OrderRefund struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	RefundNo       string     `gorm:"uniqueIndex;type:varchar(64);not null;comment:退款单号" json:"refund_no"`
+	OutRefundNo    string     `gorm:"index;type:varchar(64);comment:商户退款单号" json:"out_refund_no,omitempty"`
+	OrderID        string     `gorm:"index;type:varchar(30);not null;comment:关联订单" json:"order_id"`
+	OrderNo        string     `gorm:"index;type:varchar(32);not null;comment:本系统订单号" json:"order_no"`
+	MerchantID     int64      `gorm:"index;not null;comment:关联商户" json:"merchant_id"`
+	RefundMoney    int        `gorm:"not null;comment:退款金额(分)" json:"refund_money"`
+	RefundStatus   int        `gorm:"index;not null;default:0;comment:退款状态" json:"refund_status"`
+	Reason         string     `gorm:"type:varchar(255);comment:退款原因" json:"reason,omitempty"`
+	TicketNo       string     `gorm:"type:varchar(255);comment:官方流水号" json:"ticket_no,omitempty"`
+	ErrorMessage   string     `gorm:"type:varchar(255);comment:失败原因" json:"error_message,omitempty"`
+	CreateDatetime *time.Time `gorm:"comment:创建时间" json:"create_datetime,omitempty"`
+	UpdateDatetime *time.Time `gorm:"comment:修改时间" json:"update_datetime,omitempty"`
+}
+
+// TableName 指定表名
+func (OrderRefund) TableName() string {
+	return "dvadmin_order_refund"
+}
+
+// RefundStatus 退款状态常量
+const (
+	RefundStatusPending = 0 // 退款中
+	RefundStatusSuccess = 1 // 退款成功
+	RefundStatusFailed  = 2 // 退款失败
+)
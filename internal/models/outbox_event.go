@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// OutboxEvent 事务发件箱事件（与业务数据在同一事务中写入，由发件箱中继投递到消息总线）
+type OutboxEvent struct {
+	ID                int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Topic             string     `gorm:"type:varchar(64);not null;comment:主题" json:"topic"`
+	Tag               string     `gorm:"type:varchar(64);comment:标签" json:"tag,omitempty"`
+	EventKey          string     `gorm:"type:varchar(128);index;comment:业务键" json:"event_key,omitempty"`
+	Body              string     `gorm:"type:longtext;not null;comment:消息体" json:"body"`
+	Delayed           bool       `gorm:"not null;default:false;comment:是否延迟消息" json:"delayed"`
+	DeliverDatetime   time.Time  `gorm:"not null;comment:期望投递时间" json:"deliver_datetime"`
+	Status            int        `gorm:"not null;index:idx_outbox_status_retry,priority:1;comment:状态" json:"status"`
+	Attempts          int        `gorm:"not null;default:0;comment:发送次数" json:"attempts"`
+	LastError         string     `gorm:"type:text;comment:最后一次发送错误" json:"last_error,omitempty"`
+	NextRetryDatetime time.Time  `gorm:"not null;index:idx_outbox_status_retry,priority:2;comment:下次发送时间" json:"next_retry_datetime"`
+	SentDatetime      *time.Time `gorm:"comment:发送时间" json:"sent_datetime,omitempty"`
+	CreateDatetime    *time.Time `gorm:"comment:创建时间" json:"create_datetime,omitempty"`
+	UpdateDatetime    *time.Time `gorm:"comment:修改时间" json:"update_datetime,omitempty"`
+}
+
+// TableName 指定表名
+func (OutboxEvent) TableName() string {
+	return "dvadmin_outbox_event"
+}
+
+// OutboxStatus 发件箱事件状态常量
+const (
+	OutboxStatusPending = 0 // 待发送
+	OutboxStatusSent    = 1 // 已发送
+)
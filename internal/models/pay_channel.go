@@ -18,7 +18,8 @@ type PayChannel struct {
 	StartTime      string     `gorm:"type:varchar(8);not null;comment:启用时间" json:"start_time"`
 	EndTime        string     `gorm:"type:varchar(8);not null;comment:结束时间" json:"end_time"`
 	ExtraArg       *int       `gorm:"comment:额外参数" json:"extra_arg,omitempty"`
-	BanIP          string     `gorm:"type:json;comment:封禁IP列表" json:"ban_ip,omitempty"`
+	BanIP          string     `gorm:"type:json;comment:封禁IP列表" json:"ban_ip,omitempty"`      // JSON 数组，元素为 IP 或 CIDR
+	AccessPolicy   string     `gorm:"type:json;comment:访问策略" json:"access_policy,omitempty"` // PayChannelAccessPolicy（地区、设备限制）
 	Logo           string     `gorm:"type:longtext;comment:图标" json:"logo,omitempty"`
 	CreateDatetime *time.Time `gorm:"comment:创建时间" json:"create_datetime,omitempty"`
 	UpdateDatetime *time.Time `gorm:"comment:修改时间" json:"update_datetime,omitempty"`
@@ -37,6 +38,17 @@ func (PayChannel) TableName() string {
 	return "dvadmin_pay_channel"
 }
 
+// PayChannelAccessPolicy 支付通道访问策略（pay_channel.access_policy）
+// 省份、城市按 ip2region 归属地名称匹配，规则可以省略"省"、"市"后缀（如"广东"匹配"广东省"）；
+// 同时配置允许和拒绝时先检查拒绝
+type PayChannelAccessPolicy struct {
+	AllowProvinces []string `json:"allow_provinces,omitempty"` // 只允许的省份（为空不限制）
+	DenyProvinces  []string `json:"deny_provinces,omitempty"`  // 拒绝的省份
+	AllowCities    []string `json:"allow_cities,omitempty"`    // 只允许的城市（为空不限制）
+	DenyCities     []string `json:"deny_cities,omitempty"`     // 拒绝的城市
+	DeviceTypes    []int    `json:"device_types,omitempty"`    // 允许的设备类型（DeviceTypeAndroid 等，为空不限制）
+}
+
 // PayChannelTax 支付通道费率（租户级别）
 type PayChannelTax struct {
 	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
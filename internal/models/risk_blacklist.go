@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// RiskBlacklist 买家风控黑名单（命中时收银台和下单直接拒绝）
+// ExpireDatetime 为空表示永久有效
+type RiskBlacklist struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ListType       string     `gorm:"type:varchar(16);not null;uniqueIndex:uk_risk_blacklist,priority:1;comment:名单类型" json:"list_type"`
+	Value          string     `gorm:"type:varchar(128);not null;uniqueIndex:uk_risk_blacklist,priority:2;comment:名单值" json:"value"`
+	Remarks        string     `gorm:"type:varchar(255);comment:备注" json:"remarks,omitempty"`
+	ExpireDatetime *time.Time `gorm:"index;comment:过期时间" json:"expire_datetime,omitempty"`
+	CreateDatetime *time.Time `gorm:"comment:创建时间" json:"create_datetime,omitempty"`
+}
+
+// TableName 指定表名
+func (RiskBlacklist) TableName() string {
+	return "dvadmin_risk_blacklist"
+}
+
+// 风控黑名单类型
+const (
+	RiskListIP          = "ip"          // 客户端 IP
+	RiskListFingerprint = "fingerprint" // 收银台设备指纹
+	RiskListUser        = "user"        // 收银台用户ID
+	RiskListBuyer       = "buyer"       // 支付宝 buyer_id（微信 openid）
+)
+
+// 风控决策（记录在订单详情 risk_decision）
+const (
+	RiskDecisionAllow     = "allow"     // 放行
+	RiskDecisionChallenge = "challenge" // 收银台要求用户确认后才跳转支付
+	RiskDecisionBlock     = "block"     // 拒绝下单或不展示支付链接
+)
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// WechatProduct 微信支付产品模型（微信支付商户号配置）
+// 支持两种验签方式：
+// 1. 微信支付公钥模式（配置 public_key_id 和 public_key）
+// 2. 平台证书模式（未配置公钥时自动下载平台证书）
+type WechatProduct struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name           string     `gorm:"type:varchar(255);not null;comment:产品名称" json:"name"`
+	MchID          string     `gorm:"type:varchar(32);not null;comment:商户号" json:"mch_id"`
+	AppID          string     `gorm:"type:varchar(32);not null;comment:应用ID" json:"app_id"`
+	AppSecret      string     `gorm:"type:varchar(64);comment:应用密钥(JSAPI网页授权)" json:"app_secret,omitempty"`
+	SerialNo       string     `gorm:"type:varchar(64);not null;comment:商户证书序列号" json:"serial_no"`
+	PrivateKey     string     `gorm:"type:text;comment:商户API私钥" json:"private_key,omitempty"`
+	APIV3Key       string     `gorm:"column:api_v3_key;type:varchar(32);comment:APIv3密钥" json:"api_v3_key,omitempty"`
+	PublicKeyID    string     `gorm:"type:varchar(64);comment:微信支付公钥ID" json:"public_key_id,omitempty"`
+	PublicKey      string     `gorm:"type:text;comment:微信支付公钥" json:"public_key,omitempty"`
+	Gateway        string     `gorm:"type:varchar(255);comment:接口地址(为空使用官方地址)" json:"gateway,omitempty"`
+	Subject        string     `gorm:"type:varchar(255);comment:订单主题" json:"subject,omitempty"`
+	Status         bool       `gorm:"not null;default:1;comment:状态" json:"status"`
+	IsDelete       bool       `gorm:"not null;default:0;comment:是否删除" json:"is_delete"`
+	CanPay         bool       `gorm:"not null;default:1;comment:是否允许进单" json:"can_pay"`
+	LimitMoney     int        `gorm:"not null;default:0;comment:限额" json:"limit_money"`
+	MaxMoney       int        `gorm:"not null;default:0;comment:最大金额" json:"max_money"`
+	MinMoney       int        `gorm:"not null;default:0;comment:最小金额" json:"min_money"`
+	WriteoffID     int64      `gorm:"index;not null;comment:关联核销" json:"writeoff_id"`
+	CreateDatetime *time.Time `gorm:"comment:创建时间" json:"create_datetime,omitempty"`
+	UpdateDatetime *time.Time `gorm:"comment:修改时间" json:"update_datetime,omitempty"`
+
+	// 关联关系
+	Writeoff *Writeoff `gorm:"foreignKey:WriteoffID" json:"writeoff,omitempty"`
+}
+
+// TableName 指定表名
+func (WechatProduct) TableName() string {
+	return "dvadmin_wechat_product"
+}
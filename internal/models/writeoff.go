@@ -12,7 +12,7 @@ type Writeoff struct {
 	UpdateDatetime *time.Time `gorm:"comment:修改时间" json:"update_datetime,omitempty"`
 	CreateDatetime *time.Time `gorm:"comment:创建时间" json:"create_datetime,omitempty"`
 	Balance        *int64     `gorm:"comment:金额" json:"balance,omitempty"`
-	White          string     `gorm:"type:json;default:'[]';comment:白名单" json:"white,omitempty"`
+	White          string     `gorm:"type:json;default:'[]';comment:白名单" json:"white,omitempty"` // WriteoffWhitelist，为空时不限制
 	Telegram       string     `gorm:"type:varchar(255);comment:Telegram群的id" json:"telegram,omitempty"`
 	Ver            int64      `gorm:"not null;comment:版本号" json:"ver"`
 	CreatorID      *int64     `gorm:"index;comment:创建人" json:"creator_id,omitempty"`
@@ -30,6 +30,17 @@ func (Writeoff) TableName() string {
 	return "dvadmin_writeoff"
 }
 
+// WriteoffWhitelist 核销白名单（writeoff.white）
+// 配置后核销只接收匹配的订单：每个配置的维度都必须匹配，同一维度内匹配任意一项即可；
+// 省份、城市按 ip2region 归属地名称匹配，可以省略"省"、"市"后缀。
+// 兼容旧格式：JSON 数组视为 IP 列表，空数组表示不限制
+type WriteoffWhitelist struct {
+	MerchantIDs []int64  `json:"merchant_ids,omitempty"` // 允许的商户ID
+	IPs         []string `json:"ips,omitempty"`          // 允许的用户IP或网段（CIDR）
+	Provinces   []string `json:"provinces,omitempty"`    // 允许的用户IP归属省份
+	Cities      []string `json:"cities,omitempty"`       // 允许的用户IP归属城市
+}
+
 // WriteoffPayChannel 核销支付通道关联表
 type WriteoffPayChannel struct {
 	WriteoffID   int64 `gorm:"primaryKey;comment:核销" json:"writeoff_id"`
@@ -0,0 +1,197 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-pay-core/config"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"go.uber.org/zap"
+)
+
+// 消息主题
+const (
+	TopicCallbackSubmit = "callback-submit" // 插件回调提交
+	TopicOrderNotify    = "order-notify"    // 订单通知
+	TopicDayStatistics  = "day-statistics"  // 日统计数据更新
+	TopicAlipayNotify   = "alipay-notify"   // 支付宝回调
+	TopicCacheRefresh   = "cache-refresh"   // 缓存刷新触发
+	TopicBalanceSync    = "balance-sync"    // 后台调额后的余额同步
+	TopicOrderTimeout   = "order-timeout"   // 订单超时（延迟消息）
+)
+
+// 消息总线后端
+const (
+	BusBackendAuto     = "auto"     // 启用 RocketMQ 时使用 RocketMQ，否则使用 Redis
+	BusBackendRocketMQ = "rocketmq" // RocketMQ 5.x
+	BusBackendRedis    = "redis"    // Redis Streams + 有序集合延迟队列
+	BusBackendNone     = "none"     // 不使用消息总线（调用方降级为同步处理）
+)
+
+// Message 总线消息
+type Message struct {
+	ID      string // 消息ID
+	Topic   string // 主题
+	Tag     string // 标签
+	Body    []byte // 消息体（JSON）
+	Attempt int    // 第几次投递（从 1 开始）
+}
+
+// Handler 消息处理函数
+// 返回 nil 表示确认（ack）；返回错误表示拒绝（nack），由后端按各自策略重新投递
+type Handler func(ctx context.Context, msg *Message) error
+
+// Bus 消息总线
+// 所有异步主题（回调提交、支付宝回调、订单超时、缓存刷新等）都通过总线收发，具体后端由配置 bus.backend 决定
+type Bus interface {
+	// Backend 后端名称
+	Backend() string
+	// IsEnabled 是否可用（不可用时调用方应降级为同步处理）
+	IsEnabled() bool
+	// Publish 发送消息，body 会被序列化为 JSON
+	Publish(ctx context.Context, topic, tag string, body interface{}) error
+	// PublishDelayed 发送延迟消息，delay 后才会投递给消费者
+	PublishDelayed(ctx context.Context, topic, tag string, body interface{}, delay time.Duration) error
+	// Subscribe 订阅主题（需在 Start 之前调用，同一主题重复订阅时后者覆盖前者）
+	Subscribe(topic string, handler Handler) error
+	// Start 启动消费
+	Start(ctx context.Context) error
+	// Close 停止消费并释放资源
+	Close() error
+}
+
+var (
+	// globalBus 全局消息总线（单例模式）
+	globalBus Bus
+	// globalBusInit 用于确保全局消息总线只初始化一次
+	globalBusInit sync.Once
+)
+
+// GetBus 获取全局消息总线
+func GetBus() Bus {
+	globalBusInit.Do(func() {
+		globalBus = NewBus()
+	})
+	return globalBus
+}
+
+// NewBus 按配置创建消息总线
+// auto：启用 RocketMQ 且生产者启动成功时使用 RocketMQ，否则使用 Redis（Redis 未初始化时不使用总线）
+func NewBus() Bus {
+	backend := BusBackendAuto
+	if cfg := config.GetConfig(); cfg != nil && cfg.Bus.Backend != "" {
+		backend = cfg.Bus.Backend
+	}
+
+	switch backend {
+	case BusBackendNone:
+		return &disabledBus{}
+	case BusBackendRedis:
+		return newBusOrDisabled(newRedisBus())
+	case BusBackendRocketMQ:
+		return newRocketMQBus(GetGlobalMQClient())
+	default:
+		if client := GetGlobalMQClient(); client.IsEnabled() {
+			return newRocketMQBus(client)
+		}
+		return newBusOrDisabled(newRedisBus())
+	}
+}
+
+// newBusOrDisabled 后端创建失败时返回禁用的总线
+func newBusOrDisabled(bus Bus, err error) Bus {
+	if err != nil {
+		if logger.Logger != nil {
+			logger.Logger.Warn("创建消息总线失败，将使用同步处理", zap.Error(err))
+		}
+		return &disabledBus{}
+	}
+	if logger.Logger != nil {
+		logger.Logger.Info("消息总线已创建", zap.String("backend", bus.Backend()))
+	}
+	return bus
+}
+
+// RegisterDefaultHandlers 订阅所有内置主题
+// 所有处理函数都经过幂等包装：涉及订单的主题按订单ID + 事件类型去重，缓存刷新类主题按消息ID去重
+func RegisterDefaultHandlers(bus Bus) {
+	_ = RegisterHandlers(bus, nil)
+}
+
+// RegisterHandlers 订阅指定的内置主题（topics 为空时订阅全部），主题不存在时返回错误且不订阅任何主题
+func RegisterHandlers(bus Bus, topics []string) error {
+	handlers := defaultHandlers()
+	if len(topics) > 0 {
+		selected := make(map[string]Handler, len(topics))
+		for _, topic := range topics {
+			handler, ok := handlers[topic]
+			if !ok {
+				return fmt.Errorf("未知的消息主题: %s", topic)
+			}
+			selected[topic] = handler
+		}
+		handlers = selected
+	}
+
+	for topic, handler := range handlers {
+		if err := bus.Subscribe(topic, handler); err != nil && logger.Logger != nil {
+			logger.Logger.Warn("订阅主题失败", zap.String("topic", topic), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// IsKnownTopic 是否为内置主题
+func IsKnownTopic(topic string) bool {
+	_, ok := defaultHandlers()[topic]
+	return ok
+}
+
+// defaultHandlers 内置主题的处理函数
+func defaultHandlers() map[string]Handler {
+	return map[string]Handler{
+		TopicCallbackSubmit: Idempotent(callbackSubmitKey, handleCallbackSubmitMessages),
+		TopicOrderNotify:    Idempotent(orderNotifyKey, handleOrderNotifyMessages),
+		TopicDayStatistics:  Idempotent(dayStatisticsKey, handleDayStatisticsMessages),
+		TopicAlipayNotify:   Idempotent(alipayNotifyKey, handleAlipayNotifyMessages),
+		TopicCacheRefresh:   Idempotent(nil, handleCacheRefreshMessages),
+		TopicBalanceSync:    Idempotent(nil, handleBalanceSyncMessages),
+		TopicOrderTimeout:   Idempotent(orderTimeoutKey, handleOrderTimeoutMessages),
+	}
+}
+
+// marshalBody 序列化消息体
+func marshalBody(body interface{}) ([]byte, error) {
+	if raw, ok := body.([]byte); ok {
+		return raw, nil
+	}
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("序列化消息失败: %w", err)
+	}
+	return bodyBytes, nil
+}
+
+// disabledBus 禁用的消息总线（发送均返回错误，调用方降级为同步处理）
+type disabledBus struct{}
+
+func (b *disabledBus) Backend() string { return BusBackendNone }
+func (b *disabledBus) IsEnabled() bool { return false }
+func (b *disabledBus) Publish(ctx context.Context, topic, tag string, body interface{}) error {
+	return fmt.Errorf("消息总线未启用")
+}
+func (b *disabledBus) PublishDelayed(ctx context.Context, topic, tag string, body interface{}, delay time.Duration) error {
+	return fmt.Errorf("消息总线未启用")
+}
+func (b *disabledBus) Subscribe(topic string, handler Handler) error { return nil }
+func (b *disabledBus) Start(ctx context.Context) error               { return nil }
+func (b *disabledBus) Close() error                                  { return nil }
+
+// redisAvailable Redis 是否已初始化
+func redisAvailable() bool {
+	return database.RDB != nil
+}
@@ -0,0 +1,527 @@
+package mq
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-pay-core/config"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+const (
+	// busStreamKeyPrefix 主题 Stream 键前缀（bus:stream:{topic}）
+	busStreamKeyPrefix = "bus:stream:"
+	// busDelayedKeyPrefix 主题延迟消息有序集合键前缀（bus:delayed:{topic}，score 为投递时间毫秒时间戳）
+	busDelayedKeyPrefix = "bus:delayed:"
+	// busLegacyDelayedKey 旧版所有主题共用的延迟消息有序集合（启动时迁移到按主题的集合）
+	busLegacyDelayedKey = "bus:delayed"
+
+	// busReadBlock XREADGROUP 阻塞等待时间
+	busReadBlock = 2 * time.Second
+	// busReadCount 单次读取的最大消息数
+	busReadCount = 16
+	// busDelayPollInterval 延迟消息搬运间隔（小于该值的延迟直接投递）
+	busDelayPollInterval = 500 * time.Millisecond
+	// busDelayBatch 单次搬运的最大延迟消息数
+	busDelayBatch = 100
+)
+
+// 默认 Redis 总线配置
+const (
+	defaultBusGroup             = "pay-core"
+	defaultBusStreamMaxLen      = 100000
+	defaultBusVisibilityTimeout = 5 * time.Minute
+	defaultBusMaxAttempts       = 16
+)
+
+var busMessagesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "bus_messages_total",
+	Help: "消息总线消息处理结果计数",
+}, []string{"topic", "result"})
+
+// moveDueScript 将主题到期的延迟消息搬运到主题 Stream（原子操作，多实例并发安全）
+// KEYS[1] 主题延迟集合，KEYS[2] 主题 Stream；ARGV[1] 当前毫秒时间戳，ARGV[2] 批量大小，ARGV[3] Stream 最大长度
+var moveDueScript = redis.NewScript(`
+local items = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, tonumber(ARGV[2]))
+for _, item in ipairs(items) do
+	local ok, m = pcall(cjson.decode, item)
+	if ok and type(m) == 'table' then
+		redis.call('XADD', KEYS[2], 'MAXLEN', '~', ARGV[3], '*',
+			'tag', m.tag or '', 'body', m.body or '', 'attempt', tostring(m.attempt or 1))
+	end
+	redis.call('ZREM', KEYS[1], item)
+end
+return #items
+`)
+
+// delayedEnvelope 延迟集合中的消息
+type delayedEnvelope struct {
+	ID      string `json:"id"` // 随机ID（保证相同内容的消息不会在有序集合中合并）
+	Topic   string `json:"topic"`
+	Tag     string `json:"tag"`
+	Body    string `json:"body"`
+	Attempt int    `json:"attempt"`
+}
+
+// redisBus Redis Streams 消息总线
+// 每个主题一个 Stream，所有实例共用一个消费组（每条消息只被一个实例消费）；
+// 延迟消息先写入主题的有序集合，到期后由订阅该主题的实例搬运到 Stream；处理失败时按指数退避重新投递，超过主题最大次数后写入死信表和死信主题；
+// 实例崩溃导致未确认的消息超过 visibility_timeout 后由其他实例认领重新处理
+type redisBus struct {
+	rdb        *redis.Client
+	group      string
+	consumer   string
+	maxLen     int64
+	visibility time.Duration
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// newRedisBus 创建 Redis 消息总线
+func newRedisBus() (Bus, error) {
+	if !redisAvailable() {
+		return nil, fmt.Errorf("Redis 未初始化")
+	}
+
+	bus := &redisBus{
+		rdb:        database.RDB,
+		group:      defaultBusGroup,
+		maxLen:     defaultBusStreamMaxLen,
+		visibility: defaultBusVisibilityTimeout,
+		handlers:   make(map[string]Handler),
+	}
+	if cfg := config.GetConfig(); cfg != nil {
+		if cfg.Bus.Group != "" {
+			bus.group = cfg.Bus.Group
+		}
+		if cfg.Bus.StreamMaxLen > 0 {
+			bus.maxLen = cfg.Bus.StreamMaxLen
+		}
+		if cfg.Bus.VisibilityTimeout > 0 {
+			bus.visibility = cfg.Bus.VisibilityTimeout
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	bus.consumer = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	return bus, nil
+}
+
+// Backend 后端名称
+func (b *redisBus) Backend() string {
+	return BusBackendRedis
+}
+
+// IsEnabled Redis 总线创建成功即可用
+func (b *redisBus) IsEnabled() bool {
+	return true
+}
+
+// Publish 发送消息
+func (b *redisBus) Publish(ctx context.Context, topic, tag string, body interface{}) error {
+	bodyBytes, err := marshalBody(body)
+	if err != nil {
+		return err
+	}
+	return b.add(ctx, topic, tag, string(bodyBytes), 1)
+}
+
+// PublishDelayed 发送延迟消息（延迟小于搬运间隔时直接投递）
+func (b *redisBus) PublishDelayed(ctx context.Context, topic, tag string, body interface{}, delay time.Duration) error {
+	bodyBytes, err := marshalBody(body)
+	if err != nil {
+		return err
+	}
+	if delay < busDelayPollInterval {
+		return b.add(ctx, topic, tag, string(bodyBytes), 1)
+	}
+	return b.addDelayed(ctx, topic, tag, string(bodyBytes), 1, delay)
+}
+
+// add 写入主题 Stream
+func (b *redisBus) add(ctx context.Context, topic, tag, body string, attempt int) error {
+	err := b.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: busStreamKeyPrefix + topic,
+		MaxLen: b.maxLen,
+		Approx: true,
+		Values: map[string]interface{}{"tag": tag, "body": body, "attempt": attempt},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("发送消息失败: %w", err)
+	}
+	return nil
+}
+
+// addDelayed 写入延迟集合
+func (b *redisBus) addDelayed(ctx context.Context, topic, tag, body string, attempt int, delay time.Duration) error {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	member, err := json.Marshal(&delayedEnvelope{
+		ID:      hex.EncodeToString(id),
+		Topic:   topic,
+		Tag:     tag,
+		Body:    body,
+		Attempt: attempt,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化延迟消息失败: %w", err)
+	}
+
+	deliverAt := time.Now().Add(delay).UnixMilli()
+	if err := b.rdb.ZAdd(ctx, busDelayedKeyPrefix+topic, &redis.Z{Score: float64(deliverAt), Member: member}).Err(); err != nil {
+		return fmt.Errorf("发送延迟消息失败: %w", err)
+	}
+	return nil
+}
+
+// Subscribe 订阅主题
+func (b *redisBus) Subscribe(topic string, handler Handler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cancel != nil {
+		return fmt.Errorf("消费者已启动，无法订阅主题 %s", topic)
+	}
+	b.handlers[topic] = handler
+	return nil
+}
+
+// Start 创建消费组并启动消费、延迟消息搬运和超时认领
+func (b *redisBus) Start(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cancel != nil {
+		return nil
+	}
+
+	for topic := range b.handlers {
+		err := b.rdb.XGroupCreateMkStream(ctx, busStreamKeyPrefix+topic, b.group, "0").Err()
+		if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf("创建消费组失败(%s): %w", topic, err)
+		}
+	}
+
+	b.migrateLegacyDelayed(ctx)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	b.cancel = cancel
+
+	for topic, handler := range b.handlers {
+		b.wg.Add(3)
+		go b.moveDueLoop(runCtx, topic)
+		go b.consumeLoop(runCtx, topic, handler)
+		go b.reclaimLoop(runCtx, topic, handler)
+	}
+
+	logger.Logger.Info("Redis 消息总线已启动",
+		zap.String("group", b.group),
+		zap.String("consumer", b.consumer),
+		zap.Int("topics", len(b.handlers)))
+	return nil
+}
+
+// Close 停止消费并等待正在处理的消息完成
+func (b *redisBus) Close() error {
+	b.mu.Lock()
+	cancel := b.cancel
+	b.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		logger.Logger.Info("Redis 消息总线已关闭")
+	case <-time.After(5 * time.Second):
+		logger.Logger.Warn("关闭 Redis 消息总线超时（5秒），未确认的消息将由其他实例认领")
+	}
+	return nil
+}
+
+// moveDueLoop 定期搬运主题到期的延迟消息
+func (b *redisBus) moveDueLoop(ctx context.Context, topic string) {
+	defer b.wg.Done()
+	ticker := time.NewTicker(busDelayPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// 一次搬满说明还有积压，继续搬运
+		for ctx.Err() == nil {
+			moved, err := b.moveDue(ctx, topic, time.Now())
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Logger.Warn("搬运延迟消息失败", zap.String("topic", topic), zap.Error(err))
+				}
+				break
+			}
+			if moved < busDelayBatch {
+				break
+			}
+		}
+	}
+}
+
+// moveDue 将主题在 now 之前到期的延迟消息（最多 busDelayBatch 条）搬运到主题 Stream，返回搬运数量
+func (b *redisBus) moveDue(ctx context.Context, topic string, now time.Time) (int, error) {
+	return moveDueScript.Run(ctx, b.rdb, []string{busDelayedKeyPrefix + topic, busStreamKeyPrefix + topic},
+		now.UnixMilli(), busDelayBatch, b.maxLen).Int()
+}
+
+// migrateLegacyDelayed 将旧版共用延迟集合中的消息按主题迁移到各自的延迟集合（保留投递时间）
+// 成员内容不变，多实例同时迁移时 ZADD 不会产生重复
+func (b *redisBus) migrateLegacyDelayed(ctx context.Context) {
+	for {
+		items, err := b.rdb.ZRangeWithScores(ctx, busLegacyDelayedKey, 0, busDelayBatch-1).Result()
+		if err != nil {
+			logger.Logger.Warn("迁移旧版延迟消息失败", zap.Error(err))
+			return
+		}
+		if len(items) == 0 {
+			return
+		}
+
+		pipe := b.rdb.TxPipeline()
+		for _, item := range items {
+			member, _ := item.Member.(string)
+			var envelope delayedEnvelope
+			if json.Unmarshal([]byte(member), &envelope) == nil && envelope.Topic != "" {
+				pipe.ZAdd(ctx, busDelayedKeyPrefix+envelope.Topic, &redis.Z{Score: item.Score, Member: member})
+			}
+			pipe.ZRem(ctx, busLegacyDelayedKey, member)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			logger.Logger.Warn("迁移旧版延迟消息失败", zap.Error(err))
+			return
+		}
+		logger.Logger.Info("已迁移旧版延迟消息", zap.Int("count", len(items)))
+	}
+}
+
+// consumeLoop 读取并处理主题的新消息
+func (b *redisBus) consumeLoop(ctx context.Context, topic string, handler Handler) {
+	defer b.wg.Done()
+	stream := busStreamKeyPrefix + topic
+
+	for ctx.Err() == nil {
+		streams, err := b.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    b.group,
+			Consumer: b.consumer,
+			Streams:  []string{stream, ">"},
+			Count:    busReadCount,
+			Block:    busReadBlock,
+		}).Result()
+		if err != nil {
+			if err == redis.Nil || ctx.Err() != nil {
+				continue
+			}
+			logger.Logger.Warn("读取消息失败", zap.String("topic", topic), zap.Error(err))
+			sleepContext(ctx, time.Second)
+			continue
+		}
+
+		for _, s := range streams {
+			for _, xmsg := range s.Messages {
+				b.process(ctx, topic, handler, xmsg, 0)
+			}
+		}
+	}
+}
+
+// reclaimLoop 认领超过 visibility_timeout 仍未确认的消息（消费实例崩溃或处理卡住）
+func (b *redisBus) reclaimLoop(ctx context.Context, topic string, handler Handler) {
+	defer b.wg.Done()
+	stream := busStreamKeyPrefix + topic
+	interval := b.visibility / 2
+	if interval < 10*time.Second {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pending, err := b.rdb.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: stream,
+			Group:  b.group,
+			Start:  "-",
+			End:    "+",
+			Count:  busDelayBatch,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil && err != redis.Nil {
+				logger.Logger.Warn("查询未确认消息失败", zap.String("topic", topic), zap.Error(err))
+			}
+			continue
+		}
+
+		deliveries := make(map[string]int64)
+		ids := make([]string, 0, len(pending))
+		for _, p := range pending {
+			if p.Idle >= b.visibility {
+				ids = append(ids, p.ID)
+				deliveries[p.ID] = p.RetryCount
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		claimed, err := b.rdb.XClaim(ctx, &redis.XClaimArgs{
+			Stream:   stream,
+			Group:    b.group,
+			Consumer: b.consumer,
+			MinIdle:  b.visibility,
+			Messages: ids,
+		}).Result()
+		if err != nil {
+			logger.Logger.Warn("认领未确认消息失败", zap.String("topic", topic), zap.Error(err))
+			continue
+		}
+
+		for _, xmsg := range claimed {
+			logger.Logger.Warn("重新处理超时未确认的消息",
+				zap.String("topic", topic),
+				zap.String("message_id", xmsg.ID),
+				zap.Int64("deliveries", deliveries[xmsg.ID]))
+			b.process(ctx, topic, handler, xmsg, int(deliveries[xmsg.ID]))
+		}
+	}
+}
+
+// process 处理单条消息：成功则确认；失败则按退避重新投递，超过最大次数后转入死信
+// redelivered 为该条目此前已被投递的次数（认领时计入，避免导致进程崩溃的消息无限重试）
+func (b *redisBus) process(ctx context.Context, topic string, handler Handler, xmsg redis.XMessage, redelivered int) {
+	// 关闭总线时让正在处理的消息完成，不中断数据库操作
+	ctx = context.WithoutCancel(ctx)
+
+	msg := &Message{
+		ID:      xmsg.ID,
+		Topic:   topic,
+		Tag:     fieldString(xmsg.Values, "tag"),
+		Body:    []byte(fieldString(xmsg.Values, "body")),
+		Attempt: 1,
+	}
+	if attempt, err := strconv.Atoi(fieldString(xmsg.Values, "attempt")); err == nil && attempt > 0 {
+		msg.Attempt = attempt
+	}
+	msg.Attempt += redelivered
+
+	err := runHandler(ctx, handler, msg)
+	if err == nil {
+		busMessagesTotal.WithLabelValues(topic, "success").Inc()
+		b.ack(ctx, topic, xmsg.ID)
+		return
+	}
+
+	if msg.Attempt >= maxAttemptsFor(topic) {
+		event, dlqErr := recordDeadLetter(ctx, BusBackendRedis, msg, err)
+		if dlqErr != nil {
+			// 死信写入失败时保留未确认状态，由超时认领兜底
+			logger.Logger.Error("消息转入死信失败",
+				zap.String("topic", topic),
+				zap.String("message_id", msg.ID),
+				zap.String("body", string(msg.Body)),
+				zap.Error(dlqErr))
+			return
+		}
+		busMessagesTotal.WithLabelValues(topic, "dead_letter").Inc()
+		if err := b.add(ctx, deadLetterTopic(), topic, string(event), 1); err != nil {
+			logger.Logger.Warn("发送死信消息失败", zap.String("topic", topic), zap.Error(err))
+		}
+		b.ack(ctx, topic, xmsg.ID)
+		return
+	}
+
+	busMessagesTotal.WithLabelValues(topic, "retry").Inc()
+	backoff := busBackoff(msg.Attempt)
+	logger.Logger.Warn("消息处理失败，稍后重新投递",
+		zap.String("topic", topic),
+		zap.String("message_id", msg.ID),
+		zap.Int("attempt", msg.Attempt),
+		zap.Duration("backoff", backoff),
+		zap.Error(err))
+	if err := b.addDelayed(ctx, topic, msg.Tag, string(msg.Body), msg.Attempt+1, backoff); err != nil {
+		// 重新投递失败时保留未确认状态，由超时认领兜底
+		logger.Logger.Warn("重新投递消息失败", zap.String("topic", topic), zap.Error(err))
+		return
+	}
+	b.ack(ctx, topic, xmsg.ID)
+}
+
+// ack 确认并删除消息（Stream 长度即积压数量）
+func (b *redisBus) ack(ctx context.Context, topic, id string) {
+	stream := busStreamKeyPrefix + topic
+	pipe := b.rdb.TxPipeline()
+	pipe.XAck(ctx, stream, b.group, id)
+	pipe.XDel(ctx, stream, id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.Logger.Warn("确认消息失败",
+			zap.String("topic", topic),
+			zap.String("message_id", id),
+			zap.Error(err))
+	}
+}
+
+// runHandler 执行处理函数（panic 视为处理失败）
+func runHandler(ctx context.Context, handler Handler, msg *Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("处理消息时发生 panic: %v", r)
+		}
+	}()
+	return handler(ctx, msg)
+}
+
+// fieldString 读取 Stream 条目字段
+func fieldString(values map[string]interface{}, key string) string {
+	switch v := values[key].(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// sleepContext 可被取消的等待
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
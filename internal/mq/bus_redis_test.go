@@ -0,0 +1,193 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupTestRedisBus 创建使用 miniredis 的 Redis 总线，并为 topics 创建消费组
+func setupTestRedisBus(t *testing.T, topics ...string) (*redisBus, *miniredis.Miniredis) {
+	mr, _ := setupTestRedis(t)
+	bus, err := newRedisBus()
+	if err != nil {
+		t.Fatalf("newRedisBus: %v", err)
+	}
+	b := bus.(*redisBus)
+	for _, topic := range topics {
+		if err := b.rdb.XGroupCreateMkStream(context.Background(), busStreamKeyPrefix+topic, b.group, "0").Err(); err != nil {
+			t.Fatalf("XGroupCreateMkStream: %v", err)
+		}
+	}
+	return b, mr
+}
+
+// readOne 以消费组读取主题的一条新消息
+func readOne(t *testing.T, b *redisBus, topic string) redis.XMessage {
+	t.Helper()
+	streams, err := b.rdb.XReadGroup(context.Background(), &redis.XReadGroupArgs{
+		Group:    b.group,
+		Consumer: b.consumer,
+		Streams:  []string{busStreamKeyPrefix + topic, ">"},
+		Count:    1,
+		Block:    -1,
+	}).Result()
+	if err != nil || len(streams) == 0 || len(streams[0].Messages) == 0 {
+		t.Fatalf("XReadGroup(%s) = %v, %v", topic, streams, err)
+	}
+	return streams[0].Messages[0]
+}
+
+func TestRedisBusPublishDelayed(t *testing.T) {
+	b, _ := setupTestRedisBus(t, TopicOrderTimeout, TopicOrderNotify)
+	ctx := context.Background()
+
+	// 小于搬运间隔的延迟直接投递
+	assert.NoError(t, b.PublishDelayed(ctx, TopicOrderNotify, "n", map[string]string{"order_id": "O1"}, 0))
+	assert.Equal(t, int64(1), b.rdb.XLen(ctx, busStreamKeyPrefix+TopicOrderNotify).Val())
+
+	// 延迟消息写入主题自己的延迟集合
+	assert.NoError(t, b.PublishDelayed(ctx, TopicOrderTimeout, "t", map[string]string{"order_id": "O1"}, 10*time.Second))
+	assert.NoError(t, b.PublishDelayed(ctx, TopicOrderTimeout, "t", map[string]string{"order_id": "O1"}, 20*time.Second))
+	assert.Equal(t, int64(2), b.rdb.ZCard(ctx, busDelayedKeyPrefix+TopicOrderTimeout).Val())
+	assert.Equal(t, int64(0), b.rdb.ZCard(ctx, busDelayedKeyPrefix+TopicOrderNotify).Val())
+
+	// 未到期不搬运
+	moved, err := b.moveDue(ctx, TopicOrderTimeout, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, moved)
+
+	// 到期的消息搬运到主题 Stream，未到期的保留
+	moved, err = b.moveDue(ctx, TopicOrderTimeout, time.Now().Add(15*time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, moved)
+	assert.Equal(t, int64(1), b.rdb.ZCard(ctx, busDelayedKeyPrefix+TopicOrderTimeout).Val())
+	xmsg := readOne(t, b, TopicOrderTimeout)
+	assert.Equal(t, "t", xmsg.Values["tag"])
+	assert.Equal(t, `{"order_id":"O1"}`, xmsg.Values["body"])
+	assert.Equal(t, "1", xmsg.Values["attempt"])
+
+	// 其他主题的搬运不影响该主题
+	moved, err = b.moveDue(ctx, TopicOrderNotify, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, moved)
+	assert.Equal(t, int64(1), b.rdb.ZCard(ctx, busDelayedKeyPrefix+TopicOrderTimeout).Val())
+
+	// 无法解析的成员直接移除
+	b.rdb.ZAdd(ctx, busDelayedKeyPrefix+TopicOrderTimeout, &redis.Z{Score: 0, Member: "not json"})
+	moved, err = b.moveDue(ctx, TopicOrderTimeout, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, moved)
+	assert.Equal(t, int64(1), b.rdb.ZCard(ctx, busDelayedKeyPrefix+TopicOrderTimeout).Val())
+}
+
+func TestRedisBusMigrateLegacyDelayed(t *testing.T) {
+	b, _ := setupTestRedisBus(t)
+	ctx := context.Background()
+
+	member, _ := json.Marshal(&delayedEnvelope{ID: "1", Topic: TopicOrderTimeout, Body: "{}", Attempt: 1})
+	b.rdb.ZAdd(ctx, busLegacyDelayedKey,
+		&redis.Z{Score: 12345, Member: string(member)},
+		&redis.Z{Score: 1, Member: "not json"})
+
+	b.migrateLegacyDelayed(ctx)
+	assert.Equal(t, int64(0), b.rdb.Exists(ctx, busLegacyDelayedKey).Val())
+	items := b.rdb.ZRangeWithScores(ctx, busDelayedKeyPrefix+TopicOrderTimeout, 0, -1).Val()
+	if assert.Len(t, items, 1) {
+		assert.Equal(t, string(member), items[0].Member)
+		assert.Equal(t, float64(12345), items[0].Score)
+	}
+}
+
+func TestRedisBusProcess(t *testing.T) {
+	db := setupTestDB(t)
+	setupTestConfig(t) // 默认最大投递 3 次
+	b, _ := setupTestRedisBus(t, TopicOrderNotify, "test-dead-letter")
+	ctx := context.Background()
+	stream := busStreamKeyPrefix + TopicOrderNotify
+
+	calls := 0
+	failing := func(ctx context.Context, msg *Message) error {
+		calls++
+		return errors.New("notify failed")
+	}
+
+	// 处理失败：确认原消息，按退避写入延迟集合，投递次数加 1
+	assert.NoError(t, b.Publish(ctx, TopicOrderNotify, "n", map[string]string{"order_id": "O1"}))
+	b.process(ctx, TopicOrderNotify, failing, readOne(t, b, TopicOrderNotify), 0)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, int64(0), b.rdb.XLen(ctx, stream).Val())
+	assert.Equal(t, int64(0), b.rdb.XPending(ctx, stream, b.group).Val().Count)
+	items := b.rdb.ZRangeWithScores(ctx, busDelayedKeyPrefix+TopicOrderNotify, 0, -1).Val()
+	if assert.Len(t, items, 1) {
+		var envelope delayedEnvelope
+		assert.NoError(t, json.Unmarshal([]byte(items[0].Member.(string)), &envelope))
+		assert.Equal(t, 2, envelope.Attempt)
+		assert.Equal(t, "n", envelope.Tag)
+		// 第 1 次失败后退避 1 秒
+		delay := time.Until(time.UnixMilli(int64(items[0].Score)))
+		assert.InDelta(t, float64(time.Second), float64(delay), float64(500*time.Millisecond))
+	}
+
+	// 超时认领的消息计入此前的投递次数：达到上限后写入死信表和死信主题
+	moved, err := b.moveDue(ctx, TopicOrderNotify, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, moved)
+	b.process(ctx, TopicOrderNotify, failing, readOne(t, b, TopicOrderNotify), 1)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, int64(0), b.rdb.XLen(ctx, stream).Val())
+	assert.Equal(t, int64(0), b.rdb.ZCard(ctx, busDelayedKeyPrefix+TopicOrderNotify).Val())
+
+	dlq := readOne(t, b, "test-dead-letter")
+	assert.Equal(t, TopicOrderNotify, dlq.Values["tag"])
+	var event DeadLetterEvent
+	assert.NoError(t, json.Unmarshal([]byte(dlq.Values["body"].(string)), &event))
+	assert.Equal(t, 3, event.Attempts)
+	assert.Equal(t, "notify failed", event.Error)
+	var record models.MQDeadLetter
+	assert.NoError(t, db.First(&record, event.ID).Error)
+	assert.Equal(t, BusBackendRedis, record.Backend)
+	assert.Equal(t, 3, record.Attempts)
+
+	// 处理成功：确认并删除
+	assert.NoError(t, b.Publish(ctx, TopicOrderNotify, "n", map[string]string{"order_id": "O2"}))
+	b.process(ctx, TopicOrderNotify, func(ctx context.Context, msg *Message) error {
+		assert.Equal(t, 1, msg.Attempt)
+		assert.Equal(t, `{"order_id":"O2"}`, string(msg.Body))
+		return nil
+	}, readOne(t, b, TopicOrderNotify), 0)
+	assert.Equal(t, int64(0), b.rdb.XLen(ctx, stream).Val())
+	assert.Equal(t, int64(0), b.rdb.XPending(ctx, stream, b.group).Val().Count)
+}
+
+func TestRedisBusStart(t *testing.T) {
+	b, _ := setupTestRedisBus(t)
+	ctx := context.Background()
+
+	received := make(chan *Message, 1)
+	assert.NoError(t, b.Subscribe(TopicOrderNotify, func(ctx context.Context, msg *Message) error {
+		received <- msg
+		return nil
+	}))
+	assert.NoError(t, b.Start(ctx))
+	t.Cleanup(func() { _ = b.Close() })
+	assert.Error(t, b.Subscribe(TopicDayStatistics, func(ctx context.Context, msg *Message) error { return nil }))
+
+	// 延迟消息到期后由搬运循环投递给消费者
+	assert.NoError(t, b.PublishDelayed(ctx, TopicOrderNotify, "n", map[string]string{"order_id": "O1"}, busDelayPollInterval))
+	select {
+	case msg := <-received:
+		assert.Equal(t, TopicOrderNotify, msg.Topic)
+		assert.Equal(t, "n", msg.Tag)
+		assert.Equal(t, `{"order_id":"O1"}`, string(msg.Body))
+	case <-time.After(5 * time.Second):
+		t.Fatal("delayed message not delivered")
+	}
+}
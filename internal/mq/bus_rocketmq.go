@@ -0,0 +1,102 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-pay-core/internal/logger"
+	"go.uber.org/zap"
+)
+
+// rocketMQBus RocketMQ 消息总线（生产者复用全局 RocketMQClient，Start 时按订阅创建消费者）
+type rocketMQBus struct {
+	client   *RocketMQClient
+	mu       sync.Mutex
+	handlers map[string]Handler
+	consumer *RocketMQConsumer
+}
+
+// newRocketMQBus 创建 RocketMQ 消息总线
+func newRocketMQBus(client *RocketMQClient) *rocketMQBus {
+	return &rocketMQBus{
+		client:   client,
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Backend 后端名称
+func (b *rocketMQBus) Backend() string {
+	return BusBackendRocketMQ
+}
+
+// IsEnabled 生产者是否可用
+func (b *rocketMQBus) IsEnabled() bool {
+	return b.client.IsEnabled()
+}
+
+// Publish 发送消息
+func (b *rocketMQBus) Publish(ctx context.Context, topic, tag string, body interface{}) error {
+	bodyBytes, err := marshalBody(body)
+	if err != nil {
+		return err
+	}
+	return b.client.sendRaw(ctx, topic, tag, bodyBytes, 0)
+}
+
+// PublishDelayed 发送延迟消息
+func (b *rocketMQBus) PublishDelayed(ctx context.Context, topic, tag string, body interface{}, delay time.Duration) error {
+	bodyBytes, err := marshalBody(body)
+	if err != nil {
+		return err
+	}
+	if delay <= 0 {
+		delay = time.Millisecond
+	}
+	return b.client.sendRaw(ctx, topic, tag, bodyBytes, delay)
+}
+
+// Subscribe 订阅主题
+func (b *rocketMQBus) Subscribe(topic string, handler Handler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.consumer != nil {
+		return fmt.Errorf("消费者已启动，无法订阅主题 %s", topic)
+	}
+	b.handlers[topic] = handler
+	return nil
+}
+
+// Start 启动消费者
+func (b *rocketMQBus) Start(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.consumer != nil || len(b.handlers) == 0 {
+		return nil
+	}
+
+	consumer, err := newRocketMQConsumer(b.handlers, b.client)
+	if err != nil {
+		return err
+	}
+	b.consumer = consumer
+	if consumer.IsEnabled() {
+		logger.Logger.Info("RocketMQ 消费者已启动", zap.Int("topics", len(b.handlers)))
+	}
+	return nil
+}
+
+// Close 关闭消费者和生产者
+func (b *rocketMQBus) Close() error {
+	b.mu.Lock()
+	consumer := b.consumer
+	b.mu.Unlock()
+
+	if consumer != nil {
+		if err := consumer.Close(); err != nil {
+			logger.Logger.Error("关闭 RocketMQ 消费者失败", zap.Error(err))
+		}
+	}
+	return b.client.Close()
+}
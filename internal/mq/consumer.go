@@ -29,8 +29,10 @@ type RocketMQConsumer struct {
 	enabled  bool
 }
 
-// NewRocketMQConsumer 创建 RocketMQ 消费者
-func NewRocketMQConsumer() (*RocketMQConsumer, error) {
+// newRocketMQConsumer 创建 RocketMQ 消费者，订阅 handlers 中的主题（由 rocketMQBus.Start 调用）
+// 处理失败的消息返回 FAILURE，由 Broker 按消费组重试策略延长不可见时间后重新投递（不向原主题重发延迟消息，普通主题不接受延迟消息）；
+// 超过主题最大投递次数后写入死信表，并通过 client 发送到死信主题
+func newRocketMQConsumer(handlers map[string]Handler, client *RocketMQClient) (*RocketMQConsumer, error) {
 	cfg := config.GetConfig()
 
 	// 检查是否启用 RocketMQ
@@ -59,42 +61,16 @@ func NewRocketMQConsumer() (*RocketMQConsumer, error) {
 	// 创建消息监听器（使用 FuncMessageListener）
 	listener := &rocketmq.FuncMessageListener{
 		Consume: func(message *rocketmq.MessageView) rocketmq.ConsumerResult {
-			ctx := context.Background()
-			topic := message.GetTopic()
-
-			var err error
-			switch topic {
-			case "callback-submit":
-				err = handleCallbackSubmitMessages(ctx, message)
-			case "order-notify":
-				err = handleOrderNotifyMessages(ctx, message)
-			case "day-statistics":
-				err = handleDayStatisticsMessages(ctx, message)
-			case "alipay-notify":
-				err = handleAlipayNotifyMessages(ctx, message)
-			case "cache-refresh":
-				err = handleCacheRefreshMessages(ctx, message)
-			case "balance-sync":
-				err = handleBalanceSyncMessages(ctx, message)
-			case "order-timeout":
-				err = handleOrderTimeoutMessages(ctx, message)
-			default:
-				logger.Logger.Warn("未知的主题",
-					zap.String("topic", topic),
-					zap.String("message_id", message.GetMessageId()))
-				return rocketmq.SUCCESS
+			msg := &Message{
+				ID:      message.GetMessageId(),
+				Topic:   message.GetTopic(),
+				Body:    message.GetBody(),
+				Attempt: messageAttempt(message),
 			}
-
-			if err != nil {
-				logger.Logger.Error("处理消息失败",
-					zap.String("topic", topic),
-					zap.String("message_id", message.GetMessageId()),
-					zap.Error(err))
-				// 返回失败，消息会被重试
-				return rocketmq.SUCCESS // 暂时返回成功，避免无限重试
+			if tag := message.GetTag(); tag != nil {
+				msg.Tag = *tag
 			}
-
-			return rocketmq.SUCCESS
+			return consumeMessage(context.Background(), handlers, client, msg)
 		},
 	}
 
@@ -107,16 +83,12 @@ func NewRocketMQConsumer() (*RocketMQConsumer, error) {
 				err = fmt.Errorf("创建 RocketMQ 消费者时发生 panic: %v", r)
 			}
 		}()
+		subscriptions := make(map[string]*rocketmq.FilterExpression, len(handlers))
+		for topic := range handlers {
+			subscriptions[topic] = rocketmq.SUB_ALL
+		}
 		consumer, err = rocketmq.NewPushConsumer(consumerConfig,
-			rocketmq.WithPushSubscriptionExpressions(map[string]*rocketmq.FilterExpression{
-				"callback-submit": rocketmq.SUB_ALL,
-				"order-notify":    rocketmq.SUB_ALL,
-				"day-statistics":  rocketmq.SUB_ALL,
-				"alipay-notify":   rocketmq.SUB_ALL,
-				"cache-refresh":   rocketmq.SUB_ALL,
-				"balance-sync":    rocketmq.SUB_ALL,
-				"order-timeout":   rocketmq.SUB_ALL,
-			}),
+			rocketmq.WithPushSubscriptionExpressions(subscriptions),
 			rocketmq.WithPushMessageListener(listener),
 		)
 	}()
@@ -223,9 +195,17 @@ func NewRocketMQConsumer() (*RocketMQConsumer, error) {
 		}
 	}
 
+	// 失败重试由 Broker 按消费组重试策略完成，消费组最大重试次数不足时消息会先进入 Broker 的 %DLQ% 主题
+	maxAttempts := 0
+	for topic := range handlers {
+		if n := maxAttemptsFor(topic); n > maxAttempts {
+			maxAttempts = n
+		}
+	}
 	logger.Logger.Info("RocketMQ 消费者启动成功",
 		zap.String("endpoint", endpoint),
-		zap.String("consumer_group", cfg.RocketMQ.ConsumerGroup))
+		zap.String("consumer_group", cfg.RocketMQ.ConsumerGroup),
+		zap.Int("required_max_retry_times", maxAttempts-1))
 
 	return &RocketMQConsumer{
 		consumer: consumer,
@@ -234,14 +214,14 @@ func NewRocketMQConsumer() (*RocketMQConsumer, error) {
 }
 
 // handleCallbackSubmitMessages 处理 callback_submit 消息
-func handleCallbackSubmitMessages(ctx context.Context, msg *rocketmq.MessageView) error {
+func handleCallbackSubmitMessages(ctx context.Context, msg *Message) error {
 	// 创建插件管理器（避免循环依赖，直接创建）
 	pluginMgr := plugin.NewManager(database.RDB)
 
 	var callbackMsg CallbackSubmitMessage
-	if err := json.Unmarshal(msg.GetBody(), &callbackMsg); err != nil {
+	if err := json.Unmarshal(msg.Body, &callbackMsg); err != nil {
 		logger.Logger.Error("解析 callback_submit 消息失败",
-			zap.String("message_id", msg.GetMessageId()),
+			zap.String("message_id", msg.ID),
 			zap.Error(err))
 		return err
 	}
@@ -302,12 +282,26 @@ func handleCallbackSubmitMessages(ctx context.Context, msg *rocketmq.MessageView
 	return nil
 }
 
+// AlipayNotifyProcessor 支付宝回调处理器（避免循环依赖，由上层实现并注入）
+// 注入后消费者验签通过即交给处理器完成订单更新、成功钩子和商户通知，与同步处理逻辑一致
+type AlipayNotifyProcessor interface {
+	ProcessAlipayNotify(ctx context.Context, msg *AlipayNotifyMessage) error
+}
+
+// alipayNotifyProcessor 全局支付宝回调处理器（未设置时使用消费者内置的简化处理）
+var alipayNotifyProcessor AlipayNotifyProcessor
+
+// SetAlipayNotifyProcessor 设置全局支付宝回调处理器
+func SetAlipayNotifyProcessor(processor AlipayNotifyProcessor) {
+	alipayNotifyProcessor = processor
+}
+
 // handleAlipayNotifyMessages 处理支付宝回调消息
-func handleAlipayNotifyMessages(ctx context.Context, msg *rocketmq.MessageView) error {
+func handleAlipayNotifyMessages(ctx context.Context, msg *Message) error {
 	var notifyMsg AlipayNotifyMessage
-	if err := json.Unmarshal(msg.GetBody(), &notifyMsg); err != nil {
+	if err := json.Unmarshal(msg.Body, &notifyMsg); err != nil {
 		logger.Logger.Error("解析 alipay_notify 消息失败",
-			zap.String("message_id", msg.GetMessageId()),
+			zap.String("message_id", msg.ID),
 			zap.Error(err))
 		return err
 	}
@@ -338,6 +332,10 @@ func handleAlipayNotifyMessages(ctx context.Context, msg *rocketmq.MessageView)
 		return fmt.Errorf("签名验证失败")
 	}
 
+	if alipayNotifyProcessor != nil {
+		return alipayNotifyProcessor.ProcessAlipayNotify(ctx, &notifyMsg)
+	}
+
 	// 查询订单（通过 out_trade_no，即商户订单号）
 	var order models.Order
 	if err := database.DB.Where("out_order_no = ?", notifyMsg.NotifyData.OutTradeNo).First(&order).Error; err != nil {
@@ -494,13 +492,12 @@ func (o *simpleOrderContext) SetDomainID(id int64)    {}
 func (o *simpleOrderContext) SetDomainURL(url string) {}
 
 // handleOrderNotifyMessages 处理订单通知消息
-// 注意：这里只记录日志，实际通知逻辑应该在 service 层处理
-// 避免循环依赖，这里只做消息解析和日志记录
-func handleOrderNotifyMessages(ctx context.Context, msg *rocketmq.MessageView) error {
+// 实际通知逻辑在 service 层，通过注入的 OrderNotifyProcessor 处理（避免循环依赖）
+func handleOrderNotifyMessages(ctx context.Context, msg *Message) error {
 	var notifyMsg OrderNotifyMessage
-	if err := json.Unmarshal(msg.GetBody(), &notifyMsg); err != nil {
+	if err := json.Unmarshal(msg.Body, &notifyMsg); err != nil {
 		logger.Logger.Error("解析 order_notify 消息失败",
-			zap.String("message_id", msg.GetMessageId()),
+			zap.String("message_id", msg.ID),
 			zap.Error(err))
 		return err
 	}
@@ -510,41 +507,42 @@ func handleOrderNotifyMessages(ctx context.Context, msg *rocketmq.MessageView) e
 		zap.String("order_no", notifyMsg.OrderNo),
 		zap.Int("status", notifyMsg.Status))
 
-	// TODO: 实际的通知处理逻辑应该在 service 层实现
-	// 这里可以通过消息队列的延迟重试机制来处理失败的通知
-
+	if orderNotifyProcessor != nil {
+		return orderNotifyProcessor.ProcessOrderNotify(ctx, &notifyMsg)
+	}
 	return nil
 }
 
 // handleDayStatisticsMessages 处理日统计数据更新消息
-func handleDayStatisticsMessages(ctx context.Context, msg *rocketmq.MessageView) error {
+func handleDayStatisticsMessages(ctx context.Context, msg *Message) error {
 	var statsMsg DayStatisticsMessage
-	if err := json.Unmarshal(msg.GetBody(), &statsMsg); err != nil {
+	if err := json.Unmarshal(msg.Body, &statsMsg); err != nil {
 		logger.Logger.Error("解析 day_statistics 消息失败",
-			zap.String("message_id", msg.GetMessageId()),
+			zap.String("message_id", msg.ID),
 			zap.Error(err))
 		return err
 	}
 
-	// 调用日统计服务更新数据
-	// 这里需要根据实际的统计服务实现
 	logger.Logger.Info("处理日统计数据更新",
-		zap.String("product_id", statsMsg.ProductID),
+		zap.String("order_id", statsMsg.OrderID),
+		zap.Int("status", statsMsg.Status),
 		zap.String("statistics_type", statsMsg.StatisticsType),
 		zap.String("date", statsMsg.Date))
 
-	// TODO: 实现日统计数据更新逻辑
-	// 可以调用 plugin/alipay/statistics.go 中的服务
-
-	return nil
+	if dayStatisticsProcessor == nil {
+		logger.Logger.Warn("未设置日统计事件处理器，跳过统计",
+			zap.String("order_id", statsMsg.OrderID))
+		return nil
+	}
+	return dayStatisticsProcessor.ProcessDayStatistics(ctx, &statsMsg)
 }
 
 // handleCacheRefreshMessages 处理缓存刷新触发消息
-func handleCacheRefreshMessages(ctx context.Context, msg *rocketmq.MessageView) error {
+func handleCacheRefreshMessages(ctx context.Context, msg *Message) error {
 	var refreshMsg CacheRefreshMessage
-	if err := json.Unmarshal(msg.GetBody(), &refreshMsg); err != nil {
+	if err := json.Unmarshal(msg.Body, &refreshMsg); err != nil {
 		logger.Logger.Error("解析 cache_refresh 消息失败",
-			zap.String("message_id", msg.GetMessageId()),
+			zap.String("message_id", msg.ID),
 			zap.Error(err))
 		return err
 	}
@@ -553,7 +551,7 @@ func handleCacheRefreshMessages(ctx context.Context, msg *rocketmq.MessageView)
 	refreshCacheDirectly(ctx, refreshMsg.Full, refreshMsg.Targets, refreshMsg.TenantIDs, refreshMsg.WriteoffIDs)
 
 	logger.Logger.Info("已处理缓存刷新消息",
-		zap.String("message_id", msg.GetMessageId()),
+		zap.String("message_id", msg.ID),
 		zap.Bool("full", refreshMsg.Full),
 		zap.Strings("targets", refreshMsg.Targets),
 		zap.Any("tenant_ids", refreshMsg.TenantIDs),
@@ -563,11 +561,11 @@ func handleCacheRefreshMessages(ctx context.Context, msg *rocketmq.MessageView)
 }
 
 // handleBalanceSyncMessages 处理后台调额后的余额同步消息
-func handleBalanceSyncMessages(ctx context.Context, msg *rocketmq.MessageView) error {
+func handleBalanceSyncMessages(ctx context.Context, msg *Message) error {
 	var syncMsg BalanceSyncMessage
-	if err := json.Unmarshal(msg.GetBody(), &syncMsg); err != nil {
+	if err := json.Unmarshal(msg.Body, &syncMsg); err != nil {
 		logger.Logger.Error("解析 balance_sync 消息失败",
-			zap.String("message_id", msg.GetMessageId()),
+			zap.String("message_id", msg.ID),
 			zap.Error(err))
 		return err
 	}
@@ -577,7 +575,7 @@ func handleBalanceSyncMessages(ctx context.Context, msg *rocketmq.MessageView) e
 	refreshCacheDirectly(ctx, syncMsg.Full, targets, syncMsg.TenantIDs, syncMsg.WriteoffIDs)
 
 	logger.Logger.Info("已处理余额同步消息",
-		zap.String("message_id", msg.GetMessageId()),
+		zap.String("message_id", msg.ID),
 		zap.Any("tenant_ids", syncMsg.TenantIDs),
 		zap.Any("writeoff_ids", syncMsg.WriteoffIDs),
 		zap.Bool("full", syncMsg.Full))
@@ -586,11 +584,11 @@ func handleBalanceSyncMessages(ctx context.Context, msg *rocketmq.MessageView) e
 }
 
 // handleOrderTimeoutMessages 处理订单超时消息
-func handleOrderTimeoutMessages(ctx context.Context, msg *rocketmq.MessageView) error {
+func handleOrderTimeoutMessages(ctx context.Context, msg *Message) error {
 	var timeoutMsg OrderTimeoutMessage
-	if err := json.Unmarshal(msg.GetBody(), &timeoutMsg); err != nil {
+	if err := json.Unmarshal(msg.Body, &timeoutMsg); err != nil {
 		logger.Logger.Error("解析 order_timeout 消息失败",
-			zap.String("message_id", msg.GetMessageId()),
+			zap.String("message_id", msg.ID),
 			zap.Error(err))
 		return err
 	}
@@ -663,6 +661,62 @@ func handleOrderTimeoutMessages(ctx context.Context, msg *rocketmq.MessageView)
 	return nil
 }
 
+// consumeMessage 将消息分发给主题的处理函数，处理失败时交给 handleConsumeFailure
+func consumeMessage(ctx context.Context, handlers map[string]Handler, client *RocketMQClient, msg *Message) rocketmq.ConsumerResult {
+	handler, ok := handlers[msg.Topic]
+	if !ok {
+		logger.Logger.Warn("未知的主题",
+			zap.String("topic", msg.Topic),
+			zap.String("message_id", msg.ID))
+		return rocketmq.SUCCESS
+	}
+
+	if err := runHandler(ctx, handler, msg); err != nil {
+		return handleConsumeFailure(ctx, client, msg, err)
+	}
+
+	busMessagesTotal.WithLabelValues(msg.Topic, "success").Inc()
+	return rocketmq.SUCCESS
+}
+
+// messageAttempt 计算消息是第几次投递（Broker 每次重新投递时递增）
+func messageAttempt(message *rocketmq.MessageView) int {
+	if attempt := int(message.GetDeliveryAttempt()); attempt > 1 {
+		return attempt
+	}
+	return 1
+}
+
+// handleConsumeFailure 处理失败的消息：未达到主题最大投递次数时返回 FAILURE，
+// 由 Broker 按消费组重试策略（指数退避）延长不可见时间后重新投递，同一条消息的投递次数递增；
+// 达到上限后写入死信表并发送到死信主题，写入死信失败时同样返回 FAILURE 交由 Broker 重试，保证消息不丢失
+// 消费组的最大重试次数需不小于主题最大投递次数，否则 Broker 会先将消息转入自身的 %DLQ% 主题
+func handleConsumeFailure(ctx context.Context, client *RocketMQClient, msg *Message, handleErr error) rocketmq.ConsumerResult {
+	if msg.Attempt >= maxAttemptsFor(msg.Topic) {
+		event, err := recordDeadLetter(ctx, BusBackendRocketMQ, msg, handleErr)
+		if err != nil {
+			logger.Logger.Error("消息转入死信失败，交由 Broker 重试",
+				zap.String("topic", msg.Topic),
+				zap.String("message_id", msg.ID),
+				zap.Error(err))
+			return rocketmq.FAILURE
+		}
+		busMessagesTotal.WithLabelValues(msg.Topic, "dead_letter").Inc()
+		if err := client.sendRaw(ctx, deadLetterTopic(), msg.Topic, event, 0); err != nil {
+			logger.Logger.Warn("发送死信消息失败", zap.String("topic", msg.Topic), zap.Error(err))
+		}
+		return rocketmq.SUCCESS
+	}
+
+	logger.Logger.Warn("消息处理失败，由 Broker 稍后重新投递",
+		zap.String("topic", msg.Topic),
+		zap.String("message_id", msg.ID),
+		zap.Int("attempt", msg.Attempt),
+		zap.Error(handleErr))
+	busMessagesTotal.WithLabelValues(msg.Topic, "retry").Inc()
+	return rocketmq.FAILURE
+}
+
 // Close 关闭消费者（优化关闭逻辑，避免长时间阻塞）
 func (c *RocketMQConsumer) Close() error {
 	if !c.enabled {
@@ -0,0 +1,124 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang-pay-core/config"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultDeadLetterTopic 默认死信主题
+	defaultDeadLetterTopic = "dead-letter"
+	// busMaxBackoff 处理失败重新投递的最大间隔
+	busMaxBackoff = 5 * time.Minute
+	// deadLetterErrorMaxLen 死信记录中错误信息的最大长度
+	deadLetterErrorMaxLen = 4000
+)
+
+// DeadLetterEvent 发送到死信主题的消息（tag 为原主题）
+type DeadLetterEvent struct {
+	ID        int64  `json:"id"`         // 死信记录ID
+	Topic     string `json:"topic"`      // 原主题
+	Tag       string `json:"tag"`        // 原标签
+	MessageID string `json:"message_id"` // 原消息ID
+	Body      string `json:"body"`       // 原消息体
+	Error     string `json:"error"`      // 最后一次处理错误
+	Attempts  int    `json:"attempts"`   // 投递次数
+}
+
+// maxAttemptsFor 主题的最大投递次数（bus.topic_max_attempts 优先，其次 bus.max_attempts）
+func maxAttemptsFor(topic string) int {
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return defaultBusMaxAttempts
+	}
+	if n, ok := cfg.Bus.TopicMaxAttempts[topic]; ok && n > 0 {
+		return n
+	}
+	if cfg.Bus.MaxAttempts > 0 {
+		return cfg.Bus.MaxAttempts
+	}
+	return defaultBusMaxAttempts
+}
+
+// deadLetterTopic 死信主题名
+func deadLetterTopic() string {
+	if cfg := config.GetConfig(); cfg != nil && cfg.Bus.DeadLetterTopic != "" {
+		return cfg.Bus.DeadLetterTopic
+	}
+	return defaultDeadLetterTopic
+}
+
+// busBackoff 第 attempt 次失败后的重新投递间隔（1s、2s、4s……最大 5 分钟，Redis 后端使用）
+func busBackoff(attempt int) time.Duration {
+	if attempt > 9 {
+		return busMaxBackoff
+	}
+	backoff := time.Second << uint(attempt-1)
+	if backoff > busMaxBackoff {
+		return busMaxBackoff
+	}
+	return backoff
+}
+
+// recordDeadLetter 将处理失败次数达到上限的消息写入死信表，返回发送到死信主题的消息体
+// 写入失败时返回错误，调用方应保留消息（不确认），避免丢失
+func recordDeadLetter(ctx context.Context, backend string, msg *Message, handleErr error) ([]byte, error) {
+	if database.DB == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+
+	errText := ""
+	if handleErr != nil {
+		errText = handleErr.Error()
+		if len(errText) > deadLetterErrorMaxLen {
+			errText = errText[:deadLetterErrorMaxLen]
+		}
+	}
+
+	now := time.Now()
+	record := &models.MQDeadLetter{
+		Backend:        backend,
+		Topic:          msg.Topic,
+		Tag:            msg.Tag,
+		MessageID:      msg.ID,
+		Body:           string(msg.Body),
+		Error:          errText,
+		Attempts:       msg.Attempt,
+		Status:         models.DeadLetterStatusPending,
+		CreateDatetime: &now,
+		UpdateDatetime: &now,
+	}
+	if err := database.DB.WithContext(ctx).Create(record).Error; err != nil {
+		return nil, fmt.Errorf("写入死信记录失败: %w", err)
+	}
+
+	logger.Logger.Error("消息处理失败次数达到上限，已转入死信",
+		zap.String("backend", backend),
+		zap.String("topic", msg.Topic),
+		zap.String("message_id", msg.ID),
+		zap.Int("attempt", msg.Attempt),
+		zap.Int64("dead_letter_id", record.ID),
+		zap.Error(handleErr))
+
+	event, err := json.Marshal(&DeadLetterEvent{
+		ID:        record.ID,
+		Topic:     record.Topic,
+		Tag:       record.Tag,
+		MessageID: record.MessageID,
+		Body:      record.Body,
+		Error:     record.Error,
+		Attempts:  record.Attempts,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("序列化死信消息失败: %w", err)
+	}
+	return event, nil
+}
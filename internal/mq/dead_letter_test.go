@@ -0,0 +1,142 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	rocketmq "github.com/apache/rocketmq-clients/golang/v5"
+	"github.com/golang-pay-core/config"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupTestConfig 设置总线配置：默认最大投递 3 次，cache-refresh 主题 1 次
+func setupTestConfig(t *testing.T) {
+	oldCfg := config.Cfg
+	config.Cfg = &config.Config{Bus: config.BusConfig{
+		MaxAttempts:      3,
+		TopicMaxAttempts: map[string]int{TopicCacheRefresh: 1},
+		DeadLetterTopic:  "test-dead-letter",
+	}}
+	t.Cleanup(func() {
+		config.Cfg = oldCfg
+	})
+}
+
+func TestBusBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		3:  4 * time.Second,
+		9:  256 * time.Second,
+		10: busMaxBackoff,
+		64: busMaxBackoff,
+	}
+	for attempt, expected := range cases {
+		assert.Equal(t, expected, busBackoff(attempt), "attempt %d", attempt)
+	}
+}
+
+func TestMaxAttemptsFor(t *testing.T) {
+	oldCfg := config.Cfg
+	config.Cfg = nil
+	assert.Equal(t, defaultBusMaxAttempts, maxAttemptsFor(TopicOrderNotify))
+	assert.Equal(t, defaultDeadLetterTopic, deadLetterTopic())
+	config.Cfg = oldCfg
+
+	setupTestConfig(t)
+	assert.Equal(t, 3, maxAttemptsFor(TopicOrderNotify))
+	assert.Equal(t, 1, maxAttemptsFor(TopicCacheRefresh))
+	assert.Equal(t, "test-dead-letter", deadLetterTopic())
+}
+
+func TestConsumeMessage(t *testing.T) {
+	db := setupTestDB(t)
+	setupTestConfig(t)
+	ctx := context.Background()
+	client := &RocketMQClient{} // 未启用：发送死信主题失败只记录日志
+
+	calls := 0
+	handlers := map[string]Handler{
+		TopicOrderNotify: func(ctx context.Context, msg *Message) error {
+			calls++
+			return errors.New("notify failed")
+		},
+		TopicCacheRefresh: func(ctx context.Context, msg *Message) error {
+			calls++
+			panic("boom")
+		},
+		TopicBalanceSync: func(ctx context.Context, msg *Message) error {
+			calls++
+			return nil
+		},
+	}
+	deadLetters := func() []models.MQDeadLetter {
+		var records []models.MQDeadLetter
+		db.Order("id").Find(&records)
+		return records
+	}
+
+	// 成功、未知主题：确认
+	assert.Equal(t, rocketmq.SUCCESS, consumeMessage(ctx, handlers, client, &Message{ID: "m0", Topic: TopicBalanceSync, Attempt: 1}))
+	assert.Equal(t, rocketmq.SUCCESS, consumeMessage(ctx, handlers, client, &Message{ID: "m0", Topic: "unknown", Attempt: 1}))
+	assert.Equal(t, 1, calls)
+
+	// 未达到最大投递次数：返回 FAILURE 由 Broker 重新投递，不写死信
+	for attempt := 1; attempt < 3; attempt++ {
+		msg := &Message{ID: "m1", Topic: TopicOrderNotify, Tag: "notify", Body: []byte(`{"order_id":"O1"}`), Attempt: attempt}
+		assert.Equal(t, rocketmq.FAILURE, consumeMessage(ctx, handlers, client, msg))
+	}
+	assert.Empty(t, deadLetters())
+
+	// 达到最大投递次数：写入死信后确认
+	msg := &Message{ID: "m1", Topic: TopicOrderNotify, Tag: "notify", Body: []byte(`{"order_id":"O1"}`), Attempt: 3}
+	assert.Equal(t, rocketmq.SUCCESS, consumeMessage(ctx, handlers, client, msg))
+	records := deadLetters()
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, BusBackendRocketMQ, records[0].Backend)
+		assert.Equal(t, TopicOrderNotify, records[0].Topic)
+		assert.Equal(t, "notify", records[0].Tag)
+		assert.Equal(t, "m1", records[0].MessageID)
+		assert.Equal(t, `{"order_id":"O1"}`, records[0].Body)
+		assert.Equal(t, "notify failed", records[0].Error)
+		assert.Equal(t, 3, records[0].Attempts)
+		assert.Equal(t, models.DeadLetterStatusPending, records[0].Status)
+	}
+
+	// 主题覆盖最大投递次数，panic 视为处理失败
+	assert.Equal(t, rocketmq.SUCCESS, consumeMessage(ctx, handlers, client, &Message{ID: "m2", Topic: TopicCacheRefresh, Attempt: 1}))
+	records = deadLetters()
+	if assert.Len(t, records, 2) {
+		assert.Contains(t, records[1].Error, "panic")
+	}
+
+	// 死信写入失败：返回 FAILURE 交由 Broker 重试
+	database.DB = nil
+	assert.Equal(t, rocketmq.FAILURE, consumeMessage(ctx, handlers, client, &Message{ID: "m3", Topic: TopicOrderNotify, Attempt: 3}))
+}
+
+func TestRecordDeadLetterEvent(t *testing.T) {
+	setupTestDB(t)
+	longErr := make([]byte, deadLetterErrorMaxLen+10)
+	for i := range longErr {
+		longErr[i] = 'x'
+	}
+
+	event, err := recordDeadLetter(context.Background(), BusBackendRedis,
+		&Message{ID: "m1", Topic: TopicOrderNotify, Tag: "t", Body: []byte("{}"), Attempt: 16}, errors.New(string(longErr)))
+	if !assert.NoError(t, err) {
+		return
+	}
+	var ev DeadLetterEvent
+	assert.NoError(t, json.Unmarshal(event, &ev))
+	assert.Equal(t, TopicOrderNotify, ev.Topic)
+	assert.Equal(t, "t", ev.Tag)
+	assert.Equal(t, 16, ev.Attempts)
+	assert.Len(t, ev.Error, deadLetterErrorMaxLen)
+	assert.NotZero(t, ev.ID)
+}
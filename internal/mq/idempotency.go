@@ -0,0 +1,347 @@
+package mq
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-pay-core/config"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// dedupeKeyPrefix 幂等键前缀（mq:dedupe:{topic}:{业务键}）
+	dedupeKeyPrefix = "mq:dedupe:"
+	// dedupeKeyMaxLen 业务键超过该长度时使用摘要（MySQL 唯一索引长度限制）
+	dedupeKeyMaxLen = 128
+	// defaultDedupeTTL 默认幂等记录保留时间
+	defaultDedupeTTL = 24 * time.Hour
+	// dedupeCleanupInterval MySQL 过期幂等记录的清理间隔
+	dedupeCleanupInterval = 10 * time.Minute
+	// dedupeCleanupBatch 单次清理的最大记录数
+	dedupeCleanupBatch = 1000
+)
+
+var busMessagesDeduplicated = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "bus_messages_deduplicated_total",
+	Help: "消息总线重复消息（已处理或正在处理的业务事件）跳过计数",
+}, []string{"topic"})
+
+// BusinessKeyFunc 从消息中提取业务幂等键（订单ID + 事件类型），返回空字符串时按消息ID去重
+type BusinessKeyFunc func(msg *Message) string
+
+// claimResult 幂等键认领结果
+type claimResult int
+
+const (
+	claimAcquired   claimResult = iota // 认领成功，可以处理
+	claimDuplicate                     // 已处理过，跳过
+	claimInProgress                    // 正在被其他消费者处理（直接确认，由处理者的消息保证至少一次）
+)
+
+// dedupeStore 幂等记录存储
+// claim 以 processing 状态占用键（超过 processingTTL 未完成视为处理者已崩溃，可被重新认领）；
+// done 将键标记为已处理并保留 ttl；release 在处理失败时释放键，允许重试
+type dedupeStore interface {
+	claim(ctx context.Context, key string, msg *Message, token string, processingTTL time.Duration) (claimResult, error)
+	done(ctx context.Context, key string, msg *Message, token string, ttl time.Duration) error
+	release(ctx context.Context, key, token string) error
+}
+
+// Idempotent 为处理函数增加幂等消费：同一业务事件在幂等记录保留期内只会成功处理一次
+// 至少一次投递（重复投递、失败重试、实例崩溃后认领）下，副作用只发生一次；处理失败时释放幂等键，不影响重试
+// 相同业务消息正在被其他消费者处理时直接确认当前消息，不计入重试次数：
+// 处理者失败时其消息按总线重试，处理者崩溃时其消息超时后被重新投递（处理中状态同时到期），业务事件不会丢失
+// 优先使用 Redis 存储幂等记录，Redis 未初始化时使用 MySQL；存储不可用时返回错误，由总线稍后重新投递
+func Idempotent(keyFunc BusinessKeyFunc, handler Handler) Handler {
+	return func(ctx context.Context, msg *Message) error {
+		store := currentDedupeStore()
+		if store == nil {
+			return handler(ctx, msg)
+		}
+
+		key := dedupeKey(msg, keyFunc)
+		token := newDedupeToken()
+		result, err := store.claim(ctx, key, msg, token, dedupeProcessingTTL())
+		if err != nil {
+			return fmt.Errorf("幂等检查失败: %w", err)
+		}
+		switch result {
+		case claimDuplicate:
+			busMessagesDeduplicated.WithLabelValues(msg.Topic).Inc()
+			logger.Logger.Info("重复消息，业务已处理，跳过",
+				zap.String("topic", msg.Topic),
+				zap.String("message_id", msg.ID),
+				zap.String("dedupe_key", key))
+			return nil
+		case claimInProgress:
+			busMessagesDeduplicated.WithLabelValues(msg.Topic).Inc()
+			logger.Logger.Info("相同业务消息正在处理中，确认重复消息",
+				zap.String("topic", msg.Topic),
+				zap.String("message_id", msg.ID),
+				zap.String("dedupe_key", key))
+			return nil
+		}
+
+		if err := runHandler(ctx, handler, msg); err != nil {
+			if releaseErr := store.release(ctx, key, token); releaseErr != nil {
+				logger.Logger.Warn("释放幂等键失败（到期后可重新处理）",
+					zap.String("dedupe_key", key),
+					zap.Error(releaseErr))
+			}
+			return err
+		}
+
+		if err := store.done(ctx, key, msg, token, dedupeTTL()); err != nil {
+			// 业务已处理成功，标记失败只记录日志（处理中状态到期前仍可拦截重复消息）
+			logger.Logger.Warn("记录幂等键失败",
+				zap.String("dedupe_key", key),
+				zap.String("message_id", msg.ID),
+				zap.Error(err))
+		}
+		return nil
+	}
+}
+
+// dedupeKey 生成幂等键：有业务键时按主题 + 业务键，否则按主题 + 消息ID
+func dedupeKey(msg *Message, keyFunc BusinessKeyFunc) string {
+	business := ""
+	if keyFunc != nil {
+		business = keyFunc(msg)
+	}
+	if business == "" {
+		business = "msg:" + msg.ID
+	}
+	if len(business) > dedupeKeyMaxLen {
+		sum := sha1.Sum([]byte(business))
+		business = "sha1:" + hex.EncodeToString(sum[:])
+	}
+	return msg.Topic + ":" + business
+}
+
+// currentDedupeStore 当前可用的幂等记录存储（Redis 优先）
+func currentDedupeStore() dedupeStore {
+	if database.RDB != nil {
+		return redisDedupeStore{rdb: database.RDB}
+	}
+	if database.DB != nil {
+		return mysqlDedupeStore{}
+	}
+	return nil
+}
+
+// dedupeTTL 幂等记录保留时间
+func dedupeTTL() time.Duration {
+	if cfg := config.GetConfig(); cfg != nil && cfg.Bus.DedupeTTL > 0 {
+		return cfg.Bus.DedupeTTL
+	}
+	return defaultDedupeTTL
+}
+
+// dedupeProcessingTTL 处理中状态的保留时间（与未确认消息的认领超时一致）
+func dedupeProcessingTTL() time.Duration {
+	if cfg := config.GetConfig(); cfg != nil && cfg.Bus.VisibilityTimeout > 0 {
+		return cfg.Bus.VisibilityTimeout
+	}
+	return defaultBusVisibilityTimeout
+}
+
+// newDedupeToken 生成处理令牌（释放幂等键时校验，避免释放其他消费者认领的键）
+func newDedupeToken() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// releaseDedupeScript 令牌一致时删除幂等键
+var releaseDedupeScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// redisDedupeStore Redis 幂等记录（值为 processing:{令牌} 或 done:{消息ID}）
+type redisDedupeStore struct {
+	rdb *redis.Client
+}
+
+func (s redisDedupeStore) claim(ctx context.Context, key string, msg *Message, token string, processingTTL time.Duration) (claimResult, error) {
+	redisKey := dedupeKeyPrefix + key
+	for i := 0; i < 2; i++ {
+		ok, err := s.rdb.SetNX(ctx, redisKey, "processing:"+token, processingTTL).Result()
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return claimAcquired, nil
+		}
+
+		value, err := s.rdb.Get(ctx, redisKey).Result()
+		if err == redis.Nil {
+			// 键在两次操作之间过期，重新认领
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if strings.HasPrefix(value, "done:") {
+			return claimDuplicate, nil
+		}
+		return claimInProgress, nil
+	}
+	return claimInProgress, nil
+}
+
+func (s redisDedupeStore) done(ctx context.Context, key string, msg *Message, token string, ttl time.Duration) error {
+	return s.rdb.Set(ctx, dedupeKeyPrefix+key, "done:"+msg.ID, ttl).Err()
+}
+
+func (s redisDedupeStore) release(ctx context.Context, key, token string) error {
+	return releaseDedupeScript.Run(ctx, s.rdb, []string{dedupeKeyPrefix + key}, "processing:"+token).Err()
+}
+
+// lastDedupeCleanup 上次清理 MySQL 过期幂等记录的时间（Unix 秒）
+var lastDedupeCleanup int64
+
+// mysqlDedupeStore MySQL 幂等记录（dvadmin_mq_consume_record，dedupe_key 唯一）
+type mysqlDedupeStore struct{}
+
+func (s mysqlDedupeStore) claim(ctx context.Context, key string, msg *Message, token string, processingTTL time.Duration) (claimResult, error) {
+	s.cleanupExpired(ctx)
+
+	now := time.Now()
+	record := &models.MQConsumeRecord{
+		DedupeKey:      key,
+		Topic:          msg.Topic,
+		MessageID:      msg.ID,
+		Token:          token,
+		Status:         models.ConsumeRecordStatusProcessing,
+		ExpireDatetime: now.Add(processingTTL),
+		CreateDatetime: &now,
+		UpdateDatetime: &now,
+	}
+	result := database.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return claimAcquired, nil
+	}
+
+	// 已存在：过期的记录（处理者崩溃或保留期已过）可以被重新认领
+	result = database.DB.WithContext(ctx).Model(&models.MQConsumeRecord{}).
+		Where("dedupe_key = ? AND expire_datetime < ?", key, now).
+		Updates(map[string]interface{}{
+			"message_id":      msg.ID,
+			"token":           token,
+			"status":          models.ConsumeRecordStatusProcessing,
+			"expire_datetime": now.Add(processingTTL),
+			"update_datetime": now,
+		})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return claimAcquired, nil
+	}
+
+	var existing models.MQConsumeRecord
+	if err := database.DB.WithContext(ctx).Select("status").Where("dedupe_key = ?", key).First(&existing).Error; err != nil {
+		return 0, err
+	}
+	if existing.Status == models.ConsumeRecordStatusDone {
+		return claimDuplicate, nil
+	}
+	return claimInProgress, nil
+}
+
+func (s mysqlDedupeStore) done(ctx context.Context, key string, msg *Message, token string, ttl time.Duration) error {
+	now := time.Now()
+	return database.DB.WithContext(ctx).Model(&models.MQConsumeRecord{}).
+		Where("dedupe_key = ? AND token = ?", key, token).
+		Updates(map[string]interface{}{
+			"status":          models.ConsumeRecordStatusDone,
+			"expire_datetime": now.Add(ttl),
+			"update_datetime": now,
+		}).Error
+}
+
+func (s mysqlDedupeStore) release(ctx context.Context, key, token string) error {
+	return database.DB.WithContext(ctx).
+		Where("dedupe_key = ? AND token = ?", key, token).
+		Delete(&models.MQConsumeRecord{}).Error
+}
+
+// cleanupExpired 定期删除过期的幂等记录（每个进程最多每 10 分钟一次，每次最多 1000 条）
+func (s mysqlDedupeStore) cleanupExpired(ctx context.Context) {
+	now := time.Now().Unix()
+	last := atomic.LoadInt64(&lastDedupeCleanup)
+	if now-last < int64(dedupeCleanupInterval/time.Second) || !atomic.CompareAndSwapInt64(&lastDedupeCleanup, last, now) {
+		return
+	}
+	if err := database.DB.WithContext(ctx).
+		Where("expire_datetime < ?", time.Now()).
+		Limit(dedupeCleanupBatch).
+		Delete(&models.MQConsumeRecord{}).Error; err != nil {
+		logger.Logger.Warn("清理过期幂等记录失败", zap.Error(err))
+	}
+}
+
+// callbackSubmitKey 插件回调提交：订单ID
+func callbackSubmitKey(msg *Message) string {
+	var m CallbackSubmitMessage
+	if json.Unmarshal(msg.Body, &m) != nil || m.OrderID == "" {
+		return ""
+	}
+	return m.OrderID + ":submit"
+}
+
+// alipayNotifyKey 支付宝回调：商户订单号 + 交易状态（支付成功、交易关闭是不同事件）
+func alipayNotifyKey(msg *Message) string {
+	var m AlipayNotifyMessage
+	if json.Unmarshal(msg.Body, &m) != nil || m.NotifyData == nil || m.NotifyData.OutTradeNo == "" {
+		return ""
+	}
+	return m.NotifyData.OutTradeNo + ":" + m.NotifyData.TradeStatus
+}
+
+// dayStatisticsKey 日统计：订单ID + 统计类型（提交、成功分别计数一次）
+func dayStatisticsKey(msg *Message) string {
+	var m DayStatisticsMessage
+	if json.Unmarshal(msg.Body, &m) != nil || m.OrderID == "" {
+		return ""
+	}
+	return m.OrderID + ":" + m.StatisticsType
+}
+
+// orderNotifyKey 订单通知：订单ID + 订单状态
+func orderNotifyKey(msg *Message) string {
+	var m OrderNotifyMessage
+	if json.Unmarshal(msg.Body, &m) != nil || m.OrderID == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", m.OrderID, m.Status)
+}
+
+// orderTimeoutKey 订单超时：订单ID
+func orderTimeoutKey(msg *Message) string {
+	var m OrderTimeoutMessage
+	if json.Unmarshal(msg.Body, &m) != nil || m.OrderID == "" {
+		return ""
+	}
+	return m.OrderID + ":timeout"
+}
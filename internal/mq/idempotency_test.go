@@ -0,0 +1,157 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupTestDB 设置测试数据库（消费幂等记录、死信表）并替换全局连接
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.MQConsumeRecord{}, &models.MQDeadLetter{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+	oldDB, oldRDB, oldLogger := database.DB, database.RDB, logger.Logger
+	database.DB, database.RDB, logger.Logger = db, nil, zap.NewNop()
+	t.Cleanup(func() {
+		database.DB, database.RDB, logger.Logger = oldDB, oldRDB, oldLogger
+	})
+	return db
+}
+
+// setupTestRedis 设置测试 Redis（miniredis）并替换全局连接
+func setupTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	oldRDB, oldLogger := database.RDB, logger.Logger
+	database.RDB, logger.Logger = rdb, zap.NewNop()
+	t.Cleanup(func() {
+		database.RDB, logger.Logger = oldRDB, oldLogger
+		_ = rdb.Close()
+	})
+	return mr, rdb
+}
+
+// testDedupeStore 对幂等存储执行认领、处理中、释放、完成的状态流转
+func testDedupeStore(t *testing.T, store dedupeStore, expire func(key string)) {
+	ctx := context.Background()
+	msg := &Message{ID: "m1", Topic: "order-notify"}
+	const key = "order-notify:O1:6"
+
+	result, err := store.claim(ctx, key, msg, "t1", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, claimAcquired, result)
+
+	// 处理中：其他消费者认领失败
+	result, err = store.claim(ctx, key, msg, "t2", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, claimInProgress, result)
+
+	// 令牌不一致时不释放
+	assert.NoError(t, store.release(ctx, key, "t2"))
+	result, _ = store.claim(ctx, key, msg, "t3", time.Minute)
+	assert.Equal(t, claimInProgress, result)
+
+	// 释放后可重新认领
+	assert.NoError(t, store.release(ctx, key, "t1"))
+	result, err = store.claim(ctx, key, msg, "t4", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, claimAcquired, result)
+
+	// 完成后为重复消息
+	assert.NoError(t, store.done(ctx, key, msg, "t4", time.Hour))
+	result, err = store.claim(ctx, key, msg, "t5", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, claimDuplicate, result)
+
+	// 处理中状态到期（处理者崩溃）后可重新认领
+	const crashed = "order-notify:O2:6"
+	result, _ = store.claim(ctx, crashed, msg, "t6", time.Minute)
+	assert.Equal(t, claimAcquired, result)
+	expire(crashed)
+	result, err = store.claim(ctx, crashed, msg, "t7", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, claimAcquired, result)
+}
+
+func TestRedisDedupeStore(t *testing.T) {
+	mr, rdb := setupTestRedis(t)
+	testDedupeStore(t, redisDedupeStore{rdb: rdb}, func(string) {
+		mr.FastForward(2 * time.Minute)
+	})
+}
+
+func TestMySQLDedupeStore(t *testing.T) {
+	db := setupTestDB(t)
+	testDedupeStore(t, mysqlDedupeStore{}, func(key string) {
+		db.Model(&models.MQConsumeRecord{}).Where("dedupe_key = ?", key).
+			Update("expire_datetime", time.Now().Add(-time.Second))
+	})
+}
+
+func TestIdempotent(t *testing.T) {
+	stores := map[string]func(t *testing.T) dedupeStore{
+		"redis": func(t *testing.T) dedupeStore {
+			_, rdb := setupTestRedis(t)
+			return redisDedupeStore{rdb: rdb}
+		},
+		"mysql": func(t *testing.T) dedupeStore {
+			setupTestDB(t)
+			return mysqlDedupeStore{}
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+			keyFunc := func(msg *Message) string { return "O1:6" }
+			msg := &Message{ID: "m1", Topic: "order-notify", Attempt: 1}
+
+			calls := 0
+			failing := Idempotent(keyFunc, func(ctx context.Context, msg *Message) error {
+				calls++
+				return errors.New("boom")
+			})
+			ok := Idempotent(keyFunc, func(ctx context.Context, msg *Message) error {
+				calls++
+				return nil
+			})
+
+			// 处理失败返回错误并释放幂等键，重试时重新处理
+			assert.Error(t, failing(ctx, msg))
+			assert.NoError(t, ok(ctx, msg))
+			assert.Equal(t, 2, calls)
+
+			// 已处理：跳过
+			assert.NoError(t, ok(ctx, &Message{ID: "m2", Topic: "order-notify", Attempt: 1}))
+			assert.Equal(t, 2, calls)
+
+			// 处理中：直接确认，不调用处理函数也不返回错误（不占用重试次数）
+			key := dedupeKey(&Message{Topic: "order-notify"}, func(*Message) string { return "O2:6" })
+			result, err := store.claim(ctx, key, msg, "holder", time.Minute)
+			assert.NoError(t, err)
+			assert.Equal(t, claimAcquired, result)
+			inProgress := Idempotent(func(*Message) string { return "O2:6" }, func(ctx context.Context, msg *Message) error {
+				calls++
+				return nil
+			})
+			assert.NoError(t, inProgress(ctx, &Message{ID: "m3", Topic: "order-notify", Attempt: 1}))
+			assert.Equal(t, 2, calls)
+		})
+	}
+}
@@ -37,13 +37,15 @@ type OrderNotifyMessage struct {
 
 // DayStatisticsMessage 日统计数据更新消息
 type DayStatisticsMessage struct {
+	OrderID        string `json:"order_id"` // 订单ID（与 Status 一起作为幂等消费的业务键）
+	Status         int    `json:"status"`   // 触发统计的订单状态
 	ProductID      string `json:"product_id"`
 	ChannelID      int64  `json:"channel_id"`
 	TenantID       int64  `json:"tenant_id"`
 	WriteoffID     *int64 `json:"writeoff_id"`
 	Money          int    `json:"money"`
 	Date           string `json:"date"`            // 日期格式：2006-01-02
-	StatisticsType string `json:"statistics_type"` // submit, success
+	StatisticsType string `json:"statistics_type"` // submit, success, refund
 	ExtraArg       int    `json:"extra_arg"`       // 通道的 extra_arg（用于判断公池/神码模式）
 }
 
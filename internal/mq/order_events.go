@@ -0,0 +1,143 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/order"
+	"github.com/golang-pay-core/internal/outbox"
+	"gorm.io/gorm"
+)
+
+// orderNotifyEventDelay 订单通知事件的延迟
+// 正常流程中调用方在订单成功后立即通知商户，该事件只作为兜底（进程在通知前崩溃时补发），延迟发送避免与正常流程并发
+const orderNotifyEventDelay = 30 * time.Second
+
+// OrderStatusEventWriter 订单状态变更事件写入器（实现 order.StatusEventWriter）
+// 订单进入支付成功状态时，在同一事务中写入 order-notify 和 day-statistics 发件箱事件；
+// 支付成功的订单变更为已退款时，在同一事务中写入回退统计的 day-statistics 事件
+type OrderStatusEventWriter struct{}
+
+// WriteStatusEvents 写入订单状态变更事件
+func (OrderStatusEventWriter) WriteStatusEvents(tx *gorm.DB, change order.StatusChange) error {
+	switch {
+	case isPaidStatus(change.NewStatus) && !isPaidStatus(change.OldStatus):
+		return writePaidEvents(tx, change)
+	case change.NewStatus == models.OrderStatusRefunded && isPaidStatus(change.OldStatus):
+		return writeRefundEvents(tx, change)
+	}
+	return nil
+}
+
+// writePaidEvents 写入订单支付成功事件（商户通知兜底、成功统计）
+func writePaidEvents(tx *gorm.DB, change order.StatusChange) error {
+	var detail models.OrderDetail
+	if err := tx.Select("product_id, notify_url, ticket_no").
+		Where("order_id = ?", change.Order.ID).
+		First(&detail).Error; err != nil {
+		return fmt.Errorf("查询订单详情失败: %w", err)
+	}
+	ticketNo := change.TicketNo
+	if ticketNo == "" {
+		ticketNo = detail.TicketNo
+	}
+
+	now := time.Now()
+	return outbox.Add(tx,
+		outbox.Event{
+			Topic: TopicOrderNotify,
+			Tag:   "notify",
+			Key:   change.Order.ID,
+			Body: &OrderNotifyMessage{
+				OrderID:    change.Order.ID,
+				OrderNo:    change.Order.OrderNo,
+				OutOrderNo: change.Order.OutOrderNo,
+				Money:      change.Order.Money,
+				Status:     change.NewStatus,
+				TicketNo:   ticketNo,
+				NotifyURL:  detail.NotifyURL,
+				Timestamp:  now.Unix(),
+			},
+			Delayed: true,
+			Delay:   orderNotifyEventDelay,
+		},
+		outbox.Event{
+			Topic: TopicDayStatistics,
+			Tag:   "success",
+			Key:   change.Order.ID,
+			Body:  dayStatisticsMessage(change, detail.ProductID, "success"),
+		},
+	)
+}
+
+// writeRefundEvents 写入订单退款事件（回退订单成功时累加的统计）
+func writeRefundEvents(tx *gorm.DB, change order.StatusChange) error {
+	var detail models.OrderDetail
+	if err := tx.Select("product_id").
+		Where("order_id = ?", change.Order.ID).
+		First(&detail).Error; err != nil {
+		return fmt.Errorf("查询订单详情失败: %w", err)
+	}
+
+	return outbox.Add(tx, outbox.Event{
+		Topic: TopicDayStatistics,
+		Tag:   "refund",
+		Key:   change.Order.ID,
+		Body:  dayStatisticsMessage(change, detail.ProductID, "refund"),
+	})
+}
+
+// dayStatisticsMessage 构建订单状态变更的日统计消息
+func dayStatisticsMessage(change order.StatusChange, productID, statisticsType string) *DayStatisticsMessage {
+	msg := &DayStatisticsMessage{
+		OrderID:        change.Order.ID,
+		Status:         change.NewStatus,
+		ProductID:      productID,
+		WriteoffID:     change.Order.WriteoffID,
+		Money:          change.Order.Money,
+		Date:           time.Now().Format("2006-01-02"),
+		StatisticsType: statisticsType,
+	}
+	if change.Order.PayChannelID != nil {
+		msg.ChannelID = *change.Order.PayChannelID
+	}
+	if change.TenantID != nil {
+		msg.TenantID = *change.TenantID
+	}
+	return msg
+}
+
+// isPaidStatus 是否为支付成功状态（通知已返回或未返回）
+func isPaidStatus(status int) bool {
+	return status == models.OrderStatusPaid || status == models.OrderStatusPaidNoNotify
+}
+
+// OrderNotifyProcessor 订单通知事件处理器（避免循环依赖，由 service 包实现并在启动时注入）
+type OrderNotifyProcessor interface {
+	// ProcessOrderNotify 确保支付成功的订单已创建商户通知任务（已存在时不重复通知）
+	ProcessOrderNotify(ctx context.Context, msg *OrderNotifyMessage) error
+}
+
+// orderNotifyProcessor 全局订单通知事件处理器（未设置时只记录日志）
+var orderNotifyProcessor OrderNotifyProcessor
+
+// SetOrderNotifyProcessor 设置全局订单通知事件处理器
+func SetOrderNotifyProcessor(processor OrderNotifyProcessor) {
+	orderNotifyProcessor = processor
+}
+
+// DayStatisticsProcessor 日统计事件处理器（避免循环依赖，由 service 包实现并在启动时注入）
+type DayStatisticsProcessor interface {
+	// ProcessDayStatistics 更新订单相关的日统计（同一订单、同一状态只统计一次）
+	ProcessDayStatistics(ctx context.Context, msg *DayStatisticsMessage) error
+}
+
+// dayStatisticsProcessor 全局日统计事件处理器（未设置时只记录日志）
+var dayStatisticsProcessor DayStatisticsProcessor
+
+// SetDayStatisticsProcessor 设置全局日统计事件处理器
+func SetDayStatisticsProcessor(processor DayStatisticsProcessor) {
+	dayStatisticsProcessor = processor
+}
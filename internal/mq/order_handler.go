@@ -2,12 +2,14 @@ package mq
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/golang-pay-core/internal/database"
 	"github.com/golang-pay-core/internal/logger"
 	"github.com/golang-pay-core/internal/models"
 	"github.com/golang-pay-core/internal/order"
+	"github.com/golang-pay-core/internal/plugin"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
@@ -67,21 +69,43 @@ func (a *preTaxReleaserAdapter) ReleasePreTax(ctx context.Context, tenantID int6
 
 // updateOrderStatusDirectly 直接更新订单状态（避免循环依赖）
 // 使用统一的 order.UpdateStatus 实现，避免代码重复
-func updateOrderStatusDirectly(ctx context.Context, orderID string, status int, ticketNo string) error {
+// 指定 fromStatuses 时只更新当前状态属于其中的订单，否则返回 order.ErrStatusChanged
+func updateOrderStatusDirectly(ctx context.Context, orderID string, status int, ticketNo string, fromStatuses ...int) error {
 	// 创建适配器
 	tenantIDProvider := &tenantIDProviderAdapter{}
 	preTaxReleaser := &preTaxReleaserAdapter{}
 
-	// 使用统一的订单状态更新逻辑
-	return order.UpdateStatus(ctx, order.UpdateStatusRequest{
-		OrderID:  orderID,
-		Status:   status,
-		TicketNo: ticketNo,
-	}, order.UpdateStatusOptions{
+	opts := order.UpdateStatusOptions{
 		PreTaxReleaser:        preTaxReleaser,
 		TenantIDProvider:      tenantIDProvider,
 		HandleWriteoffBalance: false, // mq 包不需要处理码商余额
-	})
+	}
+	if GetBus().IsEnabled() {
+		opts.EventWriter = OrderStatusEventWriter{}
+	}
+
+	// 使用统一的订单状态更新逻辑
+	return order.UpdateStatus(ctx, order.UpdateStatusRequest{
+		OrderID:      orderID,
+		Status:       status,
+		TicketNo:     ticketNo,
+		FromStatuses: fromStatuses,
+	}, opts)
+}
+
+// UpstreamOrderChecker 上游查单器接口（避免循环依赖，由 service 包实现并在启动时注入）
+type UpstreamOrderChecker interface {
+	// CheckUpstreamPaid 查询上游交易状态，上游已支付时完成补单（成功钩子、通知商户）并返回 true
+	// 查单失败或上游交易状态未知时返回错误，调用方不应关闭订单
+	CheckUpstreamPaid(ctx context.Context, orderNo string) (bool, error)
+}
+
+// upstreamOrderChecker 全局上游查单器（未设置时超时只按时间关闭订单）
+var upstreamOrderChecker UpstreamOrderChecker
+
+// SetUpstreamOrderChecker 设置全局上游查单器
+func SetUpstreamOrderChecker(checker UpstreamOrderChecker) {
+	upstreamOrderChecker = checker
 }
 
 // HandleOrderTimeout 处理订单超时（统一逻辑，对外暴露）
@@ -91,13 +115,13 @@ func updateOrderStatusDirectly(ctx context.Context, orderID string, status int,
 func HandleOrderTimeout(ctx context.Context, orderNo string) error {
 	// 查询订单（只查询状态为 [0, 2] 的订单）
 	// 参考 Python: timeout_order 只处理状态为 [0, 2] 的订单
-	var order models.Order
-	if err := database.DB.Select("id, order_no, order_status, merchant_id, money").
+	var o models.Order
+	if err := database.DB.Select("id, order_no, out_order_no, order_status, merchant_id, money, pay_channel_id").
 		Where("order_no = ? AND order_status IN ?", orderNo, []int{
 			models.OrderStatusGenerating, // 0 - 生成中
 			models.OrderStatusPaying,     // 2 - 等待支付
 		}).
-		First(&order).Error; err != nil {
+		First(&o).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			logger.Logger.Info("订单不存在或状态不在超时检查范围内，跳过处理",
 				zap.String("order_no", orderNo),
@@ -111,25 +135,144 @@ func HandleOrderTimeout(ctx context.Context, orderNo string) error {
 	}
 
 	logger.Logger.Info("开始处理订单超时",
-		zap.String("order_id", order.ID),
+		zap.String("order_id", o.ID),
 		zap.String("order_no", orderNo),
-		zap.Int("current_status", order.OrderStatus))
+		zap.Int("current_status", o.OrderStatus))
+
+	// 关闭前先向上游确认真实交易状态，避免异步通知丢失导致已支付订单被关闭
+	if upstreamOrderChecker != nil {
+		paid, err := upstreamOrderChecker.CheckUpstreamPaid(ctx, orderNo)
+		if err != nil {
+			// 查单失败或上游状态未知时不关闭订单（买家可能已支付），返回错误由调用方稍后重试
+			logger.Logger.Warn("超时查单失败，暂不关闭订单",
+				zap.String("order_id", o.ID),
+				zap.String("order_no", orderNo),
+				zap.Error(err))
+			return fmt.Errorf("超时查单失败: %w", err)
+		}
+		if paid {
+			logger.Logger.Info("超时查单发现订单已支付，已补单，跳过关闭",
+				zap.String("order_id", o.ID),
+				zap.String("order_no", orderNo))
+			return nil
+		}
+	}
+
+	// 关闭上游交易，避免订单关闭后买家仍可继续付款
+	// 关闭失败时不关闭本地订单（上游交易仍可支付），返回错误由调用方稍后重试
+	tradeState, err := closeUpstreamTrade(ctx, &o)
+	if err != nil {
+		logger.Logger.Warn("关闭上游交易失败，暂不关闭订单",
+			zap.String("order_id", o.ID),
+			zap.String("order_no", orderNo),
+			zap.Error(err))
+		return err
+	}
+	// 关闭时发现上游已支付（查单与关单之间买家完成付款），再次查单补单
+	if tradeState == plugin.TradeStatePaid {
+		if upstreamOrderChecker == nil {
+			return fmt.Errorf("关闭上游交易时发现订单已支付，未配置上游查单器，无法补单")
+		}
+		paid, err := upstreamOrderChecker.CheckUpstreamPaid(ctx, orderNo)
+		if err != nil {
+			return fmt.Errorf("关闭上游交易时发现订单已支付，补单查单失败: %w", err)
+		}
+		if !paid {
+			return fmt.Errorf("关闭上游交易时发现订单已支付，但查单未确认支付")
+		}
+		logger.Logger.Info("关闭上游交易时发现订单已支付，已补单，跳过关闭",
+			zap.String("order_id", o.ID),
+			zap.String("order_no", orderNo))
+		return nil
+	}
 
 	// 状态为 [0, 2]，更新订单状态为已关闭（状态 7）并释放预占余额
 	// 参考 Python: timeout_order(order_no) - 只处理状态为 [0, 2] 的订单
-	if err := updateOrderStatusDirectly(ctx, order.ID, models.OrderStatusClosed, ""); err != nil {
+	// 只关闭仍为 [0, 2] 的订单：查单、关单期间订单可能已被异步通知更新为成功
+	err = updateOrderStatusDirectly(ctx, o.ID, models.OrderStatusClosed, "",
+		models.OrderStatusGenerating, models.OrderStatusPaying)
+	if errors.Is(err, order.ErrStatusChanged) {
+		logger.Logger.Info("订单状态已变更，跳过超时关闭",
+			zap.String("order_id", o.ID),
+			zap.String("order_no", orderNo))
+		return nil
+	}
+	if err != nil {
 		logger.Logger.Error("更新超时订单状态失败",
-			zap.String("order_id", order.ID),
+			zap.String("order_id", o.ID),
 			zap.String("order_no", orderNo),
 			zap.Error(err))
 		return err
 	}
 	logger.Logger.Info("订单已超时，状态已更新为已关闭",
-		zap.String("order_id", order.ID),
+		zap.String("order_id", o.ID),
 		zap.String("order_no", orderNo),
-		zap.Int("old_status", order.OrderStatus),
+		zap.Int("old_status", o.OrderStatus),
 		zap.Int("new_status", models.OrderStatusClosed))
 	// 注意：预占余额的释放已经在 updateOrderStatusDirectly 中处理（OrderStatusCancelled = OrderStatusClosed）
 
 	return nil
 }
+
+// closeUpstreamTrade 关闭订单对应的上游交易（插件实现 PluginCloser 时）
+// 返回上游交易状态（TradeState*）；订单未向上游下单或插件不支持关闭时返回空字符串，按原逻辑关闭本地订单
+// 关闭失败或上游交易仍可支付时返回错误，调用方不应关闭本地订单；请求和结果由插件记录到 dvadmin_query_log
+func closeUpstreamTrade(ctx context.Context, o *models.Order) (string, error) {
+	var orderDetail models.OrderDetail
+	if err := database.DB.Select("plugin_id, plugin_type, product_id, ticket_no").
+		Where("order_id = ?", o.ID).First(&orderDetail).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("查询订单详情失败: %w", err)
+	}
+
+	// 未分配产品的订单尚未向上游下单，无需关闭
+	if orderDetail.ProductID == "" {
+		return "", nil
+	}
+
+	orderCtx := &simpleOrderContext{pluginType: orderDetail.PluginType}
+	if orderDetail.PluginID != nil {
+		orderCtx.pluginID = *orderDetail.PluginID
+	}
+	if o.PayChannelID != nil {
+		orderCtx.channelID = *o.PayChannelID
+	}
+
+	pluginInstance, err := plugin.NewManager(database.RDB).GetPluginByCtx(ctx, orderCtx)
+	if err != nil {
+		return "", fmt.Errorf("获取插件实例失败: %w", err)
+	}
+
+	// 检查插件是否支持关闭上游交易（可选能力），不支持时只关闭本地订单
+	closer, ok := pluginInstance.(plugin.PluginCloser)
+	if !ok {
+		return "", nil
+	}
+
+	closeResp, err := closer.CloseOrder(ctx, &plugin.QueryOrderRequest{
+		OrderID:    o.ID,
+		OrderNo:    o.OrderNo,
+		OutOrderNo: o.OutOrderNo,
+		TicketNo:   orderDetail.TicketNo,
+		ProductID:  orderDetail.ProductID,
+		PluginID:   orderCtx.pluginID,
+		PluginType: orderDetail.PluginType,
+		Money:      o.Money,
+	})
+	if err != nil {
+		return "", fmt.Errorf("关闭上游交易失败: %w", err)
+	}
+
+	logger.Logger.Info("关闭上游交易完成",
+		zap.String("order_no", o.OrderNo),
+		zap.String("product_id", orderDetail.ProductID),
+		zap.Bool("closed", closeResp.Closed),
+		zap.String("trade_state", closeResp.TradeState))
+
+	if !closeResp.Closed && closeResp.TradeState != plugin.TradeStatePaid {
+		return "", fmt.Errorf("上游交易未关闭（%s）: %s", closeResp.TradeState, closeResp.ErrorMessage)
+	}
+	return closeResp.TradeState, nil
+}
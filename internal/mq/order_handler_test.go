@@ -0,0 +1,200 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/plugin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// fakeOrderChecker 测试上游查单器
+type fakeOrderChecker struct {
+	paid    bool
+	err     error
+	calls   int
+	onCheck func() // 查单时调用（模拟查单期间的并发更新）
+}
+
+func (c *fakeOrderChecker) CheckUpstreamPaid(ctx context.Context, orderNo string) (bool, error) {
+	c.calls++
+	if c.onCheck != nil {
+		c.onCheck()
+	}
+	return c.paid, c.err
+}
+
+// fakeCloser 支持关闭上游交易的测试插件
+type fakeCloser struct {
+	plugin.Plugin
+	resp  *plugin.CloseOrderResponse
+	err   error
+	calls int
+}
+
+func (p *fakeCloser) CloseOrder(ctx context.Context, req *plugin.QueryOrderRequest) (*plugin.CloseOrderResponse, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.resp, nil
+}
+
+// setupTimeoutTest 准备超时测试环境：订单 PAY001（状态 status，未分配产品），并设置上游查单器
+func setupTimeoutTest(t *testing.T, status int, checker UpstreamOrderChecker) *gorm.DB {
+	db := setupTestDB(t)
+	if err := db.AutoMigrate(&models.Order{}, &models.OrderDetail{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+	db.Create(&models.Order{ID: "O1", OrderNo: "PAY001", OutOrderNo: "M001", Money: 10000, OrderStatus: status})
+	db.Create(&models.OrderDetail{OrderID: "O1"})
+
+	oldChecker := upstreamOrderChecker
+	SetUpstreamOrderChecker(checker)
+	t.Cleanup(func() {
+		SetUpstreamOrderChecker(oldChecker)
+	})
+	return db
+}
+
+// useTestPlugin 将测试订单分配到产品 P1，插件类型 pluginType，测试插件 p 注册为该类型
+func useTestPlugin(db *gorm.DB, pluginType string, p plugin.Plugin) {
+	plugin.GetRegistry().Register(pluginType, func(ctx context.Context, pluginID int64, pluginType string) (plugin.Plugin, error) {
+		return p, nil
+	})
+	db.Model(&models.OrderDetail{}).Where("order_id = ?", "O1").
+		Updates(map[string]interface{}{"product_id": "P1", "plugin_id": 5, "plugin_type": pluginType})
+}
+
+// orderStatus 查询测试订单的当前状态
+func orderStatus(db *gorm.DB) int {
+	var order models.Order
+	db.First(&order, "id = ?", "O1")
+	return order.OrderStatus
+}
+
+func TestHandleOrderTimeoutQueryBeforeClose(t *testing.T) {
+	cases := []struct {
+		name       string
+		checker    *fakeOrderChecker
+		wantErr    bool
+		wantStatus int
+	}{
+		// 上游已支付（查单器已补单）：不关闭
+		{"paid", &fakeOrderChecker{paid: true}, false, models.OrderStatusPaying},
+		// 查单失败或上游状态未知：不关闭，返回错误稍后重试
+		{"query_error", &fakeOrderChecker{err: errors.New("upstream unavailable")}, true, models.OrderStatusPaying},
+		// 上游未支付：关闭
+		{"not_paid", &fakeOrderChecker{}, false, models.OrderStatusClosed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := setupTimeoutTest(t, models.OrderStatusPaying, tc.checker)
+
+			err := HandleOrderTimeout(context.Background(), "PAY001")
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, 1, tc.checker.calls)
+			assert.Equal(t, tc.wantStatus, orderStatus(db))
+		})
+	}
+
+	// 未设置查单器：按时间关闭
+	db := setupTimeoutTest(t, models.OrderStatusGenerating, nil)
+	assert.NoError(t, HandleOrderTimeout(context.Background(), "PAY001"))
+	assert.Equal(t, models.OrderStatusClosed, orderStatus(db))
+}
+
+func TestHandleOrderTimeoutStatusChanged(t *testing.T) {
+	// 订单已成功：不查单也不关闭
+	checker := &fakeOrderChecker{}
+	db := setupTimeoutTest(t, models.OrderStatusPaid, checker)
+	assert.NoError(t, HandleOrderTimeout(context.Background(), "PAY001"))
+	assert.Zero(t, checker.calls)
+	assert.Equal(t, models.OrderStatusPaid, orderStatus(db))
+
+	// 查单期间异步通知将订单更新为成功：不覆盖为已关闭
+	checker = &fakeOrderChecker{}
+	db = setupTimeoutTest(t, models.OrderStatusPaying, checker)
+	checker.onCheck = func() {
+		db.Model(&models.Order{}).Where("id = ?", "O1").Update("order_status", models.OrderStatusPaidNoNotify)
+	}
+	assert.NoError(t, HandleOrderTimeout(context.Background(), "PAY001"))
+	assert.Equal(t, 1, checker.calls)
+	assert.Equal(t, models.OrderStatusPaidNoNotify, orderStatus(db))
+}
+
+func TestHandleOrderTimeoutClosesUpstream(t *testing.T) {
+	cases := []struct {
+		name       string
+		closer     *fakeCloser
+		wantErr    bool
+		wantStatus int
+	}{
+		// 上游交易已关闭或不存在：关闭本地订单
+		{"closed", &fakeCloser{resp: &plugin.CloseOrderResponse{Closed: true, TradeState: plugin.TradeStateClosed}}, false, models.OrderStatusClosed},
+		{"not_exist", &fakeCloser{resp: &plugin.CloseOrderResponse{Closed: true, TradeState: plugin.TradeStateNotExist}}, false, models.OrderStatusClosed},
+		// 关闭失败或上游交易仍可支付：不关闭本地订单，返回错误稍后重试
+		{"close_error", &fakeCloser{err: errors.New("upstream unavailable")}, true, models.OrderStatusPaying},
+		{"still_waiting", &fakeCloser{resp: &plugin.CloseOrderResponse{TradeState: plugin.TradeStateWaiting, ErrorMessage: "system busy"}}, true, models.OrderStatusPaying},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := setupTimeoutTest(t, models.OrderStatusPaying, &fakeOrderChecker{})
+			useTestPlugin(db, "test_close_"+tc.name, tc.closer)
+
+			err := HandleOrderTimeout(context.Background(), "PAY001")
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, 1, tc.closer.calls)
+			assert.Equal(t, tc.wantStatus, orderStatus(db))
+		})
+	}
+}
+
+func TestHandleOrderTimeoutWithoutCloser(t *testing.T) {
+	// 插件不支持关闭上游交易：只关闭本地订单
+	db := setupTimeoutTest(t, models.OrderStatusPaying, &fakeOrderChecker{})
+	useTestPlugin(db, "test_close_unsupported", plugin.NewBasePlugin(5))
+	assert.NoError(t, HandleOrderTimeout(context.Background(), "PAY001"))
+	assert.Equal(t, models.OrderStatusClosed, orderStatus(db))
+
+	// 未分配产品（未向上游下单）：不加载插件，直接关闭
+	db = setupTimeoutTest(t, models.OrderStatusGenerating, &fakeOrderChecker{})
+	assert.NoError(t, HandleOrderTimeout(context.Background(), "PAY001"))
+	assert.Equal(t, models.OrderStatusClosed, orderStatus(db))
+}
+
+func TestHandleOrderTimeoutPaidWhileClosing(t *testing.T) {
+	paidResp := &plugin.CloseOrderResponse{TradeState: plugin.TradeStatePaid, ErrorMessage: "ORDERPAID"}
+
+	// 关闭时发现上游已支付：再次查单补单，不关闭
+	checker := &fakeOrderChecker{}
+	checker.onCheck = func() {
+		checker.paid = checker.calls == 2
+	}
+	db := setupTimeoutTest(t, models.OrderStatusPaying, checker)
+	closer := &fakeCloser{resp: paidResp}
+	useTestPlugin(db, "test_close_paid", closer)
+	assert.NoError(t, HandleOrderTimeout(context.Background(), "PAY001"))
+	assert.Equal(t, 2, checker.calls)
+	assert.Equal(t, 1, closer.calls)
+	assert.Equal(t, models.OrderStatusPaying, orderStatus(db))
+
+	// 再次查单未确认支付：不关闭，稍后重试
+	checker = &fakeOrderChecker{}
+	db = setupTimeoutTest(t, models.OrderStatusPaying, checker)
+	useTestPlugin(db, "test_close_paid_unconfirmed", &fakeCloser{resp: paidResp})
+	assert.Error(t, HandleOrderTimeout(context.Background(), "PAY001"))
+	assert.Equal(t, 2, checker.calls)
+	assert.Equal(t, models.OrderStatusPaying, orderStatus(db))
+}
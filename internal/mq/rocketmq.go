@@ -58,8 +58,8 @@ func GetGlobalMQClient() *RocketMQClient {
 // NewRocketMQClient 创建 RocketMQ 客户端
 func NewRocketMQClient() (*RocketMQClient, error) {
 	cfg := config.GetConfig()
-	// 检查是否启用 RocketMQ
-	if !cfg.RocketMQ.Enabled {
+	// 检查是否启用 RocketMQ（配置未加载时视为未启用）
+	if cfg == nil || !cfg.RocketMQ.Enabled {
 		if logger.Logger != nil {
 			logger.Logger.Info("RocketMQ 未启用，将使用同步处理")
 		}
@@ -306,6 +306,29 @@ func (c *RocketMQClient) SendDelayMessage(ctx context.Context, topic, tag string
 	return nil
 }
 
+// sendRaw 发送已序列化的消息（delay 大于 0 时为延迟消息）
+func (c *RocketMQClient) sendRaw(ctx context.Context, topic, tag string, body []byte, delay time.Duration) error {
+	if !c.enabled {
+		return fmt.Errorf("RocketMQ 未启用")
+	}
+
+	message := &rocketmq.Message{
+		Topic: topic,
+		Body:  body,
+	}
+	if tag != "" {
+		message.SetTag(tag)
+	}
+	if delay > 0 {
+		message.SetDelayTimestamp(time.Now().Add(delay))
+	}
+
+	if _, err := c.producer.Send(ctx, message); err != nil {
+		return fmt.Errorf("发送消息失败: %w", err)
+	}
+	return nil
+}
+
 // Close 关闭客户端（添加超时控制，避免长时间阻塞）
 func (c *RocketMQClient) Close() error {
 	if !c.enabled {
@@ -2,12 +2,15 @@ package order
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/ledger"
 	"github.com/golang-pay-core/internal/logger"
 	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/outbox"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
@@ -24,11 +27,31 @@ type TenantIDProvider interface {
 	GetTenantIDByMerchantID(ctx context.Context, merchantID int64) (*int64, error)
 }
 
+// StatusChange 订单状态变更（传给事件写入器）
+type StatusChange struct {
+	Order     *models.Order // 变更前的订单
+	OldStatus int
+	NewStatus int
+	TenantID  *int64
+	TicketNo  string
+}
+
+// StatusEventWriter 订单状态变更事件写入器（避免循环依赖，由 mq 包实现）
+// 在订单状态更新的同一事务中写入发件箱事件，订单状态提交即保证事件最终投递
+type StatusEventWriter interface {
+	WriteStatusEvents(tx *gorm.DB, change StatusChange) error
+}
+
+// ErrStatusChanged 订单当前状态不在 UpdateStatusRequest.FromStatuses 中（已被其他流程更新）
+var ErrStatusChanged = errors.New("订单状态已变更")
+
 // UpdateStatusRequest 更新订单状态请求
 type UpdateStatusRequest struct {
 	OrderID  string
 	Status   int
 	TicketNo string
+	// 只更新当前状态属于其中的订单（为空时不限制），否则返回 ErrStatusChanged
+	FromStatuses []int
 }
 
 // UpdateStatusOptions 更新订单状态选项
@@ -37,24 +60,22 @@ type UpdateStatusOptions struct {
 	TenantIDProvider TenantIDProvider
 	// 是否处理码商余额（默认 false）
 	HandleWriteoffBalance bool
+	// 状态变更事件写入器（可选，未启用消息总线时为空）
+	EventWriter StatusEventWriter
 }
 
 // UpdateStatus 更新订单状态的核心逻辑（统一实现，避免代码重复）
 // 此函数可以被 service 和 mq 包复用
 func UpdateStatus(ctx context.Context, req UpdateStatusRequest, opts UpdateStatusOptions) error {
 	// 先查询订单信息（在事务外，减少事务时间）
-	// 需要查询 tax 字段用于租户扣费和流水记录
-	// 需要查询 merchant_id 用于商户预付处理
+	// 需要查询 tax、merchant_id、writeoff_id 等字段用于订单资金记账
 	var order models.Order
-	if err := database.DB.Select("id, merchant_id, money, tax, order_status, pay_channel_id, writeoff_id").
+	if err := database.DB.Select("id, order_no, out_order_no, merchant_id, money, tax, order_status, pay_channel_id, writeoff_id").
 		Where("id = ?", req.OrderID).
 		First(&order).Error; err != nil {
 		return fmt.Errorf("订单不存在: %w", err)
 	}
 
-	// 查询订单详情（用于计算商户实际收入，更新商户预付款）
-	// 注意：realMoney 在订单成功时使用，在事务中查询
-
 	// 检查订单状态是否已经变更（避免重复处理）
 	if order.OrderStatus == req.Status {
 		logger.Logger.Debug("订单状态未变化，跳过更新",
@@ -63,6 +84,9 @@ func UpdateStatus(ctx context.Context, req UpdateStatusRequest, opts UpdateStatu
 			zap.Int("target_status", req.Status))
 		return nil // 状态未变化，直接返回
 	}
+	if len(req.FromStatuses) > 0 && !containsStatus(req.FromStatuses, order.OrderStatus) {
+		return ErrStatusChanged
+	}
 
 	logger.Logger.Info("准备更新订单状态",
 		zap.String("order_id", req.OrderID),
@@ -92,94 +116,93 @@ func UpdateStatus(ctx context.Context, req UpdateStatusRequest, opts UpdateStatu
 
 	now := time.Now()
 
-	// 处理租户余额（在事务中，确保一致性）
-	if tenantID != nil && opts.PreTaxReleaser != nil {
-		// 根据订单状态处理预占余额和余额
-		switch req.Status {
-		case models.OrderStatusPaid:
-			// 订单支付成功：从数据库扣减余额，从 Redis 释放预占
-			// 根据文档：租户余额扣费公式 = 原余额 - 租户手续费
-			// 扣费金额是 tax（手续费），而不是 money（订单金额）
-			// 先查询变更前的余额（使用 SELECT FOR UPDATE 确保一致性），用于记录流水
-			var tenant models.Tenant
-			if err := tx.Set("gorm:query_option", "FOR UPDATE").
-				Where("id = ?", *tenantID).First(&tenant).Error; err != nil {
-				tx.Rollback()
-				return fmt.Errorf("查询租户余额失败: %w", err)
-			}
-
-			oldBalance := tenant.Balance
-			// 扣费金额是手续费 tax，而不是订单金额 money
-			taxAmount := int64(order.Tax)
+	// 订单资金记账（在事务中，确保一致性）
+	// 租户手续费、核销余额、商户预付款统一由账本记账，同时生成租户/核销兼容流水
+	// 只有预占余额释放器可用时才处理租户手续费（与 Redis 预占保持一致）
+	ledgerEvent := ledger.OrderEvent{
+		Order:    &order,
+		Writeoff: opts.HandleWriteoffBalance,
+	}
+	if opts.PreTaxReleaser != nil {
+		ledgerEvent.TenantID = tenantID
+	}
 
-			// 根据文档：只有当 tenant 存在且 tax != 0 时才扣费和记录流水
-			// 如果手续费为0，不会记录流水
-			if taxAmount != 0 {
-				newBalance := oldBalance - taxAmount
+	var postings []models.LedgerPosting
+	var ledgerErr error
+	switch req.Status {
+	case models.OrderStatusPaid:
+		postings, ledgerErr = ledger.PostOrderPaid(tx, ledgerEvent)
+	case models.OrderStatusRefunded:
+		postings, ledgerErr = ledger.PostOrderRefund(tx, ledgerEvent)
+	}
+	if errors.Is(ledgerErr, ledger.ErrDuplicate) {
+		// 同一订单事件只记账一次（如状态在成功状态之间反复变更）
+		logger.Logger.Info("订单资金已记账，跳过",
+			zap.String("order_id", req.OrderID),
+			zap.Int("status", req.Status))
+	} else if ledgerErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("订单资金记账失败: %w", ledgerErr)
+	} else if len(postings) > 0 {
+		logger.Logger.Info("订单资金已记账",
+			zap.String("order_id", req.OrderID),
+			zap.Int("status", req.Status),
+			zap.Int("postings", len(postings)),
+			zap.Int("tax", order.Tax),
+			zap.Int("money", order.Money))
+	}
 
-				// 使用原子操作扣减余额（扣减手续费 tax）
-				if err := tx.Model(&models.Tenant{}).
-					Where("id = ?", *tenantID).
-					Update("balance", gorm.Expr("balance - ?", order.Tax)).Error; err != nil {
-					tx.Rollback()
-					return fmt.Errorf("扣减租户余额失败: %w", err)
-				}
+	// 更新订单状态（合并多个字段更新，减少数据库往返）
+	updates := map[string]interface{}{
+		"order_status":    req.Status,
+		"update_datetime": &now,
+	}
 
-				// 重新查询扣减后的余额（与 Python 代码保持一致）
-				var updatedTenant models.Tenant
-				if err := tx.Select("balance").Where("id = ?", *tenantID).First(&updatedTenant).Error; err != nil {
-					tx.Rollback()
-					return fmt.Errorf("查询扣减后余额失败: %w", err)
-				}
-				newBalance = updatedTenant.Balance
+	if req.Status == models.OrderStatusPaid {
+		updates["pay_datetime"] = &now
+	}
 
-				// 记录租户资金流水
-				// 根据文档：租户流水 flow_type=1 表示消费（订单手续费）
-				// 只有当 tax != 0 时才记录流水
-				cashflow := &models.TenantCashflow{
-					OldMoney:       oldBalance,
-					NewMoney:       newBalance,
-					ChangeMoney:    -taxAmount,                       // 负数表示扣减，扣减的是手续费
-					FlowType:       models.TenantCashflowTypeConsume, // 1=消费（订单手续费）
-					OrderID:        &req.OrderID,
-					PayChannelID:   order.PayChannelID,
-					TenantID:       *tenantID,
-					CreateDatetime: &now,
-				}
-				if err := tx.Create(cashflow).Error; err != nil {
-					tx.Rollback()
-					return fmt.Errorf("记录租户资金流水失败: %w", err)
-				}
+	query := tx.Model(&models.Order{}).Where("id = ?", req.OrderID)
+	if len(req.FromStatuses) > 0 {
+		query = query.Where("order_status IN ?", req.FromStatuses)
+	}
+	result := query.Updates(updates)
+	if result.Error != nil {
+		tx.Rollback()
+		return fmt.Errorf("更新订单状态失败: %w", result.Error)
+	}
+	rowsAffected := result.RowsAffected
+	if rowsAffected == 0 {
+		tx.Rollback()
+		if len(req.FromStatuses) > 0 {
+			return ErrStatusChanged
+		}
+		return fmt.Errorf("更新订单状态失败：未找到订单或状态未变化")
+	}
 
-				logger.Logger.Info("订单支付成功，已扣减租户余额",
-					zap.String("order_id", req.OrderID),
-					zap.Int64("tenant_id", *tenantID),
-					zap.Int64("old_balance", oldBalance),
-					zap.Int64("new_balance", newBalance),
-					zap.Int("tax", order.Tax),
-					zap.Int("money", order.Money))
-			} else {
-				// 手续费为0，不扣费也不记录流水
-				logger.Logger.Debug("租户手续费为0，跳过扣费和流水记录",
-					zap.String("order_id", req.OrderID),
-					zap.Int64("tenant_id", *tenantID))
-			}
+	logger.Logger.Info("订单状态更新成功",
+		zap.String("order_id", req.OrderID),
+		zap.Int("old_status", order.OrderStatus),
+		zap.Int("new_status", req.Status),
+		zap.Int64("rows_affected", rowsAffected))
 
-			// 从 Redis 释放预占余额（释放的是手续费 tax）
-			if err := opts.PreTaxReleaser.ReleasePreTax(ctx, *tenantID, taxAmount); err != nil {
-				// 如果 Redis 释放失败，记录日志但不回滚数据库事务（数据库已扣减）
+	// 处理 Redis 预占余额（预占的是手续费 tax，而不是订单金额 money）
+	// 在订单状态更新之后处理，状态已变更（未更新）时不会重复释放
+	if tenantID != nil && opts.PreTaxReleaser != nil {
+		switch req.Status {
+		case models.OrderStatusPaid:
+			// 订单支付成功：手续费已从数据库扣减，释放 Redis 预占
+			// 如果 Redis 释放失败，记录日志但不回滚数据库事务
+			if err := opts.PreTaxReleaser.ReleasePreTax(ctx, *tenantID, int64(order.Tax)); err != nil {
 				logger.Logger.Warn("释放预占余额失败",
 					zap.Int64("tenant_id", *tenantID),
-					zap.Int64("tax_amount", taxAmount),
+					zap.Int("tax", order.Tax),
 					zap.Error(err))
 			}
 
-			// 注意：日志已在 taxAmount != 0 的分支中记录
-
 		case models.OrderStatusFailed, models.OrderStatusClosed:
 			// 订单失败/取消/过期/关闭：只从 Redis 释放预占，不扣减余额
 			// 注意：OrderStatusCancelled 是 OrderStatusClosed 的别名，值相同，所以不需要单独列出
-			// 释放的是手续费 tax，而不是订单金额 money
 			if err := opts.PreTaxReleaser.ReleasePreTax(ctx, *tenantID, int64(order.Tax)); err != nil {
 				tx.Rollback()
 				return fmt.Errorf("释放预占余额失败: %w", err)
@@ -193,414 +216,6 @@ func UpdateStatus(ctx context.Context, req UpdateStatusRequest, opts UpdateStatu
 		}
 	}
 
-	// 处理码商余额（在事务中，确保一致性）
-	// 根据文档：核销余额扣费公式 = 原余额 - 实际扣除金额
-	// 实际扣除金额 = 订单金额 - 最终核销手续费
-	// 支持多级核销：从最终核销向上遍历所有上级核销，计算费率差并给上级核销增加余额
-	if opts.HandleWriteoffBalance && order.WriteoffID != nil {
-		switch req.Status {
-		case models.OrderStatusPaid:
-			// 订单支付成功：处理多级核销逻辑
-			// 1. 获取所有上级核销（从最终核销向上遍历）
-			writeoffList := []models.Writeoff{}
-			currentWriteoffID := *order.WriteoffID
-
-			// 从最终核销开始，向上遍历所有上级核销
-			for {
-				var writeoff models.Writeoff
-				if err := tx.Where("id = ?", currentWriteoffID).First(&writeoff).Error; err != nil {
-					break // 如果查询失败，停止遍历
-				}
-				writeoffList = append(writeoffList, writeoff)
-
-				// 如果有上级核销，继续向上遍历
-				if writeoff.ParentWriteoffID != nil {
-					currentWriteoffID = *writeoff.ParentWriteoffID
-				} else {
-					break // 没有上级核销，停止遍历
-				}
-			}
-
-			// 2. 收集每级核销的费率
-			writeoffTaxList := make([]float64, len(writeoffList))
-			if order.PayChannelID != nil {
-				for i, w := range writeoffList {
-					var writeoffChannel struct {
-						Tax float64 `gorm:"column:tax"`
-					}
-					if err := tx.Table("dvadmin_writeoff_pay_channel").
-						Select("tax").
-						Where("writeoff_id = ? AND pay_channel_id = ?", w.ID, *order.PayChannelID).
-						Scan(&writeoffChannel).Error; err == nil {
-						writeoffTaxList[i] = writeoffChannel.Tax
-					}
-					// 如果查询失败，税率为 0
-				}
-			}
-
-			// 3. 处理最终核销（扣减余额）
-			// 最终核销是 writeoffList[0]（第一个，即下单的核销）
-			if len(writeoffList) > 0 {
-				finalWriteoff := writeoffList[0]
-				finalWriteoffTax := writeoffTaxList[0]
-
-				// 先查询变更前的余额（使用 SELECT FOR UPDATE 确保一致性）
-				var writeoff models.Writeoff
-				if err := tx.Set("gorm:query_option", "FOR UPDATE").
-					Where("id = ?", finalWriteoff.ID).First(&writeoff).Error; err != nil {
-					tx.Rollback()
-					return fmt.Errorf("查询码商余额失败: %w", err)
-				}
-
-				// 计算最终核销手续费和实际扣除金额
-				// 最终核销手续费 = int(最终核销费率 * 订单金额 / 100)
-				// 实际扣除金额 = 订单金额 - 最终核销手续费
-				finalWriteoffTaxAmount := int64(finalWriteoffTax * float64(order.Money) / 100.0)
-				realDeductAmount := int64(order.Money) - finalWriteoffTaxAmount
-
-				// 记录码商资金流水（无论余额是否为 NULL，都需要记录流水以便追踪）
-				// 根据文档：old_money = before if before is not None else 0
-				// new_money = writeoff.balance if writeoff.balance is not None else 0（扣减后的余额）
-				var oldBalance, newBalance int64
-				if writeoff.Balance != nil {
-					// 有余额限制：保存扣减前的余额
-					oldBalance = *writeoff.Balance
-
-					// 使用原子操作扣减余额（扣减实际扣除金额，而不是订单金额）
-					if err := tx.Model(&models.Writeoff{}).
-						Where("id = ? AND balance IS NOT NULL", finalWriteoff.ID).
-						Update("balance", gorm.Expr("balance - ?", realDeductAmount)).Error; err != nil {
-						tx.Rollback()
-						return fmt.Errorf("扣减码商余额失败: %w", err)
-					}
-
-					// 重新查询扣减后的余额（与 Python 代码保持一致）
-					var updatedWriteoff models.Writeoff
-					if err := tx.Select("balance").Where("id = ?", finalWriteoff.ID).First(&updatedWriteoff).Error; err != nil {
-						tx.Rollback()
-						return fmt.Errorf("查询扣减后余额失败: %w", err)
-					}
-					if updatedWriteoff.Balance != nil {
-						newBalance = *updatedWriteoff.Balance
-					} else {
-						newBalance = 0
-					}
-				} else {
-					// 余额无限制：old_money 和 new_money 都设置为 0（表示无限制）
-					// 根据文档：如果 balance is None，则 old_money=0, new_money=0
-					oldBalance = 0
-					newBalance = 0
-				}
-
-				// 记录最终核销资金流水
-				// 根据文档：核销流水 flow_type=1 表示跑量（订单扣减）
-				cashflow := &models.WriteoffCashflow{
-					OldMoney:       oldBalance,
-					NewMoney:       newBalance,
-					ChangeMoney:    -realDeductAmount,                    // 负数表示扣减，扣减的是实际扣除金额
-					FlowType:       models.WriteoffCashflowTypeRunVolume, // 1=跑量
-					Tax:            finalWriteoffTax,                     // 核销费率
-					OrderID:        &req.OrderID,
-					PayChannelID:   order.PayChannelID,
-					WriteoffID:     finalWriteoff.ID,
-					CreateDatetime: &now,
-				}
-				if err := tx.Create(cashflow).Error; err != nil {
-					tx.Rollback()
-					return fmt.Errorf("记录码商资金流水失败: %w", err)
-				}
-
-				logger.Logger.Info("订单支付成功，已扣减最终核销余额",
-					zap.String("order_id", req.OrderID),
-					zap.Int64("writeoff_id", finalWriteoff.ID),
-					zap.Int64("old_balance", oldBalance),
-					zap.Int64("new_balance", newBalance),
-					zap.Int64("real_deduct_amount", realDeductAmount),
-					zap.Int64("writeoff_tax_amount", finalWriteoffTaxAmount),
-					zap.Float64("writeoff_tax_rate", finalWriteoffTax),
-					zap.Int("money", order.Money))
-			}
-
-			// 4. 处理上级核销（增加余额）
-			// 从 writeoffList[1] 开始（第二个开始是上级核销）
-			// 计算每级核销的费率差（上级费率 - 下级费率）
-			for i := 1; i < len(writeoffList); i++ {
-				parentWriteoff := writeoffList[i]
-				parentTax := writeoffTaxList[i]
-				childTax := writeoffTaxList[i-1]
-
-				// 计算费率差：上级费率 - 下级费率
-				taxDiff := parentTax - childTax
-				if taxDiff > 0 {
-					// 上级核销获得收益：int(费率差 * 订单金额 / 100)
-					parentTaxAmount := int64(taxDiff * float64(order.Money) / 100.0)
-
-					// 先查询变更前的余额（使用 SELECT FOR UPDATE 确保一致性）
-					var writeoff models.Writeoff
-					if err := tx.Set("gorm:query_option", "FOR UPDATE").
-						Where("id = ?", parentWriteoff.ID).First(&writeoff).Error; err != nil {
-						tx.Rollback()
-						return fmt.Errorf("查询上级核销余额失败: %w", err)
-					}
-
-					// 记录上级核销资金流水（无论余额是否为 NULL，都需要记录流水）
-					// 根据文档：old_money = before if before is not None else 0
-					// new_money = writeoff.balance if writeoff.balance is not None else 0（增加后的余额）
-					var oldBalance, newBalance int64
-					if writeoff.Balance != nil {
-						// 有余额限制：保存增加前的余额
-						oldBalance = *writeoff.Balance
-
-						// 使用原子操作增加余额（上级核销获得收益）
-						if err := tx.Model(&models.Writeoff{}).
-							Where("id = ? AND balance IS NOT NULL", parentWriteoff.ID).
-							Update("balance", gorm.Expr("balance + ?", parentTaxAmount)).Error; err != nil {
-							tx.Rollback()
-							return fmt.Errorf("增加上级核销余额失败: %w", err)
-						}
-
-						// 重新查询增加后的余额（与 Python 代码保持一致）
-						var updatedWriteoff models.Writeoff
-						if err := tx.Select("balance").Where("id = ?", parentWriteoff.ID).First(&updatedWriteoff).Error; err != nil {
-							tx.Rollback()
-							return fmt.Errorf("查询增加后余额失败: %w", err)
-						}
-						if updatedWriteoff.Balance != nil {
-							newBalance = *updatedWriteoff.Balance
-						} else {
-							newBalance = 0
-						}
-					} else {
-						// 余额无限制：old_money 和 new_money 都设置为 0（表示无限制）
-						// 根据文档：如果 balance is None，则 old_money=0, new_money=0
-						oldBalance = 0
-						newBalance = 0
-					}
-
-					// 记录上级核销资金流水
-					// 根据文档：核销流水 flow_type=7 表示下级收益（上级核销获得）
-					// ChangeMoney 字段：负数=扣减，正数=增加，所以这里用正数
-					cashflow := &models.WriteoffCashflow{
-						OldMoney:       oldBalance,
-						NewMoney:       newBalance,
-						ChangeMoney:    parentTaxAmount,                      // 正数表示增加
-						FlowType:       models.WriteoffCashflowTypeSubProfit, // 7=下级收益
-						Tax:            taxDiff,                              // 费率差
-						OrderID:        &req.OrderID,
-						PayChannelID:   order.PayChannelID,
-						WriteoffID:     parentWriteoff.ID,
-						CreateDatetime: &now,
-					}
-					if err := tx.Create(cashflow).Error; err != nil {
-						tx.Rollback()
-						return fmt.Errorf("记录上级核销资金流水失败: %w", err)
-					}
-
-					logger.Logger.Info("订单支付成功，已增加上级核销余额",
-						zap.String("order_id", req.OrderID),
-						zap.Int64("parent_writeoff_id", parentWriteoff.ID),
-						zap.Int64("old_balance", oldBalance),
-						zap.Int64("new_balance", newBalance),
-						zap.Int64("parent_tax_amount", parentTaxAmount),
-						zap.Float64("tax_diff", taxDiff),
-						zap.Float64("parent_tax", parentTax),
-						zap.Float64("child_tax", childTax))
-				}
-			}
-		case models.OrderStatusRefunded:
-			// 订单退款：需要回退核销余额和统计
-			// 根据文档：只有 order_before in [4, 6] 的订单才能退款（成功订单）
-			// 查找订单成功时的流水记录（flow_type=1，跑量流水）
-			if order.WriteoffID != nil && order.PayChannelID != nil {
-				var cashflow models.WriteoffCashflow
-				if err := tx.Where("order_id = ? AND writeoff_id = ? AND flow_type = ?", req.OrderID, *order.WriteoffID, models.WriteoffCashflowTypeRunVolume).
-					First(&cashflow).Error; err == nil {
-					// 回退余额：增加余额（因为 ChangeMoney 是负数，所以用负数回退）
-					// ChangeMoney 是负数（扣减），所以回退时应该增加，即 -ChangeMoney
-					realDeductAmount := -cashflow.ChangeMoney
-
-					// 查询变更前的余额（使用 SELECT FOR UPDATE 确保一致性）
-					var writeoff models.Writeoff
-					if err := tx.Set("gorm:query_option", "FOR UPDATE").
-						Where("id = ?", *order.WriteoffID).First(&writeoff).Error; err == nil {
-						var oldBalance, newBalance int64
-						if writeoff.Balance != nil {
-							oldBalance = *writeoff.Balance
-
-							// 回退余额：增加余额
-							if err := tx.Model(&models.Writeoff{}).
-								Where("id = ? AND balance IS NOT NULL", *order.WriteoffID).
-								Update("balance", gorm.Expr("balance + ?", realDeductAmount)).Error; err != nil {
-								tx.Rollback()
-								return fmt.Errorf("回退码商余额失败: %w", err)
-							}
-
-							// 重新查询回退后的余额
-							var updatedWriteoff models.Writeoff
-							if err := tx.Select("balance").Where("id = ?", *order.WriteoffID).First(&updatedWriteoff).Error; err == nil {
-								if updatedWriteoff.Balance != nil {
-									newBalance = *updatedWriteoff.Balance
-								} else {
-									newBalance = 0
-								}
-							}
-						} else {
-							oldBalance = 0
-							newBalance = 0
-						}
-
-						// 记录退款流水
-						refundCashflow := &models.WriteoffCashflow{
-							OldMoney:       oldBalance,
-							NewMoney:       newBalance,
-							ChangeMoney:    realDeductAmount,                  // 正数表示增加（回退）
-							FlowType:       models.WriteoffCashflowTypeRefund, // 8=订单退款
-							Tax:            cashflow.Tax,                      // 使用原流水的费率
-							OrderID:        &req.OrderID,
-							PayChannelID:   order.PayChannelID,
-							WriteoffID:     *order.WriteoffID,
-							CreateDatetime: &now,
-						}
-						if err := tx.Create(refundCashflow).Error; err != nil {
-							tx.Rollback()
-							return fmt.Errorf("记录码商退款流水失败: %w", err)
-						}
-
-						logger.Logger.Info("订单退款，已回退核销余额",
-							zap.String("order_id", req.OrderID),
-							zap.Int64("writeoff_id", *order.WriteoffID),
-							zap.Int64("old_balance", oldBalance),
-							zap.Int64("new_balance", newBalance),
-							zap.Int64("real_deduct_amount", realDeductAmount))
-					}
-				}
-			}
-		case models.OrderStatusFailed, models.OrderStatusClosed:
-			// 订单失败/取消/过期：码商余额不需要处理（码商没有预占余额的概念）
-			// 码商余额只在支付成功时扣减
-			// 注意：OrderStatusCancelled 是 OrderStatusClosed 的别名，值相同，所以不需要单独列出
-		}
-	}
-
-	// 处理商户预付款（在事务中，确保一致性）
-	// 根据文档：
-	// - 订单成功时：更新商户预付款（减少预付款），新预付款 = 原预付款 + (-real_money) = 原预付款 - real_money
-	// - 订单退款时：更新商户预付款（增加预付款），新预付款 = 原预付款 + real_money
-	if (req.Status == models.OrderStatusPaid || req.Status == models.OrderStatusRefunded) && order.MerchantID != nil {
-		// 查询订单详情以计算实际收入
-		var orderDetail models.OrderDetail
-		if err := tx.Select("notify_money, merchant_tax").
-			Where("order_id = ?", req.OrderID).
-			First(&orderDetail).Error; err != nil {
-			tx.Rollback()
-			return fmt.Errorf("查询订单详情失败: %w", err)
-		}
-
-		// 计算实际收入：实际收入 = 通知金额 - 商户手续费
-		// 根据文档：real_money = notify_money - merchant_tax
-		realMoney := int64(orderDetail.NotifyMoney) - int64(orderDetail.MerchantTax)
-
-		// 只有当实际收入大于0时才更新预付款
-		if realMoney > 0 {
-			// 根据订单状态决定预付款变更方向
-			var changeAmount int64
-			var actionDesc string
-			if req.Status == models.OrderStatusPaid {
-				// 订单成功：减少预付款（传入负数）
-				changeAmount = -realMoney
-				actionDesc = "订单支付成功，减少商户预付款"
-			} else if req.Status == models.OrderStatusRefunded {
-				// 订单退款：增加预付款（传入正数）
-				changeAmount = realMoney
-				actionDesc = "订单退款，增加商户预付款"
-			} else {
-				// 其他状态不处理
-				return nil
-			}
-
-			// 查询或创建商户预付款记录
-			var merchantPre models.MerchantPre
-			if err := tx.Where("merchant_id = ?", *order.MerchantID).First(&merchantPre).Error; err != nil {
-				if err == gorm.ErrRecordNotFound {
-					// 如果不存在，创建新的预付款记录
-					// 注意：订单退款时不应该创建新记录（因为订单成功时应该已经创建）
-					// 但如果订单成功时没有创建，这里也会创建
-					merchantPre = models.MerchantPre{
-						MerchantID: *order.MerchantID,
-						PrePay:     changeAmount, // 初始值
-						Ver:        1,
-					}
-					if err := tx.Create(&merchantPre).Error; err != nil {
-						tx.Rollback()
-						return fmt.Errorf("创建商户预付款失败: %w", err)
-					}
-					logger.Logger.Info(actionDesc,
-						zap.String("order_id", req.OrderID),
-						zap.Int64("merchant_id", *order.MerchantID),
-						zap.Int64("pre_pay", merchantPre.PrePay),
-						zap.Int64("real_money", realMoney),
-						zap.Int64("change_amount", changeAmount))
-				} else {
-					tx.Rollback()
-					return fmt.Errorf("查询商户预付款失败: %w", err)
-				}
-			} else {
-				// 更新预付款：增加或减少
-				// 根据文档：m.pre_pay += real_money（real_money 为负数时减少，为正数时增加）
-				oldPrePay := merchantPre.PrePay
-				if err := tx.Model(&models.MerchantPre{}).
-					Where("merchant_id = ?", *order.MerchantID).
-					Update("pre_pay", gorm.Expr("pre_pay + ?", changeAmount)).Error; err != nil {
-					tx.Rollback()
-					return fmt.Errorf("更新商户预付款失败: %w", err)
-				}
-
-				// 重新查询更新后的预付款
-				var updatedMerchantPre models.MerchantPre
-				if err := tx.Select("pre_pay").Where("merchant_id = ?", *order.MerchantID).First(&updatedMerchantPre).Error; err != nil {
-					tx.Rollback()
-					return fmt.Errorf("查询更新后预付款失败: %w", err)
-				}
-
-				logger.Logger.Info(actionDesc,
-					zap.String("order_id", req.OrderID),
-					zap.Int64("merchant_id", *order.MerchantID),
-					zap.Int64("old_pre_pay", oldPrePay),
-					zap.Int64("new_pre_pay", updatedMerchantPre.PrePay),
-					zap.Int64("real_money", realMoney),
-					zap.Int64("change_amount", changeAmount))
-			}
-		}
-	}
-
-	// 更新订单状态（合并多个字段更新，减少数据库往返）
-	updates := map[string]interface{}{
-		"order_status":    req.Status,
-		"update_datetime": &now,
-	}
-
-	if req.Status == models.OrderStatusPaid {
-		updates["pay_datetime"] = &now
-	}
-
-	rowsAffected := tx.Model(&models.Order{}).
-		Where("id = ?", req.OrderID).
-		Updates(updates).RowsAffected
-	if rowsAffected == 0 {
-		tx.Rollback()
-		return fmt.Errorf("更新订单状态失败：未找到订单或状态未变化")
-	}
-	if err := tx.Error; err != nil {
-		tx.Rollback()
-		return fmt.Errorf("更新订单状态失败: %w", err)
-	}
-
-	logger.Logger.Info("订单状态更新成功",
-		zap.String("order_id", req.OrderID),
-		zap.Int("old_status", order.OrderStatus),
-		zap.Int("new_status", req.Status),
-		zap.Int64("rows_affected", rowsAffected))
-
 	// 更新订单详情的 ticket_no（如果提供）
 	if req.TicketNo != "" {
 		if err := tx.Model(&models.OrderDetail{}).
@@ -611,20 +226,47 @@ func UpdateStatus(ctx context.Context, req UpdateStatusRequest, opts UpdateStatu
 		}
 	}
 
+	// 写入状态变更事件（发件箱，与订单状态同时提交）
+	if opts.EventWriter != nil {
+		if err := opts.EventWriter.WriteStatusEvents(tx, StatusChange{
+			Order:     &order,
+			OldStatus: order.OrderStatus,
+			NewStatus: req.Status,
+			TenantID:  tenantID,
+			TicketNo:  req.TicketNo,
+		}); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("写入订单事件失败: %w", err)
+		}
+	}
+
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		return fmt.Errorf("提交事务失败: %w", err)
 	}
+	if opts.EventWriter != nil {
+		outbox.Kick()
+	}
 
 	// 如果订单状态更新为"支付成功，通知未返回"或"支付成功，通知已返回"，触发成功钩子
 	// 注意：在事务提交后异步触发，避免影响主流程
-	// 为了避免循环依赖，这里只记录日志，实际触发逻辑应该在调用方处理
+	// 为了避免循环依赖，成功钩子由调用方触发；订单通知、日统计事件已通过 EventWriter 写入发件箱，进程崩溃也不会丢失，
+	// 日统计由消息队列消费端按 (订单ID, 状态) 幂等更新，调用方不再重复统计
 	if req.Status == models.OrderStatusPaidNoNotify || req.Status == models.OrderStatusPaid {
 		logger.Logger.Info("订单状态更新为成功，需要在调用方触发成功钩子",
 			zap.String("order_id", req.OrderID),
 			zap.Int("status", req.Status))
-		// TODO: 通过消息队列或事件系统触发成功钩子，避免循环依赖
 	}
 
 	return nil
 }
+
+// containsStatus 状态是否在列表中
+func containsStatus(statuses []int, status int) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang-pay-core/internal/models"
+	"gorm.io/gorm"
+)
+
+// Event 发件箱事件
+type Event struct {
+	Topic   string        // 主题
+	Tag     string        // 标签
+	Key     string        // 业务键（如订单ID，便于排查）
+	Body    interface{}   // 消息体（序列化为 JSON，[]byte 原样写入）
+	Delayed bool          // 是否以延迟消息发送
+	Delay   time.Duration // 相对写入时间的延迟（Delayed 为 true 时有效）
+}
+
+// Publisher 消息发布者（mq.Bus 实现该接口，避免 outbox 依赖 mq 包）
+type Publisher interface {
+	Publish(ctx context.Context, topic, tag string, body interface{}) error
+	PublishDelayed(ctx context.Context, topic, tag string, body interface{}, delay time.Duration) error
+}
+
+// Add 在事务中写入发件箱事件
+// tx 必须是业务数据所在的事务：业务数据提交时事件一并提交，回滚时一并回滚，由中继保证事件最终投递到消息总线
+func Add(tx *gorm.DB, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	rows := make([]models.OutboxEvent, 0, len(events))
+	for _, event := range events {
+		body, err := marshalBody(event.Body)
+		if err != nil {
+			return err
+		}
+		deliverAt := now
+		if event.Delayed && event.Delay > 0 {
+			deliverAt = now.Add(event.Delay)
+		}
+		rows = append(rows, models.OutboxEvent{
+			Topic:             event.Topic,
+			Tag:               event.Tag,
+			EventKey:          event.Key,
+			Body:              string(body),
+			Delayed:           event.Delayed,
+			DeliverDatetime:   deliverAt,
+			Status:            models.OutboxStatusPending,
+			NextRetryDatetime: now,
+			CreateDatetime:    &now,
+			UpdateDatetime:    &now,
+		})
+	}
+
+	if err := tx.Create(&rows).Error; err != nil {
+		return fmt.Errorf("写入发件箱事件失败: %w", err)
+	}
+	return nil
+}
+
+// marshalBody 序列化消息体
+func marshalBody(body interface{}) ([]byte, error) {
+	if raw, ok := body.([]byte); ok {
+		return raw, nil
+	}
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("序列化发件箱事件失败: %w", err)
+	}
+	return bodyBytes, nil
+}
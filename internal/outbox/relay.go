@@ -0,0 +1,249 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang-pay-core/config"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 默认中继配置
+const (
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 100
+	defaultRetention    = 72 * time.Hour
+
+	// relayMaxBackoff 发送失败后的最大重试间隔
+	relayMaxBackoff = 5 * time.Minute
+	// relayErrorMaxLen 记录的发送错误最大长度
+	relayErrorMaxLen = 1000
+	// cleanupInterval 清理已发送事件的间隔
+	cleanupInterval = time.Hour
+	// cleanupBatch 单次清理的最大事件数
+	cleanupBatch = 1000
+)
+
+var outboxEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "outbox_events_total",
+	Help: "发件箱事件发送结果计数",
+}, []string{"topic", "result"})
+
+// kickCh 通知中继立即发送（事务提交后调用 Kick，避免等待下一次轮询）
+var kickCh = make(chan struct{}, 1)
+
+// Kick 通知中继立即发送待发送的事件（非阻塞）
+func Kick() {
+	select {
+	case kickCh <- struct{}{}:
+	default:
+	}
+}
+
+// Relay 发件箱中继：轮询待发送的事件并发布到消息总线，发布成功后标记为已发送
+// 多实例同时运行时使用 SELECT ... FOR UPDATE SKIP LOCKED 分摊事件，同一事件不会被并发发送；
+// 发布成功但标记失败时事件会被再次发送，由消费端幂等处理
+type Relay struct {
+	publisher    Publisher
+	pollInterval time.Duration
+	batchSize    int
+	retention    time.Duration
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	done    chan struct{}
+	cleaned time.Time
+}
+
+// NewRelay 创建发件箱中继
+func NewRelay(publisher Publisher) *Relay {
+	relay := &Relay{
+		publisher:    publisher,
+		pollInterval: defaultPollInterval,
+		batchSize:    defaultBatchSize,
+		retention:    defaultRetention,
+	}
+	if cfg := config.GetConfig(); cfg != nil {
+		if cfg.Outbox.PollInterval > 0 {
+			relay.pollInterval = cfg.Outbox.PollInterval
+		}
+		if cfg.Outbox.BatchSize > 0 {
+			relay.batchSize = cfg.Outbox.BatchSize
+		}
+		if cfg.Outbox.Retention > 0 {
+			relay.retention = cfg.Outbox.Retention
+		}
+	}
+	return relay
+}
+
+// Start 启动中继（重复调用无效）
+func (r *Relay) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		return
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go r.run(runCtx)
+
+	logger.Logger.Info("发件箱中继已启动",
+		zap.Duration("poll_interval", r.pollInterval),
+		zap.Int("batch_size", r.batchSize))
+}
+
+// Stop 停止中继并等待当前批次完成
+func (r *Relay) Stop() {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+
+	select {
+	case <-done:
+		logger.Logger.Info("发件箱中继已停止")
+	case <-time.After(5 * time.Second):
+		logger.Logger.Warn("停止发件箱中继超时（5秒）")
+	}
+}
+
+// run 中继主循环
+func (r *Relay) run(ctx context.Context) {
+	defer close(r.done)
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-kickCh:
+		}
+
+		// 一次发满说明还有积压，继续发送
+		for ctx.Err() == nil {
+			sent, err := r.relayBatch(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Logger.Warn("发送发件箱事件失败", zap.Error(err))
+				}
+				break
+			}
+			if sent < r.batchSize {
+				break
+			}
+		}
+
+		if time.Since(r.cleaned) >= cleanupInterval {
+			r.cleaned = time.Now()
+			r.cleanup(ctx)
+		}
+	}
+}
+
+// relayBatch 发送一批到期的事件，返回本批处理的事件数
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	var events []models.OutboxEvent
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_retry_datetime <= ?", models.OutboxStatusPending, now).
+			Order("id").
+			Limit(r.batchSize).
+			Find(&events).Error; err != nil {
+			return err
+		}
+
+		for i := range events {
+			event := &events[i]
+			updates := map[string]interface{}{
+				"attempts":        event.Attempts + 1,
+				"update_datetime": now,
+			}
+			if err := r.publish(ctx, event, now); err != nil {
+				outboxEventsTotal.WithLabelValues(event.Topic, "retry").Inc()
+				backoff := relayBackoff(event.Attempts + 1)
+				errText := err.Error()
+				if len(errText) > relayErrorMaxLen {
+					errText = errText[:relayErrorMaxLen]
+				}
+				updates["last_error"] = errText
+				updates["next_retry_datetime"] = now.Add(backoff)
+				logger.Logger.Warn("发件箱事件发送失败，稍后重试",
+					zap.Int64("event_id", event.ID),
+					zap.String("topic", event.Topic),
+					zap.String("event_key", event.EventKey),
+					zap.Int("attempts", event.Attempts+1),
+					zap.Duration("backoff", backoff),
+					zap.Error(err))
+			} else {
+				outboxEventsTotal.WithLabelValues(event.Topic, "sent").Inc()
+				updates["status"] = models.OutboxStatusSent
+				updates["sent_datetime"] = now
+			}
+			if err := tx.Model(&models.OutboxEvent{}).Where("id = ?", event.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return len(events), err
+}
+
+// publish 发布单个事件（延迟事件按剩余延迟发送）
+func (r *Relay) publish(ctx context.Context, event *models.OutboxEvent, now time.Time) error {
+	body := []byte(event.Body)
+	if !event.Delayed {
+		return r.publisher.Publish(ctx, event.Topic, event.Tag, body)
+	}
+	delay := event.DeliverDatetime.Sub(now)
+	if delay < 0 {
+		delay = 0
+	}
+	return r.publisher.PublishDelayed(ctx, event.Topic, event.Tag, body, delay)
+}
+
+// cleanup 删除超过保留时间的已发送事件
+func (r *Relay) cleanup(ctx context.Context) {
+	before := time.Now().Add(-r.retention)
+	for ctx.Err() == nil {
+		result := database.DB.WithContext(ctx).
+			Where("status = ? AND sent_datetime < ?", models.OutboxStatusSent, before).
+			Limit(cleanupBatch).
+			Delete(&models.OutboxEvent{})
+		if result.Error != nil {
+			logger.Logger.Warn("清理已发送的发件箱事件失败", zap.Error(result.Error))
+			return
+		}
+		if result.RowsAffected < cleanupBatch {
+			return
+		}
+	}
+}
+
+// relayBackoff 第 attempts 次发送失败后的重试间隔（1s、2s、4s……最大 5 分钟）
+func relayBackoff(attempts int) time.Duration {
+	if attempts > 9 {
+		return relayMaxBackoff
+	}
+	backoff := time.Second << uint(attempts-1)
+	if backoff > relayMaxBackoff {
+		return relayMaxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,112 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// fakePublisher 记录发布的消息，err 不为空时发布失败
+type fakePublisher struct {
+	err       error
+	published []string
+	delays    []time.Duration
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, topic, tag string, body interface{}) error {
+	return p.PublishDelayed(ctx, topic, tag, body, 0)
+}
+
+func (p *fakePublisher) PublishDelayed(ctx context.Context, topic, tag string, body interface{}, delay time.Duration) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, topic+":"+string(body.([]byte)))
+	p.delays = append(p.delays, delay)
+	return nil
+}
+
+// setupTestDB 设置测试数据库并替换全局连接
+func setupTestDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.OutboxEvent{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+	oldDB, oldLogger := database.DB, logger.Logger
+	database.DB, logger.Logger = db, zap.NewNop()
+	t.Cleanup(func() {
+		database.DB, logger.Logger = oldDB, oldLogger
+	})
+}
+
+func TestRelayBatch(t *testing.T) {
+	setupTestDB(t)
+
+	// 只有事务提交的事件才会被发送
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return Add(tx, Event{Topic: "order-notify", Key: "o1", Body: map[string]string{"order_id": "o1"}})
+	})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	_ = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := Add(tx, Event{Topic: "order-notify", Key: "o2", Body: "rolled back"}); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	if err := Add(database.DB, Event{Topic: "order-timeout", Key: "o1", Body: []byte(`{}`), Delayed: true, Delay: time.Minute}); err != nil {
+		t.Fatalf("Add delayed: %v", err)
+	}
+
+	publisher := &fakePublisher{err: errors.New("bus down")}
+	relay := NewRelay(publisher)
+
+	// 发送失败：保留待发送状态并推迟重试
+	if n, err := relay.relayBatch(context.Background()); err != nil || n != 2 {
+		t.Fatalf("relayBatch = %d, %v", n, err)
+	}
+	var events []models.OutboxEvent
+	database.DB.Order("id").Find(&events)
+	for _, event := range events {
+		if event.Status != models.OutboxStatusPending || event.Attempts != 1 || event.LastError != "bus down" {
+			t.Fatalf("failed event = %+v", event)
+		}
+		if !event.NextRetryDatetime.After(time.Now()) {
+			t.Fatalf("next retry not postponed: %v", event.NextRetryDatetime)
+		}
+	}
+
+	// 到期后重新发送成功
+	database.DB.Model(&models.OutboxEvent{}).Where("1 = 1").Update("next_retry_datetime", time.Now().Add(-time.Second))
+	publisher.err = nil
+	if n, err := relay.relayBatch(context.Background()); err != nil || n != 2 {
+		t.Fatalf("relayBatch = %d, %v", n, err)
+	}
+	if len(publisher.published) != 2 || publisher.published[0] != `order-notify:{"order_id":"o1"}` || publisher.published[1] != "order-timeout:{}" {
+		t.Fatalf("published = %v", publisher.published)
+	}
+	if publisher.delays[0] != 0 || publisher.delays[1] <= 0 || publisher.delays[1] > time.Minute {
+		t.Fatalf("delays = %v", publisher.delays)
+	}
+
+	var pending int64
+	database.DB.Model(&models.OutboxEvent{}).Where("status = ?", models.OutboxStatusPending).Count(&pending)
+	if pending != 0 {
+		t.Fatalf("pending = %d, want 0", pending)
+	}
+	if n, _ := relay.relayBatch(context.Background()); n != 0 {
+		t.Fatalf("sent events relayed again: %d", n)
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/golang-pay-core/internal/logger"
 	"github.com/golang-pay-core/internal/models"
 	"github.com/golang-pay-core/internal/plugin"
+	"github.com/golang-pay-core/internal/safehttp"
 	"github.com/golang-pay-core/internal/service"
 	"go.uber.org/zap"
 )
@@ -148,10 +149,8 @@ func (p *MockPlugin) simulateCallback(ctx context.Context, req *plugin.CreateOrd
 			time.Sleep(retryDelay)
 		}
 
-		// 创建HTTP客户端
-		client := &http.Client{
-			Timeout: 10 * time.Second,
-		}
+		// 创建HTTP客户端（带 SSRF 防护）
+		client := safehttp.NewClient(10 * time.Second)
 
 		// 构建form数据
 		formData := url.Values{}
@@ -308,6 +307,37 @@ func (p *MockPlugin) buildCallbackParams(req *plugin.CreateOrderRequest) map[str
 	return params
 }
 
+// Refund 退款（模拟）
+// 不调用支付宝API，模拟延迟后直接返回退款成功
+func (p *MockPlugin) Refund(ctx context.Context, req *plugin.RefundRequest) (*plugin.RefundResponse, error) {
+	time.Sleep(time.Duration(p.getRandomDelay()) * time.Millisecond)
+	return plugin.NewRefundSuccessResponse(req.RefundNo, req.TicketNo, req.RefundMoney), nil
+}
+
+// QueryRefund 查询退款结果（模拟）
+func (p *MockPlugin) QueryRefund(ctx context.Context, req *plugin.RefundRequest) (*plugin.RefundResponse, error) {
+	return plugin.NewRefundSuccessResponse(req.RefundNo, req.TicketNo, req.RefundMoney), nil
+}
+
+// QueryOrder 查询上游交易状态（模拟）
+// 启用回调模拟时视为已支付，否则视为等待支付
+func (p *MockPlugin) QueryOrder(ctx context.Context, req *plugin.QueryOrderRequest) (*plugin.QueryOrderResponse, error) {
+	if !p.SimulateCallback {
+		return &plugin.QueryOrderResponse{TradeState: plugin.TradeStateWaiting, TradeStatus: "WAIT_BUYER_PAY"}, nil
+	}
+	return &plugin.QueryOrderResponse{
+		TradeState:  plugin.TradeStatePaid,
+		TradeStatus: "TRADE_SUCCESS",
+		TicketNo:    fmt.Sprintf("MOCK_%d", time.Now().UnixNano()),
+		PayMoney:    req.Money,
+	}, nil
+}
+
+// CloseOrder 关闭上游交易（模拟）
+func (p *MockPlugin) CloseOrder(ctx context.Context, req *plugin.QueryOrderRequest) (*plugin.CloseOrderResponse, error) {
+	return &plugin.CloseOrderResponse{Closed: true, TradeState: plugin.TradeStateClosed}, nil
+}
+
 // 实现 PluginCapabilities 接口
 var _ plugin.PluginCapabilities = (*MockPlugin)(nil)
 
@@ -35,7 +35,10 @@ func NewBasePlugin(pluginID int64) *BasePlugin {
 // 如果插件需要自定义逻辑，可以覆盖此方法
 func (p *BasePlugin) WaitProduct(ctx context.Context, req *plugin.WaitProductRequest) (*plugin.WaitProductResponse, error) {
 	// 获取可用的核销ID列表
-	writeoffIDs, err := plugin.GetWriteoffIDsForPlugin(req.TenantID, req.Money, &req.ChannelID)
+	writeoffIDs, err := plugin.GetWriteoffIDsForPlugin(req.TenantID, req.Money, &req.ChannelID, plugin.WriteoffWhitelistTarget{
+		MerchantID: req.MerchantID,
+		ClientIP:   req.ClientIP,
+	})
 	if err != nil {
 		if logger.Logger != nil {
 			logger.Logger.Error("获取核销ID失败",
@@ -0,0 +1,68 @@
+package alipay
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/plugin"
+	"go.uber.org/zap"
+)
+
+// 实现 PluginRefunder 接口（所有嵌入 BasePlugin 的支付宝插件都支持退款）
+var _ plugin.PluginRefunder = (*BasePlugin)(nil)
+
+// Refund 退款（支付宝通用实现）
+// 参考 Python: alipay.api_alipay_trade_refund
+// 下单时支付宝 out_trade_no 使用的是系统订单号 order_no，退款时优先使用支付宝交易号 trade_no（ticket_no）
+func (p *BasePlugin) Refund(ctx context.Context, req *plugin.RefundRequest) (*plugin.RefundResponse, error) {
+	alipayClient, err := p.getTradeClient(req.ProductID, req.OrderNo, req.OutOrderNo)
+	if err != nil {
+		return plugin.NewRefundErrorResponse(7327, err.Error()), nil
+	}
+
+	result, err := alipayClient.TradeRefund(req.OrderNo, req.TicketNo, req.RefundMoney, req.RefundNo, req.Reason)
+	if err != nil {
+		if logger.Logger != nil {
+			logger.Logger.Warn("支付宝退款失败",
+				zap.String("order_no", req.OrderNo),
+				zap.String("refund_no", req.RefundNo),
+				zap.Int("refund_money", req.RefundMoney),
+				zap.Error(err))
+		}
+		return nil, fmt.Errorf("支付宝退款失败: %w", err)
+	}
+
+	// fund_change=N 表示本次请求未发生资金变化（可能是重复请求），需要通过退款查询确认结果
+	if !result.FundChange {
+		return p.QueryRefund(ctx, req)
+	}
+
+	resp := plugin.NewRefundSuccessResponse(req.RefundNo, result.TradeNo, req.RefundMoney)
+	resp.ExtraData = map[string]interface{}{
+		"refund_fee":     result.RefundFee,
+		"gmt_refund_pay": result.GmtRefundPay,
+	}
+	return resp, nil
+}
+
+// QueryRefund 查询退款结果（支付宝通用实现）
+// 参考 Python: alipay.api_alipay_trade_fastpay_refund_query
+func (p *BasePlugin) QueryRefund(ctx context.Context, req *plugin.RefundRequest) (*plugin.RefundResponse, error) {
+	alipayClient, err := p.getTradeClient(req.ProductID, req.OrderNo, req.OutOrderNo)
+	if err != nil {
+		return plugin.NewRefundErrorResponse(7327, err.Error()), nil
+	}
+
+	result, err := alipayClient.TradeFastpayRefundQuery(req.OrderNo, req.TicketNo, req.RefundNo)
+	if err != nil {
+		return nil, fmt.Errorf("支付宝退款查询失败: %w", err)
+	}
+
+	// refund_status 为 REFUND_SUCCESS 时表示退款成功，为空表示退款未成功（或退款请求不存在）
+	if result.RefundStatus != "REFUND_SUCCESS" {
+		return plugin.NewRefundErrorResponse(7327, fmt.Sprintf("退款未成功: %s", result.RefundStatus)), nil
+	}
+
+	return plugin.NewRefundSuccessResponse(req.RefundNo, result.TradeNo, result.RefundAmount), nil
+}
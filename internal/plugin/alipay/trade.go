@@ -0,0 +1,127 @@
+package alipay
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golang-pay-core/internal/alipay"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/plugin"
+	"go.uber.org/zap"
+)
+
+// 实现 PluginQuerier、PluginCloser 接口（所有嵌入 BasePlugin 的支付宝插件都支持主动查单和关闭交易）
+var (
+	_ plugin.PluginQuerier = (*BasePlugin)(nil)
+	_ plugin.PluginCloser  = (*BasePlugin)(nil)
+)
+
+// QueryOrder 查询上游交易状态（支付宝通用实现）
+// 参考 Python: alipay.api_alipay_trade_query
+// 下单时支付宝 out_trade_no 使用的是系统订单号 order_no
+func (p *BasePlugin) QueryOrder(ctx context.Context, req *plugin.QueryOrderRequest) (*plugin.QueryOrderResponse, error) {
+	alipayClient, err := p.getTradeClient(req.ProductID, req.OrderNo, req.OutOrderNo)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := alipayClient.TradeQuery(req.OrderNo, req.TicketNo)
+	if err != nil {
+		// 交易不存在：用户未扫码或未登录收银台，支付宝尚未创建交易
+		if alipay.IsTradeNotExist(err) {
+			return &plugin.QueryOrderResponse{TradeState: plugin.TradeStateNotExist}, nil
+		}
+		if logger.Logger != nil {
+			logger.Logger.Warn("支付宝交易查询失败",
+				zap.String("order_no", req.OrderNo),
+				zap.String("product_id", req.ProductID),
+				zap.Error(err))
+		}
+		return nil, fmt.Errorf("支付宝交易查询失败: %w", err)
+	}
+
+	resp := &plugin.QueryOrderResponse{
+		TradeStatus: result.TradeStatus,
+		TicketNo:    result.TradeNo,
+		PayMoney:    result.TotalAmount,
+		ExtraData: map[string]interface{}{
+			"buyer_logon_id":   result.BuyerLogonID,
+			"buyer_pay_amount": result.BuyerPayAmount,
+			"send_pay_date":    result.SendPayDate,
+		},
+	}
+	switch result.TradeStatus {
+	case "TRADE_SUCCESS", "TRADE_FINISHED":
+		resp.TradeState = plugin.TradeStatePaid
+	case "TRADE_CLOSED":
+		resp.TradeState = plugin.TradeStateClosed
+	default:
+		resp.TradeState = plugin.TradeStateWaiting
+	}
+
+	return resp, nil
+}
+
+// CloseOrder 关闭上游交易（支付宝通用实现）
+// 参考 Python: alipay.api_alipay_trade_close
+// 请求和响应由支付宝客户端记录到 dvadmin_query_log
+func (p *BasePlugin) CloseOrder(ctx context.Context, req *plugin.QueryOrderRequest) (*plugin.CloseOrderResponse, error) {
+	alipayClient, err := p.getTradeClient(req.ProductID, req.OrderNo, req.OutOrderNo)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = alipayClient.TradeClose(req.OrderNo, req.TicketNo)
+	switch {
+	case err == nil:
+		return &plugin.CloseOrderResponse{Closed: true, TradeState: plugin.TradeStateClosed}, nil
+	case alipay.IsTradeNotExist(err):
+		// 交易不存在：用户未扫码，支付宝尚未创建交易，订单不会再被支付
+		return &plugin.CloseOrderResponse{Closed: true, TradeState: plugin.TradeStateNotExist}, nil
+	case alipay.IsTradeStatusError(err):
+		// 交易状态不合法：交易已支付或已关闭，通过查单确认
+		queryResp, queryErr := p.QueryOrder(ctx, req)
+		if queryErr != nil {
+			return nil, fmt.Errorf("支付宝关闭交易失败，查单确认失败: %w", queryErr)
+		}
+		return &plugin.CloseOrderResponse{
+			Closed:       queryResp.TradeState == plugin.TradeStateClosed,
+			TradeState:   queryResp.TradeState,
+			ErrorMessage: err.Error(),
+		}, nil
+	default:
+		if logger.Logger != nil {
+			logger.Logger.Warn("支付宝关闭交易失败",
+				zap.String("order_no", req.OrderNo),
+				zap.String("product_id", req.ProductID),
+				zap.Error(err))
+		}
+		return nil, fmt.Errorf("支付宝关闭交易失败: %w", err)
+	}
+}
+
+// getTradeClient 根据订单产品创建支付宝客户端（用于查单、退款等交易类接口）
+func (p *BasePlugin) getTradeClient(productID, orderNo, outOrderNo string) (*alipay.Client, error) {
+	productIDInt, err := parseProductIDInt(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	var product models.AlipayProduct
+	if err := database.DB.Where("id = ?", productIDInt).First(&product).Error; err != nil {
+		return nil, fmt.Errorf("产品不存在: %w", err)
+	}
+
+	alipayClient, err := createAlipayClient(&product, "")
+	if err != nil {
+		return nil, fmt.Errorf("创建支付宝客户端失败: %w", err)
+	}
+
+	// 设置订单信息（用于记录 query_log）
+	alipayClient.OrderNo = orderNo
+	alipayClient.OutOrderNo = outOrderNo
+
+	return alipayClient, nil
+}
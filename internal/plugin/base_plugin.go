@@ -8,6 +8,7 @@ import (
 	"sync"
 
 	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
 	"go.uber.org/zap"
 )
 
@@ -32,6 +33,16 @@ func getConfigProvider() PluginConfigProvider {
 	return globalConfigProvider
 }
 
+// GetPluginConfig 获取插件配置（通过全局插件配置提供者，带缓存）
+// 插件实例按插件类型缓存、被多个插件ID共享，因此配置需按请求中的插件ID读取
+func GetPluginConfig(ctx context.Context, pluginID int64, key string) (*models.PayPluginConfig, error) {
+	provider := getConfigProvider()
+	if provider == nil {
+		return nil, fmt.Errorf("插件配置提供者未设置")
+	}
+	return provider.GetPluginConfigByKey(ctx, pluginID, key)
+}
+
 // BasePlugin 基础插件实现（所有插件的基类）
 // 提供通用的插件功能，不包含任何第三方支付平台特定的逻辑
 // 第三方支付平台（支付宝、微信、京东等）的插件应该继承或嵌入此基类
@@ -24,6 +24,7 @@ func ExampleRegisterAlipayPlugin() {
 }
 
 // ExampleRegisterWechatPlugin 示例：注册微信支付插件
+// 实际的微信支付插件（wechat_h5、wechat_native、wechat_jsapi）见 internal/plugin/wechat
 func ExampleRegisterWechatPlugin() {
 	registry := GetRegistry()
 
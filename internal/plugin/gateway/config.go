@@ -0,0 +1,111 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/golang-pay-core/internal/gateway"
+	"github.com/golang-pay-core/internal/plugin"
+)
+
+// 上游网关插件配置项（dvadmin_pay_plugin_config.key，parent_id 为插件ID）
+const (
+	configKeyURL     = "gateway_url"     // 下单地址
+	configKeyMethod  = "gateway_method"  // 请求方式：POST_FORM（默认）、POST_JSON、GET
+	configKeyFields  = "gateway_fields"  // 下单字段映射（JSON 对象）
+	configKeySign    = "gateway_sign"    // 签名配置（JSON 对象）
+	configKeyPayURL  = "gateway_pay_url" // 支付地址 JSONPath
+	configKeySuccess = "gateway_success" // 下单成功判断（JSON 对象：path、value、message_path）
+	configKeyNotify  = "gateway_notify"  // 异步通知配置（JSON 对象）
+	configKeyQuery   = "gateway_query"   // 查单配置（JSON 对象）
+)
+
+// successConfig 下单成功判断配置
+type successConfig struct {
+	Path        string `json:"path"`
+	Value       string `json:"value"`
+	MessagePath string `json:"message_path"`
+}
+
+// LoadConfig 从插件配置组装上游网关配置
+func LoadConfig(ctx context.Context, pluginID int64) (*gateway.Config, error) {
+	cfg := &gateway.Config{}
+
+	cfg.URL = loadString(ctx, pluginID, configKeyURL)
+	cfg.Method = loadString(ctx, pluginID, configKeyMethod)
+	cfg.PayURLPath = loadString(ctx, pluginID, configKeyPayURL)
+
+	if err := loadObject(ctx, pluginID, configKeyFields, &cfg.Fields); err != nil {
+		return nil, err
+	}
+	if err := loadObject(ctx, pluginID, configKeySign, &cfg.Sign); err != nil {
+		return nil, err
+	}
+	if err := loadObject(ctx, pluginID, configKeyNotify, &cfg.Notify); err != nil {
+		return nil, err
+	}
+	if err := loadObject(ctx, pluginID, configKeyQuery, &cfg.Query); err != nil {
+		return nil, err
+	}
+
+	var success successConfig
+	if err := loadObject(ctx, pluginID, configKeySuccess, &success); err != nil {
+		return nil, err
+	}
+	cfg.SuccessPath = success.Path
+	cfg.SuccessValue = success.Value
+	cfg.MessagePath = success.MessagePath
+
+	if err := cfg.Normalize(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// configValue 读取插件配置原始值（兼容 {"value": ...} 包装格式），配置不存在时返回 nil
+func configValue(ctx context.Context, pluginID int64, key string) json.RawMessage {
+	config, err := plugin.GetPluginConfig(ctx, pluginID, key)
+	if err != nil || config == nil || strings.TrimSpace(config.Value) == "" {
+		return nil
+	}
+
+	var wrapper map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(config.Value), &wrapper); err == nil {
+		if value, ok := wrapper["value"]; ok && len(wrapper) == 1 {
+			return value
+		}
+	}
+	return json.RawMessage(config.Value)
+}
+
+// loadString 读取字符串配置（兼容 JSON 字符串和原始文本）
+func loadString(ctx context.Context, pluginID int64, key string) string {
+	raw := configValue(ctx, pluginID, key)
+	if raw == nil {
+		return ""
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return strings.TrimSpace(value)
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+// loadObject 读取 JSON 对象配置（兼容以 JSON 字符串保存的对象），配置不存在时不修改 dest
+func loadObject(ctx context.Context, pluginID int64, key string, dest interface{}) error {
+	raw := configValue(ctx, pluginID, key)
+	if raw == nil {
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		raw = json.RawMessage(text)
+	}
+	if err := json.Unmarshal(raw, dest); err != nil {
+		return fmt.Errorf("插件配置 %s 格式错误: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-pay-core/internal/gateway"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/plugin"
+	"github.com/golang-pay-core/internal/service"
+	"go.uber.org/zap"
+)
+
+// 实现 PluginQuerier 接口（配置了查单接口时支持主动查单）
+var _ plugin.PluginQuerier = (*Plugin)(nil)
+
+// Plugin 上游网关插件
+// 通过插件配置对接任意"表单/JSON 下单 + 签名 + 异步通知"类型的上游聚合平台
+// 产品即插件本身：产品ID为插件ID，下单、验签、查单均按插件ID读取配置
+type Plugin struct {
+	*plugin.BasePlugin // 嵌入 plugin.BasePlugin，继承通用功能
+}
+
+// NewPlugin 创建上游网关插件
+func NewPlugin(pluginID int64) *Plugin {
+	return &Plugin{
+		BasePlugin: plugin.NewBasePlugin(pluginID),
+	}
+}
+
+// WaitProduct 等待产品
+// 上游网关没有产品库存，校验插件配置后直接使用插件ID作为产品ID
+func (p *Plugin) WaitProduct(ctx context.Context, req *plugin.WaitProductRequest) (*plugin.WaitProductResponse, error) {
+	if _, err := LoadConfig(ctx, req.PluginID); err != nil {
+		if logger.Logger != nil {
+			logger.Logger.Error("上游网关插件配置无效",
+				zap.Int64("plugin_id", req.PluginID),
+				zap.Int64("channel_id", req.ChannelID),
+				zap.Error(err))
+		}
+		return plugin.NewWaitProductErrorResponse(7318, fmt.Sprintf("上游网关配置无效: %v", err)), nil
+	}
+
+	return plugin.NewWaitProductSuccessResponse(strconv.FormatInt(req.PluginID, 10), nil, "", req.Money), nil
+}
+
+// CreateOrder 创建订单，按字段映射向上游下单，并从响应中按 JSONPath 取支付地址
+func (p *Plugin) CreateOrder(ctx context.Context, req *plugin.CreateOrderRequest) (*plugin.CreateOrderResponse, error) {
+	cfg, err := LoadConfig(ctx, req.PluginID)
+	if err != nil {
+		return plugin.NewErrorResponse(7320, fmt.Sprintf("上游网关配置无效: %v", err)), nil
+	}
+
+	notifyURL, err := buildNotifyURL(ctx, req.DomainURL, req.PluginID)
+	if err != nil {
+		return plugin.NewErrorResponse(7320, err.Error()), nil
+	}
+
+	client := gateway.NewClient(cfg, req.OrderNo, req.OutOrderNo)
+	payURL, err := client.CreateOrder(map[string]string{
+		"order_no":     req.OrderNo,
+		"out_order_no": req.OutOrderNo,
+		"money":        strconv.Itoa(req.Money),
+		"money_yuan":   gateway.FormatYuan(req.Money),
+		"notify_url":   notifyURL,
+		"jump_url":     req.JumpURL,
+		"client_ip":    extraClientIP(req.Extra),
+		"timestamp":    strconv.FormatInt(time.Now().Unix(), 10),
+	})
+	if err != nil {
+		if logger.Logger != nil {
+			logger.Logger.Warn("上游网关下单失败",
+				zap.String("order_no", req.OrderNo),
+				zap.Int64("plugin_id", req.PluginID),
+				zap.Error(err))
+		}
+		return plugin.NewErrorResponse(7320, fmt.Sprintf("生成支付URL失败: %v", err)), nil
+	}
+
+	return plugin.NewSuccessResponse(payURL), nil
+}
+
+// CallbackSubmit 下单回调（订单创建成功后调用）
+// 更新通道和全局的提交统计
+func (p *Plugin) CallbackSubmit(ctx context.Context, req *plugin.CallbackSubmitRequest) error {
+	createDatetime, err := time.ParseInLocation("2006-01-02 15:04:05", req.CreateDatetime, time.Local)
+	if err != nil {
+		createDatetime = time.Now()
+	}
+
+	// 更新提交统计（失败不影响主流程）
+	statsService := service.NewStatisticsService()
+	if req.ChannelID > 0 {
+		channelStats := &models.PayChannelDayStatistics{PayChannelID: &req.ChannelID}
+		if req.TenantID > 0 {
+			channelStats.TenantID = &req.TenantID
+		}
+		if req.MerchantID > 0 {
+			channelStats.MerchantID = &req.MerchantID
+		}
+		if err := statsService.SubmitBaseDayStatistics(ctx, channelStats, createDatetime); err != nil {
+			logger.Logger.Error("更新通道日统计 submit_count 失败",
+				zap.String("order_no", req.OrderNo),
+				zap.Int64("channel_id", req.ChannelID),
+				zap.Error(err))
+		}
+	}
+
+	if err := statsService.SubmitBaseDayStatistics(ctx, &models.DayStatistics{SubmitMoney: int64(req.Money)}, createDatetime); err != nil {
+		logger.Logger.Error("更新全局日统计失败",
+			zap.String("order_no", req.OrderNo),
+			zap.Error(err))
+	}
+
+	return nil
+}
+
+// QueryOrder 主动查单（需配置 gateway_query）
+func (p *Plugin) QueryOrder(ctx context.Context, req *plugin.QueryOrderRequest) (*plugin.QueryOrderResponse, error) {
+	cfg, err := LoadConfig(ctx, req.PluginID)
+	if err != nil {
+		return nil, fmt.Errorf("上游网关配置无效: %w", err)
+	}
+
+	client := gateway.NewClient(cfg, req.OrderNo, req.OutOrderNo)
+	result, err := client.QueryOrder(map[string]string{
+		"order_no":     req.OrderNo,
+		"out_order_no": req.OutOrderNo,
+		"ticket_no":    req.TicketNo,
+		"money":        strconv.Itoa(req.Money),
+		"money_yuan":   gateway.FormatYuan(req.Money),
+		"timestamp":    strconv.FormatInt(time.Now().Unix(), 10),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("上游网关查单失败: %w", err)
+	}
+
+	resp := &plugin.QueryOrderResponse{
+		TradeStatus: result.TradeStatus,
+		TicketNo:    result.TradeNo,
+	}
+	if result.Amount >= 0 {
+		resp.PayMoney = result.Amount
+	}
+	switch result.TradeState {
+	case gateway.TradeStatePaid:
+		resp.TradeState = plugin.TradeStatePaid
+	case gateway.TradeStateClosed:
+		resp.TradeState = plugin.TradeStateClosed
+	case gateway.TradeStateNotExist:
+		resp.TradeState = plugin.TradeStateNotExist
+	default:
+		resp.TradeState = plugin.TradeStateWaiting
+	}
+	return resp, nil
+}
+
+// VerifyNotify 按插件配置验证并解析上游异步通知
+// 返回的配置用于获取处理成功后的应答内容
+func VerifyNotify(ctx context.Context, pluginID int64, params map[string]string) (*gateway.NotifyResult, *gateway.Config, error) {
+	cfg, err := LoadConfig(ctx, pluginID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("上游网关配置无效: %w", err)
+	}
+
+	result, err := gateway.NewClient(cfg, "", "").VerifyNotify(params)
+	if err != nil {
+		return nil, cfg, err
+	}
+	return result, cfg, nil
+}
+
+// buildNotifyURL 构建回调地址（产品ID即插件ID）
+// 优先使用系统配置 gateway.notify_domain，未配置时使用订单域名
+func buildNotifyURL(ctx context.Context, domainURL string, pluginID int64) (string, error) {
+	notifyDomain, _ := service.NewSystemConfigService().GetSystemConfigByPath(ctx, "gateway.notify_domain")
+	if notifyDomain == "" {
+		notifyDomain = domainURL
+	}
+	if notifyDomain == "" {
+		return "", fmt.Errorf("域名URL不能为空")
+	}
+	return fmt.Sprintf("%s/api/pay/order/notify/%s/%d/", strings.TrimRight(notifyDomain, "/"), PluginType, pluginID), nil
+}
+
+// extraClientIP 从订单额外参数中获取用户IP（易支付 clientip 等）
+func extraClientIP(extra string) string {
+	if extra == "" {
+		return ""
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(extra), &data); err != nil {
+		return ""
+	}
+	if ip, ok := data["client_ip"].(string); ok {
+		return ip
+	}
+	return ""
+}
@@ -0,0 +1,26 @@
+package gateway
+
+import (
+	"context"
+
+	"github.com/golang-pay-core/internal/plugin"
+)
+
+// PluginType 上游网关插件类型（支付方式 key）
+// 多个插件可共用该支付方式，各自通过插件配置对接不同的上游平台
+const PluginType = "upstream_gateway"
+
+// RegisterPlugin 注册上游网关插件
+func RegisterPlugin() {
+	registry := plugin.GetRegistry()
+	registry.Register(PluginType, func(ctx context.Context, pluginID int64, pluginType string) (plugin.Plugin, error) {
+		return NewPlugin(pluginID), nil
+	})
+}
+
+// init 自动注册上游网关插件
+// 在包导入时自动执行，无需在 main.go 中手动调用
+// 注意：init() 函数在 logger 初始化之前执行，因此不在此处记录日志
+func init() {
+	RegisterPlugin() // upstream_gateway
+}
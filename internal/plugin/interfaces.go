@@ -59,6 +59,29 @@ type PluginCapabilities interface {
 	GetTimeout(ctx context.Context, pluginID int64) int
 }
 
+// PluginRefunder 插件退款能力接口（可选实现）
+// 支持退款的插件实现此接口，订单服务通过类型断言判断插件是否支持退款
+type PluginRefunder interface {
+	// Refund 向上游发起退款（支持部分退款，同一 RefundNo 重复调用应保持幂等）
+	Refund(ctx context.Context, req *RefundRequest) (*RefundResponse, error)
+	// QueryRefund 查询上游退款结果（用于退款请求结果未知时确认退款状态）
+	QueryRefund(ctx context.Context, req *RefundRequest) (*RefundResponse, error)
+}
+
+// PluginQuerier 插件主动查单能力接口（可选实现）
+// 用于订单超时前向上游确认真实交易状态，找回丢失的异步通知
+type PluginQuerier interface {
+	// QueryOrder 向上游查询交易状态
+	QueryOrder(ctx context.Context, req *QueryOrderRequest) (*QueryOrderResponse, error)
+}
+
+// PluginCloser 插件关闭上游交易能力接口（可选实现）
+// 订单超时时关闭上游交易，避免买家对已关闭的订单继续付款
+type PluginCloser interface {
+	// CloseOrder 关闭上游交易（交易已支付时 TradeState 返回 TradeStatePaid，调用方应走补单流程）
+	CloseOrder(ctx context.Context, req *QueryOrderRequest) (*CloseOrderResponse, error)
+}
+
 // PluginConfigProvider 插件配置提供者接口（避免循环依赖）
 // 用于从缓存服务获取插件配置
 type PluginConfigProvider interface {
@@ -135,6 +158,7 @@ type WaitProductRequest struct {
 	PluginID       int64                  `json:"plugin_id"`
 	PluginType     string                 `json:"plugin_type"`
 	PluginUpstream int                    `json:"plugin_upstream"`
+	ClientIP       string                 `json:"client_ip,omitempty"` // 用户IP（下单传入 clientIp 时填充，用于核销白名单）
 	Channel        map[string]interface{} `json:"channel,omitempty"`
 }
 
@@ -216,3 +240,89 @@ type CallbackSuccessRequest struct {
 	NotifyURL      string `json:"notify_url"`      // 通知URL
 	PluginUpstream int    `json:"plugin_upstream"` // 插件上游类型
 }
+
+// RefundRequest 退款请求
+type RefundRequest struct {
+	OrderID     string `json:"order_id"`     // 订单数据库ID
+	OrderNo     string `json:"order_no"`     // 系统订单号（上游商户订单号）
+	OutOrderNo  string `json:"out_order_no"` // 商户订单号
+	TicketNo    string `json:"ticket_no"`    // 上游交易号（如支付宝 trade_no）
+	ProductID   string `json:"product_id"`   // 产品ID
+	PluginID    int64  `json:"plugin_id"`    // 插件ID
+	PluginType  string `json:"plugin_type"`  // 插件类型
+	Money       int    `json:"money"`        // 订单金额（分）
+	RefundMoney int    `json:"refund_money"` // 本次退款金额（分）
+	RefundNo    string `json:"refund_no"`    // 退款单号（上游退款请求号）
+	Reason      string `json:"reason"`       // 退款原因
+}
+
+// RefundResponse 退款响应
+type RefundResponse struct {
+	Success      bool                   `json:"success"`
+	RefundNo     string                 `json:"refund_no,omitempty"`    // 退款单号
+	TicketNo     string                 `json:"ticket_no,omitempty"`    // 上游交易号
+	RefundMoney  int                    `json:"refund_money,omitempty"` // 本次退款金额（分）
+	ErrorCode    int                    `json:"error_code,omitempty"`
+	ErrorMessage string                 `json:"error_message,omitempty"`
+	ExtraData    map[string]interface{} `json:"extra_data,omitempty"`
+}
+
+// NewRefundSuccessResponse 创建退款成功响应
+func NewRefundSuccessResponse(refundNo, ticketNo string, refundMoney int) *RefundResponse {
+	return &RefundResponse{
+		Success:     true,
+		RefundNo:    refundNo,
+		TicketNo:    ticketNo,
+		RefundMoney: refundMoney,
+	}
+}
+
+// NewRefundErrorResponse 创建退款失败响应
+func NewRefundErrorResponse(code int, message string) *RefundResponse {
+	return &RefundResponse{
+		Success:      false,
+		ErrorCode:    code,
+		ErrorMessage: message,
+	}
+}
+
+// 上游交易状态（由插件将上游状态归一化）
+const (
+	TradeStateNotExist = "not_exist" // 上游交易不存在（用户未扫码/未拉起支付）
+	TradeStateWaiting  = "waiting"   // 等待支付
+	TradeStatePaid     = "paid"      // 支付成功
+	TradeStateClosed   = "closed"    // 已关闭（未支付关闭或全额退款）
+)
+
+// QueryOrderRequest 查单请求
+type QueryOrderRequest struct {
+	OrderID    string `json:"order_id"`     // 订单数据库ID
+	OrderNo    string `json:"order_no"`     // 系统订单号（上游商户订单号）
+	OutOrderNo string `json:"out_order_no"` // 商户订单号
+	TicketNo   string `json:"ticket_no"`    // 上游交易号（如有）
+	ProductID  string `json:"product_id"`   // 产品ID
+	PluginID   int64  `json:"plugin_id"`    // 插件ID
+	PluginType string `json:"plugin_type"`  // 插件类型
+	Money      int    `json:"money"`        // 订单金额（分）
+}
+
+// QueryOrderResponse 查单响应
+type QueryOrderResponse struct {
+	TradeState  string                 `json:"trade_state"`            // 归一化的交易状态（TradeState*）
+	TradeStatus string                 `json:"trade_status,omitempty"` // 上游原始交易状态
+	TicketNo    string                 `json:"ticket_no,omitempty"`    // 上游交易号
+	PayMoney    int                    `json:"pay_money,omitempty"`    // 上游交易金额（分）
+	ExtraData   map[string]interface{} `json:"extra_data,omitempty"`
+}
+
+// IsPaid 上游交易是否已支付
+func (r *QueryOrderResponse) IsPaid() bool {
+	return r.TradeState == TradeStatePaid
+}
+
+// CloseOrderResponse 关闭上游交易响应
+type CloseOrderResponse struct {
+	Closed       bool   `json:"closed"`                  // 上游交易是否已不可支付（已关闭或交易不存在）
+	TradeState   string `json:"trade_state"`             // 归一化的交易状态（TradeState*）
+	ErrorMessage string `json:"error_message,omitempty"` // 关闭失败原因
+}
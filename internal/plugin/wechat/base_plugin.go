@@ -0,0 +1,226 @@
+package wechat
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/plugin"
+	"github.com/golang-pay-core/internal/service"
+	"github.com/golang-pay-core/internal/wechat"
+	"go.uber.org/zap"
+)
+
+// BasePlugin 微信支付基础插件
+// 继承自 plugin.BasePlugin，提供微信支付插件通用的功能（选产品、统计、查单、关单、退款）
+// 所有微信支付相关的插件（H5Plugin、NativePlugin、JSAPIPlugin）嵌入此插件
+type BasePlugin struct {
+	*plugin.BasePlugin // 嵌入 plugin.BasePlugin，继承通用功能
+}
+
+// NewBasePlugin 创建微信支付基础插件
+func NewBasePlugin(pluginID int64) *BasePlugin {
+	return &BasePlugin{
+		BasePlugin: plugin.NewBasePlugin(pluginID),
+	}
+}
+
+// WaitProduct 等待产品（微信支付通用实现）
+// 在商户所属的码商(writeoff)下随机选择一个可用的微信支付产品
+func (p *BasePlugin) WaitProduct(ctx context.Context, req *plugin.WaitProductRequest) (*plugin.WaitProductResponse, error) {
+	writeoffIDs, err := plugin.GetWriteoffIDsForPlugin(req.TenantID, req.Money, &req.ChannelID, plugin.WriteoffWhitelistTarget{
+		MerchantID: req.MerchantID,
+		ClientIP:   req.ClientIP,
+	})
+	if err != nil {
+		if logger.Logger != nil {
+			logger.Logger.Error("获取核销ID失败",
+				zap.Int64("tenant_id", req.TenantID),
+				zap.Int64("channel_id", req.ChannelID),
+				zap.Int("money", req.Money),
+				zap.Error(err))
+		}
+		return plugin.NewWaitProductErrorResponse(7318, fmt.Sprintf("获取核销ID失败: %v", err)), nil
+	}
+	if len(writeoffIDs) == 0 {
+		return plugin.NewWaitProductErrorResponse(7318, "没有可选核销"), nil
+	}
+
+	product, err := getWechatProduct(ctx, req, writeoffIDs)
+	if err != nil {
+		if logger.Logger != nil {
+			logger.Logger.Error("获取微信支付产品失败",
+				zap.Int64("tenant_id", req.TenantID),
+				zap.Int64("channel_id", req.ChannelID),
+				zap.Int("money", req.Money),
+				zap.Int64s("writeoff_ids", writeoffIDs),
+				zap.Error(err))
+		}
+		return plugin.NewWaitProductErrorResponse(7318, fmt.Sprintf("获取产品失败: %v", err)), nil
+	}
+	if product == nil {
+		return plugin.NewWaitProductErrorResponse(7318, "无货物库存"), nil
+	}
+
+	writeoffID := product.WriteoffID
+	return plugin.NewWaitProductSuccessResponse(strconv.FormatInt(product.ID, 10), &writeoffID, "", req.Money), nil
+}
+
+// CallbackSubmit 下单回调（订单创建成功后调用）
+// 更新订单备注和通道、核销通道、全局的提交统计
+func (p *BasePlugin) CallbackSubmit(ctx context.Context, req *plugin.CallbackSubmitRequest) error {
+	// 1. 更新订单备注（产品名称）
+	if product, err := getProduct(req.ProductID); err == nil {
+		if err := database.DB.Model(&models.Order{}).
+			Where("order_no = ?", req.OrderNo).
+			Update("remarks", product.Name).Error; err != nil {
+			logger.Logger.Warn("更新订单备注失败",
+				zap.String("order_no", req.OrderNo),
+				zap.Error(err))
+		}
+	}
+
+	createDatetime, err := time.ParseInLocation("2006-01-02 15:04:05", req.CreateDatetime, time.Local)
+	if err != nil {
+		createDatetime = time.Now()
+	}
+
+	// 2. 更新提交统计（失败不影响主流程）
+	statsService := service.NewStatisticsService()
+	if req.ChannelID > 0 {
+		channelStats := &models.PayChannelDayStatistics{PayChannelID: &req.ChannelID}
+		if req.TenantID > 0 {
+			channelStats.TenantID = &req.TenantID
+		}
+		if req.MerchantID > 0 {
+			channelStats.MerchantID = &req.MerchantID
+		}
+		channelStats.WriteoffID = req.WriteoffID
+		if err := statsService.SubmitBaseDayStatistics(ctx, channelStats, createDatetime); err != nil {
+			logger.Logger.Error("更新通道日统计 submit_count 失败",
+				zap.String("order_no", req.OrderNo),
+				zap.Int64("channel_id", req.ChannelID),
+				zap.Error(err))
+		}
+
+		if req.WriteoffID != nil {
+			writeoffChannelStats := &models.WriteOffChannelDayStatistics{
+				WriteoffID:   req.WriteoffID,
+				PayChannelID: &req.ChannelID,
+			}
+			if err := statsService.SubmitBaseDayStatistics(ctx, writeoffChannelStats, createDatetime); err != nil {
+				logger.Logger.Error("更新核销通道日统计 submit_count 失败",
+					zap.String("order_no", req.OrderNo),
+					zap.Int64("writeoff_id", *req.WriteoffID),
+					zap.Int64("channel_id", req.ChannelID),
+					zap.Error(err))
+			}
+		}
+	}
+
+	if err := statsService.SubmitBaseDayStatistics(ctx, &models.DayStatistics{SubmitMoney: int64(req.Money)}, createDatetime); err != nil {
+		logger.Logger.Error("更新全局日统计失败",
+			zap.String("order_no", req.OrderNo),
+			zap.Error(err))
+	}
+
+	return nil
+}
+
+// CallbackSuccess 支付成功回调
+// 微信支付产品没有单独的产品日统计，成功统计由订单成功钩子统一处理
+func (p *BasePlugin) CallbackSuccess(ctx context.Context, req *plugin.CallbackSuccessRequest) error {
+	logger.Logger.Info("微信支付订单支付成功",
+		zap.String("order_no", req.OrderNo),
+		zap.String("product_id", req.ProductID),
+		zap.Int("notify_money", req.NotifyMoney))
+	return nil
+}
+
+// getProduct 根据产品ID获取微信支付产品
+func getProduct(productID string) (*models.WechatProduct, error) {
+	if productID == "" {
+		return nil, fmt.Errorf("产品ID不能为空")
+	}
+	return wechat.GetWechatProductByID(productID)
+}
+
+// newClient 创建微信支付客户端，并设置用于记录 query_log 的订单信息
+func newClient(product *models.WechatProduct, notifyURL, orderNo, outOrderNo string) (*wechat.Client, error) {
+	client, err := wechat.NewClient(product, notifyURL)
+	if err != nil {
+		return nil, fmt.Errorf("创建微信支付客户端失败: %w", err)
+	}
+	client.OrderNo = orderNo
+	client.OutOrderNo = outOrderNo
+	return client, nil
+}
+
+// buildNotifyURL 构建回调地址
+// 优先使用系统配置 wechat.notify_domain，未配置时使用订单域名
+func buildNotifyURL(ctx context.Context, domainURL, pluginType, productID string) (string, error) {
+	notifyDomain, _ := service.NewSystemConfigService().GetSystemConfigByPath(ctx, "wechat.notify_domain")
+	if notifyDomain == "" {
+		notifyDomain = domainURL
+	}
+	if notifyDomain == "" {
+		return "", fmt.Errorf("域名URL不能为空")
+	}
+	return fmt.Sprintf("%s/api/pay/order/notify/%s/%s/", strings.TrimRight(notifyDomain, "/"), pluginType, productID), nil
+}
+
+// buildPrepayRequest 构建下单参数（H5、Native、JSAPI 公共部分）
+// 上游交易过期时间与订单超时时间保持一致，订单超时后用户无法再支付
+func (p *BasePlugin) buildPrepayRequest(ctx context.Context, req *plugin.CreateOrderRequest, product *models.WechatProduct) *wechat.PrepayRequest {
+	expireTime := time.Now().Add(time.Duration(p.GetTimeout(ctx, req.PluginID)) * time.Second)
+	return &wechat.PrepayRequest{
+		Description: generateDescription(req, product),
+		OutTradeNo:  req.OrderNo,
+		Total:       req.Money,
+		TimeExpire:  &expireTime,
+	}
+}
+
+// generateDescription 生成商品描述（支持 {money}、{order_no}、{out_order_no} 占位符）
+func generateDescription(req *plugin.CreateOrderRequest, product *models.WechatProduct) string {
+	moneyStr := fmt.Sprintf("%.2f", float64(req.Money)/100)
+	if product.Subject != "" {
+		replacer := strings.NewReplacer(
+			"{money}", moneyStr,
+			"{order_no}", req.OrderNo,
+			"{out_order_no}", req.OutOrderNo,
+		)
+		return replacer.Replace(product.Subject)
+	}
+	return fmt.Sprintf("订单支付-%s-%s元", req.OrderNo, moneyStr)
+}
+
+// resolveProductID 获取下单使用的产品ID（请求中未携带时从订单详情获取）
+func resolveProductID(req *plugin.CreateOrderRequest) (string, error) {
+	if req.ProductID != "" {
+		return req.ProductID, nil
+	}
+
+	var orderDetail models.OrderDetail
+	query := database.DB.Select("product_id")
+	switch {
+	case req.DetailID > 0:
+		query = query.Where("id = ?", req.DetailID)
+	case req.OrderID != "":
+		query = query.Where("order_id = ?", req.OrderID)
+	default:
+		return "", fmt.Errorf("缺少订单ID或详情ID")
+	}
+	if err := query.First(&orderDetail).Error; err != nil {
+		return "", fmt.Errorf("订单详情不存在")
+	}
+	if orderDetail.ProductID == "" {
+		return "", fmt.Errorf("产品ID不能为空")
+	}
+	return orderDetail.ProductID, nil
+}
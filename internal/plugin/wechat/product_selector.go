@@ -0,0 +1,93 @@
+package wechat
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/plugin"
+	"go.uber.org/zap"
+)
+
+// getWechatProduct 获取微信支付产品
+// 在商户所属的码商(writeoff)下随机选择一个可用的、允许该支付通道且未超日限额的产品
+func getWechatProduct(ctx context.Context, req *plugin.WaitProductRequest, writeoffIDs []int64) (*models.WechatProduct, error) {
+	money := req.Money
+
+	var products []models.WechatProduct
+	if err := database.DB.Model(&models.WechatProduct{}).
+		Where("can_pay = ?", true).
+		Where("status = ?", true).
+		Where("is_delete = ?", false).
+		Where("writeoff_id IN ?", writeoffIDs).
+		Where("(max_money = 0 OR max_money >= ?) AND (min_money = 0 OR min_money <= ?)", money, money).
+		Where("id IN (SELECT wechatproduct_id FROM dvadmin_wechat_product_allow_pay_channels WHERE paychannel_id = ?)", req.ChannelID).
+		Order("RAND()").
+		Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("查询产品失败: %w", err)
+	}
+
+	for i := range products {
+		product := &products[i]
+		if product.LimitMoney > 0 && !checkDailyLimit(ctx, product, money) {
+			if logger.Logger != nil {
+				logger.Logger.Debug("微信支付产品日限额超限",
+					zap.Int64("product_id", product.ID),
+					zap.Int("limit_money", product.LimitMoney),
+					zap.Int("money", money))
+			}
+			continue
+		}
+
+		if logger.Logger != nil {
+			logger.Logger.Info("成功选择微信支付产品",
+				zap.Int64("product_id", product.ID),
+				zap.Int64("writeoff_id", product.WriteoffID),
+				zap.Int("money", money),
+				zap.Int64("channel_id", req.ChannelID))
+		}
+		return product, nil
+	}
+
+	if logger.Logger != nil {
+		logger.Logger.Warn("未找到可用的微信支付产品",
+			zap.Int64("channel_id", req.ChannelID),
+			zap.Int("money", money),
+			zap.Int("product_count", len(products)))
+	}
+	return nil, nil
+}
+
+// checkDailyLimit 检查日限额
+// 当日已支付金额 + 五分钟内等待支付的订单金额 + 本次金额不超过限额
+// 订单详情的 product_id 与支付宝产品共用，按插件类型区分微信支付订单
+func checkDailyLimit(ctx context.Context, product *models.WechatProduct, money int) bool {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	productID := fmt.Sprintf("%d", product.ID)
+
+	var paidMoney int64
+	database.DB.Table("dvadmin_order").
+		Joins("JOIN dvadmin_order_detail ON dvadmin_order.id = dvadmin_order_detail.order_id").
+		Where("dvadmin_order_detail.product_id = ?", productID).
+		Where("dvadmin_order_detail.plugin_type IN ?", PluginTypes).
+		Where("dvadmin_order.order_status IN ?", []int{models.OrderStatusPaid, models.OrderStatusPaidNoNotify}).
+		Where("dvadmin_order.create_datetime >= ?", today).
+		Select("COALESCE(SUM(dvadmin_order.money), 0)").
+		Scan(&paidMoney)
+
+	var pendingMoney int64
+	database.DB.Table("dvadmin_order").
+		Joins("JOIN dvadmin_order_detail ON dvadmin_order.id = dvadmin_order_detail.order_id").
+		Where("dvadmin_order_detail.product_id = ?", productID).
+		Where("dvadmin_order_detail.plugin_type IN ?", PluginTypes).
+		Where("dvadmin_order.order_status IN ?", []int{models.OrderStatusGenerating, models.OrderStatusPaying}).
+		Where("dvadmin_order.create_datetime >= ?", now.Add(-5*time.Minute)).
+		Select("COALESCE(SUM(dvadmin_order.money), 0)").
+		Scan(&pendingMoney)
+
+	return paidMoney+pendingMoney+int64(money) <= int64(product.LimitMoney)
+}
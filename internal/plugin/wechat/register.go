@@ -0,0 +1,43 @@
+package wechat
+
+import (
+	"context"
+
+	"github.com/golang-pay-core/internal/plugin"
+)
+
+// PluginTypes 微信支付插件类型（回调路由和产品日限额统计使用）
+var PluginTypes = []string{"wechat_h5", "wechat_native", "wechat_jsapi"}
+
+// RegisterH5Plugin 注册微信 H5 支付插件
+func RegisterH5Plugin() {
+	registry := plugin.GetRegistry()
+	registry.Register("wechat_h5", func(ctx context.Context, pluginID int64, pluginType string) (plugin.Plugin, error) {
+		return NewH5Plugin(pluginID), nil
+	})
+}
+
+// RegisterNativePlugin 注册微信 Native 支付插件
+func RegisterNativePlugin() {
+	registry := plugin.GetRegistry()
+	registry.Register("wechat_native", func(ctx context.Context, pluginID int64, pluginType string) (plugin.Plugin, error) {
+		return NewNativePlugin(pluginID), nil
+	})
+}
+
+// RegisterJSAPIPlugin 注册微信 JSAPI 支付插件
+func RegisterJSAPIPlugin() {
+	registry := plugin.GetRegistry()
+	registry.Register("wechat_jsapi", func(ctx context.Context, pluginID int64, pluginType string) (plugin.Plugin, error) {
+		return NewJSAPIPlugin(pluginID), nil
+	})
+}
+
+// init 自动注册所有微信支付插件
+// 在包导入时自动执行，无需在 main.go 中手动调用
+// 注意：init() 函数在 logger 初始化之前执行，因此不在此处记录日志
+func init() {
+	RegisterH5Plugin()     // wechat_h5
+	RegisterNativePlugin() // wechat_native
+	RegisterJSAPIPlugin()  // wechat_jsapi
+}
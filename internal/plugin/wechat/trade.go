@@ -0,0 +1,144 @@
+package wechat
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/plugin"
+	"github.com/golang-pay-core/internal/wechat"
+	"go.uber.org/zap"
+)
+
+// 实现 PluginQuerier、PluginCloser、PluginRefunder 接口（所有嵌入 BasePlugin 的微信支付插件都支持）
+var (
+	_ plugin.PluginQuerier  = (*BasePlugin)(nil)
+	_ plugin.PluginCloser   = (*BasePlugin)(nil)
+	_ plugin.PluginRefunder = (*BasePlugin)(nil)
+)
+
+// QueryOrder 主动查单（微信支付通用实现）
+// 下单时微信支付 out_trade_no 使用的是系统订单号 order_no
+func (p *BasePlugin) QueryOrder(ctx context.Context, req *plugin.QueryOrderRequest) (*plugin.QueryOrderResponse, error) {
+	client, err := p.getTradeClient(req.ProductID, req.OrderNo, req.OutOrderNo)
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, err := client.QueryByOutTradeNo(req.OrderNo)
+	if err != nil {
+		if wechat.IsOrderNotExist(err) {
+			return &plugin.QueryOrderResponse{TradeState: plugin.TradeStateNotExist}, nil
+		}
+		return nil, fmt.Errorf("微信支付查单失败: %w", err)
+	}
+
+	resp := &plugin.QueryOrderResponse{
+		TradeStatus: transaction.TradeState,
+		TicketNo:    transaction.TransactionID,
+		PayMoney:    transaction.Amount.PayerTotal,
+		ExtraData: map[string]interface{}{
+			"success_time": transaction.SuccessTime,
+			"openid":       transaction.Payer.OpenID,
+		},
+	}
+	switch {
+	case transaction.IsPaid():
+		resp.TradeState = plugin.TradeStatePaid
+	case transaction.TradeState == wechat.TradeStateClosed || transaction.TradeState == wechat.TradeStateRevoked:
+		resp.TradeState = plugin.TradeStateClosed
+	default:
+		resp.TradeState = plugin.TradeStateWaiting
+	}
+	return resp, nil
+}
+
+// CloseOrder 关闭上游交易（微信支付通用实现）
+// 请求和响应由微信支付客户端记录到 dvadmin_query_log
+func (p *BasePlugin) CloseOrder(ctx context.Context, req *plugin.QueryOrderRequest) (*plugin.CloseOrderResponse, error) {
+	client, err := p.getTradeClient(req.ProductID, req.OrderNo, req.OutOrderNo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = client.CloseOrder(req.OrderNo)
+	switch {
+	case err == nil:
+		return &plugin.CloseOrderResponse{Closed: true, TradeState: plugin.TradeStateClosed}, nil
+	case wechat.IsOrderNotExist(err):
+		return &plugin.CloseOrderResponse{Closed: true, TradeState: plugin.TradeStateNotExist}, nil
+	case wechat.IsOrderPaid(err):
+		return &plugin.CloseOrderResponse{Closed: false, TradeState: plugin.TradeStatePaid, ErrorMessage: err.Error()}, nil
+	default:
+		if logger.Logger != nil {
+			logger.Logger.Warn("微信支付关闭订单失败",
+				zap.String("order_no", req.OrderNo),
+				zap.String("product_id", req.ProductID),
+				zap.Error(err))
+		}
+		return nil, fmt.Errorf("微信支付关闭订单失败: %w", err)
+	}
+}
+
+// Refund 退款（微信支付通用实现）
+// 微信退款为异步处理，PROCESSING 状态通过退款查询确认结果
+func (p *BasePlugin) Refund(ctx context.Context, req *plugin.RefundRequest) (*plugin.RefundResponse, error) {
+	client, err := p.getTradeClient(req.ProductID, req.OrderNo, req.OutOrderNo)
+	if err != nil {
+		return plugin.NewRefundErrorResponse(7327, err.Error()), nil
+	}
+
+	result, err := client.Refund(req.OrderNo, req.TicketNo, req.RefundNo, req.Reason, req.RefundMoney, req.Money)
+	if err != nil {
+		if logger.Logger != nil {
+			logger.Logger.Warn("微信支付退款失败",
+				zap.String("order_no", req.OrderNo),
+				zap.String("refund_no", req.RefundNo),
+				zap.Int("refund_money", req.RefundMoney),
+				zap.Error(err))
+		}
+		return nil, fmt.Errorf("微信支付退款失败: %w", err)
+	}
+
+	return refundResponse(req, result), nil
+}
+
+// QueryRefund 查询退款结果（微信支付通用实现）
+func (p *BasePlugin) QueryRefund(ctx context.Context, req *plugin.RefundRequest) (*plugin.RefundResponse, error) {
+	client, err := p.getTradeClient(req.ProductID, req.OrderNo, req.OutOrderNo)
+	if err != nil {
+		return plugin.NewRefundErrorResponse(7327, err.Error()), nil
+	}
+
+	result, err := client.QueryRefund(req.RefundNo)
+	if err != nil {
+		return nil, fmt.Errorf("微信支付退款查询失败: %w", err)
+	}
+
+	return refundResponse(req, result), nil
+}
+
+// refundResponse 根据微信退款状态构建退款响应
+// 退款处理中（PROCESSING）视为受理成功，与支付宝退款受理即成功保持一致
+func refundResponse(req *plugin.RefundRequest, result *wechat.RefundResult) *plugin.RefundResponse {
+	switch result.Status {
+	case wechat.RefundStatusSuccess, wechat.RefundStatusProcessing:
+		resp := plugin.NewRefundSuccessResponse(req.RefundNo, result.TransactionID, result.Amount.Refund)
+		resp.ExtraData = map[string]interface{}{
+			"refund_id":     result.RefundID,
+			"refund_status": result.Status,
+		}
+		return resp
+	default:
+		return plugin.NewRefundErrorResponse(7327, fmt.Sprintf("退款未成功: %s", result.Status))
+	}
+}
+
+// getTradeClient 根据订单产品创建微信支付客户端（查单、关单、退款不需要回调地址）
+func (p *BasePlugin) getTradeClient(productID, orderNo, outOrderNo string) (*wechat.Client, error) {
+	product, err := getProduct(productID)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(product, "", orderNo, outOrderNo)
+}
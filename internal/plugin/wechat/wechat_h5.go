@@ -0,0 +1,73 @@
+package wechat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang-pay-core/internal/plugin"
+)
+
+// H5Plugin 微信 H5 支付插件（手机浏览器中拉起微信支付）
+type H5Plugin struct {
+	*BasePlugin // 嵌入 BasePlugin（微信支付基类）
+}
+
+// NewH5Plugin 创建微信 H5 支付插件
+func NewH5Plugin(pluginID int64) *H5Plugin {
+	return &H5Plugin{
+		BasePlugin: NewBasePlugin(pluginID),
+	}
+}
+
+// CreateOrder 创建订单，返回 h5_url
+// 微信 H5 支付要求上报用户终端IP，从下单额外参数 extra 的 client_ip 字段获取
+func (p *H5Plugin) CreateOrder(ctx context.Context, req *plugin.CreateOrderRequest) (*plugin.CreateOrderResponse, error) {
+	productID, err := resolveProductID(req)
+	if err != nil {
+		return plugin.NewErrorResponse(7320, err.Error()), nil
+	}
+
+	clientIP := parseClientIP(req.Extra)
+	if clientIP == "" {
+		return plugin.NewErrorResponse(7320, "微信H5支付缺少用户终端IP(extra.client_ip)"), nil
+	}
+
+	product, err := getProduct(productID)
+	if err != nil {
+		return plugin.NewErrorResponse(7320, err.Error()), nil
+	}
+
+	notifyURL, err := buildNotifyURL(ctx, req.DomainURL, "wechat_h5", productID)
+	if err != nil {
+		return plugin.NewErrorResponse(7320, err.Error()), nil
+	}
+
+	client, err := newClient(product, notifyURL, req.OrderNo, req.OutOrderNo)
+	if err != nil {
+		return plugin.NewErrorResponse(7320, err.Error()), nil
+	}
+
+	prepayReq := p.buildPrepayRequest(ctx, req, product)
+	prepayReq.PayerClientIP = clientIP
+
+	h5URL, err := client.H5Prepay(prepayReq)
+	if err != nil {
+		return plugin.NewErrorResponse(7320, fmt.Sprintf("生成支付URL失败: %v", err)), nil
+	}
+
+	return plugin.NewSuccessResponse(h5URL), nil
+}
+
+// parseClientIP 从下单额外参数中解析用户终端IP
+func parseClientIP(extra string) string {
+	if extra == "" {
+		return ""
+	}
+	var extraMap map[string]interface{}
+	if err := json.Unmarshal([]byte(extra), &extraMap); err != nil {
+		return ""
+	}
+	clientIP, _ := extraMap["client_ip"].(string)
+	return clientIP
+}
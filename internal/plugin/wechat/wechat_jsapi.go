@@ -0,0 +1,123 @@
+package wechat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/plugin"
+	"github.com/golang-pay-core/internal/wechat"
+	"go.uber.org/zap"
+)
+
+// JSAPIPlugin 微信 JSAPI 支付插件（微信内网页支付）
+// JSAPI 下单需要用户 openid，因此下单时返回本系统的支付页地址：
+// 用户在微信内打开支付页 -> 网页授权获取 openid -> JSAPI 下单 -> WeixinJSBridge 调起支付
+type JSAPIPlugin struct {
+	*BasePlugin // 嵌入 BasePlugin（微信支付基类）
+}
+
+// NewJSAPIPlugin 创建微信 JSAPI 支付插件
+func NewJSAPIPlugin(pluginID int64) *JSAPIPlugin {
+	return &JSAPIPlugin{
+		BasePlugin: NewBasePlugin(pluginID),
+	}
+}
+
+// CreateOrder 创建订单，返回支付页地址 {domain}/api/pay/order/wechat_jsapi/{order_no}/
+func (p *JSAPIPlugin) CreateOrder(ctx context.Context, req *plugin.CreateOrderRequest) (*plugin.CreateOrderResponse, error) {
+	productID, err := resolveProductID(req)
+	if err != nil {
+		return plugin.NewErrorResponse(7320, err.Error()), nil
+	}
+
+	// 提前校验产品配置，避免用户进入支付页后才发现无法支付
+	product, err := getProduct(productID)
+	if err != nil {
+		return plugin.NewErrorResponse(7320, err.Error()), nil
+	}
+	if product.AppSecret == "" {
+		return plugin.NewErrorResponse(7320, "微信支付产品未配置应用密钥，无法使用JSAPI支付"), nil
+	}
+
+	if req.DomainURL == "" {
+		return plugin.NewErrorResponse(7320, "域名URL不能为空"), nil
+	}
+
+	payURL := fmt.Sprintf("%s/api/pay/order/wechat_jsapi/%s/", strings.TrimRight(req.DomainURL, "/"), req.OrderNo)
+	return plugin.NewSuccessResponse(payURL), nil
+}
+
+// AuthorizeURL 生成网页授权地址（静默授权，回调到支付页并携带 code）
+func (p *JSAPIPlugin) AuthorizeURL(orderDetail *models.OrderDetail, orderNo, redirectURI string) (string, error) {
+	product, err := getProduct(orderDetail.ProductID)
+	if err != nil {
+		return "", err
+	}
+	return (&wechat.Client{AppID: product.AppID}).OAuthAuthorizeURL(redirectURI, orderNo), nil
+}
+
+// PayParams 使用网页授权 code 获取 openid 并 JSAPI 下单，返回调起支付参数
+// domainURL 为支付页域名（未配置 wechat.notify_domain 时用于构建回调地址）
+func (p *JSAPIPlugin) PayParams(ctx context.Context, order *models.Order, orderDetail *models.OrderDetail, code, domainURL string) (*wechat.JSAPIPayParams, error) {
+	product, err := getProduct(orderDetail.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	notifyURL, err := buildNotifyURL(ctx, domainURL, "wechat_jsapi", orderDetail.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newClient(product, notifyURL, order.OrderNo, order.OutOrderNo)
+	if err != nil {
+		return nil, err
+	}
+
+	openID, err := client.OAuthOpenID(code)
+	if err != nil {
+		return nil, err
+	}
+
+	// 记录买家 openid
+	if err := database.DB.Model(&models.OrderDetail{}).
+		Where("id = ?", orderDetail.ID).
+		Update("buyer_id", openID).Error; err != nil {
+		logger.Logger.Warn("记录买家openid失败",
+			zap.String("order_no", order.OrderNo),
+			zap.Error(err))
+	}
+
+	// 过期时间以订单创建时间为准，用户重复进入支付页时下单参数保持一致
+	createTime := time.Now()
+	if order.CreateDatetime != nil {
+		createTime = *order.CreateDatetime
+	}
+	pluginID := p.GetPluginID()
+	if orderDetail.PluginID != nil {
+		pluginID = *orderDetail.PluginID
+	}
+	expireTime := createTime.Add(time.Duration(p.GetTimeout(ctx, pluginID)) * time.Second)
+
+	prepayID, err := client.JSAPIPrepay(&wechat.PrepayRequest{
+		Description: generateDescription(&plugin.CreateOrderRequest{
+			OrderNo:    order.OrderNo,
+			OutOrderNo: order.OutOrderNo,
+			Money:      order.Money,
+		}, product),
+		OutTradeNo: order.OrderNo,
+		Total:      order.Money,
+		TimeExpire: &expireTime,
+		OpenID:     openID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("JSAPI下单失败: %w", err)
+	}
+
+	return client.BuildJSAPIPayParams(prepayID)
+}
@@ -0,0 +1,50 @@
+package wechat
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golang-pay-core/internal/plugin"
+)
+
+// NativePlugin 微信 Native 支付插件（扫码支付）
+type NativePlugin struct {
+	*BasePlugin // 嵌入 BasePlugin（微信支付基类）
+}
+
+// NewNativePlugin 创建微信 Native 支付插件
+func NewNativePlugin(pluginID int64) *NativePlugin {
+	return &NativePlugin{
+		BasePlugin: NewBasePlugin(pluginID),
+	}
+}
+
+// CreateOrder 创建订单，返回二维码链接 code_url（weixin://wxpay/bizpayurl?pr=...）
+func (p *NativePlugin) CreateOrder(ctx context.Context, req *plugin.CreateOrderRequest) (*plugin.CreateOrderResponse, error) {
+	productID, err := resolveProductID(req)
+	if err != nil {
+		return plugin.NewErrorResponse(7320, err.Error()), nil
+	}
+
+	product, err := getProduct(productID)
+	if err != nil {
+		return plugin.NewErrorResponse(7320, err.Error()), nil
+	}
+
+	notifyURL, err := buildNotifyURL(ctx, req.DomainURL, "wechat_native", productID)
+	if err != nil {
+		return plugin.NewErrorResponse(7320, err.Error()), nil
+	}
+
+	client, err := newClient(product, notifyURL, req.OrderNo, req.OutOrderNo)
+	if err != nil {
+		return plugin.NewErrorResponse(7320, err.Error()), nil
+	}
+
+	codeURL, err := client.NativePrepay(p.buildPrepayRequest(ctx, req, product))
+	if err != nil {
+		return plugin.NewErrorResponse(7320, fmt.Sprintf("生成支付URL失败: %v", err)), nil
+	}
+
+	return plugin.NewSuccessResponse(codeURL), nil
+}
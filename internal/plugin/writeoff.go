@@ -52,23 +52,27 @@ func checkWriteoffBalance(ctx context.Context, redisClient *redis.Client, writeo
 // GetWriteoffIDsForPlugin 获取可用的核销ID列表（供插件使用）
 // 参考 Python: get_writeoff_ids(tenant_id, money, pay_channel_id=None)
 // 使用 Redis 检查码商余额，提高性能
+// 配置了白名单（writeoff.white）的码商只接收匹配 target 的订单
 // 导出此函数以便其他包（如 alipay）使用
-func GetWriteoffIDsForPlugin(tenantID int64, money int, payChannelID *int64) ([]int64, error) {
+func GetWriteoffIDsForPlugin(tenantID int64, money int, payChannelID *int64, target WriteoffWhitelistTarget) ([]int64, error) {
 	ctx := context.Background()
 	redisClient := database.RDB
 
-	// 先查询所有符合条件的核销ID（不检查余额）
-	var allWriteoffIDs []int64
+	// 先查询所有符合条件的核销ID和白名单（不检查余额）
+	var candidates []writeoffCandidate
 	query := database.DB.Model(&models.Writeoff{}).
 		Joins("JOIN dvadmin_system_users ON dvadmin_writeoff.system_user_id = dvadmin_system_users.id").
 		Where("dvadmin_writeoff.parent_id = ?", tenantID).
 		Where("dvadmin_system_users.status = ?", true).
 		Where("dvadmin_system_users.is_active = ?", true)
 
-	if err := query.Pluck("dvadmin_writeoff.id", &allWriteoffIDs).Error; err != nil {
+	if err := query.Select("dvadmin_writeoff.id, dvadmin_writeoff.white").Scan(&candidates).Error; err != nil {
 		return nil, fmt.Errorf("查询核销ID失败: %w", err)
 	}
 
+	// 过滤白名单不匹配的码商（在检查余额前过滤，减少 Redis 查询）
+	allWriteoffIDs := filterWriteoffWhitelist(candidates, target)
+
 	// 使用 Redis 检查每个码商的余额
 	writeoffIDs := make([]int64, 0)
 	for _, writeoffID := range allWriteoffIDs {
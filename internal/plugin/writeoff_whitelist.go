@@ -0,0 +1,182 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/utils"
+	"go.uber.org/zap"
+)
+
+// WriteoffWhitelistTarget 核销白名单匹配的订单信息
+type WriteoffWhitelistTarget struct {
+	MerchantID int64  // 商户ID
+	ClientIP   string // 用户IP（下单未传入 clientIp 时为空，配置了 IP 或地区白名单的核销不接收此类订单）
+}
+
+// writeoffWhitelist 解析后的核销白名单
+type writeoffWhitelist struct {
+	invalid   bool // 格式错误（按不匹配任何订单处理，避免放开限制）
+	merchants map[int64]bool
+	ips       map[string]bool
+	nets      []*net.IPNet
+	provinces []string
+	cities    []string
+}
+
+// empty 是否没有任何限制
+func (w *writeoffWhitelist) empty() bool {
+	return !w.invalid && len(w.merchants) == 0 && len(w.ips) == 0 && len(w.nets) == 0 &&
+		len(w.provinces) == 0 && len(w.cities) == 0
+}
+
+// needsLocation 是否需要查询用户IP归属地
+func (w *writeoffWhitelist) needsLocation() bool {
+	return len(w.provinces) > 0 || len(w.cities) > 0
+}
+
+// writeoffWhitelists 按原始配置缓存解析结果（核销白名单很少修改，避免每次选择核销都重复解析）
+var writeoffWhitelists sync.Map
+
+// parseWriteoffWhitelist 解析核销白名单（空值、[]、{}、null 表示不限制）
+func parseWriteoffWhitelist(writeoffID int64, raw string) *writeoffWhitelist {
+	if cached, ok := writeoffWhitelists.Load(raw); ok {
+		return cached.(*writeoffWhitelist)
+	}
+
+	w := &writeoffWhitelist{}
+	var policy models.WriteoffWhitelist
+	trimmed := strings.TrimSpace(raw)
+	switch {
+	case trimmed == "" || trimmed == "null":
+	case strings.HasPrefix(trimmed, "["):
+		// 旧格式：IP 列表
+		if err := json.Unmarshal([]byte(trimmed), &policy.IPs); err != nil {
+			w.invalid = true
+		}
+	default:
+		if err := json.Unmarshal([]byte(trimmed), &policy); err != nil {
+			w.invalid = true
+		}
+	}
+
+	if len(policy.MerchantIDs) > 0 {
+		w.merchants = make(map[int64]bool, len(policy.MerchantIDs))
+		for _, id := range policy.MerchantIDs {
+			w.merchants[id] = true
+		}
+	}
+	for _, entry := range policy.IPs {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+				w.nets = append(w.nets, ipNet)
+				continue
+			}
+		} else if ip := net.ParseIP(entry); ip != nil {
+			if w.ips == nil {
+				w.ips = make(map[string]bool)
+			}
+			w.ips[ip.String()] = true
+			continue
+		}
+		w.invalid = true
+	}
+	w.provinces = policy.Provinces
+	w.cities = policy.Cities
+
+	if w.invalid && logger.Logger != nil {
+		logger.Logger.Warn("核销白名单格式错误，该核销不接收任何订单",
+			zap.Int64("writeoff_id", writeoffID),
+			zap.String("white", raw))
+	}
+
+	writeoffWhitelists.Store(raw, w)
+	return w
+}
+
+// match 订单是否匹配白名单；归属地未知时地区规则不匹配
+func (w *writeoffWhitelist) match(target WriteoffWhitelistTarget, location *utils.IPLocationInfo) bool {
+	if w.invalid {
+		return false
+	}
+	if len(w.merchants) > 0 && !w.merchants[target.MerchantID] {
+		return false
+	}
+
+	if len(w.ips) > 0 || len(w.nets) > 0 {
+		ip := net.ParseIP(target.ClientIP)
+		if ip == nil {
+			return false
+		}
+		matched := w.ips[ip.String()]
+		for _, ipNet := range w.nets {
+			if matched {
+				break
+			}
+			matched = ipNet.Contains(ip)
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if w.needsLocation() {
+		if location == nil {
+			return false
+		}
+		if len(w.provinces) > 0 && !utils.MatchRegion(location.Province, w.provinces) {
+			return false
+		}
+		if len(w.cities) > 0 && !utils.MatchRegion(location.City, w.cities) {
+			return false
+		}
+	}
+	return true
+}
+
+// writeoffCandidate 待选核销（ID 和白名单原始配置）
+type writeoffCandidate struct {
+	ID    int64  `gorm:"column:id"`
+	White string `gorm:"column:white"`
+}
+
+// filterWriteoffWhitelist 过滤白名单不匹配订单的核销，没有配置白名单的核销不受影响
+func filterWriteoffWhitelist(candidates []writeoffCandidate, target WriteoffWhitelistTarget) []int64 {
+	var (
+		location        *utils.IPLocationInfo
+		locationFetched bool
+	)
+	writeoffIDs := make([]int64, 0, len(candidates))
+	for _, candidate := range candidates {
+		w := parseWriteoffWhitelist(candidate.ID, candidate.White)
+		if w.empty() {
+			writeoffIDs = append(writeoffIDs, candidate.ID)
+			continue
+		}
+		if w.needsLocation() && !locationFetched {
+			locationFetched = true
+			if target.ClientIP != "" {
+				if info, err := utils.GetIPLocation(target.ClientIP); err == nil && info.Province != "" {
+					location = info
+				}
+			}
+		}
+		if w.match(target, location) {
+			writeoffIDs = append(writeoffIDs, candidate.ID)
+		} else if logger.Logger != nil {
+			logger.Logger.Debug("核销白名单不匹配，跳过该核销",
+				zap.Int64("writeoff_id", candidate.ID),
+				zap.Int64("merchant_id", target.MerchantID),
+				zap.String("client_ip", target.ClientIP))
+		}
+	}
+	return writeoffIDs
+}
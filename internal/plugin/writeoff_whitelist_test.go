@@ -0,0 +1,55 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestWriteoffWhitelistMatch(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	hangzhou := &utils.IPLocationInfo{Province: "浙江省", City: "杭州市"}
+
+	cases := []struct {
+		name     string
+		white    string
+		target   WriteoffWhitelistTarget
+		location *utils.IPLocationInfo
+		want     bool
+	}{
+		{"商户匹配", `{"merchant_ids":[1,2]}`, WriteoffWhitelistTarget{MerchantID: 2}, nil, true},
+		{"商户不匹配", `{"merchant_ids":[1,2]}`, WriteoffWhitelistTarget{MerchantID: 3}, nil, false},
+		{"网段匹配", `{"ips":["10.0.0.0/8"]}`, WriteoffWhitelistTarget{ClientIP: "10.1.2.3"}, nil, true},
+		{"没有用户IP", `{"ips":["10.0.0.0/8"]}`, WriteoffWhitelistTarget{MerchantID: 1}, nil, false},
+		{"旧格式IP列表", `["1.2.3.4"]`, WriteoffWhitelistTarget{ClientIP: "1.2.3.4"}, nil, true},
+		{"城市匹配", `{"provinces":["浙江"],"cities":["杭州"]}`, WriteoffWhitelistTarget{ClientIP: "5.6.7.8"}, hangzhou, true},
+		{"归属地未知", `{"provinces":["浙江"]}`, WriteoffWhitelistTarget{ClientIP: "5.6.7.8"}, nil, false},
+		{"多个维度都必须匹配", `{"merchant_ids":[1],"cities":["杭州"]}`, WriteoffWhitelistTarget{MerchantID: 2, ClientIP: "5.6.7.8"}, hangzhou, false},
+		{"格式错误", `{"ips":["bad"]}`, WriteoffWhitelistTarget{ClientIP: "1.2.3.4"}, nil, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := parseWriteoffWhitelist(1, tc.white)
+			assert.False(t, w.empty())
+			assert.Equal(t, tc.want, w.match(tc.target, tc.location))
+		})
+	}
+
+	for _, raw := range []string{"", "null", "[]", "{}"} {
+		assert.True(t, parseWriteoffWhitelist(1, raw).empty(), raw)
+	}
+}
+
+func TestFilterWriteoffWhitelist(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	candidates := []writeoffCandidate{
+		{ID: 1, White: "[]"},
+		{ID: 2, White: `{"merchant_ids":[10]}`},
+		{ID: 3, White: `{"merchant_ids":[20]}`},
+	}
+	assert.Equal(t, []int64{1, 2}, filterWriteoffWhitelist(candidates, WriteoffWhitelistTarget{MerchantID: 10}))
+	assert.Equal(t, []int64{1}, filterWriteoffWhitelist(candidates, WriteoffWhitelistTarget{MerchantID: 30}))
+}
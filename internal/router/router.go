@@ -5,7 +5,11 @@ import (
 	"github.com/golang-pay-core/config"
 	"github.com/golang-pay-core/internal/controller"
 	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/lifecycle"
 	"github.com/golang-pay-core/internal/middleware"
+	gatewayplugin "github.com/golang-pay-core/internal/plugin/gateway"
+	wechatplugin "github.com/golang-pay-core/internal/plugin/wechat"
+	"github.com/golang-pay-core/internal/service"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
@@ -46,13 +50,32 @@ func SetupRouter() *gin.Engine {
 		orderController := controller.NewOrderController()
 		orders := api.Group("/orders")
 		{
-			orders.POST("", orderController.CreateOrder)       // 创建订单（POST）
-			orders.GET("", orderController.CreateOrder)        // 创建订单（GET）
-			orders.GET("/:order_no", orderController.GetOrder) // 获取订单
-			orders.GET("/query", orderController.QueryOrder)   // 查询订单
+			orders.POST("", orderController.CreateOrder)                                // 创建订单（POST）
+			orders.GET("", orderController.CreateOrder)                                 // 创建订单（GET）
+			orders.GET("/:order_no", orderController.GetOrder)                          // 获取订单
+			orders.GET("/query", orderController.QueryOrder)                            // 查询订单
+			orders.POST("/:order_no/refund", orderController.RefundOrder)               // 订单退款
+			orders.POST("/:order_no/renotify", orderController.Renotify)                // 重新通知
+			orders.GET("/:order_no/notifications", orderController.NotificationHistory) // 通知记录
 		}
 	}
 
+	// 管理接口路由（需要 Token 或 IP 白名单认证）
+	adminController := controller.NewAdminController()
+	admin := api.Group("/admin")
+	admin.Use(middleware.AdminAuth())
+	{
+		admin.POST("/orders/:order_no/query-upstream", adminController.QueryUpstream)     // 上游查单（补单）
+		admin.POST("/orders/:order_no/renotify", adminController.Renotify)                // 重新通知商户
+		admin.GET("/orders/:order_no/notifications", adminController.NotificationHistory) // 通知记录
+		admin.GET("/dead-letters", adminController.ListDeadLetters)                       // 消息死信列表
+		admin.GET("/dead-letters/:id", adminController.GetDeadLetter)                     // 消息死信详情
+		admin.POST("/dead-letters/:id/replay", adminController.ReplayDeadLetter)          // 重放消息死信
+		admin.GET("/risk/blacklist", adminController.ListRiskBlacklist)                   // 风控黑名单列表
+		admin.POST("/risk/blacklist", adminController.AddRiskBlacklist)                   // 添加风控黑名单
+		admin.DELETE("/risk/blacklist/:id", adminController.RemoveRiskBlacklist)          // 删除风控黑名单
+	}
+
 	// 支付相关路由
 	payController := controller.NewPayController()
 	pay := r.Group("/api/v1/pay")
@@ -62,7 +85,17 @@ func SetupRouter() *gin.Engine {
 	}
 
 	// 收银台路由（不需要 /api/v1 前缀，参考 Python 代码）
-	r.GET("/cashier", payController.Cashier) // 收银台页面
+	r.GET("/cashier", payController.Cashier)                                   // 收银台页面
+	r.GET("/api/pay/order/wechat_jsapi/:order_no/", payController.WechatJSAPI) // 微信JSAPI支付页（网页授权后调起支付）
+
+	// 易支付（EPay）协议兼容路由（兼容易支付 SDK 的接口地址）
+	epayController := controller.NewEPayController()
+	r.GET("/submit.php", epayController.Submit)                      // 页面跳转支付
+	r.POST("/submit.php", epayController.Submit)                     // 页面跳转支付
+	r.POST("/mapi.php", epayController.MAPI)                         // API 下单
+	r.GET("/api.php", epayController.API)                            // 查单、商户信息、退款
+	r.POST("/api.php", epayController.API)                           // 查单、商户信息、退款
+	r.GET("/api/pay/order/return/:order_no/", epayController.Return) // 同步跳转（携带签名参数）
 
 	// 回调相关路由
 	// 参考 Python: /api/pay/order/notify/{plugin_type}/{product_id}/
@@ -70,12 +103,21 @@ func SetupRouter() *gin.Engine {
 	r.POST("/api/pay/order/notify/:plugin_type/:product_id/", notifyController.AlipayNotify) // 支付宝回调（POST）
 	r.GET("/api/pay/order/notify/:plugin_type/:product_id/", notifyController.AlipayNotify)  // 支付宝回调（GET，兼容）
 
+	// 微信支付回调（APIv3，JSON 加密通知，仅 POST）
+	for _, pluginType := range wechatplugin.PluginTypes {
+		r.POST("/api/pay/order/notify/"+pluginType+"/:product_id/", notifyController.WechatNotify(pluginType))
+	}
+
+	// 上游网关回调（按插件配置验签，产品ID即插件ID）
+	r.POST("/api/pay/order/notify/"+gatewayplugin.PluginType+"/:product_id/", notifyController.GatewayNotify)
+	r.GET("/api/pay/order/notify/"+gatewayplugin.PluginType+"/:product_id/", notifyController.GatewayNotify)
+
 	return r
 }
 
 // healthCheck 健康检查端点
 // @Summary 健康检查
-// @Description 检查服务健康状态，包括数据库和 Redis 连接状态
+// @Description 检查服务健康状态，包括数据库和 Redis 连接状态、主节点选举状态
 // @Tags 系统
 // @Produce json
 // @Success 200 {object} map[string]interface{}
@@ -131,5 +173,11 @@ func healthCheck(c *gin.Context) {
 		}
 	}
 
+	// 商户通知队列状态
+	health["notify"] = service.GetNotifyDispatcher().Stats(c.Request.Context())
+
+	// 主节点选举状态（单实例服务只在主节点上运行）
+	health["leader"] = lifecycle.GetElector().Status(c.Request.Context())
+
 	c.JSON(200, health)
 }
@@ -0,0 +1,177 @@
+// Package safehttp 提供带 SSRF 防护的出站 HTTP 客户端
+// 用于访问商户或上游提供的地址：解析主机名后拒绝内网、环回、链路本地等地址，
+// 并直接连接校验过的 IP（防止 DNS 重绑定）；同时限制协议、端口、重定向次数和响应体大小
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrForbiddenTarget 目标地址不允许访问
+	ErrForbiddenTarget = errors.New("目标地址不允许访问")
+	// ErrResponseTooLarge 响应体超过大小限制
+	ErrResponseTooLarge = errors.New("响应体超过大小限制")
+)
+
+// transports 按策略复用的 Transport（保持连接池）
+var transports sync.Map
+
+// NewClient 使用默认策略创建 HTTP 客户端，timeout 为 0 时使用策略超时
+func NewClient(timeout time.Duration) *http.Client {
+	return NewClientWithPolicy(DefaultPolicy(), timeout)
+}
+
+// NewClientWithPolicy 使用指定策略创建 HTTP 客户端，timeout 为 0 时使用策略超时
+func NewClientWithPolicy(policy *Policy, timeout time.Duration) *http.Client {
+	if timeout <= 0 {
+		timeout = policy.Timeout
+	}
+	maxRedirects := policy.MaxRedirects
+	return &http.Client{
+		Transport: transportFor(policy),
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return fmt.Errorf("重定向次数超过限制(%d)", maxRedirects)
+			}
+			return nil
+		},
+	}
+}
+
+// transportFor 获取策略对应的 Transport
+func transportFor(policy *Policy) http.RoundTripper {
+	key := policy.key()
+	if rt, ok := transports.Load(key); ok {
+		return rt.(http.RoundTripper)
+	}
+
+	compiled := policy.compile()
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	rt := &roundTripper{
+		policy: compiled,
+		base: &http.Transport{
+			Proxy: nil, // 不使用环境变量中的代理，避免绕过地址校验
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return compiled.dialContext(ctx, dialer, network, addr)
+			},
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   10,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+	actual, _ := transports.LoadOrStore(key, rt)
+	return actual.(http.RoundTripper)
+}
+
+// roundTripper 发送前校验地址，并限制响应体大小
+type roundTripper struct {
+	policy *compiledPolicy
+	base   *http.Transport
+}
+
+// RoundTrip 实现 http.RoundTripper（重定向的每一跳都会经过这里）
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.policy.checkURL(req.URL); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if t.policy.maxBytes > 0 {
+		if resp.ContentLength > t.policy.maxBytes {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%w: Content-Length %d 超过 %d", ErrResponseTooLarge, resp.ContentLength, t.policy.maxBytes)
+		}
+		resp.Body = &limitedBody{rc: resp.Body, remaining: t.policy.maxBytes}
+	}
+	return resp, nil
+}
+
+// dialContext 解析主机名并逐个校验 IP，直接连接校验通过的 IP
+func (c *compiledPolicy) dialContext(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkPort(port); err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ipAddr := range addrs {
+			ips = append(ips, ipAddr.IP)
+		}
+	}
+
+	hostAllowed := c.hostAllowed(host)
+	lastErr := fmt.Errorf("%w: %s 没有可用的地址", ErrForbiddenTarget, host)
+	for _, ip := range ips {
+		if !hostAllowed && !c.ipAllowed(ip) {
+			lastErr = fmt.Errorf("%w: %s 解析到内网或保留地址 %s", ErrForbiddenTarget, host, ip)
+			continue
+		}
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// limitedBody 超过大小限制时返回 ErrResponseTooLarge 的响应体
+type limitedBody struct {
+	rc        io.ReadCloser
+	remaining int64
+}
+
+// Read 实现 io.Reader
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// 已读满上限，再探测一个字节判断是否超限
+		var probe [1]byte
+		n, err := b.rc.Read(probe[:])
+		if n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.rc.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// Close 实现 io.Closer
+func (b *limitedBody) Close() error {
+	return b.rc.Close()
+}
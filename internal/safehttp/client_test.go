@@ -0,0 +1,110 @@
+package safehttp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// serverPolicy 返回只允许访问测试服务端口的策略
+func serverPolicy(t *testing.T, server *httptest.Server, allowHosts ...string) *Policy {
+	t.Helper()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(u.Port())
+	policy := DefaultPolicy()
+	policy.Ports = []int{port}
+	policy.AllowHosts = allowHosts
+	return policy
+}
+
+func TestIsReservedIP(t *testing.T) {
+	reserved := []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"100.64.0.1", "0.0.0.0", "::1", "fe80::1", "fd00::1", "::ffff:127.0.0.1", "64:ff9b::a00:1"}
+	for _, addr := range reserved {
+		if !IsReservedIP(net.ParseIP(addr)) {
+			t.Errorf("%s should be reserved", addr)
+		}
+	}
+	public := []string{"8.8.8.8", "110.242.68.66", "2400:3200::1"}
+	for _, addr := range public {
+		if IsReservedIP(net.ParseIP(addr)) {
+			t.Errorf("%s should not be reserved", addr)
+		}
+	}
+}
+
+func TestClientBlocksPrivateTargets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("success"))
+	}))
+	defer server.Close()
+
+	// 默认拒绝环回地址（字面量 IP 和解析到环回地址的主机名）
+	client := NewClientWithPolicy(serverPolicy(t, server), 0)
+	for _, target := range []string{server.URL, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)} {
+		if _, err := client.Get(target); !errors.Is(err, ErrForbiddenTarget) {
+			t.Fatalf("GET %s err = %v, want ErrForbiddenTarget", target, err)
+		}
+	}
+
+	// 不在允许列表中的协议和端口
+	if _, err := client.Get("ftp://example.com/"); !errors.Is(err, ErrForbiddenTarget) {
+		t.Fatalf("ftp err = %v", err)
+	}
+	if _, err := client.Get("http://example.com:22/"); !errors.Is(err, ErrForbiddenTarget) {
+		t.Fatalf("port 22 err = %v", err)
+	}
+
+	// 白名单放行
+	client = NewClientWithPolicy(serverPolicy(t, server, "127.0.0.0/8"), 0)
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("allowed GET: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestClientLimitsRedirectsAndResponseSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/metadata":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+		default:
+			w.(http.Flusher).Flush() // 分块传输，不带 Content-Length
+			w.Write([]byte(strings.Repeat("a", 2048)))
+		}
+	}))
+	defer server.Close()
+
+	policy := serverPolicy(t, server, "127.0.0.1")
+	policy.Ports = append(policy.Ports, 80)
+	policy.MaxResponseBytes = 1024
+	client := NewClientWithPolicy(policy, 0)
+
+	if _, err := client.Get(server.URL + "/loop"); err == nil || !strings.Contains(err.Error(), "重定向次数超过限制") {
+		t.Fatalf("redirect loop err = %v", err)
+	}
+	if _, err := client.Get(server.URL + "/metadata"); !errors.Is(err, ErrForbiddenTarget) {
+		t.Fatalf("redirect to metadata err = %v", err)
+	}
+
+	resp, err := client.Get(server.URL + "/large")
+	if err != nil {
+		t.Fatalf("GET large: %v", err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("read large err = %v", err)
+	}
+}
@@ -0,0 +1,252 @@
+package safehttp
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-pay-core/config"
+)
+
+// 默认出站策略（配置缺失时使用）
+const (
+	DefaultMaxRedirects     = 3
+	DefaultMaxResponseBytes = 1 << 20
+	DefaultTimeout          = 10 * time.Second
+)
+
+var (
+	defaultSchemes = []string{"http", "https"}
+	defaultPorts   = []int{80, 443, 8080, 8443}
+)
+
+// Policy 出站请求策略
+type Policy struct {
+	Schemes          []string      // 允许的协议
+	Ports            []int         // 允许的端口
+	AllowHosts       []string      // 放行的主机名（支持 *.example.com）、IP 或 CIDR，命中后允许访问内网地址
+	MaxRedirects     int           // 最大重定向次数
+	MaxResponseBytes int64         // 响应体大小上限（字节）
+	Timeout          time.Duration // 请求超时
+}
+
+// DefaultPolicy 返回配置文件中的出站策略（outbound 配置），未配置的项使用默认值
+func DefaultPolicy() *Policy {
+	policy := &Policy{
+		Schemes:          defaultSchemes,
+		Ports:            defaultPorts,
+		MaxRedirects:     DefaultMaxRedirects,
+		MaxResponseBytes: DefaultMaxResponseBytes,
+		Timeout:          DefaultTimeout,
+	}
+
+	if config.Cfg == nil {
+		return policy
+	}
+	cfg := config.Cfg.Outbound
+	if len(cfg.AllowedSchemes) > 0 {
+		policy.Schemes = cfg.AllowedSchemes
+	}
+	if len(cfg.AllowedPorts) > 0 {
+		policy.Ports = cfg.AllowedPorts
+	}
+	policy.AllowHosts = cfg.AllowHosts
+	if cfg.MaxRedirects >= 0 {
+		policy.MaxRedirects = cfg.MaxRedirects
+	}
+	if cfg.MaxResponseBytes > 0 {
+		policy.MaxResponseBytes = cfg.MaxResponseBytes
+	}
+	if cfg.Timeout > 0 {
+		policy.Timeout = cfg.Timeout
+	}
+	return policy
+}
+
+// With 返回追加了放行主机和端口的策略副本（用于租户白名单覆盖）
+func (p *Policy) With(hosts []string, ports []int) *Policy {
+	policy := *p
+	policy.AllowHosts = append(append([]string{}, p.AllowHosts...), hosts...)
+	policy.Ports = append(append([]int{}, p.Ports...), ports...)
+	return &policy
+}
+
+// CheckURL 校验请求地址的协议、端口，以及字面量 IP 地址是否允许访问
+// 主机名在建立连接时解析后再校验（见 dialContext）
+func (p *Policy) CheckURL(u *url.URL) error {
+	return p.compile().checkURL(u)
+}
+
+// key 策略的唯一标识（用于复用 Transport）
+func (p *Policy) key() string {
+	schemes := append([]string{}, p.Schemes...)
+	sort.Strings(schemes)
+	ports := append([]int{}, p.Ports...)
+	sort.Ints(ports)
+	hosts := append([]string{}, p.AllowHosts...)
+	sort.Strings(hosts)
+	return fmt.Sprintf("%v|%v|%v|%d|%d", schemes, ports, hosts, p.MaxRedirects, p.MaxResponseBytes)
+}
+
+// compiledPolicy 预处理后的策略
+type compiledPolicy struct {
+	schemes      map[string]bool
+	ports        map[int]bool
+	hosts        map[string]bool
+	hostSuffixes []string
+	nets         []*net.IPNet
+	maxBytes     int64
+}
+
+// compile 预处理策略
+func (p *Policy) compile() *compiledPolicy {
+	c := &compiledPolicy{
+		schemes:  make(map[string]bool),
+		ports:    make(map[int]bool),
+		hosts:    make(map[string]bool),
+		maxBytes: p.MaxResponseBytes,
+	}
+	for _, scheme := range p.Schemes {
+		c.schemes[strings.ToLower(strings.TrimSpace(scheme))] = true
+	}
+	for _, port := range p.Ports {
+		c.ports[port] = true
+	}
+	for _, entry := range p.AllowHosts {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case strings.HasPrefix(entry, "*."):
+			c.hostSuffixes = append(c.hostSuffixes, entry[1:])
+		case strings.Contains(entry, "/"):
+			if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+				c.nets = append(c.nets, ipNet)
+			}
+		default:
+			if ip := net.ParseIP(entry); ip != nil {
+				c.nets = append(c.nets, singleIPNet(ip))
+			} else {
+				c.hosts[entry] = true
+			}
+		}
+	}
+	return c
+}
+
+// checkURL 校验协议、端口和字面量 IP
+func (c *compiledPolicy) checkURL(u *url.URL) error {
+	scheme := strings.ToLower(u.Scheme)
+	if !c.schemes[scheme] {
+		return fmt.Errorf("%w: 协议 %s 不在允许列表中", ErrForbiddenTarget, u.Scheme)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("%w: 缺少主机名", ErrForbiddenTarget)
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if scheme == "https" {
+			port = "443"
+		}
+	}
+	if err := c.checkPort(port); err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip != nil && !c.ipAllowed(ip) {
+		return fmt.Errorf("%w: %s 为内网或保留地址", ErrForbiddenTarget, host)
+	}
+	return nil
+}
+
+// checkPort 校验端口
+func (c *compiledPolicy) checkPort(port string) error {
+	portNum, err := strconv.Atoi(port)
+	if err != nil || !c.ports[portNum] {
+		return fmt.Errorf("%w: 端口 %s 不在允许列表中", ErrForbiddenTarget, port)
+	}
+	return nil
+}
+
+// hostAllowed 主机名是否在放行列表中
+func (c *compiledPolicy) hostAllowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if c.hosts[host] {
+		return true
+	}
+	for _, suffix := range c.hostSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipAllowed IP 是否允许访问：放行列表中的地址直接允许，否则拒绝内网和保留地址
+func (c *compiledPolicy) ipAllowed(ip net.IP) bool {
+	for _, ipNet := range c.nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return !IsReservedIP(ip)
+}
+
+// reservedNets 除 net.IP 内置判断外需要拒绝的保留地址段
+var reservedNets = mustParseCIDRs(
+	"0.0.0.0/8",       // 本网络
+	"100.64.0.0/10",   // 运营商级 NAT
+	"192.0.0.0/24",    // IETF 协议分配
+	"192.0.2.0/24",    // 文档地址 TEST-NET-1
+	"198.18.0.0/15",   // 基准测试
+	"198.51.100.0/24", // 文档地址 TEST-NET-2
+	"203.0.113.0/24",  // 文档地址 TEST-NET-3
+	"240.0.0.0/4",     // 保留地址及广播地址
+	"64:ff9b::/96",    // NAT64（可映射到内网 IPv4）
+	"64:ff9b:1::/48",  // 本地 NAT64
+	"100::/64",        // 丢弃前缀
+	"2001:db8::/32",   // 文档地址
+	"fec0::/10",       // 站点本地地址（已废弃）
+)
+
+// IsReservedIP 是否为环回、内网、链路本地（含云厂商元数据地址 169.254.169.254）、组播或其他保留地址
+func IsReservedIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, ipNet := range reservedNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// singleIPNet 将单个 IP 转换为掩码全 1 的网段
+func singleIPNet(ip net.IP) *net.IPNet {
+	if v4 := ip.To4(); v4 != nil {
+		return &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+// mustParseCIDRs 解析网段列表
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang-pay-core/internal/alipay"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+// alipayBillDiffsTotal 支付宝账单对账差异数
+var alipayBillDiffsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "alipay_bill_diffs_total",
+		Help: "支付宝账单对账发现的差异数（missing 本地已支付账单无交易、extra 账单有交易本地未支付、amount_mismatch 金额不一致）",
+	},
+	[]string{"type"},
+)
+
+// alipayBillPaidStatuses 本地视为已支付的订单状态（已退款的订单在支付当天的账单中仍有交易）
+var alipayBillPaidStatuses = []int{models.OrderStatusPaid, models.OrderStatusRefunded, models.OrderStatusPaidNoNotify}
+
+// alipayBillQueryBatch 按单号批量查询订单的批大小
+const alipayBillQueryBatch = 500
+
+// AlipayBillReconcileResult 支付宝账单对账结果
+type AlipayBillReconcileResult struct {
+	RunID     string
+	ProductID int64
+	BillDate  string
+	Trades    int                     // 账单中的交易笔数
+	Refunds   int                     // 账单中的退款笔数（不参与对账）
+	Matched   int                     // 金额一致的交易笔数
+	Diffs     []models.AlipayBillDiff // 差异明细
+}
+
+// billOrder 对账使用的本地订单
+type billOrder struct {
+	ID          string `gorm:"column:id"`
+	OrderNo     string `gorm:"column:order_no"`
+	Money       int    `gorm:"column:money"`
+	OrderStatus int    `gorm:"column:order_status"`
+	TicketNo    string `gorm:"column:ticket_no"`
+	ProductID   string `gorm:"column:product_id"`
+}
+
+// AlipayBillReconcileService 支付宝账单对账服务
+// 下载（或读取本地的）支付宝业务账单，按 out_trade_no（系统订单号 order_no）或支付宝交易号（ticket_no）
+// 与本地订单逐笔核对，记录本地已支付但账单中没有、账单中有但本地未支付、金额不一致的交易
+type AlipayBillReconcileService struct{}
+
+// NewAlipayBillReconcileService 创建支付宝账单对账服务
+func NewAlipayBillReconcileService() *AlipayBillReconcileService {
+	return &AlipayBillReconcileService{}
+}
+
+// FetchTradeBill 下载支付宝产品某天的业务账单（zip 压缩包）
+func (s *AlipayBillReconcileService) FetchTradeBill(productID int64, billDate time.Time) ([]byte, error) {
+	product, err := alipay.GetAlipayProductByID(strconv.FormatInt(productID, 10))
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := alipay.NewClient(product, "", true)
+	if err != nil {
+		return nil, fmt.Errorf("创建支付宝客户端失败: %w", err)
+	}
+
+	downloadURL, err := client.BillDownloadURLQuery(alipay.BillTypeTrade, billDate.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("查询对账单下载地址失败: %w", err)
+	}
+	return client.DownloadBill(downloadURL)
+}
+
+// ReconcileFile 使用本地账单文件对账（zip 压缩包或解压后的 csv，不需要访问支付宝）
+func (s *AlipayBillReconcileService) ReconcileFile(ctx context.Context, productID int64, billDate time.Time, path string) (*AlipayBillReconcileResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取账单文件失败: %w", err)
+	}
+	return s.reconcileData(ctx, productID, billDate, data)
+}
+
+// ReconcileDownload 下载支付宝账单后对账
+func (s *AlipayBillReconcileService) ReconcileDownload(ctx context.Context, productID int64, billDate time.Time) (*AlipayBillReconcileResult, error) {
+	data, err := s.FetchTradeBill(productID, billDate)
+	if err != nil {
+		return nil, err
+	}
+	return s.reconcileData(ctx, productID, billDate, data)
+}
+
+// reconcileData 解析账单后对账
+func (s *AlipayBillReconcileService) reconcileData(ctx context.Context, productID int64, billDate time.Time, data []byte) (*AlipayBillReconcileResult, error) {
+	lines, err := alipay.ParseTradeBill(data)
+	if err != nil {
+		return nil, err
+	}
+	return s.Reconcile(ctx, productID, billDate, lines)
+}
+
+// Reconcile 核对账单明细与本地订单，写入差异记录
+// 账单按交易完成时间出账，本地按订单支付时间 pay_datetime 统计当天已支付订单；
+// 跨零点完成的交易可能在相邻两天分别表现为 missing 和 extra
+func (s *AlipayBillReconcileService) Reconcile(ctx context.Context, productID int64, billDate time.Time, lines []alipay.TradeBillLine) (*AlipayBillReconcileResult, error) {
+	day := time.Date(billDate.Year(), billDate.Month(), billDate.Day(), 0, 0, 0, 0, billDate.Location())
+	result := &AlipayBillReconcileResult{
+		RunID:     utils.GenerateID(),
+		ProductID: productID,
+		BillDate:  day.Format("2006-01-02"),
+	}
+	productIDStr := strconv.FormatInt(productID, 10)
+
+	var trades []alipay.TradeBillLine
+	for _, line := range lines {
+		if line.BizType == alipay.BillBizTypeRefund {
+			result.Refunds++
+			continue
+		}
+		trades = append(trades, line)
+	}
+	result.Trades = len(trades)
+
+	byOrderNo, byTicketNo, err := s.loadBillOrders(ctx, trades)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make(map[string]bool, len(trades))
+	for _, line := range trades {
+		order, ok := byOrderNo[line.OutTradeNo]
+		if !ok {
+			order, ok = byTicketNo[line.TradeNo]
+		}
+
+		diff := models.AlipayBillDiff{
+			OrderNo:   line.OutTradeNo,
+			TradeNo:   line.TradeNo,
+			BillMoney: line.TotalAmount,
+		}
+		switch {
+		case !ok:
+			diff.DiffType = models.AlipayBillDiffExtra
+			diff.Remarks = "本地没有对应订单"
+		case !isBillPaidStatus(order.OrderStatus):
+			diff.DiffType = models.AlipayBillDiffExtra
+			diff.Remarks = fmt.Sprintf("本地订单未支付，状态 %d", order.OrderStatus)
+		case order.Money != line.TotalAmount:
+			diff.DiffType = models.AlipayBillDiffAmountMismatch
+		case order.ProductID != productIDStr:
+			diff.DiffType = models.AlipayBillDiffExtra
+			diff.Remarks = fmt.Sprintf("本地订单属于产品 %s", order.ProductID)
+		default:
+			matched[order.ID] = true
+			result.Matched++
+			continue
+		}
+		if ok {
+			matched[order.ID] = true
+			diff.OrderID = order.ID
+			diff.LocalMoney = order.Money
+		}
+		result.Diffs = append(result.Diffs, diff)
+	}
+
+	// 本地当天已支付但账单中没有的订单
+	var paid []billOrder
+	if err := database.DB.WithContext(ctx).Table("dvadmin_order AS o").
+		Select("o.id, o.order_no, o.money, o.order_status, d.ticket_no, d.product_id").
+		Joins("JOIN dvadmin_order_detail AS d ON d.order_id = o.id").
+		Where("d.product_id = ? AND o.order_status IN ? AND o.pay_datetime >= ? AND o.pay_datetime < ?",
+			productIDStr, alipayBillPaidStatuses, day, day.AddDate(0, 0, 1)).
+		Scan(&paid).Error; err != nil {
+		return nil, fmt.Errorf("查询本地已支付订单失败: %w", err)
+	}
+	for _, order := range paid {
+		if matched[order.ID] {
+			continue
+		}
+		result.Diffs = append(result.Diffs, models.AlipayBillDiff{
+			DiffType:   models.AlipayBillDiffMissing,
+			OrderID:    order.ID,
+			OrderNo:    order.OrderNo,
+			TradeNo:    order.TicketNo,
+			LocalMoney: order.Money,
+			Remarks:    fmt.Sprintf("订单状态 %d", order.OrderStatus),
+		})
+	}
+
+	sort.SliceStable(result.Diffs, func(i, j int) bool {
+		return result.Diffs[i].DiffType < result.Diffs[j].DiffType
+	})
+	now := time.Now()
+	for i := range result.Diffs {
+		result.Diffs[i].RunID = result.RunID
+		result.Diffs[i].ProductID = productID
+		result.Diffs[i].BillDate = result.BillDate
+		result.Diffs[i].CreateDatetime = &now
+		alipayBillDiffsTotal.WithLabelValues(result.Diffs[i].DiffType).Inc()
+	}
+	if len(result.Diffs) > 0 {
+		if err := database.DB.WithContext(ctx).CreateInBatches(&result.Diffs, alipayBillQueryBatch).Error; err != nil {
+			return result, fmt.Errorf("写入账单对账差异失败: %w", err)
+		}
+	}
+
+	logger.Logger.Info("支付宝账单对账完成",
+		zap.String("run_id", result.RunID),
+		zap.Int64("product_id", productID),
+		zap.String("bill_date", result.BillDate),
+		zap.Int("trades", result.Trades),
+		zap.Int("matched", result.Matched),
+		zap.Int("diffs", len(result.Diffs)))
+	return result, nil
+}
+
+// loadBillOrders 按商户订单号、支付宝交易号批量查询账单交易对应的本地订单
+func (s *AlipayBillReconcileService) loadBillOrders(ctx context.Context, trades []alipay.TradeBillLine) (map[string]billOrder, map[string]billOrder, error) {
+	orderNos := make([]string, 0, len(trades))
+	for _, line := range trades {
+		if line.OutTradeNo != "" {
+			orderNos = append(orderNos, line.OutTradeNo)
+		}
+	}
+	byOrderNo := make(map[string]billOrder, len(trades))
+	if err := s.queryBillOrders(ctx, "o.order_no", orderNos, func(order billOrder) {
+		byOrderNo[order.OrderNo] = order
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	// 商户订单号没有匹配到的交易再按支付宝交易号匹配（ticket_no 没有索引，只查询少量未匹配的交易）
+	var ticketNos []string
+	for _, line := range trades {
+		if _, ok := byOrderNo[line.OutTradeNo]; !ok && line.TradeNo != "" {
+			ticketNos = append(ticketNos, line.TradeNo)
+		}
+	}
+	byTicketNo := make(map[string]billOrder, len(ticketNos))
+	if err := s.queryBillOrders(ctx, "d.ticket_no", ticketNos, func(order billOrder) {
+		byTicketNo[order.TicketNo] = order
+	}); err != nil {
+		return nil, nil, err
+	}
+	return byOrderNo, byTicketNo, nil
+}
+
+// queryBillOrders 按指定列分批查询订单
+func (s *AlipayBillReconcileService) queryBillOrders(ctx context.Context, column string, values []string, fn func(order billOrder)) error {
+	for start := 0; start < len(values); start += alipayBillQueryBatch {
+		end := start + alipayBillQueryBatch
+		if end > len(values) {
+			end = len(values)
+		}
+		var orders []billOrder
+		if err := database.DB.WithContext(ctx).Table("dvadmin_order AS o").
+			Select("o.id, o.order_no, o.money, o.order_status, d.ticket_no, d.product_id").
+			Joins("JOIN dvadmin_order_detail AS d ON d.order_id = o.id").
+			Where(column+" IN ?", values[start:end]).
+			Scan(&orders).Error; err != nil {
+			return fmt.Errorf("查询账单对应订单失败: %w", err)
+		}
+		for _, order := range orders {
+			fn(order)
+		}
+	}
+	return nil
+}
+
+// isBillPaidStatus 订单是否已支付
+func isBillPaidStatus(status int) bool {
+	for _, s := range alipayBillPaidStatuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
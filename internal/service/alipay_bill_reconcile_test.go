@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-pay-core/internal/alipay"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestAlipayBillReconcile(t *testing.T) {
+	db := setupTestDB(t)
+	assert.NoError(t, db.AutoMigrate(&models.AlipayBillDiff{}))
+
+	oldDB, oldLogger := database.DB, logger.Logger
+	database.DB, logger.Logger = db, zap.NewNop()
+	t.Cleanup(func() {
+		database.DB, logger.Logger = oldDB, oldLogger
+	})
+
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	paidAt := day.Add(10 * time.Hour)
+	createOrder := func(id, orderNo, ticketNo string, money, status int) {
+		order := &models.Order{ID: id, OrderNo: orderNo, OutOrderNo: "M" + id, Money: money, OrderStatus: status}
+		if status == models.OrderStatusPaid {
+			order.PayDatetime = &paidAt
+		}
+		db.Create(order)
+		db.Create(&models.OrderDetail{OrderID: id, ProductID: "7", TicketNo: ticketNo})
+	}
+	createOrder("1", "P1", "T1", 1000, models.OrderStatusPaid)     // 一致
+	createOrder("2", "P2", "T2", 2000, models.OrderStatusPaid)     // 金额不一致
+	createOrder("3", "P3", "T3", 3000, models.OrderStatusPaid)     // 账单中没有
+	createOrder("4", "P4", "", 4000, models.OrderStatusClosed)     // 本地未支付
+	createOrder("5", "P5-old", "T5", 5000, models.OrderStatusPaid) // 商户订单号不一致，按交易号匹配
+
+	result, err := NewAlipayBillReconcileService().Reconcile(context.Background(), 7, day, []alipay.TradeBillLine{
+		{TradeNo: "T1", OutTradeNo: "P1", BizType: alipay.BillBizTypeTrade, TotalAmount: 1000},
+		{TradeNo: "T2", OutTradeNo: "P2", BizType: alipay.BillBizTypeTrade, TotalAmount: 1999},
+		{TradeNo: "T4", OutTradeNo: "P4", BizType: alipay.BillBizTypeTrade, TotalAmount: 4000},
+		{TradeNo: "T5", OutTradeNo: "P5", BizType: alipay.BillBizTypeTrade, TotalAmount: 5000},
+		{TradeNo: "T9", OutTradeNo: "P9", BizType: alipay.BillBizTypeTrade, TotalAmount: 900},
+		{TradeNo: "T1", OutTradeNo: "P1", BizType: alipay.BillBizTypeRefund, TotalAmount: -100},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, result.Trades)
+	assert.Equal(t, 1, result.Refunds)
+	assert.Equal(t, 2, result.Matched)
+
+	got := make(map[string]string)
+	for _, diff := range result.Diffs {
+		got[diff.OrderNo] = diff.DiffType
+	}
+	assert.Equal(t, map[string]string{
+		"P2": models.AlipayBillDiffAmountMismatch,
+		"P3": models.AlipayBillDiffMissing,
+		"P4": models.AlipayBillDiffExtra,
+		"P9": models.AlipayBillDiffExtra,
+	}, got)
+
+	var saved int64
+	db.Model(&models.AlipayBillDiff{}).Where("run_id = ? AND bill_date = ?", result.RunID, "2024-01-01").Count(&saved)
+	assert.Equal(t, int64(4), saved)
+}
@@ -0,0 +1,548 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-pay-core/config"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var (
+	// 最近一次核对确认的偏差数
+	balanceReconcileDrifts = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "balance_reconcile_drifts",
+			Help: "最近一次余额核对确认存在的偏差数",
+		},
+		[]string{"check"},
+	)
+
+	// 偏差处理结果
+	balanceReconcileActionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "balance_reconcile_actions_total",
+			Help: "余额偏差处理次数（report 只报告、repaired 已修复、skipped 修复时值已变化、alert 需要人工处理）",
+		},
+		[]string{"check", "action"},
+	)
+)
+
+// balanceReconcileChecks 所有核对项（用于重置指标）
+var balanceReconcileChecks = []string{
+	models.BalanceCheckTenantPreTax,
+	models.BalanceCheckTenantBalance,
+	models.BalanceCheckWriteoffBalance,
+	models.BalanceCheckTenantCashflow,
+	models.BalanceCheckWriteoffCashflow,
+}
+
+// adjustPreTaxScript 按偏差调整预占手续费（不低于 0），不覆盖核对期间并发的预占和释放
+var adjustPreTaxScript = redis.NewScript(`
+local preTax = tonumber(redis.call('GET', KEYS[1]) or '0') + tonumber(ARGV[1])
+if preTax < 0 then
+	preTax = 0
+end
+redis.call('SET', KEYS[1], preTax)
+return preTax
+`)
+
+// compareAndSetScript 值与检测时一致才覆盖（缓存刷新可能已经同步了新值）
+var compareAndSetScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// balanceDrift 一次检测到的偏差
+type balanceDrift struct {
+	checkType string
+	ownerID   int64
+	expected  int64
+	actual    int64
+	redisKey  string // Redis 核对项的键
+	actualRaw string // 检测到的 Redis 原始值
+	repairRaw string // 修复时写入的值（余额缓存核对项使用）
+	remarks   string
+}
+
+// id 偏差标识（核对项 + 租户/核销ID）
+func (d balanceDrift) id() string {
+	return fmt.Sprintf("%s:%d", d.checkType, d.ownerID)
+}
+
+// BalanceReconcileResult 余额核对结果
+type BalanceReconcileResult struct {
+	RunID   string
+	Reports []models.BalanceDriftReport // 确认存在的偏差
+}
+
+// Alerts 需要人工处理的偏差数
+func (r *BalanceReconcileResult) Alerts() int {
+	count := 0
+	for _, report := range r.Reports {
+		if report.Action == models.BalanceDriftActionAlert {
+			count++
+		}
+	}
+	return count
+}
+
+// BalanceReconcileService 余额核对服务
+// 核对 Redis 预占手续费与未完成订单、Redis 余额缓存与数据库余额、数据库余额与最近一条资金流水，
+// 相隔 confirmDelay 检测两次，两次都存在的偏差（取较小值）才写入偏差报告，排除处理中的订单造成的瞬时差异；
+// 开启自动修复时修复阈值内的 Redis 偏差，超出阈值或资金流水不一致时告警
+type BalanceReconcileService struct {
+	interval        time.Duration
+	confirmDelay    time.Duration
+	autoRepair      bool
+	repairThreshold int64
+}
+
+// NewBalanceReconcileService 创建余额核对服务（未配置的项使用默认值）
+func NewBalanceReconcileService() *BalanceReconcileService {
+	cfg := config.BalanceReconcileConfig{}
+	if config.Cfg != nil {
+		cfg = config.Cfg.BalanceReconcile
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Minute
+	}
+	if cfg.ConfirmDelay <= 0 {
+		cfg.ConfirmDelay = 5 * time.Second
+	}
+	if cfg.RepairThreshold <= 0 {
+		cfg.RepairThreshold = 10000
+	}
+
+	return &BalanceReconcileService{
+		interval:        cfg.Interval,
+		confirmDelay:    cfg.ConfirmDelay,
+		autoRepair:      cfg.AutoRepair,
+		repairThreshold: cfg.RepairThreshold,
+	}
+}
+
+// Start 启动定时核对，阻塞直到 ctx 取消
+// 作为单实例服务只在主节点上运行（lifecycle.Supervisor）
+func (s *BalanceReconcileService) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	logger.Logger.Info("余额核对服务已启动",
+		zap.Duration("interval", s.interval),
+		zap.Bool("auto_repair", s.autoRepair),
+		zap.Int64("repair_threshold", s.repairThreshold))
+
+	for {
+		if _, err := s.Run(ctx, s.autoRepair); err != nil && ctx.Err() == nil {
+			logger.Logger.Error("余额核对失败", zap.Error(err))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			logger.Logger.Info("余额核对服务已停止")
+			return
+		}
+	}
+}
+
+// Run 执行一次核对，写入偏差报告；autoRepair 为 true 时修复阈值内的 Redis 偏差
+func (s *BalanceReconcileService) Run(ctx context.Context, autoRepair bool) (*BalanceReconcileResult, error) {
+	result := &BalanceReconcileResult{RunID: utils.GenerateID()}
+
+	first, err := s.detect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var confirmed []balanceDrift
+	var drifts []int64
+	if len(first) > 0 {
+		select {
+		case <-time.After(s.confirmDelay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		second, err := s.detect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for id, d2 := range second {
+			d1, ok := first[id]
+			if !ok {
+				continue
+			}
+			drift, ok := confirmDrift(d1.actual-d1.expected, d2.actual-d2.expected)
+			if !ok {
+				continue
+			}
+			confirmed = append(confirmed, d2)
+			drifts = append(drifts, drift)
+		}
+	}
+
+	counts := make(map[string]int, len(balanceReconcileChecks))
+	now := time.Now()
+	for i, d := range confirmed {
+		action, remarks := s.resolve(ctx, d, drifts[i], autoRepair)
+		counts[d.checkType]++
+		balanceReconcileActionsTotal.WithLabelValues(d.checkType, action).Inc()
+
+		fields := []zap.Field{
+			zap.String("run_id", result.RunID),
+			zap.String("check", d.checkType),
+			zap.Int64("owner_id", d.ownerID),
+			zap.Int64("expected", d.expected),
+			zap.Int64("actual", d.actual),
+			zap.Int64("drift", drifts[i]),
+			zap.String("action", action),
+			zap.String("remarks", remarks),
+		}
+		if action == models.BalanceDriftActionAlert {
+			logger.Logger.Error("余额偏差需要人工处理", fields...)
+		} else {
+			logger.Logger.Warn("检测到余额偏差", fields...)
+		}
+
+		result.Reports = append(result.Reports, models.BalanceDriftReport{
+			RunID:          result.RunID,
+			CheckType:      d.checkType,
+			OwnerID:        d.ownerID,
+			Expected:       d.expected,
+			Actual:         d.actual,
+			Drift:          drifts[i],
+			Action:         action,
+			Remarks:        remarks,
+			CreateDatetime: &now,
+		})
+	}
+	for _, check := range balanceReconcileChecks {
+		balanceReconcileDrifts.WithLabelValues(check).Set(float64(counts[check]))
+	}
+
+	sort.Slice(result.Reports, func(i, j int) bool {
+		a, b := result.Reports[i], result.Reports[j]
+		if a.CheckType != b.CheckType {
+			return a.CheckType < b.CheckType
+		}
+		return a.OwnerID < b.OwnerID
+	})
+	if len(result.Reports) > 0 {
+		if err := database.DB.WithContext(ctx).Create(&result.Reports).Error; err != nil {
+			return result, fmt.Errorf("写入余额偏差报告失败: %w", err)
+		}
+	}
+
+	logger.Logger.Info("余额核对完成",
+		zap.String("run_id", result.RunID),
+		zap.Int("drifts", len(result.Reports)),
+		zap.Int("alerts", result.Alerts()))
+	return result, nil
+}
+
+// confirmDrift 两次检测的偏差方向一致时确认偏差，取绝对值较小的一次
+// 处理中的订单只会让某一次检测的偏差偏大，较小值是确定存在的部分
+func confirmDrift(first, second int64) (int64, bool) {
+	if first == 0 || second == 0 || (first > 0) != (second > 0) {
+		return 0, false
+	}
+	if abs64(first) < abs64(second) {
+		return first, true
+	}
+	return second, true
+}
+
+// resolve 处理确认的偏差，返回处理结果和备注
+func (s *BalanceReconcileService) resolve(ctx context.Context, d balanceDrift, drift int64, autoRepair bool) (string, string) {
+	remarks := d.remarks
+	switch {
+	case d.checkType == models.BalanceCheckTenantCashflow || d.checkType == models.BalanceCheckWriteoffCashflow:
+		return models.BalanceDriftActionAlert, joinRemarks(remarks, "余额与资金流水不一致，需要人工核对")
+	case abs64(drift) > s.repairThreshold:
+		return models.BalanceDriftActionAlert, joinRemarks(remarks, fmt.Sprintf("超出自动修复阈值 %d", s.repairThreshold))
+	case !autoRepair:
+		return models.BalanceDriftActionReport, remarks
+	}
+
+	if d.checkType == models.BalanceCheckTenantPreTax {
+		preTax, err := adjustPreTaxScript.Run(ctx, database.RDB, []string{d.redisKey}, -drift).Int64()
+		if err != nil {
+			return models.BalanceDriftActionSkipped, joinRemarks(remarks, fmt.Sprintf("修复失败: %v", err))
+		}
+		return models.BalanceDriftActionRepaired, joinRemarks(remarks, fmt.Sprintf("预占手续费调整 %d，调整后 %d", -drift, preTax))
+	}
+
+	swapped, err := compareAndSetScript.Run(ctx, database.RDB, []string{d.redisKey}, d.actualRaw, d.repairRaw).Int()
+	if err != nil {
+		return models.BalanceDriftActionSkipped, joinRemarks(remarks, fmt.Sprintf("修复失败: %v", err))
+	}
+	if swapped == 0 {
+		return models.BalanceDriftActionSkipped, joinRemarks(remarks, "修复时缓存已变化")
+	}
+	return models.BalanceDriftActionRepaired, joinRemarks(remarks, "已按数据库余额覆盖缓存")
+}
+
+// detect 检测所有核对项，返回存在偏差的项
+func (s *BalanceReconcileService) detect(ctx context.Context) (map[string]balanceDrift, error) {
+	drifts := make(map[string]balanceDrift)
+	add := func(d balanceDrift) { drifts[d.id()] = d }
+
+	if err := s.detectCashflow(ctx, add); err != nil {
+		return nil, err
+	}
+	if database.RDB == nil {
+		return drifts, nil
+	}
+	if err := s.detectTenantCache(ctx, add); err != nil {
+		return nil, err
+	}
+	if err := s.detectWriteoffCache(ctx, add); err != nil {
+		return nil, err
+	}
+	return drifts, nil
+}
+
+// detectTenantCache 核对租户预占手续费（未完成订单的手续费之和）和余额缓存
+func (s *BalanceReconcileService) detectTenantCache(ctx context.Context, add func(balanceDrift)) error {
+	db := database.DB.WithContext(ctx)
+
+	var tenants []struct {
+		ID      int64 `gorm:"column:id"`
+		Balance int64 `gorm:"column:balance"`
+	}
+	if err := db.Table("dvadmin_tenant").Select("id, balance").Order("id").Find(&tenants).Error; err != nil {
+		return fmt.Errorf("查询租户余额失败: %w", err)
+	}
+
+	var openTaxes []struct {
+		TenantID int64 `gorm:"column:tenant_id"`
+		Tax      int64 `gorm:"column:tax"`
+	}
+	if err := db.Table("dvadmin_order AS o").
+		Select("m.parent_id AS tenant_id, COALESCE(SUM(o.tax), 0) AS tax").
+		Joins("JOIN dvadmin_merchant AS m ON m.id = o.merchant_id").
+		Where("o.order_status IN ?", []int{models.OrderStatusGenerating, models.OrderStatusPaying}).
+		Group("m.parent_id").
+		Scan(&openTaxes).Error; err != nil {
+		return fmt.Errorf("汇总未完成订单手续费失败: %w", err)
+	}
+	expectedPreTax := make(map[int64]int64, len(openTaxes))
+	for _, row := range openTaxes {
+		expectedPreTax[row.TenantID] = row.Tax
+	}
+
+	keys := make([]string, 0, len(tenants)*2)
+	for _, tenant := range tenants {
+		keys = append(keys,
+			fmt.Sprintf("tenant:pre_tax:%d", tenant.ID),
+			fmt.Sprintf("tenant:balance:%d", tenant.ID))
+	}
+	values, err := mgetStrings(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	for i, tenant := range tenants {
+		preTaxKey, balanceKey := keys[2*i], keys[2*i+1]
+
+		// 预占手续费不存在时为 0
+		preTaxRaw, preTax := values[preTaxKey], int64(0)
+		if preTaxRaw != nil {
+			preTax, _ = strconv.ParseInt(*preTaxRaw, 10, 64)
+		}
+		if expected := expectedPreTax[tenant.ID]; preTax != expected {
+			add(balanceDrift{
+				checkType: models.BalanceCheckTenantPreTax,
+				ownerID:   tenant.ID,
+				expected:  expected,
+				actual:    preTax,
+				redisKey:  preTaxKey,
+			})
+		}
+
+		// 余额缓存不存在时由下单请求从数据库加载，不算偏差
+		balanceRaw := values[balanceKey]
+		if balanceRaw == nil {
+			continue
+		}
+		balance, err := strconv.ParseInt(*balanceRaw, 10, 64)
+		if err != nil || balance != tenant.Balance {
+			add(balanceDrift{
+				checkType: models.BalanceCheckTenantBalance,
+				ownerID:   tenant.ID,
+				expected:  tenant.Balance,
+				actual:    balance,
+				redisKey:  balanceKey,
+				actualRaw: *balanceRaw,
+				repairRaw: strconv.FormatInt(tenant.Balance, 10),
+			})
+		}
+	}
+	return nil
+}
+
+// detectWriteoffCache 核对核销余额缓存（"NULL" 表示不限制余额）
+func (s *BalanceReconcileService) detectWriteoffCache(ctx context.Context, add func(balanceDrift)) error {
+	var writeoffs []struct {
+		ID      int64  `gorm:"column:id"`
+		Balance *int64 `gorm:"column:balance"`
+	}
+	if err := database.DB.WithContext(ctx).Table("dvadmin_writeoff").Select("id, balance").Order("id").Find(&writeoffs).Error; err != nil {
+		return fmt.Errorf("查询核销余额失败: %w", err)
+	}
+
+	keys := make([]string, 0, len(writeoffs))
+	for _, writeoff := range writeoffs {
+		keys = append(keys, fmt.Sprintf("writeoff:balance:%d", writeoff.ID))
+	}
+	values, err := mgetStrings(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	for i, writeoff := range writeoffs {
+		raw := values[keys[i]]
+		if raw == nil {
+			continue
+		}
+
+		expectedRaw := "NULL"
+		var expected int64
+		if writeoff.Balance != nil {
+			expected = *writeoff.Balance
+			expectedRaw = strconv.FormatInt(expected, 10)
+		}
+		if *raw == expectedRaw {
+			continue
+		}
+
+		var actual int64
+		var remarks string
+		switch {
+		case *raw == "NULL":
+			remarks = "缓存为不限制余额"
+		case writeoff.Balance == nil:
+			remarks = "数据库为不限制余额"
+			actual, _ = strconv.ParseInt(*raw, 10, 64)
+		default:
+			actual, _ = strconv.ParseInt(*raw, 10, 64)
+		}
+		if actual == expected {
+			// 不限制与 0 余额之间的差异没有金额偏差，按 1 分记录，保证两次检测都能确认
+			actual = expected + 1
+		}
+		add(balanceDrift{
+			checkType: models.BalanceCheckWriteoffBalance,
+			ownerID:   writeoff.ID,
+			expected:  expected,
+			actual:    actual,
+			redisKey:  keys[i],
+			actualRaw: *raw,
+			repairRaw: expectedRaw,
+			remarks:   remarks,
+		})
+	}
+	return nil
+}
+
+// detectCashflow 核对数据库余额与最近一条资金流水的变更后余额（余额变动未记流水时不一致）
+// 余额和流水在同一条语句中读取，避免并发的订单记账造成瞬时差异
+func (s *BalanceReconcileService) detectCashflow(ctx context.Context, add func(balanceDrift)) error {
+	db := database.DB.WithContext(ctx)
+
+	var tenants []struct {
+		ID       int64 `gorm:"column:id"`
+		Balance  int64 `gorm:"column:balance"`
+		NewMoney int64 `gorm:"column:new_money"`
+	}
+	if err := db.Table("dvadmin_tenant AS t").
+		Select("t.id, t.balance, c.new_money").
+		Joins("JOIN dvadmin_tenant_cashflow AS c ON c.id = (SELECT MAX(id) FROM dvadmin_tenant_cashflow WHERE tenant_id = t.id)").
+		Where("t.balance <> c.new_money").
+		Scan(&tenants).Error; err != nil {
+		return fmt.Errorf("核对租户资金流水失败: %w", err)
+	}
+	for _, tenant := range tenants {
+		add(balanceDrift{
+			checkType: models.BalanceCheckTenantCashflow,
+			ownerID:   tenant.ID,
+			expected:  tenant.NewMoney,
+			actual:    tenant.Balance,
+		})
+	}
+
+	var writeoffs []struct {
+		ID       int64 `gorm:"column:id"`
+		Balance  int64 `gorm:"column:balance"`
+		NewMoney int64 `gorm:"column:new_money"`
+	}
+	if err := db.Table("dvadmin_writeoff AS w").
+		Select("w.id, w.balance, c.new_money").
+		Joins("JOIN dvadmin_writeoff_cashflow AS c ON c.id = (SELECT MAX(id) FROM dvadmin_writeoff_cashflow WHERE writeoff_id = w.id)").
+		Where("w.balance IS NOT NULL AND w.balance <> c.new_money").
+		Scan(&writeoffs).Error; err != nil {
+		return fmt.Errorf("核对核销资金流水失败: %w", err)
+	}
+	for _, writeoff := range writeoffs {
+		add(balanceDrift{
+			checkType: models.BalanceCheckWriteoffCashflow,
+			ownerID:   writeoff.ID,
+			expected:  writeoff.NewMoney,
+			actual:    writeoff.Balance,
+		})
+	}
+	return nil
+}
+
+// mgetStrings 批量读取 Redis 键（键不存在时值为 nil）
+func mgetStrings(ctx context.Context, keys []string) (map[string]*string, error) {
+	const batchSize = 500
+	values := make(map[string]*string, len(keys))
+	for start := 0; start < len(keys); start += batchSize {
+		end := start + batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		batch, err := database.RDB.MGet(ctx, keys[start:end]...).Result()
+		if err != nil {
+			return nil, fmt.Errorf("读取 Redis 余额失败: %w", err)
+		}
+		for i, value := range batch {
+			if str, ok := value.(string); ok {
+				values[keys[start+i]] = &str
+			}
+		}
+	}
+	return values, nil
+}
+
+// joinRemarks 合并备注
+func joinRemarks(remarks, more string) string {
+	if remarks == "" {
+		return more
+	}
+	return remarks + "；" + more
+}
+
+// abs64 绝对值
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
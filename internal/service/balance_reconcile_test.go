@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestConfirmDrift(t *testing.T) {
+	cases := []struct {
+		first, second int64
+		want          int64
+		ok            bool
+	}{
+		{first: 30, second: 50, want: 30, ok: true},
+		{first: -50, second: -30, want: -30, ok: true},
+		{first: 30, second: 0, ok: false},
+		{first: 30, second: -30, ok: false},
+	}
+	for _, c := range cases {
+		got, ok := confirmDrift(c.first, c.second)
+		assert.Equal(t, c.ok, ok, "confirmDrift(%d, %d)", c.first, c.second)
+		assert.Equal(t, c.want, got, "confirmDrift(%d, %d)", c.first, c.second)
+	}
+}
+
+func TestBalanceReconcileCashflow(t *testing.T) {
+	db := setupTestDB(t)
+	assert.NoError(t, db.AutoMigrate(&models.Writeoff{}, &models.TenantCashflow{}, &models.WriteoffCashflow{}, &models.BalanceDriftReport{}))
+
+	oldDB, oldRDB, oldLogger := database.DB, database.RDB, logger.Logger
+	database.DB, database.RDB, logger.Logger = db, nil, zap.NewNop()
+	t.Cleanup(func() {
+		database.DB, database.RDB, logger.Logger = oldDB, oldRDB, oldLogger
+	})
+
+	// 租户 1 与最近一条流水一致，租户 2 余额被直接修改；核销 10 不限制余额不核对
+	db.Create(&models.Tenant{ID: 1, Balance: 970})
+	db.Create(&models.Tenant{ID: 2, Balance: 500})
+	db.Create(&models.TenantCashflow{TenantID: 1, OldMoney: 1000, NewMoney: 970, ChangeMoney: -30})
+	db.Create(&models.TenantCashflow{TenantID: 2, OldMoney: 1000, NewMoney: 900, ChangeMoney: -100})
+	db.Create(&models.TenantCashflow{TenantID: 2, OldMoney: 900, NewMoney: 800, ChangeMoney: -100})
+	db.Create(&models.Writeoff{ID: 10, Ver: 1})
+	db.Create(&models.WriteoffCashflow{WriteoffID: 10, OldMoney: 0, NewMoney: 100, ChangeMoney: 100})
+
+	s := &BalanceReconcileService{confirmDelay: time.Millisecond, repairThreshold: 10000}
+	result, err := s.Run(context.Background(), true)
+	assert.NoError(t, err)
+	if assert.Len(t, result.Reports, 1) {
+		report := result.Reports[0]
+		assert.Equal(t, models.BalanceCheckTenantCashflow, report.CheckType)
+		assert.Equal(t, int64(2), report.OwnerID)
+		assert.Equal(t, int64(-300), report.Drift)
+		assert.Equal(t, models.BalanceDriftActionAlert, report.Action)
+	}
+	assert.Equal(t, 1, result.Alerts())
+
+	var saved int64
+	db.Model(&models.BalanceDriftReport{}).Where("run_id = ?", result.RunID).Count(&saved)
+	assert.Equal(t, int64(1), saved)
+}
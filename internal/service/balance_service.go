@@ -331,7 +331,7 @@ func (s *BalanceService) DeductWriteoffBalance(ctx context.Context, writeoffID i
 		if msg, ok := results[1].(string); ok {
 			errorMsg = msg
 		}
-		return fmt.Errorf(errorMsg)
+		return fmt.Errorf("%s", errorMsg)
 	}
 
 	return nil
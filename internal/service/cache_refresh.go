@@ -44,6 +44,7 @@ const (
 	CacheTargetMerchantPayChannels = "merchant_pay_channels"
 	CacheTargetPayChannelTaxes     = "pay_channel_taxes"
 	CacheTargetPayDomains          = "pay_domains"
+	CacheTargetMerchantSignKeys    = "merchant_sign_keys"
 )
 
 // CacheRefreshRequest 供 MQ 触发的刷新请求
@@ -144,6 +145,8 @@ func (s *CacheRefreshService) Refresh(ctx context.Context, req CacheRefreshReque
 			s.refreshPayChannelTaxesIncremental(ctx, since)
 		case CacheTargetPayDomains:
 			s.refreshPayDomainsIncremental(ctx, since)
+		case CacheTargetMerchantSignKeys:
+			s.refreshMerchantSignKeysIncremental(ctx, since)
 		default:
 			// 未知目标直接跳过
 			continue
@@ -202,6 +205,9 @@ func (s *CacheRefreshService) refreshAllIncremental(ctx context.Context, fullRef
 	// 刷新域名缓存
 	s.refreshPayDomainsIncremental(ctx, refreshSince)
 
+	// 刷新商户签名密钥缓存
+	s.refreshMerchantSignKeysIncremental(ctx, refreshSince)
+
 	// 更新最后刷新时间
 	s.lastRefreshTime = now.Add(-500 * time.Millisecond) // 留500ms缓冲，避免遗漏
 }
@@ -1118,6 +1124,63 @@ func (s *CacheRefreshService) refreshMerchantPayChannelsIncremental(ctx context.
 	}
 }
 
+// refreshMerchantSignKeysIncremental 增量刷新商户签名密钥缓存
+// 缓存按商户保存全部密钥，增量刷新时重新加载有密钥更新的商户的全部密钥
+func (s *CacheRefreshService) refreshMerchantSignKeysIncremental(ctx context.Context, since time.Time) {
+	tableKey := "table:dvadmin_merchant_sign_key"
+
+	var signKeys []models.MerchantSignKey
+	if since.IsZero() {
+		// 全量刷新时，直接查询所有密钥
+		if err := s.dbNoLog.Model(&models.MerchantSignKey{}).Order("id").Find(&signKeys).Error; err != nil {
+			return
+		}
+	} else {
+		var maxUpdateTime time.Time
+		s.dbNoLog.Model(&models.MerchantSignKey{}).
+			Select("MAX(update_datetime) as max_time").
+			Scan(&maxUpdateTime)
+
+		tableLastUpdate, _ := s.getTableUpdateTime(ctx, tableKey)
+		if !maxUpdateTime.IsZero() && !tableLastUpdate.IsZero() && !maxUpdateTime.After(tableLastUpdate) {
+			return
+		}
+
+		var merchantIDs []int64
+		if err := s.dbNoLog.Model(&models.MerchantSignKey{}).
+			Where("update_datetime > ? OR update_datetime IS NULL", since).
+			Distinct("merchant_id").Pluck("merchant_id", &merchantIDs).Error; err != nil {
+			return
+		}
+		if len(merchantIDs) > 0 {
+			if err := s.dbNoLog.Model(&models.MerchantSignKey{}).
+				Where("merchant_id IN ?", merchantIDs).Order("id").Find(&signKeys).Error; err != nil {
+				return
+			}
+		}
+	}
+
+	merchantKeys := make(map[int64][]models.MerchantSignKey)
+	var maxUpdateTime time.Time
+	for _, key := range signKeys {
+		merchantKeys[key.MerchantID] = append(merchantKeys[key.MerchantID], key)
+		if key.UpdateDatetime != nil && key.UpdateDatetime.After(maxUpdateTime) {
+			maxUpdateTime = *key.UpdateDatetime
+		}
+	}
+	for merchantID, keys := range merchantKeys {
+		if data, err := json.Marshal(keys); err == nil {
+			_ = s.redis.Set(ctx, merchantSignKeysCacheKey(merchantID), data, 0).Err()
+		}
+	}
+
+	if !maxUpdateTime.IsZero() {
+		s.setTableUpdateTime(ctx, tableKey, maxUpdateTime)
+	} else if since.IsZero() {
+		s.setTableUpdateTime(ctx, tableKey, time.Now())
+	}
+}
+
 // refreshPayChannelTaxesIncremental 增量刷新租户通道费率缓存
 func (s *CacheRefreshService) refreshPayChannelTaxesIncremental(ctx context.Context, since time.Time) {
 	tableKey := "table:dvadmin_pay_channel_tax"
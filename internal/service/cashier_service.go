@@ -16,12 +16,14 @@ import (
 // CashierService 收银台服务
 type CashierService struct {
 	orderService *OrderService
+	riskService  *RiskService
 }
 
 // NewCashierService 创建收银台服务
 func NewCashierService() *CashierService {
 	return &CashierService{
 		orderService: NewOrderService(),
+		riskService:  NewRiskService(),
 	}
 }
 
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+// channelAccessDeniedTotal 支付通道访问策略拒绝次数
+var channelAccessDeniedTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "channel_access_denied_total",
+		Help: "支付通道访问策略拒绝次数（stage: order 下单、cashier 收银台；rule: ip、region、device）",
+	},
+	[]string{"stage", "rule"},
+)
+
+// 访问策略拒绝的规则
+const (
+	channelAccessRuleIP     = "ip"
+	channelAccessRuleRegion = "region"
+	channelAccessRuleDevice = "device"
+)
+
+// channelAccessPolicy 解析后的支付通道访问策略
+type channelAccessPolicy struct {
+	ips    map[string]bool
+	nets   []*net.IPNet
+	policy models.PayChannelAccessPolicy
+}
+
+// empty 是否没有任何限制
+func (p *channelAccessPolicy) empty() bool {
+	return len(p.ips) == 0 && len(p.nets) == 0 &&
+		len(p.policy.AllowProvinces) == 0 && len(p.policy.DenyProvinces) == 0 &&
+		len(p.policy.AllowCities) == 0 && len(p.policy.DenyCities) == 0 &&
+		len(p.policy.DeviceTypes) == 0
+}
+
+// channelAccessPolicies 按原始配置缓存解析结果（通道缓存每秒刷新，避免每次请求重复解析）
+var channelAccessPolicies sync.Map
+
+// ChannelAccessDenial 访问策略拒绝结果
+type ChannelAccessDenial struct {
+	Rule   string // ip、region、device
+	Reason string // 拒绝原因（写入订单备注和日志）
+}
+
+// parseChannelAccessPolicy 解析通道的封禁IP列表和访问策略（配置格式错误的部分忽略并记录日志）
+func parseChannelAccessPolicy(channel *models.PayChannel) *channelAccessPolicy {
+	cacheKey := channel.BanIP + "\x00" + channel.AccessPolicy
+	if cached, ok := channelAccessPolicies.Load(cacheKey); ok {
+		return cached.(*channelAccessPolicy)
+	}
+
+	p := &channelAccessPolicy{ips: make(map[string]bool)}
+	if channel.BanIP != "" {
+		var entries []string
+		if err := json.Unmarshal([]byte(channel.BanIP), &entries); err != nil {
+			logger.Logger.Warn("支付通道封禁IP列表格式错误",
+				zap.Int64("channel_id", channel.ID),
+				zap.Error(err))
+		}
+		for _, entry := range entries {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			if strings.Contains(entry, "/") {
+				if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+					p.nets = append(p.nets, ipNet)
+					continue
+				}
+			} else if ip := net.ParseIP(entry); ip != nil {
+				p.ips[ip.String()] = true
+				continue
+			}
+			logger.Logger.Warn("支付通道封禁IP格式错误",
+				zap.Int64("channel_id", channel.ID),
+				zap.String("entry", entry))
+		}
+	}
+	if channel.AccessPolicy != "" && channel.AccessPolicy != "null" {
+		if err := json.Unmarshal([]byte(channel.AccessPolicy), &p.policy); err != nil {
+			logger.Logger.Warn("支付通道访问策略格式错误",
+				zap.Int64("channel_id", channel.ID),
+				zap.Error(err))
+		}
+	}
+
+	channelAccessPolicies.Store(cacheKey, p)
+	return p
+}
+
+// evaluateChannelAccess 按访问策略检查客户端，允许时返回 nil
+// 归属地未知（本地 IP、归属地库不可用）时不检查地区规则，设备类型未知时不检查设备规则
+func evaluateChannelAccess(p *channelAccessPolicy, clientIP string, location *utils.IPLocationInfo, deviceType int) *ChannelAccessDenial {
+	if ip := net.ParseIP(clientIP); ip != nil {
+		if p.ips[ip.String()] {
+			return &ChannelAccessDenial{Rule: channelAccessRuleIP, Reason: fmt.Sprintf("IP %s 已被封禁", clientIP)}
+		}
+		for _, ipNet := range p.nets {
+			if ipNet.Contains(ip) {
+				return &ChannelAccessDenial{Rule: channelAccessRuleIP, Reason: fmt.Sprintf("IP %s 属于封禁网段 %s", clientIP, ipNet)}
+			}
+		}
+	}
+
+	if location != nil && location.Province != "" {
+		policy := p.policy
+		if utils.MatchRegion(location.Province, policy.DenyProvinces) ||
+			(len(policy.AllowProvinces) > 0 && !utils.MatchRegion(location.Province, policy.AllowProvinces)) {
+			return &ChannelAccessDenial{Rule: channelAccessRuleRegion, Reason: fmt.Sprintf("IP %s 归属地 %s 不允许使用该通道", clientIP, location.Address)}
+		}
+		if utils.MatchRegion(location.City, policy.DenyCities) ||
+			(len(policy.AllowCities) > 0 && !utils.MatchRegion(location.City, policy.AllowCities)) {
+			return &ChannelAccessDenial{Rule: channelAccessRuleRegion, Reason: fmt.Sprintf("IP %s 归属地 %s 不允许使用该通道", clientIP, location.Address)}
+		}
+	}
+
+	if deviceType != models.DeviceTypeUnknown && len(p.policy.DeviceTypes) > 0 {
+		allowed := false
+		for _, t := range p.policy.DeviceTypes {
+			if t == deviceType {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &ChannelAccessDenial{Rule: channelAccessRuleDevice, Reason: fmt.Sprintf("设备类型 %d 不允许使用该通道", deviceType)}
+		}
+	}
+	return nil
+}
+
+// CheckChannelAccess 检查客户端是否允许使用支付通道，允许时返回 nil
+// 下单接口没有 User-Agent，deviceType 传 DeviceTypeUnknown，只检查IP和地区规则
+func CheckChannelAccess(channel *models.PayChannel, clientIP string, deviceType int) *ChannelAccessDenial {
+	if channel == nil || clientIP == "" {
+		return nil
+	}
+	p := parseChannelAccessPolicy(channel)
+	if p.empty() {
+		return nil
+	}
+
+	var location *utils.IPLocationInfo
+	if hasRegionRules(p.policy) {
+		location, _ = utils.GetIPLocation(clientIP)
+	}
+	return evaluateChannelAccess(p, clientIP, location, deviceType)
+}
+
+// hasRegionRules 是否配置了地区规则（没有时不查询归属地）
+func hasRegionRules(policy models.PayChannelAccessPolicy) bool {
+	return len(policy.AllowProvinces) > 0 || len(policy.DenyProvinces) > 0 ||
+		len(policy.AllowCities) > 0 || len(policy.DenyCities) > 0
+}
+
+// validateChannelAccess 下单时按客户端 IP 检查支付通道访问策略（没有传入客户端 IP 时跳过）
+func (s *OrderService) validateChannelAccess(orderCtx *OrderCreateContext, clientIP string) *OrderError {
+	if orderCtx.Test || clientIP == "" {
+		return nil
+	}
+
+	denial := CheckChannelAccess(orderCtx.Channel, clientIP, models.DeviceTypeUnknown)
+	if denial == nil {
+		return nil
+	}
+	channelAccessDeniedTotal.WithLabelValues("order", denial.Rule).Inc()
+	logger.Logger.Warn("下单被支付通道访问策略拒绝",
+		zap.String("out_order_no", orderCtx.OutOrderNo),
+		zap.Int64("merchant_id", orderCtx.MerchantID),
+		zap.Int64("channel_id", orderCtx.ChannelID),
+		zap.String("client_ip", clientIP),
+		zap.String("reason", denial.Reason))
+	return NewOrderError(ErrCodeChannelAccessDenied, "当前网络环境不支持该支付通道")
+}
+
+// CheckCashierAccess 进入收银台（展示支付链接）前检查支付通道访问策略
+// 拒绝时在订单详情备注中记录原因（同一原因只记录一次）
+func (s *CashierService) CheckCashierAccess(ctx context.Context, order *models.Order, clientIP, userAgent string) *ChannelAccessDenial {
+	if order == nil || order.PayChannelID == nil {
+		return nil
+	}
+	channel, err := s.orderService.cacheService.GetPayChannel(ctx, *order.PayChannelID)
+	if err != nil {
+		logger.Logger.Warn("查询支付通道失败，跳过访问策略检查",
+			zap.String("order_no", order.OrderNo),
+			zap.Int64("channel_id", *order.PayChannelID),
+			zap.Error(err))
+		return nil
+	}
+
+	denial := CheckChannelAccess(channel, clientIP, utils.DetectDeviceType(userAgent))
+	if denial == nil {
+		return nil
+	}
+	channelAccessDeniedTotal.WithLabelValues("cashier", denial.Rule).Inc()
+	logger.Logger.Warn("收银台访问被支付通道访问策略拒绝",
+		zap.String("order_no", order.OrderNo),
+		zap.Int64("channel_id", channel.ID),
+		zap.String("client_ip", clientIP),
+		zap.String("reason", denial.Reason))
+
+	s.appendAccessDeniedRemark(ctx, order, denial.Reason)
+	return denial
+}
+
+// appendAccessDeniedRemark 在订单详情备注中追加访问拒绝原因（同一原因只记录一次，避免用户反复刷新刷屏）
+func (s *CashierService) appendAccessDeniedRemark(ctx context.Context, order *models.Order, reason string) {
+	var detail models.OrderDetail
+	if err := database.DB.WithContext(ctx).Select("id", "remarks").
+		Where("order_id = ?", order.ID).First(&detail).Error; err != nil {
+		logger.Logger.Warn("查询订单详情失败，未记录访问拒绝备注",
+			zap.String("order_no", order.OrderNo),
+			zap.Error(err))
+		return
+	}
+	if strings.Contains(detail.Remarks, reason) {
+		return
+	}
+
+	remark := fmt.Sprintf("[%s] 收银台访问被拒绝: %s", time.Now().Format("2006-01-02 15:04:05"), reason)
+	if detail.Remarks != "" {
+		remark = detail.Remarks + "\n" + remark
+	}
+	if err := database.DB.WithContext(ctx).Model(&models.OrderDetail{}).
+		Where("id = ?", detail.ID).Update("remarks", remark).Error; err != nil {
+		logger.Logger.Warn("记录收银台访问拒绝备注失败",
+			zap.String("order_no", order.OrderNo),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestEvaluateChannelAccess(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	p := parseChannelAccessPolicy(&models.PayChannel{
+		BanIP:        `["1.2.3.4", "10.0.0.0/8", "bad"]`,
+		AccessPolicy: `{"deny_provinces":["广东"],"allow_cities":["杭州","上海"],"device_types":[1,2]}`,
+	})
+	hangzhou := &utils.IPLocationInfo{Address: "浙江省/杭州市", Province: "浙江省", City: "杭州市"}
+
+	cases := []struct {
+		name     string
+		ip       string
+		location *utils.IPLocationInfo
+		device   int
+		rule     string
+	}{
+		{"封禁IP", "1.2.3.4", hangzhou, models.DeviceTypeAndroid, channelAccessRuleIP},
+		{"封禁网段", "10.1.2.3", nil, models.DeviceTypeUnknown, channelAccessRuleIP},
+		{"拒绝省份", "5.6.7.8", &utils.IPLocationInfo{Province: "广东省", City: "深圳市"}, models.DeviceTypeAndroid, channelAccessRuleRegion},
+		{"不在允许城市", "5.6.7.8", &utils.IPLocationInfo{Province: "浙江省", City: "宁波市"}, models.DeviceTypeAndroid, channelAccessRuleRegion},
+		{"设备不允许", "5.6.7.8", hangzhou, models.DeviceTypePC, channelAccessRuleDevice},
+		{"允许", "5.6.7.8", hangzhou, models.DeviceTypeIOS, ""},
+		{"归属地和设备未知", "5.6.7.8", &utils.IPLocationInfo{Address: "本地"}, models.DeviceTypeUnknown, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			denial := evaluateChannelAccess(p, tc.ip, tc.location, tc.device)
+			if tc.rule == "" {
+				assert.Nil(t, denial)
+				return
+			}
+			if assert.NotNil(t, denial) {
+				assert.Equal(t, tc.rule, denial.Rule)
+			}
+		})
+	}
+
+	assert.True(t, parseChannelAccessPolicy(&models.PayChannel{BanIP: "[]", AccessPolicy: "null"}).empty())
+}
+
+func TestAppendAccessDeniedRemark(t *testing.T) {
+	db := setupTestDB(t)
+	oldDB, oldLogger := database.DB, logger.Logger
+	database.DB, logger.Logger = db, zap.NewNop()
+	t.Cleanup(func() {
+		database.DB, logger.Logger = oldDB, oldLogger
+	})
+
+	order := &models.Order{ID: "1", OrderNo: "P1", OutOrderNo: "M1"}
+	db.Create(order)
+	db.Create(&models.OrderDetail{OrderID: "1", Remarks: "已有备注"})
+
+	s := &CashierService{}
+	s.appendAccessDeniedRemark(context.Background(), order, "IP 1.2.3.4 已被封禁")
+	s.appendAccessDeniedRemark(context.Background(), order, "IP 1.2.3.4 已被封禁")
+
+	var detail models.OrderDetail
+	db.Where("order_id = ?", "1").First(&detail)
+	assert.True(t, strings.HasPrefix(detail.Remarks, "已有备注\n"))
+	assert.Equal(t, 1, strings.Count(detail.Remarks, "IP 1.2.3.4 已被封禁"))
+}
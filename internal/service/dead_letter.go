@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/mq"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrDeadLetterNotFound 死信记录不存在
+var ErrDeadLetterNotFound = errors.New("死信记录不存在")
+
+// 死信分页参数
+const (
+	deadLetterDefaultPageSize = 20
+	deadLetterMaxPageSize     = 100
+)
+
+// DeadLetterQuery 死信查询条件
+type DeadLetterQuery struct {
+	Topic    string // 主题（为空表示全部）
+	Status   *int   // 状态（为空表示全部）
+	Page     int    // 页码（从 1 开始）
+	PageSize int    // 每页条数（最大 100）
+}
+
+// DeadLetterListResponse 死信分页列表
+type DeadLetterListResponse struct {
+	Total int64                 `json:"total"`
+	List  []models.MQDeadLetter `json:"list"`
+}
+
+// DeadLetterService 消息死信服务（查看、重放处理失败次数达到上限的消息）
+type DeadLetterService struct {
+	bus mq.Bus // 消息总线（重放时发送到原主题）
+}
+
+// NewDeadLetterService 创建消息死信服务
+func NewDeadLetterService() *DeadLetterService {
+	return &DeadLetterService{bus: mq.GetBus()}
+}
+
+// List 按条件分页查询死信（按创建时间倒序）
+func (s *DeadLetterService) List(ctx context.Context, query DeadLetterQuery) (*DeadLetterListResponse, error) {
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = deadLetterDefaultPageSize
+	}
+	if query.PageSize > deadLetterMaxPageSize {
+		query.PageSize = deadLetterMaxPageSize
+	}
+
+	db := database.DB.WithContext(ctx).Model(&models.MQDeadLetter{})
+	if query.Topic != "" {
+		db = db.Where("topic = ?", query.Topic)
+	}
+	if query.Status != nil {
+		db = db.Where("status = ?", *query.Status)
+	}
+
+	result := &DeadLetterListResponse{List: []models.MQDeadLetter{}}
+	if err := db.Count(&result.Total).Error; err != nil {
+		return nil, fmt.Errorf("查询死信数量失败: %w", err)
+	}
+	if err := db.Order("id DESC").
+		Offset((query.Page - 1) * query.PageSize).
+		Limit(query.PageSize).
+		Find(&result.List).Error; err != nil {
+		return nil, fmt.Errorf("查询死信列表失败: %w", err)
+	}
+	return result, nil
+}
+
+// Get 查询单条死信
+func (s *DeadLetterService) Get(ctx context.Context, id int64) (*models.MQDeadLetter, error) {
+	var record models.MQDeadLetter
+	if err := database.DB.WithContext(ctx).Where("id = ?", id).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeadLetterNotFound
+		}
+		return nil, fmt.Errorf("查询死信失败: %w", err)
+	}
+	return &record, nil
+}
+
+// Replay 将死信重新发送到原主题（重新从第 1 次投递计数），并标记为已重放
+// 已重放的死信可以再次重放（例如重放后仍然失败又产生了新的死信时，以新记录为准）
+func (s *DeadLetterService) Replay(ctx context.Context, id int64) (*models.MQDeadLetter, error) {
+	record, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.bus == nil || !s.bus.IsEnabled() {
+		return nil, fmt.Errorf("消息总线未启用，无法重放")
+	}
+	if err := s.bus.Publish(ctx, record.Topic, record.Tag, []byte(record.Body)); err != nil {
+		return nil, fmt.Errorf("重放死信失败: %w", err)
+	}
+
+	now := time.Now()
+	if err := database.DB.WithContext(ctx).Model(&models.MQDeadLetter{}).
+		Where("id = ?", record.ID).
+		Updates(map[string]interface{}{
+			"status":          models.DeadLetterStatusReplayed,
+			"replay_count":    gorm.Expr("replay_count + 1"),
+			"replay_datetime": now,
+			"update_datetime": now,
+		}).Error; err != nil {
+		// 消息已重新发送，状态更新失败只记录日志
+		logger.Logger.Warn("更新死信状态失败",
+			zap.Int64("dead_letter_id", record.ID),
+			zap.Error(err))
+	}
+
+	record.Status = models.DeadLetterStatusReplayed
+	record.ReplayCount++
+	record.ReplayDatetime = &now
+	record.UpdateDatetime = &now
+
+	logger.Logger.Info("死信已重放",
+		zap.Int64("dead_letter_id", record.ID),
+		zap.String("topic", record.Topic),
+		zap.String("message_id", record.MessageID),
+		zap.Int("replay_count", record.ReplayCount))
+	return record, nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/mq"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// fakeBus 记录发送的消息
+type fakeBus struct {
+	mq.Bus
+	enabled    bool
+	publishErr error
+	published  []fakeBusMessage
+}
+
+type fakeBusMessage struct {
+	topic, tag string
+	body       interface{}
+}
+
+func (b *fakeBus) IsEnabled() bool { return b.enabled }
+
+func (b *fakeBus) Publish(ctx context.Context, topic, tag string, body interface{}) error {
+	if b.publishErr != nil {
+		return b.publishErr
+	}
+	b.published = append(b.published, fakeBusMessage{topic: topic, tag: tag, body: body})
+	return nil
+}
+
+func TestDeadLetterReplay(t *testing.T) {
+	db := setupTestDB(t)
+	assert.NoError(t, db.AutoMigrate(&models.MQDeadLetter{}))
+	oldDB, oldLogger := database.DB, logger.Logger
+	database.DB, logger.Logger = db, zap.NewNop()
+	t.Cleanup(func() {
+		database.DB, logger.Logger = oldDB, oldLogger
+	})
+
+	now := time.Now()
+	record := &models.MQDeadLetter{Backend: mq.BusBackendRedis, Topic: mq.TopicOrderNotify, Tag: "notify",
+		MessageID: "m1", Body: `{"order_id":"O1"}`, Error: "failed", Attempts: 16,
+		Status: models.DeadLetterStatusPending, CreateDatetime: &now, UpdateDatetime: &now}
+	assert.NoError(t, db.Create(record).Error)
+	ctx := context.Background()
+
+	// 总线未启用或发送失败：不标记为已重放
+	disabled := &DeadLetterService{bus: &fakeBus{}}
+	_, err := disabled.Replay(ctx, record.ID)
+	assert.Error(t, err)
+	failing := &DeadLetterService{bus: &fakeBus{enabled: true, publishErr: errors.New("send failed")}}
+	_, err = failing.Replay(ctx, record.ID)
+	assert.Error(t, err)
+	stored, _ := disabled.Get(ctx, record.ID)
+	assert.Equal(t, models.DeadLetterStatusPending, stored.Status)
+	assert.Equal(t, 0, stored.ReplayCount)
+
+	// 重放发送原主题、原标签和原消息体，可以多次重放
+	bus := &fakeBus{enabled: true}
+	s := &DeadLetterService{bus: bus}
+	for i := 1; i <= 2; i++ {
+		replayed, err := s.Replay(ctx, record.ID)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, models.DeadLetterStatusReplayed, replayed.Status)
+		assert.Equal(t, i, replayed.ReplayCount)
+	}
+	if assert.Len(t, bus.published, 2) {
+		assert.Equal(t, mq.TopicOrderNotify, bus.published[0].topic)
+		assert.Equal(t, "notify", bus.published[0].tag)
+		assert.Equal(t, []byte(`{"order_id":"O1"}`), bus.published[0].body)
+	}
+	stored, _ = s.Get(ctx, record.ID)
+	assert.Equal(t, models.DeadLetterStatusReplayed, stored.Status)
+	assert.Equal(t, 2, stored.ReplayCount)
+	assert.NotNil(t, stored.ReplayDatetime)
+
+	_, err = s.Replay(ctx, record.ID+100)
+	assert.ErrorIs(t, err, ErrDeadLetterNotFound)
+}
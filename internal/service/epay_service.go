@@ -0,0 +1,435 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 易支付（EPay）协议常量
+const (
+	EPayTradeSuccess = "TRADE_SUCCESS" // 易支付交易成功状态
+	EPaySignType     = "MD5"           // 易支付签名类型
+	EPayNotifyOK     = "success"       // 易支付商户通知成功应答
+
+	// epayTypeChannelConfig 易支付支付方式与支付通道的映射配置
+	// 值为 JSON 对象，如 {"alipay": 1, "wxpay": 2}；支付方式为数字时直接作为支付通道ID
+	epayTypeChannelConfig = "epay.type_channel"
+)
+
+// EPayExtra 易支付下单附加参数（保存在订单额外参数 req_extra 中，用于商户通知和查单）
+type EPayExtra struct {
+	Type     string `json:"epay_type"`             // 支付方式（alipay/wxpay/qqpay 等）
+	Name     string `json:"epay_name"`             // 商品名称
+	Param    string `json:"epay_param,omitempty"`  // 商户业务扩展参数（原样返回）
+	Device   string `json:"epay_device,omitempty"` // 设备类型
+	ClientIP string `json:"client_ip,omitempty"`   // 用户IP（微信H5等插件使用）
+}
+
+// parseEPayExtra 从订单额外参数解析易支付附加参数
+func parseEPayExtra(reqExtra string) *EPayExtra {
+	extra := &EPayExtra{}
+	if reqExtra != "" {
+		_ = json.Unmarshal([]byte(reqExtra), extra)
+	}
+	return extra
+}
+
+// EPayService 易支付协议适配服务
+// 将易支付 submit.php / mapi.php / api.php 协议映射到 OrderService、OrderRefundService
+// 订单以兼容模式（compatible = 1）创建，签名规则使用 utils.GetSign 的 yiSign 分支
+type EPayService struct {
+	orderService  *OrderService
+	refundService *OrderRefundService
+	cacheService  *CacheService
+	configService *SystemConfigService
+}
+
+// NewEPayService 创建易支付协议适配服务
+func NewEPayService() *EPayService {
+	orderService := NewOrderService()
+	return &EPayService{
+		orderService:  orderService,
+		refundService: NewOrderRefundService(),
+		cacheService:  orderService.cacheService,
+		configService: NewSystemConfigService(),
+	}
+}
+
+// CreateOrder 易支付下单（submit.php / mapi.php）
+// params 为商户提交的全部参数（参与签名），requestMethod 用于订单日志
+func (s *EPayService) CreateOrder(ctx context.Context, params map[string]string, requestMethod string) (*CreateOrderRequest, *CreateOrderResponse, *OrderError) {
+	merchantID, err := strconv.Atoi(params["pid"])
+	if err != nil || merchantID <= 0 {
+		return nil, nil, ErrMerchantNotFound
+	}
+
+	money, err := ParseEPayMoney(params["money"])
+	if err != nil {
+		return nil, nil, ErrAmountInvalid
+	}
+
+	channelID, orderErr := s.resolveChannelID(ctx, params["type"])
+	if orderErr != nil {
+		return nil, nil, orderErr
+	}
+
+	extraJSON, _ := json.Marshal(&EPayExtra{
+		Type:     params["type"],
+		Name:     params["name"],
+		Param:    params["param"],
+		Device:   params["device"],
+		ClientIP: params["clientip"],
+	})
+
+	rawSignData := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		rawSignData[k] = v
+	}
+	requestBody, _ := json.Marshal(params)
+
+	req := &CreateOrderRequest{
+		OutOrderNo:    params["out_trade_no"],
+		MerchantID:    merchantID,
+		ChannelID:     channelID,
+		Money:         money,
+		NotifyURL:     params["notify_url"],
+		JumpURL:       params["return_url"],
+		Extra:         string(extraJSON),
+		Compatible:    1,
+		ClientIP:      params["clientip"],
+		Sign:          params["sign"],
+		RawSignData:   rawSignData,
+		SignRaw:       string(requestBody),
+		RequestMethod: requestMethod,
+		RequestBody:   string(requestBody),
+	}
+
+	if req.NotifyURL == "" {
+		return nil, nil, NewOrderError(ErrCodeCreateFailed, "异步通知地址不能为空")
+	}
+
+	resp, orderErr := s.orderService.CreateOrder(ctx, req)
+	if orderErr != nil {
+		return req, nil, orderErr
+	}
+	return req, resp, nil
+}
+
+// resolveChannelID 将易支付支付方式映射为支付通道ID
+func (s *EPayService) resolveChannelID(ctx context.Context, payType string) (int, *OrderError) {
+	if payType == "" {
+		return 0, NewOrderError(ErrCodeChannelNotFound, "支付方式不能为空")
+	}
+
+	// 支付方式为数字时直接作为支付通道ID
+	if channelID, err := strconv.Atoi(payType); err == nil && channelID > 0 {
+		return channelID, nil
+	}
+
+	value, err := s.configService.GetSystemConfigByPath(ctx, epayTypeChannelConfig)
+	if err != nil {
+		logger.Logger.Warn("获取易支付支付方式映射失败",
+			zap.String("type", payType),
+			zap.Error(err))
+		return 0, ErrSystemBusy
+	}
+
+	var mapping map[string]interface{}
+	if value != "" {
+		_ = json.Unmarshal([]byte(value), &mapping)
+	}
+	// 配置值可能包裹在 value 字段中
+	if inner, ok := mapping["value"].(map[string]interface{}); ok {
+		mapping = inner
+	}
+
+	switch v := mapping[payType].(type) {
+	case float64:
+		if v > 0 {
+			return int(v), nil
+		}
+	case string:
+		if channelID, err := strconv.Atoi(v); err == nil && channelID > 0 {
+			return channelID, nil
+		}
+	}
+
+	return 0, NewOrderError(ErrCodeChannelNotFound, fmt.Sprintf("不支持的支付方式: %s", payType))
+}
+
+// authenticate 易支付 api.php 商户鉴权（商户ID + 商户密钥）
+func (s *EPayService) authenticate(ctx context.Context, pid, key string) (int64, *SystemUser, *OrderError) {
+	merchantID, err := strconv.ParseInt(pid, 10, 64)
+	if err != nil || merchantID <= 0 {
+		return 0, nil, ErrMerchantNotFound
+	}
+
+	_, user, err := s.cacheService.GetMerchantWithUser(ctx, merchantID)
+	if err != nil {
+		return 0, nil, ErrMerchantNotFound
+	}
+	if user == nil || !user.Status {
+		return 0, nil, ErrMerchantDisabled
+	}
+	if !s.cacheService.MatchMerchantEPayKey(ctx, merchantID, user.Key, key) {
+		return 0, nil, NewOrderError(ErrCodeSignInvalid, "商户密钥错误")
+	}
+
+	return merchantID, user, nil
+}
+
+// findMerchantOrder 按系统订单号或商户订单号查询商户订单
+func (s *EPayService) findMerchantOrder(merchantID int64, tradeNo, outTradeNo string) (*models.Order, *OrderError) {
+	query := database.DB.Preload("OrderDetail").Where("merchant_id = ?", merchantID)
+	switch {
+	case tradeNo != "":
+		query = query.Where("order_no = ?", tradeNo)
+	case outTradeNo != "":
+		query = query.Where("out_order_no = ?", outTradeNo)
+	default:
+		return nil, NewOrderError(ErrCodeOutOrderNoRequired, "订单号不能为空")
+	}
+
+	var order models.Order
+	if err := query.First(&order).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrRefundOrderNotFound
+		}
+		return nil, ErrSystemBusy
+	}
+	return &order, nil
+}
+
+// QueryOrder 易支付查询单个订单（api.php?act=order）
+func (s *EPayService) QueryOrder(ctx context.Context, pid, key, tradeNo, outTradeNo string) (map[string]interface{}, *OrderError) {
+	merchantID, _, orderErr := s.authenticate(ctx, pid, key)
+	if orderErr != nil {
+		return nil, orderErr
+	}
+
+	order, orderErr := s.findMerchantOrder(merchantID, tradeNo, outTradeNo)
+	if orderErr != nil {
+		return nil, orderErr
+	}
+
+	extra := parseEPayExtra(order.ReqExtra)
+	status := 0
+	if order.OrderStatus == models.OrderStatusPaid || order.OrderStatus == models.OrderStatusPaidNoNotify {
+		status = 1
+	}
+
+	result := map[string]interface{}{
+		"code":         1,
+		"msg":          "查询订单号成功！",
+		"trade_no":     order.OrderNo,
+		"out_trade_no": order.OutOrderNo,
+		"type":         extra.Type,
+		"pid":          merchantID,
+		"name":         extra.Name,
+		"money":        FormatEPayMoney(order.Money),
+		"status":       status,
+		"param":        extra.Param,
+		"addtime":      "",
+		"endtime":      "",
+		"api_trade_no": "",
+		"buyer":        "",
+	}
+	if order.CreateDatetime != nil {
+		result["addtime"] = order.CreateDatetime.Format("2006-01-02 15:04:05")
+	}
+	if order.PayDatetime != nil {
+		result["endtime"] = order.PayDatetime.Format("2006-01-02 15:04:05")
+	}
+	if order.OrderDetail != nil {
+		result["api_trade_no"] = order.OrderDetail.TicketNo
+		result["buyer"] = order.OrderDetail.BuyerID
+	}
+	return result, nil
+}
+
+// QueryMerchant 易支付查询商户信息（api.php?act=query）
+// 本系统商户不单独维护余额，money 返回今日成功订单金额
+func (s *EPayService) QueryMerchant(ctx context.Context, pid, key string) (map[string]interface{}, *OrderError) {
+	merchantID, user, orderErr := s.authenticate(ctx, pid, key)
+	if orderErr != nil {
+		return nil, orderErr
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	lastday := today.AddDate(0, 0, -1)
+	paidStatus := []int{models.OrderStatusPaid, models.OrderStatusPaidNoNotify}
+
+	var orders, orderToday, orderLastday int64
+	var todayMoney int64
+	if err := database.DB.Model(&models.Order{}).Where("merchant_id = ?", merchantID).Count(&orders).Error; err != nil {
+		return nil, s.queryMerchantFailed(merchantID, err)
+	}
+	if err := database.DB.Model(&models.Order{}).
+		Where("merchant_id = ? AND order_status IN ? AND create_datetime >= ?", merchantID, paidStatus, today).
+		Count(&orderToday).Error; err != nil {
+		return nil, s.queryMerchantFailed(merchantID, err)
+	}
+	if err := database.DB.Model(&models.Order{}).
+		Where("merchant_id = ? AND order_status IN ? AND create_datetime >= ? AND create_datetime < ?", merchantID, paidStatus, lastday, today).
+		Count(&orderLastday).Error; err != nil {
+		return nil, s.queryMerchantFailed(merchantID, err)
+	}
+	if err := database.DB.Model(&models.Order{}).
+		Where("merchant_id = ? AND order_status IN ? AND create_datetime >= ?", merchantID, paidStatus, today).
+		Select("COALESCE(SUM(money), 0)").
+		Scan(&todayMoney).Error; err != nil {
+		return nil, s.queryMerchantFailed(merchantID, err)
+	}
+
+	active := 0
+	if user.Status {
+		active = 1
+	}
+
+	return map[string]interface{}{
+		"code":          1,
+		"pid":           merchantID,
+		"active":        active,
+		"money":         FormatEPayMoney(int(todayMoney)),
+		"orders":        orders,
+		"order_today":   orderToday,
+		"order_lastday": orderLastday,
+	}, nil
+}
+
+// queryMerchantFailed 记录查询商户订单统计失败
+func (s *EPayService) queryMerchantFailed(merchantID int64, err error) *OrderError {
+	logger.Logger.Error("查询易支付商户订单统计失败",
+		zap.Int64("merchant_id", merchantID),
+		zap.Error(err))
+	return ErrSystemBusy
+}
+
+// Refund 易支付订单退款（api.php?act=refund）
+// money 为退款金额（元），为空时退还剩余全部金额
+func (s *EPayService) Refund(ctx context.Context, pid, key, tradeNo, outTradeNo, money string) (*RefundOrderResponse, *OrderError) {
+	merchantID, _, orderErr := s.authenticate(ctx, pid, key)
+	if orderErr != nil {
+		return nil, orderErr
+	}
+
+	order, orderErr := s.findMerchantOrder(merchantID, tradeNo, outTradeNo)
+	if orderErr != nil {
+		return nil, orderErr
+	}
+
+	refundAmount := 0
+	if money != "" {
+		amount, err := ParseEPayMoney(money)
+		if err != nil {
+			return nil, ErrRefundAmountInvalid
+		}
+		refundAmount = amount
+	}
+
+	return s.refundService.RefundAuthorizedOrder(ctx, &RefundOrderRequest{
+		OrderNo:      order.OrderNo,
+		MerchantID:   int(merchantID),
+		RefundAmount: refundAmount,
+		Reason:       "易支付API退款",
+	})
+}
+
+// BuildReturnURL 构建易支付同步跳转地址（return_url 携带与异步通知相同的签名参数）
+func (s *EPayService) BuildReturnURL(ctx context.Context, order *models.Order, orderDetail *models.OrderDetail) (string, error) {
+	if orderDetail.JumpURL == "" {
+		return "", fmt.Errorf("订单未设置跳转地址")
+	}
+	if order.MerchantID == nil {
+		return "", fmt.Errorf("订单没有商户ID")
+	}
+
+	_, user, err := s.cacheService.GetMerchantWithUser(ctx, *order.MerchantID)
+	if err != nil || user == nil {
+		return "", fmt.Errorf("查询商户失败: %v", err)
+	}
+
+	epayKey, err := s.cacheService.GetMerchantEPayKey(ctx, *order.MerchantID, user.Key)
+	if err != nil {
+		return "", err
+	}
+	return AppendQuery(orderDetail.JumpURL, BuildEPayNotifyParams(order, orderDetail, epayKey)), nil
+}
+
+// BuildEPayNotifyParams 构建易支付异步通知 / 同步跳转参数（含签名）
+func BuildEPayNotifyParams(order *models.Order, orderDetail *models.OrderDetail, key string) map[string]string {
+	extra := parseEPayExtra(order.ReqExtra)
+
+	params := map[string]string{
+		"trade_no":     order.OrderNo,
+		"out_trade_no": order.OutOrderNo,
+		"type":         extra.Type,
+		"name":         extra.Name,
+		"money":        FormatEPayMoney(order.Money),
+		"trade_status": EPayTradeSuccess,
+		"param":        extra.Param,
+	}
+	if order.MerchantID != nil {
+		params["pid"] = strconv.FormatInt(*order.MerchantID, 10)
+	}
+	if orderDetail != nil && orderDetail.TicketNo != "" {
+		params["api_trade_no"] = orderDetail.TicketNo
+	}
+
+	params["sign"] = utils.GetEPaySign(params, key)
+	params["sign_type"] = EPaySignType
+	return params
+}
+
+// AppendQuery 在地址后追加查询参数（兼容已带查询参数的地址）
+func AppendQuery(rawURL string, params map[string]string) string {
+	values := url.Values{}
+	for k, v := range params {
+		values.Set(k, v)
+	}
+	separator := "?"
+	if strings.Contains(rawURL, "?") {
+		separator = "&"
+	}
+	return rawURL + separator + values.Encode()
+}
+
+// epayMoneyPattern 易支付金额格式：非负十进制数，最多两位小数
+// 不接受符号、指数、十六进制和 NaN/Inf 等 strconv.ParseFloat 可解析的写法
+var epayMoneyPattern = regexp.MustCompile(`^\d+(\.\d{1,2})?$`)
+
+// ParseEPayMoney 解析易支付金额（元，最多两位小数）为分
+func ParseEPayMoney(money string) (int, error) {
+	money = strings.TrimSpace(money)
+	if !epayMoneyPattern.MatchString(money) {
+		return 0, fmt.Errorf("金额格式错误: %s", money)
+	}
+	yuan, fen, _ := strings.Cut(money, ".")
+	for len(fen) < 2 {
+		fen += "0"
+	}
+	value, err := strconv.Atoi(yuan + fen)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("金额格式错误: %s", money)
+	}
+	return value, nil
+}
+
+// FormatEPayMoney 将金额（分）格式化为易支付金额（元，两位小数）
+func FormatEPayMoney(money int) string {
+	return fmt.Sprintf("%.2f", float64(money)/100)
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestParseEPayMoney(t *testing.T) {
+	cases := []struct {
+		money    string
+		expected int
+		ok       bool
+	}{
+		{"1", 100, true},
+		{"1.5", 150, true},
+		{"1.05", 105, true},
+		{"100.00", 10000, true},
+		{" 0.01 ", 1, true},
+		{"0019.90", 1990, true},
+		{"", 0, false},
+		{"0", 0, false},
+		{"0.00", 0, false},
+		{"1.234", 0, false},
+		{"1.", 0, false},
+		{".5", 0, false},
+		{"-1", 0, false},
+		{"+5", 0, false},
+		{"1e2", 0, false},
+		{"0x1p-2", 0, false},
+		{"NaN", 0, false},
+		{"Inf", 0, false},
+		{"1,000", 0, false},
+		{"99999999999999999999", 0, false},
+	}
+	for _, c := range cases {
+		value, err := ParseEPayMoney(c.money)
+		if c.ok {
+			if assert.NoError(t, err, c.money) {
+				assert.Equal(t, c.expected, value, c.money)
+			}
+		} else {
+			assert.Error(t, err, c.money)
+		}
+	}
+}
+
+// setupEPayTest 准备易支付下单测试环境：商户 1（密钥 user_key）、租户 9 及其系统用户缓存
+// 库中没有支付通道，验签通过的请求止于通道校验
+func setupEPayTest(t *testing.T) *EPayService {
+	db := setupTestDB(t)
+	mr := setupTestRedis(t)
+	oldDB, oldLogger := database.DB, logger.Logger
+	database.DB, logger.Logger = db, zap.NewNop()
+	t.Cleanup(func() {
+		database.DB, logger.Logger = oldDB, oldLogger
+	})
+
+	cacheTestMerchant(mr, models.Merchant{})
+	tenantUserID := int64(200)
+	db.Create(&models.Tenant{ID: 9, SystemUserID: &tenantUserID})
+	tenantJSON, _ := json.Marshal(models.Tenant{ID: 9})
+	tenantUserJSON, _ := json.Marshal(SystemUser{ID: tenantUserID, Status: true})
+	mr.Set("tenant:9", string(tenantJSON))
+	mr.Set(fmt.Sprintf("user:%d", tenantUserID), string(tenantUserJSON))
+	mr.Set(merchantSignKeysCacheKey(1), "[]")
+
+	return NewEPayService()
+}
+
+func epayTestParams(key string) map[string]string {
+	params := map[string]string{
+		"pid":          "1",
+		"type":         "3",
+		"out_trade_no": "M001",
+		"notify_url":   "https://merchant.example.com/notify",
+		"return_url":   "https://merchant.example.com/return",
+		"name":         "商品",
+		"money":        "10.00",
+		"param":        "",
+		"sign_type":    EPaySignType,
+	}
+	params["sign"] = utils.GetEPaySign(params, key)
+	return params
+}
+
+func TestEPayServiceCreateOrderSign(t *testing.T) {
+	s := setupEPayTest(t)
+	ctx := context.Background()
+
+	// 签名正确（小写或大写 MD5）：通过验签，止于通道校验
+	params := epayTestParams("user_key")
+	req, _, orderErr := s.CreateOrder(ctx, params, "POST")
+	if assert.NotNil(t, orderErr) {
+		assert.Equal(t, ErrChannelNotFound.Code, orderErr.Code)
+	}
+	assert.True(t, strings.EqualFold(params["sign"], req.Sign))
+	assert.Equal(t, 1000, req.Money)
+	assert.Equal(t, 3, req.ChannelID)
+	assert.Equal(t, 1, req.Compatible)
+
+	cases := []struct {
+		name   string
+		modify func(map[string]string)
+		code   int
+	}{
+		{"uppercase sign", func(p map[string]string) {
+			p["out_trade_no"] = "M002"
+			p["sign"] = strings.ToUpper(utils.GetEPaySign(p, "user_key"))
+		}, ErrChannelNotFound.Code},
+		{"wrong key", func(p map[string]string) { p["sign"] = utils.GetEPaySign(p, "other_key") }, ErrCodeSignInvalid},
+		{"tampered money", func(p map[string]string) { p["money"] = "1.00" }, ErrCodeSignInvalid},
+		{"missing sign", func(p map[string]string) { delete(p, "sign") }, ErrCodeSignInvalid},
+		{"invalid money", func(p map[string]string) { p["money"] = "1e2" }, ErrAmountInvalid.Code},
+		{"invalid pid", func(p map[string]string) { p["pid"] = "abc" }, ErrMerchantNotFound.Code},
+		{"missing type", func(p map[string]string) { p["type"] = "" }, ErrCodeChannelNotFound},
+	}
+	for _, c := range cases {
+		p := epayTestParams("user_key")
+		c.modify(p)
+		_, _, orderErr := s.CreateOrder(ctx, p, "POST")
+		if assert.NotNil(t, orderErr, c.name) {
+			assert.Equal(t, c.code, orderErr.Code, c.name)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-pay-core/config"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/utils"
+)
+
+// MerchantSignResult 商户请求验签结果
+type MerchantSignResult struct {
+	Key     *models.MerchantSignKey // 验签使用的签名密钥（使用系统用户密钥验签时为 nil）
+	SignRaw string                  // 待签名字符串
+	Sign    string                  // 签名
+}
+
+// merchantSignKeysCacheKey 商户签名密钥缓存键
+func merchantSignKeysCacheKey(merchantID int64) string {
+	return fmt.Sprintf("merchant_sign_keys:%d", merchantID)
+}
+
+// GetMerchantSignKeys 获取商户的全部签名密钥（带缓存，包含停用和不在有效期内的密钥，使用时按有效期过滤）
+func (s *CacheService) GetMerchantSignKeys(ctx context.Context, merchantID int64) ([]models.MerchantSignKey, error) {
+	cacheKey := merchantSignKeysCacheKey(merchantID)
+
+	// 尝试从缓存获取
+	if s.redis != nil {
+		if val, err := s.redis.Get(ctx, cacheKey).Result(); err == nil {
+			var keys []models.MerchantSignKey
+			if err := json.Unmarshal([]byte(val), &keys); err == nil {
+				return keys, nil
+			}
+		}
+	}
+
+	// 从数据库获取（未初始化数据库时视为没有配置签名密钥）
+	keys := make([]models.MerchantSignKey, 0)
+	if database.DB == nil {
+		return keys, nil
+	}
+	if err := database.DB.WithContext(ctx).Where("merchant_id = ?", merchantID).
+		Order("id").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+
+	// 缓存签名密钥（没有密钥时同样缓存，永不过期，通过 MQ 消息主动更新）
+	if s.redis != nil {
+		if data, err := json.Marshal(keys); err == nil {
+			s.redis.Set(ctx, cacheKey, data, 0)
+		}
+	}
+
+	return keys, nil
+}
+
+// VerifyMerchantSign 验证商户请求签名
+// 商户没有配置签名密钥时使用系统用户密钥（legacyKey）按原有 MD5 规则验签；
+// 否则请求携带 keyId 时只使用对应的密钥，未携带时依次尝试所有有效的密钥
+func (s *CacheService) VerifyMerchantSign(ctx context.Context, merchantID int64, legacyKey string, rawSignData map[string]interface{}, compatible int) (*MerchantSignResult, *OrderError) {
+	keys, err := s.GetMerchantSignKeys(ctx, merchantID)
+	if err != nil {
+		return nil, ErrSystemBusy
+	}
+	return verifyMerchantSign(keys, legacyKey, rawSignData, compatible, time.Now())
+}
+
+// verifyMerchantSign 按商户签名密钥验签
+func verifyMerchantSign(keys []models.MerchantSignKey, legacyKey string, rawSignData map[string]interface{}, compatible int, now time.Time) (*MerchantSignResult, *OrderError) {
+	sign, ok := rawSignData["sign"].(string)
+	if !ok || sign == "" {
+		return nil, ErrSignInvalid
+	}
+
+	if len(keys) == 0 {
+		if legacyKey == "" {
+			return nil, ErrSignInvalid
+		}
+		signRaw, actualSign := utils.GetSign(rawSignData, legacyKey, nil, nil, compatible)
+		// 兼容模式（易支付协议）的 SDK 使用小写 MD5，签名比较不区分大小写
+		signMatched := sign == actualSign
+		if compatible == 1 {
+			signMatched = strings.EqualFold(sign, actualSign)
+		}
+		if !signMatched {
+			printExpectedSign(sign, actualSign)
+			return nil, ErrSignInvalid
+		}
+		return &MerchantSignResult{SignRaw: signRaw, Sign: actualSign}, nil
+	}
+
+	keyID, _ := rawSignData["keyId"].(string)
+	content := utils.SignContent(rawSignData, compatible)
+	for i := range keys {
+		key := &keys[i]
+		if !key.Active(now) || (keyID != "" && key.KeyID != keyID) {
+			continue
+		}
+		verifyKey := key.Secret
+		if key.SignType == models.SignTypeRSA2 {
+			verifyKey = key.MerchantPublicKey
+		}
+		if utils.VerifyWithKey(key.SignType, verifyKey, content, sign, compatible) {
+			return &MerchantSignResult{Key: key, SignRaw: content, Sign: sign}, nil
+		}
+	}
+	printExpectedSign(sign, content)
+	return nil, ErrSignInvalid
+}
+
+// printExpectedSign 开发、测试模式下输出签名和正确签名（非对称算法输出待签名字符串）
+func printExpectedSign(sign, expected string) {
+	if config.Cfg != nil && (config.Cfg.App.Mode == "debug" || config.Cfg.App.Mode == "test") {
+		fmt.Println("sign", sign)
+		fmt.Println("actualSign", expected)
+	}
+}
+
+// SignMerchantData 签名发送给商户的数据（下单响应、异步通知），设置 data 的 sign
+// key 为空时使用商户当前的签名密钥；使用签名密钥时同时设置 keyId 和 signType（参与签名）。
+// 商户没有配置签名密钥时使用系统用户密钥，与原有签名一致
+func (s *CacheService) SignMerchantData(ctx context.Context, merchantID int64, legacyKey string, key *models.MerchantSignKey, data map[string]interface{}, compatible int) error {
+	if key == nil {
+		keys, err := s.GetMerchantSignKeys(ctx, merchantID)
+		if err != nil {
+			return fmt.Errorf("查询商户签名密钥失败: %w", err)
+		}
+		if len(keys) == 0 {
+			data["sign"] = utils.GenerateResponseSign(data, legacyKey, compatible)
+			return nil
+		}
+		if key = currentMerchantSignKey(keys, "", time.Now()); key == nil {
+			return fmt.Errorf("商户 %d 没有可用于签名的有效密钥", merchantID)
+		}
+	}
+	return signMerchantData(key, data, compatible)
+}
+
+// signMerchantData 使用签名密钥签名
+func signMerchantData(key *models.MerchantSignKey, data map[string]interface{}, compatible int) error {
+	signKey := key.Secret
+	if key.SignType == models.SignTypeRSA2 {
+		signKey = key.PlatformPrivateKey
+	}
+	data["keyId"] = key.KeyID
+	data["signType"] = key.SignType
+	delete(data, "sign")
+
+	sign, err := utils.SignWithKey(key.SignType, signKey, utils.SignContent(data, compatible), compatible)
+	if err != nil {
+		return fmt.Errorf("使用商户密钥 %s 签名失败: %w", key.KeyID, err)
+	}
+	data["sign"] = sign
+	return nil
+}
+
+// currentMerchantSignKey 当前的签名密钥：有效期内、可以签名的密钥中最晚生效的（相同时取最后创建的）
+// signType 不为空时只选择该算法的密钥
+func currentMerchantSignKey(keys []models.MerchantSignKey, signType string, now time.Time) *models.MerchantSignKey {
+	var current *models.MerchantSignKey
+	for i := range keys {
+		key := &keys[i]
+		if !key.Active(now) || !key.CanSign() || (signType != "" && key.SignType != signType) {
+			continue
+		}
+		if current == nil || signKeyValidFrom(key).After(signKeyValidFrom(current)) ||
+			(signKeyValidFrom(key).Equal(signKeyValidFrom(current)) && key.ID > current.ID) {
+			current = key
+		}
+	}
+	return current
+}
+
+// signKeyValidFrom 密钥生效时间（为空时视为最早）
+func signKeyValidFrom(key *models.MerchantSignKey) time.Time {
+	if key.ValidFrom == nil {
+		return time.Time{}
+	}
+	return *key.ValidFrom
+}
+
+// GetMerchantEPayKey 易支付协议（只支持 MD5）签名使用的密钥
+// 商户没有配置签名密钥时使用系统用户密钥，否则使用当前的 md5 签名密钥
+func (s *CacheService) GetMerchantEPayKey(ctx context.Context, merchantID int64, legacyKey string) (string, error) {
+	keys, err := s.GetMerchantSignKeys(ctx, merchantID)
+	if err != nil {
+		return "", fmt.Errorf("查询商户签名密钥失败: %w", err)
+	}
+	if len(keys) == 0 {
+		return legacyKey, nil
+	}
+	key := currentMerchantSignKey(keys, models.SignTypeMD5, time.Now())
+	if key == nil {
+		return "", fmt.Errorf("商户 %d 没有有效的 md5 签名密钥，无法使用易支付协议签名", merchantID)
+	}
+	return key.Secret, nil
+}
+
+// MatchMerchantEPayKey 易支付 api.php 明文密钥鉴权：与系统用户密钥（没有配置签名密钥时）或任一有效的 md5 签名密钥一致
+func (s *CacheService) MatchMerchantEPayKey(ctx context.Context, merchantID int64, legacyKey, key string) bool {
+	if key == "" {
+		return false
+	}
+	keys, err := s.GetMerchantSignKeys(ctx, merchantID)
+	if err != nil {
+		return false
+	}
+	if len(keys) == 0 {
+		return legacyKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(legacyKey)) == 1
+	}
+	now := time.Now()
+	for i := range keys {
+		if keys[i].SignType == models.SignTypeMD5 && keys[i].Active(now) && keys[i].Secret != "" &&
+			subtle.ConstantTimeCompare([]byte(key), []byte(keys[i].Secret)) == 1 {
+			return true
+		}
+	}
+	return false
+}
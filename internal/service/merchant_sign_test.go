@@ -0,0 +1,119 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyMerchantSign(t *testing.T) {
+	now := time.Now()
+	expired := now.Add(-time.Hour)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+	rsaPub, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	rsaPriv, _ := x509.MarshalPKCS8PrivateKey(rsaKey)
+
+	keys := []models.MerchantSignKey{
+		{ID: 1, MerchantID: 1, KeyID: "old", SignType: models.SignTypeHMACSHA256, Secret: "old_secret", Status: true, ValidUntil: &expired},
+		{ID: 2, MerchantID: 1, KeyID: "hmac", SignType: models.SignTypeHMACSHA256, Secret: "hmac_secret", Status: true},
+		{ID: 3, MerchantID: 1, KeyID: "rsa", SignType: models.SignTypeRSA2, Status: true,
+			MerchantPublicKey: base64.StdEncoding.EncodeToString(rsaPub), PlatformPrivateKey: base64.StdEncoding.EncodeToString(rsaPriv)},
+	}
+	params := func() map[string]interface{} {
+		return map[string]interface{}{"mchId": 1, "mchOrderNo": "TEST001", "amount": 10000}
+	}
+
+	// 没有配置签名密钥时使用系统用户密钥
+	legacy := params()
+	_, legacy["sign"] = utils.GetSign(legacy, "legacy_key", nil, nil, 0)
+	result, orderErr := verifyMerchantSign(nil, "legacy_key", legacy, 0, now)
+	if assert.Nil(t, orderErr) {
+		assert.Nil(t, result.Key)
+	}
+	// 配置了签名密钥后不再接受系统用户密钥
+	_, orderErr = verifyMerchantSign(keys, "legacy_key", legacy, 0, now)
+	assert.Equal(t, ErrCodeSignInvalid, orderErr.Code)
+
+	hmacData := params()
+	hmacData["sign"], _ = utils.SignWithKey(models.SignTypeHMACSHA256, "hmac_secret", utils.SignContent(hmacData, 0), 0)
+	result, orderErr = verifyMerchantSign(keys, "", hmacData, 0, now)
+	if assert.Nil(t, orderErr) {
+		assert.Equal(t, "hmac", result.Key.KeyID)
+	}
+
+	// keyId 参与签名并指定验签密钥
+	rsaData := params()
+	rsaData["keyId"] = "rsa"
+	rsaSign, err := utils.SignWithKey(models.SignTypeRSA2, keys[2].PlatformPrivateKey, utils.SignContent(rsaData, 0), 0)
+	assert.NoError(t, err)
+	rsaData["sign"] = rsaSign
+	result, orderErr = verifyMerchantSign(keys, "", rsaData, 0, now)
+	if assert.Nil(t, orderErr) {
+		assert.Equal(t, "rsa", result.Key.KeyID)
+	}
+	rsaData["keyId"] = "hmac"
+	_, orderErr = verifyMerchantSign(keys, "", rsaData, 0, now)
+	assert.Equal(t, ErrCodeSignInvalid, orderErr.Code)
+
+	// 已失效的密钥
+	oldData := params()
+	oldData["sign"], _ = utils.SignWithKey(models.SignTypeHMACSHA256, "old_secret", utils.SignContent(oldData, 0), 0)
+	_, orderErr = verifyMerchantSign(keys, "", oldData, 0, now)
+	assert.Equal(t, ErrCodeSignInvalid, orderErr.Code)
+}
+
+func TestSignMerchantData(t *testing.T) {
+	now := time.Now()
+	later := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+	rsaPub, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	rsaPriv, _ := x509.MarshalPKCS8PrivateKey(rsaKey)
+	keys := []models.MerchantSignKey{
+		{ID: 1, KeyID: "md5", SignType: models.SignTypeMD5, Secret: "md5_secret", Status: true},
+		{ID: 2, KeyID: "rsa", SignType: models.SignTypeRSA2, Status: true, ValidFrom: &later,
+			MerchantPublicKey:  base64.StdEncoding.EncodeToString(rsaPub),
+			PlatformPrivateKey: base64.StdEncoding.EncodeToString(rsaPriv)},
+		{ID: 3, KeyID: "next", SignType: models.SignTypeHMACSHA256, Secret: "next_secret", Status: true, ValidFrom: &future},
+		{ID: 4, KeyID: "disabled", SignType: models.SignTypeHMACSHA256, Secret: "disabled_secret", ValidFrom: &later},
+	}
+
+	// 最晚生效的有效密钥
+	current := currentMerchantSignKey(keys, "", now)
+	if assert.NotNil(t, current) {
+		assert.Equal(t, "rsa", current.KeyID)
+	}
+	assert.Equal(t, "md5", currentMerchantSignKey(keys, models.SignTypeMD5, now).KeyID)
+	assert.Equal(t, "next", currentMerchantSignKey(keys, "", future).KeyID)
+
+	// 签名结果可以使用平台公钥验证，keyId、signType 参与签名
+	data := map[string]interface{}{"order_no": "PAY001", "money": 10000, "status": 4}
+	assert.NoError(t, signMerchantData(current, data, 0))
+	assert.Equal(t, "rsa", data["keyId"])
+	assert.Equal(t, models.SignTypeRSA2, data["signType"])
+	sign := data["sign"].(string)
+	assert.True(t, utils.VerifyWithKey(models.SignTypeRSA2, current.MerchantPublicKey, utils.SignContent(data, 0), sign, 0))
+	data["money"] = 1
+	assert.False(t, utils.VerifyWithKey(models.SignTypeRSA2, current.MerchantPublicKey, utils.SignContent(data, 0), sign, 0))
+
+	// md5 密钥与系统用户密钥签名规则一致
+	md5Data := map[string]interface{}{"order_no": "PAY001", "money": 10000}
+	expected := utils.GenerateResponseSign(map[string]interface{}{"order_no": "PAY001", "money": 10000, "keyId": "md5", "signType": "md5"}, "md5_secret", 0)
+	assert.NoError(t, signMerchantData(&keys[0], md5Data, 0))
+	assert.Equal(t, expected, md5Data["sign"])
+}
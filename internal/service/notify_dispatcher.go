@@ -0,0 +1,647 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-pay-core/config"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Redis 键
+const (
+	notifyScheduleKey   = "notify:schedule"   // 待投递通知（ZSET：通知ID -> 下次投递时间毫秒）
+	notifyProcessingKey = "notify:processing" // 已领取的通知（ZSET：通知ID -> 领取超时时间毫秒）
+)
+
+const (
+	notifyPollInterval  = time.Second      // 调度轮询间隔
+	notifyClaimTimeout  = 2 * time.Minute  // 领取超时（实例崩溃后通知重新回到待投递队列）
+	notifyHostBusyDelay = time.Second      // 主机并发已满时的延后时间
+	notifyDBErrorDelay  = 30 * time.Second // 读取通知数据失败时的延后时间
+	notifyHostIdleTTL   = 10 * time.Minute // 主机状态空闲超过该时长且未熔断时清理
+)
+
+var (
+	// 通知队列深度
+	notifyQueueDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "notify_queue_depth",
+			Help: "商户通知队列深度（scheduled 待投递、due 已到期、processing 投递中、local 进程内）",
+		},
+		[]string{"state"},
+	)
+
+	// 正在投递的 worker 数
+	notifyWorkersBusy = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "notify_workers_busy",
+			Help: "正在投递商户通知的 worker 数",
+		},
+	)
+
+	// 通知投递结果
+	notifyDeliveriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "notify_deliveries_total",
+			Help: "商户通知投递次数（success、failed、circuit_open、host_busy）",
+		},
+		[]string{"result"},
+	)
+
+	// 熔断中的主机数
+	notifyOpenCircuits = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "notify_circuit_open_hosts",
+			Help: "当前被熔断的商户通知主机数",
+		},
+	)
+)
+
+// claimDueScript 领取到期的通知：从待投递队列移到投递中队列，并设置领取超时时间
+var claimDueScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, tonumber(ARGV[2]))
+for _, id in ipairs(due) do
+	redis.call('ZREM', KEYS[1], id)
+	redis.call('ZADD', KEYS[2], ARGV[3], id)
+end
+return due
+`)
+
+// reclaimScript 将领取超时的通知放回待投递队列（领取实例已崩溃或失联）
+var reclaimScript = redis.NewScript(`
+local expired = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1], 'LIMIT', 0, 100)
+for _, id in ipairs(expired) do
+	redis.call('ZREM', KEYS[2], id)
+	redis.call('ZADD', KEYS[1], 'NX', ARGV[1], id)
+end
+return #expired
+`)
+
+// scheduleScript 安排投递时间（通知正在投递时不重复安排，由投递结果决定下次投递时间）
+var scheduleScript = redis.NewScript(`
+if redis.call('ZSCORE', KEYS[2], ARGV[2]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[1], ARGV[2])
+return 1
+`)
+
+// finishScript 投递结束：移出投递中队列，需要重试时重新加入待投递队列
+var finishScript = redis.NewScript(`
+redis.call('ZREM', KEYS[2], ARGV[1])
+if ARGV[2] ~= '' then
+	redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+end
+return 1
+`)
+
+// NotifyDispatcher 商户通知投递调度器
+// 取代临时协程投递和定时扫描 dvadmin_merchant_notification 的重试任务：
+//   - 固定数量的 worker 投递通知，慢商户不会堆积协程和数据库连接
+//   - 按商户主机限制并发，连续失败时熔断该主机（熔断状态为实例内）
+//   - 下次投递时间保存在 Redis ZSET，多实例共享；Redis 不可用时退化为进程内定时器
+//   - 投递历史仍记录到 dvadmin_merchant_notification_history
+type NotifyDispatcher struct {
+	notifyService *OrderNotifyService
+	hosts         *hostGuard
+	jobs          chan int64
+	wakeup        chan struct{}
+	stopChan      chan struct{}
+	stopOnce      sync.Once
+	started       atomic.Bool
+	workers       int
+	maxRetries    int
+	busy          atomic.Int64
+	localPending  atomic.Int64 // 进程内定时器中等待投递的通知数（Redis 不可用时）
+}
+
+var (
+	globalNotifyDispatcher     *NotifyDispatcher
+	globalNotifyDispatcherInit sync.Once
+)
+
+// GetNotifyDispatcher 获取全局通知调度器（单例模式）
+func GetNotifyDispatcher() *NotifyDispatcher {
+	globalNotifyDispatcherInit.Do(func() {
+		cfg := config.NotifyConfig{}
+		if config.Cfg != nil {
+			cfg = config.Cfg.Notify
+		}
+		globalNotifyDispatcher = NewNotifyDispatcher(cfg)
+	})
+	return globalNotifyDispatcher
+}
+
+// NewNotifyDispatcher 创建通知调度器（未配置的项使用默认值）
+func NewNotifyDispatcher(cfg config.NotifyConfig) *NotifyDispatcher {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 32
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = cfg.Workers * 8
+	}
+	if cfg.HostConcurrency <= 0 {
+		cfg.HostConcurrency = 4
+	}
+	if cfg.BreakerThreshold <= 0 {
+		cfg.BreakerThreshold = 5
+	}
+	if cfg.BreakerCooldown <= 0 {
+		cfg.BreakerCooldown = time.Minute
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = 5
+	}
+
+	return &NotifyDispatcher{
+		notifyService: NewOrderNotifyService(),
+		hosts:         newHostGuard(cfg.HostConcurrency, cfg.BreakerThreshold, cfg.BreakerCooldown),
+		jobs:          make(chan int64, cfg.QueueSize),
+		wakeup:        make(chan struct{}, 1),
+		stopChan:      make(chan struct{}),
+		workers:       cfg.Workers,
+		maxRetries:    cfg.MaxRetries,
+	}
+}
+
+// Start 启动调度器（worker 池 + 调度循环），阻塞直到停止
+// 数据库中未完成通知的恢复由主节点执行（RecoverPending）
+func (d *NotifyDispatcher) Start(ctx context.Context) {
+	if !d.started.CompareAndSwap(false, true) {
+		return
+	}
+
+	for i := 0; i < d.workers; i++ {
+		go d.worker(ctx)
+	}
+
+	ticker := time.NewTicker(notifyPollInterval)
+	defer ticker.Stop()
+
+	logger.Logger.Info("商户通知调度器已启动",
+		zap.Int("workers", d.workers),
+		zap.Int("queue_size", cap(d.jobs)))
+
+	for {
+		d.poll(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-d.wakeup:
+		case <-d.stopChan:
+			logger.Logger.Info("商户通知调度器已停止")
+			return
+		case <-ctx.Done():
+			logger.Logger.Info("商户通知调度器已停止（上下文取消）")
+			return
+		}
+	}
+}
+
+// Stop 停止调度器（投递中的通知由领取超时机制重新调度）
+func (d *NotifyDispatcher) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stopChan)
+	})
+}
+
+// Schedule 安排通知在指定时间投递
+// 优先写入 Redis 调度队列；Redis 不可用时使用进程内定时器
+func (d *NotifyDispatcher) Schedule(ctx context.Context, notificationID int64, at time.Time) {
+	if database.RDB != nil {
+		err := scheduleScript.Run(ctx, database.RDB,
+			[]string{notifyScheduleKey, notifyProcessingKey},
+			at.UnixMilli(), notificationID).Err()
+		if err == nil {
+			if !at.After(time.Now()) {
+				d.notifyWakeup()
+			}
+			return
+		}
+		logger.Logger.Warn("写入通知调度队列失败，使用进程内定时器",
+			zap.Int64("notification_id", notificationID),
+			zap.Error(err))
+	}
+
+	d.scheduleLocal(notificationID, time.Until(at))
+}
+
+// NotifyDispatcherStats 通知调度器状态
+type NotifyDispatcherStats struct {
+	Scheduled    int64 `json:"scheduled"`     // Redis 待投递通知数
+	Due          int64 `json:"due"`           // 已到投递时间的通知数
+	Processing   int64 `json:"processing"`    // 已领取投递中的通知数（所有实例）
+	Local        int64 `json:"local"`         // 进程内等待投递的通知数
+	Queued       int   `json:"queued"`        // 本地待投递队列长度
+	Busy         int64 `json:"busy"`          // 正在投递的 worker 数
+	Workers      int   `json:"workers"`       // worker 总数
+	OpenCircuits int   `json:"open_circuits"` // 熔断中的主机数
+}
+
+// Stats 获取调度器状态（同时更新 Prometheus 指标）
+func (d *NotifyDispatcher) Stats(ctx context.Context) *NotifyDispatcherStats {
+	stats := &NotifyDispatcherStats{
+		Local:        d.localPending.Load(),
+		Queued:       len(d.jobs),
+		Busy:         d.busy.Load(),
+		Workers:      d.workers,
+		OpenCircuits: d.hosts.openCount(),
+	}
+
+	if database.RDB != nil {
+		pipe := database.RDB.Pipeline()
+		scheduled := pipe.ZCard(ctx, notifyScheduleKey)
+		due := pipe.ZCount(ctx, notifyScheduleKey, "-inf", strconv.FormatInt(time.Now().UnixMilli(), 10))
+		processing := pipe.ZCard(ctx, notifyProcessingKey)
+		if _, err := pipe.Exec(ctx); err == nil {
+			stats.Scheduled = scheduled.Val()
+			stats.Due = due.Val()
+			stats.Processing = processing.Val()
+		}
+	}
+
+	notifyQueueDepth.WithLabelValues("scheduled").Set(float64(stats.Scheduled))
+	notifyQueueDepth.WithLabelValues("due").Set(float64(stats.Due))
+	notifyQueueDepth.WithLabelValues("processing").Set(float64(stats.Processing))
+	notifyQueueDepth.WithLabelValues("local").Set(float64(stats.Local + int64(stats.Queued)))
+	notifyOpenCircuits.Set(float64(stats.OpenCircuits))
+	return stats
+}
+
+// poll 回收领取超时的通知，并按本地队列空余容量领取到期通知
+func (d *NotifyDispatcher) poll(ctx context.Context) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Logger.Error("商户通知调度异常", zap.Any("panic", r))
+		}
+	}()
+
+	d.hosts.prune(time.Now())
+	d.Stats(ctx)
+
+	if database.RDB == nil {
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	keys := []string{notifyScheduleKey, notifyProcessingKey}
+	if reclaimed, err := reclaimScript.Run(ctx, database.RDB, keys, now).Int(); err != nil {
+		logger.Logger.Warn("回收超时通知失败", zap.Error(err))
+	} else if reclaimed > 0 {
+		logger.Logger.Warn("通知领取超时，已重新加入调度队列", zap.Int("count", reclaimed))
+	}
+
+	free := cap(d.jobs) - len(d.jobs)
+	if free <= 0 {
+		return
+	}
+
+	deadline := time.Now().Add(notifyClaimTimeout).UnixMilli()
+	ids, err := claimDueScript.Run(ctx, database.RDB, keys, now, free, deadline).StringSlice()
+	if err != nil {
+		logger.Logger.Warn("领取到期通知失败", zap.Error(err))
+		return
+	}
+
+	for _, raw := range ids {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			database.RDB.ZRem(ctx, notifyProcessingKey, raw)
+			continue
+		}
+		select {
+		case d.jobs <- id:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// worker 从本地队列取出通知并投递
+func (d *NotifyDispatcher) worker(ctx context.Context) {
+	for {
+		select {
+		case id := <-d.jobs:
+			d.process(ctx, id)
+		case <-d.stopChan:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// process 投递一条通知，并按结果安排下次投递
+func (d *NotifyDispatcher) process(ctx context.Context, notificationID int64) {
+	d.busy.Add(1)
+	notifyWorkersBusy.Inc()
+	defer func() {
+		d.busy.Add(-1)
+		notifyWorkersBusy.Dec()
+	}()
+
+	var next *time.Time
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Logger.Error("商户通知投递异常",
+				zap.Int64("notification_id", notificationID),
+				zap.Any("panic", r))
+			retryAt := time.Now().Add(notifyDBErrorDelay)
+			next = &retryAt
+		}
+		d.finish(ctx, notificationID, next)
+	}()
+
+	next = d.deliver(ctx, notificationID)
+}
+
+// deliver 执行投递，返回下次投递时间（无需再投递时返回 nil）
+func (d *NotifyDispatcher) deliver(ctx context.Context, notificationID int64) *time.Time {
+	target, err := d.notifyService.loadNotifyTarget(notificationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		logger.Logger.Warn("读取通知数据失败，稍后重试",
+			zap.Int64("notification_id", notificationID),
+			zap.Error(err))
+		retryAt := time.Now().Add(notifyDBErrorDelay)
+		return &retryAt
+	}
+
+	status := target.notification.Status
+	if status == models.NotificationStatusSuccess || status == models.NotificationStatusMaxRetry || target.orderDetail.NotifyURL == "" {
+		return nil
+	}
+
+	host := notifyHost(target.orderDetail.NotifyURL)
+	if wait := d.hosts.breakerWait(host); wait > 0 {
+		notifyDeliveriesTotal.WithLabelValues("circuit_open").Inc()
+		retryAt := time.Now().Add(wait)
+		return &retryAt
+	}
+	if !d.hosts.acquire(host) {
+		notifyDeliveriesTotal.WithLabelValues("host_busy").Inc()
+		retryAt := time.Now().Add(notifyHostBusyDelay)
+		return &retryAt
+	}
+	success := d.notifyService.attemptNotification(ctx, target)
+	d.hosts.release(host, success)
+
+	if success {
+		notifyDeliveriesTotal.WithLabelValues("success").Inc()
+	} else {
+		notifyDeliveriesTotal.WithLabelValues("failed").Inc()
+	}
+
+	return d.notifyService.completeNotification(ctx, notificationID, target.order.ID, success, d.maxRetries)
+}
+
+// finish 结束一次投递：移出投递中队列，需要重试时重新安排
+func (d *NotifyDispatcher) finish(ctx context.Context, notificationID int64, next *time.Time) {
+	if database.RDB != nil {
+		nextScore := ""
+		if next != nil {
+			nextScore = strconv.FormatInt(next.UnixMilli(), 10)
+		}
+		err := finishScript.Run(ctx, database.RDB,
+			[]string{notifyScheduleKey, notifyProcessingKey},
+			notificationID, nextScore).Err()
+		if err == nil {
+			return
+		}
+		logger.Logger.Warn("更新通知调度队列失败",
+			zap.Int64("notification_id", notificationID),
+			zap.Error(err))
+	}
+
+	if next != nil {
+		d.scheduleLocal(notificationID, time.Until(*next))
+	}
+}
+
+// scheduleLocal 使用进程内定时器安排投递（Redis 不可用时）
+// 本地队列已满时延后 1 秒再尝试；调度器未启动时不安排，由下次启动时的恢复任务处理
+func (d *NotifyDispatcher) scheduleLocal(notificationID int64, delay time.Duration) {
+	if !d.started.Load() {
+		logger.Logger.Warn("通知调度器未启动，通知将在下次启动时恢复投递",
+			zap.Int64("notification_id", notificationID))
+		return
+	}
+
+	if delay < 0 {
+		delay = 0
+	}
+	d.localPending.Add(1)
+	time.AfterFunc(delay, func() {
+		d.localPending.Add(-1)
+		select {
+		case d.jobs <- notificationID:
+		case <-d.stopChan:
+		default:
+			d.scheduleLocal(notificationID, notifyHostBusyDelay)
+		}
+	})
+}
+
+// notifyWakeup 唤醒调度循环立即领取到期通知
+func (d *NotifyDispatcher) notifyWakeup() {
+	select {
+	case d.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// RecoverPending 将数据库中未完成的通知加入调度队列（兼容旧数据和 Redis 数据丢失）
+// 作为单实例服务在主节点当选时执行一次；已在 Redis 队列中的通知保持原投递时间
+func (d *NotifyDispatcher) RecoverPending(ctx context.Context) {
+	if database.DB == nil {
+		return
+	}
+
+	var ids []int64
+	err := database.DB.Model(&models.MerchantNotification{}).
+		Where("status IN ?", []int{
+			models.NotificationStatusPending,
+			models.NotificationStatusFailed,
+			models.NotificationStatusRetrying,
+		}).
+		Pluck("id", &ids).Error
+	if err != nil {
+		logger.Logger.Warn("查询未完成通知失败", zap.Error(err))
+		return
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	now := time.Now()
+	if database.RDB != nil {
+		members := make([]*redis.Z, 0, len(ids))
+		for _, id := range ids {
+			members = append(members, &redis.Z{Score: float64(now.UnixMilli()), Member: id})
+		}
+		// NX：不覆盖已在队列中的投递时间
+		err := database.RDB.ZAddNX(ctx, notifyScheduleKey, members...).Err()
+		if err == nil {
+			logger.Logger.Info("未完成通知已加入调度队列", zap.Int("count", len(ids)))
+			return
+		}
+		logger.Logger.Warn("未完成通知加入调度队列失败，使用进程内定时器", zap.Error(err))
+	}
+
+	for _, id := range ids {
+		d.scheduleLocal(id, 0)
+	}
+}
+
+// notifyHost 获取通知地址的主机（用于按主机限流和熔断）
+func notifyHost(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return rawURL
+	}
+	return strings.ToLower(parsed.Host)
+}
+
+// hostGuard 按商户主机限制并发并熔断
+// 连续失败达到阈值后熔断冷却时长；冷却结束后为半开状态，只放行一个探测请求，成功则恢复，失败则重新熔断
+type hostGuard struct {
+	mu        sync.Mutex
+	limit     int
+	threshold int
+	cooldown  time.Duration
+	hosts     map[string]*hostState
+}
+
+// hostState 单个主机的投递状态
+type hostState struct {
+	active    int       // 正在投递的请求数
+	failures  int       // 连续失败次数
+	openUntil time.Time // 熔断结束时间
+	lastSeen  time.Time // 最近一次占用或释放的时间
+}
+
+// newHostGuard 创建主机限流熔断器
+func newHostGuard(limit, threshold int, cooldown time.Duration) *hostGuard {
+	return &hostGuard{
+		limit:     limit,
+		threshold: threshold,
+		cooldown:  cooldown,
+		hosts:     make(map[string]*hostState),
+	}
+}
+
+// breakerWait 返回主机熔断剩余时间（未熔断时返回 0）
+func (g *hostGuard) breakerWait(host string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	state, ok := g.hosts[host]
+	if !ok {
+		return 0
+	}
+	if wait := time.Until(state.openUntil); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// acquire 占用主机并发名额（半开状态只允许一个探测请求）
+func (g *hostGuard) acquire(host string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	state, ok := g.hosts[host]
+	if !ok {
+		state = &hostState{}
+		g.hosts[host] = state
+	}
+
+	limit := g.limit
+	if state.failures >= g.threshold {
+		limit = 1
+	}
+	if state.active >= limit {
+		return false
+	}
+	state.active++
+	state.lastSeen = time.Now()
+	return true
+}
+
+// release 释放主机并发名额并记录投递结果
+func (g *hostGuard) release(host string, success bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	state, ok := g.hosts[host]
+	if !ok {
+		return
+	}
+	state.active--
+	state.lastSeen = time.Now()
+
+	if success {
+		state.failures = 0
+		state.openUntil = time.Time{}
+	} else {
+		state.failures++
+		if state.failures >= g.threshold {
+			state.openUntil = time.Now().Add(g.cooldown)
+			if logger.Logger != nil {
+				logger.Logger.Warn("商户通知主机连续失败，已熔断",
+					zap.String("host", host),
+					zap.Int("failures", state.failures),
+					zap.Duration("cooldown", g.cooldown))
+			}
+		}
+	}
+
+	// 空闲且正常的主机不保留状态，避免 map 无限增长
+	if state.active == 0 && state.failures == 0 {
+		delete(g.hosts, host)
+	}
+}
+
+// prune 清理空闲超过 notifyHostIdleTTL 且不在熔断中的主机状态
+// 连续失败未达到阈值或熔断已结束的主机不再有投递时，失败计数随状态一起清除，避免 map 随商户主机无限增长
+func (g *hostGuard) prune(now time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for host, state := range g.hosts {
+		if state.active == 0 && !state.openUntil.After(now) && now.Sub(state.lastSeen) > notifyHostIdleTTL {
+			delete(g.hosts, host)
+		}
+	}
+}
+
+// openCount 当前熔断中的主机数
+func (g *hostGuard) openCount() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	count := 0
+	for _, state := range g.hosts {
+		if state.openUntil.After(now) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-pay-core/config"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// zscore 读取通知在队列中的分数（不存在时返回 -1）
+func zscore(t *testing.T, key string, id int64) int64 {
+	t.Helper()
+	score, err := database.RDB.ZScore(context.Background(), key, strconv.FormatInt(id, 10)).Result()
+	if err != nil {
+		return -1
+	}
+	return int64(score)
+}
+
+// zmember 构造通知队列成员
+func zmember(id int64, at time.Time) *redis.Z {
+	return zmemberRaw(strconv.FormatInt(id, 10), at)
+}
+
+func zmemberRaw(member string, at time.Time) *redis.Z {
+	return &redis.Z{Score: float64(at.UnixMilli()), Member: member}
+}
+
+func setupDispatcherTest(t *testing.T, queueSize int) *NotifyDispatcher {
+	setupTestRedis(t)
+	oldLogger := logger.Logger
+	logger.Logger = zap.NewNop()
+	t.Cleanup(func() { logger.Logger = oldLogger })
+	return NewNotifyDispatcher(config.NotifyConfig{Workers: 1, QueueSize: queueSize})
+}
+
+func TestNotifyDispatcherSchedule(t *testing.T) {
+	d := setupDispatcherTest(t, 8)
+	ctx := context.Background()
+	at := time.Now().Add(time.Minute)
+
+	d.Schedule(ctx, 1, at)
+	assert.Equal(t, at.UnixMilli(), zscore(t, notifyScheduleKey, 1))
+	assert.Empty(t, d.wakeup, "future notification must not wake up the dispatcher")
+
+	// 再次安排覆盖投递时间
+	later := at.Add(time.Minute)
+	d.Schedule(ctx, 1, later)
+	assert.Equal(t, later.UnixMilli(), zscore(t, notifyScheduleKey, 1))
+
+	// 立即投递时唤醒调度循环
+	d.Schedule(ctx, 3, time.Now())
+	assert.NotEqual(t, int64(-1), zscore(t, notifyScheduleKey, 3))
+	select {
+	case <-d.wakeup:
+	default:
+		t.Fatal("Schedule did not wake up the dispatcher")
+	}
+
+	// 正在投递的通知不重复安排，由投递结果决定下次投递时间
+	database.RDB.ZAdd(ctx, notifyProcessingKey, zmember(2, time.Now().Add(notifyClaimTimeout)))
+	d.Schedule(ctx, 2, time.Now())
+	assert.Equal(t, int64(-1), zscore(t, notifyScheduleKey, 2))
+}
+
+func TestNotifyDispatcherPoll(t *testing.T) {
+	d := setupDispatcherTest(t, 2)
+	ctx := context.Background()
+	now := time.Now()
+
+	database.RDB.ZAdd(ctx, notifyScheduleKey,
+		zmember(1, now.Add(-2*time.Second)),
+		zmember(2, now.Add(-time.Second)),
+		zmember(3, now.Add(-time.Millisecond)),
+		zmember(4, now.Add(time.Minute)))
+
+	// 按本地队列空余容量领取到期通知，移到投递中队列并设置领取超时时间
+	d.poll(ctx)
+	assert.Equal(t, []int64{1, 2}, []int64{<-d.jobs, <-d.jobs})
+	for _, id := range []int64{1, 2} {
+		assert.Equal(t, int64(-1), zscore(t, notifyScheduleKey, id))
+		assert.InDelta(t, float64(time.Now().Add(notifyClaimTimeout).UnixMilli()), float64(zscore(t, notifyProcessingKey, id)), 2000)
+	}
+	assert.NotEqual(t, int64(-1), zscore(t, notifyScheduleKey, 3))
+
+	// 本地队列已满时不领取
+	d.jobs <- 100
+	d.jobs <- 101
+	d.poll(ctx)
+	assert.NotEqual(t, int64(-1), zscore(t, notifyScheduleKey, 3))
+	<-d.jobs
+	<-d.jobs
+
+	d.poll(ctx)
+	assert.Equal(t, int64(3), <-d.jobs)
+	assert.NotEqual(t, int64(-1), zscore(t, notifyScheduleKey, 4), "future notification must stay scheduled")
+
+	// 领取超时（实例崩溃）的通知回到待投递队列
+	database.RDB.ZAdd(ctx, notifyProcessingKey, zmember(1, now.Add(-time.Second)))
+	d.poll(ctx)
+	assert.Equal(t, int64(1), <-d.jobs)
+	assert.NotEqual(t, int64(-1), zscore(t, notifyProcessingKey, 1))
+
+	// 无效的通知ID直接移除
+	database.RDB.ZAdd(ctx, notifyScheduleKey, zmemberRaw("bad", now.Add(-time.Second)))
+	d.poll(ctx)
+	_, err := database.RDB.ZScore(ctx, notifyProcessingKey, "bad").Result()
+	assert.ErrorIs(t, err, redis.Nil)
+	_, err = database.RDB.ZScore(ctx, notifyScheduleKey, "bad").Result()
+	assert.ErrorIs(t, err, redis.Nil)
+	assert.Empty(t, d.jobs)
+}
+
+func TestNotifyDispatcherReclaimKeepsSchedule(t *testing.T) {
+	setupDispatcherTest(t, 8)
+	ctx := context.Background()
+	now := time.Now()
+	keys := []string{notifyScheduleKey, notifyProcessingKey}
+
+	// 已在待投递队列中的通知保持原投递时间（NX）
+	scheduled := now.Add(time.Minute)
+	database.RDB.ZAdd(ctx, notifyScheduleKey, zmember(1, scheduled))
+	database.RDB.ZAdd(ctx, notifyProcessingKey, zmember(1, now.Add(-time.Second)), zmember(2, now.Add(time.Minute)))
+
+	reclaimed, err := reclaimScript.Run(ctx, database.RDB, keys, now.UnixMilli()).Int()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reclaimed)
+	assert.Equal(t, scheduled.UnixMilli(), zscore(t, notifyScheduleKey, 1))
+	assert.Equal(t, int64(-1), zscore(t, notifyProcessingKey, 1))
+	assert.NotEqual(t, int64(-1), zscore(t, notifyProcessingKey, 2))
+}
+
+func TestNotifyDispatcherFinish(t *testing.T) {
+	d := setupDispatcherTest(t, 8)
+	ctx := context.Background()
+	deadline := time.Now().Add(notifyClaimTimeout)
+
+	// 需要重试：移出投递中队列并按下次投递时间重新加入
+	database.RDB.ZAdd(ctx, notifyProcessingKey, zmember(1, deadline))
+	next := time.Now().Add(30 * time.Second)
+	d.finish(ctx, 1, &next)
+	assert.Equal(t, int64(-1), zscore(t, notifyProcessingKey, 1))
+	assert.Equal(t, next.UnixMilli(), zscore(t, notifyScheduleKey, 1))
+
+	// 投递完成：只移出投递中队列
+	database.RDB.ZAdd(ctx, notifyProcessingKey, zmember(2, deadline))
+	d.finish(ctx, 2, nil)
+	assert.Equal(t, int64(-1), zscore(t, notifyProcessingKey, 2))
+	assert.Equal(t, int64(-1), zscore(t, notifyScheduleKey, 2))
+}
+
+func TestHostGuardLimit(t *testing.T) {
+	g := newHostGuard(2, 3, time.Minute)
+
+	assert.True(t, g.acquire("a.example.com"))
+	assert.True(t, g.acquire("a.example.com"))
+	assert.False(t, g.acquire("a.example.com"), "per-host limit")
+	assert.True(t, g.acquire("b.example.com"), "other hosts are independent")
+
+	g.release("a.example.com", true)
+	assert.True(t, g.acquire("a.example.com"))
+
+	// 空闲且正常的主机不保留状态
+	g.release("a.example.com", true)
+	g.release("a.example.com", true)
+	g.release("b.example.com", true)
+	assert.Empty(t, g.hosts)
+}
+
+func TestHostGuardBreaker(t *testing.T) {
+	g := newHostGuard(4, 3, time.Minute)
+	const host = "merchant.example.com"
+
+	// 连续失败未达到阈值：不熔断
+	for i := 0; i < 2; i++ {
+		assert.True(t, g.acquire(host))
+		g.release(host, false)
+	}
+	assert.Zero(t, g.breakerWait(host))
+	assert.Equal(t, 0, g.openCount())
+
+	// 达到阈值：熔断冷却时长
+	assert.True(t, g.acquire(host))
+	g.release(host, false)
+	wait := g.breakerWait(host)
+	assert.True(t, wait > 50*time.Second && wait <= time.Minute, "wait = %v", wait)
+	assert.Equal(t, 1, g.openCount())
+
+	// 冷却结束：半开，只放行一个探测请求
+	g.hosts[host].openUntil = time.Now().Add(-time.Second)
+	assert.Zero(t, g.breakerWait(host))
+	assert.Equal(t, 0, g.openCount())
+	assert.True(t, g.acquire(host))
+	assert.False(t, g.acquire(host))
+
+	// 探测失败：重新熔断
+	g.release(host, false)
+	assert.True(t, g.breakerWait(host) > 0)
+
+	// 探测成功：恢复并清除状态
+	g.hosts[host].openUntil = time.Now().Add(-time.Second)
+	assert.True(t, g.acquire(host))
+	g.release(host, true)
+	assert.Zero(t, g.breakerWait(host))
+	assert.NotContains(t, g.hosts, host)
+	for i := 0; i < 4; i++ {
+		assert.True(t, g.acquire(host))
+	}
+}
+
+func TestHostGuardPrune(t *testing.T) {
+	g := newHostGuard(4, 3, time.Minute)
+	now := time.Now()
+
+	g.hosts["idle-failed"] = &hostState{failures: 1, lastSeen: now.Add(-notifyHostIdleTTL - time.Second)}
+	g.hosts["idle-closed"] = &hostState{failures: 3, openUntil: now.Add(-time.Second), lastSeen: now.Add(-notifyHostIdleTTL - time.Second)}
+	g.hosts["recent"] = &hostState{failures: 1, lastSeen: now.Add(-time.Second)}
+	g.hosts["open"] = &hostState{failures: 3, openUntil: now.Add(time.Minute), lastSeen: now.Add(-notifyHostIdleTTL - time.Second)}
+	g.hosts["active"] = &hostState{active: 1, failures: 1, lastSeen: now.Add(-notifyHostIdleTTL - time.Second)}
+
+	g.prune(now)
+	assert.NotContains(t, g.hosts, "idle-failed")
+	assert.NotContains(t, g.hosts, "idle-closed")
+	assert.Contains(t, g.hosts, "recent")
+	assert.Contains(t, g.hosts, "open")
+	assert.Contains(t, g.hosts, "active")
+}
+
+func TestNotifyHost(t *testing.T) {
+	assert.Equal(t, "merchant.example.com:8080", notifyHost("https://Merchant.Example.com:8080/notify?a=1"))
+	assert.Equal(t, "not a url", notifyHost("not a url"))
+}
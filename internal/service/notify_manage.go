@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// NotificationManageRequest 商户重新通知/查询通知记录请求
+type NotificationManageRequest struct {
+	OrderNo     string                 `json:"payOrderId"`               // 系统订单号（来自路径参数）
+	MerchantID  int                    `json:"mchId" binding:"required"` // 商户ID
+	Ver         int64                  `json:"ver"`                      // 期望的通知版本号（可选，用于乐观锁校验）
+	KeyID       string                 `json:"keyId"`                    // 签名密钥编号（可选）
+	Timestamp   int64                  `json:"timestamp"`                // 请求时间戳（防重放，可选）
+	Nonce       string                 `json:"nonce"`                    // 随机串（防重放，可选）
+	Sign        string                 `json:"sign" binding:"required"`  // 签名
+	RawSignData map[string]interface{} `json:"-"`                        // 原始签名数据（内部使用）
+}
+
+// NotificationInfo 通知任务状态
+type NotificationInfo struct {
+	PayOrderID       string     `json:"payOrderId"`                 // 系统订单号
+	MchOrderNo       string     `json:"mchOrderNo"`                 // 商户订单号
+	NotificationID   int64      `json:"notificationId"`             // 通知任务ID
+	Status           int        `json:"status"`                     // 通知状态
+	Ver              int64      `json:"ver"`                        // 版本号
+	RenotifyDatetime *time.Time `json:"renotifyDatetime,omitempty"` // 最近一次手动重新通知时间
+	UpdateDatetime   *time.Time `json:"updateDatetime,omitempty"`   // 修改时间
+	NotifyURL        string     `json:"notifyUrl"`                  // 通知地址
+}
+
+// NotificationAttempt 通知投递记录
+type NotificationAttempt struct {
+	ID             int64      `json:"id"`
+	URL            string     `json:"url"`
+	RequestMethod  string     `json:"requestMethod"`
+	RequestBody    string     `json:"requestBody"`
+	ResponseCode   int        `json:"responseCode"`
+	ResponseBody   string     `json:"responseBody"`
+	CreateDatetime *time.Time `json:"createDatetime,omitempty"`
+}
+
+// NotificationHistoryResponse 通知记录查询响应
+type NotificationHistoryResponse struct {
+	Notification *NotificationInfo     `json:"notification,omitempty"` // 通知任务（订单尚未产生通知时为空）
+	Attempts     []NotificationAttempt `json:"attempts"`               // 投递记录（按时间倒序）
+}
+
+// notificationHistoryLimit 通知记录查询的最大条数
+const notificationHistoryLimit = 100
+
+// NotificationManageService 商户通知管理服务（重新通知、查询通知记录）
+// 商户接口需验证商户签名，且只能操作本商户订单；管理接口由 AdminAuth 认证，不限商户
+type NotificationManageService struct {
+	notifyService *OrderNotifyService
+	cacheService  *CacheService
+}
+
+// NewNotificationManageService 创建商户通知管理服务
+func NewNotificationManageService() *NotificationManageService {
+	notifyService := NewOrderNotifyService()
+	return &NotificationManageService{
+		notifyService: notifyService,
+		cacheService:  notifyService.orderService.cacheService,
+	}
+}
+
+// VerifyMerchant 验证商户状态、请求签名和防重放参数
+func (s *NotificationManageService) VerifyMerchant(ctx context.Context, req *NotificationManageRequest) *OrderError {
+	merchant, user, err := s.cacheService.GetMerchantWithUser(ctx, int64(req.MerchantID))
+	if err != nil {
+		return ErrMerchantNotFound
+	}
+	if user == nil || !user.Status {
+		return ErrMerchantDisabled
+	}
+	return validateMerchantSign(ctx, s.cacheService, merchant, user.Key, req.RawSignData)
+}
+
+// Renotify 立即重新通知商户（包括已达到最大重试次数和已通知成功的订单）
+// merchantID 为 0 时不限制商户（管理接口）；expectedVer 不为 0 时要求与当前通知版本号一致
+// 通知状态重置为未通知并记录重新通知时间（重试次数重新计数），版本号冲突时返回 ErrNotifyConflict
+func (s *NotificationManageService) Renotify(ctx context.Context, orderNo string, merchantID int64, expectedVer int64) (*NotificationInfo, *OrderError) {
+	order, orderDetail, orderErr := s.findOrder(orderNo, merchantID)
+	if orderErr != nil {
+		return nil, orderErr
+	}
+
+	// 只有支付成功的订单才会通知商户
+	if (order.OrderStatus != models.OrderStatusPaid && order.OrderStatus != models.OrderStatusPaidNoNotify) || orderDetail.NotifyURL == "" {
+		return nil, ErrNotifyStatusInvalid
+	}
+
+	notification, err := s.notifyService.createOrGetNotification(order.ID)
+	if err != nil {
+		logger.Logger.Warn("创建通知任务失败",
+			zap.String("order_id", order.ID),
+			zap.Error(err))
+		return nil, ErrSystemBusy
+	}
+	if expectedVer != 0 && expectedVer != notification.Ver {
+		return nil, ErrNotifyConflict
+	}
+
+	// 重置通知状态（使用乐观锁，与 updateNotificationStatus 一致）
+	now := time.Now()
+	result := database.DB.Model(&models.MerchantNotification{}).
+		Where("id = ? AND ver = ?", notification.ID, notification.Ver).
+		Updates(map[string]interface{}{
+			"status":            models.NotificationStatusPending,
+			"renotify_datetime": &now,
+			"update_datetime":   &now,
+			"ver":               gorm.Expr("ver + ?", 1),
+		})
+	if result.Error != nil {
+		logger.Logger.Warn("重置通知状态失败",
+			zap.Int64("notification_id", notification.ID),
+			zap.Error(result.Error))
+		return nil, ErrSystemBusy
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotifyConflict
+	}
+
+	GetNotifyDispatcher().Schedule(ctx, notification.ID, now)
+
+	logger.Logger.Info("已安排重新通知商户",
+		zap.String("order_no", order.OrderNo),
+		zap.Int64("notification_id", notification.ID),
+		zap.Int("previous_status", notification.Status),
+		zap.Int64("merchant_id", merchantID))
+
+	notification.Status = models.NotificationStatusPending
+	notification.Ver++
+	notification.RenotifyDatetime = &now
+	notification.UpdateDatetime = &now
+	return buildNotificationInfo(order, orderDetail, notification), nil
+}
+
+// ListHistory 查询订单的通知任务和投递记录（最近 100 条）
+// merchantID 为 0 时不限制商户（管理接口）
+func (s *NotificationManageService) ListHistory(ctx context.Context, orderNo string, merchantID int64) (*NotificationHistoryResponse, *OrderError) {
+	order, orderDetail, orderErr := s.findOrder(orderNo, merchantID)
+	if orderErr != nil {
+		return nil, orderErr
+	}
+
+	resp := &NotificationHistoryResponse{Attempts: []NotificationAttempt{}}
+
+	var notification models.MerchantNotification
+	if err := database.DB.Where("order_id = ?", order.ID).First(&notification).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return resp, nil
+		}
+		return nil, ErrSystemBusy
+	}
+	resp.Notification = buildNotificationInfo(order, orderDetail, &notification)
+
+	var histories []models.MerchantNotificationHistory
+	if err := database.DB.Where("notification_id = ?", notification.ID).
+		Order("id DESC").
+		Limit(notificationHistoryLimit).
+		Find(&histories).Error; err != nil {
+		return nil, ErrSystemBusy
+	}
+
+	for _, history := range histories {
+		resp.Attempts = append(resp.Attempts, NotificationAttempt{
+			ID:             history.ID,
+			URL:            history.URL,
+			RequestMethod:  history.RequestMethod,
+			RequestBody:    history.RequestBody,
+			ResponseCode:   history.ResponseCode,
+			ResponseBody:   history.JSONResult,
+			CreateDatetime: history.CreateDatetime,
+		})
+	}
+	return resp, nil
+}
+
+// findOrder 查询订单和订单详情（merchantID 不为 0 时只能查询本商户订单）
+func (s *NotificationManageService) findOrder(orderNo string, merchantID int64) (*models.Order, *models.OrderDetail, *OrderError) {
+	query := database.DB.Where("order_no = ?", orderNo)
+	if merchantID != 0 {
+		query = query.Where("merchant_id = ?", merchantID)
+	}
+
+	var order models.Order
+	if err := query.First(&order).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, ErrNotifyOrderNotFound
+		}
+		return nil, nil, ErrSystemBusy
+	}
+
+	var orderDetail models.OrderDetail
+	if err := database.DB.Where("order_id = ?", order.ID).First(&orderDetail).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, ErrNotifyOrderNotFound
+		}
+		return nil, nil, ErrSystemBusy
+	}
+
+	return &order, &orderDetail, nil
+}
+
+// buildNotificationInfo 构建通知任务状态
+func buildNotificationInfo(order *models.Order, orderDetail *models.OrderDetail, notification *models.MerchantNotification) *NotificationInfo {
+	return &NotificationInfo{
+		PayOrderID:       order.OrderNo,
+		MchOrderNo:       order.OutOrderNo,
+		NotificationID:   notification.ID,
+		Status:           notification.Status,
+		Ver:              notification.Ver,
+		RenotifyDatetime: notification.RenotifyDatetime,
+		UpdateDatetime:   notification.UpdateDatetime,
+		NotifyURL:        orderDetail.NotifyURL,
+	}
+}
@@ -9,12 +9,12 @@ import (
 	"time"
 
 	"github.com/go-redis/redis/v8"
-	"github.com/golang-pay-core/config"
 	"github.com/golang-pay-core/internal/database"
 	"github.com/golang-pay-core/internal/logger"
 	"github.com/golang-pay-core/internal/models"
 	"github.com/golang-pay-core/internal/mq"
 	"github.com/golang-pay-core/internal/order"
+	"github.com/golang-pay-core/internal/outbox"
 	"github.com/golang-pay-core/internal/plugin"
 	"github.com/golang-pay-core/internal/utils"
 	"go.uber.org/zap"
@@ -41,6 +41,10 @@ type CreateOrderRequest struct {
 	Extra         string                 `json:"extra"`                           // 额外参数
 	Compatible    int                    `json:"compatible"`                      // 兼容模式 0/1
 	Test          bool                   `json:"test"`                            // 测试模式
+	ClientIP      string                 `json:"clientIp"`                        // 用户IP（可选，传入时检查通道访问策略）
+	KeyID         string                 `json:"keyId"`                           // 商户签名密钥编号（可选，配置了签名密钥时指定验签使用的密钥）
+	Timestamp     int64                  `json:"timestamp"`                       // 请求时间戳（防重放，开启防重放的商户必填）
+	Nonce         string                 `json:"nonce"`                           // 随机串（防重放，开启防重放的商户必填）
 	Sign          string                 `json:"sign" binding:"required"`         // 签名
 	RawSignData   map[string]interface{} `json:"-"`                               // 原始签名数据（内部使用）
 	SignRaw       string                 `json:"-"`                               // 签名原始数据（JSON字符串，用于日志）
@@ -60,6 +64,11 @@ type CreateOrderResponse struct {
 	MchOrderNo string `json:"mchOrderNo,omitempty"`
 	PayOrderID string `json:"payOrderId,omitempty"`
 	PayURL2    string `json:"payUrl,omitempty"`
+
+	// 商户配置了签名密钥时，使用验签的密钥签名响应
+	KeyID    string `json:"keyId,omitempty"`
+	SignType string `json:"signType,omitempty"`
+	Sign     string `json:"sign,omitempty"`
 }
 
 // OrderCreateContext 订单创建上下文
@@ -73,6 +82,7 @@ type OrderCreateContext struct {
 	Extra       string
 	Compatible  int
 	Test        bool
+	ClientIP    string // 用户IP（可选）
 
 	// 验证后填充的字段
 	MerchantID     int64
@@ -91,6 +101,9 @@ type OrderCreateContext struct {
 	SignRaw        string // 签名原始数据
 	Sign           string // 签名数据
 
+	// 验签使用的商户签名密钥（使用系统用户密钥时为空），下单响应使用同一密钥签名
+	MerchantSignKey *models.MerchantSignKey
+
 	// 关联对象
 	Merchant   *models.Merchant
 	Tenant     *models.Tenant
@@ -103,13 +116,18 @@ type OrderCreateContext struct {
 	TenantUser *SystemUser
 
 	// 订单信息
-	OrderNo       string
-	OrderID       string
-	OrderDetailID int64 // 订单详情ID（创建后保存，避免重复查询）
+	OrderNo        string
+	OrderID        string
+	OrderDetailID  int64     // 订单详情ID（创建后保存，避免重复查询）
+	CreateDatetime time.Time // 订单创建时间
+	TimeoutSeconds int       // 订单超时时间（秒，从插件获取，0 表示未获取到）
 
 	// 手续费信息
 	MerchantTax int // 商户手续费（分）
 
+	// 风控决策（下单传入客户端 IP 时填充，写入订单详情）
+	Risk *RiskResult
+
 	// 请求信息（用于日志记录）
 	RequestMethod string // 请求方法（GET/POST）
 	RequestBody   string // 请求体（JSON字符串）
@@ -143,18 +161,19 @@ type OrderService struct {
 	pluginService  *PluginService
 	pluginManager  *plugin.Manager
 	balanceService *BalanceService
+	riskService    *RiskService
 	redis          *redis.Client
-	mqClient       *mq.RocketMQClient // RocketMQ 客户端（可选，如果未启用则使用同步处理）
+	bus            mq.Bus // 消息总线（可选，未启用时使用同步处理）
 }
 
 // NewOrderService 创建订单服务
 func NewOrderService() *OrderService {
 	pluginMgr := plugin.NewManager(database.RDB)
 
-	// 使用全局 RocketMQ 客户端（单例模式，避免重复创建）
-	mqClient := mq.GetGlobalMQClient()
-	if !mqClient.IsEnabled() {
-		mqClient = nil
+	// 使用全局消息总线（单例模式，避免重复创建）
+	bus := mq.GetBus()
+	if !bus.IsEnabled() {
+		bus = nil
 	}
 	pluginSvc := NewPluginService()
 
@@ -166,8 +185,9 @@ func NewOrderService() *OrderService {
 		pluginService:  pluginSvc,
 		pluginManager:  pluginMgr,
 		balanceService: NewBalanceService(),
+		riskService:    NewRiskService(),
 		redis:          database.RDB,
-		mqClient:       mqClient,
+		bus:            bus,
 	}
 }
 
@@ -222,6 +242,7 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *CreateOrderRequest)
 		Extra:         req.Extra,
 		Compatible:    req.Compatible,
 		Test:          req.Test,
+		ClientIP:      req.ClientIP,
 		SignRaw:       req.SignRaw,
 		RequestMethod: req.RequestMethod,
 		RequestBody:   req.RequestBody,
@@ -240,12 +261,24 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *CreateOrderRequest)
 	// 将验证后的签名信息回传到 req，供 Controller 层记录日志使用
 	req.SignRaw = orderCtx.SignRaw
 	req.Sign = orderCtx.Sign
+	// 防重放（易支付协议没有 nonce，兼容模式不检查）
+	if orderCtx.Compatible == 0 {
+		if err := checkMerchantReplay(ctx, orderCtx.Merchant, req.RawSignData); err != nil {
+			return nil, err
+		}
+	}
 	if err := s.validateOutOrderNo(ctx, orderCtx); err != nil {
 		return nil, err
 	}
 	if err := s.validateChannel(ctx, orderCtx, int64(req.ChannelID)); err != nil {
 		return nil, err
 	}
+	if err := s.validateChannelAccess(orderCtx, req.ClientIP); err != nil {
+		return nil, err
+	}
+	if err := s.validateRisk(ctx, orderCtx, req.ClientIP); err != nil {
+		return nil, err
+	}
 	if err := s.validatePlugin(ctx, orderCtx); err != nil {
 		return nil, err
 	}
@@ -299,7 +332,12 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *CreateOrderRequest)
 		return nil, err
 	}
 
-	// 6. 创建订单和详情（此时已经有产品ID、核销ID等信息）
+	// 6. 获取订单超时时间
+	// 启用消息总线时，超时延迟消息与订单在同一事务中写入发件箱；否则写入 Redis 超时定时队列
+	// 参考 Python: get_plugin_out_time(ctx.plugin.id) - 从插件配置获取超时时间
+	orderCtx.TimeoutSeconds = s.getOrderTimeoutSeconds(ctx, orderCtx)
+
+	// 6.1. 创建订单和详情（此时已经有产品ID、核销ID等信息）
 	// 在事务中会再次检查余额，确保一致性
 	orderDetailID, err := s.createOrderAndDetail(ctx, orderCtx)
 	if err != nil {
@@ -308,77 +346,14 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *CreateOrderRequest)
 	// 保存订单详情ID到上下文，避免后续重复查询
 	orderCtx.OrderDetailID = orderDetailID
 
-	// 6.1. 发送订单超时延迟消息（如果启用 RocketMQ）
-	// 使用延迟消息确保订单在超时后自动更新状态，比定时扫描更可靠
-	// 参考 Python: get_plugin_out_time(ctx.plugin.id) - 从插件配置获取超时时间
-	logger.Logger.Debug("检查是否需要发送订单超时延迟消息",
-		zap.String("order_no", orderCtx.OrderNo),
-		zap.Bool("mq_client_nil", s.mqClient == nil))
-
-	if s.mqClient == nil {
-		logger.Logger.Warn("RocketMQ 客户端未初始化，无法发送订单超时延迟消息",
-			zap.String("order_no", orderCtx.OrderNo))
-	} else if !s.mqClient.IsEnabled() {
-		logger.Logger.Warn("RocketMQ 未启用，无法发送订单超时延迟消息",
-			zap.String("order_no", orderCtx.OrderNo))
-	} else {
-		// 获取插件实例以获取超时时间
-		pluginInstance, err := s.pluginManager.GetPluginByCtx(ctx, orderCtx)
-		if err != nil {
-			logger.Logger.Warn("获取插件实例失败，无法发送超时延迟消息",
+	// 6.2. 消息总线不可用时加入超时定时队列（失败时由数据库对账扫描兜底）
+	if s.bus == nil && orderCtx.TimeoutSeconds > 0 {
+		expireAt := orderCtx.CreateDatetime.Add(time.Duration(orderCtx.TimeoutSeconds) * time.Second)
+		if err := ScheduleOrderTimeout(ctx, orderCtx.OrderID, expireAt); err != nil {
+			logger.Logger.Warn("加入订单超时定时队列失败，将依赖对账扫描",
 				zap.String("order_no", orderCtx.OrderNo),
-				zap.Int64("plugin_id", orderCtx.PluginID),
-				zap.String("plugin_type", orderCtx.PluginType),
+				zap.String("order_id", orderCtx.OrderID),
 				zap.Error(err))
-		} else {
-			// 如果插件实现了 PluginCapabilities 接口，使用插件的 GetTimeout 方法
-			capabilities, ok := pluginInstance.(plugin.PluginCapabilities)
-			if !ok {
-				logger.Logger.Warn("插件未实现 PluginCapabilities 接口，无法获取超时时间",
-					zap.String("order_no", orderCtx.OrderNo),
-					zap.String("plugin_type", orderCtx.PluginType),
-					zap.String("plugin_instance_type", fmt.Sprintf("%T", pluginInstance)))
-			} else {
-				timeoutSeconds := capabilities.GetTimeout(ctx, orderCtx.PluginID)
-				logger.Logger.Debug("获取到插件超时时间",
-					zap.String("order_no", orderCtx.OrderNo),
-					zap.Int64("plugin_id", orderCtx.PluginID),
-					zap.Int("timeout_seconds", timeoutSeconds))
-
-				timeoutMsg := &mq.OrderTimeoutMessage{
-					OrderID:        orderCtx.OrderID,
-					OrderNo:        orderCtx.OrderNo,
-					CreateDatetime: time.Now().Unix(),
-					TimeoutSeconds: timeoutSeconds,
-				}
-
-				// 发送延迟消息，延迟时间就是订单超时时间
-				delayDuration := time.Duration(timeoutSeconds) * time.Second
-				logger.Logger.Debug("准备发送订单超时延迟消息",
-					zap.String("order_no", orderCtx.OrderNo),
-					zap.String("order_id", orderCtx.OrderID),
-					zap.Int("timeout_seconds", timeoutSeconds),
-					zap.Duration("delay_duration", delayDuration))
-
-				if err := s.mqClient.SendDelayMessage(ctx, "order-timeout", "timeout", timeoutMsg, delayDuration); err != nil {
-					logger.Logger.Warn("发送订单超时延迟消息失败，将依赖定时扫描",
-						zap.String("order_no", orderCtx.OrderNo),
-						zap.String("order_id", orderCtx.OrderID),
-						zap.Int("timeout_seconds", timeoutSeconds),
-						zap.Int64("plugin_id", orderCtx.PluginID),
-						zap.Error(err))
-					// 失败不影响订单创建，定时扫描会作为兜底
-				} else {
-					logger.Logger.Info("订单超时延迟消息已发送",
-						zap.String("order_no", orderCtx.OrderNo),
-						zap.String("order_id", orderCtx.OrderID),
-						zap.Int("timeout_seconds", timeoutSeconds),
-						zap.Int64("plugin_id", orderCtx.PluginID),
-						zap.String("plugin_type", orderCtx.PluginType),
-						zap.Duration("delay_duration", delayDuration),
-						zap.Time("expected_expire_time", time.Now().Add(delayDuration)))
-				}
-			}
 		}
 	}
 
@@ -452,59 +427,8 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *CreateOrderRequest)
 	// s.updateOrderLogResponse(ctx, orderCtx, response)
 
 	// 11. 异步调用 callback_submit（下单回调）
-	// 如果启用了 RocketMQ，使用消息队列；否则使用 goroutine
-	if s.mqClient != nil && s.mqClient.IsEnabled() {
-		// 使用 RocketMQ 发送消息（延迟 500 微秒，确保订单数据已完全写入）
-		msg := &mq.CallbackSubmitMessage{
-			OrderNo:        orderCtx.OrderNo,
-			OutOrderNo:     orderCtx.OutOrderNo,
-			PluginID:       orderCtx.PluginID,
-			Tax:            orderCtx.Tax,
-			PluginType:     orderCtx.PluginType,
-			Money:          orderCtx.Money,
-			DomainID:       orderCtx.DomainID,
-			NotifyMoney:    orderCtx.NotifyMoney,
-			OrderID:        orderCtx.OrderID,
-			ProductID:      orderCtx.ProductID,
-			CookieID:       orderCtx.CookieID,
-			ChannelID:      orderCtx.ChannelID,
-			MerchantID:     orderCtx.MerchantID,
-			WriteoffID:     orderCtx.WriteoffID,
-			TenantID:       orderCtx.TenantID,
-			CreateDatetime: time.Now().Format("2006-01-02 15:04:05"),
-			NotifyURL:      orderCtx.NotifyURL,
-			PluginUpstream: orderCtx.PluginUpstream,
-		}
-
-		// 查询订单创建时间（如果订单已创建）
-		if orderCtx.OrderID != "" {
-			var order models.Order
-			if err := database.DB.Select("create_datetime").Where("id = ?", orderCtx.OrderID).First(&order).Error; err == nil {
-				if order.CreateDatetime != nil {
-					msg.CreateDatetime = order.CreateDatetime.Format("2006-01-02 15:04:05")
-				}
-			}
-		}
-
-		// 发送延迟消息（500 微秒延迟）
-		if err := s.mqClient.SendDelayMessage(ctx, "callback-submit", "submit", msg, 500*time.Microsecond); err != nil {
-			logger.Logger.Error("发送 callback_submit 消息失败，降级为同步处理",
-				zap.String("order_no", orderCtx.OrderNo),
-				zap.String("plugin_type", orderCtx.PluginType),
-				zap.Error(err))
-			// 降级为同步处理
-			go func() {
-				time.Sleep(500 * time.Microsecond)
-				if err := s.callbackPluginSubmit(context.Background(), orderCtx); err != nil {
-					logger.Logger.Error("插件 callback_submit 触发错误",
-						zap.String("order_no", orderCtx.OrderNo),
-						zap.String("plugin_type", orderCtx.PluginType),
-						zap.Error(err))
-				}
-			}()
-		}
-	} else {
-		// 未启用 RocketMQ，使用 goroutine（原有逻辑）
+	// 如果启用了消息总线，事件已在创建订单的事务中写入发件箱，由中继投递；否则使用 goroutine
+	if s.bus == nil {
 		go func() {
 			// 延迟执行，确保订单数据已完全写入
 			time.Sleep(500 * time.Microsecond)
@@ -565,35 +489,22 @@ func (s *OrderService) validateTenant(ctx context.Context, orderCtx *OrderCreate
 	return nil
 }
 
-// validateSign 验证签名
+// validateSign 验证签名（按商户签名密钥选择签名算法，见 VerifyMerchantSign）
 func (s *OrderService) validateSign(ctx context.Context, orderCtx *OrderCreateContext, rawSignData map[string]interface{}) *OrderError {
-	if orderCtx.SignKey == "" {
-		return ErrSignInvalid
-	}
-
-	sign, ok := rawSignData["sign"].(string)
-	if !ok || sign == "" {
-		return ErrSignInvalid
-	}
-
-	signRaw, actualSign := utils.GetSign(rawSignData, orderCtx.SignKey, nil, nil, orderCtx.Compatible)
-	if sign != actualSign {
-		// 如果是开发、测试模式则输出正确签名
-		if config.Cfg.App.Mode == "debug" || config.Cfg.App.Mode == "test" {
-			fmt.Println("sign", sign)
-			fmt.Println("actualSign", actualSign)
-		}
-		return ErrSignInvalid
+	result, orderErr := s.cacheService.VerifyMerchantSign(ctx, orderCtx.MerchantID, orderCtx.SignKey, rawSignData, orderCtx.Compatible)
+	if orderErr != nil {
+		return orderErr
 	}
 
 	// 保存签名原始数据和签名
-	// 注意：signRaw 是 GetSign 返回的用于签名的原始字符串（如 "key=value&key=value&key=xxx"）
+	// 注意：signRaw 是用于签名的原始字符串（如 "key=value&key=value&key=xxx"）
 	// 但根据 Python 代码和数据库表结构，sign_raw 字段应该存储的是原始请求数据的 JSON 字符串
 	// 这里保存 signRaw（用于签名的原始字符串）到 orderCtx.SignRaw
 	// 如果 Controller 层已经设置了 SignRaw（JSON 格式），这里会覆盖它
 	// 为了保持与 Python 代码一致，这里使用 signRaw（用于签名的原始字符串）
-	orderCtx.SignRaw = signRaw
-	orderCtx.Sign = actualSign
+	orderCtx.SignRaw = result.SignRaw
+	orderCtx.Sign = result.Sign
+	orderCtx.MerchantSignKey = result.Key
 
 	return nil
 }
@@ -924,10 +835,35 @@ func (s *OrderService) checkChannelTime(channel *models.PayChannel) *OrderError
 	return nil
 }
 
-// checkChannelAmount 检查渠道金额限制（已废弃，逻辑已移至 validateChannel）
-// 注意：此方法保留是为了向后兼容，实际逻辑已在 validateChannel 中实现
+// checkChannelAmount 检查渠道金额限制（固定金额 + 单笔范围）
+// 注意：validateChannel 中在浮动加价前后分别检查，此方法用于不涉及浮动加价的一次性检查
 func (s *OrderService) checkChannelAmount(channel *models.PayChannel, orderCtx *OrderCreateContext) *OrderError {
-	// 此方法已不再使用，逻辑已移至 validateChannel
+	// 检查固定金额模式
+	if channel.Settled && channel.Moneys != "" {
+		var moneys []int
+		if err := json.Unmarshal([]byte(channel.Moneys), &moneys); err == nil {
+			valid := false
+			for _, m := range moneys {
+				if m == orderCtx.Money {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				return NewOrderError(ErrCodeAmountOutOfRange,
+					fmt.Sprintf("金额%d不在范围内,可用:%v", orderCtx.Money, moneys))
+			}
+		}
+	}
+
+	// 检查单笔金额大小
+	if channel.MinMoney != 0 || channel.MaxMoney != 0 {
+		if orderCtx.Money < channel.MinMoney || orderCtx.Money > channel.MaxMoney {
+			return NewOrderError(ErrCodeAmountOutOfRange,
+				fmt.Sprintf("金额%d不在范围[%d,%d]内", orderCtx.Money, channel.MinMoney, channel.MaxMoney))
+		}
+	}
+
 	return nil
 }
 
@@ -1283,6 +1219,10 @@ func (s *OrderService) createOrderAndDetail(ctx context.Context, orderCtx *Order
 		CookieID:       orderCtx.CookieID,
 		MerchantTax:    orderCtx.MerchantTax, // 保存商户手续费
 	}
+	if orderCtx.Risk != nil {
+		orderDetail.RiskDecision = orderCtx.Risk.Decision
+		orderDetail.RiskReason = truncateRiskReason(orderCtx.Risk.Reason)
+	}
 
 	if err := tx.Create(orderDetail).Error; err != nil {
 		tx.Rollback()
@@ -1291,6 +1231,43 @@ func (s *OrderService) createOrderAndDetail(ctx context.Context, orderCtx *Order
 
 	// 注意：预占余额已在事务外通过 Redis 原子操作完成，这里不再需要数据库操作
 
+	// 写入订单事件（发件箱，与订单同时提交，由中继投递）
+	// callback_submit 以延迟消息投递（500 微秒延迟，与原有投递方式一致）
+	// 订单超时事件的延迟时间就是订单超时时间，数据库对账扫描作为兜底
+	timeoutEvent := s.bus != nil && orderCtx.TimeoutSeconds > 0
+	if s.bus != nil {
+		events := []outbox.Event{{
+			Topic:   mq.TopicCallbackSubmit,
+			Tag:     "submit",
+			Key:     orderCtx.OrderID,
+			Body:    newCallbackSubmitMessage(orderCtx, now),
+			Delayed: true,
+			Delay:   500 * time.Microsecond,
+		}}
+		if timeoutEvent {
+			events = append(events, outbox.Event{
+				Topic: mq.TopicOrderTimeout,
+				Tag:   "timeout",
+				Key:   orderCtx.OrderID,
+				Body: &mq.OrderTimeoutMessage{
+					OrderID:        orderCtx.OrderID,
+					OrderNo:        orderCtx.OrderNo,
+					CreateDatetime: now.Unix(),
+					TimeoutSeconds: orderCtx.TimeoutSeconds,
+				},
+				Delayed: true,
+				Delay:   time.Duration(orderCtx.TimeoutSeconds) * time.Second,
+			})
+		}
+		if err := outbox.Add(tx, events...); err != nil {
+			tx.Rollback()
+			if orderCtx.Tenant != nil {
+				_ = s.balanceService.ReleasePreTax(ctx, orderCtx.TenantID, int64(orderCtx.Tax))
+			}
+			return 0, NewOrderError(ErrCodeCreateFailed, err.Error())
+		}
+	}
+
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		// 如果事务提交失败，需要回滚 Redis 中的预占余额（释放的是手续费 tax）
@@ -1299,6 +1276,17 @@ func (s *OrderService) createOrderAndDetail(ctx context.Context, orderCtx *Order
 		}
 		return 0, NewOrderError(ErrCodeCreateFailed, fmt.Sprintf("提交事务失败: %v", err))
 	}
+	orderCtx.CreateDatetime = now
+	if s.bus != nil {
+		outbox.Kick()
+	}
+	if timeoutEvent {
+		logger.Logger.Info("订单超时事件已写入发件箱",
+			zap.String("order_no", orderCtx.OrderNo),
+			zap.String("order_id", orderCtx.OrderID),
+			zap.Int("timeout_seconds", orderCtx.TimeoutSeconds),
+			zap.Time("expected_expire_time", now.Add(time.Duration(orderCtx.TimeoutSeconds)*time.Second)))
+	}
 
 	// 注意：余额和预占余额已完全由 Redis 管理，不需要使缓存失效
 
@@ -1308,6 +1296,36 @@ func (s *OrderService) createOrderAndDetail(ctx context.Context, orderCtx *Order
 	return orderDetail.ID, nil
 }
 
+// getOrderTimeoutSeconds 从插件获取订单超时时间（秒），获取失败返回 0（依赖对账扫描兜底）
+func (s *OrderService) getOrderTimeoutSeconds(ctx context.Context, orderCtx *OrderCreateContext) int {
+	pluginInstance, err := s.pluginManager.GetPluginByCtx(ctx, orderCtx)
+	if err != nil {
+		logger.Logger.Warn("获取插件实例失败，无法获取订单超时时间",
+			zap.String("out_order_no", orderCtx.OutOrderNo),
+			zap.Int64("plugin_id", orderCtx.PluginID),
+			zap.String("plugin_type", orderCtx.PluginType),
+			zap.Error(err))
+		return 0
+	}
+
+	// 如果插件实现了 PluginCapabilities 接口，使用插件的 GetTimeout 方法
+	capabilities, ok := pluginInstance.(plugin.PluginCapabilities)
+	if !ok {
+		logger.Logger.Warn("插件未实现 PluginCapabilities 接口，无法获取超时时间",
+			zap.String("out_order_no", orderCtx.OutOrderNo),
+			zap.String("plugin_type", orderCtx.PluginType),
+			zap.String("plugin_instance_type", fmt.Sprintf("%T", pluginInstance)))
+		return 0
+	}
+
+	timeoutSeconds := capabilities.GetTimeout(ctx, orderCtx.PluginID)
+	logger.Logger.Debug("获取到插件超时时间",
+		zap.String("out_order_no", orderCtx.OutOrderNo),
+		zap.Int64("plugin_id", orderCtx.PluginID),
+		zap.Int("timeout_seconds", timeoutSeconds))
+	return timeoutSeconds
+}
+
 // createOrderLog 创建订单日志
 // 参考 Python: 只创建 order_log，不更新
 // 重要：订单事务成功后异步创建 order_log（只包含请求信息）
@@ -1717,9 +1735,48 @@ func (s *OrderService) buildResponse(orderCtx *OrderCreateContext, payURL string
 		response.PayURL2 = payURL
 	}
 
+	if orderCtx.MerchantSignKey != nil {
+		s.signResponse(orderCtx, response)
+	}
+
 	return response
 }
 
+// signResponse 使用验签的商户签名密钥签名下单响应（签名字段与响应字段一致，另含 keyId、signType）
+// rsa2 密钥没有配置平台私钥时只返回 keyId
+func (s *OrderService) signResponse(orderCtx *OrderCreateContext, response *CreateOrderResponse) {
+	key := orderCtx.MerchantSignKey
+	response.KeyID = key.KeyID
+	if !key.CanSign() {
+		return
+	}
+
+	var data map[string]interface{}
+	if orderCtx.Compatible == 1 {
+		data = map[string]interface{}{
+			"trade_no": response.TradeNo,
+			"payurl":   response.PayURL,
+			"msg":      response.Msg,
+			"code":     response.Code,
+		}
+	} else {
+		data = map[string]interface{}{
+			"mchOrderNo": response.MchOrderNo,
+			"payOrderId": response.PayOrderID,
+			"payUrl":     response.PayURL2,
+		}
+	}
+	if err := signMerchantData(key, data, orderCtx.Compatible); err != nil {
+		logger.Logger.Error("签名下单响应失败",
+			zap.String("out_order_no", orderCtx.OutOrderNo),
+			zap.String("key_id", key.KeyID),
+			zap.Error(err))
+		return
+	}
+	response.SignType = key.SignType
+	response.Sign, _ = data["sign"].(string)
+}
+
 // GetOrderByOrderNo 根据订单号获取订单
 func (s *OrderService) GetOrderByOrderNo(orderNo string) (*models.Order, error) {
 	var order models.Order
@@ -1761,6 +1818,30 @@ func (s *OrderService) GetOrderByOutOrderNo(outOrderNo string, merchantID int64)
 	return &order, nil
 }
 
+// newCallbackSubmitMessage 构建 callback_submit 事件消息
+func newCallbackSubmitMessage(orderCtx *OrderCreateContext, createDatetime time.Time) *mq.CallbackSubmitMessage {
+	return &mq.CallbackSubmitMessage{
+		OrderNo:        orderCtx.OrderNo,
+		OutOrderNo:     orderCtx.OutOrderNo,
+		PluginID:       orderCtx.PluginID,
+		Tax:            orderCtx.Tax,
+		PluginType:     orderCtx.PluginType,
+		Money:          orderCtx.Money,
+		DomainID:       orderCtx.DomainID,
+		NotifyMoney:    orderCtx.NotifyMoney,
+		OrderID:        orderCtx.OrderID,
+		ProductID:      orderCtx.ProductID,
+		CookieID:       orderCtx.CookieID,
+		ChannelID:      orderCtx.ChannelID,
+		MerchantID:     orderCtx.MerchantID,
+		WriteoffID:     orderCtx.WriteoffID,
+		TenantID:       orderCtx.TenantID,
+		CreateDatetime: createDatetime.Format("2006-01-02 15:04:05"),
+		NotifyURL:      orderCtx.NotifyURL,
+		PluginUpstream: orderCtx.PluginUpstream,
+	}
+}
+
 // callbackPluginSubmit 调用插件的 callback_submit 方法
 // 参考 Python: callback_plugin_submit 函数
 func (s *OrderService) callbackPluginSubmit(ctx context.Context, orderCtx *OrderCreateContext) error {
@@ -1843,16 +1924,22 @@ func (s *OrderService) UpdateOrderStatus(ctx context.Context, orderID string, st
 	tenantIDProvider := &tenantIDProviderAdapter{cacheService: s.cacheService}
 	preTaxReleaser := &preTaxReleaserAdapter{balanceService: s.balanceService}
 
+	opts := order.UpdateStatusOptions{
+		PreTaxReleaser:        preTaxReleaser,
+		TenantIDProvider:      tenantIDProvider,
+		HandleWriteoffBalance: true, // service 包需要处理码商余额
+	}
+	if s.bus != nil {
+		// 订单通知、日统计事件与订单状态在同一事务中写入发件箱
+		opts.EventWriter = mq.OrderStatusEventWriter{}
+	}
+
 	// 使用统一的订单状态更新逻辑
 	err := order.UpdateStatus(ctx, order.UpdateStatusRequest{
 		OrderID:  orderID,
 		Status:   status,
 		TicketNo: ticketNo,
-	}, order.UpdateStatusOptions{
-		PreTaxReleaser:        preTaxReleaser,
-		TenantIDProvider:      tenantIDProvider,
-		HandleWriteoffBalance: true, // service 包需要处理码商余额
-	})
+	}, opts)
 
 	// 如果事务提交失败，需要回滚 Redis 中的预占余额操作
 	if err != nil && err.Error() == "提交事务失败" {
@@ -5,6 +5,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
 	"github.com/golang-pay-core/internal/database"
 	"github.com/golang-pay-core/internal/models"
 	"github.com/golang-pay-core/internal/utils"
@@ -37,11 +39,18 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	return db
 }
 
-// setupTestRedis 设置测试 Redis（使用 Mock）
-// 注意：实际测试中应该使用真实的 Redis 或 miniredis
-// func setupTestRedis() *redis.Client {
-// 	return nil
-// }
+// setupTestRedis 设置测试 Redis（miniredis），替换 database.RDB 并在测试结束后恢复
+func setupTestRedis(t *testing.T) *miniredis.Miniredis {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	oldRDB := database.RDB
+	database.RDB = rdb
+	t.Cleanup(func() {
+		database.RDB = oldRDB
+		_ = rdb.Close()
+	})
+	return mr
+}
 
 // TestOrderService_CreateOrder_AmountInvalid 测试金额无效
 func TestOrderService_CreateOrder_AmountInvalid(t *testing.T) {
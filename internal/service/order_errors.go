@@ -36,6 +36,18 @@ const (
 	ErrCodeOutOrderNoRequired       = 7321
 	ErrCodeOutOrderNoExists         = 7321
 	ErrCodeConcurrencyLimit         = 7322
+	ErrCodeRefundOrderNotFound      = 7323
+	ErrCodeRefundStatusInvalid      = 7324
+	ErrCodeRefundAmountInvalid      = 7325
+	ErrCodeRefundNotSupported       = 7326
+	ErrCodeRefundFailed             = 7327
+	ErrCodeNotifyOrderNotFound      = 7328
+	ErrCodeNotifyStatusInvalid      = 7329
+	ErrCodeNotifyVersionConflict    = 7330
+	ErrCodeChannelAccessDenied      = 7331
+	ErrCodeRiskBlocked              = 7332
+	ErrCodeRequestExpired           = 7333
+	ErrCodeRequestReplayed          = 7334
 	ErrCodeSystemBusy               = 9999
 )
 
@@ -60,6 +72,17 @@ var (
 	ErrOutOrderNoExists    = &OrderError{Code: ErrCodeOutOrderNoExists, Message: "商户订单号已存在"}
 	ErrDomainUnavailable   = &OrderError{Code: ErrCodeDomainUnavailable, Message: "无可用收银台"}
 	ErrSystemBusy          = &OrderError{Code: ErrCodeSystemBusy, Message: "系统繁忙,请稍后重试"}
+	ErrRefundOrderNotFound = &OrderError{Code: ErrCodeRefundOrderNotFound, Message: "订单不存在"}
+	ErrRefundStatusInvalid = &OrderError{Code: ErrCodeRefundStatusInvalid, Message: "订单状态不允许退款"}
+	ErrRefundAmountInvalid = &OrderError{Code: ErrCodeRefundAmountInvalid, Message: "退款金额错误"}
+	ErrRefundNotSupported  = &OrderError{Code: ErrCodeRefundNotSupported, Message: "该通道不支持退款"}
+	ErrRefundFailed        = &OrderError{Code: ErrCodeRefundFailed, Message: "退款失败"}
+	ErrNotifyOrderNotFound = &OrderError{Code: ErrCodeNotifyOrderNotFound, Message: "订单不存在"}
+	ErrNotifyStatusInvalid = &OrderError{Code: ErrCodeNotifyStatusInvalid, Message: "订单未支付或没有通知地址，不能重新通知"}
+	ErrNotifyConflict      = &OrderError{Code: ErrCodeNotifyVersionConflict, Message: "通知状态已变更，请刷新后重试"}
+	ErrRiskBlocked         = &OrderError{Code: ErrCodeRiskBlocked, Message: "下单过于频繁，请稍后再试"}
+	ErrRequestExpired      = &OrderError{Code: ErrCodeRequestExpired, Message: "请求已过期，请检查 timestamp"}
+	ErrRequestReplayed     = &OrderError{Code: ErrCodeRequestReplayed, Message: "重复的请求，nonce 已使用"}
 )
 
 // NewOrderError 创建新的订单错误
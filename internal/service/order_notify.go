@@ -6,12 +6,15 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/golang-pay-core/internal/database"
 	"github.com/golang-pay-core/internal/logger"
 	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/mq"
+	"github.com/golang-pay-core/internal/safehttp"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
@@ -30,31 +33,12 @@ func NewOrderNotifyService() *OrderNotifyService {
 
 // NotifyMerchant 通知商户（异步执行）
 // 参考 Python: 向商户的 notify_url 发送回调通知
-// 创建通知任务，如果失败则记录到数据库用于后续重试
+// 创建通知任务并交给通知调度器立即投递，失败后由调度器按退避策略重试
 func (s *OrderNotifyService) NotifyMerchant(ctx context.Context, order *models.Order, orderDetail *models.OrderDetail) {
 	if orderDetail.NotifyURL == "" {
 		return
 	}
 
-	// 构建通知数据
-	notifyData := map[string]interface{}{
-		"order_no":     order.OrderNo,
-		"out_order_no": order.OutOrderNo,
-		"money":        order.Money,
-		"status":       order.OrderStatus,
-		"ticket_no":    orderDetail.TicketNo,
-		"timestamp":    time.Now().Unix(),
-	}
-
-	// 序列化为 JSON
-	notifyJSON, err := json.Marshal(notifyData)
-	if err != nil {
-		logger.Logger.Warn("序列化通知数据失败",
-			zap.String("order_no", order.OrderNo),
-			zap.Error(err))
-		return
-	}
-
 	// 创建或获取通知任务
 	notification, err := s.createOrGetNotification(order.ID)
 	if err != nil {
@@ -64,43 +48,149 @@ func (s *OrderNotifyService) NotifyMerchant(ctx context.Context, order *models.O
 		return
 	}
 
-	// 执行通知
-	success := s.sendNotification(ctx, notification.ID, orderDetail.NotifyURL, string(notifyJSON))
+	GetNotifyDispatcher().Schedule(ctx, notification.ID, time.Now())
+}
+
+// ProcessOrderNotify 处理订单通知事件（发件箱投递的兜底事件）
+// 订单已有通知任务时说明通知已在支付成功时发起，由通知调度器负责重试，这里直接确认
+func (s *OrderNotifyService) ProcessOrderNotify(ctx context.Context, msg *mq.OrderNotifyMessage) error {
+	var count int64
+	if err := database.DB.WithContext(ctx).Model(&models.MerchantNotification{}).
+		Where("order_id = ?", msg.OrderID).Count(&count).Error; err != nil {
+		return fmt.Errorf("查询通知任务失败: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	var order models.Order
+	if err := database.DB.WithContext(ctx).Where("id = ?", msg.OrderID).First(&order).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return fmt.Errorf("查询订单失败: %w", err)
+	}
+	if order.OrderStatus != models.OrderStatusPaid && order.OrderStatus != models.OrderStatusPaidNoNotify {
+		return nil
+	}
+
+	var orderDetail models.OrderDetail
+	if err := database.DB.WithContext(ctx).Where("order_id = ?", order.ID).First(&orderDetail).Error; err != nil {
+		return fmt.Errorf("查询订单详情失败: %w", err)
+	}
+
+	logger.Logger.Info("订单未发起通知，由订单通知事件补发",
+		zap.String("order_id", order.ID),
+		zap.String("order_no", order.OrderNo))
+	s.NotifyMerchant(ctx, &order, &orderDetail)
+	return nil
+}
+
+// notifyTarget 一次通知投递所需的数据
+type notifyTarget struct {
+	notification *models.MerchantNotification
+	order        *models.Order
+	orderDetail  *models.OrderDetail
+}
+
+// loadNotifyTarget 加载通知任务及其订单、订单详情
+func (s *OrderNotifyService) loadNotifyTarget(notificationID int64) (*notifyTarget, error) {
+	var notification models.MerchantNotification
+	if err := database.DB.Where("id = ?", notificationID).First(&notification).Error; err != nil {
+		return nil, err
+	}
+
+	var order models.Order
+	if err := database.DB.Where("id = ?", notification.OrderID).First(&order).Error; err != nil {
+		return nil, err
+	}
+
+	var orderDetail models.OrderDetail
+	if err := database.DB.Where("order_id = ?", order.ID).First(&orderDetail).Error; err != nil {
+		return nil, err
+	}
+
+	return &notifyTarget{notification: &notification, order: &order, orderDetail: &orderDetail}, nil
+}
+
+// attemptNotification 执行一次通知投递并记录历史，返回商户是否确认成功
+func (s *OrderNotifyService) attemptNotification(ctx context.Context, target *notifyTarget) bool {
+	notifyReq, err := s.buildNotifyRequest(ctx, target.order, target.orderDetail)
+	if err != nil {
+		logger.Logger.Warn("构建通知数据失败",
+			zap.String("order_no", target.order.OrderNo),
+			zap.Error(err))
+		// 记录失败历史（计入投递次数，避免无法构建的通知无限重试）
+		s.recordNotificationHistory(target.notification.ID, target.orderDetail.NotifyURL, "", "", 0, fmt.Sprintf("构建通知失败: %v", err))
+		return false
+	}
+
+	return s.sendNotification(ctx, target.notification.ID, notifyReq)
+}
 
+// completeNotification 根据投递结果更新通知状态（使用乐观锁）
+// 返回下次投递时间；通知成功或达到最大投递次数时返回 nil
+func (s *OrderNotifyService) completeNotification(ctx context.Context, notificationID int64, orderID string, success bool, maxRetries int) *time.Time {
 	// 重新获取通知记录以获取最新的版本号（防止并发更新）
 	var latestNotification models.MerchantNotification
-	if err := database.DB.Where("id = ?", notification.ID).First(&latestNotification).Error; err != nil {
+	if err := database.DB.Where("id = ?", notificationID).First(&latestNotification).Error; err != nil {
 		logger.Logger.Warn("获取最新通知记录失败",
-			zap.Int64("notification_id", notification.ID),
+			zap.Int64("notification_id", notificationID),
 			zap.Error(err))
-		return
+		return nil
 	}
 
-	// 更新通知状态
 	if success {
 		// 通知成功，更新状态为成功
 		s.updateNotificationStatus(latestNotification.ID, latestNotification.Ver, models.NotificationStatusSuccess)
+		s.markOrderNotified(ctx, orderID)
+		return nil
+	}
 
-		// 如果订单状态是"支付成功，通知未返回"，则更新为"支付成功，通知已返回"
-		// 需要重新查询订单以获取最新状态
-		var currentOrder models.Order
-		if err := database.DB.Where("id = ?", order.ID).First(&currentOrder).Error; err == nil {
-			if currentOrder.OrderStatus == models.OrderStatusPaidNoNotify {
-				// 更新订单状态为"支付成功，通知已返回"
-				if err := s.orderService.UpdateOrderStatus(ctx, order.ID, models.OrderStatusPaid, ""); err != nil {
-					logger.Logger.Warn("商户通知成功，但更新订单状态失败",
-						zap.String("order_id", order.ID),
-						zap.Error(err))
-				} else {
-					logger.Logger.Info("商户通知成功，订单状态已更新为'支付成功，通知已返回'",
-						zap.String("order_id", order.ID))
-				}
-			}
+	// 计算投递次数（通过历史记录数量，手动重新通知后重新计数）
+	var historyCount int64
+	query := database.DB.Model(&models.MerchantNotificationHistory{}).
+		Where("notification_id = ?", notificationID)
+	if latestNotification.RenotifyDatetime != nil {
+		query = query.Where("create_datetime >= ?", latestNotification.RenotifyDatetime)
+	}
+	query.Count(&historyCount)
+
+	if historyCount >= int64(maxRetries) {
+		if s.updateNotificationStatus(latestNotification.ID, latestNotification.Ver, models.NotificationStatusMaxRetry) {
+			logger.Logger.Info("通知已达到最大重试次数，已标记为最大重试状态",
+				zap.Int64("notification_id", notificationID),
+				zap.String("order_id", orderID),
+				zap.Int64("retry_count", historyCount))
 		}
-	} else {
-		// 通知失败，更新状态为失败（等待重试）
-		s.updateNotificationStatus(latestNotification.ID, latestNotification.Ver, models.NotificationStatusFailed)
+		return nil
 	}
+
+	// 通知失败，更新状态为失败（等待重试）
+	s.updateNotificationStatus(latestNotification.ID, latestNotification.Ver, models.NotificationStatusFailed)
+	nextAttempt := time.Now().Add(s.calculateRetryInterval(int(historyCount)))
+	return &nextAttempt
+}
+
+// markOrderNotified 商户通知成功后，将"支付成功，通知未返回"的订单更新为"支付成功，通知已返回"
+func (s *OrderNotifyService) markOrderNotified(ctx context.Context, orderID string) {
+	// 需要重新查询订单以获取最新状态
+	var currentOrder models.Order
+	if err := database.DB.Where("id = ?", orderID).First(&currentOrder).Error; err != nil {
+		return
+	}
+	if currentOrder.OrderStatus != models.OrderStatusPaidNoNotify {
+		return
+	}
+
+	if err := s.orderService.UpdateOrderStatus(ctx, orderID, models.OrderStatusPaid, ""); err != nil {
+		logger.Logger.Warn("商户通知成功，但更新订单状态失败",
+			zap.String("order_id", orderID),
+			zap.Error(err))
+		return
+	}
+	logger.Logger.Info("商户通知成功，订单状态已更新为'支付成功，通知已返回'",
+		zap.String("order_id", orderID))
 }
 
 // createOrGetNotification 创建或获取通知任务
@@ -130,65 +220,208 @@ func (s *OrderNotifyService) createOrGetNotification(orderID string) (*models.Me
 	return &notification, nil
 }
 
+// merchantNotifyRequest 商户通知请求
+type merchantNotifyRequest struct {
+	Method        string   // 请求方法
+	URL           string   // 请求地址（GET 时已包含查询参数）
+	Body          string   // 请求体（GET 时为空）
+	ContentType   string   // 请求体类型
+	SuccessBodies []string // 商户配置了成功应答时，要求响应体与其中之一一致（忽略首尾空白和大小写）才视为成功
+	TenantID      int64    // 商户所属租户（用于读取租户出站白名单）
+}
+
+// buildNotifyRequest 构建商户通知请求
+// 通知参数使用商户密钥签名，格式由商户配置 notify_format 决定，未配置时按订单兼容模式选择：
+//   - epay：按易支付规范以 GET 方式发送签名参数（兼容模式默认）
+//   - json：POST JSON（标准模式默认）
+//   - form：POST 表单
+//
+// json、form 格式的签名与下单响应签名一致：商户配置了签名密钥时使用当前签名密钥（携带 keyId、signType），
+// 否则使用系统用户密钥（utils.GenerateResponseSign，按订单兼容模式选择签名方法）
+func (s *OrderNotifyService) buildNotifyRequest(ctx context.Context, order *models.Order, orderDetail *models.OrderDetail) (*merchantNotifyRequest, error) {
+	if order.MerchantID == nil {
+		return nil, fmt.Errorf("订单没有商户ID，无法生成通知签名")
+	}
+	merchant, user, err := s.orderService.cacheService.GetMerchantWithUser(ctx, *order.MerchantID)
+	if err != nil || merchant == nil || user == nil {
+		return nil, fmt.Errorf("查询商户密钥失败: %v", err)
+	}
+
+	format := strings.ToLower(strings.TrimSpace(merchant.NotifyFormat))
+	if format == "" {
+		format = models.NotifyFormatJSON
+		if order.Compatible == 1 {
+			format = models.NotifyFormatEPay
+		}
+	}
+
+	if format == models.NotifyFormatEPay {
+		epayKey, err := s.orderService.cacheService.GetMerchantEPayKey(ctx, *order.MerchantID, user.Key)
+		if err != nil {
+			return nil, err
+		}
+		return &merchantNotifyRequest{
+			Method:        http.MethodGet,
+			URL:           AppendQuery(orderDetail.NotifyURL, BuildEPayNotifyParams(order, orderDetail, epayKey)),
+			SuccessBodies: notifySuccessBodies(merchant.NotifySuccessBody),
+			TenantID:      merchant.ParentID,
+		}, nil
+	}
+
+	notifyData := map[string]interface{}{
+		"order_no":     order.OrderNo,
+		"out_order_no": order.OutOrderNo,
+		"money":        order.Money,
+		"status":       order.OrderStatus,
+		"ticket_no":    orderDetail.TicketNo,
+		"timestamp":    time.Now().Unix(),
+	}
+	if err := s.orderService.cacheService.SignMerchantData(ctx, *order.MerchantID, user.Key, nil, notifyData, order.Compatible); err != nil {
+		return nil, err
+	}
+
+	notifyReq := &merchantNotifyRequest{
+		Method:        http.MethodPost,
+		URL:           orderDetail.NotifyURL,
+		SuccessBodies: notifySuccessBodies(merchant.NotifySuccessBody),
+		TenantID:      merchant.ParentID,
+	}
+
+	switch format {
+	case models.NotifyFormatForm:
+		values := url.Values{}
+		for k, v := range notifyData {
+			values.Set(k, fmt.Sprint(v))
+		}
+		notifyReq.Body = values.Encode()
+		notifyReq.ContentType = "application/x-www-form-urlencoded"
+	case models.NotifyFormatJSON:
+		notifyJSON, err := json.Marshal(notifyData)
+		if err != nil {
+			return nil, fmt.Errorf("序列化通知数据失败: %w", err)
+		}
+		notifyReq.Body = string(notifyJSON)
+		notifyReq.ContentType = "application/json"
+	default:
+		return nil, fmt.Errorf("不支持的通知格式: %s", format)
+	}
+
+	return notifyReq, nil
+}
+
+// notifySuccessBodies 解析商户配置的成功应答内容
+// 多个值用逗号分隔；未配置或为 * 时不校验应答内容（只要求 HTTP 200，与原有行为一致）
+func notifySuccessBodies(configured string) []string {
+	configured = strings.TrimSpace(configured)
+	if configured == "" || configured == "*" {
+		return nil
+	}
+
+	bodies := make([]string, 0)
+	for _, body := range strings.Split(configured, ",") {
+		if body = strings.TrimSpace(body); body != "" {
+			bodies = append(bodies, body)
+		}
+	}
+	return bodies
+}
+
+// isNotifySuccessBody 判断商户应答内容是否表示成功（忽略首尾空白和大小写）
+func isNotifySuccessBody(body string, successBodies []string) bool {
+	if len(successBodies) == 0 {
+		return true
+	}
+	body = strings.TrimSpace(body)
+	for _, expected := range successBodies {
+		if strings.EqualFold(body, expected) {
+			return true
+		}
+	}
+	return false
+}
+
 // sendNotification 发送通知并记录历史
-func (s *OrderNotifyService) sendNotification(ctx context.Context, notificationID int64, notifyURL, requestBody string) bool {
-	// 发送 POST 请求到商户的通知地址
-	req, err := http.NewRequestWithContext(ctx, "POST", notifyURL, strings.NewReader(requestBody))
+func (s *OrderNotifyService) sendNotification(ctx context.Context, notificationID int64, notifyReq *merchantNotifyRequest) bool {
+	var body io.Reader
+	if notifyReq.Body != "" {
+		body = strings.NewReader(notifyReq.Body)
+	}
+
+	// 发送请求到商户的通知地址
+	req, err := http.NewRequestWithContext(ctx, notifyReq.Method, notifyReq.URL, body)
 	if err != nil {
 		logger.Logger.Warn("创建通知请求失败",
 			zap.Int64("notification_id", notificationID),
-			zap.String("notify_url", notifyURL),
+			zap.String("notify_url", notifyReq.URL),
 			zap.Error(err))
 		// 记录失败历史
-		s.recordNotificationHistory(notificationID, notifyURL, "POST", requestBody, 0, fmt.Sprintf("创建请求失败: %v", err))
+		s.recordNotificationHistory(notificationID, notifyReq.URL, notifyReq.Method, notifyReq.Body, 0, fmt.Sprintf("创建请求失败: %v", err))
 		return false
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.ContentLength = int64(len(requestBody))
-
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+	if notifyReq.ContentType != "" {
+		req.Header.Set("Content-Type", notifyReq.ContentType)
 	}
 
+	// 通知地址由商户提供，使用带 SSRF 防护的客户端（拒绝内网地址，租户白名单除外）
+	client := safehttp.NewClientWithPolicy(tenantOutboundPolicy(ctx, notifyReq.TenantID), 10*time.Second)
+
 	resp, err := client.Do(req)
 	if err != nil {
 		logger.Logger.Warn("发送通知失败",
 			zap.Int64("notification_id", notificationID),
-			zap.String("notify_url", notifyURL),
+			zap.String("notify_url", notifyReq.URL),
 			zap.Error(err))
 		// 记录失败历史
-		s.recordNotificationHistory(notificationID, notifyURL, "POST", requestBody, 0, fmt.Sprintf("请求失败: %v", err))
+		s.recordNotificationHistory(notificationID, notifyReq.URL, notifyReq.Method, notifyReq.Body, 0, fmt.Sprintf("请求失败: %v", err))
 		return false
 	}
 	defer resp.Body.Close()
 
-	// 读取响应
-	body, _ := io.ReadAll(resp.Body)
-	bodyStr := string(body)
+	// 读取响应（超过大小限制视为失败）
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Logger.Warn("读取商户通知响应失败",
+			zap.Int64("notification_id", notificationID),
+			zap.String("notify_url", notifyReq.URL),
+			zap.Error(err))
+		s.recordNotificationHistory(notificationID, notifyReq.URL, notifyReq.Method, notifyReq.Body, resp.StatusCode, fmt.Sprintf("读取响应失败: %v", err))
+		return false
+	}
+	bodyStr := string(respBody)
 
 	// 记录通知历史
-	s.recordNotificationHistory(notificationID, notifyURL, "POST", requestBody, resp.StatusCode, bodyStr)
+	s.recordNotificationHistory(notificationID, notifyReq.URL, notifyReq.Method, notifyReq.Body, resp.StatusCode, bodyStr)
 
 	// 只有 HTTP 200 状态码才认为通知成功
 	// 其他状态码（如 403、404、500 等）都视为失败
-	if resp.StatusCode == http.StatusOK {
-		logger.Logger.Info("商户通知成功",
-			zap.Int64("notification_id", notificationID),
-			zap.String("notify_url", notifyURL),
-			zap.Int("status_code", resp.StatusCode),
-			zap.String("response", bodyStr))
-		return true
-	} else {
+	if resp.StatusCode != http.StatusOK {
 		// 明确记录失败原因（包括状态码）
 		logger.Logger.Warn("商户通知失败：HTTP 状态码不是 200",
 			zap.Int64("notification_id", notificationID),
-			zap.String("notify_url", notifyURL),
+			zap.String("notify_url", notifyReq.URL),
 			zap.Int("status_code", resp.StatusCode),
 			zap.String("expected_status", "200"),
 			zap.String("response", bodyStr))
 		return false
 	}
+
+	// 商户配置了成功应答时，需要返回约定的应答内容（如 success），避免把网关错误页等 200 响应误判为成功
+	if !isNotifySuccessBody(bodyStr, notifyReq.SuccessBodies) {
+		logger.Logger.Warn("商户通知失败：应答内容不符合要求",
+			zap.Int64("notification_id", notificationID),
+			zap.String("notify_url", notifyReq.URL),
+			zap.Strings("expected_body", notifyReq.SuccessBodies),
+			zap.String("response", bodyStr))
+		return false
+	}
+
+	logger.Logger.Info("商户通知成功",
+		zap.Int64("notification_id", notificationID),
+		zap.String("notify_url", notifyReq.URL),
+		zap.Int("status_code", resp.StatusCode),
+		zap.String("response", bodyStr))
+	return true
 }
 
 // recordNotificationHistory 记录通知历史
@@ -245,152 +478,6 @@ func (s *OrderNotifyService) updateNotificationStatus(notificationID int64, curr
 	return true
 }
 
-// RetryFailedNotifications 重试失败的通知
-// 参考 Python: 定时任务扫描失败的通知并重试
-// 使用指数退避策略，更灵活且可扩展
-func (s *OrderNotifyService) RetryFailedNotifications(ctx context.Context) {
-	// 查询需要重试的通知（状态为失败或重试中）
-
-	now := time.Now()
-	var notifications []models.MerchantNotification
-
-	// 查询所有失败状态的通知
-	if err := database.DB.Where("status IN ?", []int{
-		models.NotificationStatusFailed,
-		models.NotificationStatusRetrying,
-	}).Find(&notifications).Error; err != nil {
-		logger.Logger.Warn("查询失败通知失败", zap.Error(err))
-		return
-	}
-
-	for _, notification := range notifications {
-		// 查询订单和订单详情
-		var order models.Order
-		if err := database.DB.Where("id = ?", notification.OrderID).First(&order).Error; err != nil {
-			continue
-		}
-
-		var orderDetail models.OrderDetail
-		if err := database.DB.Where("order_id = ?", order.ID).First(&orderDetail).Error; err != nil {
-			continue
-		}
-
-		if orderDetail.NotifyURL == "" {
-			continue
-		}
-
-		// 计算重试次数（通过历史记录数量）
-		var historyCount int64
-		database.DB.Model(&models.MerchantNotificationHistory{}).
-			Where("notification_id = ?", notification.ID).
-			Count(&historyCount)
-
-		// 最大重试次数（5次）
-		maxRetries := 5
-		if historyCount >= int64(maxRetries) {
-			// 更新为最大重试状态（使用乐观锁）
-			if s.updateNotificationStatus(notification.ID, notification.Ver, models.NotificationStatusMaxRetry) {
-				logger.Logger.Info("通知已达到最大重试次数，已标记为最大重试状态",
-					zap.Int64("notification_id", notification.ID),
-					zap.String("order_id", order.ID),
-					zap.Int64("retry_count", historyCount))
-			}
-			continue
-		}
-
-		// 计算下次重试时间
-		if notification.UpdateDatetime == nil {
-			continue
-		}
-
-		// 使用指数退避策略计算重试间隔
-		retryInterval := s.calculateRetryInterval(int(historyCount))
-		nextRetryTime := notification.UpdateDatetime.Add(retryInterval)
-		if now.Before(nextRetryTime) {
-			// 还没到重试时间
-			continue
-		}
-
-		// 执行重试
-		logger.Logger.Info("开始重试商户通知",
-			zap.Int64("notification_id", notification.ID),
-			zap.String("order_id", order.ID),
-			zap.Int64("retry_count", historyCount))
-
-		// 更新状态为重试中（使用乐观锁）
-		if !s.updateNotificationStatus(notification.ID, notification.Ver, models.NotificationStatusRetrying) {
-			// 如果更新失败（版本冲突），跳过此次重试
-			logger.Logger.Warn("更新通知状态为重试中失败（版本冲突），跳过此次重试",
-				zap.Int64("notification_id", notification.ID),
-				zap.String("order_id", order.ID))
-			continue
-		}
-
-		// 构建通知数据
-		notifyData := map[string]interface{}{
-			"order_no":     order.OrderNo,
-			"out_order_no": order.OutOrderNo,
-			"money":        order.Money,
-			"status":       order.OrderStatus,
-			"ticket_no":    orderDetail.TicketNo,
-			"timestamp":    time.Now().Unix(),
-		}
-
-		notifyJSON, _ := json.Marshal(notifyData)
-
-		// 发送通知
-		success := s.sendNotification(ctx, notification.ID, orderDetail.NotifyURL, string(notifyJSON))
-
-		// 重新获取通知记录以获取最新的版本号（防止并发更新）
-		var latestNotification models.MerchantNotification
-		if err := database.DB.Where("id = ?", notification.ID).First(&latestNotification).Error; err != nil {
-			logger.Logger.Warn("获取最新通知记录失败",
-				zap.Int64("notification_id", notification.ID),
-				zap.Error(err))
-			continue
-		}
-
-		// 更新状态（使用乐观锁）
-		if success {
-			if s.updateNotificationStatus(latestNotification.ID, latestNotification.Ver, models.NotificationStatusSuccess) {
-				logger.Logger.Info("重试通知成功",
-					zap.Int64("notification_id", latestNotification.ID),
-					zap.String("order_id", order.ID))
-
-				// 如果订单状态是"支付成功，通知未返回"，则更新为"支付成功，通知已返回"
-				var currentOrder models.Order
-				if err := database.DB.Where("id = ?", order.ID).First(&currentOrder).Error; err == nil {
-					if currentOrder.OrderStatus == models.OrderStatusPaidNoNotify {
-						// 更新订单状态为"支付成功，通知已返回"
-						if err := s.orderService.UpdateOrderStatus(ctx, order.ID, models.OrderStatusPaid, ""); err != nil {
-							logger.Logger.Warn("重试通知成功，但更新订单状态失败",
-								zap.String("order_id", order.ID),
-								zap.Error(err))
-						} else {
-							logger.Logger.Info("重试通知成功，订单状态已更新为'支付成功，通知已返回'",
-								zap.String("order_id", order.ID))
-						}
-					}
-				}
-			} else {
-				logger.Logger.Warn("更新通知状态为成功失败（版本冲突）",
-					zap.Int64("notification_id", latestNotification.ID),
-					zap.String("order_id", order.ID))
-			}
-		} else {
-			if s.updateNotificationStatus(latestNotification.ID, latestNotification.Ver, models.NotificationStatusFailed) {
-				logger.Logger.Info("重试通知失败，已标记为失败状态",
-					zap.Int64("notification_id", latestNotification.ID),
-					zap.String("order_id", order.ID))
-			} else {
-				logger.Logger.Warn("更新通知状态为失败失败（版本冲突）",
-					zap.Int64("notification_id", latestNotification.ID),
-					zap.String("order_id", order.ID))
-			}
-		}
-	}
-}
-
 // calculateRetryInterval 计算重试间隔（指数退避策略）
 // 使用指数退避算法：base * (2 ^ retryCount)
 // 基础间隔为1分钟，每次重试间隔翻倍
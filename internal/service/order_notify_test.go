@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// setupNotifyTest 准备商户通知测试环境：商户 1（系统用户密钥 user_key）及其缓存
+func setupNotifyTest(t *testing.T, merchant models.Merchant) (*OrderNotifyService, *miniredis.Miniredis) {
+	db := setupTestDB(t)
+	assert.NoError(t, db.AutoMigrate(&models.MerchantSignKey{}, &models.MerchantNotificationHistory{}))
+	mr := setupTestRedis(t)
+
+	oldDB, oldLogger := database.DB, logger.Logger
+	database.DB, logger.Logger = db, zap.NewNop()
+	t.Cleanup(func() {
+		database.DB, logger.Logger = oldDB, oldLogger
+	})
+
+	cacheTestMerchant(mr, merchant)
+	return &OrderNotifyService{orderService: &OrderService{cacheService: NewCacheService()}}, mr
+}
+
+// cacheTestMerchant 写入商户 1（租户 9）及其系统用户（密钥 user_key）的缓存
+func cacheTestMerchant(mr *miniredis.Miniredis, merchant models.Merchant) {
+	userID := int64(100)
+	merchant.ID = 1
+	merchant.ParentID = 9
+	merchant.SystemUserID = &userID
+	merchantJSON, _ := json.Marshal(merchant)
+	userJSON, _ := json.Marshal(SystemUser{ID: userID, Key: "user_key", Status: true})
+	mr.Set("merchant:1", string(merchantJSON))
+	mr.Set(fmt.Sprintf("user:%d", userID), string(userJSON))
+}
+
+func notifyTestOrder(compatible int) (*models.Order, *models.OrderDetail) {
+	merchantID := int64(1)
+	order := &models.Order{ID: "O1", OrderNo: "PAY001", OutOrderNo: "M001", Money: 10000,
+		OrderStatus: models.OrderStatusPaidNoNotify, MerchantID: &merchantID, Compatible: compatible,
+		ReqExtra: `{"type":"alipay","name":"商品","param":"p"}`}
+	detail := &models.OrderDetail{OrderID: "O1", NotifyURL: "https://merchant.example.com/notify", TicketNo: "T001"}
+	return order, detail
+}
+
+func TestNotifySuccessBodies(t *testing.T) {
+	assert.Nil(t, notifySuccessBodies(""))
+	assert.Nil(t, notifySuccessBodies(" * "))
+	assert.Equal(t, []string{"success", "OK"}, notifySuccessBodies(" success, OK ,"))
+
+	cases := []struct {
+		body     string
+		expected []string
+		ok       bool
+	}{
+		{"anything", nil, true}, // 未配置时只要求 HTTP 200
+		{"", nil, true},
+		{" SUCCESS\n", []string{"success"}, true},
+		{"ok", []string{"success", "ok"}, true},
+		{"fail", []string{"success"}, false},
+		{"<html>502</html>", []string{"success"}, false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.ok, isNotifySuccessBody(c.body, c.expected), "body=%q expected=%v", c.body, c.expected)
+	}
+}
+
+func TestBuildNotifyRequestFormats(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("标准模式默认 json", func(t *testing.T) {
+		s, _ := setupNotifyTest(t, models.Merchant{})
+		order, detail := notifyTestOrder(0)
+		req, err := s.buildNotifyRequest(ctx, order, detail)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "application/json", req.ContentType)
+		assert.Nil(t, req.SuccessBodies)
+		assert.Equal(t, int64(9), req.TenantID)
+
+		var data map[string]interface{}
+		decoder := json.NewDecoder(strings.NewReader(req.Body))
+		decoder.UseNumber()
+		assert.NoError(t, decoder.Decode(&data))
+		assert.Equal(t, "PAY001", data["order_no"])
+		sign := data["sign"]
+		delete(data, "sign")
+		assert.Equal(t, utils.GenerateResponseSign(data, "user_key", 0), sign)
+	})
+
+	t.Run("兼容模式默认 epay", func(t *testing.T) {
+		s, _ := setupNotifyTest(t, models.Merchant{NotifySuccessBody: "success"})
+		order, detail := notifyTestOrder(1)
+		req, err := s.buildNotifyRequest(ctx, order, detail)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, http.MethodGet, req.Method)
+		assert.Empty(t, req.Body)
+		assert.Equal(t, []string{"success"}, req.SuccessBodies)
+
+		u, err := url.Parse(req.URL)
+		assert.NoError(t, err)
+		query := u.Query()
+		assert.Equal(t, "100.00", query.Get("money"))
+		assert.Equal(t, EPayTradeSuccess, query.Get("trade_status"))
+		assert.Equal(t, "T001", query.Get("api_trade_no"))
+		params := map[string]string{}
+		for k := range query {
+			params[k] = query.Get(k)
+		}
+		assert.Equal(t, utils.GetEPaySign(params, "user_key"), query.Get("sign"))
+	})
+
+	t.Run("form 使用签名密钥", func(t *testing.T) {
+		s, _ := setupNotifyTest(t, models.Merchant{NotifyFormat: models.NotifyFormatForm})
+		database.DB.Create(&models.MerchantSignKey{MerchantID: 1, KeyID: "k1", SignType: models.SignTypeHMACSHA256, Secret: "hmac_secret", Status: true})
+		order, detail := notifyTestOrder(0)
+		req, err := s.buildNotifyRequest(ctx, order, detail)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "application/x-www-form-urlencoded", req.ContentType)
+
+		values, err := url.ParseQuery(req.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "k1", values.Get("keyId"))
+		assert.Equal(t, models.SignTypeHMACSHA256, values.Get("signType"))
+		data := map[string]interface{}{}
+		for k := range values {
+			data[k] = values.Get(k)
+		}
+		assert.True(t, utils.VerifyWithKey(models.SignTypeHMACSHA256, "hmac_secret", utils.SignContent(data, 0), values.Get("sign"), 0))
+	})
+}
+
+func TestSendNotificationSuccessBody(t *testing.T) {
+	s, mr := setupNotifyTest(t, models.Merchant{})
+	var status int
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	// 放行测试服务地址（租户出站白名单）
+	u, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(u.Port())
+	allowList, _ := json.Marshal(map[string]outboundAllowList{"*": {Hosts: []string{u.Hostname()}, Ports: []int{port}}})
+	mr.Set("system_config:"+outboundAllowListConfigKey, string(allowList))
+
+	cases := []struct {
+		name          string
+		status        int
+		body          string
+		successBodies []string
+		ok            bool
+	}{
+		{"未配置应答时任意 200 应答成功", http.StatusOK, "received", nil, true},
+		{"非 200 失败", http.StatusInternalServerError, "success", nil, false},
+		{"配置应答且一致", http.StatusOK, " SUCCESS ", []string{"success"}, true},
+		{"配置应答但不一致", http.StatusOK, "received", []string{"success"}, false},
+	}
+	for i, c := range cases {
+		status, body = c.status, c.body
+		ok := s.sendNotification(context.Background(), int64(i+1), &merchantNotifyRequest{
+			Method:        http.MethodPost,
+			URL:           server.URL,
+			Body:          "a=1",
+			ContentType:   "application/x-www-form-urlencoded",
+			SuccessBodies: c.successBodies,
+		})
+		assert.Equal(t, c.ok, ok, c.name)
+	}
+
+	var histories int64
+	database.DB.Model(&models.MerchantNotificationHistory{}).Count(&histories)
+	assert.Equal(t, int64(len(cases)), histories)
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/plugin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrQueryNotSupported 插件不支持主动查单
+var ErrQueryNotSupported = errors.New("该插件不支持主动查单")
+
+// UpstreamQueryResult 上游查单结果
+type UpstreamQueryResult struct {
+	OrderNo     string `json:"order_no"`
+	OrderStatus int    `json:"order_status"`           // 查单（及补单）后的订单状态
+	TradeState  string `json:"trade_state"`            // 归一化的上游交易状态
+	TradeStatus string `json:"trade_status,omitempty"` // 上游原始交易状态
+	TicketNo    string `json:"ticket_no,omitempty"`    // 上游交易号
+	PayMoney    int    `json:"pay_money,omitempty"`    // 上游交易金额（分）
+	Recovered   bool   `json:"recovered"`              // 是否通过查单补单（本地未成功但上游已支付）
+}
+
+// OrderQueryService 上游查单服务
+// 订单超时关闭前主动向上游确认交易状态，上游已支付时走正常的成功流程（成功钩子、通知商户），找回丢失的异步通知
+type OrderQueryService struct {
+	orderService  *OrderService
+	cacheService  *CacheService
+	pluginManager *plugin.Manager
+	notifyService *OrderNotifyService
+}
+
+// NewOrderQueryService 创建上游查单服务
+func NewOrderQueryService() *OrderQueryService {
+	orderService := NewOrderService()
+	return &OrderQueryService{
+		orderService:  orderService,
+		cacheService:  orderService.cacheService,
+		pluginManager: orderService.pluginManager,
+		notifyService: NewOrderNotifyService(),
+	}
+}
+
+// recoverableStatuses 可以通过查单补单的订单状态
+// 参考 Python: 补单只处理未成功的订单（生成中、等待支付、支付失败、已关闭）
+var recoverableStatuses = map[int]bool{
+	models.OrderStatusGenerating: true,
+	models.OrderStatusPaying:     true,
+	models.OrderStatusFailed:     true,
+	models.OrderStatusClosed:     true,
+}
+
+// QueryUpstream 向上游查询订单的真实交易状态，上游已支付而本地未成功时执行补单
+func (s *OrderQueryService) QueryUpstream(ctx context.Context, orderNo string) (*UpstreamQueryResult, error) {
+	var order models.Order
+	if err := database.DB.Where("order_no = ?", orderNo).First(&order).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("订单不存在")
+		}
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+
+	var orderDetail models.OrderDetail
+	if err := database.DB.Where("order_id = ?", order.ID).First(&orderDetail).Error; err != nil {
+		return nil, fmt.Errorf("查询订单详情失败: %w", err)
+	}
+
+	pluginCtx := &simpleOrderContextForSuccess{
+		pluginType: orderDetail.PluginType,
+	}
+	if orderDetail.PluginID != nil {
+		pluginCtx.pluginID = *orderDetail.PluginID
+	}
+	if order.PayChannelID != nil {
+		pluginCtx.channelID = *order.PayChannelID
+	}
+
+	pluginInstance, err := s.pluginManager.GetPluginByCtx(ctx, pluginCtx)
+	if err != nil {
+		return nil, fmt.Errorf("获取插件实例失败: %w", err)
+	}
+
+	// 检查插件是否支持主动查单（可选能力）
+	querier, ok := pluginInstance.(plugin.PluginQuerier)
+	if !ok {
+		return nil, ErrQueryNotSupported
+	}
+
+	queryResp, err := querier.QueryOrder(ctx, &plugin.QueryOrderRequest{
+		OrderID:    order.ID,
+		OrderNo:    order.OrderNo,
+		OutOrderNo: order.OutOrderNo,
+		TicketNo:   orderDetail.TicketNo,
+		ProductID:  orderDetail.ProductID,
+		PluginID:   pluginCtx.pluginID,
+		PluginType: orderDetail.PluginType,
+		Money:      order.Money,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UpstreamQueryResult{
+		OrderNo:     order.OrderNo,
+		OrderStatus: order.OrderStatus,
+		TradeState:  queryResp.TradeState,
+		TradeStatus: queryResp.TradeStatus,
+		TicketNo:    queryResp.TicketNo,
+		PayMoney:    queryResp.PayMoney,
+	}
+
+	if !queryResp.IsPaid() || !recoverableStatuses[order.OrderStatus] {
+		return result, nil
+	}
+
+	// 上游已支付但本地未成功：补单
+	if queryResp.PayMoney > 0 && queryResp.PayMoney != order.Money {
+		// 金额不匹配，但不阻止处理（与异步通知处理保持一致）
+		logger.Logger.Warn("查单金额不匹配",
+			zap.String("order_no", order.OrderNo),
+			zap.Int("order_money", order.Money),
+			zap.Int("pay_money", queryResp.PayMoney))
+	}
+
+	if err := s.MarkOrderPaid(ctx, &order, queryResp.TicketNo); err != nil {
+		return nil, err
+	}
+
+	result.OrderStatus = models.OrderStatusPaidNoNotify
+	result.Recovered = true
+	return result, nil
+}
+
+// CheckUpstreamPaid 订单超时关闭前查询上游，上游已支付时完成补单并返回 true
+// 插件不支持查单时返回 false（按时间关闭）；查单失败或上游交易状态未知时返回错误，调用方不应关闭订单
+// 实现 mq.UpstreamOrderChecker 接口（由 main 注入 mq 包，避免循环依赖）
+func (s *OrderQueryService) CheckUpstreamPaid(ctx context.Context, orderNo string) (bool, error) {
+	result, err := s.QueryUpstream(ctx, orderNo)
+	if err != nil {
+		if errors.Is(err, ErrQueryNotSupported) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	switch result.TradeState {
+	case plugin.TradeStatePaid:
+		// 已补单，或订单在查单期间已被其他流程更新为成功
+		return true, nil
+	case plugin.TradeStateNotExist, plugin.TradeStateWaiting, plugin.TradeStateClosed:
+		return false, nil
+	default:
+		return false, fmt.Errorf("上游交易状态未知: %q（%s）", result.TradeState, result.TradeStatus)
+	}
+}
+
+// MarkOrderPaid 上游确认已支付：更新订单状态为"支付成功，通知未返回"，触发成功钩子并通知商户
+// 用于查单补单和不经过 MQ 的上游异步通知（如微信支付），与支付宝异步通知的成功处理流程保持一致
+func (s *OrderQueryService) MarkOrderPaid(ctx context.Context, order *models.Order, ticketNo string) error {
+	orderBefore := order.OrderStatus
+
+	if err := s.orderService.UpdateOrderStatus(ctx, order.ID, models.OrderStatusPaidNoNotify, ticketNo); err != nil {
+		return fmt.Errorf("更新订单状态失败: %w", err)
+	}
+
+	logger.Logger.Info("上游确认订单已支付，已更新订单状态",
+		zap.String("order_id", order.ID),
+		zap.String("order_no", order.OrderNo),
+		zap.Int("old_status", orderBefore),
+		zap.String("ticket_no", ticketNo))
+
+	// 异步触发成功钩子和商户通知（避免阻塞）
+	go func() {
+		successData, err := buildOrderSuccessData(context.Background(), s.cacheService, order.ID, orderBefore)
+		if err != nil {
+			logger.Logger.Error("构建订单成功数据失败，无法触发成功钩子",
+				zap.String("order_id", order.ID),
+				zap.Error(err))
+			return
+		}
+
+		hookService := NewOrderSuccessHookService()
+		if err := hookService.NotifyOrderSuccess(context.Background(), successData); err != nil {
+			logger.Logger.Error("触发订单成功钩子失败",
+				zap.String("order_id", order.ID),
+				zap.Error(err))
+		}
+
+		// 通知商户（重新查询订单和详情，获取最新状态）
+		var updatedOrder models.Order
+		var updatedDetail models.OrderDetail
+		if err := database.DB.Where("id = ?", order.ID).First(&updatedOrder).Error; err != nil {
+			return
+		}
+		if err := database.DB.Where("order_id = ?", order.ID).First(&updatedDetail).Error; err != nil {
+			return
+		}
+		s.notifyService.NotifyMerchant(context.Background(), &updatedOrder, &updatedDetail)
+	}()
+
+	return nil
+}
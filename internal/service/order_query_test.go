@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/plugin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// fakeQuerier 支持主动查单的测试插件
+type fakeQuerier struct {
+	plugin.Plugin
+	resp  *plugin.QueryOrderResponse
+	err   error
+	calls int
+}
+
+// newFakeQuerier 创建返回 resp 或 err 的查单测试插件（其他能力使用基础插件实现）
+func newFakeQuerier(resp *plugin.QueryOrderResponse, err error) *fakeQuerier {
+	return &fakeQuerier{Plugin: plugin.NewBasePlugin(5), resp: resp, err: err}
+}
+
+func (p *fakeQuerier) QueryOrder(ctx context.Context, req *plugin.QueryOrderRequest) (*plugin.QueryOrderResponse, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.resp, nil
+}
+
+// setupQueryTest 准备查单测试环境：订单 PAY001（金额 10000、状态 status，通知地址已配置）
+// 订单使用插件类型 pluginType，测试插件 p 注册为该类型
+func setupQueryTest(t *testing.T, pluginType string, p plugin.Plugin, status int) (*OrderQueryService, *gorm.DB, *miniredis.Miniredis) {
+	db := setupStatisticsTestDB(t)
+	assert.NoError(t, db.AutoMigrate(&models.MerchantNotification{}, &models.MerchantNotificationHistory{}))
+	mr := setupTestRedis(t)
+
+	channelID, pluginID := int64(3), int64(5)
+	now := time.Now()
+	db.Create(&models.Order{ID: "O1", OrderNo: "PAY001", OutOrderNo: "M001", Money: 10000,
+		PayChannelID: &channelID, OrderStatus: status, CreateDatetime: &now})
+	db.Create(&models.OrderDetail{OrderID: "O1", ProductID: "P1", PluginID: &pluginID, PluginType: pluginType,
+		NotifyURL: "https://merchant.example.com/notify", NotifyMoney: 10000})
+
+	plugin.GetRegistry().Register(pluginType, func(ctx context.Context, pluginID int64, pluginType string) (plugin.Plugin, error) {
+		return p, nil
+	})
+
+	orderService := &OrderService{cacheService: NewCacheService(), balanceService: NewBalanceService()}
+	return &OrderQueryService{
+		orderService:  orderService,
+		cacheService:  orderService.cacheService,
+		pluginManager: plugin.NewManager(database.RDB),
+		notifyService: NewOrderNotifyService(),
+	}, db, mr
+}
+
+// queryTestOrder 查询测试订单的当前状态和上游交易号
+func queryTestOrder(db *gorm.DB) (int, string) {
+	var order models.Order
+	var detail models.OrderDetail
+	db.First(&order, "id = ?", "O1")
+	db.First(&detail, "order_id = ?", "O1")
+	return order.OrderStatus, detail.TicketNo
+}
+
+func TestCheckUpstreamPaidRecoversOrder(t *testing.T) {
+	querier := newFakeQuerier(&plugin.QueryOrderResponse{
+		TradeState: plugin.TradeStatePaid, TradeStatus: "TRADE_SUCCESS", TicketNo: "T9", PayMoney: 10000}, nil)
+	s, db, mr := setupQueryTest(t, "test_query_recover", querier, models.OrderStatusPaying)
+
+	// 上游已支付：补单为"支付成功，通知未返回"并通知商户
+	paid, err := s.CheckUpstreamPaid(context.Background(), "PAY001")
+	assert.NoError(t, err)
+	assert.True(t, paid)
+	status, ticketNo := queryTestOrder(db)
+	assert.Equal(t, models.OrderStatusPaidNoNotify, status)
+	assert.Equal(t, "T9", ticketNo)
+
+	var notification models.MerchantNotification
+	assert.Eventually(t, func() bool {
+		return db.First(&notification, "order_id = ?", "O1").Error == nil && mr.Exists(notifyScheduleKey)
+	}, 2*time.Second, 10*time.Millisecond, "merchant notification not scheduled")
+	assert.Equal(t, models.NotificationStatusPending, notification.Status)
+}
+
+func TestQueryUpstreamRecoversClosedOrder(t *testing.T) {
+	querier := newFakeQuerier(&plugin.QueryOrderResponse{TradeState: plugin.TradeStatePaid, TicketNo: "T9"}, nil)
+	s, db, mr := setupQueryTest(t, "test_query_closed", querier, models.OrderStatusClosed)
+
+	// 已超时关闭的订单上游已支付：补单
+	result, err := s.QueryUpstream(context.Background(), "PAY001")
+	assert.NoError(t, err)
+	assert.True(t, result.Recovered)
+	assert.Equal(t, models.OrderStatusPaidNoNotify, result.OrderStatus)
+	status, _ := queryTestOrder(db)
+	assert.Equal(t, models.OrderStatusPaidNoNotify, status)
+
+	// 等待异步通知完成（测试结束后会恢复全局数据库连接）
+	assert.Eventually(t, func() bool {
+		return mr.Exists(notifyScheduleKey)
+	}, 2*time.Second, 10*time.Millisecond, "merchant notification not scheduled")
+}
+
+func TestCheckUpstreamPaidStatusAlreadyChanged(t *testing.T) {
+	querier := newFakeQuerier(&plugin.QueryOrderResponse{TradeState: plugin.TradeStatePaid, TicketNo: "T9"}, nil)
+	s, db, _ := setupQueryTest(t, "test_query_changed", querier, models.OrderStatusPaid)
+
+	// 订单已由异步通知更新为成功：不重复补单，但仍视为已支付（不关闭）
+	result, err := s.QueryUpstream(context.Background(), "PAY001")
+	assert.NoError(t, err)
+	assert.False(t, result.Recovered)
+	assert.Equal(t, models.OrderStatusPaid, result.OrderStatus)
+
+	paid, err := s.CheckUpstreamPaid(context.Background(), "PAY001")
+	assert.NoError(t, err)
+	assert.True(t, paid)
+	assert.Equal(t, 2, querier.calls)
+	status, ticketNo := queryTestOrder(db)
+	assert.Equal(t, models.OrderStatusPaid, status)
+	assert.Empty(t, ticketNo)
+
+	var count int64
+	db.Model(&models.MerchantNotification{}).Count(&count)
+	assert.Zero(t, count)
+}
+
+func TestCheckUpstreamPaidNotPaid(t *testing.T) {
+	cases := []struct {
+		name    string
+		plugin  plugin.Plugin
+		wantErr bool
+	}{
+		{"waiting", newFakeQuerier(&plugin.QueryOrderResponse{TradeState: plugin.TradeStateWaiting}, nil), false},
+		{"not_exist", newFakeQuerier(&plugin.QueryOrderResponse{TradeState: plugin.TradeStateNotExist}, nil), false},
+		{"closed", newFakeQuerier(&plugin.QueryOrderResponse{TradeState: plugin.TradeStateClosed}, nil), false},
+		{"not_supported", &fakePlugin{}, false},
+		{"unknown", newFakeQuerier(&plugin.QueryOrderResponse{TradeStatus: "SOMETHING_NEW"}, nil), true},
+		{"query_error", newFakeQuerier(nil, errors.New("upstream unavailable")), true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, db, _ := setupQueryTest(t, "test_query_"+tc.name, tc.plugin, models.OrderStatusPaying)
+
+			paid, err := s.CheckUpstreamPaid(context.Background(), "PAY001")
+			assert.False(t, paid)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			// 未支付或状态未知：不补单
+			status, _ := queryTestOrder(db)
+			assert.Equal(t, models.OrderStatusPaying, status)
+		})
+	}
+}
@@ -0,0 +1,515 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/ledger"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/mq"
+	"github.com/golang-pay-core/internal/plugin"
+	"github.com/golang-pay-core/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// refundLockTTL 订单退款锁的过期时间
+const refundLockTTL = 60 * time.Second
+
+// releaseRefundLockScript 释放订单退款锁（令牌一致时才删除，避免锁过期后删除其他请求的锁）
+var releaseRefundLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// RefundOrderRequest 订单退款请求
+type RefundOrderRequest struct {
+	OrderNo      string                 `json:"payOrderId"`               // 系统订单号（来自路径参数）
+	MerchantID   int                    `json:"mchId" binding:"required"` // 商户ID
+	RefundAmount int                    `json:"refundAmount"`             // 退款金额（分），为 0 时退还剩余全部金额
+	OutRefundNo  string                 `json:"mchRefundNo"`              // 商户退款单号（可选，用于幂等）
+	Reason       string                 `json:"reason"`                   // 退款原因
+	KeyID        string                 `json:"keyId"`                    // 签名密钥编号（可选）
+	Timestamp    int64                  `json:"timestamp"`                // 请求时间戳（防重放，可选）
+	Nonce        string                 `json:"nonce"`                    // 随机串（防重放，可选）
+	Sign         string                 `json:"sign" binding:"required"`  // 签名
+	RawSignData  map[string]interface{} `json:"-"`                        // 原始签名数据（内部使用）
+}
+
+// RefundOrderResponse 订单退款响应
+type RefundOrderResponse struct {
+	PayOrderID     string `json:"payOrderId"`            // 系统订单号
+	MchOrderNo     string `json:"mchOrderNo"`            // 商户订单号
+	RefundOrderID  string `json:"refundOrderId"`         // 退款单号
+	MchRefundNo    string `json:"mchRefundNo,omitempty"` // 商户退款单号
+	RefundAmount   int    `json:"refundAmount"`          // 本次退款金额（分）
+	RefundedAmount int    `json:"refundedAmount"`        // 累计已退款金额（分）
+	Status         int    `json:"status"`                // 订单状态
+}
+
+// OrderRefundService 订单退款服务
+// 流程：验证商户和签名 -> 校验订单状态和退款金额 -> 创建退款记录 -> 调用插件退款 -> 回退资金 -> 全额退款时变更订单状态并回退统计
+// 部分退款在退款成功的事务中按退款金额比例冲回资金（账本部分退款分录），订单状态不变；
+// 累计退款达到订单金额时订单状态变更为已退款，冲回剩余资金并回退统计
+type OrderRefundService struct {
+	orderService  *OrderService
+	cacheService  *CacheService
+	pluginManager *plugin.Manager
+}
+
+// NewOrderRefundService 创建订单退款服务
+func NewOrderRefundService() *OrderRefundService {
+	orderService := NewOrderService()
+	return &OrderRefundService{
+		orderService:  orderService,
+		cacheService:  orderService.cacheService,
+		pluginManager: orderService.pluginManager,
+	}
+}
+
+// RefundOrder 订单退款（主入口）
+func (s *OrderRefundService) RefundOrder(ctx context.Context, req *RefundOrderRequest) (*RefundOrderResponse, *OrderError) {
+	// 1. 验证商户
+	merchant, user, err := s.cacheService.GetMerchantWithUser(ctx, int64(req.MerchantID))
+	if err != nil {
+		return nil, ErrMerchantNotFound
+	}
+	if user == nil || !user.Status {
+		return nil, ErrMerchantDisabled
+	}
+
+	// 2. 验证签名和防重放参数
+	if orderErr := validateMerchantSign(ctx, s.cacheService, merchant, user.Key, req.RawSignData); orderErr != nil {
+		return nil, orderErr
+	}
+
+	return s.RefundAuthorizedOrder(ctx, req)
+}
+
+// RefundAuthorizedOrder 已完成商户鉴权的订单退款
+// 供使用其他鉴权方式的接入协议调用（如易支付 api.php 使用商户密钥鉴权），调用方负责验证商户身份
+func (s *OrderRefundService) RefundAuthorizedOrder(ctx context.Context, req *RefundOrderRequest) (*RefundOrderResponse, *OrderError) {
+	// 3. 查询订单（只能退款本商户的订单）
+	var order models.Order
+	if err := database.DB.Where("order_no = ? AND merchant_id = ?", req.OrderNo, req.MerchantID).
+		First(&order).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrRefundOrderNotFound
+		}
+		return nil, ErrSystemBusy
+	}
+
+	// 4. 只有支付成功的订单才能退款（order_status in [4, 6]）
+	if order.OrderStatus != models.OrderStatusPaid && order.OrderStatus != models.OrderStatusPaidNoNotify {
+		if order.OrderStatus == models.OrderStatusRefunded {
+			return nil, NewOrderError(ErrCodeRefundStatusInvalid, "订单已全额退款")
+		}
+		return nil, ErrRefundStatusInvalid
+	}
+
+	// 5. 同一订单的退款串行处理，避免超额退款
+	lockKey := fmt.Sprintf("refund:lock:%s", order.ID)
+	lockToken := newRefundLockToken()
+	ok, err := database.RDB.SetNX(ctx, lockKey, lockToken, refundLockTTL).Result()
+	if err != nil {
+		return nil, ErrSystemBusy
+	}
+	if !ok {
+		return nil, NewOrderError(ErrCodeConcurrencyLimit, "订单退款处理中,请稍后重试")
+	}
+	defer func() {
+		if err := releaseRefundLockScript.Run(context.Background(), database.RDB, []string{lockKey}, lockToken).Err(); err != nil {
+			logger.Logger.Warn("释放订单退款锁失败",
+				zap.String("order_no", order.OrderNo),
+				zap.Error(err))
+		}
+	}()
+
+	// 加锁后重新读取订单状态（等待加锁期间其他请求可能已完成全额退款）
+	if err := database.DB.Select("order_status").Where("id = ?", order.ID).First(&order).Error; err != nil {
+		return nil, ErrSystemBusy
+	}
+	if order.OrderStatus == models.OrderStatusRefunded {
+		return nil, NewOrderError(ErrCodeRefundStatusInvalid, "订单已全额退款")
+	}
+
+	// 6. 累计退款已达到订单金额但订单状态未变更（上次变更失败），重新完成全额退款
+	refunded, err := s.getRefundedAmount(order.ID)
+	if err != nil {
+		return nil, ErrSystemBusy
+	}
+	if refunded >= order.Money {
+		return s.retryFullRefund(ctx, &order)
+	}
+
+	// 6.1. 获取或创建退款记录
+	refund, existed, orderErr := s.prepareRefund(ctx, &order, req)
+	if orderErr != nil {
+		return nil, orderErr
+	}
+	if existed && refund.RefundStatus == models.RefundStatusSuccess {
+		// 相同商户退款单号已退款成功，直接返回（幂等）
+		return s.buildResponse(&order, refund)
+	}
+
+	// 7. 调用插件退款
+	if orderErr := s.callPluginRefund(ctx, &order, refund); orderErr != nil {
+		return nil, orderErr
+	}
+
+	// 8. 累计退款达到订单金额时，变更订单状态为已退款并回退资金和统计
+	// 变更失败时返回错误，商户重试时重新完成全额退款（上游已退款，不会重复退款）
+	refunded, err = s.getRefundedAmount(order.ID)
+	if err != nil {
+		logger.Logger.Error("查询累计退款金额失败",
+			zap.String("order_no", order.OrderNo),
+			zap.Error(err))
+		return nil, ErrSystemBusy
+	}
+	if refunded >= order.Money {
+		if err := s.finishFullRefund(ctx, &order); err != nil {
+			return nil, ErrSystemBusy
+		}
+	}
+
+	return s.buildResponse(&order, refund)
+}
+
+// retryFullRefund 重新完成全额退款（上游已全额退款，只变更订单状态），返回最近一次成功的退款记录
+func (s *OrderRefundService) retryFullRefund(ctx context.Context, order *models.Order) (*RefundOrderResponse, *OrderError) {
+	var refund models.OrderRefund
+	if err := database.DB.Where("order_id = ? AND refund_status = ?", order.ID, models.RefundStatusSuccess).
+		Order("id DESC").First(&refund).Error; err != nil {
+		return nil, ErrSystemBusy
+	}
+
+	logger.Logger.Warn("订单已全额退款但状态未变更，重新完成全额退款",
+		zap.String("order_no", order.OrderNo),
+		zap.String("refund_no", refund.RefundNo))
+	if err := s.finishFullRefund(ctx, order); err != nil {
+		return nil, ErrSystemBusy
+	}
+	return s.buildResponse(order, &refund)
+}
+
+// newRefundLockToken 生成订单退款锁令牌（每个请求不同）
+func newRefundLockToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validateMerchantSign 验证商户接口请求签名（退款、重新通知等，与下单标准模式签名规则一致）和防重放参数
+func validateMerchantSign(ctx context.Context, cacheService *CacheService, merchant *models.Merchant, legacyKey string, rawSignData map[string]interface{}) *OrderError {
+	if _, orderErr := cacheService.VerifyMerchantSign(ctx, merchant.ID, legacyKey, rawSignData, 0); orderErr != nil {
+		return orderErr
+	}
+	return checkMerchantReplay(ctx, merchant, rawSignData)
+}
+
+// prepareRefund 获取或创建退款记录
+// 传入商户退款单号且已存在记录时复用该记录（失败的退款使用相同退款单号重试，上游按退款单号幂等）
+func (s *OrderRefundService) prepareRefund(ctx context.Context, order *models.Order, req *RefundOrderRequest) (*models.OrderRefund, bool, *OrderError) {
+	if req.OutRefundNo != "" {
+		var existing models.OrderRefund
+		err := database.DB.Where("merchant_id = ? AND out_refund_no = ?", req.MerchantID, req.OutRefundNo).
+			First(&existing).Error
+		if err == nil {
+			if existing.OrderID != order.ID {
+				return nil, false, NewOrderError(ErrCodeRefundAmountInvalid, "商户退款单号已存在")
+			}
+			if req.RefundAmount > 0 && req.RefundAmount != existing.RefundMoney {
+				return nil, false, NewOrderError(ErrCodeRefundAmountInvalid, "退款金额与原退款单不一致")
+			}
+			if existing.RefundStatus != models.RefundStatusSuccess {
+				// 重试前重新校验可退款金额
+				refunded, err := s.getRefundedAmount(order.ID)
+				if err != nil {
+					return nil, false, ErrSystemBusy
+				}
+				if existing.RefundMoney > order.Money-refunded {
+					return nil, false, NewOrderError(ErrCodeRefundAmountInvalid,
+						fmt.Sprintf("退款金额错误,可退款金额: %d", order.Money-refunded))
+				}
+			}
+			return &existing, true, nil
+		}
+		if err != gorm.ErrRecordNotFound {
+			return nil, false, ErrSystemBusy
+		}
+	}
+
+	// 计算可退款金额
+	refunded, err := s.getRefundedAmount(order.ID)
+	if err != nil {
+		return nil, false, ErrSystemBusy
+	}
+	remaining := order.Money - refunded
+
+	refundMoney := req.RefundAmount
+	if refundMoney == 0 {
+		refundMoney = remaining
+	}
+	if refundMoney <= 0 || refundMoney > remaining {
+		return nil, false, NewOrderError(ErrCodeRefundAmountInvalid,
+			fmt.Sprintf("退款金额错误,可退款金额: %d", remaining))
+	}
+
+	// 查询官方流水号（支付宝交易号）
+	var orderDetail models.OrderDetail
+	if err := database.DB.Select("ticket_no").Where("order_id = ?", order.ID).First(&orderDetail).Error; err != nil {
+		return nil, false, ErrSystemBusy
+	}
+
+	now := time.Now()
+	refund := &models.OrderRefund{
+		RefundNo:       utils.GenerateRefundNo(),
+		OutRefundNo:    req.OutRefundNo,
+		OrderID:        order.ID,
+		OrderNo:        order.OrderNo,
+		MerchantID:     int64(req.MerchantID),
+		RefundMoney:    refundMoney,
+		RefundStatus:   models.RefundStatusPending,
+		Reason:         req.Reason,
+		TicketNo:       orderDetail.TicketNo,
+		CreateDatetime: &now,
+		UpdateDatetime: &now,
+	}
+	if err := database.DB.Create(refund).Error; err != nil {
+		logger.Logger.Error("创建退款记录失败",
+			zap.String("order_no", order.OrderNo),
+			zap.Error(err))
+		return nil, false, ErrSystemBusy
+	}
+
+	return refund, false, nil
+}
+
+// callPluginRefund 调用插件退款，并根据结果更新退款记录
+func (s *OrderRefundService) callPluginRefund(ctx context.Context, order *models.Order, refund *models.OrderRefund) *OrderError {
+	var orderDetail models.OrderDetail
+	if err := database.DB.Where("order_id = ?", order.ID).First(&orderDetail).Error; err != nil {
+		return ErrSystemBusy
+	}
+
+	pluginCtx := &simpleOrderContextForSuccess{
+		pluginType: orderDetail.PluginType,
+	}
+	if orderDetail.PluginID != nil {
+		pluginCtx.pluginID = *orderDetail.PluginID
+	}
+	if order.PayChannelID != nil {
+		pluginCtx.channelID = *order.PayChannelID
+	}
+
+	pluginInstance, err := s.pluginManager.GetPluginByCtx(ctx, pluginCtx)
+	if err != nil {
+		s.markRefund(refund, models.RefundStatusFailed, "", err.Error())
+		return ErrPluginUnavailable
+	}
+
+	// 检查插件是否支持退款（可选能力）
+	refunder, ok := pluginInstance.(plugin.PluginRefunder)
+	if !ok {
+		s.markRefund(refund, models.RefundStatusFailed, "", "插件不支持退款")
+		return ErrRefundNotSupported
+	}
+
+	refundReq := &plugin.RefundRequest{
+		OrderID:     order.ID,
+		OrderNo:     order.OrderNo,
+		OutOrderNo:  order.OutOrderNo,
+		TicketNo:    orderDetail.TicketNo,
+		ProductID:   orderDetail.ProductID,
+		PluginID:    pluginCtx.pluginID,
+		PluginType:  orderDetail.PluginType,
+		Money:       order.Money,
+		RefundMoney: refund.RefundMoney,
+		RefundNo:    refund.RefundNo,
+		Reason:      refund.Reason,
+	}
+
+	resp, err := refunder.Refund(ctx, refundReq)
+	if err != nil {
+		// 请求异常（网络超时等），通过退款查询确认结果
+		logger.Logger.Warn("插件退款请求异常，查询退款结果",
+			zap.String("order_no", order.OrderNo),
+			zap.String("refund_no", refund.RefundNo),
+			zap.Error(err))
+		resp, err = refunder.QueryRefund(ctx, refundReq)
+	}
+	if err != nil {
+		s.markRefund(refund, models.RefundStatusFailed, "", err.Error())
+		return NewOrderError(ErrCodeRefundFailed, "退款失败: "+err.Error())
+	}
+	if !resp.Success {
+		s.markRefund(refund, models.RefundStatusFailed, "", resp.ErrorMessage)
+		return NewOrderError(ErrCodeRefundFailed, "退款失败: "+resp.ErrorMessage)
+	}
+
+	if err := s.completeRefund(ctx, order, refund, resp.TicketNo); err != nil {
+		// 上游已退款，商户使用相同退款单号重试时重新完成（上游按退款单号幂等）
+		logger.Logger.Error("完成退款失败",
+			zap.String("order_no", order.OrderNo),
+			zap.String("refund_no", refund.RefundNo),
+			zap.Error(err))
+		return ErrSystemBusy
+	}
+
+	logger.Logger.Info("订单退款成功",
+		zap.String("order_no", order.OrderNo),
+		zap.String("refund_no", refund.RefundNo),
+		zap.Int("refund_money", refund.RefundMoney))
+
+	return nil
+}
+
+// completeRefund 上游退款成功：更新退款记录为成功，部分退款在同一事务中按比例冲回资金
+// 累计退款达到订单金额的退款不在这里记账，由订单状态变更为已退款时冲回剩余部分（finishFullRefund）
+func (s *OrderRefundService) completeRefund(ctx context.Context, order *models.Order, refund *models.OrderRefund, ticketNo string) error {
+	refunded, err := s.getRefundedAmount(order.ID)
+	if err != nil {
+		return fmt.Errorf("查询累计退款金额失败: %w", err)
+	}
+	partial := refunded+refund.RefundMoney < order.Money
+
+	// 与订单状态变更的记账选项一致：处理租户手续费和核销余额
+	ev := ledger.OrderEvent{Order: order, Writeoff: true}
+	if partial && order.MerchantID != nil {
+		tenantIDProvider := &tenantIDProviderAdapter{cacheService: s.cacheService}
+		if tenantID, err := tenantIDProvider.GetTenantIDByMerchantID(ctx, *order.MerchantID); err == nil {
+			ev.TenantID = tenantID
+		}
+	}
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.OrderRefund{}).Where("id = ?", refund.ID).
+			Updates(refundUpdates(models.RefundStatusSuccess, ticketNo, "")).Error; err != nil {
+			return fmt.Errorf("更新退款记录失败: %w", err)
+		}
+		if !partial {
+			return nil
+		}
+		_, err := ledger.PostOrderPartialRefund(tx, ev, refund.RefundNo, refund.RefundMoney)
+		if errors.Is(err, ledger.ErrDuplicate) {
+			return nil
+		}
+		return err
+	}); err != nil {
+		return err
+	}
+
+	refund.RefundStatus = models.RefundStatusSuccess
+	refund.ErrorMessage = ""
+	if ticketNo != "" {
+		refund.TicketNo = ticketNo
+	}
+	return nil
+}
+
+// refundUpdates 退款记录状态更新字段
+func refundUpdates(status int, ticketNo, errorMessage string) map[string]interface{} {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"refund_status":   status,
+		"error_message":   errorMessage,
+		"update_datetime": &now,
+	}
+	if ticketNo != "" {
+		updates["ticket_no"] = ticketNo
+	}
+	return updates
+}
+
+// markRefund 更新退款记录状态
+func (s *OrderRefundService) markRefund(refund *models.OrderRefund, status int, ticketNo, errorMessage string) {
+	if err := database.DB.Model(&models.OrderRefund{}).Where("id = ?", refund.ID).
+		Updates(refundUpdates(status, ticketNo, errorMessage)).Error; err != nil {
+		logger.Logger.Error("更新退款记录失败",
+			zap.String("refund_no", refund.RefundNo),
+			zap.Int("refund_status", status),
+			zap.Error(err))
+	}
+
+	refund.RefundStatus = status
+	refund.ErrorMessage = errorMessage
+	if ticketNo != "" {
+		refund.TicketNo = ticketNo
+	}
+}
+
+// getRefundedAmount 查询订单累计退款成功金额
+func (s *OrderRefundService) getRefundedAmount(orderID string) (int, error) {
+	var refunded int
+	if err := database.DB.Model(&models.OrderRefund{}).
+		Where("order_id = ? AND refund_status = ?", orderID, models.RefundStatusSuccess).
+		Select("COALESCE(SUM(refund_money), 0)").
+		Scan(&refunded).Error; err != nil {
+		return 0, err
+	}
+	return refunded, nil
+}
+
+// finishFullRefund 订单全额退款：变更订单状态为已退款（回退租户手续费、码商余额、商户预付款），并回退统计
+// 资金回退按订单幂等记账，状态变更失败后可以重复调用
+// 启用消息总线时，回退统计的日统计事件与订单状态在同一事务中写入发件箱，由消费端按 (订单ID, 状态) 幂等回退；
+// 未启用时在这里同步回退（同样按 (订单ID, 状态) 幂等）
+func (s *OrderRefundService) finishFullRefund(ctx context.Context, order *models.Order) error {
+	if err := s.orderService.UpdateOrderStatus(ctx, order.ID, models.OrderStatusRefunded, ""); err != nil {
+		logger.Logger.Error("更新订单状态为已退款失败",
+			zap.String("order_no", order.OrderNo),
+			zap.Error(err))
+		return err
+	}
+	order.OrderStatus = models.OrderStatusRefunded
+
+	if s.orderService.bus != nil {
+		return nil
+	}
+
+	msg := &mq.DayStatisticsMessage{
+		OrderID:        order.ID,
+		Status:         models.OrderStatusRefunded,
+		StatisticsType: "refund",
+	}
+	if order.MerchantID != nil {
+		tenantIDProvider := &tenantIDProviderAdapter{cacheService: s.cacheService}
+		if tenantID, err := tenantIDProvider.GetTenantIDByMerchantID(ctx, *order.MerchantID); err == nil {
+			msg.TenantID = *tenantID
+		}
+	}
+	if err := NewOrderSuccessHookService().ProcessDayStatistics(ctx, msg); err != nil {
+		// 订单已退款，统计回退失败只记录日志（资金已由账本冲回）
+		logger.Logger.Error("回退订单退款统计失败",
+			zap.String("order_id", order.ID),
+			zap.Error(err))
+	}
+	return nil
+}
+
+// buildResponse 构建退款响应
+func (s *OrderRefundService) buildResponse(order *models.Order, refund *models.OrderRefund) (*RefundOrderResponse, *OrderError) {
+	refunded, err := s.getRefundedAmount(order.ID)
+	if err != nil {
+		return nil, ErrSystemBusy
+	}
+
+	return &RefundOrderResponse{
+		PayOrderID:     order.OrderNo,
+		MchOrderNo:     order.OutOrderNo,
+		RefundOrderID:  refund.RefundNo,
+		MchRefundNo:    refund.OutRefundNo,
+		RefundAmount:   refund.RefundMoney,
+		RefundedAmount: refunded,
+		Status:         order.OrderStatus,
+	}, nil
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/ledger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/plugin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// fakeRefunder 支持退款的测试插件
+type fakeRefunder struct {
+	plugin.Plugin
+	requests   []*plugin.RefundRequest
+	queries    int
+	refundErr  error
+	resp       *plugin.RefundResponse
+	beforeResp func() // 返回退款结果前调用（模拟退款期间的并发操作）
+}
+
+func (p *fakeRefunder) Refund(ctx context.Context, req *plugin.RefundRequest) (*plugin.RefundResponse, error) {
+	p.requests = append(p.requests, req)
+	if p.beforeResp != nil {
+		p.beforeResp()
+	}
+	if p.refundErr != nil {
+		return nil, p.refundErr
+	}
+	return p.resp, nil
+}
+
+func (p *fakeRefunder) QueryRefund(ctx context.Context, req *plugin.RefundRequest) (*plugin.RefundResponse, error) {
+	p.queries++
+	return p.resp, nil
+}
+
+// fakePlugin 不支持退款的测试插件
+type fakePlugin struct {
+	plugin.Plugin
+}
+
+// setupRefundTest 准备退款测试环境：租户 9（余额 1000）、商户 1 的订单 PAY001（金额 10000、手续费 300、状态 6，已记支付分录）
+// 订单使用插件类型 pluginType，测试插件 p 注册为该类型
+func setupRefundTest(t *testing.T, pluginType string, p plugin.Plugin) (*OrderRefundService, *gorm.DB, *miniredis.Miniredis) {
+	db := setupStatisticsTestDB(t)
+	assert.NoError(t, db.AutoMigrate(
+		&models.OrderRefund{}, &models.Writeoff{}, &models.MerchantPre{},
+		&models.TenantCashflow{}, &models.WriteoffCashflow{},
+		&models.LedgerAccount{}, &models.LedgerEntry{}, &models.LedgerPosting{},
+	))
+	mr := setupTestRedis(t)
+	cacheTestMerchant(mr, models.Merchant{})
+
+	merchantID, channelID, pluginID, tenantID := int64(1), int64(3), int64(5), int64(9)
+	db.Create(&models.Tenant{ID: tenantID, Balance: 1000})
+	order := &models.Order{ID: "O1", OrderNo: "PAY001", OutOrderNo: "M001", Money: 10000, Tax: 300,
+		MerchantID: &merchantID, PayChannelID: &channelID, OrderStatus: models.OrderStatusPaid}
+	db.Create(order)
+	db.Create(&models.OrderDetail{OrderID: "O1", ProductID: "P1", PluginID: &pluginID, PluginType: pluginType,
+		TicketNo: "T1", NotifyMoney: 10000, MerchantTax: 500})
+	_, err := ledger.PostOrderPaid(db, ledger.OrderEvent{Order: order, TenantID: &tenantID, Writeoff: true})
+	assert.NoError(t, err)
+
+	plugin.GetRegistry().Register(pluginType, func(ctx context.Context, pluginID int64, pluginType string) (plugin.Plugin, error) {
+		return p, nil
+	})
+
+	orderService := &OrderService{cacheService: NewCacheService(), balanceService: NewBalanceService()}
+	return &OrderRefundService{
+		orderService:  orderService,
+		cacheService:  orderService.cacheService,
+		pluginManager: plugin.NewManager(database.RDB),
+	}, db, mr
+}
+
+// tenantBalance 查询租户 9 的余额
+func tenantBalance(db *gorm.DB) int64 {
+	var tenant models.Tenant
+	db.First(&tenant, 9)
+	return tenant.Balance
+}
+
+func TestPrepareRefundValidation(t *testing.T) {
+	s, db, _ := setupRefundTest(t, "test_refund_prepare", &fakeRefunder{})
+	ctx := context.Background()
+	var order models.Order
+	db.First(&order, "id = ?", "O1")
+
+	// 已成功退款 3000，可退款金额 7000
+	db.Create(&models.OrderRefund{RefundNo: "R0", OutRefundNo: "MR0", OrderID: "O1", OrderNo: "PAY001", MerchantID: 1,
+		RefundMoney: 3000, RefundStatus: models.RefundStatusSuccess})
+	// 其他订单的商户退款单号、失败的退款单
+	db.Create(&models.OrderRefund{RefundNo: "R9", OutRefundNo: "OTHER", OrderID: "O2", OrderNo: "PAY002", MerchantID: 1,
+		RefundMoney: 100, RefundStatus: models.RefundStatusSuccess})
+	db.Create(&models.OrderRefund{RefundNo: "R1", OutRefundNo: "MR1", OrderID: "O1", OrderNo: "PAY001", MerchantID: 1,
+		RefundMoney: 8000, RefundStatus: models.RefundStatusFailed})
+	db.Create(&models.OrderRefund{RefundNo: "R2", OutRefundNo: "MR2", OrderID: "O1", OrderNo: "PAY001", MerchantID: 1,
+		RefundMoney: 2000, RefundStatus: models.RefundStatusFailed})
+
+	invalid := []struct {
+		name string
+		req  RefundOrderRequest
+	}{
+		{"超过可退款金额", RefundOrderRequest{MerchantID: 1, RefundAmount: 7001}},
+		{"负数金额", RefundOrderRequest{MerchantID: 1, RefundAmount: -1}},
+		{"商户退款单号属于其他订单", RefundOrderRequest{MerchantID: 1, OutRefundNo: "OTHER"}},
+		{"金额与原退款单不一致", RefundOrderRequest{MerchantID: 1, OutRefundNo: "MR2", RefundAmount: 1000}},
+		{"重试的退款单超过可退款金额", RefundOrderRequest{MerchantID: 1, OutRefundNo: "MR1"}},
+	}
+	for _, c := range invalid {
+		_, _, orderErr := s.prepareRefund(ctx, &order, &c.req)
+		if assert.NotNil(t, orderErr, c.name) {
+			assert.Equal(t, ErrCodeRefundAmountInvalid, orderErr.Code, c.name)
+		}
+	}
+
+	// 金额为 0 时退还剩余全部金额
+	refund, existed, orderErr := s.prepareRefund(ctx, &order, &RefundOrderRequest{MerchantID: 1, OutRefundNo: "MR3"})
+	assert.Nil(t, orderErr)
+	assert.False(t, existed)
+	assert.Equal(t, 7000, refund.RefundMoney)
+	assert.Equal(t, "T1", refund.TicketNo)
+	assert.Equal(t, models.RefundStatusPending, refund.RefundStatus)
+
+	// 部分退款金额
+	refund, _, orderErr = s.prepareRefund(ctx, &order, &RefundOrderRequest{MerchantID: 1, RefundAmount: 1500})
+	assert.Nil(t, orderErr)
+	assert.Equal(t, 1500, refund.RefundMoney)
+
+	// 相同商户退款单号复用已有记录
+	refund, existed, orderErr = s.prepareRefund(ctx, &order, &RefundOrderRequest{MerchantID: 1, OutRefundNo: "MR2"})
+	assert.Nil(t, orderErr)
+	assert.True(t, existed)
+	assert.Equal(t, "R2", refund.RefundNo)
+	refund, existed, orderErr = s.prepareRefund(ctx, &order, &RefundOrderRequest{MerchantID: 1, OutRefundNo: "MR0", RefundAmount: 3000})
+	assert.Nil(t, orderErr)
+	assert.True(t, existed)
+	assert.Equal(t, models.RefundStatusSuccess, refund.RefundStatus)
+}
+
+func TestRefundPartialThenFull(t *testing.T) {
+	p := &fakeRefunder{resp: plugin.NewRefundSuccessResponse("", "UPSTREAM1", 0)}
+	s, db, mr := setupRefundTest(t, "test_refund_partial", p)
+	ctx := context.Background()
+
+	// 部分退款：调用插件退款，按比例冲回租户手续费，订单状态不变
+	resp, orderErr := s.RefundAuthorizedOrder(ctx, &RefundOrderRequest{OrderNo: "PAY001", MerchantID: 1, RefundAmount: 3000, OutRefundNo: "MR1", Reason: "部分"})
+	if !assert.Nil(t, orderErr) {
+		return
+	}
+	assert.Equal(t, 3000, resp.RefundAmount)
+	assert.Equal(t, 3000, resp.RefundedAmount)
+	assert.Equal(t, models.OrderStatusPaid, resp.Status)
+	if assert.Len(t, p.requests, 1) {
+		req := p.requests[0]
+		assert.Equal(t, "PAY001", req.OrderNo)
+		assert.Equal(t, "T1", req.TicketNo)
+		assert.Equal(t, int64(5), req.PluginID)
+		assert.Equal(t, 10000, req.Money)
+		assert.Equal(t, 3000, req.RefundMoney)
+		assert.Equal(t, resp.RefundOrderID, req.RefundNo)
+	}
+	assert.Equal(t, int64(700+90), tenantBalance(db))
+	assert.False(t, mr.Exists("refund:lock:O1"), "退款完成后释放锁")
+
+	var refund models.OrderRefund
+	db.Where("refund_no = ?", resp.RefundOrderID).First(&refund)
+	assert.Equal(t, models.RefundStatusSuccess, refund.RefundStatus)
+	assert.Equal(t, "UPSTREAM1", refund.TicketNo)
+
+	// 相同商户退款单号重复请求直接返回（不再调用插件）
+	_, orderErr = s.RefundAuthorizedOrder(ctx, &RefundOrderRequest{OrderNo: "PAY001", MerchantID: 1, OutRefundNo: "MR1"})
+	assert.Nil(t, orderErr)
+	assert.Len(t, p.requests, 1)
+
+	// 退还剩余金额：订单变更为已退款，冲回剩余手续费并回退统计
+	resp, orderErr = s.RefundAuthorizedOrder(ctx, &RefundOrderRequest{OrderNo: "PAY001", MerchantID: 1})
+	if !assert.Nil(t, orderErr) {
+		return
+	}
+	assert.Equal(t, 7000, resp.RefundAmount)
+	assert.Equal(t, 10000, resp.RefundedAmount)
+	assert.Equal(t, models.OrderStatusRefunded, resp.Status)
+	assert.Equal(t, int64(1000), tenantBalance(db))
+
+	var records int64
+	db.Model(&models.OrderStatisticsRecord{}).Where("order_id = ? AND order_status = ?", "O1", models.OrderStatusRefunded).Count(&records)
+	assert.Equal(t, int64(1), records)
+
+	// 已全额退款的订单不能再退款
+	_, orderErr = s.RefundAuthorizedOrder(ctx, &RefundOrderRequest{OrderNo: "PAY001", MerchantID: 1})
+	if assert.NotNil(t, orderErr) {
+		assert.Equal(t, ErrCodeRefundStatusInvalid, orderErr.Code)
+	}
+}
+
+func TestRefundRetryFinishesFullRefund(t *testing.T) {
+	p := &fakeRefunder{resp: plugin.NewRefundSuccessResponse("", "", 0)}
+	s, db, _ := setupRefundTest(t, "test_refund_retry", p)
+
+	// 上次已全额退款成功，但订单状态变更失败
+	db.Create(&models.OrderRefund{RefundNo: "R1", OrderID: "O1", OrderNo: "PAY001", MerchantID: 1,
+		RefundMoney: 10000, RefundStatus: models.RefundStatusSuccess})
+
+	resp, orderErr := s.RefundAuthorizedOrder(context.Background(), &RefundOrderRequest{OrderNo: "PAY001", MerchantID: 1})
+	if !assert.Nil(t, orderErr) {
+		return
+	}
+	assert.Empty(t, p.requests, "上游已退款，不重复调用插件")
+	assert.Equal(t, "R1", resp.RefundOrderID)
+	assert.Equal(t, models.OrderStatusRefunded, resp.Status)
+
+	var order models.Order
+	db.First(&order, "id = ?", "O1")
+	assert.Equal(t, models.OrderStatusRefunded, order.OrderStatus)
+	assert.Equal(t, int64(1000), tenantBalance(db))
+}
+
+func TestRefundLockOwnership(t *testing.T) {
+	p := &fakeRefunder{resp: plugin.NewRefundSuccessResponse("", "", 0)}
+	s, _, mr := setupRefundTest(t, "test_refund_lock", p)
+	ctx := context.Background()
+
+	// 其他请求持有锁时拒绝退款，且不删除其他请求的锁
+	mr.Set("refund:lock:O1", "other")
+	_, orderErr := s.RefundAuthorizedOrder(ctx, &RefundOrderRequest{OrderNo: "PAY001", MerchantID: 1, RefundAmount: 1000})
+	if assert.NotNil(t, orderErr) {
+		assert.Equal(t, ErrCodeConcurrencyLimit, orderErr.Code)
+	}
+	assert.Empty(t, p.requests)
+	value, _ := mr.Get("refund:lock:O1")
+	assert.Equal(t, "other", value)
+
+	// 退款期间锁过期并被其他请求获取，完成后不删除其他请求的锁
+	mr.Del("refund:lock:O1")
+	p.beforeResp = func() {
+		mr.Set("refund:lock:O1", "next")
+	}
+	_, orderErr = s.RefundAuthorizedOrder(ctx, &RefundOrderRequest{OrderNo: "PAY001", MerchantID: 1, RefundAmount: 1000})
+	assert.Nil(t, orderErr)
+	value, _ = mr.Get("refund:lock:O1")
+	assert.Equal(t, "next", value)
+}
+
+func TestRefundPluginDispatch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("插件不支持退款", func(t *testing.T) {
+		s, db, _ := setupRefundTest(t, "test_refund_unsupported", &fakePlugin{})
+		_, orderErr := s.RefundAuthorizedOrder(ctx, &RefundOrderRequest{OrderNo: "PAY001", MerchantID: 1})
+		if assert.NotNil(t, orderErr) {
+			assert.Equal(t, ErrCodeRefundNotSupported, orderErr.Code)
+		}
+		var refund models.OrderRefund
+		db.First(&refund)
+		assert.Equal(t, models.RefundStatusFailed, refund.RefundStatus)
+	})
+
+	t.Run("请求异常时查询退款结果", func(t *testing.T) {
+		p := &fakeRefunder{refundErr: errors.New("timeout"), resp: plugin.NewRefundSuccessResponse("", "", 0)}
+		s, db, _ := setupRefundTest(t, "test_refund_query", p)
+		_, orderErr := s.RefundAuthorizedOrder(ctx, &RefundOrderRequest{OrderNo: "PAY001", MerchantID: 1, RefundAmount: 1000})
+		assert.Nil(t, orderErr)
+		assert.Equal(t, 1, p.queries)
+		var refund models.OrderRefund
+		db.First(&refund)
+		assert.Equal(t, models.RefundStatusSuccess, refund.RefundStatus)
+	})
+
+	t.Run("上游退款失败", func(t *testing.T) {
+		p := &fakeRefunder{resp: &plugin.RefundResponse{Success: false, ErrorMessage: "余额不足"}}
+		s, db, _ := setupRefundTest(t, "test_refund_failed", p)
+		_, orderErr := s.RefundAuthorizedOrder(ctx, &RefundOrderRequest{OrderNo: "PAY001", MerchantID: 1, RefundAmount: 1000})
+		if assert.NotNil(t, orderErr) {
+			assert.Equal(t, ErrCodeRefundFailed, orderErr.Code)
+		}
+		var refund models.OrderRefund
+		db.First(&refund)
+		assert.Equal(t, models.RefundStatusFailed, refund.RefundStatus)
+		assert.Equal(t, "余额不足", refund.ErrorMessage)
+		assert.Equal(t, int64(700), tenantBalance(db), "退款失败不冲回资金")
+	})
+}
@@ -2,21 +2,25 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/golang-pay-core/internal/database"
 	"github.com/golang-pay-core/internal/logger"
 	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/mq"
 	"github.com/golang-pay-core/internal/plugin"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // OrderSuccessHookService 订单成功钩子服务
 // 参考 Python: notify_order_success 和 @order_success_handle() 装饰器
 type OrderSuccessHookService struct {
 	pluginManager *plugin.Manager
+	statsDB       *gorm.DB // 统计更新使用的连接（为空时使用 database.DB；消费日统计事件时为事务）
 }
 
 // NewOrderSuccessHookService 创建订单成功钩子服务
@@ -49,6 +53,58 @@ type OrderSuccessData struct {
 	OrderBefore    int // 订单之前的状态
 }
 
+// buildOrderSuccessData 根据订单最新数据构建成功钩子数据（订单成功、退款回退统计共用）
+// cacheService 为空时不查询租户ID，由调用方填充
+func buildOrderSuccessData(ctx context.Context, cacheService *CacheService, orderID string, orderBefore int) (*OrderSuccessData, error) {
+	var order models.Order
+	if err := database.DB.Where("id = ?", orderID).First(&order).Error; err != nil {
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+	var orderDetail models.OrderDetail
+	if err := database.DB.Where("order_id = ?", orderID).First(&orderDetail).Error; err != nil {
+		return nil, fmt.Errorf("查询订单详情失败: %w", err)
+	}
+
+	data := &OrderSuccessData{
+		OrderNo:     order.OrderNo,
+		OutOrderNo:  order.OutOrderNo,
+		Tax:         order.Tax, // 租户手续费（系统总利润）
+		MerchantTax: orderDetail.MerchantTax,
+		Money:       order.Money,
+		NotifyMoney: orderDetail.NotifyMoney,
+		RealMoney:   orderDetail.NotifyMoney - orderDetail.MerchantTax,
+		WriteoffID:  order.WriteoffID,
+		ProductID:   orderDetail.ProductID,
+		PayDatetime: time.Now(), // 默认使用当前时间作为支付时间
+		OrderID:     order.ID,
+		OrderBefore: orderBefore,
+	}
+	// 统计按订单创建日期计算
+	if order.CreateDatetime != nil {
+		data.CreateDatetime = *order.CreateDatetime
+	}
+	if order.PayDatetime != nil {
+		data.PayDatetime = *order.PayDatetime
+	}
+	if order.MerchantID != nil {
+		data.MerchantID = *order.MerchantID
+		// 租户ID为商户的 parent_id（使用缓存服务）
+		if cacheService != nil {
+			if merchant, _, err := cacheService.GetMerchantWithUser(ctx, *order.MerchantID); err == nil && merchant != nil {
+				data.TenantID = merchant.ParentID
+			}
+		}
+	}
+	if order.PayChannelID != nil {
+		data.ChannelID = *order.PayChannelID
+	}
+	if orderDetail.PluginID != nil {
+		data.PluginID = *orderDetail.PluginID
+	}
+
+	return data, nil
+}
+
 // NotifyOrderSuccess 触发订单成功钩子
 // 参考 Python: notify_order_success(**create_data)
 // 这会触发所有注册的回调函数
@@ -62,11 +118,73 @@ func (s *OrderSuccessHookService) NotifyOrderSuccess(ctx context.Context, data *
 	}
 
 	// 2. 触发统计回调
-	s.callbackStatistics(ctx, data)
+	// 启用消息队列时，统计由日统计事件（订单状态更新时写入发件箱）幂等更新，这里不再重复统计
+	if mq.GetBus().IsEnabled() {
+		return nil
+	}
+	if err := s.callbackStatistics(ctx, data); err != nil {
+		logger.Logger.Error("订单成功统计失败",
+			zap.String("order_no", data.OrderNo),
+			zap.Error(err))
+	}
 
 	return nil
 }
 
+// ProcessDayStatistics 消费日统计事件（实现 mq.DayStatisticsProcessor）
+// success 事件累加订单成功统计，refund 事件（订单状态为已退款）回退订单成功时累加的统计；
+// 统计记录 (订单ID, 状态) 与统计更新在同一事务中写入，记录已存在时跳过，重复投递不会重复统计；
+// 统计失败时事务回滚并返回错误，由消息队列重试
+func (s *OrderSuccessHookService) ProcessDayStatistics(ctx context.Context, msg *mq.DayStatisticsMessage) error {
+	if msg.StatisticsType != "success" && msg.StatisticsType != "refund" {
+		return nil
+	}
+
+	data, err := buildOrderSuccessData(ctx, nil, msg.OrderID, msg.Status)
+	if err != nil {
+		return err
+	}
+	data.TenantID = msg.TenantID
+
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		record := &models.OrderStatisticsRecord{
+			OrderID:        msg.OrderID,
+			OrderStatus:    msg.Status,
+			CreateDatetime: &now,
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if result.Error != nil {
+			return fmt.Errorf("写入订单统计记录失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			logger.Logger.Info("订单已统计，跳过重复的日统计事件",
+				zap.String("order_id", msg.OrderID),
+				zap.Int("status", msg.Status))
+			return nil
+		}
+
+		hook := &OrderSuccessHookService{pluginManager: s.pluginManager, statsDB: tx}
+		if msg.StatisticsType == "refund" {
+			return hook.callbackRefundStatistics(ctx, data)
+		}
+		return hook.callbackStatistics(ctx, data)
+	})
+}
+
+// statistics 统计服务（使用钩子的统计连接）
+func (s *OrderSuccessHookService) statistics() *StatisticsService {
+	return &StatisticsService{db: s.statsDB}
+}
+
+// conn 统计回调读取订单数据使用的连接
+func (s *OrderSuccessHookService) conn() *gorm.DB {
+	if s.statsDB != nil {
+		return s.statsDB
+	}
+	return database.DB
+}
+
 // callbackPluginSuccess 调用插件的 callback_success
 func (s *OrderSuccessHookService) callbackPluginSuccess(ctx context.Context, data *OrderSuccessData) error {
 	// 构建插件上下文（简化版，只包含必要信息）
@@ -119,32 +237,35 @@ func (s *OrderSuccessHookService) callbackPluginSuccess(ctx context.Context, dat
 
 // callbackStatistics 触发统计回调
 // 参考 Python: 各种 @order_success_handle() 装饰的回调函数
-func (s *OrderSuccessHookService) callbackStatistics(ctx context.Context, data *OrderSuccessData) {
+// 租户扣费、核销余额、商户预付款已在订单状态更新的事务中由账本记账（internal/ledger），这里只更新统计
+// 返回所有失败的统计（消费日统计事件时任一失败则整体回滚）
+func (s *OrderSuccessHookService) callbackStatistics(ctx context.Context, data *OrderSuccessData) error {
+	var errs []error
+
 	// 1. 通道统计
-	s.callbackPayChannelSuccess(ctx, data)
+	errs = append(errs, s.callbackPayChannelSuccess(ctx, data))
 
 	// 2. 商户统计
-	s.callbackMerchantSuccess(ctx, data)
+	errs = append(errs, s.callbackMerchantSuccess(ctx, data))
 
 	// 3. 租户统计
-	s.callbackTenantSuccess(ctx, data)
-
-	// 4. 租户扣费
-	s.callbackTenantTaxSuccess(ctx, data)
+	errs = append(errs, s.callbackTenantSuccess(ctx, data))
 
-	// 5. 核销统计
+	// 4. 核销统计
 	if data.WriteoffID != nil {
-		s.callbackWriteoffSuccess(ctx, data)
+		errs = append(errs, s.callbackWriteoffSuccess(ctx, data))
 	}
 
-	// 5.1 核销通道统计（需要从订单详情中获取最终核销手续费和实际扣除金额）
+	// 4.1 核销通道统计（需要从订单详情中获取最终核销手续费和实际扣除金额）
 	// 注意：订单成功和退款都使用同一个方法，但传入的参数不同
 	if data.WriteoffID != nil && data.ChannelID > 0 {
-		s.callbackWriteoffChannelSuccess(ctx, data)
+		errs = append(errs, s.callbackWriteoffChannelSuccess(ctx, data, false))
 	}
 
-	// 6. 全局统计
-	s.callbackDaySuccess(ctx, data)
+	// 5. 全局统计
+	errs = append(errs, s.callbackDaySuccess(ctx, data))
+
+	return errors.Join(errs...)
 }
 
 // simpleOrderContextForSuccess 简单的订单上下文（用于获取插件）
@@ -177,7 +298,7 @@ func (o *simpleOrderContextForSuccess) SetDomainURL(url string) {}
 
 // callbackPayChannelSuccess 通道统计回调
 // 参考 Python: callback_pay_channel_success
-func (s *OrderSuccessHookService) callbackPayChannelSuccess(ctx context.Context, data *OrderSuccessData) {
+func (s *OrderSuccessHookService) callbackPayChannelSuccess(ctx context.Context, data *OrderSuccessData) error {
 	// 记录日志，帮助调试 tax 值
 	logger.Logger.Info("通道统计回调",
 		zap.String("order_no", data.OrderNo),
@@ -185,7 +306,7 @@ func (s *OrderSuccessHookService) callbackPayChannelSuccess(ctx context.Context,
 		zap.Int("tax", data.Tax),
 		zap.Int("notify_money", data.NotifyMoney))
 
-	statsService := NewStatisticsService()
+	statsService := s.statistics()
 	stats := &models.PayChannelDayStatistics{
 		PayChannelID: &data.ChannelID,
 		TenantID:     &data.TenantID,
@@ -202,13 +323,15 @@ func (s *OrderSuccessHookService) callbackPayChannelSuccess(ctx context.Context,
 			zap.String("order_no", data.OrderNo),
 			zap.Int("tax", data.Tax),
 			zap.Error(err))
+		return fmt.Errorf("通道统计失败: %w", err)
 	}
+	return nil
 }
 
 // callbackMerchantSuccess 商户统计回调
 // 参考 Python: callback_merchant_success
-func (s *OrderSuccessHookService) callbackMerchantSuccess(ctx context.Context, data *OrderSuccessData) {
-	statsService := NewStatisticsService()
+func (s *OrderSuccessHookService) callbackMerchantSuccess(ctx context.Context, data *OrderSuccessData) error {
+	statsService := s.statistics()
 	stats := &models.MerchantDayStatistics{
 		MerchantID: &data.MerchantID,
 	}
@@ -221,22 +344,16 @@ func (s *OrderSuccessHookService) callbackMerchantSuccess(ctx context.Context, d
 		logger.Logger.Error("商户统计失败",
 			zap.String("order_no", data.OrderNo),
 			zap.Error(err))
-		return
+		return fmt.Errorf("商户统计失败: %w", err)
 	}
 
-	// 更新商户预付款（扣除实际收入）
-	// 参考 Python: update_merchant_pre(-real_money, merchant_id)
-	// 注意：这里需要实现商户预付款更新逻辑，暂时先记录日志
-	logger.Logger.Info("商户统计成功，需要更新商户预付款",
-		zap.String("order_no", data.OrderNo),
-		zap.Int64("merchant_id", data.MerchantID),
-		zap.Int("real_money", data.RealMoney))
-	// TODO: 实现商户预付款更新
+	// 商户预付款（参考 Python: update_merchant_pre(-real_money, merchant_id)）已在订单状态更新时由账本记账
+	return nil
 }
 
 // callbackTenantSuccess 租户统计回调
 // 参考 Python: callback_tenant_success
-func (s *OrderSuccessHookService) callbackTenantSuccess(ctx context.Context, data *OrderSuccessData) {
+func (s *OrderSuccessHookService) callbackTenantSuccess(ctx context.Context, data *OrderSuccessData) error {
 	// 记录日志，帮助调试
 	logger.Logger.Info("更新租户日统计",
 		zap.String("order_no", data.OrderNo),
@@ -247,10 +364,10 @@ func (s *OrderSuccessHookService) callbackTenantSuccess(ctx context.Context, dat
 	if data.TenantID == 0 {
 		logger.Logger.Warn("租户ID为0，跳过租户统计",
 			zap.String("order_no", data.OrderNo))
-		return
+		return nil
 	}
 
-	statsService := NewStatisticsService()
+	statsService := s.statistics()
 	stats := &models.TenantDayStatistics{
 		TenantID: &data.TenantID,
 	}
@@ -261,44 +378,21 @@ func (s *OrderSuccessHookService) callbackTenantSuccess(ctx context.Context, dat
 			zap.Int64("tenant_id", data.TenantID),
 			zap.Int("tax", data.Tax),
 			zap.Error(err))
-	} else {
-		logger.Logger.Info("租户统计更新成功",
-			zap.String("order_no", data.OrderNo),
-			zap.Int64("tenant_id", data.TenantID),
-			zap.Int("tax", data.Tax),
-			zap.Int64("notify_money", int64(data.NotifyMoney)))
-	}
-}
-
-// callbackTenantTaxSuccess 租户扣费回调
-// 参考 Python: callback_tenant_tax_success
-func (s *OrderSuccessHookService) callbackTenantTaxSuccess(ctx context.Context, data *OrderSuccessData) {
-	// 如果订单之前的状态不是7（已关闭），删除预扣
-	if data.OrderBefore != 7 {
-		// TODO: 实现删除预扣逻辑（take_up_tax）
-		logger.Logger.Info("删除租户预扣",
-			zap.String("order_no", data.OrderNo),
-			zap.Int64("tenant_id", data.TenantID),
-			zap.Int("tax", data.Tax))
-	}
-
-	// 扣除手续费
-	// 参考 Python: tenant_success_tax(tenant_id, tax, order_id, channel_id, reorder)
-	tenantService := NewTenantService()
-	if err := tenantService.DeductTax(ctx, data.TenantID, data.Tax, data.OrderID, data.ChannelID); err != nil {
-		logger.Logger.Error("租户扣费失败",
-			zap.String("order_no", data.OrderNo),
-			zap.Int64("tenant_id", data.TenantID),
-			zap.Int("tax", data.Tax),
-			zap.Error(err))
+		return fmt.Errorf("租户统计失败: %w", err)
 	}
+	logger.Logger.Info("租户统计更新成功",
+		zap.String("order_no", data.OrderNo),
+		zap.Int64("tenant_id", data.TenantID),
+		zap.Int("tax", data.Tax),
+		zap.Int64("notify_money", int64(data.NotifyMoney)))
+	return nil
 }
 
 // callbackWriteoffSuccess 核销统计回调
 // 参考 Python: callback_writeoff_success
-func (s *OrderSuccessHookService) callbackWriteoffSuccess(ctx context.Context, data *OrderSuccessData) {
+func (s *OrderSuccessHookService) callbackWriteoffSuccess(ctx context.Context, data *OrderSuccessData) error {
 	if data.WriteoffID == nil {
-		return
+		return nil
 	}
 
 	// 记录日志，帮助调试 tax 值
@@ -309,7 +403,7 @@ func (s *OrderSuccessHookService) callbackWriteoffSuccess(ctx context.Context, d
 		zap.Int("tax", data.Tax),
 		zap.Int("notify_money", data.NotifyMoney))
 
-	statsService := NewStatisticsService()
+	statsService := s.statistics()
 	stats := &models.WriteOffDayStatistics{
 		WriteoffID: data.WriteoffID,
 	}
@@ -319,7 +413,7 @@ func (s *OrderSuccessHookService) callbackWriteoffSuccess(ctx context.Context, d
 			zap.String("order_no", data.OrderNo),
 			zap.Int("tax", data.Tax),
 			zap.Error(err))
-		return
+		return fmt.Errorf("核销统计失败: %w", err)
 	}
 
 	// 更新核销预付款
@@ -329,6 +423,7 @@ func (s *OrderSuccessHookService) callbackWriteoffSuccess(ctx context.Context, d
 		zap.Int64("writeoff_id", *data.WriteoffID),
 		zap.Int("money", data.Money))
 	// TODO: 实现核销预付款更新
+	return nil
 }
 
 // callbackWriteoffChannelSuccess 核销通道统计回调
@@ -336,9 +431,9 @@ func (s *OrderSuccessHookService) callbackWriteoffSuccess(ctx context.Context, d
 // 根据文档：
 // - 订单成功时：success_money += real_money, total_tax += parent_tax_money
 // - 订单退款时：success_money -= flow.money, total_tax -= flow.tax（使用负数）
-func (s *OrderSuccessHookService) callbackWriteoffChannelSuccess(ctx context.Context, data *OrderSuccessData) {
+func (s *OrderSuccessHookService) callbackWriteoffChannelSuccess(ctx context.Context, data *OrderSuccessData, refund bool) error {
 	if data.WriteoffID == nil || data.ChannelID == 0 {
-		return
+		return nil
 	}
 
 	// 查询订单详情以获取最终核销手续费和实际扣除金额
@@ -351,14 +446,14 @@ func (s *OrderSuccessHookService) callbackWriteoffChannelSuccess(ctx context.Con
 	// 根据文档：订单退款时，只有 order_before in [4, 6] 的订单才能退款（成功订单）
 	// 订单成功时：直接查找跑量流水
 	// 订单退款时：也需要查找跑量流水（订单成功时的流水记录）
-	if err := database.DB.Where("order_id = ? AND writeoff_id = ? AND flow_type = ?", data.OrderID, *data.WriteoffID, flowType).
+	if err := s.conn().Where("order_id = ? AND writeoff_id = ? AND flow_type = ?", data.OrderID, *data.WriteoffID, flowType).
 		First(&cashflow).Error; err != nil {
 		logger.Logger.Warn("查询核销流水失败，跳过核销通道统计",
 			zap.String("order_no", data.OrderNo),
 			zap.String("order_id", data.OrderID),
 			zap.Int64("writeoff_id", *data.WriteoffID),
 			zap.Error(err))
-		return
+		return nil
 	}
 
 	// real_money = -cashflow.ChangeMoney（因为 ChangeMoney 是负数，表示扣减）
@@ -373,60 +468,61 @@ func (s *OrderSuccessHookService) callbackWriteoffChannelSuccess(ctx context.Con
 
 	// 判断是订单成功还是退款
 	// 根据文档：订单退款时，使用负数更新统计
-	// 如果 OrderBefore 是成功状态（4=支付成功，6=支付成功通知已返回），且当前是退款状态，则使用负数
-	// 但这里我们通过查询订单状态来判断
-	var order models.Order
-	if err := database.DB.Select("order_status").Where("id = ?", data.OrderID).First(&order).Error; err == nil {
-		// 如果是退款状态，使用负数
-		if order.OrderStatus == models.OrderStatusRefunded {
-			// 订单退款：使用负数回退统计
-			realMoney = -realMoney
-			// 计算 parent_tax_money：从 cashflow.Tax（费率）和订单金额计算
-			// parent_tax_money = int(cashflow.Tax * 订单金额 / 100)
-			parentTaxMoney = int64(cashflow.Tax * float64(data.Money) / 100.0)
-			parentTaxMoney = -parentTaxMoney // 使用负数
-
-			logger.Logger.Info("核销通道统计回调（退款）",
-				zap.String("order_no", data.OrderNo),
-				zap.Int64("writeoff_id", *data.WriteoffID),
-				zap.Int64("channel_id", data.ChannelID),
-				zap.Int64("real_money", -realMoney),            // 显示原始值
-				zap.Int64("parent_tax_money", -parentTaxMoney), // 显示原始值
-				zap.Int("money", data.Money))
-		} else {
-			// 订单成功：使用正数
-			logger.Logger.Info("核销通道统计回调（成功）",
-				zap.String("order_no", data.OrderNo),
-				zap.Int64("writeoff_id", *data.WriteoffID),
-				zap.Int64("channel_id", data.ChannelID),
-				zap.Int64("real_money", realMoney),
-				zap.Int64("parent_tax_money", parentTaxMoney),
-				zap.Int("money", data.Money))
+	// 由调用方传入，不查询订单当前状态（日统计事件消费时订单可能已退款，仍须按成功统计）
+	var updateFields map[string]interface{}
+	if refund {
+		// 订单退款：使用负数回退统计，成功笔数同时减一
+		updateFields = map[string]interface{}{
+			"success_count": gorm.Expr("success_count - 1"),
 		}
+		realMoney = -realMoney
+		// 计算 parent_tax_money：从 cashflow.Tax（费率）和订单金额计算
+		// parent_tax_money = int(cashflow.Tax * 订单金额 / 100)
+		parentTaxMoney = int64(cashflow.Tax * float64(data.Money) / 100.0)
+		parentTaxMoney = -parentTaxMoney // 使用负数
+
+		logger.Logger.Info("核销通道统计回调（退款）",
+			zap.String("order_no", data.OrderNo),
+			zap.Int64("writeoff_id", *data.WriteoffID),
+			zap.Int64("channel_id", data.ChannelID),
+			zap.Int64("real_money", -realMoney),            // 显示原始值
+			zap.Int64("parent_tax_money", -parentTaxMoney), // 显示原始值
+			zap.Int("money", data.Money))
+	} else {
+		// 订单成功：使用正数
+		logger.Logger.Info("核销通道统计回调（成功）",
+			zap.String("order_no", data.OrderNo),
+			zap.Int64("writeoff_id", *data.WriteoffID),
+			zap.Int64("channel_id", data.ChannelID),
+			zap.Int64("real_money", realMoney),
+			zap.Int64("parent_tax_money", parentTaxMoney),
+			zap.Int("money", data.Money))
 	}
 
-	statsService := NewStatisticsService()
+	statsService := s.statistics()
 	stats := &models.WriteOffChannelDayStatistics{
 		WriteoffID:   data.WriteoffID,
 		PayChannelID: &data.ChannelID,
 	}
 
-	if err := statsService.SuccessBaseDayStatistics(ctx, stats, realMoney, parentTaxMoney, data.CreateDatetime, nil); err != nil {
+	if err := statsService.SuccessBaseDayStatistics(ctx, stats, realMoney, parentTaxMoney, data.CreateDatetime, updateFields); err != nil {
 		logger.Logger.Error("核销通道统计失败",
 			zap.String("order_no", data.OrderNo),
 			zap.Int64("writeoff_id", *data.WriteoffID),
 			zap.Int64("channel_id", data.ChannelID),
 			zap.Error(err))
+		return fmt.Errorf("核销通道统计失败: %w", err)
 	}
+	return nil
 }
 
 // callbackDaySuccess 全局日统计回调
 // 参考 Python: callback_day_success
-func (s *OrderSuccessHookService) callbackDaySuccess(ctx context.Context, data *OrderSuccessData) {
+func (s *OrderSuccessHookService) callbackDaySuccess(ctx context.Context, data *OrderSuccessData) error {
 	// 查询订单设备详情以获取设备类型
 	var deviceDetail models.OrderDeviceDetail
 	deviceType := models.DeviceTypeUnknown // 默认未知设备
-	if err := database.DB.Where("order_id = ?", data.OrderID).First(&deviceDetail).Error; err == nil {
+	if err := s.conn().Where("order_id = ?", data.OrderID).First(&deviceDetail).Error; err == nil {
 		deviceType = deviceDetail.DeviceType
 	}
 
@@ -451,7 +547,7 @@ func (s *OrderSuccessHookService) callbackDaySuccess(ctx context.Context, data *
 		zap.Int("device_type", deviceType),
 		zap.Int("order_tax_from_data", data.Tax))
 
-	statsService := NewStatisticsService()
+	statsService := s.statistics()
 	stats := &models.DayStatistics{}
 
 	// 更新成功统计（包含设备统计和手续费）
@@ -461,10 +557,180 @@ func (s *OrderSuccessHookService) callbackDaySuccess(ctx context.Context, data *
 			zap.String("order_no", data.OrderNo),
 			zap.Int("tax", data.Tax),
 			zap.Error(err))
-	} else {
-		logger.Logger.Info("全局日统计更新成功",
+		return fmt.Errorf("全局日统计失败: %w", err)
+	}
+	logger.Logger.Info("全局日统计更新成功",
+		zap.String("order_no", data.OrderNo),
+		zap.Int("tax", data.Tax),
+		zap.Int64("notify_money", int64(data.NotifyMoney)))
+	return nil
+}
+
+// callbackRefundStatistics 订单退款统计回调（回退订单成功时累加的统计）
+// 租户手续费、核销余额、商户预付款已在订单状态更新为已退款的事务中由账本冲回，这里只回退统计
+// 参考 Python: notify_order_refund 和 @order_refund_handle() 装饰器
+// 注意：只在订单全额退款（状态变更为已退款）后调用，部分退款不回退统计；返回所有失败的统计（任一失败则整体回滚）
+func (s *OrderSuccessHookService) callbackRefundStatistics(ctx context.Context, data *OrderSuccessData) error {
+	var errs []error
+
+	// 1. 通道统计
+	errs = append(errs, s.callbackPayChannelRefund(ctx, data))
+
+	// 2. 商户统计
+	errs = append(errs, s.callbackMerchantRefund(ctx, data))
+
+	// 3. 租户统计
+	errs = append(errs, s.callbackTenantRefund(ctx, data))
+
+	// 4. 核销统计
+	if data.WriteoffID != nil {
+		errs = append(errs, s.callbackWriteoffRefund(ctx, data))
+	}
+
+	// 4.1 核销通道统计（使用负数回退）
+	if data.WriteoffID != nil && data.ChannelID > 0 {
+		errs = append(errs, s.callbackWriteoffChannelSuccess(ctx, data, true))
+	}
+
+	// 5. 全局统计
+	errs = append(errs, s.callbackDayRefund(ctx, data))
+
+	return errors.Join(errs...)
+}
+
+// callbackPayChannelRefund 通道统计退款回调
+// 参考 Python: callback_pay_channel_refund
+func (s *OrderSuccessHookService) callbackPayChannelRefund(ctx context.Context, data *OrderSuccessData) error {
+	statsService := s.statistics()
+	stats := &models.PayChannelDayStatistics{
+		PayChannelID: &data.ChannelID,
+		TenantID:     &data.TenantID,
+		MerchantID:   &data.MerchantID,
+		WriteoffID:   data.WriteoffID,
+	}
+
+	updateFields := map[string]interface{}{
+		"success_count": gorm.Expr("success_count - 1"),
+		"real_money":    gorm.Expr("real_money - ?", data.RealMoney),
+	}
+
+	if err := statsService.SuccessBaseDayStatistics(ctx, stats, -int64(data.NotifyMoney), -int64(data.Tax), data.CreateDatetime, updateFields); err != nil {
+		logger.Logger.Error("通道统计回退失败",
 			zap.String("order_no", data.OrderNo),
-			zap.Int("tax", data.Tax),
-			zap.Int64("notify_money", int64(data.NotifyMoney)))
+			zap.Error(err))
+		return fmt.Errorf("通道统计回退失败: %w", err)
+	}
+	return nil
+}
+
+// callbackMerchantRefund 商户统计退款回调
+// 参考 Python: callback_merchant_refund
+func (s *OrderSuccessHookService) callbackMerchantRefund(ctx context.Context, data *OrderSuccessData) error {
+	statsService := s.statistics()
+	stats := &models.MerchantDayStatistics{
+		MerchantID: &data.MerchantID,
 	}
+
+	updateFields := map[string]interface{}{
+		"success_count": gorm.Expr("success_count - 1"),
+		"real_money":    gorm.Expr("real_money - ?", data.RealMoney),
+	}
+
+	if err := statsService.SuccessBaseDayStatistics(ctx, stats, -int64(data.NotifyMoney), -int64(data.MerchantTax), data.CreateDatetime, updateFields); err != nil {
+		logger.Logger.Error("商户统计回退失败",
+			zap.String("order_no", data.OrderNo),
+			zap.Error(err))
+		return fmt.Errorf("商户统计回退失败: %w", err)
+	}
+	return nil
+}
+
+// callbackTenantRefund 租户统计退款回调
+// 参考 Python: callback_tenant_refund
+func (s *OrderSuccessHookService) callbackTenantRefund(ctx context.Context, data *OrderSuccessData) error {
+	if data.TenantID == 0 {
+		logger.Logger.Warn("租户ID为0，跳过租户统计回退",
+			zap.String("order_no", data.OrderNo))
+		return nil
+	}
+
+	statsService := s.statistics()
+	stats := &models.TenantDayStatistics{
+		TenantID: &data.TenantID,
+	}
+
+	updateFields := map[string]interface{}{
+		"success_count": gorm.Expr("success_count - 1"),
+	}
+
+	if err := statsService.SuccessBaseDayStatistics(ctx, stats, -int64(data.NotifyMoney), -int64(data.Tax), data.CreateDatetime, updateFields); err != nil {
+		logger.Logger.Error("租户统计回退失败",
+			zap.String("order_no", data.OrderNo),
+			zap.Int64("tenant_id", data.TenantID),
+			zap.Error(err))
+		return fmt.Errorf("租户统计回退失败: %w", err)
+	}
+	return nil
+}
+
+// callbackWriteoffRefund 核销统计退款回调
+// 参考 Python: callback_writeoff_refund
+func (s *OrderSuccessHookService) callbackWriteoffRefund(ctx context.Context, data *OrderSuccessData) error {
+	if data.WriteoffID == nil {
+		return nil
+	}
+
+	statsService := s.statistics()
+	stats := &models.WriteOffDayStatistics{
+		WriteoffID: data.WriteoffID,
+	}
+
+	updateFields := map[string]interface{}{
+		"success_count": gorm.Expr("success_count - 1"),
+	}
+
+	if err := statsService.SuccessBaseDayStatistics(ctx, stats, -int64(data.Money), -int64(data.Tax), data.CreateDatetime, updateFields); err != nil {
+		logger.Logger.Error("核销统计回退失败",
+			zap.String("order_no", data.OrderNo),
+			zap.Int64("writeoff_id", *data.WriteoffID),
+			zap.Error(err))
+		return fmt.Errorf("核销统计回退失败: %w", err)
+	}
+	return nil
+}
+
+// callbackDayRefund 全局日统计退款回调
+// 参考 Python: callback_day_refund
+func (s *OrderSuccessHookService) callbackDayRefund(ctx context.Context, data *OrderSuccessData) error {
+	// 查询订单设备详情以获取设备类型
+	var deviceDetail models.OrderDeviceDetail
+	deviceType := models.DeviceTypeUnknown // 默认未知设备
+	if err := s.conn().Where("order_id = ?", data.OrderID).First(&deviceDetail).Error; err == nil {
+		deviceType = deviceDetail.DeviceType
+	}
+
+	updateFields := map[string]interface{}{
+		"success_count": gorm.Expr("success_count - 1"),
+	}
+	switch deviceType {
+	case models.DeviceTypeAndroid:
+		updateFields["android_count"] = gorm.Expr("android_count - 1")
+	case models.DeviceTypeIOS:
+		updateFields["ios_count"] = gorm.Expr("ios_count - 1")
+	case models.DeviceTypePC:
+		updateFields["pc_count"] = gorm.Expr("pc_count - 1")
+	default:
+		updateFields["unknown_count"] = gorm.Expr("unknown_count - 1")
+	}
+
+	statsService := s.statistics()
+	stats := &models.DayStatistics{}
+
+	if err := statsService.SuccessBaseDayStatistics(ctx, stats, -int64(data.NotifyMoney), -int64(data.Tax), data.CreateDatetime, updateFields); err != nil {
+		logger.Logger.Error("全局日统计回退失败",
+			zap.String("order_no", data.OrderNo),
+			zap.Error(err))
+		return fmt.Errorf("全局日统计回退失败: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/mq"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// setupStatisticsTestDB 创建统计相关的表和线上唯一索引
+func setupStatisticsTestDB(t *testing.T) *gorm.DB {
+	db := setupTestDB(t)
+	assert.NoError(t, db.AutoMigrate(
+		&models.OrderDeviceDetail{},
+		&models.OrderStatisticsRecord{},
+		&models.DayStatistics{},
+		&models.PayChannelDayStatistics{},
+		&models.MerchantDayStatistics{},
+		&models.TenantDayStatistics{},
+	))
+	// 线上表的唯一索引（模型中未声明），统计使用 ON CONFLICT 累加
+	db.Exec("CREATE UNIQUE INDEX uk_channel_stats ON dvadmin_day_statistics_pay_channel (date, pay_channel_id, tenant_id, merchant_id)")
+	db.Exec("CREATE UNIQUE INDEX uk_merchant_stats ON dvadmin_day_statistics_merchant (date, merchant_id)")
+	db.Exec("CREATE UNIQUE INDEX uk_tenant_stats ON dvadmin_day_statistics_tenant (date, tenant_id)")
+
+	oldDB, oldLogger := database.DB, logger.Logger
+	database.DB, logger.Logger = db, zap.NewNop()
+	t.Cleanup(func() {
+		database.DB, logger.Logger = oldDB, oldLogger
+	})
+	return db
+}
+
+func TestProcessDayStatisticsIdempotent(t *testing.T) {
+	db := setupStatisticsTestDB(t)
+
+	merchantID, channelID := int64(2), int64(3)
+	created := time.Now()
+	db.Create(&models.Order{ID: "O1", OrderNo: "PAY001", Money: 10000, Tax: 300, MerchantID: &merchantID, PayChannelID: &channelID,
+		OrderStatus: models.OrderStatusPaidNoNotify, CreateDatetime: &created})
+	db.Create(&models.OrderDetail{OrderID: "O1", ProductID: "P1", NotifyMoney: 10000, MerchantTax: 500})
+
+	msg := &mq.DayStatisticsMessage{OrderID: "O1", Status: models.OrderStatusPaidNoNotify, TenantID: 1, StatisticsType: "success"}
+	s := &OrderSuccessHookService{}
+	// 重复投递只统计一次
+	assert.NoError(t, s.ProcessDayStatistics(context.Background(), msg))
+	assert.NoError(t, s.ProcessDayStatistics(context.Background(), msg))
+
+	var day models.DayStatistics
+	assert.NoError(t, db.First(&day).Error)
+	assert.Equal(t, 1, day.SuccessCount)
+	assert.Equal(t, int64(10000), day.SuccessMoney)
+
+	var merchant models.MerchantDayStatistics
+	assert.NoError(t, db.Where("merchant_id = ?", merchantID).First(&merchant).Error)
+	assert.Equal(t, 1, merchant.SuccessCount)
+	assert.Equal(t, int64(500), merchant.TotalTax)
+
+	var tenant models.TenantDayStatistics
+	assert.NoError(t, db.Where("tenant_id = ?", 1).First(&tenant).Error)
+	assert.Equal(t, int64(300), tenant.TotalTax)
+
+	var records int64
+	db.Model(&models.OrderStatisticsRecord{}).Count(&records)
+	assert.Equal(t, int64(1), records)
+}
+
+func TestProcessDayStatisticsRefundIdempotent(t *testing.T) {
+	db := setupStatisticsTestDB(t)
+
+	merchantID, channelID := int64(2), int64(3)
+	created := time.Now()
+	db.Create(&models.Order{ID: "O1", OrderNo: "PAY001", Money: 10000, Tax: 300, MerchantID: &merchantID, PayChannelID: &channelID,
+		OrderStatus: models.OrderStatusRefunded, CreateDatetime: &created})
+	db.Create(&models.OrderDetail{OrderID: "O1", ProductID: "P1", NotifyMoney: 10000, MerchantTax: 500})
+
+	s := &OrderSuccessHookService{}
+	ctx := context.Background()
+	assert.NoError(t, s.ProcessDayStatistics(ctx, &mq.DayStatisticsMessage{OrderID: "O1", Status: models.OrderStatusPaidNoNotify, TenantID: 1, StatisticsType: "success"}))
+
+	// 退款事件重复投递只回退一次
+	refund := &mq.DayStatisticsMessage{OrderID: "O1", Status: models.OrderStatusRefunded, TenantID: 1, StatisticsType: "refund"}
+	assert.NoError(t, s.ProcessDayStatistics(ctx, refund))
+	assert.NoError(t, s.ProcessDayStatistics(ctx, refund))
+
+	var day models.DayStatistics
+	assert.NoError(t, db.First(&day).Error)
+	assert.Equal(t, 0, day.SuccessCount)
+	assert.Equal(t, int64(0), day.SuccessMoney)
+
+	var tenant models.TenantDayStatistics
+	assert.NoError(t, db.Where("tenant_id = ?", 1).First(&tenant).Error)
+	assert.Equal(t, 0, tenant.SuccessCount)
+	assert.Equal(t, int64(0), tenant.TotalTax)
+
+	var records int64
+	db.Model(&models.OrderStatisticsRecord{}).Where("order_id = ?", "O1").Count(&records)
+	assert.Equal(t, int64(2), records)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/golang-pay-core/config"
 	"github.com/golang-pay-core/internal/database"
 	"github.com/golang-pay-core/internal/logger"
 	"github.com/golang-pay-core/internal/models"
@@ -12,29 +13,46 @@ import (
 	"go.uber.org/zap"
 )
 
-// OrderTimeoutService 订单超时检查服务
-// 定时扫描超时的订单并更新状态
+// OrderTimeoutService 订单超时对账服务
+// 订单超时主要由延迟消息（消息总线）或 Redis 超时定时队列处理，这里低频扫描数据库作为对账：
+// 关闭遗漏的超时订单，并将未超时的订单补充到定时队列（Redis 数据丢失时恢复）
 type OrderTimeoutService struct {
 	orderService *OrderService
+	scanInterval time.Duration
+	scanLookback time.Duration
 	stopChan     chan struct{}
 }
 
-// NewOrderTimeoutService 创建订单超时检查服务
+// NewOrderTimeoutService 创建订单超时对账服务
 func NewOrderTimeoutService() *OrderTimeoutService {
+	cfg := config.OrderTimeoutConfig{}
+	if config.Cfg != nil {
+		cfg = config.Cfg.OrderTimeout
+	}
+	if cfg.ScanInterval <= 0 {
+		cfg.ScanInterval = 5 * time.Minute
+	}
+	if cfg.ScanLookback <= 0 {
+		cfg.ScanLookback = 24 * time.Hour
+	}
+
 	return &OrderTimeoutService{
 		orderService: NewOrderService(),
+		scanInterval: cfg.ScanInterval,
+		scanLookback: cfg.ScanLookback,
 		stopChan:     make(chan struct{}),
 	}
 }
 
-// Start 启动超时检查服务
-// 每30秒执行一次超时检查
+// Start 启动超时对账服务
+// 每 scanInterval 执行一次对账扫描
 func (s *OrderTimeoutService) Start(ctx context.Context) {
-	// 每30秒执行一次超时检查
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(s.scanInterval)
 	defer ticker.Stop()
 
-	logger.Logger.Info("订单超时检查服务已启动（每30秒检查一次）")
+	logger.Logger.Info("订单超时对账服务已启动",
+		zap.Duration("scan_interval", s.scanInterval),
+		zap.Duration("scan_lookback", s.scanLookback))
 
 	// 立即执行一次
 	s.checkExpiredOrders(ctx)
@@ -42,25 +60,25 @@ func (s *OrderTimeoutService) Start(ctx context.Context) {
 	for {
 		select {
 		case <-ticker.C:
-			// 定时执行超时检查
+			// 定时执行对账扫描
 			s.checkExpiredOrders(ctx)
 		case <-s.stopChan:
-			logger.Logger.Info("订单超时检查服务已停止")
+			logger.Logger.Info("订单超时对账服务已停止")
 			return
 		case <-ctx.Done():
-			logger.Logger.Info("订单超时检查服务已停止（上下文取消）")
+			logger.Logger.Info("订单超时对账服务已停止（上下文取消）")
 			return
 		}
 	}
 }
 
-// Stop 停止超时检查服务
+// Stop 停止超时对账服务
 func (s *OrderTimeoutService) Stop() {
 	close(s.stopChan)
 }
 
-// checkExpiredOrders 检查并处理超时的订单（兜底机制）
-// 如果延迟消息失败或服务重启，定时扫描会作为兜底
+// checkExpiredOrders 检查并处理超时的订单（对账机制）
+// 如果延迟消息或定时队列处理失败，对账扫描会作为兜底
 func (s *OrderTimeoutService) checkExpiredOrders(ctx context.Context) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -72,9 +90,11 @@ func (s *OrderTimeoutService) checkExpiredOrders(ctx context.Context) {
 	now := time.Now()
 
 	// 查询需要检查的订单（生成中或支付中状态）
-	// 只查询最近2小时内的订单，避免扫描过多数据
-	// 注意：这是兜底机制，延迟消息是主要方式
-	twoHoursAgo := now.Add(-2 * time.Hour)
+	// 只查询 scanLookback 内创建的订单，避免扫描过多数据
+	// 注意：这是对账机制，延迟消息和定时队列是主要方式
+	lookback := now.Add(-s.scanLookback)
+	// 消息总线不可用时订单超时依赖定时队列，对账时补充队列中缺失的订单
+	requeue := s.orderService.bus == nil && orderTimeoutQueueEnabled()
 	var orders []struct {
 		ID             string
 		OrderNo        string
@@ -94,7 +114,7 @@ func (s *OrderTimeoutService) checkExpiredOrders(ctx context.Context) {
 			models.OrderStatusGenerating, // 0 - 生成中
 			models.OrderStatusPaying,     // 2 - 等待支付
 		}).
-		Where("dvadmin_order.create_datetime >= ?", twoHoursAgo).
+		Where("dvadmin_order.create_datetime >= ?", lookback).
 		Where("dvadmin_order_detail.plugin_id IS NOT NULL").
 		Scan(&orders).Error; err != nil {
 		logger.Logger.Error("查询待检查订单失败", zap.Error(err))
@@ -105,11 +125,12 @@ func (s *OrderTimeoutService) checkExpiredOrders(ctx context.Context) {
 		return
 	}
 
-	logger.Logger.Debug("开始检查订单超时（兜底机制）",
+	logger.Logger.Debug("开始检查订单超时（对账机制）",
 		zap.Int("order_count", len(orders)))
 
 	// 检查每个订单是否超时
 	expiredCount := 0
+	requeuedCount := 0
 	for _, order := range orders {
 		if order.CreateDatetime == nil || order.PluginID == nil {
 			continue
@@ -123,44 +144,56 @@ func (s *OrderTimeoutService) checkExpiredOrders(ctx context.Context) {
 
 		expireTime := order.CreateDatetime.Add(time.Duration(timeoutSeconds) * time.Second)
 
-		// 检查是否已过期
-		if now.After(expireTime) {
-			// 订单已过期，使用统一的超时处理函数（与延迟消息使用相同的逻辑）
-			// 参考 Python: timeout_check 的逻辑
-			logger.Logger.Info("发现过期订单，开始处理",
+		// 未过期的订单确保在定时队列中
+		if !now.After(expireTime) {
+			if requeue {
+				if added, err := ensureOrderTimeoutScheduled(ctx, order.ID, expireTime); err != nil {
+					logger.Logger.Warn("补充订单到超时定时队列失败",
+						zap.String("order_id", order.ID),
+						zap.Error(err))
+				} else if added {
+					requeuedCount++
+				}
+			}
+			continue
+		}
+
+		// 订单已过期，使用统一的超时处理函数（与延迟消息使用相同的逻辑）
+		// 参考 Python: timeout_check 的逻辑
+		logger.Logger.Info("发现过期订单，开始处理",
+			zap.String("order_id", order.ID),
+			zap.String("order_no", order.OrderNo),
+			zap.Int("order_status", order.OrderStatus),
+			zap.Time("create_time", *order.CreateDatetime),
+			zap.Time("expire_time", expireTime),
+			zap.Int("timeout_seconds", timeoutSeconds),
+			zap.Duration("overdue_time", now.Sub(expireTime)))
+
+		if err := mq.HandleOrderTimeout(ctx, order.OrderNo); err != nil {
+			logger.Logger.Error("处理订单超时失败",
+				zap.String("order_id", order.ID),
+				zap.String("order_no", order.OrderNo),
+				zap.Int("order_status", order.OrderStatus),
+				zap.Error(err))
+			// 即使失败也计入 expiredCount，因为确实过期了
+			expiredCount++
+		} else {
+			expiredCount++
+			logger.Logger.Info("订单已超时，处理完成（通过对账扫描兜底）",
 				zap.String("order_id", order.ID),
 				zap.String("order_no", order.OrderNo),
 				zap.Int("order_status", order.OrderStatus),
 				zap.Time("create_time", *order.CreateDatetime),
 				zap.Time("expire_time", expireTime),
-				zap.Int("timeout_seconds", timeoutSeconds),
-				zap.Duration("overdue_time", now.Sub(expireTime)))
-
-			if err := mq.HandleOrderTimeout(ctx, order.OrderNo); err != nil {
-				logger.Logger.Error("处理订单超时失败",
-					zap.String("order_id", order.ID),
-					zap.String("order_no", order.OrderNo),
-					zap.Int("order_status", order.OrderStatus),
-					zap.Error(err))
-				// 即使失败也计入 expiredCount，因为确实过期了
-				expiredCount++
-			} else {
-				expiredCount++
-				logger.Logger.Info("订单已超时，处理完成（通过定时扫描兜底）",
-					zap.String("order_id", order.ID),
-					zap.String("order_no", order.OrderNo),
-					zap.Int("order_status", order.OrderStatus),
-					zap.Time("create_time", *order.CreateDatetime),
-					zap.Time("expire_time", expireTime),
-					zap.Int("timeout_seconds", timeoutSeconds))
-			}
+				zap.Int("timeout_seconds", timeoutSeconds))
 		}
 	}
 
-	if expiredCount > 0 {
-		logger.Logger.Info("订单超时检查完成（兜底机制）",
+	if expiredCount > 0 || requeuedCount > 0 {
+		logger.Logger.Info("订单超时检查完成（对账机制）",
 			zap.Int("total_checked", len(orders)),
 			zap.Int("expired_count", expiredCount),
-			zap.String("note", "建议检查延迟消息是否正常工作"))
+			zap.Int("requeued_count", requeuedCount),
+			zap.String("note", "建议检查延迟消息和超时定时队列是否正常工作"))
 	}
 }
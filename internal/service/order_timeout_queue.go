@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-pay-core/config"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/mq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Redis 键
+const (
+	orderTimeoutQueueKey = "order:timeout:queue" // 超时定时队列（ZSET：订单ID -> 超时时间毫秒）
+)
+
+var (
+	// 定时队列处理结果
+	orderTimeoutQueueTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_timeout_queue_total",
+			Help: "订单超时定时队列处理次数（expired 已处理、retry 处理失败稍后重试）",
+		},
+		[]string{"result"},
+	)
+
+	// 定时队列深度
+	orderTimeoutQueueDepth = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "order_timeout_queue_depth",
+			Help: "订单超时定时队列中的订单数",
+		},
+	)
+)
+
+// claimExpiredOrdersScript 领取到期订单：将分数推迟到重试时间，处理成功后再移除
+// 主节点在处理中崩溃时，订单在重试时间后被新的主节点重新领取
+var claimExpiredOrdersScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, tonumber(ARGV[2]))
+for _, id in ipairs(due) do
+	redis.call('ZADD', KEYS[1], ARGV[3], id)
+end
+return due
+`)
+
+// orderTimeoutQueueEnabled 是否使用 Redis 定时队列
+func orderTimeoutQueueEnabled() bool {
+	if database.RDB == nil {
+		return false
+	}
+	return config.Cfg == nil || config.Cfg.OrderTimeout.QueueEnabled
+}
+
+// ScheduleOrderTimeout 将订单加入超时定时队列（消息总线不可用时代替超时延迟消息）
+func ScheduleOrderTimeout(ctx context.Context, orderID string, expireAt time.Time) error {
+	if !orderTimeoutQueueEnabled() {
+		return fmt.Errorf("订单超时定时队列未启用")
+	}
+	return database.RDB.ZAdd(ctx, orderTimeoutQueueKey, &redis.Z{
+		Score:  float64(expireAt.UnixMilli()),
+		Member: orderID,
+	}).Err()
+}
+
+// ensureOrderTimeoutScheduled 订单不在定时队列中时补充加入（对账扫描使用，不改变已有的超时时间）
+func ensureOrderTimeoutScheduled(ctx context.Context, orderID string, expireAt time.Time) (bool, error) {
+	added, err := database.RDB.ZAddNX(ctx, orderTimeoutQueueKey, &redis.Z{
+		Score:  float64(expireAt.UnixMilli()),
+		Member: orderID,
+	}).Result()
+	return added > 0, err
+}
+
+// OrderTimeoutQueue 订单超时定时队列
+// 订单创建时按超时时间写入 Redis ZSET，定时领取到期订单并关闭
+// 作为单实例服务只在主节点上运行（lifecycle.Supervisor），主节点失联后由新的主节点接管
+type OrderTimeoutQueue struct {
+	drainInterval time.Duration
+	batchSize     int
+	concurrency   int
+	retryDelay    time.Duration
+	stopChan      chan struct{}
+	stopOnce      sync.Once
+}
+
+// NewOrderTimeoutQueue 创建订单超时定时队列（未配置的项使用默认值）
+func NewOrderTimeoutQueue() *OrderTimeoutQueue {
+	cfg := config.OrderTimeoutConfig{}
+	if config.Cfg != nil {
+		cfg = config.Cfg.OrderTimeout
+	}
+	if cfg.DrainInterval <= 0 {
+		cfg.DrainInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 8
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = time.Minute
+	}
+
+	return &OrderTimeoutQueue{
+		drainInterval: cfg.DrainInterval,
+		batchSize:     cfg.BatchSize,
+		concurrency:   cfg.Concurrency,
+		retryDelay:    cfg.RetryDelay,
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// Start 启动定时队列，阻塞直到停止
+func (q *OrderTimeoutQueue) Start(ctx context.Context) {
+	if !orderTimeoutQueueEnabled() {
+		logger.Logger.Info("订单超时定时队列未启用（Redis 未初始化或 order_timeout.queue_enabled 为 false）")
+		return
+	}
+
+	ticker := time.NewTicker(q.drainInterval)
+	defer ticker.Stop()
+
+	logger.Logger.Info("订单超时定时队列已启动",
+		zap.Duration("drain_interval", q.drainInterval))
+
+	for {
+		q.drain(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-q.stopChan:
+			logger.Logger.Info("订单超时定时队列已停止")
+			return
+		case <-ctx.Done():
+			logger.Logger.Info("订单超时定时队列已停止（上下文取消）")
+			return
+		}
+	}
+}
+
+// Stop 停止定时队列（处理中的订单由重试时间兜底）
+func (q *OrderTimeoutQueue) Stop() {
+	q.stopOnce.Do(func() {
+		close(q.stopChan)
+	})
+}
+
+// drain 领取并处理所有到期订单（每批 batchSize 个，并发 concurrency 个）
+func (q *OrderTimeoutQueue) drain(ctx context.Context) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Logger.Error("订单超时定时队列异常", zap.Any("panic", r))
+		}
+	}()
+
+	if depth, err := database.RDB.ZCard(ctx, orderTimeoutQueueKey).Result(); err == nil {
+		orderTimeoutQueueDepth.Set(float64(depth))
+	}
+
+	for ctx.Err() == nil {
+		now := time.Now()
+		orderIDs, err := claimExpiredOrdersScript.Run(ctx, database.RDB, []string{orderTimeoutQueueKey},
+			now.UnixMilli(), q.batchSize, now.Add(q.retryDelay).UnixMilli()).StringSlice()
+		if err != nil {
+			logger.Logger.Warn("领取到期订单失败", zap.Error(err))
+			return
+		}
+		if len(orderIDs) == 0 {
+			return
+		}
+
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, q.concurrency)
+		for _, orderID := range orderIDs {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(orderID string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				q.expireOrder(ctx, orderID)
+			}(orderID)
+		}
+		wg.Wait()
+
+		if len(orderIDs) < q.batchSize {
+			return
+		}
+	}
+}
+
+// expireOrder 处理单个到期订单，成功后移出队列；失败时保留在队列中，重试时间后重新处理
+func (q *OrderTimeoutQueue) expireOrder(ctx context.Context, orderID string) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Logger.Error("处理超时订单异常",
+				zap.String("order_id", orderID),
+				zap.Any("panic", r))
+		}
+	}()
+
+	var order models.Order
+	err := database.DB.WithContext(ctx).Select("id, order_no").Where("id = ?", orderID).First(&order).Error
+	if err == nil {
+		err = mq.HandleOrderTimeout(ctx, order.OrderNo)
+	} else if err == gorm.ErrRecordNotFound {
+		err = nil
+	}
+	if err != nil {
+		orderTimeoutQueueTotal.WithLabelValues("retry").Inc()
+		logger.Logger.Warn("处理超时订单失败，稍后重试",
+			zap.String("order_id", orderID),
+			zap.Duration("retry_delay", q.retryDelay),
+			zap.Error(err))
+		return
+	}
+
+	orderTimeoutQueueTotal.WithLabelValues("expired").Inc()
+	if err := database.RDB.ZRem(ctx, orderTimeoutQueueKey, orderID).Err(); err != nil {
+		logger.Logger.Warn("移除定时队列中的订单失败（重试时会再次检查订单状态）",
+			zap.String("order_id", orderID),
+			zap.Error(err))
+	}
+}
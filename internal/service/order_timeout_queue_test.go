@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/mq"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// failingOrderChecker 查单总是失败的上游查单器（订单超时处理失败）
+type failingOrderChecker struct{}
+
+func (failingOrderChecker) CheckUpstreamPaid(ctx context.Context, orderNo string) (bool, error) {
+	return false, errors.New("upstream unavailable")
+}
+
+// setupTimeoutQueueTest 准备超时定时队列测试环境（插件 77 未配置超时时间，使用默认 300 秒）
+func setupTimeoutQueueTest(t *testing.T) *gorm.DB {
+	db := setupStatisticsTestDB(t)
+	setupTestRedis(t)
+	return db
+}
+
+// createTimeoutTestOrder 创建使用插件 77 的订单
+func createTimeoutTestOrder(db *gorm.DB, id string, status int, createdAt time.Time) {
+	pluginID := int64(77)
+	db.Create(&models.Order{ID: id, OrderNo: "PAY" + id, OutOrderNo: "M" + id, Money: 10000,
+		OrderStatus: status, CreateDatetime: &createdAt})
+	db.Create(&models.OrderDetail{OrderID: id, PluginID: &pluginID})
+}
+
+// timeoutQueueScore 读取订单在定时队列中的分数（不存在时返回 -1）
+func timeoutQueueScore(t *testing.T, orderID string) int64 {
+	t.Helper()
+	score, err := database.RDB.ZScore(context.Background(), orderTimeoutQueueKey, orderID).Result()
+	if err != nil {
+		return -1
+	}
+	return int64(score)
+}
+
+func TestClaimExpiredOrdersScript(t *testing.T) {
+	setupTimeoutQueueTest(t)
+	ctx := context.Background()
+	now := time.Now()
+	retryAt := now.Add(time.Minute)
+
+	database.RDB.ZAdd(ctx, orderTimeoutQueueKey,
+		&redis.Z{Score: float64(now.Add(-3 * time.Second).UnixMilli()), Member: "O1"},
+		&redis.Z{Score: float64(now.Add(-2 * time.Second).UnixMilli()), Member: "O2"},
+		&redis.Z{Score: float64(now.Add(-time.Second).UnixMilli()), Member: "O3"},
+		&redis.Z{Score: float64(now.Add(time.Hour).UnixMilli()), Member: "O4"})
+
+	// 按到期顺序领取不超过 batch 个订单，分数推迟到重试时间（处理成功前不移除）
+	claimed, err := claimExpiredOrdersScript.Run(ctx, database.RDB, []string{orderTimeoutQueueKey},
+		now.UnixMilli(), 2, retryAt.UnixMilli()).StringSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"O1", "O2"}, claimed)
+	assert.Equal(t, retryAt.UnixMilli(), timeoutQueueScore(t, "O1"))
+	assert.Equal(t, retryAt.UnixMilli(), timeoutQueueScore(t, "O2"))
+	assert.Equal(t, now.Add(-time.Second).UnixMilli(), timeoutQueueScore(t, "O3"))
+	assert.Equal(t, now.Add(time.Hour).UnixMilli(), timeoutQueueScore(t, "O4"))
+
+	// 已领取的订单在重试时间之前不会被再次领取
+	claimed, err = claimExpiredOrdersScript.Run(ctx, database.RDB, []string{orderTimeoutQueueKey},
+		now.UnixMilli(), 10, retryAt.UnixMilli()).StringSlice()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"O3"}, claimed)
+
+	// 到达重试时间后（处理者崩溃）重新领取
+	claimed, err = claimExpiredOrdersScript.Run(ctx, database.RDB, []string{orderTimeoutQueueKey},
+		retryAt.UnixMilli(), 10, retryAt.Add(time.Minute).UnixMilli()).StringSlice()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"O1", "O2", "O3"}, claimed)
+}
+
+func TestOrderTimeoutQueueDrain(t *testing.T) {
+	db := setupTimeoutQueueTest(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	createTimeoutTestOrder(db, "O1", models.OrderStatusPaying, now.Add(-time.Hour))
+	createTimeoutTestOrder(db, "O2", models.OrderStatusGenerating, now.Add(-time.Hour))
+	createTimeoutTestOrder(db, "O3", models.OrderStatusPaid, now.Add(-time.Hour))
+	createTimeoutTestOrder(db, "O4", models.OrderStatusPaying, now)
+	for _, id := range []string{"O1", "O2", "O3", "O404"} {
+		assert.NoError(t, ScheduleOrderTimeout(ctx, id, now.Add(-time.Second)))
+	}
+	future := now.Add(5 * time.Minute)
+	assert.NoError(t, ScheduleOrderTimeout(ctx, "O4", future))
+
+	// 分批处理所有到期订单：关闭后、订单已成功或不存在时移出队列，未到期的保留
+	q := &OrderTimeoutQueue{batchSize: 2, concurrency: 2, retryDelay: time.Minute}
+	q.drain(ctx)
+	for _, id := range []string{"O1", "O2", "O3", "O404"} {
+		assert.Equal(t, int64(-1), timeoutQueueScore(t, id), id)
+	}
+	assert.Equal(t, future.UnixMilli(), timeoutQueueScore(t, "O4"))
+
+	var orders []models.Order
+	db.Order("id").Find(&orders)
+	if assert.Len(t, orders, 4) {
+		assert.Equal(t, models.OrderStatusClosed, orders[0].OrderStatus)
+		assert.Equal(t, models.OrderStatusClosed, orders[1].OrderStatus)
+		assert.Equal(t, models.OrderStatusPaid, orders[2].OrderStatus)
+		assert.Equal(t, models.OrderStatusPaying, orders[3].OrderStatus)
+	}
+}
+
+func TestOrderTimeoutQueueRetry(t *testing.T) {
+	db := setupTimeoutQueueTest(t)
+	ctx := context.Background()
+	mq.SetUpstreamOrderChecker(failingOrderChecker{})
+	t.Cleanup(func() { mq.SetUpstreamOrderChecker(nil) })
+
+	createTimeoutTestOrder(db, "O1", models.OrderStatusPaying, time.Now().Add(-time.Hour))
+	assert.NoError(t, ScheduleOrderTimeout(ctx, "O1", time.Now().Add(-time.Second)))
+
+	// 处理失败：保留在队列中，重试时间后重新处理
+	q := &OrderTimeoutQueue{batchSize: 10, concurrency: 1, retryDelay: time.Minute}
+	before := time.Now()
+	q.drain(ctx)
+	score := timeoutQueueScore(t, "O1")
+	assert.GreaterOrEqual(t, score, before.Add(time.Minute).UnixMilli())
+	assert.LessOrEqual(t, score, time.Now().Add(time.Minute).UnixMilli())
+	var order models.Order
+	db.First(&order, "id = ?", "O1")
+	assert.Equal(t, models.OrderStatusPaying, order.OrderStatus)
+
+	// 重试成功后移出队列
+	mq.SetUpstreamOrderChecker(nil)
+	database.RDB.ZAdd(ctx, orderTimeoutQueueKey, &redis.Z{Score: float64(time.Now().Add(-time.Second).UnixMilli()), Member: "O1"})
+	q.drain(ctx)
+	assert.Equal(t, int64(-1), timeoutQueueScore(t, "O1"))
+	db.First(&order, "id = ?", "O1")
+	assert.Equal(t, models.OrderStatusClosed, order.OrderStatus)
+}
+
+func TestEnsureOrderTimeoutScheduled(t *testing.T) {
+	db := setupTimeoutQueueTest(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Millisecond)
+
+	// 不在队列中时加入，已在队列中时不改变超时时间
+	added, err := ensureOrderTimeoutScheduled(ctx, "O1", now)
+	assert.NoError(t, err)
+	assert.True(t, added)
+	added, err = ensureOrderTimeoutScheduled(ctx, "O1", now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.False(t, added)
+	assert.Equal(t, now.UnixMilli(), timeoutQueueScore(t, "O1"))
+	database.RDB.Del(ctx, orderTimeoutQueueKey)
+
+	// 对账扫描：未超时的订单补充到队列（保留已有的超时时间），已超时的订单直接关闭
+	createdAt := now.Add(-time.Minute)
+	createTimeoutTestOrder(db, "O1", models.OrderStatusPaying, createdAt)
+	createTimeoutTestOrder(db, "O2", models.OrderStatusGenerating, createdAt)
+	createTimeoutTestOrder(db, "O3", models.OrderStatusPaying, now.Add(-time.Hour))
+	createTimeoutTestOrder(db, "O4", models.OrderStatusPaid, createdAt)
+	existing := now.Add(10 * time.Minute)
+	assert.NoError(t, ScheduleOrderTimeout(ctx, "O2", existing))
+
+	s := &OrderTimeoutService{orderService: &OrderService{}, scanLookback: 24 * time.Hour}
+	s.checkExpiredOrders(ctx)
+
+	assert.Equal(t, createdAt.Add(300*time.Second).UnixMilli(), timeoutQueueScore(t, "O1"))
+	assert.Equal(t, existing.UnixMilli(), timeoutQueueScore(t, "O2"))
+	assert.Equal(t, int64(-1), timeoutQueueScore(t, "O3"))
+	assert.Equal(t, int64(-1), timeoutQueueScore(t, "O4"))
+	var order models.Order
+	db.First(&order, "id = ?", "O3")
+	assert.Equal(t, models.OrderStatusClosed, order.OrderStatus)
+}
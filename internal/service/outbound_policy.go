@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/safehttp"
+	"go.uber.org/zap"
+)
+
+// outboundAllowListConfigKey 出站请求租户白名单的系统配置路径
+// 配置值为 JSON，键为租户ID（"*" 对所有租户生效），例如：
+//
+//	{"12": {"hosts": ["notify.internal.example.com", "10.8.0.0/16"], "ports": [9000]}}
+const outboundAllowListConfigKey = "outbound.allow_list"
+
+// outboundAllowList 租户出站白名单
+type outboundAllowList struct {
+	Hosts []string `json:"hosts"` // 放行的主机名（支持 *.example.com）、IP 或 CIDR
+	Ports []int    `json:"ports"` // 额外允许的端口
+}
+
+// tenantOutboundPolicy 获取租户的出站请求策略（全局配置 + 系统配置中的租户白名单）
+// 白名单读取失败时使用全局策略，不放宽限制
+func tenantOutboundPolicy(ctx context.Context, tenantID int64) *safehttp.Policy {
+	policy := safehttp.DefaultPolicy()
+	if database.DB == nil || database.RDB == nil {
+		return policy
+	}
+
+	value, err := NewSystemConfigService().GetSystemConfigByPath(ctx, outboundAllowListConfigKey)
+	if err != nil || value == "" {
+		return policy
+	}
+
+	var allowLists map[string]outboundAllowList
+	if err := json.Unmarshal([]byte(value), &allowLists); err != nil {
+		logger.Logger.Warn("解析出站请求白名单失败",
+			zap.String("key", outboundAllowListConfigKey),
+			zap.Error(err))
+		return policy
+	}
+
+	for _, key := range []string{"*", strconv.FormatInt(tenantID, 10)} {
+		if allowList, ok := allowLists[key]; ok {
+			policy = policy.With(allowList.Hosts, allowList.Ports)
+		}
+	}
+	return policy
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-pay-core/config"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+// replayRejectedTotal 防重放拒绝次数
+var replayRejectedTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "replay_rejected_total",
+		Help: "商户接口防重放拒绝次数（reason: invalid 参数缺失或格式错误、expired 时间戳过期、replayed nonce 重复）",
+	},
+	[]string{"reason"},
+)
+
+const (
+	replayNonceKeyPrefix = "replay:nonce:" // replay:nonce:<商户ID>:<nonce>，nonce 已使用的标记
+	replayNonceMaxLen    = 64
+	replayDefaultWindow  = 5 * time.Minute
+)
+
+// replayRequired 商户接口请求是否需要检查 timestamp、nonce
+func replayRequired(merchant *models.Merchant) bool {
+	if merchant != nil && merchant.ReplayProtect {
+		return true
+	}
+	return config.Cfg != nil && config.Cfg.Replay.RequireAll
+}
+
+// replayWindow 允许的时间戳偏差
+func replayWindow() time.Duration {
+	if config.Cfg != nil && config.Cfg.Replay.Window > 0 {
+		return config.Cfg.Replay.Window
+	}
+	return replayDefaultWindow
+}
+
+// checkMerchantReplay 商户接口防重放检查（须在验签通过后调用，避免未签名的请求占用 nonce）
+// 开启防重放的商户，请求必须携带参与签名的 timestamp（Unix 秒，也接受毫秒）和 nonce，
+// 时间戳与服务器时间相差超过 replay.window 返回 7333，nonce 在 2 倍 window 内重复使用返回 7334
+func checkMerchantReplay(ctx context.Context, merchant *models.Merchant, rawSignData map[string]interface{}) *OrderError {
+	if !replayRequired(merchant) {
+		return nil
+	}
+
+	window := replayWindow()
+	nonce, orderErr := parseReplayParams(rawSignData, time.Now(), window)
+	if orderErr != nil {
+		return orderErr
+	}
+
+	if database.RDB == nil {
+		return ErrSystemBusy
+	}
+	key := fmt.Sprintf("%s%d:%s", replayNonceKeyPrefix, merchant.ID, nonce)
+	ok, err := database.RDB.SetNX(ctx, key, 1, 2*window).Result()
+	if err != nil {
+		logger.Logger.Error("写入防重放 nonce 失败",
+			zap.Int64("merchant_id", merchant.ID),
+			zap.Error(err))
+		return ErrSystemBusy
+	}
+	if !ok {
+		replayRejectedTotal.WithLabelValues("replayed").Inc()
+		return ErrRequestReplayed
+	}
+	return nil
+}
+
+// parseReplayParams 校验 timestamp 和 nonce，返回 nonce
+func parseReplayParams(rawSignData map[string]interface{}, now time.Time, window time.Duration) (string, *OrderError) {
+	timestamp := strings.TrimSpace(replayParam(rawSignData, "timestamp"))
+	nonce := strings.TrimSpace(replayParam(rawSignData, "nonce"))
+	if timestamp == "" || nonce == "" {
+		replayRejectedTotal.WithLabelValues("invalid").Inc()
+		return "", NewOrderError(ErrCodeRequestExpired, "缺少 timestamp 或 nonce 参数")
+	}
+	if len(nonce) > replayNonceMaxLen {
+		replayRejectedTotal.WithLabelValues("invalid").Inc()
+		return "", NewOrderError(ErrCodeRequestExpired, fmt.Sprintf("nonce 长度不能超过 %d", replayNonceMaxLen))
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || ts <= 0 {
+		replayRejectedTotal.WithLabelValues("invalid").Inc()
+		return "", NewOrderError(ErrCodeRequestExpired, "timestamp 格式错误")
+	}
+	requestTime := time.Unix(ts, 0)
+	if ts > 1e12 {
+		requestTime = time.UnixMilli(ts)
+	}
+	if skew := now.Sub(requestTime); skew > window || skew < -window {
+		replayRejectedTotal.WithLabelValues("expired").Inc()
+		return "", ErrRequestExpired
+	}
+	return nonce, nil
+}
+
+// replayParam 读取签名数据中的参数（JSON 请求为数字，表单和查询参数为字符串）
+func replayParam(rawSignData map[string]interface{}, name string) string {
+	switch v := rawSignData[name].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
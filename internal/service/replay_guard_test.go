@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-pay-core/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReplayParams(t *testing.T) {
+	now := time.Unix(1704081600, 0)
+	window := 5 * time.Minute
+
+	cases := []struct {
+		name      string
+		timestamp interface{}
+		nonce     interface{}
+		code      int
+	}{
+		{"JSON 数字时间戳", int64(1704081600), "n1", 0},
+		{"表单字符串时间戳", "1704081500", "n1", 0},
+		{"JSON 解码的浮点数", float64(1704081700), "n1", 0},
+		{"毫秒时间戳", strconv.FormatInt(now.UnixMilli(), 10), "n1", 0},
+		{"缺少 nonce", "1704081600", nil, ErrCodeRequestExpired},
+		{"缺少 timestamp", nil, "n1", ErrCodeRequestExpired},
+		{"格式错误", "abc", "n1", ErrCodeRequestExpired},
+		{"过期", "1704081200", "n1", ErrCodeRequestExpired},
+		{"超前", "1704082000", "n1", ErrCodeRequestExpired},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := map[string]interface{}{"mchId": 1}
+			if tc.timestamp != nil {
+				raw["timestamp"] = tc.timestamp
+			}
+			if tc.nonce != nil {
+				raw["nonce"] = tc.nonce
+			}
+			nonce, orderErr := parseReplayParams(raw, now, window)
+			if tc.code == 0 {
+				assert.Nil(t, orderErr)
+				assert.Equal(t, tc.nonce, nonce)
+				return
+			}
+			if assert.NotNil(t, orderErr) {
+				assert.Equal(t, tc.code, orderErr.Code)
+			}
+		})
+	}
+}
+
+func TestCheckMerchantReplayOptIn(t *testing.T) {
+	// 未开启防重放的商户不检查（无需 Redis）
+	orderErr := checkMerchantReplay(context.Background(), &models.Merchant{ID: 1}, map[string]interface{}{"mchId": 1})
+	assert.Nil(t, orderErr)
+
+	// 开启后缺少参数直接拒绝
+	orderErr = checkMerchantReplay(context.Background(), &models.Merchant{ID: 1, ReplayProtect: true}, map[string]interface{}{"mchId": 1})
+	if assert.NotNil(t, orderErr) {
+		assert.Equal(t, ErrCodeRequestExpired, orderErr.Code)
+	}
+}
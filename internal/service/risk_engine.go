@@ -0,0 +1,572 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-pay-core/config"
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// riskDecisionsTotal 买家风控决策次数
+var riskDecisionsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "risk_decisions_total",
+		Help: "买家风控决策次数（stage: order 下单、cashier 收银台、device 设备指纹、notify 支付回调；decision: allow、challenge、block）",
+	},
+	[]string{"stage", "decision", "rule"},
+)
+
+// 风控规则（RiskResult.Rule）
+const (
+	RiskRuleBlacklist           = "blacklist"            // 命中黑名单
+	RiskRuleIPVelocity          = "ip_velocity"          // 窗口内同一 IP 订单数
+	RiskRuleFingerprintVelocity = "fingerprint_velocity" // 窗口内同一设备指纹订单数
+	RiskRuleBuyerUnpaid         = "buyer_unpaid"         // 窗口内同一买家未支付订单数
+)
+
+// Redis 键
+const (
+	riskWindowKeyPrefix      = "risk:window:"          // risk:window:<ip|fingerprint|user|buyer>:<值>，ZSET 成员为商户订单号，分值为毫秒时间戳
+	riskBlacklistKeyPrefix   = "risk:blacklist:"       // risk:blacklist:<类型>:<值>，值为备注，过期时间与名单一致
+	riskBlacklistLoadedKey   = "risk:blacklist:loaded" // 黑名单已从数据库加载到 Redis 的标记（Redis 清空后自动重新加载）
+	riskBlacklistDefaultSize = 20
+	riskBlacklistMaxSize     = 100
+)
+
+// riskWindowScript 滑动窗口：移除窗口外的订单并加入当前订单（同一订单重复检查不重复计数）
+// ARGV[4] 为 1 时返回窗口内的订单号（用于统计未支付订单），否则返回订单数
+var riskWindowScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', tonumber(ARGV[1]) - tonumber(ARGV[2]))
+redis.call('ZADD', KEYS[1], 'NX', ARGV[1], ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+if ARGV[4] == '1' then
+	return redis.call('ZRANGE', KEYS[1], 0, -1)
+end
+return redis.call('ZCARD', KEYS[1])
+`)
+
+// riskUnpaidStatuses 未支付的订单状态
+var riskUnpaidStatuses = []int{models.OrderStatusGenerating, models.OrderStatusPaying}
+
+// 黑名单管理错误
+var (
+	ErrRiskBlacklistNotFound = errors.New("黑名单记录不存在")
+	ErrRiskBlacklistInvalid  = errors.New("名单类型或名单值无效")
+)
+
+// RiskSubject 风控检查对象（为空的字段不检查）
+type RiskSubject struct {
+	OutOrderNo  string // 商户订单号（滑动窗口成员，同一订单在下单和收银台重复检查不重复计数）
+	IP          string // 客户端 IP
+	Fingerprint string // 收银台设备指纹
+	UserID      string // 收银台用户ID
+	BuyerID     string // 支付宝 buyer_id（微信 openid）
+}
+
+// RiskResult 风控决策
+type RiskResult struct {
+	Decision string `json:"decision"`         // allow、challenge、block
+	Rule     string `json:"rule,omitempty"`   // 命中的规则
+	Reason   string `json:"reason,omitempty"` // 决策原因（记录在订单详情 risk_reason）
+}
+
+// riskAllow 放行结果
+var riskAllow = &RiskResult{Decision: models.RiskDecisionAllow}
+
+// RiskBlacklistQuery 黑名单查询条件
+type RiskBlacklistQuery struct {
+	ListType string // 名单类型（为空表示全部）
+	Value    string // 名单值（精确匹配）
+	Page     int    // 页码（从 1 开始）
+	PageSize int    // 每页条数（最大 100）
+}
+
+// RiskBlacklistRequest 添加黑名单请求
+type RiskBlacklistRequest struct {
+	ListType      string `json:"list_type" binding:"required"` // ip、fingerprint、user、buyer
+	Value         string `json:"value" binding:"required"`     // 名单值
+	Remarks       string `json:"remarks"`                      // 备注
+	ExpireSeconds int64  `json:"expire_seconds"`               // 有效期（秒），0 表示永久
+}
+
+// RiskBlacklistListResponse 黑名单分页列表
+type RiskBlacklistListResponse struct {
+	Total int64                  `json:"total"`
+	List  []models.RiskBlacklist `json:"list"`
+}
+
+// RiskService 买家风控服务
+// 按 Redis 滑动窗口统计同一 IP、设备指纹的订单数和同一买家的未支付订单数，结合黑名单给出
+// allow（放行）、challenge（收银台要求用户确认）、block（拒绝）决策，并记录在订单详情上
+type RiskService struct {
+	redis *redis.Client
+
+	loadMu sync.Mutex // 避免同一进程并发重复加载黑名单
+}
+
+// NewRiskService 创建买家风控服务
+func NewRiskService() *RiskService {
+	return &RiskService{redis: database.RDB}
+}
+
+// Evaluate 检查风控对象，返回最严重的决策
+// Redis 不可用时放行（只记录日志），风控不影响正常支付
+func (s *RiskService) Evaluate(ctx context.Context, subject RiskSubject) *RiskResult {
+	if s.redis == nil {
+		return riskAllow
+	}
+
+	if result := s.checkBlacklist(ctx, subject); result != nil {
+		return result
+	}
+
+	cfg := config.Cfg.Risk
+	if !cfg.Enabled || subject.OutOrderNo == "" || cfg.Window <= 0 {
+		return riskAllow
+	}
+
+	result := riskAllow
+	if subject.IP != "" && cfg.MaxOrdersPerIP > 0 {
+		if count, err := s.windowCount(ctx, models.RiskListIP, subject.IP, subject.OutOrderNo); err == nil {
+			result = worseRisk(result, riskLimitResult(RiskRuleIPVelocity, count, cfg.MaxOrdersPerIP, cfg.ChallengeRatio,
+				fmt.Sprintf("IP %s 在 %s 内有 %d 笔订单", subject.IP, cfg.Window, count)))
+		}
+	}
+	if subject.Fingerprint != "" && cfg.MaxOrdersPerFingerprint > 0 {
+		if count, err := s.windowCount(ctx, models.RiskListFingerprint, subject.Fingerprint, subject.OutOrderNo); err == nil {
+			result = worseRisk(result, riskLimitResult(RiskRuleFingerprintVelocity, count, cfg.MaxOrdersPerFingerprint, cfg.ChallengeRatio,
+				fmt.Sprintf("设备 %s 在 %s 内有 %d 笔订单", subject.Fingerprint, cfg.Window, count)))
+		}
+	}
+	if cfg.MaxUnpaidPerBuyer > 0 {
+		for _, buyer := range []struct{ listType, value string }{
+			{models.RiskListUser, subject.UserID},
+			{models.RiskListBuyer, subject.BuyerID},
+		} {
+			if buyer.value == "" {
+				continue
+			}
+			if unpaid, err := s.windowUnpaid(ctx, buyer.listType, buyer.value, subject.OutOrderNo); err == nil {
+				result = worseRisk(result, riskLimitResult(RiskRuleBuyerUnpaid, unpaid, cfg.MaxUnpaidPerBuyer, cfg.ChallengeRatio,
+					fmt.Sprintf("买家 %s 在 %s 内有 %d 笔未支付订单", buyer.value, cfg.Window, unpaid)))
+			}
+		}
+	}
+	return result
+}
+
+// riskLimitResult 按限制给出决策：超过限制拒绝，达到限制的 ratio 比例要求确认
+func riskLimitResult(rule string, count, limit int, ratio float64, reason string) *RiskResult {
+	switch {
+	case limit <= 0:
+		return riskAllow
+	case count > limit:
+		return &RiskResult{Decision: models.RiskDecisionBlock, Rule: rule, Reason: reason}
+	case ratio > 0 && ratio < 1 && float64(count) >= float64(limit)*ratio:
+		return &RiskResult{Decision: models.RiskDecisionChallenge, Rule: rule, Reason: reason}
+	default:
+		return riskAllow
+	}
+}
+
+// worseRisk 返回更严重的决策（相同时保留先命中的）
+func worseRisk(a, b *RiskResult) *RiskResult {
+	if riskSeverity(b.Decision) > riskSeverity(a.Decision) {
+		return b
+	}
+	return a
+}
+
+// riskSeverity 决策的严重程度
+func riskSeverity(decision string) int {
+	switch decision {
+	case models.RiskDecisionBlock:
+		return 2
+	case models.RiskDecisionChallenge:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// windowCount 将订单加入滑动窗口，返回窗口内的订单数
+func (s *RiskService) windowCount(ctx context.Context, listType, value, outOrderNo string) (int, error) {
+	window := config.Cfg.Risk.Window
+	count, err := riskWindowScript.Run(ctx, s.redis, []string{riskWindowKeyPrefix + listType + ":" + value},
+		time.Now().UnixMilli(), window.Milliseconds(), outOrderNo, "0").Int()
+	if err != nil {
+		logger.Logger.Warn("风控滑动窗口计数失败，跳过该规则",
+			zap.String("list_type", listType),
+			zap.String("value", value),
+			zap.Error(err))
+		return 0, err
+	}
+	return count, nil
+}
+
+// windowUnpaid 将订单加入买家的滑动窗口，返回窗口内仍未支付的订单数（订单状态以数据库为准）
+func (s *RiskService) windowUnpaid(ctx context.Context, listType, value, outOrderNo string) (int, error) {
+	window := config.Cfg.Risk.Window
+	outOrderNos, err := riskWindowScript.Run(ctx, s.redis, []string{riskWindowKeyPrefix + listType + ":" + value},
+		time.Now().UnixMilli(), window.Milliseconds(), outOrderNo, "1").StringSlice()
+	if err != nil {
+		logger.Logger.Warn("风控滑动窗口计数失败，跳过该规则",
+			zap.String("list_type", listType),
+			zap.String("value", value),
+			zap.Error(err))
+		return 0, err
+	}
+	return countUnpaidOrders(ctx, outOrderNos)
+}
+
+// countUnpaidOrders 统计商户订单号中未支付的订单数
+func countUnpaidOrders(ctx context.Context, outOrderNos []string) (int, error) {
+	if len(outOrderNos) == 0 {
+		return 0, nil
+	}
+	var count int64
+	if err := database.DB.WithContext(ctx).Model(&models.Order{}).
+		Where("out_order_no IN ? AND order_status IN ?", outOrderNos, riskUnpaidStatuses).
+		Count(&count).Error; err != nil {
+		logger.Logger.Warn("统计买家未支付订单失败，跳过该规则", zap.Error(err))
+		return 0, err
+	}
+	return int(count), nil
+}
+
+// checkBlacklist 检查黑名单（风控未启用时同样生效），命中时返回拒绝决策
+func (s *RiskService) checkBlacklist(ctx context.Context, subject RiskSubject) *RiskResult {
+	candidates := []struct{ listType, value string }{
+		{models.RiskListIP, subject.IP},
+		{models.RiskListFingerprint, subject.Fingerprint},
+		{models.RiskListUser, subject.UserID},
+		{models.RiskListBuyer, subject.BuyerID},
+	}
+	var keys, labels []string
+	for _, c := range candidates {
+		if c.value != "" {
+			keys = append(keys, riskBlacklistKey(c.listType, c.value))
+			labels = append(labels, c.listType+" "+c.value)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	s.ensureBlacklistLoaded(ctx)
+	values, err := s.redis.MGet(ctx, keys...).Result()
+	if err != nil {
+		logger.Logger.Warn("查询风控黑名单失败，跳过黑名单检查", zap.Error(err))
+		return nil
+	}
+	for i, v := range values {
+		remarks, ok := v.(string)
+		if !ok {
+			continue
+		}
+		reason := labels[i] + " 在黑名单中"
+		if remarks != "" {
+			reason += "（" + remarks + "）"
+		}
+		return &RiskResult{Decision: models.RiskDecisionBlock, Rule: RiskRuleBlacklist, Reason: reason}
+	}
+	return nil
+}
+
+// ensureBlacklistLoaded Redis 中没有加载标记时（首次运行或 Redis 清空），从数据库加载未过期的黑名单
+func (s *RiskService) ensureBlacklistLoaded(ctx context.Context) {
+	if n, err := s.redis.Exists(ctx, riskBlacklistLoadedKey).Result(); err != nil || n > 0 {
+		return
+	}
+
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+	if n, err := s.redis.Exists(ctx, riskBlacklistLoadedKey).Result(); err != nil || n > 0 {
+		return
+	}
+
+	var entries []models.RiskBlacklist
+	if err := database.DB.WithContext(ctx).
+		Where("expire_datetime IS NULL OR expire_datetime > ?", time.Now()).
+		Find(&entries).Error; err != nil {
+		logger.Logger.Warn("加载风控黑名单失败", zap.Error(err))
+		return
+	}
+	pipe := s.redis.Pipeline()
+	for i := range entries {
+		s.cacheBlacklist(ctx, pipe, &entries[i])
+	}
+	pipe.Set(ctx, riskBlacklistLoadedKey, time.Now().Unix(), 0)
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.Logger.Warn("写入风控黑名单缓存失败", zap.Error(err))
+		return
+	}
+	logger.Logger.Info("风控黑名单已加载到 Redis", zap.Int("count", len(entries)))
+}
+
+// cacheBlacklist 写入黑名单缓存（过期时间与名单一致）
+func (s *RiskService) cacheBlacklist(ctx context.Context, pipe redis.Pipeliner, entry *models.RiskBlacklist) {
+	var ttl time.Duration
+	if entry.ExpireDatetime != nil {
+		if ttl = time.Until(*entry.ExpireDatetime); ttl <= 0 {
+			return
+		}
+	}
+	pipe.Set(ctx, riskBlacklistKey(entry.ListType, entry.Value), entry.Remarks, ttl)
+}
+
+// riskBlacklistKey 黑名单缓存键
+func riskBlacklistKey(listType, value string) string {
+	return riskBlacklistKeyPrefix + listType + ":" + value
+}
+
+// isRiskListType 是否为有效的名单类型
+func isRiskListType(listType string) bool {
+	switch listType {
+	case models.RiskListIP, models.RiskListFingerprint, models.RiskListUser, models.RiskListBuyer:
+		return true
+	}
+	return false
+}
+
+// ListBlacklist 按条件分页查询黑名单（按ID倒序，包含已过期的记录）
+func (s *RiskService) ListBlacklist(ctx context.Context, query RiskBlacklistQuery) (*RiskBlacklistListResponse, error) {
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = riskBlacklistDefaultSize
+	}
+	if query.PageSize > riskBlacklistMaxSize {
+		query.PageSize = riskBlacklistMaxSize
+	}
+
+	db := database.DB.WithContext(ctx).Model(&models.RiskBlacklist{})
+	if query.ListType != "" {
+		db = db.Where("list_type = ?", query.ListType)
+	}
+	if query.Value != "" {
+		db = db.Where("value = ?", query.Value)
+	}
+
+	result := &RiskBlacklistListResponse{List: []models.RiskBlacklist{}}
+	if err := db.Count(&result.Total).Error; err != nil {
+		return nil, fmt.Errorf("查询黑名单数量失败: %w", err)
+	}
+	if err := db.Order("id DESC").
+		Offset((query.Page - 1) * query.PageSize).
+		Limit(query.PageSize).
+		Find(&result.List).Error; err != nil {
+		return nil, fmt.Errorf("查询黑名单列表失败: %w", err)
+	}
+	return result, nil
+}
+
+// AddBlacklist 添加黑名单（名单已存在时更新备注和过期时间），立即写入 Redis 生效
+func (s *RiskService) AddBlacklist(ctx context.Context, listType, value, remarks string, expireAt *time.Time) (*models.RiskBlacklist, error) {
+	value = strings.TrimSpace(value)
+	if !isRiskListType(listType) || value == "" || len(value) > 128 {
+		return nil, ErrRiskBlacklistInvalid
+	}
+
+	now := time.Now()
+	var entry models.RiskBlacklist
+	err := database.DB.WithContext(ctx).Where("list_type = ? AND value = ?", listType, value).First(&entry).Error
+	switch {
+	case err == nil:
+		if err := database.DB.WithContext(ctx).Model(&entry).Updates(map[string]interface{}{
+			"remarks":         remarks,
+			"expire_datetime": expireAt,
+		}).Error; err != nil {
+			return nil, fmt.Errorf("更新黑名单失败: %w", err)
+		}
+		entry.Remarks = remarks
+		entry.ExpireDatetime = expireAt
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		entry = models.RiskBlacklist{
+			ListType:       listType,
+			Value:          value,
+			Remarks:        remarks,
+			ExpireDatetime: expireAt,
+			CreateDatetime: &now,
+		}
+		if err := database.DB.WithContext(ctx).Create(&entry).Error; err != nil {
+			return nil, fmt.Errorf("添加黑名单失败: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("查询黑名单失败: %w", err)
+	}
+
+	if s.redis != nil {
+		key := riskBlacklistKey(entry.ListType, entry.Value)
+		pipe := s.redis.Pipeline()
+		pipe.Del(ctx, key)
+		s.cacheBlacklist(ctx, pipe, &entry)
+		if _, err := pipe.Exec(ctx); err != nil {
+			logger.Logger.Warn("写入风控黑名单缓存失败",
+				zap.String("list_type", entry.ListType),
+				zap.String("value", entry.Value),
+				zap.Error(err))
+		}
+	}
+
+	logger.Logger.Info("已添加风控黑名单",
+		zap.String("list_type", entry.ListType),
+		zap.String("value", entry.Value),
+		zap.String("remarks", entry.Remarks))
+	return &entry, nil
+}
+
+// RemoveBlacklist 删除黑名单，立即从 Redis 移除
+func (s *RiskService) RemoveBlacklist(ctx context.Context, id int64) (*models.RiskBlacklist, error) {
+	var entry models.RiskBlacklist
+	if err := database.DB.WithContext(ctx).Where("id = ?", id).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRiskBlacklistNotFound
+		}
+		return nil, fmt.Errorf("查询黑名单失败: %w", err)
+	}
+	if err := database.DB.WithContext(ctx).Delete(&entry).Error; err != nil {
+		return nil, fmt.Errorf("删除黑名单失败: %w", err)
+	}
+	if s.redis != nil {
+		if err := s.redis.Del(ctx, riskBlacklistKey(entry.ListType, entry.Value)).Err(); err != nil {
+			logger.Logger.Warn("删除风控黑名单缓存失败",
+				zap.String("list_type", entry.ListType),
+				zap.String("value", entry.Value),
+				zap.Error(err))
+		}
+	}
+
+	logger.Logger.Info("已删除风控黑名单",
+		zap.String("list_type", entry.ListType),
+		zap.String("value", entry.Value))
+	return &entry, nil
+}
+
+// RecordDecision 在订单详情上记录风控决策（只升级不降级：收银台重复访问不会覆盖更严重的决策）
+func (s *RiskService) RecordDecision(ctx context.Context, orderID, stage string, result *RiskResult) {
+	riskDecisionsTotal.WithLabelValues(stage, result.Decision, result.Rule).Inc()
+	if result.Decision != models.RiskDecisionAllow {
+		logger.Logger.Warn("买家风控命中",
+			zap.String("order_id", orderID),
+			zap.String("stage", stage),
+			zap.String("decision", result.Decision),
+			zap.String("rule", result.Rule),
+			zap.String("reason", result.Reason))
+	}
+
+	lower := []string{""}
+	for _, d := range []string{models.RiskDecisionAllow, models.RiskDecisionChallenge} {
+		if riskSeverity(d) < riskSeverity(result.Decision) {
+			lower = append(lower, d)
+		}
+	}
+	reason := truncateRiskReason(result.Reason)
+	if err := database.DB.WithContext(ctx).Model(&models.OrderDetail{}).
+		Where("order_id = ? AND (risk_decision IS NULL OR risk_decision IN ?)", orderID, lower).
+		Updates(map[string]interface{}{
+			"risk_decision": result.Decision,
+			"risk_reason":   reason,
+		}).Error; err != nil {
+		logger.Logger.Warn("记录风控决策失败",
+			zap.String("order_id", orderID),
+			zap.Error(err))
+	}
+}
+
+// truncateRiskReason 截断风控原因（risk_reason 最长 255 个字符）
+func truncateRiskReason(reason string) string {
+	if runes := []rune(reason); len(runes) > 255 {
+		return string(runes[:255])
+	}
+	return reason
+}
+
+// validateRisk 下单时按客户端 IP 检查买家风控（没有传入客户端 IP 时只能在收银台检查，测试模式不检查）
+func (s *OrderService) validateRisk(ctx context.Context, orderCtx *OrderCreateContext, clientIP string) *OrderError {
+	if orderCtx.Test || clientIP == "" || s.riskService == nil {
+		return nil
+	}
+
+	result := s.riskService.Evaluate(ctx, RiskSubject{OutOrderNo: orderCtx.OutOrderNo, IP: clientIP})
+	riskDecisionsTotal.WithLabelValues("order", result.Decision, result.Rule).Inc()
+	if result.Decision == models.RiskDecisionAllow {
+		orderCtx.Risk = result
+		return nil
+	}
+	logger.Logger.Warn("下单命中买家风控",
+		zap.String("out_order_no", orderCtx.OutOrderNo),
+		zap.Int64("merchant_id", orderCtx.MerchantID),
+		zap.String("client_ip", clientIP),
+		zap.String("decision", result.Decision),
+		zap.String("rule", result.Rule),
+		zap.String("reason", result.Reason))
+	if result.Decision == models.RiskDecisionBlock {
+		return ErrRiskBlocked
+	}
+	// challenge 不拒绝下单，记录在订单详情，用户进入收银台时要求确认
+	orderCtx.Risk = result
+	return nil
+}
+
+// EvaluateCashierRisk 收银台（stage: cashier 进入页面、device 提交设备指纹、auth 获取支付链接）检查买家风控并记录决策
+// 只检查未支付的订单；返回的决策不低于订单详情上已记录的决策
+func (s *CashierService) EvaluateCashierRisk(ctx context.Context, order *models.Order, stage, clientIP, fingerprint, userID string) *RiskResult {
+	if order == nil || s.riskService == nil ||
+		(order.OrderStatus != models.OrderStatusGenerating && order.OrderStatus != models.OrderStatusPaying) {
+		return riskAllow
+	}
+
+	subject := RiskSubject{
+		OutOrderNo:  order.OutOrderNo,
+		IP:          clientIP,
+		Fingerprint: fingerprint,
+		UserID:      userID,
+	}
+	recorded := riskAllow
+	if order.OrderDetail != nil {
+		subject.BuyerID = order.OrderDetail.BuyerID
+		if order.OrderDetail.RiskDecision != "" {
+			recorded = &RiskResult{Decision: order.OrderDetail.RiskDecision, Reason: order.OrderDetail.RiskReason}
+		}
+	}
+
+	result := s.riskService.Evaluate(ctx, subject)
+	s.riskService.RecordDecision(ctx, order.ID, stage, result)
+	return worseRisk(result, recorded)
+}
+
+// CheckPaidBuyer 支付回调时记录买家 buyer_id 并检查黑名单
+// 订单已支付，命中黑名单时只在订单详情上记录 block 决策，供人工复核
+func (s *RiskService) CheckPaidBuyer(ctx context.Context, order *models.Order, buyerID string) {
+	if buyerID == "" {
+		return
+	}
+	if err := database.DB.WithContext(ctx).Model(&models.OrderDetail{}).
+		Where("order_id = ? AND (buyer_id IS NULL OR buyer_id = '')", order.ID).
+		Update("buyer_id", buyerID).Error; err != nil {
+		logger.Logger.Warn("记录买家ID失败",
+			zap.String("order_id", order.ID),
+			zap.Error(err))
+	}
+
+	if s.redis == nil {
+		return
+	}
+	if result := s.checkBlacklist(ctx, RiskSubject{BuyerID: buyerID}); result != nil {
+		s.RecordDecision(ctx, order.ID, "notify", result)
+	}
+}
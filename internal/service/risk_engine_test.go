@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestRiskLimitResult(t *testing.T) {
+	assert.Equal(t, models.RiskDecisionAllow, riskLimitResult(RiskRuleIPVelocity, 100, 0, 0.8, "").Decision)
+	assert.Equal(t, models.RiskDecisionAllow, riskLimitResult(RiskRuleIPVelocity, 15, 20, 0.8, "").Decision)
+	assert.Equal(t, models.RiskDecisionChallenge, riskLimitResult(RiskRuleIPVelocity, 16, 20, 0.8, "").Decision)
+	assert.Equal(t, models.RiskDecisionChallenge, riskLimitResult(RiskRuleIPVelocity, 20, 20, 0.8, "").Decision)
+	assert.Equal(t, models.RiskDecisionBlock, riskLimitResult(RiskRuleIPVelocity, 21, 20, 0.8, "").Decision)
+	// ratio 为 0 或 1 时不要求确认
+	assert.Equal(t, models.RiskDecisionAllow, riskLimitResult(RiskRuleIPVelocity, 20, 20, 1, "").Decision)
+
+	challenge := &RiskResult{Decision: models.RiskDecisionChallenge, Rule: RiskRuleIPVelocity}
+	block := &RiskResult{Decision: models.RiskDecisionBlock, Rule: RiskRuleBlacklist}
+	assert.Same(t, block, worseRisk(challenge, block))
+	assert.Same(t, challenge, worseRisk(challenge, riskAllow))
+}
+
+func TestRecordDecisionOnlyUpgrades(t *testing.T) {
+	db := setupTestDB(t)
+	oldDB, oldLogger := database.DB, logger.Logger
+	database.DB, logger.Logger = db, zap.NewNop()
+	t.Cleanup(func() {
+		database.DB, logger.Logger = oldDB, oldLogger
+	})
+
+	db.Create(&models.OrderDetail{OrderID: "1"})
+	s := &RiskService{}
+	ctx := context.Background()
+	decision := func() string {
+		var detail models.OrderDetail
+		db.Where("order_id = ?", "1").First(&detail)
+		return detail.RiskDecision
+	}
+
+	s.RecordDecision(ctx, "1", "cashier", riskAllow)
+	assert.Equal(t, models.RiskDecisionAllow, decision())
+	s.RecordDecision(ctx, "1", "cashier", &RiskResult{Decision: models.RiskDecisionBlock, Rule: RiskRuleBlacklist, Reason: "IP 命中黑名单"})
+	assert.Equal(t, models.RiskDecisionBlock, decision())
+	s.RecordDecision(ctx, "1", "cashier", &RiskResult{Decision: models.RiskDecisionChallenge, Rule: RiskRuleIPVelocity})
+	assert.Equal(t, models.RiskDecisionBlock, decision())
+}
+
+func TestCountUnpaidOrders(t *testing.T) {
+	db := setupTestDB(t)
+	oldDB, oldLogger := database.DB, logger.Logger
+	database.DB, logger.Logger = db, zap.NewNop()
+	t.Cleanup(func() {
+		database.DB, logger.Logger = oldDB, oldLogger
+	})
+
+	db.Create(&models.Order{ID: "1", OrderNo: "P1", OutOrderNo: "M1", OrderStatus: models.OrderStatusPaying})
+	db.Create(&models.Order{ID: "2", OrderNo: "P2", OutOrderNo: "M2", OrderStatus: models.OrderStatusPaid})
+	db.Create(&models.Order{ID: "3", OrderNo: "P3", OutOrderNo: "M3", OrderStatus: models.OrderStatusGenerating})
+
+	count, err := countUnpaidOrders(context.Background(), []string{"M1", "M2", "M3", "M4"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
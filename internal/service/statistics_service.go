@@ -14,13 +14,23 @@ import (
 )
 
 // StatisticsService 统计服务
-type StatisticsService struct{}
+type StatisticsService struct {
+	db *gorm.DB // 为空时使用 database.DB；在事务中更新统计时为事务
+}
 
 // NewStatisticsService 创建统计服务
 func NewStatisticsService() *StatisticsService {
 	return &StatisticsService{}
 }
 
+// conn 统计使用的数据库连接
+func (s *StatisticsService) conn() *gorm.DB {
+	if s.db != nil {
+		return s.db
+	}
+	return database.DB
+}
+
 // SuccessBaseDayStatistics 成功日统计（通用方法）
 // 参考 Python: success_base_day_statistics
 func (s *StatisticsService) SuccessBaseDayStatistics(
@@ -109,7 +119,7 @@ func (s *StatisticsService) successPayChannelDayStatistics(
 		return fmt.Errorf("通道统计缺少必要的索引字段")
 	}
 
-	err := database.DB.Clauses(clause.OnConflict{
+	err := s.conn().Clauses(clause.OnConflict{
 		Columns:   conflictColumns,
 		DoUpdates: clause.Assignments(doUpdatesMap),
 	}).Create(stats).Error
@@ -162,7 +172,7 @@ func (s *StatisticsService) successMerchantDayStatistics(
 		return fmt.Errorf("商户统计缺少 merchant_id")
 	}
 
-	err := database.DB.Clauses(clause.OnConflict{
+	err := s.conn().Clauses(clause.OnConflict{
 		Columns: []clause.Column{
 			{Name: "date"},
 			{Name: "merchant_id"},
@@ -225,7 +235,7 @@ func (s *StatisticsService) successTenantDayStatistics(
 		zap.Int64("success_money", successMoney),
 		zap.Int64("tax", tax))
 
-	err := database.DB.Clauses(clause.OnConflict{
+	err := s.conn().Clauses(clause.OnConflict{
 		Columns: []clause.Column{
 			{Name: "date"},
 			{Name: "tenant_id"},
@@ -239,7 +249,7 @@ func (s *StatisticsService) successTenantDayStatistics(
 
 	// 记录更新后的值（查询确认）
 	var updatedStats models.TenantDayStatistics
-	if err := database.DB.Where("date = ? AND tenant_id = ?", date, *stats.TenantID).First(&updatedStats).Error; err == nil {
+	if err := s.conn().Where("date = ? AND tenant_id = ?", date, *stats.TenantID).First(&updatedStats).Error; err == nil {
 		logger.Logger.Info("租户日统计更新成功",
 			zap.Time("date", date),
 			zap.Int64("tenant_id", *stats.TenantID),
@@ -303,7 +313,7 @@ func (s *StatisticsService) successWriteoffDayStatistics(
 		return fmt.Errorf("核销统计缺少 writeoff_id")
 	}
 
-	err := database.DB.Clauses(clause.OnConflict{
+	err := s.conn().Clauses(clause.OnConflict{
 		Columns: []clause.Column{
 			{Name: "date"},
 			{Name: "writeoff_id"},
@@ -372,7 +382,7 @@ func (s *StatisticsService) successWriteoffChannelDayStatistics(
 	}
 
 	// 唯一约束: (date, writeoff_id, pay_channel_id)
-	err := database.DB.Clauses(clause.OnConflict{
+	err := s.conn().Clauses(clause.OnConflict{
 		Columns: []clause.Column{
 			{Name: "date"},
 			{Name: "writeoff_id"},
@@ -400,13 +410,95 @@ func (s *StatisticsService) SubmitBaseDayStatistics(
 
 	// 根据类型选择不同的处理方式
 	switch v := stats.(type) {
+	case *models.PayChannelDayStatistics:
+		return s.submitPayChannelDayStatistics(ctx, v, dateOnly)
 	case *models.WriteOffChannelDayStatistics:
 		return s.submitWriteoffChannelDayStatistics(ctx, v, dateOnly)
+	case *models.DayStatistics:
+		return s.submitDayStatistics(ctx, v, dateOnly)
 	default:
 		return fmt.Errorf("不支持的统计类型: %T", stats)
 	}
 }
 
+// submitPayChannelDayStatistics 更新通道日统计（订单提交时）
+// 参考 Python: submit_base_day_statistics(PayChannelDayStatistics, ...)
+func (s *StatisticsService) submitPayChannelDayStatistics(
+	ctx context.Context,
+	stats *models.PayChannelDayStatistics,
+	date time.Time,
+) error {
+	stats.Date = date
+	if stats.SubmitCount == 0 {
+		stats.SubmitCount = 1
+	}
+	if stats.Ver == 0 {
+		stats.Ver = 1
+	}
+
+	if stats.PayChannelID == nil {
+		return fmt.Errorf("通道统计缺少 pay_channel_id")
+	}
+
+	// 唯一约束: (date, pay_channel_id, tenant_id, merchant_id, writeoff_id)，按实际设置的字段确定
+	conflictColumns := []clause.Column{{Name: "date"}, {Name: "pay_channel_id"}}
+	if stats.TenantID != nil {
+		conflictColumns = append(conflictColumns, clause.Column{Name: "tenant_id"})
+	}
+	if stats.MerchantID != nil {
+		conflictColumns = append(conflictColumns, clause.Column{Name: "merchant_id"})
+	}
+	if stats.WriteoffID != nil {
+		conflictColumns = append(conflictColumns, clause.Column{Name: "writeoff_id"})
+	}
+
+	err := s.conn().Clauses(clause.OnConflict{
+		Columns: conflictColumns,
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"submit_count": gorm.Expr("submit_count + 1"),
+			"ver":          gorm.Expr("ver + 1"),
+		}),
+	}).Create(stats).Error
+
+	if err != nil {
+		return fmt.Errorf("创建/更新通道日统计失败: %w", err)
+	}
+
+	return nil
+}
+
+// submitDayStatistics 更新全局日统计（订单提交时）
+func (s *StatisticsService) submitDayStatistics(
+	ctx context.Context,
+	stats *models.DayStatistics,
+	date time.Time,
+) error {
+	stats.Date = date
+	if stats.SubmitCount == 0 {
+		stats.SubmitCount = 1
+	}
+	if stats.Ver == 0 {
+		stats.Ver = 1
+	}
+
+	err := s.conn().Clauses(clause.OnConflict{
+		Columns: []clause.Column{
+			{Name: "date"},
+		},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"submit_count": gorm.Expr("submit_count + 1"),
+			"submit_money": gorm.Expr("submit_money + ?", stats.SubmitMoney),
+			"ver":          gorm.Expr("ver + 1"),
+		}),
+	}).Create(stats).Error
+
+	if err != nil {
+		return fmt.Errorf("创建/更新全局日统计失败: %w", err)
+	}
+
+	return nil
+}
+
 // submitWriteoffChannelDayStatistics 更新核销通道日统计（订单提交时）
 // 参考 Python: submit_base_day_statistics(WriteOffChannelDayStatistics, ...)
 func (s *StatisticsService) submitWriteoffChannelDayStatistics(
@@ -430,7 +522,7 @@ func (s *StatisticsService) submitWriteoffChannelDayStatistics(
 	}
 
 	// 唯一约束: (date, writeoff_id, pay_channel_id)
-	err := database.DB.Clauses(clause.OnConflict{
+	err := s.conn().Clauses(clause.OnConflict{
 		Columns: []clause.Column{
 			{Name: "date"},
 			{Name: "writeoff_id"},
@@ -493,7 +585,7 @@ func (s *StatisticsService) successDayStatistics(
 		zap.Int64("tax", tax),
 		zap.Int("device_fields_count", len(updateFields)))
 
-	err := database.DB.Clauses(clause.OnConflict{
+	err := s.conn().Clauses(clause.OnConflict{
 		Columns: []clause.Column{
 			{Name: "date"},
 		},
@@ -506,7 +598,7 @@ func (s *StatisticsService) successDayStatistics(
 
 	// 记录更新后的值（查询确认）
 	var updatedStats models.DayStatistics
-	if err := database.DB.Where("date = ?", date).First(&updatedStats).Error; err == nil {
+	if err := s.conn().Where("date = ?", date).First(&updatedStats).Error; err == nil {
 		logger.Logger.Info("全局日统计更新成功",
 			zap.Time("date", date),
 			zap.Int64("total_tax", updatedStats.TotalTax),
@@ -31,6 +31,7 @@ func (s *OrderService) waitProduct(ctx context.Context, orderCtx *OrderCreateCon
 		PluginID:       orderCtx.PluginID,
 		PluginType:     orderCtx.PluginType,
 		PluginUpstream: orderCtx.PluginUpstream,
+		ClientIP:       orderCtx.ClientIP,
 	}
 
 	// 添加关联对象（转换为 map）
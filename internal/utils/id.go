@@ -23,3 +23,12 @@ func GenerateOrderNo() string {
 	return fmt.Sprintf("%s%s%04d", prefix, timestamp, random)
 }
 
+
+// GenerateRefundNo 生成退款单号
+// 格式与订单号一致，前缀为 "REF"
+func GenerateRefundNo() string {
+	prefix := "REF"
+	timestamp := time.Now().Format("20060102150405")
+	random := time.Now().UnixNano() % 10000
+	return fmt.Sprintf("%s%s%04d", prefix, timestamp, random)
+}
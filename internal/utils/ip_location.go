@@ -14,9 +14,11 @@ import (
 
 // IPLocationInfo IP 归属地信息
 type IPLocationInfo struct {
-	Address string // 归属地（格式：省/市，如：广东省/深圳市）
-	PID     int    // 省份ID（代理省ip）
-	CID     int    // 城市ID（代理城市ip）
+	Address  string // 归属地（格式：省/市，如：广东省/深圳市）
+	Province string // 省份名称（未知时为空）
+	City     string // 城市名称（未知时为空）
+	PID      int    // 省份ID（代理省ip）
+	CID      int    // 城市ID（代理城市ip）
 }
 
 var (
@@ -184,10 +186,16 @@ func GetIPLocation(ip string) (*IPLocationInfo, error) {
 	}
 
 	// 过滤掉 "0" 和空字符串
-	if province != "" && province != "0" {
+	if province == "0" {
+		province = ""
+	}
+	if city == "0" {
+		city = ""
+	}
+	if province != "" {
 		addressParts = append(addressParts, province)
 	}
-	if city != "" && city != "0" {
+	if city != "" {
 		addressParts = append(addressParts, city)
 	}
 
@@ -199,9 +207,11 @@ func GetIPLocation(ip string) (*IPLocationInfo, error) {
 	// 注意：pid 和 cid 需要根据实际的省份/城市映射表来设置
 	// 这里暂时返回 -1，如果需要可以后续添加映射逻辑
 	return &IPLocationInfo{
-		Address: address,
-		PID:     -1, // TODO: 根据省份名称映射到省份ID
-		CID:     -1, // TODO: 根据城市名称映射到城市ID
+		Address:  address,
+		Province: province,
+		City:     city,
+		PID:      -1, // TODO: 根据省份名称映射到省份ID
+		CID:      -1, // TODO: 根据城市名称映射到城市ID
 	}, nil
 }
 
@@ -245,3 +255,16 @@ func isLocalIP(ip string) bool {
 
 	return false
 }
+
+// MatchRegion 地区名称是否匹配规则（规则可以省略"省"、"市"等后缀）
+func MatchRegion(name string, rules []string) bool {
+	if name == "" {
+		return false
+	}
+	for _, rule := range rules {
+		if rule = strings.TrimSpace(rule); rule != "" && strings.HasPrefix(name, rule) {
+			return true
+		}
+	}
+	return false
+}
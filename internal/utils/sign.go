@@ -11,29 +11,33 @@ import (
 
 // combineValues 组合参数值，移除 sign 字段，按 key 排序，拼接成 key=value 格式，最后加上 key={key}
 func combineValues(params map[string]interface{}, key string) string {
-	// 创建新的 map，移除 sign 字段
-	filtered := make(map[string]interface{})
-	for k, v := range params {
-		if k != "sign" && v != nil {
-			filtered[k] = v
-		}
+	content := SignContent(params, 0)
+	if content == "" {
+		return fmt.Sprintf("key=%s", key)
 	}
+	return content + fmt.Sprintf("&key=%s", key)
+}
 
-	// 按 key 排序
-	keys := make([]string, 0, len(filtered))
-	for k := range filtered {
+// SignContent 构建待签名字符串（不含密钥），按 key 排序拼接成 key=value&key=value 格式
+// 标准模式移除 sign 字段和 nil 值；兼容模式（compatible = 1）移除 sign、sign_type 字段和空值
+func SignContent(params map[string]interface{}, compatible int) string {
+	keys := make([]string, 0, len(params))
+	for k, v := range params {
+		if k == "sign" || v == nil {
+			continue
+		}
+		if compatible == 1 && (k == "sign_type" || v == "") {
+			continue
+		}
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
 
-	// 拼接参数
-	combinedValue := make([]string, 0, len(filtered)+1)
+	pairs := make([]string, 0, len(keys))
 	for _, k := range keys {
-		combinedValue = append(combinedValue, fmt.Sprintf("%s=%v", k, filtered[k]))
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, params[k]))
 	}
-	combinedValue = append(combinedValue, fmt.Sprintf("key=%s", key))
-
-	return strings.Join(combinedValue, "&")
+	return strings.Join(pairs, "&")
 }
 
 // md5Encryption MD5 加密并转大写
@@ -51,35 +55,26 @@ func toSign(data map[string]interface{}, key string) (string, string) {
 
 // yiSign 兼容模式签名方法
 func yiSign(params map[string]interface{}, key string) (string, string) {
-	// 过滤掉 sign、sign_type 和空值
-	type kv struct {
-		key   string
-		value interface{}
-	}
-	pairs := make([]kv, 0)
-	for k, v := range params {
-		if k != "sign" && k != "sign_type" && v != nil && v != "" {
-			pairs = append(pairs, kv{key: k, value: v})
-		}
-	}
-
-	// 按 key 排序
-	sort.Slice(pairs, func(i, j int) bool {
-		return pairs[i].key < pairs[j].key
-	})
-
-	// 拼接参数
-	urlEncodedParams := make([]string, 0, len(pairs))
-	for _, p := range pairs {
-		urlEncodedParams = append(urlEncodedParams, fmt.Sprintf("%s=%v", p.key, p.value))
-	}
-	signString := strings.Join(urlEncodedParams, "&") + key
+	// 过滤掉 sign、sign_type 和空值，按 key 排序拼接后追加密钥
+	signString := SignContent(params, 1) + key
 
 	// MD5 加密
 	md5Hash := md5Encryption(signString)
 	return signString, md5Hash
 }
 
+// GetEPaySign 易支付（EPay）协议签名
+// 规则与 yiSign 一致：过滤 sign、sign_type 和空值，按 key 排序拼接后追加商户密钥，MD5 取小写
+// 易支付 SDK 使用小写 MD5 并区分大小写比较，因此对外签名必须输出小写
+func GetEPaySign(params map[string]string, key string) string {
+	data := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		data[k] = v
+	}
+	_, sign := yiSign(data, key)
+	return strings.ToLower(sign)
+}
+
 // defaultUseList 默认使用的字段列表
 var defaultUseList = []string{"mchId", "channelId", "mchOrderNo", "amount", "notifyUrl", "jumpUrl"}
 
@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"container/list"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/golang-pay-core/internal/models"
+)
+
+// parsedSignKeyCacheSize 解析后密钥缓存的容量
+// 按最近使用淘汰，密钥轮换后旧密钥不再使用，会被新密钥逐步淘汰
+const parsedSignKeyCacheSize = 1024
+
+// parsedSignKeys 按算法和原始密钥缓存解析后的公钥、私钥（RSA 解析开销较大，避免每次验签重复解析）
+var parsedSignKeys = newSignKeyCache(parsedSignKeyCacheSize)
+
+// signKeyCache 容量有限的 LRU 缓存
+type signKeyCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // 最近使用的在前
+	items    map[string]*list.Element
+}
+
+// signKeyCacheEntry 缓存项
+type signKeyCacheEntry struct {
+	key    string
+	parsed interface{}
+}
+
+func newSignKeyCache(capacity int) *signKeyCache {
+	return &signKeyCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Load 读取缓存并标记为最近使用
+func (c *signKeyCache) Load(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*signKeyCacheEntry).parsed, true
+}
+
+// Store 写入缓存，超过容量时淘汰最久未使用的密钥
+func (c *signKeyCache) Store(key string, parsed interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		elem.Value.(*signKeyCacheEntry).parsed = parsed
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.order.PushFront(&signKeyCacheEntry{key: key, parsed: parsed})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*signKeyCacheEntry).key)
+	}
+}
+
+// SignWithKey 使用商户签名密钥对待签名字符串签名（content 由 SignContent 生成）
+// md5、hmac_sha256 传入共享密钥，rsa2 传入平台私钥
+func SignWithKey(signType, key, content string, compatible int) (string, error) {
+	switch signType {
+	case models.SignTypeMD5:
+		if compatible == 1 {
+			return md5Encryption(content + key), nil
+		}
+		if content == "" {
+			return md5Encryption("key=" + key), nil
+		}
+		return md5Encryption(content + "&key=" + key), nil
+	case models.SignTypeHMACSHA256:
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(content))
+		return strings.ToUpper(hex.EncodeToString(mac.Sum(nil))), nil
+	case models.SignTypeRSA2:
+		priv, err := parseSignKey(signType, key, true)
+		if err != nil {
+			return "", err
+		}
+		digest := sha256.Sum256([]byte(content))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, priv.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+		if err != nil {
+			return "", fmt.Errorf("RSA2 签名失败: %w", err)
+		}
+		return base64.StdEncoding.EncodeToString(sig), nil
+	default:
+		return "", fmt.Errorf("不支持的签名算法: %s", signType)
+	}
+}
+
+// VerifyWithKey 使用商户签名密钥验证签名
+// md5、hmac_sha256 传入共享密钥（不区分大小写，常量时间比较），rsa2 传入商户公钥
+func VerifyWithKey(signType, key, content, sign string, compatible int) bool {
+	if sign == "" || key == "" {
+		return false
+	}
+	switch signType {
+	case models.SignTypeMD5, models.SignTypeHMACSHA256:
+		expected, err := SignWithKey(signType, key, content, compatible)
+		if err != nil {
+			return false
+		}
+		return hmac.Equal([]byte(strings.ToUpper(sign)), []byte(expected))
+	case models.SignTypeRSA2:
+		pub, err := parseSignKey(signType, key, false)
+		if err != nil {
+			return false
+		}
+		// GET 请求未编码时 Base64 中的 + 会被解码为空格
+		sig, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(sign, " ", "+"))
+		if err != nil {
+			return false
+		}
+		digest := sha256.Sum256([]byte(content))
+		return rsa.VerifyPKCS1v15(pub.(*rsa.PublicKey), crypto.SHA256, digest[:], sig) == nil
+	default:
+		return false
+	}
+}
+
+// parseSignKey 解析 rsa2 的公钥或私钥
+// 支持 PEM 或 Base64 的 PKIX/PKCS#1 公钥、PKCS#8/PKCS#1 私钥
+func parseSignKey(signType, key string, private bool) (interface{}, error) {
+	// 缓存键使用密钥摘要，不保留原始密钥
+	digest := sha256.Sum256([]byte(key))
+	cacheKey := fmt.Sprintf("%s:%t:%s", signType, private, hex.EncodeToString(digest[:]))
+	if cached, ok := parsedSignKeys.Load(cacheKey); ok {
+		return cached, nil
+	}
+
+	der, err := decodeSignKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed interface{}
+	switch {
+	case signType == models.SignTypeRSA2 && private:
+		parsed, err = parseRSAPrivateKey(der)
+	case signType == models.SignTypeRSA2:
+		parsed, err = parseRSAPublicKey(der)
+	default:
+		err = fmt.Errorf("不支持的签名算法: %s", signType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	parsedSignKeys.Store(cacheKey, parsed)
+	return parsed, nil
+}
+
+// decodeSignKey 解码密钥：PEM 或 Base64
+func decodeSignKey(key string) ([]byte, error) {
+	key = strings.TrimSpace(key)
+	if strings.HasPrefix(key, "-----BEGIN") {
+		block, _ := pem.Decode([]byte(key))
+		if block == nil {
+			return nil, fmt.Errorf("密钥 PEM 格式错误")
+		}
+		return block.Bytes, nil
+	}
+
+	key = strings.Join(strings.Fields(key), "")
+	if key == "" {
+		return nil, fmt.Errorf("密钥为空")
+	}
+	der, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("密钥格式错误: %w", err)
+	}
+	return der, nil
+}
+
+// parseRSAPublicKey 解析 RSA 公钥（PKIX 或 PKCS#1）
+func parseRSAPublicKey(der []byte) (*rsa.PublicKey, error) {
+	if pub, err := x509.ParsePKIXPublicKey(der); err == nil {
+		if rsaKey, ok := pub.(*rsa.PublicKey); ok {
+			return rsaKey, nil
+		}
+		return nil, fmt.Errorf("不是 RSA 公钥")
+	}
+	pub, err := x509.ParsePKCS1PublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("解析 RSA 公钥失败: %w", err)
+	}
+	return pub, nil
+}
+
+// parseRSAPrivateKey 解析 RSA 私钥（PKCS#8 或 PKCS#1）
+func parseRSAPrivateKey(der []byte) (*rsa.PrivateKey, error) {
+	if priv, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		if rsaKey, ok := priv.(*rsa.PrivateKey); ok {
+			return rsaKey, nil
+		}
+		return nil, fmt.Errorf("不是 RSA 私钥")
+	}
+	priv, err := x509.ParsePKCS1PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("解析 RSA 私钥失败: %w", err)
+	}
+	return priv, nil
+}
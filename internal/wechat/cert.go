@@ -0,0 +1,170 @@
+package wechat
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// certificateTTL 平台证书缓存有效期（微信支付建议定期更新平台证书）
+	certificateTTL = 12 * time.Hour
+	// certificateMinRefresh 遇到未知证书序列号时的最小刷新间隔（避免频繁下载）
+	certificateMinRefresh = time.Minute
+)
+
+// certificateCache 平台证书缓存（按商户号缓存，进程内共享）
+type certificateCache struct {
+	keys      map[string]*rsa.PublicKey // 证书序列号 -> 公钥
+	fetchedAt time.Time
+}
+
+var (
+	certificateCaches   = make(map[string]*certificateCache)
+	certificateCachesMu sync.Mutex
+	// certificateDownloads 合并同一商户号的并发下载
+	certificateDownloads singleflight.Group
+)
+
+// certificateVerifier 平台证书验签器（按需下载并缓存平台证书）
+type certificateVerifier struct {
+	client *Client
+}
+
+func (v *certificateVerifier) Verify(serial, message, signature string) error {
+	publicKey, err := v.client.getPlatformKey(serial)
+	if err != nil {
+		return err
+	}
+	return verifySHA256WithRSA(publicKey, message, signature)
+}
+
+// getPlatformKey 获取平台证书公钥
+// 缓存过期或遇到未知序列号（平台证书轮换）时重新下载
+// 下载在锁外执行，同一商户号的并发刷新只下载一次，锁只用于读取和替换缓存
+func (c *Client) getPlatformKey(serial string) (*rsa.PublicKey, error) {
+	if keys, ok := c.cachedCertificates(serial); ok {
+		return lookupPlatformKey(keys, serial)
+	}
+
+	result, err, _ := certificateDownloads.Do(c.MchID, func() (interface{}, error) {
+		// 双重检查：等待期间其他请求可能已完成刷新
+		if keys, ok := c.cachedCertificates(serial); ok {
+			return keys, nil
+		}
+
+		keys, err := c.DownloadCertificates()
+		if err != nil {
+			return nil, err
+		}
+		certificateCachesMu.Lock()
+		certificateCaches[c.MchID] = &certificateCache{keys: keys, fetchedAt: time.Now()}
+		certificateCachesMu.Unlock()
+		return keys, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lookupPlatformKey(result.(map[string]*rsa.PublicKey), serial)
+}
+
+// cachedCertificates 返回可直接使用的缓存证书
+// 缓存未过期且包含该序列号，或刚刷新过（未到最小刷新间隔）时返回 true，否则需要重新下载
+func (c *Client) cachedCertificates(serial string) (map[string]*rsa.PublicKey, bool) {
+	certificateCachesMu.Lock()
+	defer certificateCachesMu.Unlock()
+
+	cache := certificateCaches[c.MchID]
+	if cache == nil || time.Since(cache.fetchedAt) >= certificateTTL {
+		return nil, false
+	}
+	if _, ok := cache.keys[serial]; ok || time.Since(cache.fetchedAt) < certificateMinRefresh {
+		return cache.keys, true
+	}
+	return nil, false
+}
+
+// lookupPlatformKey 按序列号查找平台证书公钥
+func lookupPlatformKey(keys map[string]*rsa.PublicKey, serial string) (*rsa.PublicKey, error) {
+	key, ok := keys[serial]
+	if !ok {
+		return nil, fmt.Errorf("平台证书不存在: %s", serial)
+	}
+	return key, nil
+}
+
+// certificatesResponse 下载平台证书响应
+type certificatesResponse struct {
+	Data []struct {
+		SerialNo           string `json:"serial_no"`
+		EffectiveTime      string `json:"effective_time"`
+		ExpireTime         string `json:"expire_time"`
+		EncryptCertificate struct {
+			Algorithm      string `json:"algorithm"`
+			Nonce          string `json:"nonce"`
+			AssociatedData string `json:"associated_data"`
+			Ciphertext     string `json:"ciphertext"`
+		} `json:"encrypt_certificate"`
+	} `json:"data"`
+}
+
+// DownloadCertificates 下载平台证书
+// 证书使用 APIv3 密钥加密，解密后用下载的证书验证应答签名
+func (c *Client) DownloadCertificates() (map[string]*rsa.PublicKey, error) {
+	header, body, err := c.request("GET", "/v3/certificates", nil, "微信支付下载平台证书")
+	if err != nil {
+		return nil, fmt.Errorf("下载平台证书失败: %w", err)
+	}
+
+	var resp certificatesResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("解析平台证书失败: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(resp.Data))
+	for _, item := range resp.Data {
+		certPEM, err := DecryptAES256GCM(c.APIV3Key,
+			item.EncryptCertificate.AssociatedData,
+			item.EncryptCertificate.Nonce,
+			item.EncryptCertificate.Ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("解密平台证书失败: %w", err)
+		}
+
+		serialNo, publicKey, err := parseCertificate(string(certPEM))
+		if err != nil {
+			return nil, err
+		}
+		if serialNo != item.SerialNo {
+			return nil, fmt.Errorf("平台证书序列号不匹配: %s != %s", serialNo, item.SerialNo)
+		}
+		keys[serialNo] = publicKey
+	}
+
+	// 使用下载的证书验证应答签名
+	verifier := &staticKeysVerifier{keys: keys}
+	message := fmt.Sprintf("%s\n%s\n%s\n",
+		header.Get("Wechatpay-Timestamp"), header.Get("Wechatpay-Nonce"), body)
+	if err := verifier.Verify(header.Get("Wechatpay-Serial"), message, header.Get("Wechatpay-Signature")); err != nil {
+		return nil, fmt.Errorf("平台证书应答验签失败: %w", err)
+	}
+
+	return keys, nil
+}
+
+// staticKeysVerifier 固定公钥集合验签器
+type staticKeysVerifier struct {
+	keys map[string]*rsa.PublicKey
+}
+
+func (v *staticKeysVerifier) Verify(serial, message, signature string) error {
+	key, ok := v.keys[serial]
+	if !ok {
+		return fmt.Errorf("平台证书不存在: %s", serial)
+	}
+	return verifySHA256WithRSA(key, message, signature)
+}
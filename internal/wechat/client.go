@@ -0,0 +1,240 @@
+package wechat
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/logger"
+	"github.com/golang-pay-core/internal/models"
+	"github.com/golang-pay-core/internal/safehttp"
+	"go.uber.org/zap"
+)
+
+// DefaultGateway 微信支付 APIv3 官方接口地址
+const DefaultGateway = "https://api.mch.weixin.qq.com"
+
+// DefaultOAuthGateway 微信网页授权接口地址（JSAPI 获取 openid）
+const DefaultOAuthGateway = "https://api.weixin.qq.com"
+
+// Client 微信支付 APIv3 客户端
+type Client struct {
+	MchID        string
+	AppID        string
+	AppSecret    string
+	SerialNo     string          // 商户证书序列号
+	PrivateKey   *rsa.PrivateKey // 商户API私钥（请求签名、JSAPI 调起支付签名）
+	APIV3Key     string          // APIv3 密钥（回调和平台证书解密）
+	Gateway      string
+	OAuthGateway string
+	NotifyURL    string
+	HTTPClient   *http.Client
+	// verifier 微信支付验签器（微信支付公钥或平台证书）
+	verifier Verifier
+	// 用于记录 query_log 的订单信息
+	OrderNo    string // 系统订单号
+	OutOrderNo string // 外部订单号
+}
+
+// APIError 微信支付接口错误（HTTP 状态码非 2xx）
+type APIError struct {
+	StatusCode int
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("微信支付接口错误: status=%d, code=%s, message=%s", e.StatusCode, e.Code, e.Message)
+}
+
+// NewClient 创建微信支付客户端
+// 配置了微信支付公钥时使用公钥验签，否则按需下载平台证书验签
+func NewClient(product *models.WechatProduct, notifyURL string) (*Client, error) {
+	if product.MchID == "" || product.SerialNo == "" {
+		return nil, fmt.Errorf("微信支付产品缺少商户号或证书序列号")
+	}
+	if product.APIV3Key != "" && len(product.APIV3Key) != 32 {
+		return nil, fmt.Errorf("APIv3密钥长度必须为32字节")
+	}
+
+	privateKey, err := parsePrivateKey(product.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("解析商户API私钥失败: %w", err)
+	}
+
+	gateway := strings.TrimRight(product.Gateway, "/")
+	if gateway == "" {
+		gateway = DefaultGateway
+	}
+
+	client := &Client{
+		MchID:        product.MchID,
+		AppID:        product.AppID,
+		AppSecret:    product.AppSecret,
+		SerialNo:     product.SerialNo,
+		PrivateKey:   privateKey,
+		APIV3Key:     product.APIV3Key,
+		Gateway:      gateway,
+		OAuthGateway: DefaultOAuthGateway,
+		NotifyURL:    notifyURL,
+		HTTPClient:   safehttp.NewClient(15 * time.Second),
+	}
+
+	if product.PublicKeyID != "" && product.PublicKey != "" {
+		publicKey, err := parsePublicKey(product.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("解析微信支付公钥失败: %w", err)
+		}
+		client.verifier = &publicKeyVerifier{keyID: product.PublicKeyID, publicKey: publicKey}
+	} else {
+		if product.APIV3Key == "" {
+			return nil, fmt.Errorf("平台证书模式下必须配置APIv3密钥")
+		}
+		client.verifier = &certificateVerifier{client: client}
+	}
+
+	return client, nil
+}
+
+// doRequest 发送签名请求并验证应答签名
+// path 为包含查询参数的请求路径（如 /v3/pay/transactions/h5），body 为 nil 时不发送请求体
+// 请求和响应记录到 dvadmin_query_log
+func (c *Client) doRequest(method, path string, body interface{}, result interface{}, remarks string) error {
+	header, respBody, err := c.request(method, path, body, remarks)
+	if err != nil {
+		return err
+	}
+
+	if err := c.verifyResponse(header, respBody); err != nil {
+		return fmt.Errorf("应答验签失败: %w", err)
+	}
+
+	if result != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return fmt.Errorf("解析响应失败: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// request 发送签名请求，返回应答头和应答报文（不验签，HTTP 状态码非 2xx 时返回 *APIError）
+func (c *Client) request(method, path string, body interface{}, remarks string) (http.Header, []byte, error) {
+	var bodyBytes []byte
+	if body != nil {
+		var err error
+		bodyBytes, err = json.Marshal(body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("序列化请求参数失败: %w", err)
+		}
+	}
+
+	authorization, err := c.buildAuthorization(method, path, bodyBytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("生成请求签名失败: %w", err)
+	}
+
+	requestURL := c.Gateway + path
+	req, err := http.NewRequest(method, requestURL, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "golang-pay-core")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		go c.createQueryLog(requestURL, method, string(bodyBytes), "", fmt.Sprintf("请求失败: %v", err), remarks)
+		return nil, nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 记录 query_log（异步执行，不阻塞主流程）
+	go c.createQueryLog(requestURL, method, string(bodyBytes), fmt.Sprintf("%d", resp.StatusCode), string(respBody), remarks)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		_ = json.Unmarshal(respBody, apiErr)
+		return nil, nil, apiErr
+	}
+
+	return resp.Header, respBody, nil
+}
+
+// buildAuthorization 构建 Authorization 请求头
+// 签名串：HTTP请求方法\nURL\n请求时间戳\n请求随机串\n请求报文主体\n
+func (c *Client) buildAuthorization(method, path string, body []byte) (string, error) {
+	timestamp := time.Now().Unix()
+	nonce := generateNonce()
+	message := fmt.Sprintf("%s\n%s\n%d\n%s\n%s\n", method, path, timestamp, nonce, body)
+
+	signature, err := signSHA256WithRSA(c.PrivateKey, message)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`%s mchid="%s",nonce_str="%s",signature="%s",timestamp="%d",serial_no="%s"`,
+		AuthorizationSchema, c.MchID, nonce, signature, timestamp, c.SerialNo), nil
+}
+
+// verifyResponse 验证应答签名
+// 验签串：应答时间戳\n应答随机串\n应答报文主体\n
+func (c *Client) verifyResponse(header http.Header, body []byte) error {
+	timestamp := header.Get("Wechatpay-Timestamp")
+	nonce := header.Get("Wechatpay-Nonce")
+	signature := header.Get("Wechatpay-Signature")
+	serial := header.Get("Wechatpay-Serial")
+	if timestamp == "" || nonce == "" || signature == "" || serial == "" {
+		return fmt.Errorf("缺少应答签名头")
+	}
+
+	// 微信支付的探测流量签名以 WECHATPAY/SIGNTEST/ 开头，直接判定为失败
+	if strings.HasPrefix(signature, "WECHATPAY/SIGNTEST/") {
+		return fmt.Errorf("签名探测流量")
+	}
+
+	message := fmt.Sprintf("%s\n%s\n%s\n", timestamp, nonce, body)
+	return c.verifier.Verify(serial, message, signature)
+}
+
+// createQueryLog 创建查询日志（记录微信支付 API 调用）
+// 注意：query_log 只创建不更新，每次 API 调用都创建一条新记录
+func (c *Client) createQueryLog(url, requestMethod, requestBody, responseCode, jsonResult, remarks string) {
+	if database.DB == nil {
+		return
+	}
+
+	now := time.Now()
+	queryLog := &models.QueryLog{
+		OutOrderNo:     c.OutOrderNo,
+		OrderNo:        c.OrderNo,
+		URL:            url,
+		RequestBody:    requestBody,
+		RequestMethod:  requestMethod,
+		ResponseCode:   responseCode,
+		JSONResult:     jsonResult,
+		Remarks:        remarks,
+		CreateDatetime: &now,
+	}
+
+	if err := database.DB.Create(queryLog).Error; err != nil && logger.Logger != nil {
+		logger.Logger.Warn("创建查询日志失败",
+			zap.String("out_order_no", c.OutOrderNo),
+			zap.String("order_no", c.OrderNo),
+			zap.String("url", url),
+			zap.Error(err))
+	}
+}
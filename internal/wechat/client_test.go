@@ -0,0 +1,420 @@
+package wechat
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-pay-core/internal/models"
+)
+
+const (
+	testMchID       = "1900000001"
+	testAppID       = "wx0000000000000001"
+	testSerialNo    = "MERCHANT_SERIAL_TEST"
+	testAPIV3Key    = "0123456789abcdef0123456789abcdef"
+	testPublicKeyID = "PUB_KEY_ID_TEST"
+)
+
+// stubGateway 本地微信支付网关桩：校验请求签名，并使用平台私钥对应答签名
+type stubGateway struct {
+	t            *testing.T
+	server       *httptest.Server
+	merchantKey  *rsa.PublicKey
+	platformKey  *rsa.PrivateKey
+	serial       string        // 应答签名使用的序列号（公钥ID或平台证书序列号）
+	certificates []byte        // /v3/certificates 应答
+	certGate     chan struct{} // 非空时下载平台证书等待该通道关闭
+	downloads    atomic.Int32  // 下载平台证书次数
+	mu           sync.Mutex
+	requests     map[string]string
+}
+
+func newStubGateway(t *testing.T, merchantKey *rsa.PublicKey, platformKey *rsa.PrivateKey, serial string) *stubGateway {
+	g := &stubGateway{
+		t:           t,
+		merchantKey: merchantKey,
+		platformKey: platformKey,
+		serial:      serial,
+		requests:    make(map[string]string),
+	}
+	g.server = httptest.NewServer(http.HandlerFunc(g.handle))
+	t.Cleanup(g.server.Close)
+	return g
+}
+
+func (g *stubGateway) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if err := g.verifyAuthorization(r, body); err != nil {
+		g.reply(w, http.StatusUnauthorized, `{"code":"SIGN_ERROR","message":"`+err.Error()+`"}`)
+		return
+	}
+	g.mu.Lock()
+	g.requests[r.Method+" "+r.URL.Path] = string(body)
+	g.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/v3/pay/transactions/h5":
+		g.reply(w, http.StatusOK, `{"h5_url":"https://wx.tenpay.com/cgi-bin/mmpayweb-bin/checkmweb?prepay_id=wx_test"}`)
+	case r.URL.Path == "/v3/pay/transactions/native":
+		g.reply(w, http.StatusOK, `{"code_url":"weixin://wxpay/bizpayurl?pr=test"}`)
+	case r.URL.Path == "/v3/pay/transactions/jsapi":
+		g.reply(w, http.StatusOK, `{"prepay_id":"wx_prepay_test"}`)
+	case r.URL.Path == "/v3/pay/transactions/out-trade-no/NOT_EXIST":
+		g.reply(w, http.StatusNotFound, `{"code":"ORDER_NOT_EXIST","message":"订单不存在"}`)
+	case strings.HasPrefix(r.URL.Path, "/v3/pay/transactions/out-trade-no/") && strings.HasSuffix(r.URL.Path, "/close"):
+		g.reply(w, http.StatusNoContent, "")
+	case strings.HasPrefix(r.URL.Path, "/v3/pay/transactions/out-trade-no/"):
+		outTradeNo := strings.TrimPrefix(r.URL.Path, "/v3/pay/transactions/out-trade-no/")
+		g.reply(w, http.StatusOK, fmt.Sprintf(`{"mchid":"%s","out_trade_no":"%s","transaction_id":"4200000001","trade_state":"SUCCESS","amount":{"total":100,"payer_total":100}}`,
+			r.URL.Query().Get("mchid"), outTradeNo))
+	case r.URL.Path == "/v3/certificates":
+		g.downloads.Add(1)
+		if g.certGate != nil {
+			<-g.certGate
+		}
+		g.reply(w, http.StatusOK, string(g.certificates))
+	default:
+		g.reply(w, http.StatusNotFound, `{"code":"NOT_FOUND","message":"not found"}`)
+	}
+}
+
+// verifyAuthorization 校验商户请求签名
+func (g *stubGateway) verifyAuthorization(r *http.Request, body []byte) error {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, AuthorizationSchema+" ") {
+		return fmt.Errorf("invalid schema")
+	}
+	fields := make(map[string]string)
+	for _, part := range strings.Split(strings.TrimPrefix(auth, AuthorizationSchema+" "), ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) == 2 {
+			fields[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+	}
+	if fields["mchid"] != testMchID || fields["serial_no"] != testSerialNo {
+		return fmt.Errorf("invalid mchid or serial_no")
+	}
+	message := fmt.Sprintf("%s\n%s\n%s\n%s\n%s\n", r.Method, r.URL.RequestURI(), fields["timestamp"], fields["nonce_str"], body)
+	return verifySHA256WithRSA(g.merchantKey, message, fields["signature"])
+}
+
+func (g *stubGateway) reply(w http.ResponseWriter, status int, body string) {
+	for k, v := range g.signHeaders(body) {
+		w.Header().Set(k, v)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(body))
+}
+
+func (g *stubGateway) signHeaders(body string) map[string]string {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := generateNonce()
+	signature, err := signSHA256WithRSA(g.platformKey, fmt.Sprintf("%s\n%s\n%s\n", timestamp, nonce, body))
+	if err != nil {
+		g.t.Fatalf("sign response: %v", err)
+	}
+	return map[string]string{
+		"Wechatpay-Timestamp": timestamp,
+		"Wechatpay-Nonce":     nonce,
+		"Wechatpay-Signature": signature,
+		"Wechatpay-Serial":    g.serial,
+	}
+}
+
+func encryptResource(t *testing.T, plaintext, associatedData string) (nonce, ciphertext string) {
+	block, err := aes.NewCipher([]byte(testAPIV3Key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCMWithNonceSize(block, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce = "abcdef123456"
+	return nonce, base64.StdEncoding.EncodeToString(aead.Seal(nil, []byte(nonce), []byte(plaintext), []byte(associatedData)))
+}
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newTestProduct(t *testing.T, merchantKey *rsa.PrivateKey, gateway string) *models.WechatProduct {
+	der, err := x509.MarshalPKCS8PrivateKey(merchantKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &models.WechatProduct{
+		MchID:      testMchID,
+		AppID:      testAppID,
+		SerialNo:   testSerialNo,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		APIV3Key:   testAPIV3Key,
+		Gateway:    gateway,
+	}
+}
+
+func TestClientWithPublicKey(t *testing.T) {
+	merchantKey := generateKey(t)
+	platformKey := generateKey(t)
+	gateway := newStubGateway(t, &merchantKey.PublicKey, platformKey, testPublicKeyID)
+
+	product := newTestProduct(t, merchantKey, gateway.server.URL)
+	der, err := x509.MarshalPKIXPublicKey(&platformKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	product.PublicKeyID = testPublicKeyID
+	product.PublicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	client, err := NewClient(product, "https://pay.example.com/api/pay/order/notify/wechat_h5/1/")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	client.HTTPClient = gateway.server.Client()
+
+	h5URL, err := client.H5Prepay(&PrepayRequest{Description: "test", OutTradeNo: "P001", Total: 100, PayerClientIP: "1.2.3.4"})
+	if err != nil {
+		t.Fatalf("H5Prepay: %v", err)
+	}
+	if !strings.Contains(h5URL, "prepay_id=wx_test") {
+		t.Fatalf("unexpected h5_url: %s", h5URL)
+	}
+	var h5Body map[string]interface{}
+	_ = json.Unmarshal([]byte(gateway.requests["POST /v3/pay/transactions/h5"]), &h5Body)
+	if h5Body["out_trade_no"] != "P001" || h5Body["notify_url"] != client.NotifyURL {
+		t.Fatalf("unexpected h5 request body: %v", h5Body)
+	}
+
+	prepayID, err := client.JSAPIPrepay(&PrepayRequest{Description: "test", OutTradeNo: "P002", Total: 100, OpenID: "openid"})
+	if err != nil {
+		t.Fatalf("JSAPIPrepay: %v", err)
+	}
+	params, err := client.BuildJSAPIPayParams(prepayID)
+	if err != nil {
+		t.Fatalf("BuildJSAPIPayParams: %v", err)
+	}
+	message := fmt.Sprintf("%s\n%s\n%s\n%s\n", params.AppID, params.TimeStamp, params.NonceStr, params.Package)
+	if err := verifySHA256WithRSA(&merchantKey.PublicKey, message, params.PaySign); err != nil {
+		t.Fatalf("invalid paySign: %v", err)
+	}
+
+	transaction, err := client.QueryByOutTradeNo("P001")
+	if err != nil {
+		t.Fatalf("QueryByOutTradeNo: %v", err)
+	}
+	if !transaction.IsPaid() || transaction.TransactionID != "4200000001" || transaction.MchID != testMchID {
+		t.Fatalf("unexpected transaction: %+v", transaction)
+	}
+
+	if _, err := client.QueryByOutTradeNo("NOT_EXIST"); !IsOrderNotExist(err) {
+		t.Fatalf("expected ORDER_NOT_EXIST, got %v", err)
+	}
+
+	if err := client.CloseOrder("P003"); err != nil {
+		t.Fatalf("CloseOrder: %v", err)
+	}
+
+	// 应答签名被篡改（序列号不匹配）时拒绝
+	gateway.serial = "OTHER_KEY_ID"
+	if _, err := client.NativePrepay(&PrepayRequest{Description: "test", OutTradeNo: "P004", Total: 100}); err == nil {
+		t.Fatal("expected response verification failure")
+	}
+}
+
+// newCertificateGateway 创建使用平台证书签名的网关桩，测试结束后清除平台证书缓存
+func newCertificateGateway(t *testing.T, merchantKey *rsa.PrivateKey) (*stubGateway, string) {
+	platformKey := generateKey(t)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(0x5157F09EFDC096DE),
+		Subject:      pkix.Name{CommonName: "Tenpay.com Root CA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &platformKey.PublicKey, platformKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial := strings.ToUpper(template.SerialNumber.Text(16))
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}))
+
+	gateway := newStubGateway(t, &merchantKey.PublicKey, platformKey, serial)
+	nonce, ciphertext := encryptResource(t, certPEM, "certificate")
+	gateway.certificates, _ = json.Marshal(map[string]interface{}{
+		"data": []map[string]interface{}{{
+			"serial_no": serial,
+			"encrypt_certificate": map[string]string{
+				"algorithm":       "AEAD_AES_256_GCM",
+				"nonce":           nonce,
+				"associated_data": "certificate",
+				"ciphertext":      ciphertext,
+			},
+		}},
+	})
+
+	clearCertificateCache := func() {
+		certificateCachesMu.Lock()
+		delete(certificateCaches, testMchID)
+		certificateCachesMu.Unlock()
+	}
+	clearCertificateCache()
+	t.Cleanup(clearCertificateCache)
+	return gateway, serial
+}
+
+func TestClientWithPlatformCertificate(t *testing.T) {
+	merchantKey := generateKey(t)
+	gateway, _ := newCertificateGateway(t, merchantKey)
+
+	client, err := NewClient(newTestProduct(t, merchantKey, gateway.server.URL), "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	client.HTTPClient = gateway.server.Client()
+
+	codeURL, err := client.NativePrepay(&PrepayRequest{Description: "test", OutTradeNo: "P005", Total: 100})
+	if err != nil {
+		t.Fatalf("NativePrepay: %v", err)
+	}
+	if codeURL != "weixin://wxpay/bizpayurl?pr=test" {
+		t.Fatalf("unexpected code_url: %s", codeURL)
+	}
+
+	// 回调通知：验签并解密
+	transactionJSON := `{"mchid":"1900000001","out_trade_no":"P005","transaction_id":"4200000002","trade_state":"SUCCESS","amount":{"total":100,"payer_total":100}}`
+	nonce, ciphertext := encryptResource(t, transactionJSON, "transaction")
+	notifyBody, _ := json.Marshal(map[string]interface{}{
+		"id":            "EV-2018022511223320873",
+		"event_type":    EventTypeTransactionSuccess,
+		"resource_type": "encrypt-resource",
+		"resource": map[string]string{
+			"algorithm":       "AEAD_AES_256_GCM",
+			"ciphertext":      ciphertext,
+			"associated_data": "transaction",
+			"original_type":   "transaction",
+			"nonce":           nonce,
+		},
+	})
+	header := http.Header{}
+	for k, v := range gateway.signHeaders(string(notifyBody)) {
+		header.Set(k, v)
+	}
+
+	notification, transaction, err := client.ParseTransactionNotify(header, notifyBody)
+	if err != nil {
+		t.Fatalf("ParseTransactionNotify: %v", err)
+	}
+	if notification.EventType != EventTypeTransactionSuccess || transaction.OutTradeNo != "P005" || transaction.Amount.PayerTotal != 100 {
+		t.Fatalf("unexpected notify: %+v %+v", notification, transaction)
+	}
+
+	// 报文被篡改时验签失败
+	tampered := strings.Replace(string(notifyBody), "EV-", "XX-", 1)
+	if _, _, err := client.ParseTransactionNotify(header, []byte(tampered)); err == nil {
+		t.Fatal("expected notify verification failure")
+	}
+}
+
+func TestGetPlatformKeyConcurrentRefresh(t *testing.T) {
+	merchantKey := generateKey(t)
+	gateway, serial := newCertificateGateway(t, merchantKey)
+	gateway.certGate = make(chan struct{})
+
+	client, err := NewClient(newTestProduct(t, merchantKey, gateway.server.URL), "")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	client.HTTPClient = gateway.server.Client()
+
+	// 其他商户号的缓存证书
+	otherKey := generateKey(t)
+	certificateCachesMu.Lock()
+	certificateCaches["OTHER_MCH"] = &certificateCache{
+		keys:      map[string]*rsa.PublicKey{"OTHER_SERIAL": &otherKey.PublicKey},
+		fetchedAt: time.Now(),
+	}
+	certificateCachesMu.Unlock()
+	t.Cleanup(func() {
+		certificateCachesMu.Lock()
+		delete(certificateCaches, "OTHER_MCH")
+		certificateCachesMu.Unlock()
+	})
+
+	// 同一商户号的并发刷新只下载一次
+	const callers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key, err := client.getPlatformKey(serial)
+			if err == nil && key == nil {
+				err = fmt.Errorf("nil platform key")
+			}
+			errs <- err
+		}()
+	}
+
+	// 下载期间不持有缓存锁：其他商户号读取缓存不被阻塞
+	deadline := time.Now().Add(5 * time.Second)
+	for gateway.downloads.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := (&Client{MchID: "OTHER_MCH"}).getPlatformKey("OTHER_SERIAL")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("cached platform key: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cached lookup blocked by certificate download")
+	}
+
+	close(gateway.certGate)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("getPlatformKey: %v", err)
+		}
+	}
+	if n := gateway.downloads.Load(); n != 1 {
+		t.Fatalf("downloads = %d, want 1", n)
+	}
+
+	// 刷新后未知序列号在最小刷新间隔内不重新下载
+	if _, err := client.getPlatformKey("UNKNOWN_SERIAL"); err == nil {
+		t.Fatal("expected unknown serial error")
+	}
+	if n := gateway.downloads.Load(); n != 1 {
+		t.Fatalf("downloads = %d, want 1", n)
+	}
+}
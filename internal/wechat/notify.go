@@ -0,0 +1,92 @@
+package wechat
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/models"
+)
+
+// notifyTimestampTolerance 回调时间戳允许的最大偏差（防重放）
+const notifyTimestampTolerance = 5 * time.Minute
+
+// EventTypeTransactionSuccess 支付成功通知类型
+const EventTypeTransactionSuccess = "TRANSACTION.SUCCESS"
+
+// Notification 回调通知报文
+type Notification struct {
+	ID           string `json:"id"`
+	CreateTime   string `json:"create_time"`
+	EventType    string `json:"event_type"`
+	ResourceType string `json:"resource_type"`
+	Summary      string `json:"summary"`
+	Resource     struct {
+		Algorithm      string `json:"algorithm"`
+		Ciphertext     string `json:"ciphertext"`
+		AssociatedData string `json:"associated_data"`
+		OriginalType   string `json:"original_type"`
+		Nonce          string `json:"nonce"`
+	} `json:"resource"`
+}
+
+// ParseTransactionNotify 验证并解密支付回调通知
+// 1. 使用微信支付公钥/平台证书验证回调签名（并校验时间戳防重放）
+// 2. 使用 APIv3 密钥解密 resource 得到交易信息
+func (c *Client) ParseTransactionNotify(header http.Header, body []byte) (*Notification, *Transaction, error) {
+	timestamp, err := strconv.ParseInt(header.Get("Wechatpay-Timestamp"), 10, 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("回调时间戳格式错误")
+	}
+	if diff := time.Since(time.Unix(timestamp, 0)); diff > notifyTimestampTolerance || diff < -notifyTimestampTolerance {
+		return nil, nil, fmt.Errorf("回调时间戳已过期")
+	}
+
+	if err := c.verifyResponse(header, body); err != nil {
+		return nil, nil, fmt.Errorf("回调验签失败: %w", err)
+	}
+
+	var notification Notification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, nil, fmt.Errorf("解析回调报文失败: %w", err)
+	}
+	if notification.Resource.Algorithm != "AEAD_AES_256_GCM" {
+		return nil, nil, fmt.Errorf("不支持的加密算法: %s", notification.Resource.Algorithm)
+	}
+
+	plaintext, err := DecryptAES256GCM(c.APIV3Key,
+		notification.Resource.AssociatedData,
+		notification.Resource.Nonce,
+		notification.Resource.Ciphertext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解密回调资源失败: %w", err)
+	}
+
+	var transaction Transaction
+	if err := json.Unmarshal(plaintext, &transaction); err != nil {
+		return nil, nil, fmt.Errorf("解析交易信息失败: %w", err)
+	}
+	if transaction.OutTradeNo == "" {
+		return nil, nil, fmt.Errorf("缺少订单号")
+	}
+
+	return &notification, &transaction, nil
+}
+
+// GetWechatProductByID 根据产品ID获取微信支付产品信息
+func GetWechatProductByID(productID string) (*models.WechatProduct, error) {
+	productIDInt, err := strconv.ParseInt(productID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("产品ID格式错误: %w", err)
+	}
+
+	var product models.WechatProduct
+	if err := database.DB.Where("id = ?", productIDInt).First(&product).Error; err != nil {
+		return nil, fmt.Errorf("产品不存在: %w", err)
+	}
+
+	return &product, nil
+}
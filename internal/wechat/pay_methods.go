@@ -0,0 +1,192 @@
+package wechat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// PrepayRequest 下单请求参数
+type PrepayRequest struct {
+	Description   string // 商品描述
+	OutTradeNo    string // 商户订单号（使用系统订单号 order_no）
+	Total         int    // 订单金额（分）
+	NotifyURL     string // 回调地址（为空使用客户端 NotifyURL）
+	TimeExpire    *time.Time
+	PayerClientIP string // 用户终端IP（H5 必填）
+	OpenID        string // 用户 openid（JSAPI 必填）
+	Attach        string // 附加数据
+}
+
+// buildPrepayBody 构建下单请求体（H5、Native、JSAPI 公共部分）
+func (c *Client) buildPrepayBody(req *PrepayRequest) map[string]interface{} {
+	notifyURL := req.NotifyURL
+	if notifyURL == "" {
+		notifyURL = c.NotifyURL
+	}
+
+	body := map[string]interface{}{
+		"appid":        c.AppID,
+		"mchid":        c.MchID,
+		"description":  req.Description,
+		"out_trade_no": req.OutTradeNo,
+		"notify_url":   notifyURL,
+		"amount": map[string]interface{}{
+			"total":    req.Total,
+			"currency": "CNY",
+		},
+	}
+	if req.TimeExpire != nil {
+		body["time_expire"] = req.TimeExpire.Format(time.RFC3339)
+	}
+	if req.Attach != "" {
+		body["attach"] = req.Attach
+	}
+	return body
+}
+
+// H5Prepay H5 下单，返回 h5_url
+// 参考: POST /v3/pay/transactions/h5
+func (c *Client) H5Prepay(req *PrepayRequest) (string, error) {
+	if req.PayerClientIP == "" {
+		return "", fmt.Errorf("H5 下单缺少用户终端IP")
+	}
+
+	body := c.buildPrepayBody(req)
+	body["scene_info"] = map[string]interface{}{
+		"payer_client_ip": req.PayerClientIP,
+		"h5_info": map[string]interface{}{
+			"type": "Wap",
+		},
+	}
+
+	var resp struct {
+		H5URL string `json:"h5_url"`
+	}
+	if err := c.doRequest("POST", "/v3/pay/transactions/h5", body, &resp, "微信支付H5下单"); err != nil {
+		return "", err
+	}
+	if resp.H5URL == "" {
+		return "", fmt.Errorf("微信支付H5下单未返回 h5_url")
+	}
+	return resp.H5URL, nil
+}
+
+// NativePrepay Native 下单，返回二维码链接 code_url
+// 参考: POST /v3/pay/transactions/native
+func (c *Client) NativePrepay(req *PrepayRequest) (string, error) {
+	body := c.buildPrepayBody(req)
+
+	var resp struct {
+		CodeURL string `json:"code_url"`
+	}
+	if err := c.doRequest("POST", "/v3/pay/transactions/native", body, &resp, "微信支付Native下单"); err != nil {
+		return "", err
+	}
+	if resp.CodeURL == "" {
+		return "", fmt.Errorf("微信支付Native下单未返回 code_url")
+	}
+	return resp.CodeURL, nil
+}
+
+// JSAPIPrepay JSAPI 下单，返回 prepay_id
+// 参考: POST /v3/pay/transactions/jsapi
+func (c *Client) JSAPIPrepay(req *PrepayRequest) (string, error) {
+	if req.OpenID == "" {
+		return "", fmt.Errorf("JSAPI 下单缺少用户 openid")
+	}
+
+	body := c.buildPrepayBody(req)
+	body["payer"] = map[string]interface{}{
+		"openid": req.OpenID,
+	}
+
+	var resp struct {
+		PrepayID string `json:"prepay_id"`
+	}
+	if err := c.doRequest("POST", "/v3/pay/transactions/jsapi", body, &resp, "微信支付JSAPI下单"); err != nil {
+		return "", err
+	}
+	if resp.PrepayID == "" {
+		return "", fmt.Errorf("微信支付JSAPI下单未返回 prepay_id")
+	}
+	return resp.PrepayID, nil
+}
+
+// JSAPIPayParams JSAPI 调起支付参数（WeixinJSBridge.invoke('getBrandWCPayRequest', params)）
+type JSAPIPayParams struct {
+	AppID     string `json:"appId"`
+	TimeStamp string `json:"timeStamp"`
+	NonceStr  string `json:"nonceStr"`
+	Package   string `json:"package"`
+	SignType  string `json:"signType"`
+	PaySign   string `json:"paySign"`
+}
+
+// BuildJSAPIPayParams 生成 JSAPI 调起支付参数
+// 签名串：appId\ntimeStamp\nnonceStr\npackage\n
+func (c *Client) BuildJSAPIPayParams(prepayID string) (*JSAPIPayParams, error) {
+	params := &JSAPIPayParams{
+		AppID:     c.AppID,
+		TimeStamp: strconv.FormatInt(time.Now().Unix(), 10),
+		NonceStr:  generateNonce(),
+		Package:   "prepay_id=" + prepayID,
+		SignType:  "RSA",
+	}
+
+	message := fmt.Sprintf("%s\n%s\n%s\n%s\n", params.AppID, params.TimeStamp, params.NonceStr, params.Package)
+	paySign, err := signSHA256WithRSA(c.PrivateKey, message)
+	if err != nil {
+		return nil, fmt.Errorf("生成调起支付签名失败: %w", err)
+	}
+	params.PaySign = paySign
+	return params, nil
+}
+
+// OAuthAuthorizeURL 生成网页授权地址（snsapi_base 静默授权，用于 JSAPI 获取 openid）
+func (c *Client) OAuthAuthorizeURL(redirectURI, state string) string {
+	return fmt.Sprintf("https://open.weixin.qq.com/connect/oauth2/authorize?appid=%s&redirect_uri=%s&response_type=code&scope=snsapi_base&state=%s#wechat_redirect",
+		url.QueryEscape(c.AppID), url.QueryEscape(redirectURI), url.QueryEscape(state))
+}
+
+// OAuthOpenID 使用网页授权 code 换取用户 openid
+// 参考: GET /sns/oauth2/access_token
+func (c *Client) OAuthOpenID(code string) (string, error) {
+	if c.AppSecret == "" {
+		return "", fmt.Errorf("微信支付产品未配置应用密钥，无法获取 openid")
+	}
+
+	query := url.Values{}
+	query.Set("appid", c.AppID)
+	query.Set("secret", c.AppSecret)
+	query.Set("code", code)
+	query.Set("grant_type", "authorization_code")
+	requestURL := c.OAuthGateway + "/sns/oauth2/access_token?" + query.Encode()
+
+	resp, err := c.HTTPClient.Get(requestURL)
+	if err != nil {
+		return "", fmt.Errorf("获取 openid 请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	var result struct {
+		OpenID  string `json:"openid"`
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("解析响应失败: %w", err)
+	}
+	if result.ErrCode != 0 || result.OpenID == "" {
+		return "", fmt.Errorf("获取 openid 失败: errcode=%d, errmsg=%s", result.ErrCode, result.ErrMsg)
+	}
+	return result.OpenID, nil
+}
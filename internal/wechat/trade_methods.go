@@ -0,0 +1,151 @@
+package wechat
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// 交易状态（trade_state）
+const (
+	TradeStateSuccess    = "SUCCESS"    // 支付成功
+	TradeStateRefund     = "REFUND"     // 转入退款
+	TradeStateNotPay     = "NOTPAY"     // 未支付
+	TradeStateClosed     = "CLOSED"     // 已关闭
+	TradeStateRevoked    = "REVOKED"    // 已撤销（付款码支付）
+	TradeStateUserPaying = "USERPAYING" // 用户支付中（付款码支付）
+	TradeStatePayError   = "PAYERROR"   // 支付失败
+)
+
+// 退款状态（status）
+const (
+	RefundStatusSuccess    = "SUCCESS"    // 退款成功
+	RefundStatusClosed     = "CLOSED"     // 退款关闭
+	RefundStatusProcessing = "PROCESSING" // 退款处理中
+	RefundStatusAbnormal   = "ABNORMAL"   // 退款异常
+)
+
+// Transaction 交易信息（查单结果和支付回调解密后的资源）
+type Transaction struct {
+	AppID          string `json:"appid"`
+	MchID          string `json:"mchid"`
+	OutTradeNo     string `json:"out_trade_no"`
+	TransactionID  string `json:"transaction_id"`
+	TradeType      string `json:"trade_type"`
+	TradeState     string `json:"trade_state"`
+	TradeStateDesc string `json:"trade_state_desc"`
+	SuccessTime    string `json:"success_time"`
+	Attach         string `json:"attach"`
+	Payer          struct {
+		OpenID string `json:"openid"`
+	} `json:"payer"`
+	Amount struct {
+		Total      int    `json:"total"`       // 订单金额（分）
+		PayerTotal int    `json:"payer_total"` // 用户支付金额（分）
+		Currency   string `json:"currency"`
+	} `json:"amount"`
+}
+
+// IsPaid 交易是否已支付（支付成功或已转入退款）
+func (t *Transaction) IsPaid() bool {
+	return t.TradeState == TradeStateSuccess || t.TradeState == TradeStateRefund
+}
+
+// QueryByOutTradeNo 商户订单号查询订单
+// 参考: GET /v3/pay/transactions/out-trade-no/{out_trade_no}?mchid={mchid}
+// 订单不存在时返回的错误满足 IsOrderNotExist
+func (c *Client) QueryByOutTradeNo(outTradeNo string) (*Transaction, error) {
+	if outTradeNo == "" {
+		return nil, fmt.Errorf("out_trade_no 不能为空")
+	}
+
+	path := fmt.Sprintf("/v3/pay/transactions/out-trade-no/%s?mchid=%s", url.PathEscape(outTradeNo), url.QueryEscape(c.MchID))
+	var transaction Transaction
+	if err := c.doRequest("GET", path, nil, &transaction, "微信支付查询订单"); err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// CloseOrder 关闭订单（关闭后用户无法再支付）
+// 参考: POST /v3/pay/transactions/out-trade-no/{out_trade_no}/close
+// 订单已支付时返回的错误满足 IsOrderPaid
+func (c *Client) CloseOrder(outTradeNo string) error {
+	if outTradeNo == "" {
+		return fmt.Errorf("out_trade_no 不能为空")
+	}
+
+	path := fmt.Sprintf("/v3/pay/transactions/out-trade-no/%s/close", url.PathEscape(outTradeNo))
+	body := map[string]interface{}{
+		"mchid": c.MchID,
+	}
+	return c.doRequest("POST", path, body, nil, "微信支付关闭订单（订单超时）")
+}
+
+// RefundResult 退款结果
+type RefundResult struct {
+	RefundID      string `json:"refund_id"`
+	OutRefundNo   string `json:"out_refund_no"`
+	TransactionID string `json:"transaction_id"`
+	OutTradeNo    string `json:"out_trade_no"`
+	Status        string `json:"status"`
+	SuccessTime   string `json:"success_time"`
+	Amount        struct {
+		Refund int `json:"refund"` // 退款金额（分）
+		Total  int `json:"total"`  // 原订单金额（分）
+	} `json:"amount"`
+}
+
+// Refund 申请退款（同一 out_refund_no 重复请求保持幂等）
+// 参考: POST /v3/refund/domestic/refunds
+func (c *Client) Refund(outTradeNo, transactionID, outRefundNo, reason string, refund, total int) (*RefundResult, error) {
+	if outTradeNo == "" && transactionID == "" {
+		return nil, fmt.Errorf("out_trade_no 和 transaction_id 不能同时为空")
+	}
+
+	body := map[string]interface{}{
+		"out_refund_no": outRefundNo,
+		"amount": map[string]interface{}{
+			"refund":   refund,
+			"total":    total,
+			"currency": "CNY",
+		},
+	}
+	if transactionID != "" {
+		body["transaction_id"] = transactionID
+	} else {
+		body["out_trade_no"] = outTradeNo
+	}
+	if reason != "" {
+		body["reason"] = reason
+	}
+
+	var result RefundResult
+	if err := c.doRequest("POST", "/v3/refund/domestic/refunds", body, &result, "微信支付申请退款"); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// QueryRefund 查询单笔退款
+// 参考: GET /v3/refund/domestic/refunds/{out_refund_no}
+func (c *Client) QueryRefund(outRefundNo string) (*RefundResult, error) {
+	path := fmt.Sprintf("/v3/refund/domestic/refunds/%s", url.PathEscape(outRefundNo))
+	var result RefundResult
+	if err := c.doRequest("GET", path, nil, &result, "微信支付查询退款"); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// IsOrderNotExist 判断错误是否为订单不存在
+func IsOrderNotExist(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == "ORDER_NOT_EXIST"
+}
+
+// IsOrderPaid 判断错误是否为订单已支付（关单时返回）
+func IsOrderPaid(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == "ORDERPAID"
+}
@@ -21,16 +21,22 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-pay-core/config"
+	"github.com/golang-pay-core/internal/controller"
 	"github.com/golang-pay-core/internal/database"
+	"github.com/golang-pay-core/internal/ledger"
+	"github.com/golang-pay-core/internal/lifecycle"
 	"github.com/golang-pay-core/internal/logger"
 	"github.com/golang-pay-core/internal/mq"
+	"github.com/golang-pay-core/internal/outbox"
 	"github.com/golang-pay-core/internal/plugin"
-	_ "github.com/golang-pay-core/internal/plugin/alipay" // 导入以触发自动注册（包含 alipay_mock）
+	_ "github.com/golang-pay-core/internal/plugin/alipay"  // 导入以触发自动注册（包含 alipay_mock）
+	_ "github.com/golang-pay-core/internal/plugin/gateway" // 导入以触发自动注册（upstream_gateway）
+	_ "github.com/golang-pay-core/internal/plugin/wechat"  // 导入以触发自动注册（wechat_h5、wechat_native、wechat_jsapi）
 	"github.com/golang-pay-core/internal/router"
 	"github.com/golang-pay-core/internal/service"
 	"go.uber.org/zap"
@@ -39,27 +45,17 @@ import (
 )
 
 func main() {
-	// 加载配置
+	// 解析命令行参数（进程角色、配置文件、worker 消费的主题）
 	// 支持通过环境变量 APP_ENV 或命令行参数指定环境
 	// 环境变量优先级: 命令行参数 > 环境变量 APP_ENV > 默认 dev
-	configPath := ""
-	if len(os.Args) > 1 {
-		// 支持命令行参数: ./app --config=config/config.prod.yaml
-		// 或: ./app prod (自动选择 config.prod.yaml)
-		arg := os.Args[1]
-		if arg == "prod" || arg == "production" {
-			configPath = "config/config.prod.yaml"
-		} else if arg == "test" || arg == "testing" {
-			configPath = "config/config.test.yaml"
-		} else if arg == "dev" || arg == "development" {
-			configPath = "config/config.yaml"
-		} else if len(arg) > 0 && arg[0] != '-' {
-			// 如果参数不是以 - 开头，可能是配置文件路径
-			configPath = arg
-		}
+	opts, err := parseOptions(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n\n%s", err, usage)
+		os.Exit(2)
 	}
 
-	if err := config.Load(configPath); err != nil {
+	// 加载配置
+	if err := config.Load(opts.configPath); err != nil {
 		panic(fmt.Sprintf("加载配置失败: %v", err))
 	}
 
@@ -91,80 +87,374 @@ func main() {
 	plugin.SetConfigProvider(cacheService)
 	logger.Logger.Info("插件配置提供者已设置（使用 CacheService）")
 
-	refreshCtx := context.Background()
-
-	// 启动通知重试服务（每30秒检查一次失败的通知并重试）
-	notifyRetryService := service.NewNotifyRetryService()
-	go notifyRetryService.Start(refreshCtx)
-	logger.Logger.Info("通知重试服务已启动（每30秒检查一次失败的通知）")
-
-	// 启动订单超时检查服务（每30秒检查一次超时的订单）
-	orderTimeoutService := service.NewOrderTimeoutService()
-	go orderTimeoutService.Start(refreshCtx)
-	logger.Logger.Info("订单超时检查服务已启动（每30秒检查一次超时的订单）")
-
-	// 初始化全局 RocketMQ 生产者客户端（单例模式，避免重复创建）
-	mqProducer := mq.GetGlobalMQClient()
-	if mqProducer.IsEnabled() {
-		logger.Logger.Info("RocketMQ 生产者已启动")
-		defer func() {
-			if err := mqProducer.Close(); err != nil {
-				logger.Logger.Error("关闭 RocketMQ 生产者失败", zap.Error(err))
+	// 设置超时订单的上游查单器（关闭前主动查单，找回丢失的异步通知）
+	mq.SetUpstreamOrderChecker(service.NewOrderQueryService())
+	// 设置订单通知事件处理器（发件箱投递的兜底通知）、日统计事件处理器（幂等更新统计）
+	mq.SetOrderNotifyProcessor(service.NewOrderNotifyService())
+	mq.SetDayStatisticsProcessor(service.NewOrderSuccessHookService())
+
+	// 一次性余额核对：核对完成后输出结果并退出
+	if opts.role == roleReconcileBalance {
+		os.Exit(runBalanceReconcile(opts.repair))
+	}
+
+	// 一次性支付宝账单对账：对账完成后输出差异并退出
+	if opts.role == roleReconcileAlipay {
+		os.Exit(runAlipayBillReconcile(opts))
+	}
+
+	logger.Logger.Info("进程角色",
+		zap.String("role", opts.role),
+		zap.Strings("topics", opts.topics))
+
+	// 后台服务由 Supervisor 统一启动和停止：普通服务在所有实例上运行，
+	// 单实例服务（定时扫描、补偿任务）只在选举出的主节点上运行
+	supervisor := lifecycle.NewSupervisor()
+
+	// 设置路由（只有提供 HTTP 接口的角色需要）
+	var r http.Handler
+	if opts.runAPI() {
+		r = router.SetupRouter()
+	} else if opts.runWorker() {
+		// 不提供 HTTP 接口的 worker 仍需注入支付宝回调处理器（由回调控制器实现）
+		controller.NewNotifyController()
+	}
+
+	// 消息总线（RocketMQ 或 Redis Streams，由 bus.backend 决定）
+	// 所有角色都可能发送消息；只有 worker 订阅主题并消费
+	// 在路由之后启动：控制器创建时注入的处理器（如支付宝回调）需要在消费前就绪
+	bus := mq.GetBus()
+	if bus.IsEnabled() {
+		// worker 只指定了 notify-delivery 时不消费任何主题
+		consume := opts.runWorker() && (len(opts.topics) == 0 || len(opts.busTopics()) > 0)
+		if consume {
+			if err := mq.RegisterHandlers(bus, opts.busTopics()); err != nil {
+				logger.Logger.Fatal("订阅消息总线主题失败", zap.Error(err))
+			}
+		}
+		supervisor.Add("bus", func(ctx context.Context) {
+			if consume {
+				if err := bus.Start(ctx); err != nil {
+					logger.Logger.Warn("启动消息总线消费者失败",
+						zap.String("backend", bus.Backend()),
+						zap.Error(err))
+				} else {
+					logger.Logger.Info("消息总线已启动", zap.String("backend", bus.Backend()))
+				}
+			}
+			<-ctx.Done()
+			if err := bus.Close(); err != nil {
+				logger.Logger.Error("关闭消息总线失败", zap.Error(err))
 			}
-		}()
+		})
+
+		// 启动发件箱中继（将事务中写入的订单事件投递到消息总线，先于总线停止）
+		// 中继使用 SKIP LOCKED 领取事件，多个 worker 同时运行不会重复发送
+		if opts.runWorker() {
+			relay := outbox.NewRelay(bus)
+			supervisor.Add("outbox-relay", func(ctx context.Context) {
+				relay.Start(ctx)
+				<-ctx.Done()
+				relay.Stop()
+			})
+		}
 	}
 
-	// 初始化 RocketMQ 消费者（如果启用）
-	mqConsumer, err := mq.NewRocketMQConsumer()
-	if err != nil {
-		logger.Logger.Warn("初始化 RocketMQ 消费者失败",
-			zap.Error(err))
-	} else if mqConsumer.IsEnabled() {
-		logger.Logger.Info("RocketMQ 消费者已启动")
-		defer func() {
-			if err := mqConsumer.Close(); err != nil {
-				logger.Logger.Error("关闭 RocketMQ 消费者失败", zap.Error(err))
+	// 启动商户通知调度器（固定 worker 池投递通知，失败后按退避策略重试）
+	notifyDispatcher := service.GetNotifyDispatcher()
+	if opts.consumesTopic(topicNotifyDelivery) {
+		supervisor.Add("notify-dispatcher", notifyDispatcher.Start)
+	}
+
+	if opts.runScheduler() {
+		// 主节点恢复数据库中未完成的通知
+		supervisor.AddSingleton("notify-recover", func(ctx context.Context) {
+			notifyDispatcher.RecoverPending(ctx)
+			<-ctx.Done()
+		})
+
+		// 启动订单超时定时队列（消息总线不可用时处理超时订单）
+		supervisor.AddSingleton("order-timeout-queue", service.NewOrderTimeoutQueue().Start)
+
+		// 启动订单超时对账服务（低频扫描数据库，兜底延迟消息和定时队列）
+		supervisor.AddSingleton("order-timeout-scan", service.NewOrderTimeoutService().Start)
+
+		// 启动缓存刷新服务
+		supervisor.AddSingleton("cache-refresh", service.NewCacheRefreshService().Start)
+
+		// 启动账户余额快照服务
+		supervisor.AddSingleton("ledger-snapshot", ledger.NewSnapshotter().Start)
+
+		// 启动余额核对服务（Redis 与数据库余额、数据库余额与资金流水）
+		supervisor.AddSingleton("balance-reconcile", service.NewBalanceReconcileService().Start)
+	}
+
+	// HTTP 服务器最后启动、最先停止（停止接收请求后再停止后台服务）
+	if opts.runAPI() {
+		srv := &http.Server{
+			Addr:           fmt.Sprintf(":%d", config.Cfg.App.Port),
+			Handler:        r,
+			ReadTimeout:    config.Cfg.App.ReadTimeout,
+			WriteTimeout:   config.Cfg.App.WriteTimeout,
+			MaxHeaderBytes: 1 << 20, // 1MB
+		}
+
+		supervisor.Add("http", func(ctx context.Context) {
+			go func() {
+				logger.Logger.Info("服务器启动",
+					zap.String("address", srv.Addr),
+					zap.String("mode", config.Cfg.App.Mode),
+				)
+				if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					logger.Logger.Fatal("服务器启动失败", zap.Error(err))
+				}
+			}()
+
+			<-ctx.Done()
+			logger.Logger.Info("正在关闭服务器...")
+
+			// 设置 5 秒超时关闭服务器
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				logger.Logger.Error("服务器强制关闭", zap.Error(err))
+				return
 			}
-		}()
+			logger.Logger.Info("服务器已关闭")
+		})
+	}
+
+	// 运行直到收到 SIGINT/SIGTERM，然后按顺序停止所有服务
+	if err := supervisor.Run(context.Background()); err != nil {
+		logger.Logger.Error("停止后台服务超时", zap.Error(err))
+	}
+}
+
+// 进程角色
+const (
+	roleServe     = "serve"     // 全部功能（默认）
+	roleServeAPI  = "serve-api" // 只提供 HTTP 接口（下单、收银台、回调、管理接口）
+	roleWorker    = "worker"    // 消息总线消费者、发件箱中继、商户通知投递
+	roleScheduler = "scheduler" // 单实例定时任务（主节点选举后运行）
+
+	roleReconcileBalance = "reconcile-balance" // 执行一次余额核对后退出
+	roleReconcileAlipay  = "reconcile-alipay"  // 核对一个支付宝产品一天的账单后退出
+)
+
+// topicNotifyDelivery worker 的 --topics 中表示商户通知投递的名称（不是消息总线主题）
+const topicNotifyDelivery = "notify-delivery"
+
+const usage = `用法: app [serve|serve-api|worker|scheduler|reconcile-balance|reconcile-alipay] [prod|test|dev|配置文件路径] [--config=配置文件路径] [--topics=主题1,主题2] [--repair] [--product=产品ID --date=yyyy-MM-dd --bill=账单文件]
+
+  serve       HTTP 接口 + 消费者 + 定时任务（默认）
+  serve-api   只提供 HTTP 接口
+  worker      消费消息总线主题、发件箱中继和商户通知投递；--topics 指定只消费的主题（notify-delivery 表示商户通知投递）
+  scheduler   只运行单实例定时任务（订单超时、通知恢复、缓存刷新、余额核对），多实例部署时由主节点执行
+  reconcile-balance
+              执行一次余额核对，输出偏差后退出（存在需要人工处理的偏差时退出码为 1）；--repair 修复阈值内的 Redis 偏差
+  reconcile-alipay
+              核对支付宝产品（--product）某天（--date，默认昨天）的业务账单与本地订单，输出差异后退出（存在差异时退出码为 1）；
+              --bill 指定本地账单文件（zip 或 csv）时不访问支付宝
+`
+
+// options 命令行参数
+type options struct {
+	role       string
+	configPath string
+	topics     []string // worker 消费的主题（为空表示全部）
+	repair     bool     // reconcile-balance 是否自动修复
+	productID  int64    // reconcile-alipay 的支付宝产品ID
+	billDate   string   // reconcile-alipay 的账单日期
+	billPath   string   // reconcile-alipay 的本地账单文件
+}
+
+// runAPI 是否提供 HTTP 接口
+func (o *options) runAPI() bool {
+	return o.role == roleServe || o.role == roleServeAPI
+}
+
+// runWorker 是否运行消费者
+func (o *options) runWorker() bool {
+	return o.role == roleServe || o.role == roleWorker
+}
+
+// runScheduler 是否运行单实例定时任务
+func (o *options) runScheduler() bool {
+	return o.role == roleServe || o.role == roleScheduler
+}
+
+// consumesTopic worker 是否处理指定主题
+func (o *options) consumesTopic(topic string) bool {
+	if !o.runWorker() {
+		return false
 	}
+	if len(o.topics) == 0 {
+		return true
+	}
+	for _, t := range o.topics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// busTopics worker 订阅的消息总线主题（为空表示全部）
+func (o *options) busTopics() []string {
+	topics := make([]string, 0, len(o.topics))
+	for _, topic := range o.topics {
+		if topic != topicNotifyDelivery {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
+
+// parseOptions 解析命令行参数
+// 兼容原有用法：./app prod（自动选择 config.prod.yaml）或 ./app config/config.prod.yaml
+func parseOptions(args []string) (*options, error) {
+	opts := &options{role: roleServe}
+	roleSet := false
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		// --name=value 或 --name value
+		if strings.HasPrefix(arg, "-") {
+			name := strings.TrimLeft(arg, "-")
+			value := ""
+			if idx := strings.Index(name, "="); idx >= 0 {
+				name, value = name[:idx], name[idx+1:]
+			} else if name == "repair" {
+				value = "true" // 布尔参数不读取下一个参数
+			} else if i+1 < len(args) {
+				i++
+				value = args[i]
+			}
 
-	// 设置路由
-	r := router.SetupRouter()
+			switch name {
+			case "config":
+				opts.configPath = value
+			case "topics":
+				for _, topic := range strings.Split(value, ",") {
+					if topic = strings.TrimSpace(topic); topic != "" {
+						opts.topics = append(opts.topics, topic)
+					}
+				}
+			case "repair":
+				repair, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, fmt.Errorf("无效的 --repair: %s", value)
+				}
+				opts.repair = repair
+			case "product":
+				productID, err := strconv.ParseInt(value, 10, 64)
+				if err != nil || productID <= 0 {
+					return nil, fmt.Errorf("无效的 --product: %s", value)
+				}
+				opts.productID = productID
+			case "date":
+				if _, err := time.ParseInLocation("2006-01-02", value, time.Local); err != nil {
+					return nil, fmt.Errorf("无效的 --date: %s", value)
+				}
+				opts.billDate = value
+			case "bill":
+				opts.billPath = value
+			default:
+				return nil, fmt.Errorf("未知参数: %s", arg)
+			}
+			continue
+		}
 
-	// 创建 HTTP 服务器
-	srv := &http.Server{
-		Addr:           fmt.Sprintf(":%d", config.Cfg.App.Port),
-		Handler:        r,
-		ReadTimeout:    config.Cfg.App.ReadTimeout,
-		WriteTimeout:   config.Cfg.App.WriteTimeout,
-		MaxHeaderBytes: 1 << 20, // 1MB
+		switch arg {
+		case roleServe, roleServeAPI, roleWorker, roleScheduler, roleReconcileBalance, roleReconcileAlipay:
+			if roleSet {
+				return nil, fmt.Errorf("只能指定一个进程角色: %s", arg)
+			}
+			opts.role = arg
+			roleSet = true
+		case "prod", "production":
+			opts.configPath = "config/config.prod.yaml"
+		case "test", "testing":
+			opts.configPath = "config/config.test.yaml"
+		case "dev", "development":
+			opts.configPath = "config/config.yaml"
+		default:
+			// 其他参数视为配置文件路径
+			opts.configPath = arg
+		}
 	}
 
-	// 启动服务器（在 goroutine 中）
-	go func() {
-		logger.Logger.Info("服务器启动",
-			zap.String("address", srv.Addr),
-			zap.String("mode", config.Cfg.App.Mode),
-		)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Logger.Fatal("服务器启动失败", zap.Error(err))
+	if opts.repair && opts.role != roleReconcileBalance {
+		return nil, fmt.Errorf("--repair 只能用于 reconcile-balance")
+	}
+	if opts.role == roleReconcileAlipay {
+		if opts.productID == 0 {
+			return nil, fmt.Errorf("reconcile-alipay 需要指定 --product")
 		}
-	}()
+	} else if opts.productID != 0 || opts.billDate != "" || opts.billPath != "" {
+		return nil, fmt.Errorf("--product、--date、--bill 只能用于 reconcile-alipay")
+	}
+	if len(opts.topics) > 0 {
+		if opts.role != roleWorker {
+			return nil, fmt.Errorf("--topics 只能用于 worker")
+		}
+		for _, topic := range opts.topics {
+			if topic != topicNotifyDelivery && !mq.IsKnownTopic(topic) {
+				return nil, fmt.Errorf("未知的主题: %s", topic)
+			}
+		}
+	}
+	return opts, nil
+}
 
-	// 等待中断信号以优雅地关闭服务器
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+// runBalanceReconcile 执行一次余额核对并输出偏差，返回进程退出码
+func runBalanceReconcile(repair bool) int {
+	result, err := service.NewBalanceReconcileService().Run(context.Background(), repair)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "余额核对失败: %v\n", err)
+		return 2
+	}
 
-	logger.Logger.Info("正在关闭服务器...")
+	fmt.Printf("核对批次: %s，偏差: %d，需人工处理: %d\n", result.RunID, len(result.Reports), result.Alerts())
+	for _, report := range result.Reports {
+		fmt.Printf("  %-18s %-8d 期望 %-12d 实际 %-12d 偏差 %-10d %-8s %s\n",
+			report.CheckType, report.OwnerID, report.Expected, report.Actual, report.Drift, report.Action, report.Remarks)
+	}
+	if result.Alerts() > 0 {
+		return 1
+	}
+	return 0
+}
 
-	// 设置 5 秒超时关闭服务器
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// runAlipayBillReconcile 核对支付宝账单并输出差异，返回进程退出码
+func runAlipayBillReconcile(opts *options) int {
+	billDate := time.Now().AddDate(0, 0, -1)
+	if opts.billDate != "" {
+		billDate, _ = time.ParseInLocation("2006-01-02", opts.billDate, time.Local)
+	}
 
-	if err := srv.Shutdown(ctx); err != nil {
-		logger.Logger.Fatal("服务器强制关闭", zap.Error(err))
+	reconcileService := service.NewAlipayBillReconcileService()
+	var result *service.AlipayBillReconcileResult
+	var err error
+	if opts.billPath != "" {
+		result, err = reconcileService.ReconcileFile(context.Background(), opts.productID, billDate, opts.billPath)
+	} else {
+		result, err = reconcileService.ReconcileDownload(context.Background(), opts.productID, billDate)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "支付宝账单对账失败: %v\n", err)
+		return 2
 	}
 
-	logger.Logger.Info("服务器已关闭")
+	fmt.Printf("对账批次: %s，产品: %d，日期: %s，交易: %d，一致: %d，退款: %d，差异: %d\n",
+		result.RunID, result.ProductID, result.BillDate, result.Trades, result.Matched, result.Refunds, len(result.Diffs))
+	for _, diff := range result.Diffs {
+		fmt.Printf("  %-16s 订单 %-22s 商户订单号 %-32s 交易号 %-32s 本地 %-10d 账单 %-10d %s\n",
+			diff.DiffType, diff.OrderID, diff.OrderNo, diff.TradeNo, diff.LocalMoney, diff.BillMoney, diff.Remarks)
+	}
+	if len(result.Diffs) > 0 {
+		return 1
+	}
+	return 0
 }
@@ -327,6 +327,28 @@ CREATE TABLE `dvadmin_agent_payment` (
   KEY `dvadmin_agent_payment_order_id_927ccd0d` (`order_id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb3 COMMENT='代付';
 
+-- ----------------------------
+-- Table structure for dvadmin_alipay_bill_diff
+-- ----------------------------
+DROP TABLE IF EXISTS `dvadmin_alipay_bill_diff`;
+CREATE TABLE `dvadmin_alipay_bill_diff` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `run_id` varchar(32) NOT NULL COMMENT '对账批次',
+  `product_id` bigint NOT NULL COMMENT '支付宝产品',
+  `bill_date` varchar(10) NOT NULL COMMENT '账单日期',
+  `diff_type` varchar(16) NOT NULL COMMENT '差异类型',
+  `order_id` varchar(30) DEFAULT NULL COMMENT '系统订单',
+  `order_no` varchar(64) DEFAULT NULL COMMENT '商户订单号(out_trade_no)',
+  `trade_no` varchar(64) DEFAULT NULL COMMENT '支付宝交易号',
+  `local_money` int NOT NULL DEFAULT '0' COMMENT '本地订单金额(分)',
+  `bill_money` int NOT NULL DEFAULT '0' COMMENT '账单金额(分)',
+  `remarks` varchar(255) DEFAULT NULL COMMENT '备注',
+  `create_datetime` datetime(6) DEFAULT NULL COMMENT '创建时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_dvadmin_alipay_bill_diff_run_id` (`run_id`),
+  KEY `idx_alipay_bill_diff_product` (`product_id`,`bill_date`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='支付宝账单对账差异';
+
 -- ----------------------------
 -- Table structure for dvadmin_alipay_complain
 -- ----------------------------
@@ -987,6 +1009,26 @@ CREATE TABLE `dvadmin_api_white_list` (
   KEY `dvadmin_api_white_list_creator_id_fd335789` (`creator_id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb3 COMMENT='接口白名单';
 
+-- ----------------------------
+-- Table structure for dvadmin_balance_drift_report
+-- ----------------------------
+DROP TABLE IF EXISTS `dvadmin_balance_drift_report`;
+CREATE TABLE `dvadmin_balance_drift_report` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `run_id` varchar(32) NOT NULL COMMENT '核对批次',
+  `check_type` varchar(32) NOT NULL COMMENT '核对项',
+  `owner_id` bigint NOT NULL COMMENT '租户/核销ID',
+  `expected` bigint NOT NULL COMMENT '期望值',
+  `actual` bigint NOT NULL COMMENT '实际值',
+  `drift` bigint NOT NULL COMMENT '偏差',
+  `action` varchar(16) NOT NULL COMMENT '处理结果',
+  `remarks` varchar(255) DEFAULT NULL COMMENT '备注',
+  `create_datetime` datetime(6) DEFAULT NULL COMMENT '创建时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_dvadmin_balance_drift_report_run_id` (`run_id`),
+  KEY `idx_balance_drift_owner` (`check_type`,`owner_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='余额核对偏差报告';
+
 -- ----------------------------
 -- Table structure for dvadmin_ban_ip
 -- ----------------------------
@@ -1915,6 +1957,73 @@ CREATE TABLE `dvadmin_jt_product_day_statistics` (
   KEY `dvadmin_jt_product_day_statistics_product_id_d3727a4b` (`product_id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb3 COMMENT='JT商品每日统计';
 
+-- ----------------------------
+-- Table structure for dvadmin_ledger_account
+-- ----------------------------
+DROP TABLE IF EXISTS `dvadmin_ledger_account`;
+CREATE TABLE `dvadmin_ledger_account` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `account_type` varchar(16) NOT NULL COMMENT '账户类型',
+  `owner_id` bigint NOT NULL COMMENT '账户所属ID',
+  `balance` bigint NOT NULL DEFAULT '0' COMMENT '账本余额',
+  `create_datetime` datetime(6) DEFAULT NULL COMMENT '创建时间',
+  `update_datetime` datetime(6) DEFAULT NULL COMMENT '修改时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_ledger_account_owner` (`account_type`,`owner_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='账本账户';
+
+-- ----------------------------
+-- Table structure for dvadmin_ledger_entry
+-- ----------------------------
+DROP TABLE IF EXISTS `dvadmin_ledger_entry`;
+CREATE TABLE `dvadmin_ledger_entry` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `event_key` varchar(128) NOT NULL COMMENT '幂等键',
+  `entry_type` varchar(32) NOT NULL COMMENT '分录类型',
+  `order_id` varchar(30) DEFAULT NULL COMMENT '系统订单',
+  `pay_channel_id` bigint DEFAULT NULL COMMENT '支付通道',
+  `remarks` varchar(255) DEFAULT NULL COMMENT '备注',
+  `create_datetime` datetime(6) DEFAULT NULL COMMENT '创建时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_dvadmin_ledger_entry_event_key` (`event_key`),
+  KEY `idx_dvadmin_ledger_entry_order_id` (`order_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='账本分录';
+
+-- ----------------------------
+-- Table structure for dvadmin_ledger_posting
+-- ----------------------------
+DROP TABLE IF EXISTS `dvadmin_ledger_posting`;
+CREATE TABLE `dvadmin_ledger_posting` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `entry_id` bigint NOT NULL COMMENT '关联分录',
+  `account_id` bigint NOT NULL COMMENT '关联账户',
+  `amount` bigint NOT NULL COMMENT '变动金额',
+  `old_money` bigint NOT NULL COMMENT '变更前余额',
+  `new_money` bigint NOT NULL COMMENT '变更后余额',
+  `flow_type` int NOT NULL DEFAULT '0' COMMENT '兼容流水类型',
+  `tax` decimal(5,2) NOT NULL DEFAULT '0.00' COMMENT '费率',
+  `create_datetime` datetime(6) DEFAULT NULL COMMENT '创建时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_dvadmin_ledger_posting_entry_id` (`entry_id`),
+  KEY `idx_dvadmin_ledger_posting_account_id` (`account_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='账本分录明细';
+
+-- ----------------------------
+-- Table structure for dvadmin_ledger_snapshot
+-- ----------------------------
+DROP TABLE IF EXISTS `dvadmin_ledger_snapshot`;
+CREATE TABLE `dvadmin_ledger_snapshot` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `account_id` bigint NOT NULL COMMENT '关联账户',
+  `date` date NOT NULL COMMENT '日期',
+  `balance` bigint NOT NULL COMMENT '账本余额',
+  `last_posting_id` bigint NOT NULL DEFAULT '0' COMMENT '快照包含的最后一条明细',
+  `create_datetime` datetime(6) DEFAULT NULL COMMENT '创建时间',
+  `update_datetime` datetime(6) DEFAULT NULL COMMENT '修改时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_ledger_snapshot_account_date` (`account_id`,`date`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='账户余额日快照';
+
 -- ----------------------------
 -- Table structure for dvadmin_merchant
 -- ----------------------------
@@ -1929,6 +2038,9 @@ CREATE TABLE `dvadmin_merchant` (
   `creator_id` bigint DEFAULT NULL COMMENT '创建人',
   `system_user_id` bigint DEFAULT NULL COMMENT '绑定的系统用户',
   `parent_id` bigint NOT NULL COMMENT '上级租户',
+  `notify_format` varchar(16) NOT NULL DEFAULT '' COMMENT '通知格式',
+  `notify_success_body` varchar(64) NOT NULL DEFAULT '' COMMENT '通知成功应答',
+  `replay_protect` tinyint(1) NOT NULL DEFAULT '0' COMMENT '防重放',
   PRIMARY KEY (`id`),
   UNIQUE KEY `system_user_id` (`system_user_id`),
   KEY `dvadmin_merchant_parent_id_f0b2817b_fk_dvadmin_tenant_id` (`parent_id`),
@@ -1951,6 +2063,7 @@ CREATE TABLE `dvadmin_merchant_notification` (
   `ver` bigint NOT NULL,
   `creator_id` bigint DEFAULT NULL COMMENT '创建人',
   `order_id` varchar(30) NOT NULL COMMENT '关联订单',
+  `renotify_datetime` datetime(6) DEFAULT NULL COMMENT '手动重新通知时间',
   PRIMARY KEY (`id`),
   UNIQUE KEY `order_id` (`order_id`),
   KEY `dvadmin_merchant_notification_creator_id_80c42fad` (`creator_id`)
@@ -2064,6 +2177,29 @@ CREATE TABLE `dvadmin_merchant_pre_history` (
   KEY `dvadmin_merchant_pre_history_merchant_id_da901c0e` (`merchant_id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb3 COMMENT='商户预付历史记录';
 
+-- ----------------------------
+-- Table structure for dvadmin_merchant_sign_key
+-- ----------------------------
+DROP TABLE IF EXISTS `dvadmin_merchant_sign_key`;
+CREATE TABLE `dvadmin_merchant_sign_key` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `merchant_id` bigint NOT NULL COMMENT '商户ID',
+  `key_id` varchar(32) NOT NULL COMMENT '密钥编号',
+  `sign_type` varchar(16) NOT NULL COMMENT '签名算法（md5、hmac_sha256、rsa2）',
+  `secret` varchar(255) NOT NULL DEFAULT '' COMMENT '共享密钥（md5、hmac_sha256）',
+  `merchant_public_key` text COMMENT '商户公钥（rsa2 验证商户请求签名）',
+  `platform_private_key` text COMMENT '平台私钥（rsa2 签名响应和通知）',
+  `status` tinyint(1) NOT NULL DEFAULT '1' COMMENT '状态',
+  `valid_from` datetime(6) DEFAULT NULL COMMENT '生效时间',
+  `valid_until` datetime(6) DEFAULT NULL COMMENT '失效时间',
+  `remarks` varchar(255) DEFAULT NULL COMMENT '备注',
+  `create_datetime` datetime(6) DEFAULT NULL COMMENT '创建时间',
+  `update_datetime` datetime(6) DEFAULT NULL COMMENT '修改时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_merchant_sign_key` (`merchant_id`,`key_id`),
+  KEY `idx_dvadmin_merchant_sign_key_update_datetime` (`update_datetime`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='商户签名密钥';
+
 -- ----------------------------
 -- Table structure for dvadmin_message_center
 -- ----------------------------
@@ -2116,6 +2252,50 @@ CREATE TABLE `dvadmin_message_center_target_user` (
   KEY `dvadmin_message_center_target_user_users_id_9ff81ff5` (`users_id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb3 COMMENT='消息中心目标用户表';
 
+-- ----------------------------
+-- Table structure for dvadmin_mq_consume_record
+-- ----------------------------
+DROP TABLE IF EXISTS `dvadmin_mq_consume_record`;
+CREATE TABLE `dvadmin_mq_consume_record` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `dedupe_key` varchar(191) NOT NULL COMMENT '幂等键',
+  `topic` varchar(64) NOT NULL COMMENT '主题',
+  `message_id` varchar(64) DEFAULT NULL COMMENT '消息ID',
+  `token` varchar(64) NOT NULL COMMENT '处理令牌',
+  `status` int NOT NULL COMMENT '状态',
+  `expire_datetime` datetime(6) NOT NULL COMMENT '过期时间',
+  `create_datetime` datetime(6) DEFAULT NULL COMMENT '创建时间',
+  `update_datetime` datetime(6) DEFAULT NULL COMMENT '修改时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `dvadmin_mq_consume_record_dedupe_key` (`dedupe_key`),
+  KEY `dvadmin_mq_consume_record_expire_datetime` (`expire_datetime`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb3 COMMENT='消息消费幂等记录';
+
+-- ----------------------------
+-- Table structure for dvadmin_mq_dead_letter
+-- ----------------------------
+DROP TABLE IF EXISTS `dvadmin_mq_dead_letter`;
+CREATE TABLE `dvadmin_mq_dead_letter` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `backend` varchar(16) NOT NULL COMMENT '消息总线后端',
+  `topic` varchar(64) NOT NULL COMMENT '主题',
+  `tag` varchar(64) DEFAULT NULL COMMENT '标签',
+  `message_id` varchar(64) DEFAULT NULL COMMENT '消息ID',
+  `body` longtext NOT NULL COMMENT '消息体',
+  `error` longtext COMMENT '最后一次处理错误',
+  `attempts` int NOT NULL COMMENT '投递次数',
+  `status` int NOT NULL COMMENT '状态',
+  `replay_count` int NOT NULL DEFAULT '0' COMMENT '重放次数',
+  `replay_datetime` datetime(6) DEFAULT NULL COMMENT '最近重放时间',
+  `create_datetime` datetime(6) DEFAULT NULL COMMENT '创建时间',
+  `update_datetime` datetime(6) DEFAULT NULL COMMENT '修改时间',
+  PRIMARY KEY (`id`),
+  KEY `dvadmin_mq_dead_letter_topic` (`topic`),
+  KEY `dvadmin_mq_dead_letter_message_id` (`message_id`),
+  KEY `dvadmin_mq_dead_letter_status` (`status`),
+  KEY `dvadmin_mq_dead_letter_create_datetime` (`create_datetime`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb3 COMMENT='消息死信';
+
 -- ----------------------------
 -- Table structure for dvadmin_oil_gun
 -- ----------------------------
@@ -2231,6 +2411,8 @@ CREATE TABLE `dvadmin_order_detail` (
   `extra` json NOT NULL COMMENT '额外数据',
   `remarks` longtext COMMENT '备注',
   `buyer_id` varchar(255) DEFAULT NULL COMMENT '买家ID',
+  `risk_decision` varchar(16) DEFAULT NULL COMMENT '风控决策',
+  `risk_reason` varchar(255) DEFAULT NULL COMMENT '风控原因',
   `creator_id` bigint DEFAULT NULL COMMENT '创建人',
   `order_id` varchar(30) NOT NULL COMMENT '关联订单',
   `writeoff_id` bigint DEFAULT NULL COMMENT '核销',
@@ -2296,6 +2478,68 @@ CREATE TABLE `dvadmin_order_log` (
   KEY `dvadmin_order_log_creator_id_4fee3955` (`creator_id`)
 ) ENGINE=InnoDB AUTO_INCREMENT=374520 DEFAULT CHARSET=utf8mb3 COMMENT='订单日志';
 
+-- ----------------------------
+-- Table structure for dvadmin_order_refund
+-- ----------------------------
+DROP TABLE IF EXISTS `dvadmin_order_refund`;
+CREATE TABLE `dvadmin_order_refund` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `update_datetime` datetime(6) DEFAULT NULL COMMENT '修改时间',
+  `create_datetime` datetime(6) DEFAULT NULL COMMENT '创建时间',
+  `refund_no` varchar(64) NOT NULL COMMENT '退款单号',
+  `out_refund_no` varchar(64) DEFAULT NULL COMMENT '商户退款单号',
+  `order_id` varchar(30) NOT NULL COMMENT '关联订单',
+  `order_no` varchar(32) NOT NULL COMMENT '本系统订单号',
+  `merchant_id` bigint NOT NULL COMMENT '关联商户',
+  `refund_money` int NOT NULL COMMENT '退款金额(分)',
+  `refund_status` int NOT NULL DEFAULT '0' COMMENT '退款状态',
+  `reason` varchar(255) DEFAULT NULL COMMENT '退款原因',
+  `ticket_no` varchar(255) DEFAULT NULL COMMENT '官方流水号',
+  `error_message` varchar(255) DEFAULT NULL COMMENT '失败原因',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `refund_no` (`refund_no`),
+  KEY `dvadmin_order_refund_out_refund_no` (`merchant_id`,`out_refund_no`),
+  KEY `dvadmin_order_refund_order_id` (`order_id`,`refund_status`),
+  KEY `dvadmin_order_refund_order_no` (`order_no`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb3 COMMENT='订单退款';
+
+-- ----------------------------
+-- Table structure for dvadmin_order_statistics_record
+-- ----------------------------
+DROP TABLE IF EXISTS `dvadmin_order_statistics_record`;
+CREATE TABLE `dvadmin_order_statistics_record` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `order_id` varchar(30) NOT NULL COMMENT '订单ID',
+  `order_status` int NOT NULL COMMENT '订单状态',
+  `create_datetime` datetime(6) DEFAULT NULL COMMENT '创建时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_order_statistics_record` (`order_id`,`order_status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='订单统计记录（日统计事件幂等）';
+
+-- ----------------------------
+-- Table structure for dvadmin_outbox_event
+-- ----------------------------
+DROP TABLE IF EXISTS `dvadmin_outbox_event`;
+CREATE TABLE `dvadmin_outbox_event` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `topic` varchar(64) NOT NULL COMMENT '主题',
+  `tag` varchar(64) DEFAULT NULL COMMENT '标签',
+  `event_key` varchar(128) DEFAULT NULL COMMENT '业务键',
+  `body` longtext NOT NULL COMMENT '消息体',
+  `delayed` tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否延迟消息',
+  `deliver_datetime` datetime(6) NOT NULL COMMENT '期望投递时间',
+  `status` int NOT NULL DEFAULT '0' COMMENT '状态',
+  `attempts` int NOT NULL DEFAULT '0' COMMENT '发送次数',
+  `last_error` text COMMENT '最后一次发送错误',
+  `next_retry_datetime` datetime(6) NOT NULL COMMENT '下次发送时间',
+  `sent_datetime` datetime(6) DEFAULT NULL COMMENT '发送时间',
+  `create_datetime` datetime(6) DEFAULT NULL COMMENT '创建时间',
+  `update_datetime` datetime(6) DEFAULT NULL COMMENT '修改时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_outbox_status_retry` (`status`,`next_retry_datetime`),
+  KEY `idx_dvadmin_outbox_event_event_key` (`event_key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='事务发件箱';
+
 -- ----------------------------
 -- Table structure for dvadmin_pay_channel
 -- ----------------------------
@@ -2318,6 +2562,7 @@ CREATE TABLE `dvadmin_pay_channel` (
   `end_time` varchar(8) NOT NULL COMMENT '结束时间',
   `extra_arg` int DEFAULT NULL COMMENT '额外参数',
   `ban_ip` json NOT NULL COMMENT '封禁IP列表',
+  `access_policy` json DEFAULT NULL COMMENT '访问策略',
   `logo` longtext COMMENT '图标',
   `creator_id` bigint DEFAULT NULL COMMENT '创建人',
   `plugin_id` bigint NOT NULL COMMENT '支付插件',
@@ -3000,6 +3245,22 @@ CREATE TABLE `dvadmin_recharge_history` (
   KEY `dvadmin_recharge_history_user_id_c9e75c09` (`user_id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb3 COMMENT='自助充值记录';
 
+-- ----------------------------
+-- Table structure for dvadmin_risk_blacklist
+-- ----------------------------
+DROP TABLE IF EXISTS `dvadmin_risk_blacklist`;
+CREATE TABLE `dvadmin_risk_blacklist` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `list_type` varchar(16) NOT NULL COMMENT '名单类型',
+  `value` varchar(128) NOT NULL COMMENT '名单值',
+  `remarks` varchar(255) DEFAULT NULL COMMENT '备注',
+  `expire_datetime` datetime(6) DEFAULT NULL COMMENT '过期时间',
+  `create_datetime` datetime(6) DEFAULT NULL COMMENT '创建时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_risk_blacklist` (`list_type`,`value`),
+  KEY `idx_dvadmin_risk_blacklist_expire_datetime` (`expire_datetime`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='买家风控黑名单';
+
 -- ----------------------------
 -- Table structure for dvadmin_safe_book
 -- ----------------------------
@@ -4160,6 +4421,50 @@ CREATE TABLE `dvadmin_transfer_history` (
   KEY `dvadmin_transfer_history_order_id_74d4c7af` (`order_id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb3 COMMENT='转账记录';
 
+-- ----------------------------
+-- Table structure for dvadmin_wechat_product
+-- ----------------------------
+DROP TABLE IF EXISTS `dvadmin_wechat_product`;
+CREATE TABLE `dvadmin_wechat_product` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `update_datetime` datetime(6) DEFAULT NULL COMMENT '修改时间',
+  `create_datetime` datetime(6) DEFAULT NULL COMMENT '创建时间',
+  `name` varchar(255) NOT NULL COMMENT '产品名称',
+  `mch_id` varchar(32) NOT NULL COMMENT '商户号',
+  `app_id` varchar(32) NOT NULL COMMENT '应用ID',
+  `app_secret` varchar(64) DEFAULT NULL COMMENT '应用密钥(JSAPI网页授权)',
+  `serial_no` varchar(64) NOT NULL COMMENT '商户证书序列号',
+  `private_key` longtext COMMENT '商户API私钥',
+  `api_v3_key` varchar(32) DEFAULT NULL COMMENT 'APIv3密钥',
+  `public_key_id` varchar(64) DEFAULT NULL COMMENT '微信支付公钥ID',
+  `public_key` longtext COMMENT '微信支付公钥',
+  `gateway` varchar(255) DEFAULT NULL COMMENT '接口地址(为空使用官方地址)',
+  `subject` varchar(255) DEFAULT NULL COMMENT '订单主题',
+  `status` tinyint(1) NOT NULL DEFAULT '1' COMMENT '状态',
+  `is_delete` tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否删除',
+  `can_pay` tinyint(1) NOT NULL DEFAULT '1' COMMENT '是否允许进单',
+  `limit_money` int NOT NULL DEFAULT '0' COMMENT '限额',
+  `max_money` int NOT NULL DEFAULT '0' COMMENT '最大金额',
+  `min_money` int NOT NULL DEFAULT '0' COMMENT '最小金额',
+  `writeoff_id` bigint NOT NULL COMMENT '关联核销',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `name` (`name`),
+  KEY `dvadmin_wechat_product_writeoff_id` (`writeoff_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb3 COMMENT='微信支付产品';
+
+-- ----------------------------
+-- Table structure for dvadmin_wechat_product_allow_pay_channels
+-- ----------------------------
+DROP TABLE IF EXISTS `dvadmin_wechat_product_allow_pay_channels`;
+CREATE TABLE `dvadmin_wechat_product_allow_pay_channels` (
+  `id` int NOT NULL AUTO_INCREMENT,
+  `wechatproduct_id` bigint NOT NULL,
+  `paychannel_id` bigint NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `dvadmin_wechat_product_allow_pay_channels_uniq` (`wechatproduct_id`,`paychannel_id`),
+  KEY `dvadmin_wechat_product_allow_pay_channels_paychannel_id` (`paychannel_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb3;
+
 -- ----------------------------
 -- Table structure for dvadmin_wechat_account
 -- ----------------------------